@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/events"
+)
+
+func TestServer_AdminEvents(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server, baseURL, _ := startShutdownServer(t, slowUpstream(t, release))
+
+	received := make(chan events.Event, 16)
+	tailed := make(chan error, 1)
+	go func() {
+		tailed <- events.Tail(context.Background(), baseURL, testAdminKey, events.Filter{Model: "fast"}, func(e events.Event) {
+			received <- e
+		})
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for events.Default.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("tail did not subscribe to events")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	body, _ := openStream(t, baseURL)
+	io.Copy(io.Discard, body)
+	body.Close()
+
+	var types []string
+	var completed events.Event
+	for completed.Type == "" {
+		select {
+		case e := <-received:
+			types = append(types, e.Type)
+			if e.Type == events.RequestCompleted {
+				completed = e
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("request.completed not received, got %v", types)
+		}
+	}
+	want := []string{events.RequestReceived, events.RouteResolved, events.UpstreamConnected, events.FirstToken, events.RequestCompleted}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+	if !strings.HasPrefix(completed.RequestID, "req_") || completed.Protocol != "anthropic" || completed.Provider != "openai" ||
+		completed.UpstreamModel != "gpt-4o" || completed.Status != 200 || completed.InputTokens != 3 || completed.OutputTokens != 2 {
+		t.Errorf("unexpected request.completed: %+v", completed)
+	}
+
+	// Shutting down ends the event stream instead of waiting for it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error: %v", err)
+	}
+	if err := <-tailed; err != nil {
+		t.Errorf("Tail() error: %v", err)
+	}
+}
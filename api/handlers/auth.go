@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"ai-proxy/auth"
+	"ai-proxy/capture"
+	"ai-proxy/conversation"
+	"ai-proxy/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewAuthMiddleware creates a Gin middleware that authenticates clients by API key.
+// Requests without a valid "Authorization: Bearer" or "x-api-key" key are
+// rejected with 401 in the error format of the endpoint's protocol.
+//
+// @param a - Authenticator of the configured client keys. Must not be nil.
+// @return Gin middleware function that authenticates each request.
+//
+// @pre a != nil
+// @post On success, the identity is available via auth.FromContext(c.Request.Context())
+// and is recorded in the request capture.
+// @post On success, the client's key headers are removed so they are never forwarded upstream.
+func NewAuthMiddleware(a *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := a.Authenticate(c.Request)
+		if err != nil {
+			logging.InfoMsg("Rejected %s %s from %s: %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err)
+			sendAuthError(c, err)
+			c.Abort()
+			return
+		}
+
+		// Provider credentials are set per upstream; the client's own key must not leak
+		c.Request.Header.Del("Authorization")
+		c.Request.Header.Del("X-Api-Key")
+
+		ctx := c.Request.Context()
+		if cc := capture.GetCaptureContext(ctx); cc != nil {
+			cc.Recorder.SetIdentity(id.User, id.Org, id.Name)
+		}
+		c.Request = c.Request.WithContext(auth.WithIdentity(ctx, id))
+		c.Next()
+	}
+}
+
+// NewAdminMiddleware creates a Gin middleware that restricts the /admin
+// endpoints to admin client keys. It must run after NewAuthMiddleware.
+// Without authentication no client can present an admin key, so the
+// endpoints are closed: they could cancel other clients' streams, reload
+// the configuration and read everyone's usage.
+//
+// @return Gin middleware function that rejects non-admin clients with 403.
+func NewAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := auth.FromContext(c.Request.Context())
+		if id == nil {
+			sendOpenAIError(c, http.StatusForbidden, "The admin API requires an admin key; configure auth with an admin key to use it")
+			c.Abort()
+			return
+		}
+		if !id.Admin {
+			sendOpenAIError(c, http.StatusForbidden, "API key is not allowed to use the admin API")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// sendAuthError writes a 401 response for a failed authentication.
+// Anthropic Messages endpoints receive an Anthropic authentication_error;
+// all other endpoints receive an OpenAI invalid_api_key error.
+//
+// @param c - Gin context for writing the response.
+// @param err - auth.ErrMissingKey or auth.ErrInvalidKey.
+//
+// @pre c != nil and response has not been written.
+func sendAuthError(c *gin.Context, err error) {
+	msg := "Invalid API key"
+	if errors.Is(err, auth.ErrMissingKey) {
+		msg = "Missing API key: set the Authorization or x-api-key header"
+	}
+
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"type": "error",
+			"error": gin.H{
+				"type":    "authentication_error",
+				"message": msg,
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": gin.H{
+			"message": msg,
+			"type":    "invalid_request_error",
+			"code":    "invalid_api_key",
+		},
+	})
+}
+
+// ownedConversation looks up a stored conversation on behalf of the request's client.
+// Conversations owned by another user are reported as missing, so their IDs
+// cannot be probed.
+//
+// @param c - Gin context of the request.
+// @param id - Response ID of the conversation.
+// @return The conversation, or nil if it does not exist or belongs to another user.
+func ownedConversation(c *gin.Context, id string) *conversation.Conversation {
+	conv := conversation.GetFromDefault(id)
+	if conv == nil {
+		return nil
+	}
+	if user := auth.UserID(c.Request.Context()); user != "" && conv.UserID != "" && conv.UserID != user {
+		return nil
+	}
+	return conv
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/auth"
+	"ai-proxy/config"
+	"ai-proxy/conversation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestAuthenticator returns an authenticator with an unrestricted key for
+// "alice" and a key for "bob" restricted to "gpt-3.5".
+func newTestAuthenticator() *auth.Authenticator {
+	return auth.New(&config.AuthConfig{Keys: []config.ClientKey{
+		{Name: "alice-laptop", Key: "sk-alice", User: "alice", Org: "eng"},
+		{Name: "bob-ci", Key: "sk-bob", User: "bob", Models: []string{"gpt-3.5"}},
+	}})
+}
+
+func TestAuthMiddleware_Rejects(t *testing.T) {
+	engine := gin.New()
+	engine.Use(NewAuthMiddleware(newTestAuthenticator()))
+	engine.POST("/v1/messages", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.POST("/v1/chat/completions", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name      string
+		path      string
+		key       string
+		wantType  string
+		wantInMsg string
+	}{
+		{name: "anthropic missing key", path: "/v1/messages", wantType: "authentication_error", wantInMsg: "Missing API key"},
+		{name: "anthropic invalid key", path: "/v1/messages", key: "sk-wrong", wantType: "authentication_error", wantInMsg: "Invalid API key"},
+		{name: "openai missing key", path: "/v1/chat/completions", wantType: "invalid_request_error", wantInMsg: "Missing API key"},
+		{name: "openai invalid key", path: "/v1/chat/completions", key: "sk-wrong", wantType: "invalid_request_error", wantInMsg: "Invalid API key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{}`))
+			if tt.key != "" {
+				req.Header.Set("x-api-key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status 401, got %d", w.Code)
+			}
+			var body struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse error body: %v", err)
+			}
+			if body.Error.Type != tt.wantType || !strings.Contains(body.Error.Message, tt.wantInMsg) {
+				t.Errorf("unexpected error body: %s", w.Body.String())
+			}
+			if tt.path == "/v1/messages" && body.Type != "error" {
+				t.Errorf("expected Anthropic error envelope, got %s", w.Body.String())
+			}
+			if tt.path == "/v1/chat/completions" && body.Error.Code != "invalid_api_key" {
+				t.Errorf("expected code invalid_api_key, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_AuthenticatesAndStripsKey(t *testing.T) {
+	var upstream http.Header
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		upstream = req.Header.Clone()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStreamWithUsage)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	engine := gin.New()
+	engine.Use(NewAuthMiddleware(newTestAuthenticator()))
+	engine.POST("/v1/chat/completions", NewCompletionsHandler(&config.Config{}, newBreakerRouter(t, false)))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("X-Api-Key", "sk-alice")
+	w := newMockResponseWriter()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if upstream.Get("X-Api-Key") != "" {
+		t.Error("client API key must not be forwarded upstream")
+	}
+	if upstream.Get("Authorization") != "Bearer primary-key" {
+		t.Errorf("expected provider key upstream, got %q", upstream.Get("Authorization"))
+	}
+}
+
+func TestHandle_RejectsModelOutsideKeyAllowList(t *testing.T) {
+	calls := 0
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return errorResponse(http.StatusInternalServerError), nil
+	})
+
+	engine := gin.New()
+	engine.Use(NewAuthMiddleware(newTestAuthenticator()))
+	engine.POST("/v1/chat/completions", NewCompletionsHandler(&config.Config{}, newBreakerRouter(t, false)))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer sk-bob")
+	w := newMockResponseWriter()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "not allowed to use model 'gpt-4'") {
+		t.Errorf("unexpected error body: %s", w.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("expected no upstream call, got %d", calls)
+	}
+}
+
+func TestModelsHandler_FiltersByKeyAllowList(t *testing.T) {
+	cfg := &config.Config{AppConfig: &config.Schema{
+		Models: map[string]config.ModelConfig{
+			"gpt-4":   {Provider: "openai", Model: "gpt-4-turbo"},
+			"gpt-3.5": {Provider: "openai", Model: "gpt-3.5-turbo"},
+		},
+	}}
+	engine := gin.New()
+	engine.Use(NewAuthMiddleware(newTestAuthenticator()))
+	engine.GET("/v1/models", NewModelsHandler(cfg))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer sk-bob")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	var response ModelsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].ID != "gpt-3.5" {
+		t.Errorf("expected only gpt-3.5, got %+v", response.Data)
+	}
+}
+
+func TestResponseHandlers_HideOtherUsersConversations(t *testing.T) {
+	conversation.InitDefaultStore(conversation.Config{MaxSize: 100, TTL: time.Hour})
+	conversation.DefaultStore.Clear()
+	conversation.StoreInDefault(&conversation.Conversation{ID: "resp_alice", UserID: "alice", CreatedAt: time.Now()})
+
+	engine := gin.New()
+	engine.Use(NewAuthMiddleware(newTestAuthenticator()))
+	engine.GET("/v1/responses/:id", NewResponseGetHandler())
+	engine.GET("/v1/responses/:id/input_items", NewResponseInputItemsHandler())
+	engine.DELETE("/v1/responses/:id", NewResponseDeleteHandler())
+
+	send := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, tt := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/responses/resp_alice"},
+		{http.MethodGet, "/v1/responses/resp_alice/input_items"},
+		{http.MethodDelete, "/v1/responses/resp_alice"},
+	} {
+		if got := send(tt.method, tt.path, "sk-bob"); got != http.StatusNotFound {
+			t.Errorf("%s %s by another user: expected 404, got %d", tt.method, tt.path, got)
+		}
+	}
+	if conversation.GetFromDefault("resp_alice") == nil {
+		t.Fatal("another user must not delete the conversation")
+	}
+	if got := send(http.MethodGet, "/v1/responses/resp_alice", "sk-alice"); got != http.StatusOK {
+		t.Errorf("owner: expected 200, got %d", got)
+	}
+	if got := send(http.MethodDelete, "/v1/responses/resp_alice", "sk-alice"); got != http.StatusOK {
+		t.Errorf("owner delete: expected 200, got %d", got)
+	}
+}
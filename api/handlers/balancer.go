@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"ai-proxy/balance"
+	"ai-proxy/proxy"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BalancerHandler reports the usage of every provider API key.
+// Operators use it to see how traffic is spread across keys and which keys
+// are currently ejected after a 401 or 429.
+//
+// This handler:
+//   - Accepts GET requests
+//   - Returns in-flight, request, failure and ejection counters per key
+//   - Redacts keys to their last four characters
+type BalancerHandler struct {
+	modelRouter router.Router
+}
+
+// NewBalancerHandler creates a Gin handler for the GET /admin/balancer endpoint.
+//
+// @param r - Model router owning the provider keys. Must not be nil.
+// @return Gin handler function that reports key usage.
+func NewBalancerHandler(r router.Router) gin.HandlerFunc {
+	h := &BalancerHandler{modelRouter: r}
+	return h.Handle
+}
+
+// Handle writes the key usage snapshot as JSON.
+//
+// @param c - Gin context for the HTTP request.
+// @post Response body is {"keys": [...]} with status 200.
+func (h *BalancerHandler) Handle(c *gin.Context) {
+	keys := h.modelRouter.KeyStats()
+	if keys == nil {
+		keys = []balance.KeyStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// upstreamKey returns the load-balanced key of the handler's current route.
+func upstreamKey(h Handler) *balance.Key {
+	if bh, ok := h.(BalancedHandler); ok {
+		return bh.UpstreamKey()
+	}
+	return nil
+}
+
+// recordKeyOutcome records the final upstream outcome of a route on its key.
+// Requests cancelled by the client are not the key's fault and are not recorded.
+//
+// @param key - The route's key, may be nil.
+// @param resp - The upstream response, nil on transport error.
+// @param err - The transport error, nil if a response was received.
+func recordKeyOutcome(key *balance.Key, resp *http.Response, err error) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		key.Record(0, err, 0)
+		return
+	}
+	retryAfter, _ := proxy.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	key.Record(resp.StatusCode, nil, retryAfter)
+}
+
+// keyedClient is an upstream client that keeps its route's key in flight
+// until the client is closed.
+type keyedClient struct {
+	upstreamClient
+	key     *balance.Key
+	release sync.Once
+}
+
+// Close closes the underlying client and releases the key.
+func (c *keyedClient) Close() {
+	c.upstreamClient.Close()
+	c.release.Do(c.key.Release)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/balance"
+	"ai-proxy/config"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newBalancedRouter returns a real router serving "qwen" from a provider with two keys.
+func newBalancedRouter(t *testing.T) router.Router {
+	t.Helper()
+	r, err := router.NewRouter(&config.Schema{
+		Providers: []config.Provider{
+			{
+				Name:      "chutes",
+				Endpoints: map[string]string{"openai": "https://llm.chutes.ai/v1/chat/completions"},
+				APIKeys:   []string{"key-aaaaaaaa-1111", "key-bbbbbbbb-2222"},
+			},
+		},
+		Models: map[string]config.ModelConfig{
+			"qwen": {Provider: "chutes", Model: "Qwen/Qwen3"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() error: %v", err)
+	}
+	return r
+}
+
+func TestCompletionsHandler_RotatesKeyAfter429(t *testing.T) {
+	var auths []string
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		auth := req.Header.Get("Authorization")
+		auths = append(auths, auth)
+		if strings.HasSuffix(auth, "-1111") {
+			return errorResponse(http.StatusTooManyRequests), nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	r := newBalancedRouter(t)
+	request := func() *mockResponseWriter {
+		w := newMockResponseWriter()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+			`{"model":"qwen","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+		NewCompletionsHandler(&config.Config{}, r)(c)
+		return w
+	}
+
+	if w := request(); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if fmt.Sprint(auths) != "[Bearer key-aaaaaaaa-1111 Bearer key-bbbbbbbb-2222]" {
+		t.Fatalf("expected the rate-limited key to be followed by the second key, got %v", auths)
+	}
+
+	// The ejected key is skipped while the second key is used for new requests
+	auths = nil
+	request()
+	request()
+	if fmt.Sprint(auths) != "[Bearer key-bbbbbbbb-2222 Bearer key-bbbbbbbb-2222]" {
+		t.Errorf("expected only the second key while the first is ejected, got %v", auths)
+	}
+
+	stats := r.KeyStats()
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(stats))
+	}
+	if !stats[0].Ejected || stats[0].Failures != 1 || stats[0].Requests != 1 {
+		t.Errorf("unexpected stats for ejected key: %+v", stats[0])
+	}
+	if stats[1].Ejected || stats[1].Requests != 3 || stats[1].Failures != 0 {
+		t.Errorf("unexpected stats for healthy key: %+v", stats[1])
+	}
+	for _, s := range stats {
+		if s.InFlight != 0 {
+			t.Errorf("expected no in-flight requests after completion, got %+v", s)
+		}
+	}
+}
+
+func TestSendUpstream_HoldsKeyInFlightUntilClose(t *testing.T) {
+	r := newBalancedRouter(t)
+	plan, err := r.ResolvePlan("qwen", "openai")
+	if err != nil {
+		t.Fatalf("ResolvePlan() error: %v", err)
+	}
+	key := plan.Primary().Key
+
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		if key.InFlight() != 1 {
+			t.Errorf("expected key in flight during the upstream request, got %d", key.InFlight())
+		}
+		return sseOKResponse(), nil
+	})
+
+	h := &CompletionsHandler{route: plan.Primary(), plan: plan}
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	client := newUpstreamClient("https://example.com", key.Value())
+	req, _ := client.BuildRequest(c.Request.Context(), []byte(`{}`))
+	client, resp, ok := sendUpstream(c, h, client, req, []byte(`{}`), []byte(`{}`))
+	if !ok {
+		t.Fatal("sendUpstream() failed")
+	}
+	resp.Body.Close()
+	if key.InFlight() != 1 {
+		t.Errorf("expected key to stay in flight until the client is closed, got %d", key.InFlight())
+	}
+	client.Close()
+	client.Close()
+	if key.InFlight() != 0 {
+		t.Errorf("expected key released exactly once on close, got %d", key.InFlight())
+	}
+}
+
+func TestBalancerHandler(t *testing.T) {
+	mockR := newMockRouter()
+	mockR.keyStats = []balance.KeyStats{
+		{Provider: "chutes", Index: 0, Key: "...1111", InFlight: 2, Requests: 10, Failures: 1},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/balancer", nil)
+	NewBalancerHandler(mockR)(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var body struct {
+		Keys []balance.KeyStats `json:"keys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body.Keys) != 1 || body.Keys[0].Key != "...1111" || body.Keys[0].InFlight != 2 {
+		t.Errorf("unexpected keys: %+v", body.Keys)
+	}
+}
+
+func TestBalancerHandler_NoKeys(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/balancer", nil)
+	NewBalancerHandler(newMockRouter())(c)
+
+	if body := w.Body.String(); body != `{"keys":[]}` {
+		t.Errorf("expected empty key list, got %s", body)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ai-proxy/circuit"
+)
+
+// errFirstByteTimeout is returned when upstream response headers do not arrive
+// within the provider's first-byte timeout.
+var errFirstByteTimeout = errors.New("upstream first byte timeout")
+
+// upstreamBreaker returns the circuit breaker of the handler's current route.
+func upstreamBreaker(h Handler) *circuit.Breaker {
+	if bh, ok := h.(BreakerHandler); ok {
+		return bh.UpstreamBreaker()
+	}
+	return nil
+}
+
+// recordBreakerOutcome records the final upstream outcome of a route on its
+// provider's breaker. Transport errors, first-byte timeouts, 408 and 5xx count
+// as failures; any other response shows the provider is up. Requests cancelled
+// by the client only return their probe slot.
+//
+// @param b - The route's breaker, may be nil.
+// @param resp - The upstream response, nil on transport error.
+// @param err - The transport error, nil if a response was received.
+func recordBreakerOutcome(b *circuit.Breaker, resp *http.Response, err error) {
+	switch {
+	case err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		b.Cancel()
+	case err != nil:
+		b.Failure(err.Error())
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= http.StatusInternalServerError:
+		b.Failure(fmt.Sprintf("status %d", resp.StatusCode))
+	default:
+		b.Success()
+	}
+}
+
+// doWithFirstByteTimeout sends a request, failing it with errFirstByteTimeout if
+// the response headers do not arrive within timeout. Once headers have arrived
+// the timeout no longer applies, so long streams are not cut off.
+//
+// @param client - Upstream client.
+// @param req - Request to send.
+// @param timeout - First-byte timeout; 0 sends the request without one.
+// @return The response or transport error, as from client.Do.
+func doWithFirstByteTimeout(client upstreamClient, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return client.Do(req)
+	}
+
+	// The context outlives this call: cancelling it would abort the response body
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(timeout, func() { cancel(errFirstByteTimeout) })
+	resp, err := client.Do(req.WithContext(ctx))
+	if timer.Stop() {
+		if resp == nil {
+			cancel(nil)
+			return resp, err
+		}
+		// Release the context once the body is done with
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+		return resp, err
+	}
+
+	// The timer fired; the request context is cancelled even if headers made it
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err == nil || errors.Is(context.Cause(ctx), errFirstByteTimeout) {
+		return nil, fmt.Errorf("%w after %v", errFirstByteTimeout, timeout)
+	}
+	return nil, err
+}
+
+// cancelOnCloseBody cancels the context of a response's request when the
+// response body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+// Close closes the body, then cancels the request context.
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
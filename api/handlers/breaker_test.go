@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/circuit"
+	"ai-proxy/config"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newBreakerRouter returns a real router serving "gpt-4" from a provider whose
+// breaker opens after two failures, with an optional fallback provider.
+func newBreakerRouter(t *testing.T, withFallback bool) router.Router {
+	t.Helper()
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{
+				Name:           "primary",
+				Endpoints:      map[string]string{"openai": "https://primary.example.com/v1/chat/completions"},
+				APIKey:         "primary-key",
+				CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: "1m"},
+			},
+			{
+				Name:      "backup",
+				Endpoints: map[string]string{"openai": "https://backup.example.com/v1/chat/completions"},
+				APIKey:    "backup-key",
+			},
+		},
+		Models: map[string]config.ModelConfig{
+			"gpt-4": {Provider: "primary", Model: "gpt-4"},
+		},
+	}
+	if withFallback {
+		schema.Models["gpt-4"] = config.ModelConfig{
+			Provider:  "primary",
+			Model:     "gpt-4",
+			Fallbacks: []config.FallbackRoute{{Provider: "backup", Model: "gpt-4o"}},
+		}
+	}
+	r, err := router.NewRouter(schema)
+	if err != nil {
+		t.Fatalf("NewRouter() error: %v", err)
+	}
+	return r
+}
+
+// sendCompletion runs a streaming chat completion for "gpt-4" through the handler.
+func sendCompletion(r router.Router) *mockResponseWriter {
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	NewCompletionsHandler(&config.Config{}, r)(c)
+	return w
+}
+
+func TestCompletionsHandler_OpenBreakerFailsFast(t *testing.T) {
+	calls := 0
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return errorResponse(http.StatusServiceUnavailable), nil
+	})
+
+	r := newBreakerRouter(t, false)
+	sendCompletion(r)
+	sendCompletion(r)
+	if calls != 2 {
+		t.Fatalf("expected 2 upstream calls before the breaker opens, got %d", calls)
+	}
+
+	w := sendCompletion(r)
+	if calls != 2 {
+		t.Errorf("expected no upstream call while the breaker is open, got %d calls", calls)
+	}
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "circuit breaker is open") {
+		t.Errorf("expected 503 circuit breaker error, got %d: %s", w.Code, w.Body.String())
+	}
+
+	health := r.ProviderHealth()
+	if health[0].State != circuit.StateOpen || health[0].LastError != "status 503" || health[0].ErrorRate != 1 {
+		t.Errorf("unexpected primary health: %+v", health[0])
+	}
+}
+
+func TestCompletionsHandler_OpenBreakerSkipsToFallback(t *testing.T) {
+	var models []string
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		model, _ := upstreamModel(t, req)
+		models = append(models, model)
+		if model == "gpt-4" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	r := newBreakerRouter(t, true)
+	for i := 0; i < 3; i++ {
+		if w := sendCompletion(r); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200 from the fallback, got %d", i, w.Code)
+		}
+	}
+
+	if fmt.Sprint(models) != "[gpt-4 gpt-4o gpt-4 gpt-4o gpt-4o]" {
+		t.Errorf("expected the open primary to be skipped on the third request, got %v", models)
+	}
+}
+
+func TestSendUpstream_OpenBreakerOnLastRoute(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		t.Error("upstream must not be called while the breaker is open")
+		return sseOKResponse(), nil
+	})
+
+	breaker := circuit.New("primary", &config.CircuitBreakerConfig{FailureThreshold: 1})
+	breaker.Failure("status 500")
+	route := &router.ResolvedRoute{Model: "gpt-4", Breaker: breaker}
+	h := &CompletionsHandler{route: route, plan: &router.RoutePlan{Routes: []*router.ResolvedRoute{route}}}
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	client := newUpstreamClient("https://example.com", "key")
+	req, _ := client.BuildRequest(c.Request.Context(), []byte(`{}`))
+	if _, _, ok := sendUpstream(c, h, client, req, []byte(`{}`), []byte(`{}`)); ok {
+		t.Fatal("expected sendUpstream to fail")
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
+
+func TestRecordBreakerOutcome(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      *http.Response
+		err       error
+		wantState circuit.State
+		wantError string
+	}{
+		{name: "success", resp: &http.Response{StatusCode: 200}, wantState: circuit.StateClosed},
+		{name: "client error shows provider is up", resp: &http.Response{StatusCode: 400}, wantState: circuit.StateClosed},
+		{name: "rate limit shows provider is up", resp: &http.Response{StatusCode: 429}, wantState: circuit.StateClosed},
+		{name: "server error", resp: &http.Response{StatusCode: 502}, wantState: circuit.StateOpen, wantError: "status 502"},
+		{name: "request timeout", resp: &http.Response{StatusCode: 408}, wantState: circuit.StateOpen, wantError: "status 408"},
+		{name: "connection error", err: errors.New("connection refused"), wantState: circuit.StateOpen, wantError: "connection refused"},
+		{name: "first byte timeout", err: fmt.Errorf("%w after 1s", errFirstByteTimeout), wantState: circuit.StateOpen, wantError: "upstream first byte timeout after 1s"},
+		{name: "client cancellation", err: context.Canceled, wantState: circuit.StateClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := circuit.New("p", &config.CircuitBreakerConfig{FailureThreshold: 1})
+			recordBreakerOutcome(b, tt.resp, tt.err)
+			stats := b.Stats()
+			if stats.State != tt.wantState || stats.LastError != tt.wantError {
+				t.Errorf("state=%s lastError=%q, want state=%s lastError=%q", stats.State, stats.LastError, tt.wantState, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestDoWithFirstByteTimeout(t *testing.T) {
+	slow := &fakeUpstreamClient{do: func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}}
+	req := httptest.NewRequest(http.MethodPost, "https://example.com", nil)
+
+	_, err := doWithFirstByteTimeout(slow, req, 10*time.Millisecond)
+	if !errors.Is(err, errFirstByteTimeout) {
+		t.Fatalf("expected first byte timeout, got %v", err)
+	}
+	if !isFallbackFailure(nil, err) {
+		t.Error("a first byte timeout should fall back to the next route")
+	}
+
+	var bodyCtx context.Context
+	fast := &fakeUpstreamClient{do: func(req *http.Request) (*http.Response, error) {
+		bodyCtx = req.Context()
+		return sseOKResponse(), nil
+	}}
+	resp, err := doWithFirstByteTimeout(fast, req, 10*time.Millisecond)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected fast response to pass, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if bodyCtx.Err() != nil {
+		t.Error("the timeout must not cancel a response whose headers arrived in time")
+	}
+	// Closing the body releases the request context
+	resp.Body.Close()
+	if bodyCtx.Err() == nil {
+		t.Error("expected closing the body to cancel the request context")
+	}
+
+	// Client cancellation is reported as such, not as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = doWithFirstByteTimeout(slow, req.WithContext(ctx), time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -10,10 +10,13 @@ import (
 	"net/http"
 	"strings"
 
+	"ai-proxy/auth"
 	"ai-proxy/capture"
 	"ai-proxy/logging"
 	"ai-proxy/proxy"
+	"ai-proxy/stream"
 	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tmaxmax/go-sse"
@@ -26,8 +29,8 @@ type upstreamClient interface {
 	Close()
 }
 
-var newUpstreamClient = func(baseURL, apiKey string) upstreamClient {
-	return proxy.NewClient(baseURL, apiKey)
+var newUpstreamClient = func(baseURL, apiKey string, opts ...proxy.ClientOption) upstreamClient {
+	return proxy.NewClient(baseURL, apiKey, opts...)
 }
 
 // timingCaptureWriter wraps an io.Writer and captures SSE events with accurate timing.
@@ -42,6 +45,23 @@ type timingCaptureWriter struct {
 	cw capture.CaptureWriter
 	// buffer for accumulating partial SSE events
 	buf bytes.Buffer
+	// lines records each newline-terminated line as a chunk, for NDJSON streams
+	lines bool
+}
+
+// countingWriter counts the bytes written through it.
+//
+// Thread Safety: NOT thread-safe. Use from single goroutine.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write implements io.Writer.
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
 }
 
 // newTimingCaptureWriter creates a writer that captures SSE events with timing.
@@ -72,9 +92,26 @@ func (tcw *timingCaptureWriter) Write(p []byte) (n int, err error) {
 
 // parseAndRecordEvents parses complete SSE events from the buffer and records them.
 // SSE events are delimited by "\n\n". Each event may have "event:" and "data:" lines.
+// In lines mode, each complete NDJSON line is recorded as is.
 func (tcw *timingCaptureWriter) parseAndRecordEvents() {
 	data := tcw.buf.Bytes()
 
+	if tcw.lines {
+		for {
+			idx := bytes.IndexByte(data, '\n')
+			if idx == -1 {
+				break
+			}
+			if line := bytes.TrimSpace(data[:idx]); len(line) > 0 {
+				tcw.cw.RecordChunk("", line)
+			}
+			data = data[idx+1:]
+		}
+		tcw.buf.Reset()
+		tcw.buf.Write(data)
+		return
+	}
+
 	// Find complete events (ending with \n\n)
 	for {
 		idx := bytes.Index(data, []byte("\n\n"))
@@ -135,7 +172,7 @@ func parseSSEEvent(event []byte) (eventType string, data []byte) {
 // The processing flow is:
 //  1. Read request body from client
 //  2. Record downstream request for capture
-//  3. Validate request format
+//  3. Validate request format, the client's access to the model, and its limits
 //  4. Transform request to upstream format
 //  5. Forward to upstream and stream response back
 //
@@ -149,6 +186,10 @@ func parseSSEEvent(event []byte) (eventType string, data []byte) {
 // @post Response is fully written to client on return (success or error).
 func Handle(h Handler) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Request metrics are recorded once the response has been written
+		m := startRequestMetrics(c)
+		defer m.finish(c, h)
+
 		// Step 1: Read the complete request body for processing
 		body, err := readBody(c)
 		if err != nil {
@@ -161,12 +202,39 @@ func Handle(h Handler) gin.HandlerFunc {
 		// This captures the original request before any transformation
 		capture.RecordDownstreamRequest(c.Request.Context(), c.Request, body)
 
+		// Non-streaming clients are served by streaming from upstream and
+		// aggregating the transformed output into a single JSON body
+		var agg aggregate.Aggregator
+		if ah, ok := h.(AggregatingHandler); ok && isNonStreamingRequest(body) {
+			if streamingBody, err := enableStreaming(body); err == nil {
+				body = streamingBody
+				agg = ah.NewAggregator()
+			}
+		}
+
 		// Step 3: Validate request format and semantics
 		if err := h.ValidateRequest(body); err != nil {
 			// Validation failure indicates client error (400-level response)
 			h.WriteError(c, http.StatusBadRequest, err.Error())
 			return
 		}
+		// Lifecycle events are published from here on, for /admin/events subscribers
+		m.received(c, h)
+
+		// Authenticated clients may be restricted to a list of models
+		if model, _ := h.ModelInfo(); !auth.FromContext(c.Request.Context()).AllowsModel(model) {
+			h.WriteError(c, http.StatusForbidden, fmt.Sprintf("API key is not allowed to use model '%s'", model))
+			return
+		}
+
+		// Clients are held to their request rate limits and token/spend quotas.
+		// Once the response is complete its upstream usage is priced, charged
+		// to the quotas and recorded in the usage ledger
+		limiters, ok := admitRequest(c, h)
+		if !ok {
+			return
+		}
+		defer settleRequest(c, h, limiters)
 
 		// Step 4: Transform request to upstream format
 		transformedBody, err := h.TransformRequest(c.Request.Context(), body)
@@ -176,8 +244,21 @@ func Handle(h Handler) gin.HandlerFunc {
 			return
 		}
 
-		// Step 5: Forward to upstream and stream response
-		proxyRequest(c, h, transformedBody)
+		// The upstream request inherits a context that /admin/streams and a server
+		// shutdown can interrupt, so cancelled streams are ended properly
+		ctx, interrupt := context.WithCancelCause(c.Request.Context())
+		defer interrupt(nil)
+		c.Request = c.Request.WithContext(ctx)
+		defer registerRequest(c, h, agg == nil, interrupt)()
+
+		// Step 5: Forward to upstream and stream (or aggregate) the response
+		if agg != nil {
+			proxyAggregatedRequest(c, h, body, transformedBody, agg)
+			return
+		}
+		// The cost of a stream is only known once it has ended
+		c.Header("Trailer", CostHeader)
+		proxyRequest(c, h, body, transformedBody)
 	}
 }
 
@@ -194,6 +275,35 @@ func readBody(c *gin.Context) ([]byte, error) {
 	return io.ReadAll(c.Request.Body)
 }
 
+// isNonStreamingRequest reports whether the request explicitly sets "stream": false.
+// Requests without a stream field keep the proxy's historical streaming behavior.
+//
+// @param body - Raw JSON request body.
+// @return true only if the stream field is present and false.
+func isNonStreamingRequest(body []byte) bool {
+	var req struct {
+		Stream *bool `json:"stream"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	return req.Stream != nil && !*req.Stream
+}
+
+// enableStreaming rewrites the request body with "stream": true so the upstream
+// request streams regardless of what the client asked for.
+//
+// @param body - Raw JSON request body.
+// @return The rewritten body, or error if body is not a JSON object.
+func enableStreaming(body []byte) ([]byte, error) {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	req["stream"] = json.RawMessage("true")
+	return json.Marshal(req)
+}
+
 // validateStreaming checks that the request has streaming enabled.
 // Returns an error if the request is not configured for streaming.
 //
@@ -224,112 +334,428 @@ func validateStreaming(body []byte) error {
 //
 // @param c - Gin context for the current request.
 // @param h - Handler defining upstream URL, headers, and error handling.
+// @param original - Request body before TransformRequest, re-transformed for fallback routes.
 // @param body - Transformed request body to send upstream.
 //
 // @pre body is in correct upstream format.
 // @pre h.UpstreamURL() returns valid URL.
 // @post Response is streamed to client or error response is sent.
-func proxyRequest(c *gin.Context, h Handler, body []byte) {
-	// Resolve API key for upstream authentication
-	apiKey := h.ResolveAPIKey(c)
-
-	// Create HTTP client configured for upstream endpoint
-
-	// Log request with model info for debugging
-	downstreamModel, upstreamModel := h.ModelInfo()
-	logging.InfoMsg("Sending request to upstream: %s (downstream_model=%s, upstream_model=%s)", h.UpstreamURL(), downstreamModel, upstreamModel)
-	client := newUpstreamClient(h.UpstreamURL(), apiKey)
-	// Ensure connection resources are released when done
-	defer client.Close()
-
-	// Build the upstream HTTP request
-	req, err := client.BuildRequest(c.Request.Context(), body)
-	if err != nil {
-		// Request build failure indicates internal error
-		h.WriteError(c, http.StatusInternalServerError, "Failed to create upstream request")
+func proxyRequest(c *gin.Context, h Handler, original, body []byte) {
+	client, req, ok := prepareUpstreamRequest(c, h, body)
+	if !ok {
 		return
 	}
 
-	// Set standard headers required by upstream API
-	client.SetHeaders(req)
-	// Forward custom headers from original request
-	h.ForwardHeaders(c, req)
-
 	// Check if capture is enabled and route to appropriate streaming method
 	// Capture context is attached by CaptureMiddleware if capture is enabled
 	cc := capture.GetCaptureContext(c.Request.Context())
 
 	// Set up SSE stream headers before creating transformer and making upstream request
 	setStreamHeaders(c)
+	if sf, ok := h.(StreamFormatHandler); ok {
+		c.Header("Content-Type", sf.StreamContentType())
+	}
 
 	// Create and initialize transformer BEFORE upstream request
 	// This ensures response.created is emitted before any upstream response
+	// For capture mode, the transformer is created inside streamWithCapture instead.
+	// While fallback routes remain, the upstream protocol may still change, so the
+	// transformer is only created once an upstream has accepted the request.
 	var transformer transform.SSETransformer
-	var responseID string
+	if cc == nil && !hasNextRoute(h) {
+		var cleanup func()
+		transformer, cleanup, ok = startTransformer(c, h)
+		if !ok {
+			client.Close()
+			return
+		}
+		defer cleanup()
+	}
+
+	// Execute the upstream request, retrying and falling back per configuration
+	client, resp, ok := sendUpstream(c, h, client, req, original, body)
+	if !ok {
+		return
+	}
+	// Ensure connection resources are released when done
+	defer client.Close()
 
 	if cc != nil {
-		// For capture mode, we need special handling - transformer created inside c.Stream
-		// We'll initialize it there before reading from upstream
-		transformer = nil
-	} else {
-		// Create transformer without capture wrapper
-		transformer = h.CreateTransformer(c.Writer)
-		// Set context for cache status tracking
-		setContextOnTransformer(transformer, c.Request.Context())
-		// Initialize transformer and emit response.created before upstream call
-		if err := transformer.Initialize(); err != nil {
-			logging.ErrorMsg("Failed to initialize transformer: %v", err)
-			h.WriteError(c, http.StatusInternalServerError, "Failed to initialize response stream")
+		// Stream with capture when capture is enabled
+		// Transformer is created and initialized inside streamWithCapture
+		streamWithCapture(c, upstreamBody(h, resp), h, cc)
+		return
+	}
+
+	if transformer == nil {
+		var cleanup func()
+		transformer, cleanup, ok = startTransformer(c, h)
+		if !ok {
 			return
 		}
-		defer transformer.Close()
+		defer cleanup()
+	}
+	// Stream without capture for lower latency
+	// Transformer is already initialized, just stream events
+	streamWithInitializedTransformer(c, upstreamBody(h, resp), transformer)
+}
 
-		// Get response ID for stream cancellation registration
-		if getter, ok := transformer.(transform.ResponseIDGetter); ok {
-			responseID = getter.GetResponseID()
-		}
+// startTransformer creates the handler's transformer on the client connection,
+// initializes it (emitting e.g. response.created), and registers it for cancellation.
+//
+// @param c - Gin context for the current request.
+// @param h - Handler providing the SSE transformer.
+// @return The transformer, a cleanup function to defer, and false if an error response was written.
+func startTransformer(c *gin.Context, h Handler) (transform.SSETransformer, func(), bool) {
+	// Create transformer without capture wrapper
+	transformer := h.CreateTransformer(c.Writer)
+	// Set context for cache status tracking
+	setContextOnTransformer(transformer, c.Request.Context())
+	if err := transformer.Initialize(); err != nil {
+		logging.ErrorMsg("Failed to initialize transformer: %v", err)
+		h.WriteError(c, http.StatusInternalServerError, "Failed to initialize response stream")
+		return nil, nil, false
 	}
 
 	// Register stream for cancellation support if we have a response ID
-	var cancel context.CancelFunc
-	if responseID != "" {
-		registry := GetGlobalRegistry()
-		var streamCtx context.Context
-		streamCtx, cancel = context.WithCancel(c.Request.Context())
-		c.Request = c.Request.WithContext(streamCtx)
-		registry.Register(responseID, cancel, transformer)
-		defer func() {
-			registry.Remove(responseID)
-			if cancel != nil {
-				cancel()
-			}
-		}()
+	unregister := registerStream(c, transformer)
+	return transformer, func() {
+		unregister()
+		transformer.Close()
+	}, true
+}
+
+// proxyAggregatedRequest forwards a "stream": false request upstream as a streaming
+// request and returns the assembled result as a single JSON response.
+// The transformer chain runs exactly as in streaming mode but writes into agg
+// instead of the client connection, so no headers are committed until the
+// upstream stream has been fully consumed.
+//
+// @param c - Gin context for the current request.
+// @param h - Handler defining upstream URL, headers, and error handling.
+// @param original - Request body before TransformRequest, re-transformed for fallback routes.
+// @param body - Transformed request body to send upstream (with streaming enabled).
+// @param agg - Aggregator for the handler's downstream protocol.
+//
+// @pre body is in correct upstream format.
+// @post A JSON response or error response is written to the client.
+func proxyAggregatedRequest(c *gin.Context, h Handler, original, body []byte, agg aggregate.Aggregator) {
+	client, req, ok := prepareUpstreamRequest(c, h, body)
+	if !ok {
+		return
 	}
 
-	// Execute the upstream request
-	resp, err := client.Do(req)
-	if err != nil {
-		// Upstream connection failure indicates gateway error
-		h.WriteError(c, http.StatusBadGateway, "Upstream request failed")
+	// The transformer is created after the upstream accepted the request, so it
+	// matches the protocol of the route that finally served it
+	client, resp, ok := sendUpstream(c, h, client, req, original, body)
+	if !ok {
 		return
 	}
+	defer client.Close()
+
+	cc := capture.GetCaptureContext(c.Request.Context())
+
+	// Nothing reaches the client before the end, so progress counts the
+	// transformer output fed into the aggregator
+	fed := &countingWriter{w: agg}
+
+	// In capture mode the transformer output is recorded on its way into the aggregator
+	var out io.Writer = fed
+	var downstream, upstream capture.CaptureWriter
+	var timingWriter *timingCaptureWriter
+	if cc != nil {
+		downstream = capture.NewCaptureWriter(cc.StartTime)
+		upstream = capture.NewCaptureWriter(cc.StartTime)
+		timingWriter = newTimingCaptureWriter(fed, downstream)
+		out = timingWriter
+	}
 
-	// Check for non-200 responses from upstream
-	// Non-OK status indicates upstream error (auth, rate limit, etc.)
-	if resp.StatusCode != http.StatusOK {
-		handleUpstreamError(c, resp)
+	transformer := h.CreateTransformer(out)
+	setContextOnTransformer(transformer, c.Request.Context())
+	if err := transformer.Initialize(); err != nil {
+		logging.ErrorMsg("Failed to initialize transformer: %v", err)
+		h.WriteError(c, http.StatusInternalServerError, "Failed to initialize response stream")
 		return
 	}
+	defer registerStream(c, transformer)()
+
+	m := requestMetricsFrom(c)
+	defer m.streamEnded()
+	for ev, err := range sse.Read(upstreamBody(h, resp), nil) {
+		if err != nil {
+			if endInterruptedStream(c, transformer) {
+				break
+			}
+			if errors.Is(err, context.Canceled) {
+				logging.DebugMsg("Aggregation stopped, client disconnected")
+				transformer.Close()
+				return
+			}
+			logging.ErrorMsg("SSE stream error (aggregate): %v", err)
+			m.streamFailed(err)
+			emitStreamError(transformer, err)
+			break
+		}
+		if cc != nil {
+			recordUpstreamEvent(upstream, ev)
+		}
+		m.upstreamEvent(ev.Data)
+		if err := transformer.Transform(&ev); err != nil {
+			logging.ErrorMsg("Transform error (aggregate): %v", err)
+			emitStreamError(transformer, err)
+			break
+		}
+		m.progressed(fed.n)
+	}
+	transformer.Close()
+	m.streamEnded()
 
 	if cc != nil {
-		// Stream with capture when capture is enabled
-		// Transformer is created and initialized inside streamWithCapture
-		streamWithCapture(c, resp.Body, h, cc)
-	} else {
-		// Stream without capture for lower latency
-		// Transformer is already initialized, just stream events
-		streamWithInitializedTransformer(c, resp.Body, transformer)
+		timingWriter.FlushRemaining()
+		finalizeCapture(cc, downstream, upstream)
+	}
+
+	result, err := agg.Result()
+	if err != nil {
+		h.WriteError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	setCostHeader(c, requestTotals(c, h))
+	c.Data(http.StatusOK, "application/json", result)
+}
+
+// sendUpstream sends the prepared request and returns the upstream's 200 response.
+// Failed attempts are retried per the provider's retry policy. When the route
+// still fails and the handler has a fallback route, the original body is
+// transformed for the next route and sent there; this only happens before any
+// upstream response data has been streamed to the client.
+// Routes whose provider's circuit breaker is open are skipped; if the last
+// route's breaker is open, 503 is written without contacting the upstream.
+// A 401 to a key fetched from a credential source drops that key and retries
+// the route once with a fresh one.
+// If no route succeeds, the last failure is written to the client.
+//
+// @param c - Gin context for the current request.
+// @param h - Handler defining upstream URL, headers, and error handling.
+// @param client - Client built by prepareUpstreamRequest for the current route.
+// @param req - Request built by prepareUpstreamRequest for the current route.
+// @param original - Request body before TransformRequest.
+// @param body - Transformed request body for the current route.
+// @return The client that served the request, its response, and false if an error response was written.
+//
+// @post On failure every client has been closed.
+// @post On success the caller must Close() the returned client.
+func sendUpstream(c *gin.Context, h Handler, client upstreamClient, req *http.Request, original, body []byte) (upstreamClient, *http.Response, bool) {
+	// credentialRetried is set once the current route retried with a fresh credential
+	credentialRetried := false
+	for {
+		breaker := upstreamBreaker(h)
+		if breaker.Allow() {
+			key := upstreamKey(h)
+			key.Acquire()
+			resp, err := doUpstream(c, h, client, req, body)
+			if err == nil {
+				requestMetricsFrom(c).upstreamResponded(h)
+			}
+			requestMetricsFrom(c).upstreamAttempted(resp, err)
+			recordKeyOutcome(key, resp, err)
+			recordBreakerOutcome(breaker, resp, err)
+			if err == nil && resp.StatusCode == http.StatusOK {
+				return &keyedClient{upstreamClient: client, key: key}, resp, true
+			}
+			key.Release()
+
+			if err == nil && resp.StatusCode == http.StatusUnauthorized && !credentialRetried && invalidateCredential(h) {
+				// The fetched key was revoked or expired early; retry once with a fresh one
+				logging.InfoMsg("Upstream %s rejected the fetched credential, retrying with a fresh one", h.UpstreamURL())
+				credentialRetried = true
+				io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+				resp.Body.Close()
+				client.Close()
+
+				var ok bool
+				client, req, ok = prepareUpstreamRequest(c, h, body)
+				if !ok {
+					return nil, nil, false
+				}
+				continue
+			}
+
+			if !hasNextRoute(h) || !isFallbackFailure(resp, err) {
+				if err != nil {
+					// Upstream connection failure indicates gateway error
+					h.WriteError(c, http.StatusBadGateway, "Upstream request failed")
+				} else {
+					// Non-OK status indicates upstream error (auth, rate limit, etc.)
+					handleUpstreamError(c, resp, upstreamDialect(h))
+				}
+				client.Close()
+				return nil, nil, false
+			}
+
+			logging.InfoMsg("Upstream %s failed (%s), falling back to next route", h.UpstreamURL(), attemptOutcome(resp, err))
+			if resp != nil {
+				io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+				resp.Body.Close()
+			}
+		} else {
+			requestMetricsFrom(c).upstreamAttempted(nil, fmt.Errorf("circuit breaker of provider %s is open", breaker.Provider()))
+			if !hasNextRoute(h) {
+				// The provider is known to be failing; fail fast instead of waiting on it
+				h.WriteError(c, http.StatusServiceUnavailable, "Upstream circuit breaker is open")
+				client.Close()
+				return nil, nil, false
+			}
+			logging.InfoMsg("Circuit breaker of provider %s is open, falling back to next route", breaker.Provider())
+		}
+		client.Close()
+
+		h.(FallbackHandler).NextRoute()
+		credentialRetried = false
+		requestMetricsFrom(c).routeResolved(h)
+		var err error
+		body, err = h.TransformRequest(c.Request.Context(), original)
+		if err != nil {
+			h.WriteError(c, http.StatusInternalServerError, "Failed to transform request")
+			return nil, nil, false
+		}
+
+		var ok bool
+		client, req, ok = prepareUpstreamRequest(c, h, body)
+		if !ok {
+			return nil, nil, false
+		}
+	}
+}
+
+// hasNextRoute reports whether the handler can fall back to another route.
+func hasNextRoute(h Handler) bool {
+	fh, ok := h.(FallbackHandler)
+	return ok && fh.HasNextRoute()
+}
+
+// isFallbackFailure reports whether a failed upstream attempt should move the
+// request to the next route. Transport errors and provider-side statuses fall
+// back; client errors such as 400 would fail on any route and are returned as-is.
+// A cancelled client request never falls back.
+//
+// @param resp - The upstream response, nil on transport error.
+// @param err - The transport error, nil if a response was received.
+// @return true if the next route should be tried.
+func isFallbackFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+		http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// prepareUpstreamRequest creates the upstream client and builds the request
+// with standard and forwarded headers. A route's key from a credential source
+// is fetched first; if that fails, 502 is written.
+//
+// @param c - Gin context for the current request.
+// @param h - Handler defining upstream URL, headers, and error handling.
+// @param body - Transformed request body to send upstream.
+// @return The client, the built request, and false if an error response was written.
+//
+// @post On success the caller must Close() the returned client.
+func prepareUpstreamRequest(c *gin.Context, h Handler, body []byte) (upstreamClient, *http.Request, bool) {
+	// Fetch the provider's key first if it comes from a credential source
+	if err := fetchCredential(c.Request.Context(), h); err != nil {
+		logging.ErrorMsg("Failed to fetch upstream credential: %v", err)
+		h.WriteError(c, http.StatusBadGateway, "Failed to fetch upstream credential")
+		return nil, nil, false
+	}
+
+	// Resolve API key for upstream authentication
+	apiKey := h.ResolveAPIKey(c)
+
+	// Log request with model info for debugging
+	downstreamModel, upstreamModel := h.ModelInfo()
+	logging.InfoMsg("Sending request to upstream: %s (downstream_model=%s, upstream_model=%s)", h.UpstreamURL(), downstreamModel, upstreamModel)
+
+	// Create HTTP client configured for upstream endpoint
+	client := newUpstreamClient(h.UpstreamURL(), apiKey, upstreamClientOptions(h)...)
+
+	// Build the upstream HTTP request
+	req, err := client.BuildRequest(c.Request.Context(), body)
+	if err != nil {
+		client.Close()
+		// Request build failure indicates internal error
+		h.WriteError(c, http.StatusInternalServerError, "Failed to create upstream request")
+		return nil, nil, false
+	}
+
+	// Forward custom headers from original request
+	h.ForwardHeaders(c, req)
+	// Set standard, auth and provider headers, which win over forwarded ones
+	client.SetHeaders(req)
+
+	return client, req, true
+}
+
+// registerStream registers the transformer in the global stream registry so the
+// response can be cancelled via POST /v1/responses/{id}/cancel.
+// Requests served by Handle are registered already; the transformer is attached
+// to their entry. Otherwise only transformers with a response ID are registered.
+//
+// @param c - Gin context; for requests not registered by Handle, its request
+// context is replaced with a cancellable one.
+// @param transformer - Initialized transformer for the current request.
+// @return Cleanup function that unregisters the stream. Never nil.
+func registerStream(c *gin.Context, transformer transform.SSETransformer) func() {
+	registry := GetGlobalRegistry()
+	if m := requestMetricsFrom(c); m != nil && m.active != nil {
+		id := m.active.ID
+		registry.Attach(id, transformer)
+		m.transformer = transformer
+		return func() {
+			registry.Attach(id, nil)
+		}
+	}
+
+	var responseID string
+	if getter, ok := transformer.(transform.ResponseIDGetter); ok {
+		responseID = getter.GetResponseID()
+	}
+	if responseID == "" {
+		return func() {}
+	}
+	streamCtx, cancel := context.WithCancel(c.Request.Context())
+	c.Request = c.Request.WithContext(streamCtx)
+	registry.Register(responseID, cancel, transformer)
+	return func() {
+		registry.Remove(responseID)
+		cancel()
+	}
+}
+
+// endInterruptedStream ends a stream whose request was cancelled via the stream
+// registry or interrupted by a server shutdown, letting the transformer tell the
+// client the response is incomplete (e.g. response.cancelled or message_stop)
+// instead of cutting it off.
+//
+// @param c - Gin context for the current request.
+// @param transformer - The stream's transformer.
+// @return true if the request was interrupted and the stream has been ended.
+func endInterruptedStream(c *gin.Context, transformer transform.SSETransformer) bool {
+	switch cause := context.Cause(c.Request.Context()); {
+	case errors.Is(cause, stream.ErrShutdown):
+		logging.InfoMsg("Stream interrupted by server shutdown")
+	case errors.Is(cause, stream.ErrCancelled):
+		logging.InfoMsg("Stream cancelled")
+	default:
+		return false
+	}
+	if err := transformer.HandleCancel(); err != nil {
+		logging.ErrorMsg("Failed to end interrupted stream: %v", err)
 	}
+	return true
 }
 
 // streamResponse streams the upstream SSE response to the client with transformation.
@@ -357,12 +783,16 @@ func streamResponse(c *gin.Context, body io.Reader, h Handler) {
 	c.Stream(func(w io.Writer) bool {
 		for ev, err := range sse.Read(body, nil) {
 			if err != nil {
+				if endInterruptedStream(c, transformer) {
+					return false
+				}
 				// Context canceled means client disconnected - can't send response.failed
 				if errors.Is(err, context.Canceled) {
 					logging.DebugMsg("Stream completed, client disconnected")
 					return false
 				}
 				logging.ErrorMsg("SSE stream error: %v", err)
+				requestMetricsFrom(c).streamFailed(err)
 				emitStreamError(transformer, err)
 				return false
 			}
@@ -397,6 +827,19 @@ func setStreamHeaders(c *gin.Context) {
 	c.Header("X-Accel-Buffering", "no")
 }
 
+// upstreamBody returns the body of the upstream response as an SSE stream.
+// Ollama upstreams stream NDJSON, whose lines are framed as events.
+//
+// @param h - Handler whose current route served resp.
+// @param resp - Successful upstream response.
+// @return Reader for the upstream SSE stream.
+func upstreamBody(h Handler, resp *http.Response) io.Reader {
+	if _, _, protocol := handlerLabels(h); protocol == "ollama" {
+		return proxy.NewNDJSONEventReader(resp.Body)
+	}
+	return resp.Body
+}
+
 // streamWithCapture streams the response while capturing both upstream and downstream
 // data for logging and analysis.
 //
@@ -418,10 +861,12 @@ func streamWithCapture(c *gin.Context, body io.Reader, h Handler, cc *capture.Ca
 	// Stream events with capture
 	// Get flusher from Gin response writer for immediate delivery
 	flusher, canFlush := c.Writer.(http.Flusher)
+	m := requestMetricsFrom(c)
 
 	c.Stream(func(w io.Writer) bool {
 		// Create timing-aware writer that captures downstream events with correct timing
 		timingWriter := newTimingCaptureWriter(w, downstream)
+		_, timingWriter.lines = h.(StreamFormatHandler)
 		// Create transformer that writes to our timing-aware writer
 		transformer := h.CreateTransformer(timingWriter)
 		// Set context for cache status tracking
@@ -437,16 +882,23 @@ func streamWithCapture(c *gin.Context, body io.Reader, h Handler, cc *capture.Ca
 			emitStreamError(transformer, err)
 			return false
 		}
+		m.streamStarted(c.Writer.Size())
+		// Register for cancellation and shutdown
+		defer registerStream(c, transformer)()
 
 		// Iterate over all SSE events from upstream
 		for ev, err := range sse.Read(body, nil) {
 			if err != nil {
+				if endInterruptedStream(c, transformer) {
+					return false
+				}
 				// Context canceled means client disconnected - can't send response.failed
 				if errors.Is(err, context.Canceled) {
 					logging.DebugMsg("Stream completed, client disconnected")
 					return false
 				}
 				logging.ErrorMsg("SSE stream error (capture): %v", err)
+				m.streamFailed(err)
 				emitStreamError(transformer, err)
 				return false
 			}
@@ -455,12 +907,14 @@ func streamWithCapture(c *gin.Context, body io.Reader, h Handler, cc *capture.Ca
 			if ev.Data != "" {
 				recordUpstreamEvent(upstream, ev)
 			}
+			m.upstreamEvent(ev.Data)
 			// Transform and send event to client (timing captured by timingWriter)
 			if err := transformer.Transform(&ev); err != nil {
 				logging.ErrorMsg("Transform error (capture): %v", err)
 				emitStreamError(transformer, err)
 				return false
 			}
+			m.downstreamWritten(c.Writer.Size())
 
 			// Flush after each event to ensure immediate delivery
 			// This prevents buffering that causes clients to timeout
@@ -470,6 +924,7 @@ func streamWithCapture(c *gin.Context, body io.Reader, h Handler, cc *capture.Ca
 		}
 		return false
 	})
+	m.streamEnded()
 
 	// Finalize capture by recording all captured data
 	finalizeCapture(cc, downstream, upstream)
@@ -481,25 +936,34 @@ func streamWithInitializedTransformer(c *gin.Context, body io.Reader, transforme
 	// Stream events without capture overhead
 	// Get flusher from Gin response writer for immediate delivery
 	flusher, canFlush := c.Writer.(http.Flusher)
+	m := requestMetricsFrom(c)
+	m.streamStarted(c.Writer.Size())
+	defer m.streamEnded()
 
 	c.Stream(func(w io.Writer) bool {
 		for ev, err := range sse.Read(body, nil) {
 			if err != nil {
+				if endInterruptedStream(c, transformer) {
+					return false
+				}
 				// Context canceled means client disconnected - can't send response.failed
 				if errors.Is(err, context.Canceled) {
 					logging.DebugMsg("Stream completed, client disconnected")
 					return false
 				}
 				logging.ErrorMsg("SSE stream error (no-capture): %v", err)
+				requestMetricsFrom(c).streamFailed(err)
 				emitStreamError(transformer, err)
 				return false
 			}
+			m.upstreamEvent(ev.Data)
 			// Transform and send event directly to client
 			if err := transformer.Transform(&ev); err != nil {
 				logging.ErrorMsg("Transform error (no-capture): %v", err)
 				emitStreamError(transformer, err)
 				return false
 			}
+			m.downstreamWritten(c.Writer.Size())
 
 			// Flush after each event to ensure immediate delivery
 			if canFlush {
@@ -536,12 +1000,16 @@ func streamWithoutCapture(c *gin.Context, body io.Reader, h Handler) {
 	c.Stream(func(w io.Writer) bool {
 		for ev, err := range sse.Read(body, nil) {
 			if err != nil {
+				if endInterruptedStream(c, transformer) {
+					return false
+				}
 				// Context canceled means client disconnected - can't send response.failed
 				if errors.Is(err, context.Canceled) {
 					logging.DebugMsg("Stream completed, client disconnected")
 					return false
 				}
 				logging.ErrorMsg("SSE stream error (no-capture): %v", err)
+				requestMetricsFrom(c).streamFailed(err)
 				emitStreamError(transformer, err)
 				return false
 			}
@@ -702,16 +1170,17 @@ func finalizeCapture(cc *capture.CaptureContext, downstream, upstream capture.Ca
 //
 // @param c - Gin context for writing the error response.
 // @param resp - Error response from upstream.
+// @param dialect - The provider's dialect, whose error envelope is unwrapped.
 //
 // @pre resp != nil and resp.Body is readable.
 // @post Error response is sent to client in OpenAI error format.
-func handleUpstreamError(c *gin.Context, resp *http.Response) {
+func handleUpstreamError(c *gin.Context, resp *http.Response, dialect string) {
 	// Read the error body for inclusion in client error message
 	body, _ := io.ReadAll(resp.Body)
-	msg := string(body)
+	msg := upstreamErrorMessage(dialect, body)
 
 	// Record the upstream error for capture
-	c.Set("upstream_error_body", msg)
+	c.Set("upstream_error_body", string(body))
 	c.Set("upstream_error_status", resp.StatusCode)
 
 	// Send error in OpenAI format with the original upstream status code
@@ -721,6 +1190,7 @@ func handleUpstreamError(c *gin.Context, resp *http.Response) {
 
 // sendOpenAIError sends an error response in OpenAI API format.
 // OpenAI format: {"error": {"message": "...", "type": "..."}}
+// A 429 is reported as a rate_limit_error with code rate_limit_exceeded.
 //
 // @param c - Gin context for writing the response.
 // @param status - HTTP status code for the error.
@@ -729,6 +1199,16 @@ func handleUpstreamError(c *gin.Context, resp *http.Response) {
 // @pre c != nil and response has not been written.
 // @post JSON error response is written and flushed.
 func sendOpenAIError(c *gin.Context, status int, msg string) {
+	if status == http.StatusTooManyRequests {
+		c.JSON(status, gin.H{
+			"error": gin.H{
+				"message": msg,
+				"type":    "rate_limit_error",
+				"code":    "rate_limit_exceeded",
+			},
+		})
+		return
+	}
 	c.JSON(status, gin.H{
 		"error": gin.H{
 			"message": msg,
@@ -739,6 +1219,7 @@ func sendOpenAIError(c *gin.Context, status int, msg string) {
 
 // sendAnthropicError sends an error response in Anthropic API format.
 // Anthropic format: {"type": "error", "error": {"type": "...", "message": "..."}}
+// A 429 is reported as a rate_limit_error.
 //
 // @param c - Gin context for writing the response.
 // @param status - HTTP status code for the error.
@@ -747,10 +1228,14 @@ func sendOpenAIError(c *gin.Context, status int, msg string) {
 // @pre c != nil and response has not been written.
 // @post JSON error response is written and flushed.
 func sendAnthropicError(c *gin.Context, status int, msg string) {
+	errType := "invalid_request_error"
+	if status == http.StatusTooManyRequests {
+		errType = "rate_limit_error"
+	}
 	c.JSON(status, gin.H{
 		"type": "error",
 		"error": gin.H{
-			"type":    "invalid_request_error",
+			"type":    errType,
 			"message": msg,
 		},
 	})
@@ -758,6 +1243,7 @@ func sendAnthropicError(c *gin.Context, status int, msg string) {
 
 // sendOpenAIResponsesError sends an error response in OpenAI Responses API format.
 // OpenAI Responses API uses SSE format for errors.
+// A 429 is reported with code rate_limit_exceeded.
 //
 // @param c - Gin context for writing the response.
 // @param status - HTTP status code for the error.
@@ -766,10 +1252,14 @@ func sendAnthropicError(c *gin.Context, status int, msg string) {
 // @pre c != nil and response has not been written.
 // @post SSE error response is written and flushed.
 func sendOpenAIResponsesError(c *gin.Context, status int, msg string) {
+	code := "invalid_request_error"
+	if status == http.StatusTooManyRequests {
+		code = "rate_limit_exceeded"
+	}
 	event := map[string]interface{}{
 		"type": "error",
 		"error": map[string]interface{}{
-			"code":    "invalid_request_error",
+			"code":    code,
 			"message": msg,
 		},
 	}
@@ -14,7 +14,10 @@ import (
 
 	"ai-proxy/capture"
 	"ai-proxy/config"
+	"ai-proxy/proxy"
+	"ai-proxy/router"
 	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tmaxmax/go-sse"
@@ -322,7 +325,7 @@ func TestHandleUpstreamError(t *testing.T) {
 				Body:       io.NopCloser(bytes.NewBufferString(tt.upstreamBody)),
 			}
 
-			handleUpstreamError(c, resp)
+			handleUpstreamError(c, resp, "")
 
 			// Status code should be preserved from upstream
 			if w.Code != tt.statusCode {
@@ -467,9 +470,14 @@ func withFakeUpstreamClient(
 	t.Helper()
 
 	oldFactory := newUpstreamClient
-	newUpstreamClient = func(baseURL, apiKey string) upstreamClient {
+	newUpstreamClient = func(baseURL, apiKey string, opts ...proxy.ClientOption) upstreamClient {
 		return &fakeUpstreamClient{
 			setHdrs: func(req *http.Request) {
+				if len(opts) > 0 {
+					// Routes configure auth and headers; set them as the real client does
+					proxy.NewClient(baseURL, apiKey, opts...).SetHeaders(req)
+					return
+				}
 				req.Header.Set("Content-Type", "application/json")
 				authHeader := "Bearer " + apiKey
 				if apiKey == "" {
@@ -486,6 +494,43 @@ func withFakeUpstreamClient(
 	})
 }
 
+// newSingleRouteRouter returns a router resolving alias to route alone.
+func newSingleRouteRouter(alias string, route *router.ResolvedRoute) *mockRouter {
+	r := newMockRouter()
+	r.models[alias] = route
+	r.plans[alias] = &router.RoutePlan{Routes: []*router.ResolvedRoute{route}}
+	return r
+}
+
+// upstreamResponse returns an upstream response with the given status,
+// content type and body.
+func upstreamResponse(status int, contentType, body string) *http.Response {
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     header,
+	}
+}
+
+// serveUpstream runs a request through a handler built for r, against a fake
+// upstream answering every request with respond, and returns the client response.
+func serveUpstream(t *testing.T, newHandler func(*config.Config, router.Router) gin.HandlerFunc, r router.Router, path, body string, respond func(req *http.Request) *http.Response) *mockResponseWriter {
+	t.Helper()
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return respond(req), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	newHandler(&config.Config{}, r)(c)
+	return w
+}
+
 func TestHandle_ValidateRequestError(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -544,7 +589,7 @@ func TestHandle_UpstreamNon200Status(t *testing.T) {
 	t.Cleanup(func() {
 		newUpstreamClient = oldFactory
 	})
-	newUpstreamClient = func(baseURL, apiKey string) upstreamClient {
+	newUpstreamClient = func(baseURL, apiKey string, opts ...proxy.ClientOption) upstreamClient {
 		return &fakeUpstreamClient{
 			do: func(req *http.Request) (*http.Response, error) {
 				return &http.Response{
@@ -716,7 +761,7 @@ func TestProxyRequest_BadUpstreamURL(t *testing.T) {
 		apiKey:      "test-key",
 	}
 
-	proxyRequest(c, h, []byte(`{"test": "data"}`))
+	proxyRequest(c, h, []byte(`{"test": "data"}`), []byte(`{"test": "data"}`))
 
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
@@ -1245,3 +1290,151 @@ func TestResolveAPIKeyInterface(t *testing.T) {
 		t.Errorf("expected empty API key, got %q", key)
 	}
 }
+
+func TestIsNonStreamingRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{name: "stream false", body: `{"stream": false}`, want: true},
+		{name: "stream true", body: `{"stream": true}`, want: false},
+		{name: "stream absent", body: `{"model": "m"}`, want: false},
+		{name: "invalid JSON", body: `{`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNonStreamingRequest([]byte(tt.body)); got != tt.want {
+				t.Errorf("isNonStreamingRequest(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnableStreaming(t *testing.T) {
+	got, err := enableStreaming([]byte(`{"model":"m","stream":false,"max_tokens":5}`))
+	if err != nil {
+		t.Fatalf("enableStreaming() error = %v", err)
+	}
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(got, &req); err != nil {
+		t.Fatalf("result is not valid JSON: %v", err)
+	}
+	if req["stream"] != true || req["model"] != "m" || req["max_tokens"] != float64(5) {
+		t.Errorf("enableStreaming() = %s", got)
+	}
+
+	if _, err := enableStreaming([]byte(`[]`)); err == nil {
+		t.Error("expected error for non-object body")
+	}
+}
+
+// mockAggregatingHandler is a mockHandler that supports "stream": false.
+type mockAggregatingHandler struct {
+	mockHandler
+	aggregating bool
+}
+
+func (m *mockAggregatingHandler) CreateTransformer(w io.Writer) transform.SSETransformer {
+	return transform.NewPassthroughTransformer(w)
+}
+
+func (m *mockAggregatingHandler) NewAggregator() aggregate.Aggregator {
+	m.aggregating = true
+	return aggregate.NewChatAggregator()
+}
+
+func TestHandle_NonStreamingAggregatesResponse(t *testing.T) {
+	var upstreamBody []byte
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		upstreamBody, _ = io.ReadAll(req.Body)
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(
+				`data: {"id":"chatcmpl-1","model":"m","choices":[{"index":0,"delta":{"content":"Hel"}}]}` + "\n\n" +
+					`data: {"id":"chatcmpl-1","model":"m","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}` + "\n\n" +
+					"data: [DONE]\n\n")),
+			Header: make(http.Header),
+		}
+		return resp, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"model":"m","stream":false}`))
+
+	h := &mockAggregatingHandler{mockHandler: mockHandler{upstreamURL: "https://example.com"}}
+	Handle(h)(c)
+
+	if !h.aggregating {
+		t.Fatal("expected NewAggregator to be called")
+	}
+	if !strings.Contains(string(upstreamBody), `"stream":true`) {
+		t.Errorf("upstream body = %s, want stream:true", upstreamBody)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var completion map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, w.Body.String())
+	}
+	msg := completion["choices"].([]interface{})[0].(map[string]interface{})["message"].(map[string]interface{})
+	if msg["content"] != "Hello" {
+		t.Errorf("content = %v, want Hello", msg["content"])
+	}
+}
+
+func TestHandle_NonStreamingStreamError(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`data: {"error":{"message":"overloaded","type":"server_error"}}` + "\n\n")),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"stream":false}`))
+
+	h := &mockAggregatingHandler{mockHandler: mockHandler{upstreamURL: "https://example.com"}}
+	Handle(h)(c)
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "overloaded") {
+		t.Errorf("body = %s, want upstream message", w.Body.String())
+	}
+}
+
+func TestHandle_StreamFieldAbsentKeepsStreaming(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("data: {\"id\":\"x\"}\n\n")),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"model":"m"}`))
+
+	h := &mockAggregatingHandler{mockHandler: mockHandler{upstreamURL: "https://example.com"}}
+	Handle(h)(c)
+
+	if h.aggregating {
+		t.Error("NewAggregator should not be called when stream is absent")
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+}
@@ -7,10 +7,14 @@ import (
 	"net/http"
 	"strings"
 
+	"ai-proxy/balance"
+	"ai-proxy/circuit"
 	"ai-proxy/config"
 	"ai-proxy/convert"
+	"ai-proxy/proxy"
 	"ai-proxy/router"
 	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
 	"ai-proxy/transform/toolcall"
 
 	"github.com/gin-gonic/gin"
@@ -33,11 +37,17 @@ type CompletionsHandler struct {
 	cfg *config.Config
 	// router resolves model names to providers. May be nil for legacy behavior.
 	modelRouter router.Router
+	// plan is the resolved route plan for the current request.
+	// Set during ValidateRequest; nil if the model was not resolved.
+	plan *router.RoutePlan
 	// route is the resolved route for the current request.
 	// Set during ValidateRequest for use in subsequent methods.
 	route *router.ResolvedRoute
 	// originalModel is the model name from the original request.
 	originalModel string
+	// aggregating is true when the client sent "stream": false.
+	// Usage is then always requested so the assembled completion reports it.
+	aggregating bool
 }
 
 // NewCompletionsHandler creates a Gin handler for the /v1/chat/completions endpoint.
@@ -80,13 +90,14 @@ func (h *CompletionsHandler) ValidateRequest(body []byte) error {
 		return nil // Let upstream handle missing model
 	}
 
-	// Resolve the model to a route (incoming protocol is OpenAI for completions endpoint)
-	route, err := h.modelRouter.ResolveWithProtocol(req.Model, "openai")
+	// Resolve the model to a route plan (incoming protocol is OpenAI for completions endpoint)
+	plan, err := h.modelRouter.ResolvePlan(req.Model, "openai")
 	if err != nil {
 		return nil // Use fallback behavior
 	}
 
-	h.route = route
+	h.plan = plan
+	h.route = plan.Primary()
 	h.originalModel = req.Model
 	return nil
 }
@@ -94,6 +105,9 @@ func (h *CompletionsHandler) ValidateRequest(body []byte) error {
 // TransformRequest converts the request body based on the upstream provider type.
 // For OpenAI providers: passes through without transformation, adding stream_options.
 // For Anthropic providers: converts OpenAI Chat Completions to Anthropic Messages.
+// For Responses providers: converts OpenAI Chat Completions to a streaming Responses request.
+// For Gemini providers: converts OpenAI Chat Completions to a Gemini generateContent request.
+// For Ollama providers: converts OpenAI Chat Completions to a streaming Ollama chat request.
 //
 // @param ctx - Context for the request (unused in this handler).
 // @param body - Raw request body in OpenAI ChatCompletion format.
@@ -114,6 +128,11 @@ func (h *CompletionsHandler) TransformRequest(ctx context.Context, body []byte)
 
 	// Passthrough optimization - no transformation needed
 	if h.route.IsPassthrough {
+		if h.aggregating {
+			req["stream_options"] = map[string]interface{}{
+				"include_usage": true,
+			}
+		}
 		return json.Marshal(req)
 	}
 
@@ -147,7 +166,27 @@ func (h *CompletionsHandler) TransformRequest(ctx context.Context, body []byte)
 		if err != nil {
 			return nil, err
 		}
-		return convert.TransformChatToResponses(updatedBody)
+		transformed, err := convert.TransformChatToResponses(updatedBody)
+		if err != nil {
+			return nil, err
+		}
+		// The proxy always streams from upstream, even if the client omitted stream
+		return enableStreaming(transformed)
+	case "gemini":
+		// Convert Chat Completions to Gemini format; the model and streaming
+		// are part of the endpoint URL
+		updatedBody, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		return convert.TransformChatToGemini(updatedBody)
+	case "ollama":
+		// Convert Chat Completions to Ollama format
+		updatedBody, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		return convert.TransformChatToOllama(updatedBody)
 	default:
 		// Unknown protocol - pass through as-is
 		return json.Marshal(req)
@@ -159,7 +198,7 @@ func (h *CompletionsHandler) TransformRequest(ctx context.Context, body []byte)
 // @return URL string for the upstream API endpoint.
 func (h *CompletionsHandler) UpstreamURL() string {
 	if h.route != nil {
-		return h.route.Provider.GetEndpoint(h.route.OutputProtocol)
+		return h.route.Provider.GetModelEndpoint(h.route.OutputProtocol, h.route.Model)
 	}
 	return ""
 }
@@ -170,7 +209,7 @@ func (h *CompletionsHandler) UpstreamURL() string {
 // @return API key string from the provider configuration.
 func (h *CompletionsHandler) ResolveAPIKey(c *gin.Context) string {
 	if h.route != nil {
-		return h.route.Provider.GetAPIKey()
+		return h.route.GetAPIKey()
 	}
 	return ""
 }
@@ -178,6 +217,8 @@ func (h *CompletionsHandler) ResolveAPIKey(c *gin.Context) string {
 // ForwardHeaders copies headers to the upstream request based on provider type.
 // For OpenAI providers: forwards X-* headers and Extra header.
 // For Anthropic providers: forwards X-*, Anthropic-Version, and Anthropic-Beta headers.
+// For other providers: forwards X-* headers.
+// The API key and provider headers are set afterwards by the upstream client.
 //
 // @param c - Gin context containing the original request headers.
 // @param req - Upstream request to receive forwarded headers.
@@ -208,6 +249,9 @@ func (h *CompletionsHandler) ForwardHeaders(c *gin.Context, req *http.Request) {
 // CreateTransformer builds an SSE transformer based on the provider type.
 // For OpenAI providers: uses OpenAITransformer for tool call handling.
 // For Anthropic providers: uses ChatToAnthropicTransformer to convert responses back to OpenAI format.
+// For Responses providers: uses ResponsesToChatTransformer with Kimi/GLM-5 extraction from reasoning.
+// For Gemini providers: converts Gemini chunks to Chat Completions, then handles them like OpenAI.
+// For Ollama providers: converts Ollama lines to Chat Completions, then handles them like OpenAI.
 //
 // @param w - Writer to receive transformed output.
 // @return Transformer for processing SSE events.
@@ -227,22 +271,39 @@ func (h *CompletionsHandler) CreateTransformer(w io.Writer) transform.SSETransfo
 		// Convert Anthropic responses back to OpenAI Chat format
 		return convert.NewChatToAnthropicTransformer(w)
 	case "openai":
-		// Use OpenAI transformer for tool call handling
-		if h.route.KimiToolCallTransform || h.route.GLM5ToolCallTransform {
-			t := toolcall.NewOpenAITransformer(w)
-			t.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
-			t.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
-			return t
-		}
-		return transform.NewPassthroughTransformer(w)
+		return h.newChatTransformer(w)
+	case "gemini":
+		// Convert Gemini chunks to Chat Completions chunks first
+		return convert.NewGeminiToChatTransformer(h.newChatTransformer(w))
+	case "ollama":
+		// Convert Ollama lines to Chat Completions chunks first
+		return convert.NewOllamaToChatTransformer(h.newChatTransformer(w))
 	case "responses":
 		// Convert Responses SSE back to Chat Completions format
-		return convert.NewChatToResponsesTransformer(w)
+		t := convert.NewResponsesToChatTransformer(w)
+		t.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
+		t.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
+		return t
 	default:
 		return transform.NewPassthroughTransformer(w)
 	}
 }
 
+// newChatTransformer builds the transformer for Chat Completions chunks:
+// the OpenAI transformer if tool call markup is extracted, otherwise passthrough.
+//
+// @param w - Writer to receive transformed output.
+// @return Transformer for Chat Completions chunks.
+func (h *CompletionsHandler) newChatTransformer(w io.Writer) transform.SSETransformer {
+	if h.route.KimiToolCallTransform || h.route.GLM5ToolCallTransform {
+		t := toolcall.NewOpenAITransformer(w)
+		t.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
+		t.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
+		return t
+	}
+	return transform.NewPassthroughTransformer(w)
+}
+
 // WriteError sends an error response in OpenAI format.
 // Maintains consistency with OpenAI API error responses.
 //
@@ -253,6 +314,15 @@ func (h *CompletionsHandler) WriteError(c *gin.Context, status int, msg string)
 	sendOpenAIError(c, status, msg)
 }
 
+// NewAggregator switches the handler into non-streaming mode.
+// The assembled response is a single chat.completion object.
+//
+// @return Aggregator for Chat Completions streaming chunks.
+func (h *CompletionsHandler) NewAggregator() aggregate.Aggregator {
+	h.aggregating = true
+	return aggregate.NewChatAggregator()
+}
+
 // forwardCustomHeaders copies headers matching any of the given prefixes
 // from the incoming request to the upstream request.
 func forwardCustomHeaders(c *gin.Context, req *http.Request, prefixes ...string) {
@@ -266,6 +336,100 @@ func forwardCustomHeaders(c *gin.Context, req *http.Request, prefixes ...string)
 	}
 }
 
+// RetryPolicy returns the retry policy of the resolved provider.
+//
+// @return Policy from the provider's retry configuration, single attempt if none.
+func (h *CompletionsHandler) RetryPolicy() proxy.RetryPolicy {
+	return routeRetryPolicy(h.route)
+}
+
+// HasNextRoute reports whether the route plan has a fallback after the current route.
+func (h *CompletionsHandler) HasNextRoute() bool {
+	return h.plan.Next(h.route) != nil
+}
+
+// NextRoute switches to the next fallback route of the route plan.
+//
+// @return false if the current route is the last one.
+func (h *CompletionsHandler) NextRoute() bool {
+	next := h.plan.Next(h.route)
+	if next == nil {
+		return false
+	}
+	h.route = next
+	return true
+}
+
+// UpstreamKey returns the load-balanced API key of the current route.
+func (h *CompletionsHandler) UpstreamKey() *balance.Key {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Key
+}
+
+// UpstreamBreaker returns the circuit breaker of the current route's provider.
+func (h *CompletionsHandler) UpstreamBreaker() *circuit.Breaker {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Breaker
+}
+
+// FetchCredential fetches the current route's key from its provider's
+// credential source, if it has one.
+func (h *CompletionsHandler) FetchCredential(ctx context.Context) error {
+	if h.route == nil {
+		return nil
+	}
+	_, err := h.route.FetchAPIKey(ctx)
+	return err
+}
+
+// InvalidateCredential drops the current route's fetched key from its
+// provider's credential source.
+func (h *CompletionsHandler) InvalidateCredential() bool {
+	return h.route != nil && h.route.InvalidateCredential()
+}
+
+// MetricLabels returns the inbound protocol and the current route's provider
+// and upstream protocol.
+func (h *CompletionsHandler) MetricLabels() (inbound, provider, upstreamProtocol string) {
+	if h.route == nil {
+		return "openai", "", ""
+	}
+	return "openai", h.route.Provider.Name, h.route.OutputProtocol
+}
+
+// UpstreamDialect returns the dialect of the current route's provider.
+func (h *CompletionsHandler) UpstreamDialect() string {
+	if h.route == nil {
+		return ""
+	}
+	return h.route.Provider.Dialect
+}
+
+// UpstreamClientOptions returns the auth and header options of the current route's provider.
+func (h *CompletionsHandler) UpstreamClientOptions() []proxy.ClientOption {
+	return routeClientOptions(h.route)
+}
+
+// ModelLimits returns the per-client limits of the resolved model alias.
+func (h *CompletionsHandler) ModelLimits() *config.LimitsConfig {
+	if h.plan == nil {
+		return nil
+	}
+	return h.plan.Limits
+}
+
+// RoutePrice returns the price of the current route's upstream model.
+func (h *CompletionsHandler) RoutePrice() *config.ModelPrice {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Price
+}
+
 // ModelInfo returns the downstream and upstream model names for logging.
 func (h *CompletionsHandler) ModelInfo() (downstreamModel string, upstreamModel string) {
 	downstreamModel = h.originalModel
@@ -349,3 +349,19 @@ func TestForwardCustomHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestCompletionsHandler_TransformRequest_AggregatingRequestsUsage(t *testing.T) {
+	h := &CompletionsHandler{
+		cfg:   &config.Config{},
+		route: &router.ResolvedRoute{Model: "upstream-model", OutputProtocol: "openai", IsPassthrough: true},
+	}
+	h.NewAggregator()
+
+	got, err := h.TransformRequest(context.TODO(), []byte(`{"model":"m","stream":true}`))
+	if err != nil {
+		t.Fatalf("TransformRequest() error = %v", err)
+	}
+	if !bytes.Contains(got, []byte(`"stream_options":{"include_usage":true}`)) {
+		t.Errorf("TransformRequest() = %s, want stream_options.include_usage", got)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ai-proxy/proxy"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConnectionsHandler reports the shared upstream connection pool of every
+// provider. Operators use it to see how often requests reuse a pooled
+// connection instead of paying for a new TCP and TLS handshake.
+//
+// This handler:
+//   - Accepts GET requests
+//   - Returns request, new and reused connection, and HTTP/2 counters per provider
+//   - Counters start afresh when a config reload replaces the pools
+type ConnectionsHandler struct {
+	modelRouter router.Router
+}
+
+// NewConnectionsHandler creates a Gin handler for the GET /admin/connections endpoint.
+//
+// @param r - Model router owning the provider connection pools. Must not be nil.
+// @return Gin handler function that reports connection pool usage.
+func NewConnectionsHandler(r router.Router) gin.HandlerFunc {
+	h := &ConnectionsHandler{modelRouter: r}
+	return h.Handle
+}
+
+// Handle writes the connection pool snapshot as JSON.
+//
+// @param c - Gin context for the HTTP request.
+// @post Response body is {"providers": [...]} with status 200.
+func (h *ConnectionsHandler) Handle(c *gin.Context) {
+	providers := h.modelRouter.ConnectionStats()
+	if providers == nil {
+		providers = []proxy.PoolStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/config"
+	"ai-proxy/proxy"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConnectionsHandler(t *testing.T) {
+	mockR := newMockRouter()
+	mockR.connections = []proxy.PoolStats{
+		{Provider: "openai", Requests: 10, NewConnections: 1, ReusedConnections: 9, HTTP2Responses: 10},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/connections", nil)
+	NewConnectionsHandler(mockR)(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var body struct {
+		Providers []proxy.PoolStats `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body.Providers) != 1 || body.Providers[0].ReusedConnections != 9 {
+		t.Errorf("unexpected providers: %+v", body.Providers)
+	}
+}
+
+func TestConnectionsHandler_NoProviders(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/connections", nil)
+	NewConnectionsHandler(newMockRouter())(c)
+
+	if body := w.Body.String(); body != `{"providers":[]}` {
+		t.Errorf("expected empty provider list, got %s", body)
+	}
+}
+
+func TestCompletionsHandler_SharesProviderConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, chatUpstreamStream)
+	}))
+	defer upstream.Close()
+
+	r, err := router.NewRouter(&config.Schema{
+		Providers: []config.Provider{
+			{Name: "local", Endpoints: map[string]string{"openai": upstream.URL}, APIKey: "sk-local"},
+		},
+		Models: map[string]config.ModelConfig{
+			"m": {Provider: "local", Model: "model-a"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() error: %v", err)
+	}
+	defer r.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		w := newMockResponseWriter()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+			`{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+		NewCompletionsHandler(&config.Config{}, r)(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d: %s", i, w.Code, w.Body.String())
+		}
+	}
+
+	stats := r.ConnectionStats()
+	if len(stats) != 1 || stats[0].Requests != 3 || stats[0].NewConnections != 1 || stats[0].ReusedConnections != 2 {
+		t.Errorf("expected one connection reused by later requests, got %+v", stats)
+	}
+}
@@ -159,11 +159,20 @@ func (h *CountTokensHandler) UpstreamURL() string {
 // @pre h.cfg != nil
 func (h *CountTokensHandler) ResolveAPIKey(c *gin.Context) string {
 	if h.route != nil {
-		return h.route.Provider.GetAPIKey()
+		return h.route.GetAPIKey()
 	}
 	return ""
 }
 
+// UpstreamClientOptions returns the auth, header and connection pool options
+// of the provider's anthropic endpoint.
+func (h *CountTokensHandler) UpstreamClientOptions() []proxy.ClientOption {
+	if h.route == nil {
+		return nil
+	}
+	return protocolClientOptions(h.route, "anthropic")
+}
+
 // ForwardHeaders copies X-*, Anthropic-Version, and Anthropic-Beta headers
 // to the upstream request.
 //
@@ -251,7 +260,11 @@ func proxyNonStreamingRequest(c *gin.Context, h NonStreamingHandler, body []byte
 	apiKey := h.ResolveAPIKey(c)
 
 	// Create HTTP client configured for upstream endpoint
-	client := proxy.NewClient(h.UpstreamURL(), apiKey)
+	var opts []proxy.ClientOption
+	if oh, ok := h.(ClientOptionsHandler); ok {
+		opts = oh.UpstreamClientOptions()
+	}
+	client := proxy.NewClient(h.UpstreamURL(), apiKey, opts...)
 	defer client.Close()
 
 	// Build the upstream HTTP request
@@ -261,10 +274,10 @@ func proxyNonStreamingRequest(c *gin.Context, h NonStreamingHandler, body []byte
 		return
 	}
 
-	// Set standard headers required by upstream API
-	client.SetHeaders(req)
 	// Forward custom headers from original request
 	h.ForwardHeaders(c, req)
+	// Set standard, auth and provider headers, which win over forwarded ones
+	client.SetHeaders(req)
 
 	// Execute the upstream request
 	resp, err := client.Do(req)
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+)
+
+// fetchCredential fetches the key of the handler's current route from its
+// provider's credential source.
+//
+// @return Error if the credential source failed, nil if the handler's routes
+// have no credential source.
+func fetchCredential(ctx context.Context, h Handler) error {
+	if ch, ok := h.(CredentialHandler); ok {
+		return ch.FetchCredential(ctx)
+	}
+	return nil
+}
+
+// invalidateCredential drops the fetched key of the handler's current route
+// after the upstream rejected it.
+//
+// @return true if the key was fetched, so a retry may succeed with a fresh one.
+func invalidateCredential(h Handler) bool {
+	ch, ok := h.(CredentialHandler)
+	return ok && ch.InvalidateCredential()
+}
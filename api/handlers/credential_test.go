@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"ai-proxy/config"
+	"ai-proxy/credential"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTokenServer starts a stub OAuth2 token endpoint issuing token-1, token-2, ...
+// or failing with the given status if it is not 0.
+func newTokenServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var issued atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != 0 {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		n := issued.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+// serveWithCredential runs a chat completion through a provider fetching its
+// key from the token server, against a fake upstream accepting only the keys
+// in accepted. It returns the client response and the upstream call count.
+func serveWithCredential(t *testing.T, tokenURL string, accepted ...string) (*mockResponseWriter, int) {
+	t.Helper()
+	calls := 0
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		status, body := http.StatusUnauthorized, `{"error":{"message":"invalid token"}}`
+		for _, key := range accepted {
+			if req.Header.Get("Authorization") == "Bearer "+key {
+				status, body = http.StatusOK, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n"
+			}
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})
+
+	provider := config.Provider{
+		Name:      "oauth",
+		Endpoints: map[string]string{"openai": "https://llm.example.com/v1/chat/completions"},
+		Credential: &config.CredentialConfig{
+			Type:         config.CredentialOAuth2,
+			TokenURL:     tokenURL,
+			ClientID:     "proxy",
+			ClientSecret: "secret",
+		},
+	}
+	route := mockRoute(provider, "model-a", "openai")
+	route.Credential = credential.New(provider.Name, provider.Credential)
+	r := newMockRouter()
+	r.models["m"] = route
+	r.plans["m"] = &router.RoutePlan{Routes: []*router.ResolvedRoute{route}}
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	NewCompletionsHandler(&config.Config{}, r)(c)
+	return w, calls
+}
+
+func TestCompletionsHandler_CredentialRetriedOn401(t *testing.T) {
+	server, issued := newTokenServer(t, 0)
+	// token-1 has been revoked upstream
+	w, calls := serveWithCredential(t, server.URL, "token-2")
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"content":"Hello"`) {
+		t.Fatalf("expected the retry with a fresh token to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if calls != 2 || issued.Load() != 2 {
+		t.Errorf("expected 2 upstream calls and 2 tokens, got %d and %d", calls, issued.Load())
+	}
+}
+
+func TestCompletionsHandler_CredentialRetriedOnce(t *testing.T) {
+	server, issued := newTokenServer(t, 0)
+	w, calls := serveWithCredential(t, server.URL, "never-issued")
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the upstream 401, got %d: %s", w.Code, w.Body.String())
+	}
+	if calls != 2 || issued.Load() != 2 {
+		t.Errorf("expected a single retry, got %d upstream calls and %d tokens", calls, issued.Load())
+	}
+}
+
+func TestCompletionsHandler_CredentialFetchFailure(t *testing.T) {
+	server, _ := newTokenServer(t, http.StatusUnauthorized)
+	w, calls := serveWithCredential(t, server.URL)
+
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "Failed to fetch upstream credential") {
+		t.Errorf("expected 502, got %d: %s", w.Code, w.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("expected no upstream call without a credential, got %d", calls)
+	}
+}
+
+func TestCompletionsHandler_StaticKeyNotRetried(t *testing.T) {
+	calls := 0
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(`{}`)), Header: make(http.Header)}, nil
+	})
+	route := mockRoute(config.Provider{
+		Name:      "static",
+		Endpoints: map[string]string{"openai": "https://llm.example.com/v1/chat/completions"},
+		APIKey:    "sk-static",
+	}, "model-a", "openai")
+	r := newMockRouter()
+	r.models["m"] = route
+	r.plans["m"] = &router.RoutePlan{Routes: []*router.ResolvedRoute{route}}
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	NewCompletionsHandler(&config.Config{}, r)(c)
+
+	if w.Code != http.StatusUnauthorized || calls != 1 {
+		t.Errorf("expected a single attempt answered with 401, got %d after %d calls", w.Code, calls)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"ai-proxy/config"
+	"ai-proxy/proxy"
+	"ai-proxy/router"
+)
+
+// upstreamDialect returns the dialect of the handler's current route's provider.
+func upstreamDialect(h Handler) string {
+	if dh, ok := h.(DialectHandler); ok {
+		return dh.UpstreamDialect()
+	}
+	return ""
+}
+
+// upstreamClientOptions returns the upstream client options of the handler's current route.
+func upstreamClientOptions(h Handler) []proxy.ClientOption {
+	if oh, ok := h.(ClientOptionsHandler); ok {
+		return oh.UpstreamClientOptions()
+	}
+	return nil
+}
+
+// routeClientOptions returns the upstream client options of a route: how its
+// provider takes the API key, the provider's static headers, the default
+// headers of the route's protocol, and the provider's shared connection pool.
+//
+// @param route - The current route, may be nil.
+// @return The options, nil if route is nil.
+func routeClientOptions(route *router.ResolvedRoute) []proxy.ClientOption {
+	if route == nil {
+		return nil
+	}
+	return protocolClientOptions(route, route.OutputProtocol)
+}
+
+// protocolClientOptions returns the upstream client options for the endpoint
+// of the given protocol of a route's provider.
+func protocolClientOptions(route *router.ResolvedRoute, protocol string) []proxy.ClientOption {
+	p := &route.Provider
+	opts := []proxy.ClientOption{
+		proxy.WithAuth(p.UpstreamAuth(protocol)),
+		proxy.WithHeaders(p.Headers),
+		proxy.WithDefaultHeaders(config.DefaultHeaders(protocol)),
+	}
+	if route.Transport != nil {
+		opts = append(opts, proxy.WithPooledTransport(route.Transport))
+	}
+	return opts
+}
+
+// azureError is the error envelope of Azure OpenAI. Model errors use the
+// "error" object; the API gateway reports authentication and quota failures
+// with top-level statusCode and message fields.
+type azureError struct {
+	Error *struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError *struct {
+			Code string `json:"code"`
+		} `json:"innererror"`
+	} `json:"error"`
+	Message string `json:"message"`
+}
+
+// upstreamErrorMessage returns the message reported to the client for an
+// upstream error body. Bodies of the standard dialect are returned as-is;
+// Azure error envelopes are reduced to their code and message, e.g.
+// "content_filter (ResponsibleAIPolicyViolation): The response was filtered".
+//
+// @param dialect - The provider's dialect.
+// @param body - The upstream error response body.
+// @return The message, the body itself if it is not a recognised envelope.
+func upstreamErrorMessage(dialect string, body []byte) string {
+	if dialect != config.DialectAzure {
+		return string(body)
+	}
+
+	var env azureError
+	if err := json.Unmarshal(body, &env); err != nil {
+		return string(body)
+	}
+	if env.Error == nil {
+		if env.Message != "" {
+			return env.Message
+		}
+		return string(body)
+	}
+	if env.Error.Message == "" {
+		return string(body)
+	}
+
+	code := env.Error.Code
+	if env.Error.InnerError != nil && env.Error.InnerError.Code != "" {
+		code += " (" + env.Error.InnerError.Code + ")"
+	}
+	if code == "" {
+		return env.Error.Message
+	}
+	return code + ": " + env.Error.Message
+}
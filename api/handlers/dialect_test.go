@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ai-proxy/config"
+	"ai-proxy/proxy"
+)
+
+// newAzureProvider returns an Azure OpenAI provider with chat and responses endpoints.
+func newAzureProvider() config.Provider {
+	return config.Provider{
+		Name:    "azure",
+		Dialect: config.DialectAzure,
+		Endpoints: map[string]string{
+			"openai":    "https://res.openai.azure.com/openai/deployments/{model}/chat/completions",
+			"responses": "https://res.openai.azure.com/openai/responses",
+		},
+		Default:     "openai",
+		QueryParams: map[string]string{"api-version": "2024-10-21"},
+		APIKey:      "azure-key",
+	}
+}
+
+// newAzureRouter returns a router resolving "gpt" to the Azure deployment
+// gpt-4o-prod over the given protocol.
+func newAzureRouter(protocol string) *mockRouter {
+	return newSingleRouteRouter("gpt", mockRoute(newAzureProvider(), "gpt-4o-prod", protocol))
+}
+
+// azureUpstream returns a fake Azure upstream answering with the given status
+// and body, which checks that requests authenticate with the api-key header.
+func azureUpstream(t *testing.T, status int, body string) func(*http.Request) *http.Response {
+	return func(req *http.Request) *http.Response {
+		if got := req.Header.Get("Api-Key"); got != "azure-key" {
+			t.Errorf("Api-Key = %q, want azure-key", got)
+		}
+		if got := req.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization should not be sent to Azure, got %q", got)
+		}
+		return upstreamResponse(status, "", body)
+	}
+}
+
+func TestCompletionsHandler_AzureDialect(t *testing.T) {
+	w := serveUpstream(t, NewCompletionsHandler, newAzureRouter("openai"), "/v1/chat/completions",
+		`{"model":"gpt","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		azureUpstream(t, http.StatusOK, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"content":"Hello"`) {
+		t.Errorf("expected streamed content, got %s", w.Body.String())
+	}
+}
+
+func TestResponsesHandler_AzureDialectError(t *testing.T) {
+	w := serveUpstream(t, NewResponsesHandler, newAzureRouter("responses"), "/v1/responses",
+		`{"model":"gpt","stream":true,"input":"hi"}`,
+		azureUpstream(t, http.StatusNotFound, `{"error":{"code":"DeploymentNotFound","message":"The API deployment for this resource does not exist."}}`))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid error response: %v", err)
+	}
+	if want := "DeploymentNotFound: The API deployment for this resource does not exist."; resp.Error.Message != want {
+		t.Errorf("message = %q, want %q", resp.Error.Message, want)
+	}
+}
+
+func TestAzureUpstreamURL(t *testing.T) {
+	chat := mockRoute(newAzureProvider(), "gpt-4o-prod", "openai")
+	if got, want := (&CompletionsHandler{route: chat}).UpstreamURL(), "https://res.openai.azure.com/openai/deployments/gpt-4o-prod/chat/completions?api-version=2024-10-21"; got != want {
+		t.Errorf("CompletionsHandler.UpstreamURL() = %q, want %q", got, want)
+	}
+	responses := mockRoute(newAzureProvider(), "gpt-4o-prod", "responses")
+	if got, want := (&ResponsesHandler{route: responses}).UpstreamURL(), "https://res.openai.azure.com/openai/responses?api-version=2024-10-21"; got != want {
+		t.Errorf("ResponsesHandler.UpstreamURL() = %q, want %q", got, want)
+	}
+}
+
+func TestRouteClientOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    config.Provider
+		protocol    string
+		clientHdrs  map[string]string
+		wantHeaders map[string]string
+		wantQuery   string
+	}{
+		{
+			name:        "openai bearer",
+			provider:    mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"),
+			protocol:    "openai",
+			wantHeaders: map[string]string{"Authorization": "Bearer sk-test", "X-Api-Key": ""},
+		},
+		{
+			name:        "anthropic x-api-key and version",
+			provider:    mockLegacyProvider("anthropic", "anthropic", "https://api.anthropic.com/v1/messages"),
+			protocol:    "anthropic",
+			wantHeaders: map[string]string{"X-Api-Key": "sk-test", "Authorization": "", "Anthropic-Version": "2023-06-01"},
+		},
+		{
+			name:        "anthropic version from client kept",
+			provider:    mockLegacyProvider("anthropic", "anthropic", "https://api.anthropic.com/v1/messages"),
+			protocol:    "anthropic",
+			clientHdrs:  map[string]string{"Anthropic-Version": "2024-01-01"},
+			wantHeaders: map[string]string{"Anthropic-Version": "2024-01-01"},
+		},
+		{
+			name:        "gemini",
+			provider:    mockLegacyProvider("google", "gemini", geminiEndpoint),
+			protocol:    "gemini",
+			wantHeaders: map[string]string{"X-Goog-Api-Key": "sk-test", "Authorization": ""},
+		},
+		{
+			name:        "azure",
+			provider:    newAzureProvider(),
+			protocol:    "openai",
+			wantHeaders: map[string]string{"Api-Key": "sk-test", "Authorization": ""},
+		},
+		{
+			name: "custom header and static headers",
+			provider: config.Provider{
+				Name:      "gateway",
+				Endpoints: map[string]string{"openai": "https://gw.example.com/v1/chat/completions"},
+				Auth:      &config.ProviderAuth{Type: config.AuthHeader, Name: "X-Gateway-Key"},
+				Headers:   map[string]string{"X-Tenant-Id": "acme", "X-DashScope-SSE": "enable"},
+			},
+			protocol:    "openai",
+			clientHdrs:  map[string]string{"X-Tenant-Id": "spoofed"},
+			wantHeaders: map[string]string{"X-Gateway-Key": "sk-test", "Authorization": "", "X-Tenant-Id": "acme", "X-Dashscope-Sse": "enable"},
+		},
+		{
+			name: "query parameter",
+			provider: config.Provider{
+				Name:      "gateway",
+				Endpoints: map[string]string{"openai": "https://gw.example.com/v1/chat/completions?v=1"},
+				Auth:      &config.ProviderAuth{Type: config.AuthQuery, Name: "key"},
+			},
+			protocol:    "openai",
+			wantHeaders: map[string]string{"Authorization": ""},
+			wantQuery:   "key=sk-test&v=1",
+		},
+		{
+			name: "bearer overrides protocol default",
+			provider: config.Provider{
+				Name:      "proxy",
+				Endpoints: map[string]string{"anthropic": "https://gw.example.com/v1/messages"},
+				Auth:      &config.ProviderAuth{Type: config.AuthBearer},
+			},
+			protocol:    "anthropic",
+			wantHeaders: map[string]string{"Authorization": "Bearer sk-test", "X-Api-Key": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := mockRoute(tt.provider, "model", tt.protocol)
+			client := proxy.NewClient(route.Provider.GetModelEndpoint(tt.protocol, "model"), "sk-test", routeClientOptions(route)...)
+			req, err := client.BuildRequest(context.Background(), []byte(`{}`))
+			if err != nil {
+				t.Fatalf("BuildRequest returned error: %v", err)
+			}
+			for name, value := range tt.clientHdrs {
+				req.Header.Set(name, value)
+			}
+			client.SetHeaders(req)
+
+			for name, want := range tt.wantHeaders {
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.wantQuery != "" && req.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestUpstreamErrorMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		body    string
+		want    string
+	}{
+		{
+			name: "standard dialect keeps body",
+			body: `{"error":{"code":"x","message":"y"}}`,
+			want: `{"error":{"code":"x","message":"y"}}`,
+		},
+		{
+			name:    "azure error object",
+			dialect: config.DialectAzure,
+			body:    `{"error":{"code":"429","message":"Rate limit exceeded."}}`,
+			want:    "429: Rate limit exceeded.",
+		},
+		{
+			name:    "azure content filter",
+			dialect: config.DialectAzure,
+			body:    `{"error":{"message":"The response was filtered","type":null,"param":"prompt","code":"content_filter","status":400,"innererror":{"code":"ResponsibleAIPolicyViolation"}}}`,
+			want:    "content_filter (ResponsibleAIPolicyViolation): The response was filtered",
+		},
+		{
+			name:    "azure gateway error",
+			dialect: config.DialectAzure,
+			body:    `{"statusCode":401,"message":"Unauthorized. Access token is missing, invalid, audience is incorrect, or have expired."}`,
+			want:    "Unauthorized. Access token is missing, invalid, audience is incorrect, or have expired.",
+		},
+		{
+			name:    "azure non-JSON body",
+			dialect: config.DialectAzure,
+			body:    "upstream connect error",
+			want:    "upstream connect error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := upstreamErrorMessage(tt.dialect, []byte(tt.body)); got != tt.want {
+				t.Errorf("upstreamErrorMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ai-proxy/events"
+	"ai-proxy/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsBuffer is how many events may wait for a slow /admin/events client
+// before further events are dropped.
+const eventsBuffer = 256
+
+// eventsKeepalive is how often an idle /admin/events stream sends a comment,
+// so proxies and clients do not time the connection out.
+const eventsKeepalive = 15 * time.Second
+
+// NewEventsHandler creates a Gin handler for the GET /admin/events endpoint,
+// which streams request lifecycle events as server-sent events, named by
+// event type, until the client disconnects or the server shuts down.
+//
+// Query parameters, each keeping only matching events:
+//   - session: the X-Session-ID of the request.
+//   - model: the model alias or upstream model.
+//   - client: the client key name.
+//
+// @param bus - the bus to subscribe to, events.Default in the server.
+// @return Gin handler function that streams events.
+//
+// @note Events a slow client cannot keep up with are dropped, not queued.
+func NewEventsHandler(bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub := bus.Subscribe(events.Filter{
+			Session: c.Query("session"),
+			Model:   c.Query("model"),
+			Client:  c.Query("client"),
+		}, eventsBuffer)
+		defer sub.Close()
+
+		setStreamHeaders(c)
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		keepalive := time.NewTicker(eventsKeepalive)
+		defer keepalive.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-keepalive.C:
+				fmt.Fprint(c.Writer, ": keepalive\n\n")
+			case e, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					logging.ErrorMsg("Failed to marshal event: %v", err)
+					continue
+				}
+				fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", e.Type, data)
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/config"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatUpstreamStream is a minimal Chat Completions SSE stream.
+const chatUpstreamStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`
+
+// newFallbackRouter returns a router resolving "claude" to an Anthropic primary
+// route followed by the given fallback routes.
+func newFallbackRouter(fallbacks ...*router.ResolvedRoute) *mockRouter {
+	r := newMockRouter()
+	primary := mockRoute(mockLegacyProvider("anthropic", "anthropic", "https://api.anthropic.com/v1/messages"), "claude-primary", "anthropic")
+	r.models["claude"] = primary
+	r.plans["claude"] = &router.RoutePlan{Routes: append([]*router.ResolvedRoute{primary}, fallbacks...)}
+	return r
+}
+
+// upstreamModel extracts the model field from an upstream request body.
+func upstreamModel(t *testing.T, req *http.Request) (string, map[string]interface{}) {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode upstream body: %v", err)
+	}
+	model, _ := body["model"].(string)
+	return model, body
+}
+
+func TestMessagesHandler_FallbackToOpenAIRoute(t *testing.T) {
+	var models []string
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		model, body := upstreamModel(t, req)
+		models = append(models, model)
+		if model == "claude-primary" {
+			return errorResponse(http.StatusServiceUnavailable), nil
+		}
+		// The fallback request must be re-transformed to Chat Completions
+		if _, ok := body["stream_options"]; !ok {
+			t.Errorf("expected Chat Completions request for fallback, got %v", body)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"claude","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+
+	fallback := mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"), "gpt-4o", "openai")
+	NewMessagesHandler(&config.Config{}, newFallbackRouter(fallback))(c)
+
+	if fmt.Sprint(models) != "[claude-primary gpt-4o]" {
+		t.Fatalf("expected primary then fallback, got %v", models)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"content":"Hello"`) {
+		t.Errorf("expected fallback stream in response\n%s", w.Body.String())
+	}
+}
+
+func TestMessagesHandler_FallbackToResponsesRoute(t *testing.T) {
+	var models []string
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		model, body := upstreamModel(t, req)
+		models = append(models, model)
+		if model == "claude-primary" {
+			return errorResponse(http.StatusBadGateway), nil
+		}
+		if _, ok := body["input"]; !ok {
+			t.Errorf("expected Responses request for fallback, got %v", body)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(responsesUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"claude","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+
+	fallback := mockRoute(responsesOnlyProvider(), "gpt-5", "responses")
+	NewMessagesHandler(&config.Config{}, newFallbackRouter(fallback))(c)
+
+	if fmt.Sprint(models) != "[claude-primary gpt-5]" {
+		t.Fatalf("expected primary then fallback, got %v", models)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"event: message_start", `"text":"Hello","type":"text_delta"`, "event: message_stop"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %q\n%s", want, body)
+		}
+	}
+	if strings.Count(body, "event: message_start") != 1 {
+		t.Errorf("expected a single message_start\n%s", body)
+	}
+}
+
+func TestResponsesHandler_FallbackEmitsSingleResponseCreated(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		model, _ := upstreamModel(t, req)
+		if model == "gpt-4o" {
+			return errorResponse(http.StatusServiceUnavailable), nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(responsesUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	r := newMockRouter()
+	primary := mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"), "gpt-4o", "openai")
+	fallback := mockRoute(responsesOnlyProvider(), "gpt-5", "responses")
+	r.models["alias"] = primary
+	r.plans["alias"] = &router.RoutePlan{Routes: []*router.ResolvedRoute{primary, fallback}}
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(
+		`{"model":"alias","input":"hi","stream":true,"store":false}`))
+
+	NewResponsesHandler(&config.Config{}, r)(c)
+
+	body := w.Body.String()
+	// The Chat Completions route would emit its own response.created before
+	// the upstream call; it must not leak into the fallback's stream
+	if n := strings.Count(body, "event: response.created"); n != 1 {
+		t.Errorf("expected 1 response.created, got %d\n%s", n, body)
+	}
+	if !strings.Contains(body, "event: response.completed") {
+		t.Errorf("expected fallback stream to complete\n%s", body)
+	}
+}
+
+func TestMessagesHandler_FallbackSkippedForClientError(t *testing.T) {
+	var calls int
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return errorResponse(http.StatusBadRequest), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"claude","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+
+	fallback := mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"), "gpt-4o", "openai")
+	NewMessagesHandler(&config.Config{}, newFallbackRouter(fallback))(c)
+
+	if calls != 1 {
+		t.Errorf("expected 1 upstream call, got %d", calls)
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestMessagesHandler_AllRoutesFail(t *testing.T) {
+	var models []string
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		model, _ := upstreamModel(t, req)
+		models = append(models, model)
+		if model == "claude-primary" {
+			return nil, errors.New("connection refused")
+		}
+		return errorResponse(http.StatusTooManyRequests), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"claude","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+
+	first := mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"), "gpt-4o", "openai")
+	second := mockRoute(responsesOnlyProvider(), "gpt-5", "responses")
+	NewMessagesHandler(&config.Config{}, newFallbackRouter(first, second))(c)
+
+	if fmt.Sprint(models) != "[claude-primary gpt-4o gpt-5]" {
+		t.Fatalf("expected every route to be tried in order, got %v", models)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected last route's status 429, got %d", w.Code)
+	}
+}
+
+func TestCompletionsHandler_NonStreamingFallback(t *testing.T) {
+	var models []string
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		model, _ := upstreamModel(t, req)
+		models = append(models, model)
+		if model == "claude-primary" {
+			return nil, errors.New("connection reset by peer")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(responsesUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"claude","stream":false,"messages":[{"role":"user","content":"hi"}]}`))
+
+	fallback := mockRoute(responsesOnlyProvider(), "gpt-5", "responses")
+	NewCompletionsHandler(&config.Config{}, newFallbackRouter(fallback))(c)
+
+	if fmt.Sprint(models) != "[claude-primary gpt-5]" {
+		t.Fatalf("expected primary then fallback, got %v", models)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"content":"Hello"`) {
+		t.Errorf("expected assembled completion from fallback, got %s", w.Body.String())
+	}
+}
+
+func TestMessagesHandler_NextRoute(t *testing.T) {
+	fallback := mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"), "gpt-4o", "openai")
+	h := &MessagesHandler{cfg: &config.Config{}, modelRouter: newFallbackRouter(fallback)}
+
+	if err := h.ValidateRequest([]byte(`{"model":"claude"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !h.HasNextRoute() {
+		t.Fatal("expected a fallback route")
+	}
+	if !h.NextRoute() {
+		t.Fatal("expected NextRoute to succeed")
+	}
+	if h.route != fallback || h.UpstreamURL() != "https://api.openai.com/v1/chat/completions" {
+		t.Errorf("expected handler to use the fallback route, got %+v", h.route)
+	}
+	if h.HasNextRoute() || h.NextRoute() {
+		t.Error("expected no route after the last fallback")
+	}
+	if _, upstream := h.ModelInfo(); upstream != "gpt-4o" {
+		t.Errorf("expected upstream model gpt-4o, got %q", upstream)
+	}
+}
+
+func TestIsFallbackFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"transport error", nil, errors.New("dial tcp: connection refused"), true},
+		{"client cancelled", nil, context.Canceled, false},
+		{"deadline exceeded", nil, context.DeadlineExceeded, false},
+		{"bad request", errorResponse(http.StatusBadRequest), nil, false},
+		{"unprocessable", errorResponse(http.StatusUnprocessableEntity), nil, false},
+		{"unauthorized", errorResponse(http.StatusUnauthorized), nil, true},
+		{"forbidden", errorResponse(http.StatusForbidden), nil, true},
+		{"not found", errorResponse(http.StatusNotFound), nil, true},
+		{"rate limited", errorResponse(http.StatusTooManyRequests), nil, true},
+		{"server error", errorResponse(http.StatusInternalServerError), nil, true},
+		{"overloaded", errorResponse(529), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFallbackFailure(tt.resp, tt.err); got != tt.want {
+				t.Errorf("isFallbackFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ai-proxy/config"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geminiUpstreamStream is a minimal Gemini streamGenerateContent?alt=sse stream
+// with reasoning, text and a function call.
+const geminiUpstreamStream = `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Pondering","thought":true}]},"index":0}],"modelVersion":"gemini-2.5-flash","responseId":"g1"}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"index":0}],"modelVersion":"gemini-2.5-flash","responseId":"g1"}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"cat"}}}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":6,"thoughtsTokenCount":2,"totalTokenCount":20},"modelVersion":"gemini-2.5-flash","responseId":"g1"}
+
+`
+
+// geminiEndpoint is a Gemini endpoint template.
+const geminiEndpoint = "https://generativelanguage.googleapis.com/v1beta/models/{model}:streamGenerateContent?alt=sse"
+
+// newGeminiRouter returns a router resolving "gemini" to a Gemini route.
+func newGeminiRouter() *mockRouter {
+	provider := mockLegacyProvider("google", "gemini", geminiEndpoint)
+	provider.APIKey = "goog-key"
+	return newSingleRouteRouter("gemini", mockRoute(provider, "gemini-2.5-flash", "gemini"))
+}
+
+// serveGemini runs a request through a handler against a fake Gemini upstream
+// and returns the response body and the upstream request body.
+func serveGemini(t *testing.T, newHandler func(*config.Config, router.Router) gin.HandlerFunc, path, body string) (string, map[string]interface{}) {
+	t.Helper()
+	var upstream map[string]interface{}
+	w := serveUpstream(t, newHandler, newGeminiRouter(), path, body, func(req *http.Request) *http.Response {
+		if got := req.Header.Get("X-Goog-Api-Key"); got != "goog-key" {
+			t.Errorf("X-Goog-Api-Key = %q, want goog-key", got)
+		}
+		if got := req.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization should not be sent to Gemini, got %q", got)
+		}
+		if err := json.NewDecoder(req.Body).Decode(&upstream); err != nil {
+			t.Fatalf("failed to decode upstream body: %v", err)
+		}
+		return upstreamResponse(http.StatusOK, "", geminiUpstreamStream)
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, w.Body.String())
+	}
+	if _, ok := upstream["contents"]; !ok {
+		t.Errorf("expected Gemini request upstream, got %v", upstream)
+	}
+	if _, ok := upstream["model"]; ok {
+		t.Errorf("model belongs in the URL, not the Gemini body: %v", upstream)
+	}
+	return w.Body.String(), upstream
+}
+
+func TestCompletionsHandler_GeminiUpstream(t *testing.T) {
+	body, upstream := serveGemini(t, NewCompletionsHandler, "/v1/chat/completions",
+		`{"model":"gemini","stream":true,"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hi"}],
+		"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`)
+
+	if _, ok := upstream["systemInstruction"]; !ok {
+		t.Errorf("expected systemInstruction upstream, got %v", upstream)
+	}
+	for _, want := range []string{
+		`"reasoning_content":"Pondering"`,
+		`"content":"Hello"`,
+		`"name":"lookup"`,
+		`"finish_reason":"tool_calls"`,
+		`"prompt_tokens":12`,
+		"data: [DONE]",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestMessagesHandler_GeminiUpstream(t *testing.T) {
+	body, _ := serveGemini(t, NewMessagesHandler, "/v1/messages",
+		`{"model":"gemini","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}],
+		"tools":[{"name":"lookup","input_schema":{"type":"object"}}]}`)
+
+	for _, want := range []string{
+		"event: message_start",
+		`"thinking":"Pondering"`,
+		`"text":"Hello"`,
+		`"type":"tool_use"`,
+		`"stop_reason":"tool_use"`,
+		"event: message_stop",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestResponsesHandler_GeminiUpstream(t *testing.T) {
+	body, _ := serveGemini(t, NewResponsesHandler, "/v1/responses",
+		`{"model":"gemini","stream":true,"input":"hi","tools":[{"type":"function","name":"lookup","parameters":{"type":"object"}}]}`)
+
+	for _, want := range []string{
+		`"type":"response.created"`,
+		`"delta":"Hello"`,
+		`"type":"function_call"`,
+		`"name":"lookup"`,
+		`"type":"response.completed"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestGeminiUpstreamURL(t *testing.T) {
+	route := mockRoute(mockLegacyProvider("google", "gemini", geminiEndpoint), "gemini-2.5-pro", "gemini")
+	want := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse"
+
+	if got := (&CompletionsHandler{route: route}).UpstreamURL(); got != want {
+		t.Errorf("CompletionsHandler.UpstreamURL() = %q, want %q", got, want)
+	}
+	if got := (&MessagesHandler{route: route}).UpstreamURL(); got != want {
+		t.Errorf("MessagesHandler.UpstreamURL() = %q, want %q", got, want)
+	}
+	if got := (&ResponsesHandler{route: route}).UpstreamURL(); got != want {
+		t.Errorf("ResponsesHandler.UpstreamURL() = %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,12 @@
 package handlers
 
 import (
+	"net/http"
+	"strconv"
+
+	"ai-proxy/circuit"
+	"ai-proxy/router"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,3 +29,59 @@ func HealthCheck(c *gin.Context) {
 	// Does not verify upstream connectivity or database health
 	c.JSON(200, gin.H{"status": "ok"})
 }
+
+// HealthHandler serves /health with optional per-provider detail.
+// Without query parameters it behaves like HealthCheck. With ?detailed=true it
+// lists the circuit breaker of every provider: its state, consecutive failures,
+// error rate over recent requests and last error message.
+type HealthHandler struct {
+	modelRouter router.Router
+}
+
+// NewHealthHandler creates a Gin handler for the GET /health endpoint.
+//
+// @param r - Model router owning the provider circuit breakers. Must not be nil.
+// @return Gin handler function that reports service and provider health.
+func NewHealthHandler(r router.Router) gin.HandlerFunc {
+	h := &HealthHandler{modelRouter: r}
+	return h.Handle
+}
+
+// Handle writes the health status as JSON.
+//
+// @param c - Gin context for the HTTP request.
+//
+// @post Without ?detailed=true, the response is HealthCheck's.
+// @post In detailed mode, "status" is "ok" when no breaker is open, "degraded"
+// when some are, and "unavailable" with HTTP 503 when every enabled breaker is open.
+func (h *HealthHandler) Handle(c *gin.Context) {
+	if detailed, _ := strconv.ParseBool(c.Query("detailed")); !detailed {
+		HealthCheck(c)
+		return
+	}
+
+	providers := h.modelRouter.ProviderHealth()
+	if providers == nil {
+		providers = []circuit.Stats{}
+	}
+
+	enabled, open := 0, 0
+	for _, p := range providers {
+		if p.State == circuit.StateDisabled {
+			continue
+		}
+		enabled++
+		if p.State == circuit.StateOpen {
+			open++
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	switch {
+	case open > 0 && open == enabled:
+		status, code = "unavailable", http.StatusServiceUnavailable
+	case open > 0:
+		status = "degraded"
+	}
+	c.JSON(code, gin.H{"status": status, "providers": providers})
+}
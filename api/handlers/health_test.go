@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"ai-proxy/circuit"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,3 +44,51 @@ func TestHealthCheck_ContentType(t *testing.T) {
 		t.Errorf("expected content type 'application/json; charset=utf-8', got %q", contentType)
 	}
 }
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		health     []circuit.Stats
+		wantCode   int
+		wantStatus string
+		wantDetail bool
+	}{
+		{name: "plain mode", query: "", health: []circuit.Stats{{Provider: "a", State: circuit.StateOpen}}, wantCode: 200, wantStatus: "ok"},
+		{name: "all closed", query: "?detailed=true", health: []circuit.Stats{{Provider: "a", State: circuit.StateClosed}, {Provider: "b", State: circuit.StateDisabled}}, wantCode: 200, wantStatus: "ok", wantDetail: true},
+		{name: "some open", query: "?detailed=1", health: []circuit.Stats{{Provider: "a", State: circuit.StateOpen}, {Provider: "b", State: circuit.StateHalfOpen}}, wantCode: 200, wantStatus: "degraded", wantDetail: true},
+		{name: "all open", query: "?detailed=true", health: []circuit.Stats{{Provider: "a", State: circuit.StateOpen}, {Provider: "b", State: circuit.StateDisabled}}, wantCode: 503, wantStatus: "unavailable", wantDetail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockR := newMockRouter()
+			mockR.health = tt.health
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/health"+tt.query, nil)
+			NewHealthHandler(mockR)(c)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			var response struct {
+				Status    string          `json:"status"`
+				Providers []circuit.Stats `json:"providers"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if response.Status != tt.wantStatus {
+				t.Errorf("expected status %q, got %q", tt.wantStatus, response.Status)
+			}
+			if tt.wantDetail && len(response.Providers) != len(tt.health) {
+				t.Errorf("expected %d providers, got %d", len(tt.health), len(response.Providers))
+			}
+			if !tt.wantDetail && response.Providers != nil {
+				t.Error("plain mode should not list providers")
+			}
+		})
+	}
+}
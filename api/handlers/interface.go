@@ -7,7 +7,12 @@ import (
 	"io"
 	"net/http"
 
+	"ai-proxy/balance"
+	"ai-proxy/circuit"
+	"ai-proxy/config"
+	"ai-proxy/proxy"
 	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
 
 	"github.com/gin-gonic/gin"
 )
@@ -111,3 +116,180 @@ type Handler interface {
 	// @note Used for logging and debugging purposes.
 	ModelInfo() (downstreamModel string, upstreamModel string)
 }
+
+// AggregatingHandler is implemented by handlers that can serve clients which send
+// "stream": false. The upstream request is always streamed; the handler's transformer
+// output is fed into the returned aggregator, which assembles the non-streaming body.
+//
+// This is an optional interface checked via type assertion in Handle().
+type AggregatingHandler interface {
+	// NewAggregator switches the handler into non-streaming mode and returns the
+	// aggregator for its downstream protocol.
+	//
+	// @return Aggregator that builds the endpoint's non-streaming JSON response.
+	//
+	// @pre Called at most once per request, before ValidateRequest.
+	// @post WriteError emits plain JSON errors for the rest of the request.
+	NewAggregator() aggregate.Aggregator
+}
+
+// RetryingHandler is implemented by handlers whose upstream requests may be retried.
+// Retries happen only before any upstream response data is streamed to the client.
+//
+// This is an optional interface checked via type assertion in doUpstream().
+type RetryingHandler interface {
+	// RetryPolicy returns the retry policy for the resolved upstream provider.
+	//
+	// @return Policy to apply; the zero value performs a single attempt.
+	//
+	// @pre Called after ValidateRequest.
+	RetryPolicy() proxy.RetryPolicy
+}
+
+// FallbackHandler is implemented by handlers that can move a request to the next
+// route of its route plan when the current route fails before streaming starts.
+// After switching, TransformRequest is run again so the body matches the new
+// route's protocol.
+//
+// This is an optional interface checked via type assertion in sendUpstream().
+type FallbackHandler interface {
+	// HasNextRoute reports whether a fallback route follows the current route.
+	//
+	// @pre Called after ValidateRequest.
+	HasNextRoute() bool
+
+	// NextRoute switches the handler to the next route of its plan.
+	//
+	// @return false if no route follows the current one; the handler is unchanged.
+	//
+	// @post On true, TransformRequest, UpstreamURL, ResolveAPIKey, ForwardHeaders,
+	// CreateTransformer, and RetryPolicy use the new route.
+	NextRoute() bool
+}
+
+// BalancedHandler is implemented by handlers whose routes carry a load-balanced
+// provider API key. The key is held in flight while its upstream request and
+// response stream are open, and the upstream outcome is recorded on it so keys
+// answering 401 or 429 are ejected from rotation.
+//
+// This is an optional interface checked via type assertion in sendUpstream().
+type BalancedHandler interface {
+	// UpstreamKey returns the API key selected for the current route.
+	//
+	// @return The key, nil if the provider's default key is used.
+	//
+	// @pre Called after ValidateRequest.
+	UpstreamKey() *balance.Key
+}
+
+// BreakerHandler is implemented by handlers whose routes carry their provider's
+// circuit breaker. Requests are only sent while the breaker allows them, their
+// outcome is recorded on it, and its first-byte timeout bounds each attempt.
+//
+// This is an optional interface checked via type assertion in sendUpstream()
+// and doUpstream().
+type BreakerHandler interface {
+	// UpstreamBreaker returns the circuit breaker of the current route's provider.
+	//
+	// @return The breaker, nil if the route has none.
+	//
+	// @pre Called after ValidateRequest.
+	UpstreamBreaker() *circuit.Breaker
+}
+
+// CredentialHandler is implemented by handlers whose routes may fetch their
+// provider API key from a credential source, such as a rotated key file or an
+// OAuth2 token endpoint. A failed fetch is reported before the upstream request
+// is sent, and a fetched key rejected with 401 is dropped and the request
+// retried once with a fresh one.
+//
+// This is an optional interface checked via type assertion in
+// prepareUpstreamRequest() and sendUpstream().
+type CredentialHandler interface {
+	// FetchCredential fetches the current route's key from its credential
+	// source, if it has one, so ResolveAPIKey returns it from the cache.
+	//
+	// @return Error if the credential source failed.
+	//
+	// @pre Called after ValidateRequest.
+	FetchCredential(ctx context.Context) error
+	// InvalidateCredential drops the current route's fetched key after the
+	// upstream rejected it.
+	//
+	// @return true if the key was fetched and a retry may succeed with a fresh one.
+	InvalidateCredential() bool
+}
+
+// InstrumentedHandler is implemented by handlers that label the proxy traffic
+// metrics with their inbound protocol and the route that served the request.
+//
+// This is an optional interface checked via type assertion when Handle()
+// records the request's metrics.
+type InstrumentedHandler interface {
+	// MetricLabels returns the metric labels of the current route.
+	//
+	// @return inbound - the protocol the client spoke: "anthropic", "openai" or "responses".
+	// @return provider - the current route's provider, empty if no route was resolved.
+	// @return upstreamProtocol - the protocol sent upstream, empty if no route was resolved.
+	MetricLabels() (inbound, provider, upstreamProtocol string)
+}
+
+// LimitedHandler is implemented by handlers whose model alias may carry
+// per-client limits and whose routes carry the price of their upstream model.
+// Requests are admitted against the alias's limits for the client, and the
+// upstream usage is priced at the current route's price, charged to those
+// limits and recorded in the usage ledger.
+//
+// This is an optional interface checked via type assertion in Handle().
+type LimitedHandler interface {
+	// ModelLimits returns the limits of the resolved model alias.
+	//
+	// @return The limits, nil if the alias has none.
+	//
+	// @pre Called after ValidateRequest.
+	ModelLimits() *config.LimitsConfig
+
+	// RoutePrice returns the price of the current route's upstream model.
+	//
+	// @return The price, nil if the model has none or no route was resolved.
+	RoutePrice() *config.ModelPrice
+}
+
+// DialectHandler is implemented by handlers whose route's provider may speak a
+// provider-specific dialect, such as Azure OpenAI's error envelope.
+//
+// This is an optional interface checked via type assertion when an upstream
+// error is returned to the client.
+type DialectHandler interface {
+	// UpstreamDialect returns the dialect of the current route's provider.
+	//
+	// @return The provider's dialect, empty for the standard dialect or if no route was resolved.
+	UpstreamDialect() string
+}
+
+// ClientOptionsHandler is implemented by handlers whose route's provider
+// configures how the upstream request is authenticated and which headers it
+// carries, such as an x-api-key, static tenant headers or anthropic-version.
+//
+// This is an optional interface checked via type assertion when the upstream
+// client is created.
+type ClientOptionsHandler interface {
+	// UpstreamClientOptions returns the upstream client options of the current route.
+	//
+	// @return The options, nil if no route was resolved.
+	UpstreamClientOptions() []proxy.ClientOption
+}
+
+// StreamFormatHandler is implemented by handlers whose streaming responses are
+// not server-sent events, such as the newline-delimited JSON of the Ollama API.
+// Their transformers write that format to the client, and captured downstream
+// output is recorded line by line.
+//
+// This is an optional interface checked via type assertion in proxyRequest()
+// and streamWithCapture().
+type StreamFormatHandler interface {
+	// StreamContentType returns the Content-Type of streaming responses.
+	//
+	// @return A media type, e.g. "application/x-ndjson".
+	StreamContentType() string
+}
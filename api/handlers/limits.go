@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ai-proxy/auth"
+	"ai-proxy/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admitRequest admits a request against the rate limits and quotas of its
+// client key and of the client's use of the requested model alias.
+// Rejected requests receive a 429 in the handler's protocol with a Retry-After
+// header; admitted requests receive x-ratelimit-* headers for the tightest limits.
+//
+// @param c - Gin context of the request; no response has been written yet.
+// @param h - Handler of the request, after ValidateRequest.
+// @return limiters - the limiters to charge with the request's usage.
+// @return ok - false if the request was rejected and the error written.
+func admitRequest(c *gin.Context, h Handler) (limiters []*ratelimit.Limiter, ok bool) {
+	id := auth.FromContext(c.Request.Context())
+	client := clientScope(id)
+	if id != nil {
+		limiters = append(limiters, ratelimit.Default.Get(client, id.Limits))
+	}
+	if lh, isLimited := h.(LimitedHandler); isLimited {
+		model, _ := h.ModelInfo()
+		scope := fmt.Sprintf("model '%s'", model)
+		if client != "" {
+			scope += " for " + client
+		}
+		limiters = append(limiters, ratelimit.Default.Get(scope, lh.ModelLimits()))
+	}
+
+	status, err := ratelimit.Admit(limiters...)
+	if err != nil {
+		retryAfter := ceilSeconds(err.RetryAfter)
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		c.Header("x-ratelimit-reset-"+err.Limit, retryAfter.String())
+		h.WriteError(c, http.StatusTooManyRequests,
+			fmt.Sprintf("Rate limit exceeded: %s. Retry after %s.", err, retryAfter))
+		return nil, false
+	}
+
+	setRateLimitHeader(c, "requests", status.Requests)
+	setRateLimitHeader(c, "tokens", status.Tokens)
+	setRateLimitHeader(c, "spend", status.Spend)
+	return limiters, true
+}
+
+// clientScope names the rate-limit scope of an authenticated client.
+//
+// @return The scope, empty if the request is not authenticated.
+func clientScope(id *auth.Identity) string {
+	switch {
+	case id == nil:
+		return ""
+	case id.Name != "":
+		return fmt.Sprintf("client key '%s'", id.Name)
+	default:
+		return fmt.Sprintf("user '%s'", id.User)
+	}
+}
+
+// setRateLimitHeader sets the x-ratelimit-limit-, -remaining- and -reset-
+// headers of one kind of limit, if it applies.
+func setRateLimitHeader(c *gin.Context, kind string, w *ratelimit.Window) {
+	if w == nil {
+		return
+	}
+	c.Header("x-ratelimit-limit-"+kind, formatLimit(w.Limit))
+	c.Header("x-ratelimit-remaining-"+kind, formatLimit(w.Remaining))
+	c.Header("x-ratelimit-reset-"+kind, ceilSeconds(w.Reset).String())
+}
+
+// formatLimit formats a limit value, rounding dollar amounts to millionths.
+func formatLimit(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', -1, 64)
+}
+
+// ceilSeconds rounds a duration up to whole seconds, at least one second.
+func ceilSeconds(d time.Duration) time.Duration {
+	return time.Duration(math.Max(1, math.Ceil(d.Seconds()))) * time.Second
+}
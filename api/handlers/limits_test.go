@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/auth"
+	"ai-proxy/config"
+	"ai-proxy/ratelimit"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withRateLimits gives the test a fresh ratelimit.Default registry.
+func withRateLimits(t *testing.T) {
+	t.Helper()
+	old := ratelimit.Default
+	ratelimit.Default = ratelimit.NewRegistry()
+	t.Cleanup(func() { ratelimit.Default = old })
+}
+
+// newLimitedRouter returns a router serving "gpt-4" with the given model limits and pricing.
+func newLimitedRouter(t *testing.T, limits *config.LimitsConfig, pricing map[string]config.ModelPrice) router.Router {
+	t.Helper()
+	r, err := router.NewRouter(&config.Schema{
+		Providers: []config.Provider{
+			{Name: "primary", Endpoints: map[string]string{"openai": "https://primary.example.com/v1/chat/completions"}, APIKey: "primary-key"},
+		},
+		Models:  map[string]config.ModelConfig{"gpt-4": {Provider: "primary", Model: "gpt-4", Limits: limits}},
+		Pricing: pricing,
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() error: %v", err)
+	}
+	return r
+}
+
+// usageStream answers every upstream request with a stream reporting 12 input and 5 output tokens.
+func usageStream(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStreamWithUsage)),
+			Header:     make(http.Header),
+		}, nil
+	})
+}
+
+// serveAs runs a request through a handler, authenticated as id if non-nil.
+func serveAs(id *auth.Identity, handler gin.HandlerFunc, path, body string) *mockResponseWriter {
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if id != nil {
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), id))
+	}
+	handler(c)
+	return w
+}
+
+const (
+	limitedChatBody      = `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	limitedMessagesBody  = `{"model":"gpt-4","max_tokens":10,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	limitedResponsesBody = `{"model":"gpt-4","stream":true,"input":"hi"}`
+)
+
+func TestHandle_RequestRateLimitPerProtocol(t *testing.T) {
+	usageStream(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		handler func(*config.Config, router.Router) gin.HandlerFunc
+		want    string
+	}{
+		{"openai", "/v1/chat/completions", limitedChatBody, NewCompletionsHandler,
+			`"code":"rate_limit_exceeded"`},
+		{"anthropic", "/v1/messages", limitedMessagesBody, NewMessagesHandler,
+			`"type":"rate_limit_error"`},
+		{"responses", "/v1/responses", limitedResponsesBody, NewResponsesHandler,
+			`data: {"error":{"code":"rate_limit_exceeded"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRateLimits(t)
+			handler := tt.handler(&config.Config{}, newLimitedRouter(t, &config.LimitsConfig{RequestsPerMinute: 1}, nil))
+
+			w := serveAs(nil, handler, tt.path, tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("first request: expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if w.Header().Get("x-ratelimit-limit-requests") != "1" || w.Header().Get("x-ratelimit-remaining-requests") != "0" {
+				t.Errorf("unexpected rate-limit headers: %v", w.Header())
+			}
+
+			w = serveAs(nil, handler, tt.path, tt.body)
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("second request: expected status 429, got %d", w.Code)
+			}
+			if w.Header().Get("Retry-After") != "60" {
+				t.Errorf("Retry-After = %q, want 60", w.Header().Get("Retry-After"))
+			}
+			if !strings.Contains(w.Body.String(), tt.want) || !strings.Contains(w.Body.String(), "request rate limit exceeded for model 'gpt-4'") {
+				t.Errorf("unexpected error body: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandle_TokenQuotaChargedFromUsage(t *testing.T) {
+	withRateLimits(t)
+	usageStream(t)
+	handler := NewCompletionsHandler(&config.Config{}, newLimitedRouter(t, nil, nil))
+	alice := &auth.Identity{Name: "alice-laptop", User: "alice", Limits: &config.LimitsConfig{TokensPerHour: 17}}
+
+	w := serveAs(alice, handler, "/v1/chat/completions", limitedChatBody)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("x-ratelimit-remaining-tokens") != "17" {
+		t.Errorf("remaining tokens before charging = %q, want 17", w.Header().Get("x-ratelimit-remaining-tokens"))
+	}
+
+	// 12 input + 5 output tokens used up the quota
+	w = serveAs(alice, handler, "/v1/chat/completions", limitedChatBody)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected status 429, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "hourly token quota exceeded for client key 'alice-laptop'") {
+		t.Errorf("unexpected error body: %s", w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "3600" {
+		t.Errorf("Retry-After = %q, want 3600", w.Header().Get("Retry-After"))
+	}
+
+	// Other clients have their own quota
+	bob := &auth.Identity{User: "bob", Limits: &config.LimitsConfig{TokensPerHour: 17}}
+	if w := serveAs(bob, handler, "/v1/chat/completions", limitedChatBody); w.Code != http.StatusOK {
+		t.Errorf("another client: expected status 200, got %d", w.Code)
+	}
+}
+
+func TestHandle_ModelSpendQuotaPerClient(t *testing.T) {
+	withRateLimits(t)
+	usageStream(t)
+	// 12 input tokens at $1/token cost $12 per request
+	handler := NewCompletionsHandler(&config.Config{}, newLimitedRouter(t,
+		&config.LimitsConfig{SpendPerDay: 10},
+		map[string]config.ModelPrice{"gpt-4": {Input: 1e6}}))
+	alice := &auth.Identity{User: "alice"}
+
+	if w := serveAs(alice, handler, "/v1/chat/completions", limitedChatBody); w.Code != http.StatusOK {
+		t.Fatalf("first request: expected status 200, got %d", w.Code)
+	}
+	w := serveAs(alice, handler, "/v1/chat/completions", limitedChatBody)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected status 429, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "daily spend quota exceeded for model 'gpt-4' for user 'alice'") {
+		t.Errorf("unexpected error body: %s", w.Body.String())
+	}
+	if w.Header().Get("x-ratelimit-reset-spend") != "24h0m0s" {
+		t.Errorf("x-ratelimit-reset-spend = %q, want 24h0m0s", w.Header().Get("x-ratelimit-reset-spend"))
+	}
+
+	if w := serveAs(&auth.Identity{User: "bob"}, handler, "/v1/chat/completions", limitedChatBody); w.Code != http.StatusOK {
+		t.Errorf("another client: expected status 200, got %d", w.Code)
+	}
+}
+
+func TestHandle_UnlimitedRequestsHaveNoRateLimitHeaders(t *testing.T) {
+	withRateLimits(t)
+	usageStream(t)
+	w := serveAs(&auth.Identity{User: "alice"}, NewCompletionsHandler(&config.Config{}, newLimitedRouter(t, nil, nil)),
+		"/v1/chat/completions", limitedChatBody)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	for key := range w.Header() {
+		if strings.HasPrefix(strings.ToLower(key), "x-ratelimit-") {
+			t.Errorf("unexpected header %s", key)
+		}
+	}
+}
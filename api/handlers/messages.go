@@ -8,11 +8,15 @@ import (
 	"net/http"
 	"strings"
 
+	"ai-proxy/balance"
+	"ai-proxy/circuit"
 	"ai-proxy/config"
 	"ai-proxy/convert"
 	"ai-proxy/logging"
+	"ai-proxy/proxy"
 	"ai-proxy/router"
 	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
 	"ai-proxy/transform/toolcall"
 	wstransform "ai-proxy/transform/websearch"
 	"ai-proxy/types"
@@ -39,6 +43,9 @@ type MessagesHandler struct {
 	cfg *config.Config
 	// router resolves model names to providers. May be nil for legacy behavior.
 	modelRouter router.Router
+	// plan is the resolved route plan for the current request.
+	// Set during ValidateRequest; nil if the model was not resolved.
+	plan *router.RoutePlan
 	// route is the resolved route for the current request.
 	// Set during ValidateRequest for use in subsequent methods.
 	route *router.ResolvedRoute
@@ -87,14 +94,15 @@ func (h *MessagesHandler) ValidateRequest(body []byte) error {
 		return nil // Let upstream handle missing model
 	}
 
-	// Resolve the model to a route with incoming protocol context
+	// Resolve the model to a route plan with incoming protocol context
 	// The messages endpoint receives requests in Anthropic format
-	route, err := h.modelRouter.ResolveWithProtocol(req.Model, "anthropic")
+	plan, err := h.modelRouter.ResolvePlan(req.Model, "anthropic")
 	if err != nil {
 		return nil // Use fallback behavior
 	}
 
-	h.route = route
+	h.plan = plan
+	h.route = plan.Primary()
 	h.originalModel = req.Model
 	return nil
 }
@@ -102,6 +110,9 @@ func (h *MessagesHandler) ValidateRequest(body []byte) error {
 // TransformRequest converts the request body based on the upstream provider type.
 // For Anthropic providers: passes through without transformation.
 // For OpenAI providers: converts Anthropic Messages to OpenAI Chat Completions.
+// For Responses providers: converts Anthropic Messages to a streaming Responses request.
+// For Gemini providers: converts Anthropic Messages to a Gemini generateContent request.
+// For Ollama providers: converts Anthropic Messages to an Ollama chat request.
 //
 // @param ctx - Context for the request (unused in this handler).
 // @param body - Raw request body in Anthropic Messages format.
@@ -153,7 +164,30 @@ func (h *MessagesHandler) TransformRequest(ctx context.Context, body []byte) ([]
 		return convert.NormalizeWebSearchToolResultsInMessages(updatedBody), nil
 	case "responses":
 		// Convert Anthropic Messages to Responses API format
-		return convert.TransformAnthropicToResponses(updatedBody)
+		transformed, err := convert.TransformAnthropicToResponses(updatedBody)
+		if err != nil {
+			return nil, err
+		}
+		// The proxy always streams from upstream, even if the client omitted stream
+		return enableStreaming(transformed)
+	case "gemini":
+		// Convert Anthropic Messages to Gemini format via Chat Completions
+		transformed, err := transformAnthropicToChat(updatedBody)
+		if err != nil {
+			return nil, err
+		}
+		return convert.TransformChatToGemini(transformed)
+	case "ollama":
+		// Convert Anthropic Messages to Ollama format via Chat Completions
+		transformed, err := transformAnthropicToChat(updatedBody)
+		if err != nil {
+			return nil, err
+		}
+		ollamaBody, err := convert.TransformChatToOllama(transformed)
+		if err != nil {
+			return nil, err
+		}
+		return applyAnthropicThinkingToOllama(ollamaBody, updatedBody)
 	default:
 		// Unknown protocol - pass through as-is
 		return updatedBody, nil
@@ -165,7 +199,7 @@ func (h *MessagesHandler) TransformRequest(ctx context.Context, body []byte) ([]
 // @return URL string for the upstream API endpoint.
 func (h *MessagesHandler) UpstreamURL() string {
 	if h.route != nil {
-		return h.route.Provider.GetEndpoint(h.route.OutputProtocol)
+		return h.route.Provider.GetModelEndpoint(h.route.OutputProtocol, h.route.Model)
 	}
 	return ""
 }
@@ -176,7 +210,7 @@ func (h *MessagesHandler) UpstreamURL() string {
 // @return API key string from the provider configuration.
 func (h *MessagesHandler) ResolveAPIKey(c *gin.Context) string {
 	if h.route != nil {
-		return h.route.Provider.GetAPIKey()
+		return h.route.GetAPIKey()
 	}
 	return ""
 }
@@ -184,6 +218,8 @@ func (h *MessagesHandler) ResolveAPIKey(c *gin.Context) string {
 // ForwardHeaders copies headers to the upstream request based on provider type.
 // For OpenAI providers: forwards X-* headers only.
 // For Anthropic providers: forwards X-*, Anthropic-Version, and Anthropic-Beta headers.
+// For other providers: forwards X-* headers.
+// The API key and provider headers are set afterwards by the upstream client.
 //
 // @param c - Gin context containing the original request headers.
 // @param req - Upstream request to receive forwarded headers.
@@ -213,6 +249,9 @@ func (h *MessagesHandler) ForwardHeaders(c *gin.Context, req *http.Request) {
 // CreateTransformer builds an SSE transformer for converting upstream responses.
 // For OpenAI providers: converts Chat Completions to Anthropic format.
 // For Anthropic providers: passes through SSE events.
+// For Responses providers: converts Responses API events to Anthropic format.
+// For Gemini providers: converts Gemini chunks to Anthropic format via Chat Completions.
+// For Ollama providers: converts Ollama lines to Anthropic format via Chat Completions.
 // If web search service is enabled, wraps the transformer to intercept web_search tool calls.
 //
 // @param w - Writer to receive transformed output.
@@ -233,13 +272,22 @@ func (h *MessagesHandler) CreateTransformer(w io.Writer) transform.SSETransforme
 	switch h.route.OutputProtocol {
 	case "openai":
 		// OpenAI to Anthropic transformer
-		transformer := toolcall.NewAnthropicTransformer(w)
-		transformer.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
-		transformer.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
-		baseTransformer = transformer
+		baseTransformer = h.newAnthropicTransformer(w)
 	case "anthropic":
 		// Passthrough for native Anthropic
 		baseTransformer = transform.NewPassthroughTransformer(w)
+	case "responses":
+		// Responses to Anthropic transformer
+		transformer := convert.NewResponsesToAnthropicStreamingTransformer(w)
+		transformer.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
+		transformer.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
+		baseTransformer = transformer
+	case "gemini":
+		// Gemini to Chat Completions, then Chat Completions to Anthropic
+		baseTransformer = convert.NewGeminiToChatTransformer(h.newChatToAnthropicTransformer(w))
+	case "ollama":
+		// Ollama to Chat Completions, then Chat Completions to Anthropic
+		baseTransformer = convert.NewOllamaToChatTransformer(h.newChatToAnthropicTransformer(w))
 	default:
 		return transform.NewPassthroughTransformer(w)
 	}
@@ -248,6 +296,31 @@ func (h *MessagesHandler) CreateTransformer(w io.Writer) transform.SSETransforme
 	return h.wrapWithWebSearch(baseTransformer)
 }
 
+// newChatToAnthropicTransformer builds the transformer converting Chat
+// Completions chunks to Anthropic events, for the chunks Gemini and Ollama
+// streams are converted to: the Anthropic events are passed through the same
+// tool call markup extraction as for OpenAI providers.
+//
+// @param w - Writer to receive transformed output.
+// @return Transformer for Chat Completions chunks.
+func (h *MessagesHandler) newChatToAnthropicTransformer(w io.Writer) transform.SSETransformer {
+	return transform.NewChainTransformer(func(w io.Writer) transform.SSETransformer {
+		return convert.NewChatToAnthropicTransformer(w)
+	}, h.newAnthropicTransformer(w))
+}
+
+// newAnthropicTransformer builds the transformer for Anthropic events, which
+// extracts the route's Kimi/GLM-5 tool call markup.
+//
+// @param w - Writer to receive transformed output.
+// @return Transformer for Anthropic events.
+func (h *MessagesHandler) newAnthropicTransformer(w io.Writer) *toolcall.AnthropicTransformer {
+	t := toolcall.NewAnthropicTransformer(w)
+	t.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
+	t.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
+	return t
+}
+
 // wrapWithWebSearch wraps the base transformer with web search interception if enabled.
 //
 // @param base - The base transformer to wrap.
@@ -273,6 +346,40 @@ func (h *MessagesHandler) WriteError(c *gin.Context, status int, msg string) {
 	sendAnthropicError(c, status, msg)
 }
 
+// NewAggregator switches the handler into non-streaming mode.
+// The assembled response is a single Anthropic message object.
+//
+// @return Aggregator for Anthropic Messages streaming events.
+func (h *MessagesHandler) NewAggregator() aggregate.Aggregator {
+	return aggregate.NewAnthropicAggregator()
+}
+
+// applyAnthropicThinkingToOllama sets the Ollama think field from the Anthropic
+// thinking config, which Chat Completions has no field to carry.
+//
+// @param ollamaBody - Ollama chat request converted from anthropicBody.
+// @param anthropicBody - The original Anthropic Messages request.
+// @return ollamaBody with think set, or unchanged when thinking is not configured.
+func applyAnthropicThinkingToOllama(ollamaBody, anthropicBody []byte) ([]byte, error) {
+	var anthReq types.MessageRequest
+	if err := json.Unmarshal(anthropicBody, &anthReq); err != nil {
+		return nil, err
+	}
+	if anthReq.Thinking == nil {
+		return ollamaBody, nil
+	}
+
+	var req types.OllamaChatRequest
+	if err := json.Unmarshal(ollamaBody, &req); err != nil {
+		return nil, err
+	}
+	req.Think = json.RawMessage("false")
+	if anthReq.Thinking.Type == "enabled" {
+		req.Think = json.RawMessage("true")
+	}
+	return json.Marshal(&req)
+}
+
 // transformAnthropicToChat converts an Anthropic MessageRequest to OpenAI ChatCompletionRequest.
 // This reuses the transformation logic from the bridge handler.
 func transformAnthropicToChat(body []byte) ([]byte, error) {
@@ -452,6 +559,100 @@ func convertAnthropicTools(anthTools []types.ToolDef) []types.Tool {
 	return openTools
 }
 
+// RetryPolicy returns the retry policy of the resolved provider.
+//
+// @return Policy from the provider's retry configuration, single attempt if none.
+func (h *MessagesHandler) RetryPolicy() proxy.RetryPolicy {
+	return routeRetryPolicy(h.route)
+}
+
+// HasNextRoute reports whether the route plan has a fallback after the current route.
+func (h *MessagesHandler) HasNextRoute() bool {
+	return h.plan.Next(h.route) != nil
+}
+
+// NextRoute switches to the next fallback route of the route plan.
+//
+// @return false if the current route is the last one.
+func (h *MessagesHandler) NextRoute() bool {
+	next := h.plan.Next(h.route)
+	if next == nil {
+		return false
+	}
+	h.route = next
+	return true
+}
+
+// UpstreamKey returns the load-balanced API key of the current route.
+func (h *MessagesHandler) UpstreamKey() *balance.Key {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Key
+}
+
+// UpstreamBreaker returns the circuit breaker of the current route's provider.
+func (h *MessagesHandler) UpstreamBreaker() *circuit.Breaker {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Breaker
+}
+
+// FetchCredential fetches the current route's key from its provider's
+// credential source, if it has one.
+func (h *MessagesHandler) FetchCredential(ctx context.Context) error {
+	if h.route == nil {
+		return nil
+	}
+	_, err := h.route.FetchAPIKey(ctx)
+	return err
+}
+
+// InvalidateCredential drops the current route's fetched key from its
+// provider's credential source.
+func (h *MessagesHandler) InvalidateCredential() bool {
+	return h.route != nil && h.route.InvalidateCredential()
+}
+
+// MetricLabels returns the inbound protocol and the current route's provider
+// and upstream protocol.
+func (h *MessagesHandler) MetricLabels() (inbound, provider, upstreamProtocol string) {
+	if h.route == nil {
+		return "anthropic", "", ""
+	}
+	return "anthropic", h.route.Provider.Name, h.route.OutputProtocol
+}
+
+// UpstreamDialect returns the dialect of the current route's provider.
+func (h *MessagesHandler) UpstreamDialect() string {
+	if h.route == nil {
+		return ""
+	}
+	return h.route.Provider.Dialect
+}
+
+// UpstreamClientOptions returns the auth and header options of the current route's provider.
+func (h *MessagesHandler) UpstreamClientOptions() []proxy.ClientOption {
+	return routeClientOptions(h.route)
+}
+
+// ModelLimits returns the per-client limits of the resolved model alias.
+func (h *MessagesHandler) ModelLimits() *config.LimitsConfig {
+	if h.plan == nil {
+		return nil
+	}
+	return h.plan.Limits
+}
+
+// RoutePrice returns the price of the current route's upstream model.
+func (h *MessagesHandler) RoutePrice() *config.ModelPrice {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Price
+}
+
 // ModelInfo returns the downstream and upstream model names for logging.
 func (h *MessagesHandler) ModelInfo() (downstreamModel string, upstreamModel string) {
 	downstreamModel = h.originalModel
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ai-proxy/auth"
+	"ai-proxy/capture"
+	"ai-proxy/events"
+	"ai-proxy/logging"
+	"ai-proxy/metrics"
+	"ai-proxy/stream"
+	"ai-proxy/transform"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// trafficLabels partition every proxy traffic metric.
+var trafficLabels = []string{"inbound_protocol", "model", "provider", "upstream_protocol", "status"}
+
+// Proxy traffic metrics, collected for every request whether or not capture is enabled.
+var (
+	requestsTotal = metrics.NewCounterVec("ai_proxy_requests_total",
+		"Proxied requests by route and final HTTP status.", trafficLabels...)
+	upstreamFirstByteSeconds = metrics.NewHistogramVec("ai_proxy_upstream_first_byte_seconds",
+		"Time from request start until an upstream answered with response headers, including retries and fallbacks.",
+		nil, trafficLabels...)
+	downstreamFirstTokenSeconds = metrics.NewHistogramVec("ai_proxy_downstream_first_token_seconds",
+		"Time from request start until the first transformed upstream event was written to a streaming client.",
+		nil, trafficLabels...)
+	streamDurationSeconds = metrics.NewHistogramVec("ai_proxy_stream_duration_seconds",
+		"Time from the upstream response until its stream was fully consumed.",
+		nil, trafficLabels...)
+	tokensTotal = metrics.NewCounterVec("ai_proxy_tokens_total",
+		"Tokens reported in upstream usage, by type: input, output, cache_read or cache_creation.",
+		append(append([]string(nil), trafficLabels...), "type")...)
+)
+
+// requestMetricsKey is the gin context key of the request's *requestMetrics.
+const requestMetricsKey = "request_metrics"
+
+// requestMetrics tracks the timings and token usage of one proxied request
+// until it is recorded by finish, and publishes its lifecycle events.
+//
+// All methods are safe to call on a nil *requestMetrics, which records nothing.
+//
+// Thread Safety: Not safe for concurrent use; owned by the request goroutine.
+type requestMetrics struct {
+	// id identifies the request in /admin/streams and its lifecycle events.
+	id string
+	// clientCtx is the context of the client's request, done once the client
+	// disconnects, unlike the contexts Handle derives from it.
+	clientCtx context.Context
+
+	start      time.Time
+	upstreamAt time.Time
+	firstToken time.Time
+	streamEnd  time.Time
+	// written is the client response size when streaming started, so output
+	// of transformer initialization is not mistaken for the first token.
+	written int
+	usage   capture.TokenUsage
+
+	// active is the request's entry in the stream registry, which its progress
+	// is published to; nil for requests not registered by Handle.
+	active *stream.ActiveStream
+	// transformer is the stream's transformer once registerStream attached it.
+	transformer transform.SSETransformer
+
+	// events publishes the request's lifecycle events once it was received.
+	events *events.Request
+	// cost is the price of the upstream usage, set by settleRequest.
+	cost float64
+	// served is set once an upstream accepted the request with 200 OK.
+	served bool
+}
+
+// startRequestMetrics starts tracking a request and attaches the tracker to c.
+func startRequestMetrics(c *gin.Context) *requestMetrics {
+	m := &requestMetrics{
+		id:        "req_" + uuid.New().String(),
+		clientCtx: c.Request.Context(),
+		start:     time.Now(),
+	}
+	c.Set(requestMetricsKey, m)
+	return m
+}
+
+// requestMetricsFrom returns the tracker attached by startRequestMetrics, or nil.
+func requestMetricsFrom(c *gin.Context) *requestMetrics {
+	if v, ok := c.Get(requestMetricsKey); ok {
+		m, _ := v.(*requestMetrics)
+		return m
+	}
+	return nil
+}
+
+// received starts publishing the request's lifecycle events with
+// RequestReceived, and RouteResolved if its model resolved to a route.
+// Transformers publish to them through the request context.
+//
+// @param c - Gin context of a validated request; its request context is replaced.
+// @param h - Handler processing the request.
+func (m *requestMetrics) received(c *gin.Context, h Handler) {
+	if m == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	identity := events.Event{RequestID: m.id, Client: clientKeyName(auth.FromContext(ctx))}
+	if cc := capture.GetCaptureContext(ctx); cc != nil && cc.SessionProvided {
+		identity.Session = cc.SessionID
+	}
+	identity.Model, identity.UpstreamModel = h.ModelInfo()
+	identity.Protocol, identity.Provider, _ = handlerLabels(h)
+
+	m.events = events.NewRequest(events.Default, identity)
+	c.Request = c.Request.WithContext(events.WithRequest(ctx, m.events))
+	m.events.Publish(events.Event{Type: events.RequestReceived})
+	if identity.Provider != "" {
+		m.events.Publish(events.Event{Type: events.RouteResolved})
+	}
+}
+
+// routeResolved publishes RouteResolved after a fallback to the handler's next
+// route, whose provider and model later events carry.
+func (m *requestMetrics) routeResolved(h Handler) {
+	if m == nil {
+		return
+	}
+	_, upstreamModel := h.ModelInfo()
+	_, provider, _ := handlerLabels(h)
+	m.events.SetRoute(provider, upstreamModel)
+	m.events.Publish(events.Event{Type: events.RouteResolved})
+}
+
+// upstreamAttempted publishes the outcome of an upstream attempt: UpstreamConnected
+// for a streaming response, UpstreamError otherwise. Attempts the client or an
+// operator cancelled are not upstream errors and publish nothing.
+//
+// @param resp - The upstream response, nil on transport error.
+// @param err - The transport error, nil if a response was received.
+func (m *requestMetrics) upstreamAttempted(resp *http.Response, err error) {
+	if m == nil {
+		return
+	}
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+	case err != nil:
+		m.events.Publish(events.Event{Type: events.UpstreamError, Error: err.Error()})
+	case resp.StatusCode != http.StatusOK:
+		m.events.Publish(events.Event{Type: events.UpstreamError, Status: resp.StatusCode})
+	default:
+		m.served = true
+		m.events.Publish(events.Event{Type: events.UpstreamConnected})
+	}
+}
+
+// upstreamServed reports whether an upstream accepted the request. Requests
+// that every upstream attempt failed for are not charged to the client.
+func (m *requestMetrics) upstreamServed() bool {
+	return m != nil && m.served
+}
+
+// streamFailed publishes UpstreamError for an upstream stream that broke off.
+func (m *requestMetrics) streamFailed(err error) {
+	if m == nil {
+		return
+	}
+	m.events.Publish(events.Event{Type: events.UpstreamError, Error: err.Error()})
+}
+
+// upstreamResponded marks the arrival of the upstream response that will be streamed.
+//
+// @param h - Handler whose current route responded, after any fallbacks.
+func (m *requestMetrics) upstreamResponded(h Handler) {
+	if m == nil {
+		return
+	}
+	m.upstreamAt = time.Now()
+	if m.active != nil {
+		_, upstreamModel := h.ModelInfo()
+		_, provider, _ := handlerLabels(h)
+		m.active.Update(func(info *stream.Info) {
+			info.Provider = provider
+			info.UpstreamModel = upstreamModel
+		})
+	}
+}
+
+// streamStarted records the client response size before the first upstream event.
+//
+// @param written - current size of the client response, as from gin's ResponseWriter.Size().
+func (m *requestMetrics) streamStarted(written int) {
+	if m == nil {
+		return
+	}
+	m.written = written
+}
+
+// upstreamEvent updates token usage from an upstream SSE event payload.
+func (m *requestMetrics) upstreamEvent(data string) {
+	if m == nil || data == "" {
+		return
+	}
+	capture.UpdateTokenUsage([]byte(data), &m.usage)
+}
+
+// tokenUsage returns the token usage reported by the upstream so far.
+func (m *requestMetrics) tokenUsage() capture.TokenUsage {
+	if m == nil {
+		return capture.TokenUsage{}
+	}
+	return m.usage
+}
+
+// downstreamWritten marks the first token once the client response has grown
+// past its size at streamStarted.
+//
+// @param written - current size of the client response.
+func (m *requestMetrics) downstreamWritten(written int) {
+	if m == nil {
+		return
+	}
+	m.progressed(written)
+	if !m.firstToken.IsZero() || written <= m.written {
+		return
+	}
+	m.firstToken = time.Now()
+	m.events.Publish(events.Event{Type: events.FirstToken})
+}
+
+// progressed publishes the bytes written, the token usage so far and the
+// transformer state to the request's stream registry entry, after an upstream
+// event was transformed.
+//
+// @param written - current size of the client response, or of the output fed
+// into the aggregator of a "stream": false request; negative before anything was written.
+func (m *requestMetrics) progressed(written int) {
+	if m == nil || m.active == nil {
+		return
+	}
+	var state string
+	if reporter, ok := m.transformer.(transform.StateReporter); ok {
+		state = reporter.State()
+	}
+	m.active.Update(func(info *stream.Info) {
+		info.BytesWritten = max(written, 0)
+		info.InputTokens = m.usage.InputTokens
+		info.OutputTokens = m.usage.OutputTokens
+		info.TransformerState = state
+	})
+}
+
+// streamEnded marks the end of the upstream stream. Only the first call counts.
+func (m *requestMetrics) streamEnded() {
+	if m == nil || m.upstreamAt.IsZero() || !m.streamEnd.IsZero() {
+		return
+	}
+	m.streamEnd = time.Now()
+}
+
+// finish records the request on the proxy traffic metrics and publishes
+// RequestCompleted, preceded by ClientDisconnected if the client went away.
+// Labels reflect the route that finally served the request and the status
+// written to the client.
+//
+// @param c - Gin context whose response has been written.
+// @param h - Handler that processed the request.
+func (m *requestMetrics) finish(c *gin.Context, h Handler) {
+	if m == nil {
+		return
+	}
+	if m.clientCtx.Err() != nil {
+		m.events.Publish(events.Event{Type: events.ClientDisconnected})
+	}
+	m.events.Publish(events.Event{
+		Type:         events.RequestCompleted,
+		Status:       c.Writer.Status(),
+		InputTokens:  m.usage.InputTokens,
+		OutputTokens: m.usage.OutputTokens,
+		Cost:         m.cost,
+	})
+
+	labels := requestLabels(h, c.Writer.Status())
+	requestsTotal.Inc(labels...)
+
+	if !m.upstreamAt.IsZero() {
+		upstreamFirstByteSeconds.Observe(m.upstreamAt.Sub(m.start).Seconds(), labels...)
+	}
+	if !m.firstToken.IsZero() {
+		downstreamFirstTokenSeconds.Observe(m.firstToken.Sub(m.start).Seconds(), labels...)
+	}
+	if !m.streamEnd.IsZero() {
+		streamDurationSeconds.Observe(m.streamEnd.Sub(m.upstreamAt).Seconds(), labels...)
+	}
+
+	for _, t := range []struct {
+		kind  string
+		count int
+	}{
+		{"input", m.usage.InputTokens},
+		{"output", m.usage.OutputTokens},
+		{"cache_read", m.usage.CacheReadTokens},
+		{"cache_creation", m.usage.CacheCreationTokens},
+	} {
+		if t.count > 0 {
+			tokensTotal.Add(float64(t.count), append(labels, t.kind)...)
+		}
+	}
+}
+
+// requestLabels returns the trafficLabels values of a finished request.
+// The model label is only set for requests that resolved to a route, so
+// arbitrary client-supplied model names do not create new series.
+func requestLabels(h Handler, status int) []string {
+	var model string
+	inbound, provider, upstreamProtocol := handlerLabels(h)
+	if provider != "" {
+		model, _ = h.ModelInfo()
+	}
+	return []string{inbound, model, provider, upstreamProtocol, strconv.Itoa(status)}
+}
+
+// handlerLabels returns the metric labels of handlers that implement
+// InstrumentedHandler, and empty labels for others.
+func handlerLabels(h Handler) (inbound, provider, upstreamProtocol string) {
+	if ih, ok := h.(InstrumentedHandler); ok {
+		return ih.MetricLabels()
+	}
+	return "", "", ""
+}
+
+// NewMetricsHandler creates a Gin handler for the GET /metrics endpoint,
+// serving the Default metrics registry in the Prometheus text format.
+//
+// @return Gin handler function that writes all proxy metrics.
+func NewMetricsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := metrics.Default.WritePrometheus(c.Writer); err != nil {
+			logging.ErrorMsg("Failed to write metrics: %v", err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatUpstreamStreamWithUsage is a Chat Completions SSE stream ending with a usage chunk.
+const chatUpstreamStreamWithUsage = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"prompt_tokens_details":{"cached_tokens":4}}}
+
+data: [DONE]
+
+`
+
+func TestHandle_RecordsTrafficMetrics(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStreamWithUsage)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	labels := []string{"openai", "gpt-4", "primary", "openai", "200"}
+	requests := requestsTotal.Value(labels...)
+	firstBytes := upstreamFirstByteSeconds.Count(labels...)
+	firstTokens := downstreamFirstTokenSeconds.Count(labels...)
+	streams := streamDurationSeconds.Count(labels...)
+	input := tokensTotal.Value(append(labels, "input")...)
+	output := tokensTotal.Value(append(labels, "output")...)
+	cacheRead := tokensTotal.Value(append(labels, "cache_read")...)
+
+	if w := sendCompletion(newBreakerRouter(t, false)); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	if got := requestsTotal.Value(labels...) - requests; got != 1 {
+		t.Errorf("requests recorded = %v, want 1", got)
+	}
+	if upstreamFirstByteSeconds.Count(labels...)-firstBytes != 1 ||
+		downstreamFirstTokenSeconds.Count(labels...)-firstTokens != 1 ||
+		streamDurationSeconds.Count(labels...)-streams != 1 {
+		t.Error("expected one observation of each latency histogram")
+	}
+	if tokensTotal.Value(append(labels, "input")...)-input != 12 ||
+		tokensTotal.Value(append(labels, "output")...)-output != 5 ||
+		tokensTotal.Value(append(labels, "cache_read")...)-cacheRead != 4 {
+		t.Error("expected the usage chunk's tokens to be counted")
+	}
+}
+
+func TestHandle_RecordsAggregatedRequestMetrics(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(chatUpstreamStreamWithUsage)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	labels := []string{"openai", "gpt-4", "primary", "openai", "200"}
+	firstTokens := downstreamFirstTokenSeconds.Count(labels...)
+	streams := streamDurationSeconds.Count(labels...)
+	output := tokensTotal.Value(append(labels, "output")...)
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"gpt-4","stream":false,"messages":[{"role":"user","content":"hi"}]}`))
+	NewCompletionsHandler(&config.Config{}, newBreakerRouter(t, false))(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	if streamDurationSeconds.Count(labels...)-streams != 1 {
+		t.Error("expected the aggregated stream duration to be observed")
+	}
+	if downstreamFirstTokenSeconds.Count(labels...) != firstTokens {
+		t.Error("aggregated responses have no first downstream token")
+	}
+	if tokensTotal.Value(append(labels, "output")...)-output != 5 {
+		t.Error("expected aggregated output tokens to be counted")
+	}
+}
+
+func TestHandle_RecordsFailedRequestMetrics(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return errorResponse(http.StatusBadRequest), nil
+	})
+
+	failed := []string{"openai", "gpt-4", "primary", "openai", "400"}
+	requests := requestsTotal.Value(failed...)
+	streams := streamDurationSeconds.Count(failed...)
+	sendCompletion(newBreakerRouter(t, false))
+	if requestsTotal.Value(failed...)-requests != 1 {
+		t.Error("expected the upstream error status to be recorded")
+	}
+	if streamDurationSeconds.Count(failed...) != streams {
+		t.Error("failed requests have no stream duration")
+	}
+
+	// Unknown models are not used as label values
+	unresolved := []string{"openai", "", "", "", "400"}
+	requests = requestsTotal.Value(unresolved...)
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"no-such-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	NewCompletionsHandler(&config.Config{}, newBreakerRouter(t, false))(c)
+	if requestsTotal.Value(unresolved...)-requests != 1 {
+		t.Errorf("expected an unlabeled 400 for an unknown model, got status %d", w.Code)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	NewMetricsHandler()(c)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, name := range []string{
+		"# TYPE ai_proxy_requests_total counter",
+		"# TYPE ai_proxy_upstream_first_byte_seconds histogram",
+		"# TYPE ai_proxy_downstream_first_token_seconds histogram",
+		"# TYPE ai_proxy_stream_duration_seconds histogram",
+		"# TYPE ai_proxy_tokens_total counter",
+		"# TYPE ai_proxy_conversation_store_size gauge",
+	} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("expected %q in metrics output", name)
+		}
+	}
+}
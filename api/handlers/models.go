@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"ai-proxy/auth"
 	"ai-proxy/config"
 
 	"github.com/gin-gonic/gin"
@@ -53,8 +54,13 @@ func (h *ModelsHandler) Handle(c *gin.Context) {
 		return
 	}
 
+	// Clients restricted to a list of models only see those models
+	identity := auth.FromContext(c.Request.Context())
 	models := make([]Model, 0, len(schema.Models))
 	for id, mc := range schema.Models {
+		if !identity.AllowsModel(id) {
+			continue
+		}
 		models = append(models, Model{
 			ID:      id,
 			Object:  "model",
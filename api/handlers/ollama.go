@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-proxy/auth"
+	"ai-proxy/config"
+	"ai-proxy/convert"
+	"ai-proxy/router"
+	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
+	"ai-proxy/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ndjsonContentType is the content type of Ollama streaming responses.
+const ndjsonContentType = "application/x-ndjson"
+
+// ollamaModifiedAt is the modification time reported for every model, like
+// the fixed creation time of /v1/models.
+var ollamaModifiedAt = time.Unix(1700000000, 0).UTC().Format(time.RFC3339)
+
+// OllamaHandler handles Ollama /api/chat and /api/generate requests.
+// It implements the Handler interface on top of CompletionsHandler.
+//
+// This handler:
+//   - Accepts requests in Ollama chat or generate format and converts them to
+//     Chat Completions, which CompletionsHandler routes to any upstream protocol
+//   - Streams the Chat Completions output back as Ollama NDJSON lines, with
+//     tool calls (including Kimi/GLM-5 extracted ones) in message.tool_calls
+//   - Serves "stream": false with a single Ollama response object
+//   - Accepts model names with a ":latest" tag for aliases configured without one
+//
+// @note Local tools that only speak the Ollama API can use any configured model.
+type OllamaHandler struct {
+	*CompletionsHandler
+	// generate is true for /api/generate, false for /api/chat.
+	generate bool
+	// model is the model name as the client sent it, reported in every line.
+	model string
+	// alias is the configured model alias model resolves to.
+	alias string
+	// hideThinking is true when the client sent "think": false.
+	hideThinking bool
+	// agg is the aggregator when the client sent "stream": false.
+	agg *convert.ChatToOllamaWriter
+}
+
+// NewOllamaChatHandler creates a Gin handler for the Ollama /api/chat endpoint.
+//
+// @param cfg - Application configuration. Must not be nil.
+// @param r - Router for model resolution. May be nil for legacy behavior.
+// @return Gin handler function that processes Ollama chat requests.
+func NewOllamaChatHandler(cfg *config.Config, r router.Router) gin.HandlerFunc {
+	return newOllamaHandler(cfg, r, false)
+}
+
+// NewOllamaGenerateHandler creates a Gin handler for the Ollama /api/generate endpoint.
+//
+// @param cfg - Application configuration. Must not be nil.
+// @param r - Router for model resolution. May be nil for legacy behavior.
+// @return Gin handler function that processes Ollama generate requests.
+func NewOllamaGenerateHandler(cfg *config.Config, r router.Router) gin.HandlerFunc {
+	return newOllamaHandler(cfg, r, true)
+}
+
+// newOllamaHandler creates a Gin handler for /api/chat or /api/generate.
+func newOllamaHandler(cfg *config.Config, r router.Router, generate bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := &OllamaHandler{
+			CompletionsHandler: &CompletionsHandler{
+				cfg:         cfg,
+				modelRouter: r,
+			},
+			generate: generate,
+		}
+		Handle(h)(c)
+	}
+}
+
+// ValidateRequest validates the Ollama request and resolves its model route.
+//
+// @param body - Raw request body in Ollama format.
+// @return Error if the body is not valid JSON, has no model, or the model is not configured.
+func (h *OllamaHandler) ValidateRequest(body []byte) error {
+	var req struct {
+		Model string          `json:"model"`
+		Think json.RawMessage `json:"think"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	h.model = req.Model
+	h.alias = h.resolveAlias(req.Model)
+	h.hideThinking = convert.OllamaHidesThinking(req.Think)
+	if h.agg != nil {
+		h.agg.SetModel(h.model)
+		h.agg.SetHideThinking(h.hideThinking)
+	}
+
+	chatBody, err := h.toChat(body)
+	if err != nil {
+		return err
+	}
+	if err := h.CompletionsHandler.ValidateRequest(chatBody); err != nil {
+		return err
+	}
+	if h.modelRouter != nil && h.route == nil {
+		return fmt.Errorf("model '%s' not found", req.Model)
+	}
+	return nil
+}
+
+// resolveAlias returns the configured alias for model. Ollama clients often
+// append ":latest", so a tagged name falls back to the untagged alias.
+func (h *OllamaHandler) resolveAlias(model string) string {
+	if h.modelRouter == nil {
+		return model
+	}
+	if _, err := h.modelRouter.Resolve(model); err == nil {
+		return model
+	}
+	if untagged := strings.TrimSuffix(model, ":latest"); untagged != model {
+		if _, err := h.modelRouter.Resolve(untagged); err == nil {
+			return untagged
+		}
+	}
+	return model
+}
+
+// toChat converts the Ollama request body to a Chat Completions request for
+// the resolved alias.
+func (h *OllamaHandler) toChat(body []byte) ([]byte, error) {
+	var chatBody []byte
+	var err error
+	if h.generate {
+		chatBody, err = convert.TransformOllamaGenerateToChat(body)
+	} else {
+		chatBody, err = convert.TransformOllamaChatToChat(body)
+	}
+	if err != nil || h.alias == h.model {
+		return chatBody, err
+	}
+
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(chatBody, &req); err != nil {
+		return nil, err
+	}
+	req["model"], _ = json.Marshal(h.alias)
+	return json.Marshal(req)
+}
+
+// TransformRequest converts the Ollama request to Chat Completions, then to the
+// upstream format of the current route. Called again after a fallback.
+//
+// @param ctx - Context for the request.
+// @param body - Raw request body in Ollama format.
+// @return Transformed body in the upstream format.
+func (h *OllamaHandler) TransformRequest(ctx context.Context, body []byte) ([]byte, error) {
+	chatBody, err := h.toChat(body)
+	if err != nil {
+		return nil, err
+	}
+	return h.CompletionsHandler.TransformRequest(ctx, chatBody)
+}
+
+// CreateTransformer builds the Chat Completions transformer of the current
+// route, writing into an Ollama NDJSON writer. When aggregating, the Chat
+// output goes straight to the Ollama aggregator instead.
+//
+// @param w - Writer to receive transformed output.
+// @return Transformer for processing SSE events.
+func (h *OllamaHandler) CreateTransformer(w io.Writer) transform.SSETransformer {
+	if h.agg != nil {
+		return h.CompletionsHandler.CreateTransformer(w)
+	}
+	out := convert.NewChatToOllamaWriter(w, h.model, h.generate)
+	out.SetHideThinking(h.hideThinking)
+	return &ollamaTransformer{SSETransformer: h.CompletionsHandler.CreateTransformer(out), out: out}
+}
+
+// WriteError sends an error response in Ollama format: {"error": msg}.
+//
+// @param c - Gin context for writing the response.
+// @param status - HTTP status code for the error.
+// @param msg - Human-readable error message.
+func (h *OllamaHandler) WriteError(c *gin.Context, status int, msg string) {
+	sendOllamaError(c, status, msg)
+}
+
+// NewAggregator switches the handler into non-streaming mode.
+// The assembled response is a single Ollama response object.
+//
+// @return Aggregator for Chat Completions streaming chunks.
+func (h *OllamaHandler) NewAggregator() aggregate.Aggregator {
+	h.CompletionsHandler.NewAggregator()
+	h.agg = convert.NewOllamaAggregator("", h.generate)
+	return h.agg
+}
+
+// StreamContentType returns the NDJSON content type of Ollama streams.
+func (h *OllamaHandler) StreamContentType() string {
+	return ndjsonContentType
+}
+
+// MetricLabels returns the inbound protocol and the current route's provider
+// and upstream protocol.
+func (h *OllamaHandler) MetricLabels() (inbound, provider, upstreamProtocol string) {
+	_, provider, upstreamProtocol = h.CompletionsHandler.MetricLabels()
+	return "ollama", provider, upstreamProtocol
+}
+
+// ollamaTransformer is the transformer chain of an Ollama stream. It ends the
+// NDJSON stream when the chain is closed and reports stream errors as
+// {"error": msg} lines.
+type ollamaTransformer struct {
+	transform.SSETransformer
+	out *convert.ChatToOllamaWriter
+}
+
+// Close closes the Chat Completions chain, then sends the done line if the
+// stream ended without one.
+func (t *ollamaTransformer) Close() error {
+	err := t.SSETransformer.Close()
+	if closeErr := t.out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// EmitError ends the stream with an {"error": msg} line.
+func (t *ollamaTransformer) EmitError(streamErr error) error {
+	t.out.Fail(streamErr.Error())
+	return nil
+}
+
+// SetContext sets the request context on the Chat Completions chain.
+func (t *ollamaTransformer) SetContext(ctx context.Context) {
+	setContextOnTransformer(t.SSETransformer, ctx)
+}
+
+// GetResponseID returns the response ID of the Chat Completions chain, if it has one.
+// Implements transform.ResponseIDGetter interface.
+func (t *ollamaTransformer) GetResponseID() string {
+	if getter, ok := t.SSETransformer.(transform.ResponseIDGetter); ok {
+		return getter.GetResponseID()
+	}
+	return ""
+}
+
+// State reports the state of the Chat Completions chain, if it reports one.
+// Implements transform.StateReporter interface.
+func (t *ollamaTransformer) State() string {
+	if reporter, ok := t.SSETransformer.(transform.StateReporter); ok {
+		return reporter.State()
+	}
+	return ""
+}
+
+// sendOllamaError sends an error response in Ollama format: {"error": "..."}.
+//
+// @param c - Gin context for writing the response.
+// @param status - HTTP status code for the error.
+// @param msg - Human-readable error message.
+//
+// @pre c != nil and response has not been written.
+// @post JSON error response is written.
+func sendOllamaError(c *gin.Context, status int, msg string) {
+	c.JSON(status, types.OllamaErrorResponse{Error: msg})
+}
+
+// NewOllamaTagsHandler creates a Gin handler for the Ollama /api/tags endpoint,
+// which lists the configured model aliases the client may use.
+//
+// @param cfg - Application configuration. Must not be nil.
+// @return Gin handler function that lists models in Ollama format.
+func NewOllamaTagsHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schema := cfg.GetSchema()
+		if schema == nil {
+			sendOllamaError(c, http.StatusInternalServerError, "Configuration not loaded")
+			return
+		}
+
+		identity := auth.FromContext(c.Request.Context())
+		names := make([]string, 0, len(schema.Models))
+		for name := range schema.Models {
+			if identity.AllowsModel(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		models := make([]types.OllamaModel, 0, len(names))
+		for _, name := range names {
+			mc := schema.Models[name]
+			models = append(models, types.OllamaModel{
+				Name:       name,
+				Model:      name,
+				ModifiedAt: ollamaModifiedAt,
+				Digest:     ollamaDigest(name, mc),
+				Details:    ollamaModelDetails(mc),
+			})
+		}
+		c.JSON(http.StatusOK, types.OllamaTagsResponse{Models: models})
+	}
+}
+
+// NewOllamaShowHandler creates a Gin handler for the Ollama /api/show endpoint,
+// which describes one configured model alias.
+//
+// @param cfg - Application configuration. Must not be nil.
+// @return Gin handler function that describes a model in Ollama format.
+func NewOllamaShowHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schema := cfg.GetSchema()
+		if schema == nil {
+			sendOllamaError(c, http.StatusInternalServerError, "Configuration not loaded")
+			return
+		}
+
+		var req types.OllamaShowRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			sendOllamaError(c, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		requested := req.Model
+		if requested == "" {
+			requested = req.Name
+		}
+
+		// Models the client may not use are reported as missing
+		identity := auth.FromContext(c.Request.Context())
+		name := requested
+		mc, ok := schema.Models[name]
+		if !ok {
+			name = strings.TrimSuffix(name, ":latest")
+			mc, ok = schema.Models[name]
+		}
+		if !ok || !identity.AllowsModel(name) {
+			sendOllamaError(c, http.StatusNotFound, fmt.Sprintf("model '%s' not found", requested))
+			return
+		}
+
+		c.JSON(http.StatusOK, types.OllamaShowResponse{
+			Details: ollamaModelDetails(mc),
+			ModelInfo: map[string]interface{}{
+				"general.architecture": mc.Provider,
+				"general.basename":     mc.Model,
+			},
+			Capabilities: []string{"completion", "tools"},
+			ModifiedAt:   ollamaModifiedAt,
+		})
+	}
+}
+
+// ollamaModelDetails describes a configured model: its provider as family.
+func ollamaModelDetails(mc config.ModelConfig) types.OllamaModelDetails {
+	return types.OllamaModelDetails{
+		Format:   "remote",
+		Family:   mc.Provider,
+		Families: []string{mc.Provider},
+	}
+}
+
+// ollamaDigest derives a stable digest from a model alias and its route, so
+// clients caching models by digest notice when the alias is re-pointed.
+func ollamaDigest(name string, mc config.ModelConfig) string {
+	sum := sha256.Sum256([]byte(name + "\x00" + mc.Provider + "\x00" + mc.Model))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/auth"
+	"ai-proxy/config"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ollamaToolStream is a Chat Completions SSE stream with a streamed tool call.
+const ollamaToolStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"cat\"}"}}]},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`
+
+// newOllamaRouter returns a router resolving "gpt" to an OpenAI route.
+func newOllamaRouter() *mockRouter {
+	r := newMockRouter()
+	r.models["gpt"] = mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1/chat/completions"), "gpt-4o", "openai")
+	return r
+}
+
+// serveOllama runs a request through an Ollama handler against a fake Chat
+// Completions upstream streaming the given body. It returns the recorder and
+// the upstream request body, nil if the upstream was not called.
+func serveOllama(t *testing.T, newHandler func(*config.Config, router.Router) gin.HandlerFunc, path, body, stream string) (*mockResponseWriter, map[string]interface{}) {
+	t.Helper()
+	var upstream map[string]interface{}
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(req.Body).Decode(&upstream); err != nil {
+			t.Fatalf("failed to decode upstream body: %v", err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(stream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	newHandler(&config.Config{}, newOllamaRouter())(c)
+	return w, upstream
+}
+
+// ndjsonLines decodes every line of an NDJSON body.
+func ndjsonLines(t *testing.T, body string) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestOllamaChatHandler_Streaming(t *testing.T) {
+	w, upstream := serveOllama(t, NewOllamaChatHandler, "/api/chat",
+		`{"model":"gpt","messages":[{"role":"user","content":"hi"}],"options":{"num_predict":64}}`, chatUpstreamStreamWithUsage)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+	}
+	if upstream["model"] != "gpt-4o" || upstream["max_tokens"] != float64(64) {
+		t.Errorf("unexpected upstream request: %v", upstream)
+	}
+
+	lines := ndjsonLines(t, w.Body.String())
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d\n%s", len(lines), w.Body.String())
+	}
+	msg, _ := lines[0]["message"].(map[string]interface{})
+	if lines[0]["model"] != "gpt" || msg["content"] != "Hello" || lines[0]["done"] != false {
+		t.Errorf("unexpected content line: %v", lines[0])
+	}
+	done := lines[1]
+	if done["done"] != true || done["done_reason"] != "stop" {
+		t.Errorf("unexpected done line: %v", done)
+	}
+	if done["prompt_eval_count"] != float64(12) || done["eval_count"] != float64(5) {
+		t.Errorf("expected token counts on done line, got %v", done)
+	}
+}
+
+func TestOllamaChatHandler_ToolCalls(t *testing.T) {
+	w, _ := serveOllama(t, NewOllamaChatHandler, "/api/chat",
+		`{"model":"gpt","messages":[{"role":"user","content":"find a cat"}],
+		"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`, ollamaToolStream)
+
+	lines := ndjsonLines(t, w.Body.String())
+	if len(lines) != 2 {
+		t.Fatalf("expected tool call and done lines, got %d\n%s", len(lines), w.Body.String())
+	}
+	msg, _ := lines[0]["message"].(map[string]interface{})
+	calls, _ := msg["tool_calls"].([]interface{})
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 tool call, got %v", lines[0])
+	}
+	fn := calls[0].(map[string]interface{})["function"].(map[string]interface{})
+	args, _ := fn["arguments"].(map[string]interface{})
+	if fn["name"] != "lookup" || args["q"] != "cat" {
+		t.Errorf("unexpected tool call: %v", fn)
+	}
+}
+
+func TestOllamaGenerateHandler_Streaming(t *testing.T) {
+	w, upstream := serveOllama(t, NewOllamaGenerateHandler, "/api/generate",
+		`{"model":"gpt","system":"Be brief.","prompt":"hi"}`, chatUpstreamStream)
+
+	messages, _ := upstream["messages"].([]interface{})
+	if len(messages) != 2 {
+		t.Errorf("expected system and user messages upstream, got %v", upstream["messages"])
+	}
+	lines := ndjsonLines(t, w.Body.String())
+	if len(lines) != 2 || lines[0]["response"] != "Hello" || lines[1]["done"] != true {
+		t.Errorf("unexpected generate stream:\n%s", w.Body.String())
+	}
+}
+
+func TestOllamaChatHandler_NonStreaming(t *testing.T) {
+	w, upstream := serveOllama(t, NewOllamaChatHandler, "/api/chat",
+		`{"model":"gpt","stream":false,"messages":[{"role":"user","content":"hi"}]}`, chatUpstreamStreamWithUsage)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, w.Body.String())
+	}
+	if upstream["stream"] != true {
+		t.Errorf("expected a streaming upstream request, got %v", upstream)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal([]byte(w.Body.String()), &resp); err != nil {
+		t.Fatalf("expected a single JSON object, got %s", w.Body.String())
+	}
+	msg, _ := resp["message"].(map[string]interface{})
+	if resp["model"] != "gpt" || msg["content"] != "Hello" || resp["done"] != true || resp["eval_count"] != float64(5) {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestOllamaChatHandler_LatestTag(t *testing.T) {
+	w, upstream := serveOllama(t, NewOllamaChatHandler, "/api/chat",
+		`{"model":"gpt:latest","messages":[{"role":"user","content":"hi"}]}`, chatUpstreamStream)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, w.Body.String())
+	}
+	if upstream["model"] != "gpt-4o" {
+		t.Errorf("expected gpt:latest to resolve to gpt, got upstream %v", upstream["model"])
+	}
+	if lines := ndjsonLines(t, w.Body.String()); lines[0]["model"] != "gpt:latest" {
+		t.Errorf("expected the requested name in responses, got %v", lines[0]["model"])
+	}
+}
+
+func TestOllamaChatHandler_UnknownModel(t *testing.T) {
+	w, upstream := serveOllama(t, NewOllamaChatHandler, "/api/chat",
+		`{"model":"missing","messages":[{"role":"user","content":"hi"}]}`, chatUpstreamStream)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	if upstream != nil {
+		t.Error("upstream should not be called for an unknown model")
+	}
+	var resp map[string]interface{}
+	_ = json.Unmarshal([]byte(w.Body.String()), &resp)
+	if _, ok := resp["error"].(string); !ok {
+		t.Errorf(`expected {"error": "..."}, got %s`, w.Body.String())
+	}
+}
+
+func TestOllamaHandler_MetricLabels(t *testing.T) {
+	h := &OllamaHandler{CompletionsHandler: &CompletionsHandler{
+		route: mockRoute(mockLegacyProvider("openai", "openai", "https://api.openai.com/v1"), "gpt-4o", "openai"),
+	}}
+	inbound, provider, upstream := h.MetricLabels()
+	if inbound != "ollama" || provider != "openai" || upstream != "openai" {
+		t.Errorf("MetricLabels() = %q, %q, %q", inbound, provider, upstream)
+	}
+}
+
+// ollamaConfig returns a config with two model aliases.
+func ollamaConfig() *config.Config {
+	return &config.Config{
+		AppConfig: &config.Schema{
+			Providers: []config.Provider{
+				{Name: "openai", Endpoints: map[string]string{"openai": "https://api.openai.com/v1/chat/completions"}},
+			},
+			Models: map[string]config.ModelConfig{
+				"gpt":  {Provider: "openai", Model: "gpt-4o"},
+				"mini": {Provider: "openai", Model: "gpt-4o-mini"},
+			},
+		},
+	}
+}
+
+func TestOllamaTagsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/tags", nil)
+	c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), &auth.Identity{Models: []string{"mini"}}))
+	NewOllamaTagsHandler(ollamaConfig())(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Digest  string `json:"digest"`
+			Details struct {
+				Family string `json:"family"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(resp.Models) != 1 || resp.Models[0].Name != "mini" {
+		t.Fatalf("expected only the allowed model, got %+v", resp.Models)
+	}
+	if len(resp.Models[0].Digest) != 64 || resp.Models[0].Details.Family != "openai" {
+		t.Errorf("unexpected model entry: %+v", resp.Models[0])
+	}
+}
+
+func TestOllamaShowHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"model", `{"model":"gpt"}`, http.StatusOK},
+		{"deprecated name with tag", `{"name":"gpt:latest"}`, http.StatusOK},
+		{"unknown model", `{"model":"missing"}`, http.StatusNotFound},
+		{"invalid JSON", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/show", strings.NewReader(tt.body))
+			NewOllamaShowHandler(ollamaConfig())(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d\n%s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			var resp map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if _, ok := resp["error"].(string); !ok {
+					t.Errorf("expected an Ollama error, got %v", resp)
+				}
+				return
+			}
+			info, _ := resp["model_info"].(map[string]interface{})
+			if info["general.basename"] != "gpt-4o" {
+				t.Errorf("unexpected model_info: %v", info)
+			}
+		})
+	}
+}
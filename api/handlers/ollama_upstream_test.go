@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ai-proxy/config"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ollamaUpstreamStream is a minimal Ollama /api/chat NDJSON stream with
+// thinking, text and a tool call.
+const ollamaUpstreamStream = `{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"Pondering"},"done":false}
+{"model":"qwen3","message":{"role":"assistant","content":"Hello"},"done":false}
+{"model":"qwen3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"lookup","arguments":{"q":"cat"}}}]},"done":false}
+{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":6}
+`
+
+// newOllamaUpstreamRouter returns a router resolving "qwen" to an Ollama route.
+func newOllamaUpstreamRouter() *mockRouter {
+	return newSingleRouteRouter("qwen", mockRoute(mockLegacyProvider("gpu", "ollama", "http://gpu:11434/api/chat"), "qwen3", "ollama"))
+}
+
+// serveOllamaUpstream runs a request through a handler against a fake Ollama upstream
+// and returns the response body and the upstream request body.
+func serveOllamaUpstream(t *testing.T, newHandler func(*config.Config, router.Router) gin.HandlerFunc, path, body string) (string, map[string]interface{}) {
+	t.Helper()
+	var upstream map[string]interface{}
+	w := serveUpstream(t, newHandler, newOllamaUpstreamRouter(), path, body, func(req *http.Request) *http.Response {
+		if err := json.NewDecoder(req.Body).Decode(&upstream); err != nil {
+			t.Fatalf("failed to decode upstream body: %v", err)
+		}
+		return upstreamResponse(http.StatusOK, "application/x-ndjson", ollamaUpstreamStream)
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, w.Body.String())
+	}
+	if _, ok := upstream["messages"]; !ok {
+		t.Errorf("expected Ollama chat request upstream, got %v", upstream)
+	}
+	if upstream["model"] != "qwen3" || upstream["stream"] != true {
+		t.Errorf("expected streaming request for qwen3, got %v", upstream)
+	}
+	return w.Body.String(), upstream
+}
+
+func TestCompletionsHandler_OllamaUpstream(t *testing.T) {
+	body, upstream := serveOllamaUpstream(t, NewCompletionsHandler, "/v1/chat/completions",
+		`{"model":"qwen","stream":true,"reasoning_effort":"high","max_tokens":64,"messages":[{"role":"user","content":"hi"}],
+		"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`)
+
+	if upstream["think"] != "high" {
+		t.Errorf("expected think high upstream, got %v", upstream["think"])
+	}
+	if options, _ := upstream["options"].(map[string]interface{}); options["num_predict"] != float64(64) {
+		t.Errorf("expected num_predict 64 upstream, got %v", upstream["options"])
+	}
+	for _, want := range []string{
+		`"reasoning_content":"Pondering"`,
+		`"content":"Hello"`,
+		`"name":"lookup"`,
+		`"finish_reason":"tool_calls"`,
+		`"prompt_tokens":12`,
+		"data: [DONE]",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestMessagesHandler_OllamaUpstream(t *testing.T) {
+	body, upstream := serveOllamaUpstream(t, NewMessagesHandler, "/v1/messages",
+		`{"model":"qwen","max_tokens":100,"stream":true,"thinking":{"type":"enabled","budget_tokens":1024},
+		"messages":[{"role":"user","content":"hi"}],"tools":[{"name":"lookup","input_schema":{"type":"object"}}]}`)
+
+	if upstream["think"] != true {
+		t.Errorf("expected think true upstream, got %v", upstream["think"])
+	}
+
+	for _, want := range []string{
+		"event: message_start",
+		`"thinking":"Pondering"`,
+		`"text":"Hello"`,
+		`"type":"tool_use"`,
+		`"stop_reason":"tool_use"`,
+		"event: message_stop",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestResponsesHandler_OllamaUpstream(t *testing.T) {
+	body, _ := serveOllamaUpstream(t, NewResponsesHandler, "/v1/responses",
+		`{"model":"qwen","stream":true,"input":"hi","tools":[{"type":"function","name":"lookup","parameters":{"type":"object"}}]}`)
+
+	for _, want := range []string{
+		`"type":"response.created"`,
+		`"delta":"Hello"`,
+		`"type":"function_call"`,
+		`"name":"lookup"`,
+		`"type":"response.completed"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestMessagesHandler_OllamaUpstream_KimiToolCalls(t *testing.T) {
+	const stream = `{"model":"kimi-k2","message":{"role":"assistant","content":"","thinking":"Let me check.<|tool_calls_section_begin|><|tool_call_begin|>functions.bash:0<|tool_call_argument_begin|>{\"cmd\":\"ls\"}<|tool_call_end|><|tool_calls_section_end|>"},"done":false}
+{"model":"kimi-k2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":6}
+`
+	route := mockRoute(mockLegacyProvider("gpu", "ollama", "http://gpu:11434/api/chat"), "kimi-k2", "ollama")
+	route.KimiToolCallTransform = true
+	w := serveUpstream(t, NewMessagesHandler, newSingleRouteRouter("kimi", route), "/v1/messages",
+		`{"model":"kimi","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}],"tools":[{"name":"bash","input_schema":{"type":"object"}}]}`,
+		func(*http.Request) *http.Response {
+			return upstreamResponse(http.StatusOK, "application/x-ndjson", stream)
+		})
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d\n%s", w.Code, body)
+	}
+	if strings.Contains(body, "tool_calls_section_begin") {
+		t.Errorf("tool call markup leaked into the response:\n%s", body)
+	}
+	for _, want := range []string{
+		`"thinking":"Let me check."`,
+		`"type":"tool_use"`,
+		`"name":"bash"`,
+		`"stop_reason":"tool_use"`,
+		"event: message_stop",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s\n%s", want, body)
+		}
+	}
+}
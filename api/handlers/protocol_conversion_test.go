@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"ai-proxy/config"
@@ -1360,3 +1362,191 @@ func TestRegression_MultiProtocolProviderType(t *testing.T) {
 			"This indicates the switch statement is using Provider.Type instead of OutputProtocol.")
 	}
 }
+
+// =============================================================================
+// Responses-Only Provider Tests
+// =============================================================================
+
+// responsesOnlyProvider returns a provider that only exposes a Responses endpoint.
+func responsesOnlyProvider() config.Provider {
+	return mockLegacyProvider("openai-native", "responses", "https://api.test.com/v1/responses")
+}
+
+// TestMessagesHandler_ResponsesTarget_TransformRequest tests that Anthropic requests
+// are converted to a streaming Responses request.
+func TestMessagesHandler_ResponsesTarget_TransformRequest(t *testing.T) {
+	handler := &MessagesHandler{
+		cfg:   &config.Config{},
+		route: mockRoute(responsesOnlyProvider(), "gpt-5", "responses"),
+	}
+
+	// Stream omitted by the client - upstream must still stream
+	request := `{"model": "alias", "max_tokens": 100, "messages": [{"role": "user", "content": "hi"}]}`
+
+	transformed, err := handler.TransformRequest(context.TODO(), []byte(request))
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(transformed, &req); err != nil {
+		t.Fatalf("transformed body is not JSON: %v", err)
+	}
+	if req["model"] != "gpt-5" {
+		t.Errorf("model = %v, want gpt-5", req["model"])
+	}
+	if req["stream"] != true {
+		t.Errorf("stream = %v, want true", req["stream"])
+	}
+	if _, ok := req["input"]; !ok {
+		t.Error("expected Responses input field")
+	}
+	if handler.UpstreamURL() != "https://api.test.com/v1/responses" {
+		t.Errorf("UpstreamURL() = %q", handler.UpstreamURL())
+	}
+}
+
+// TestCompletionsHandler_ResponsesTarget_TransformRequest tests that Chat requests
+// are converted to a streaming Responses request.
+func TestCompletionsHandler_ResponsesTarget_TransformRequest(t *testing.T) {
+	handler := &CompletionsHandler{
+		cfg:   &config.Config{},
+		route: mockRoute(responsesOnlyProvider(), "gpt-5", "responses"),
+	}
+
+	request := `{"model": "alias", "messages": [{"role": "user", "content": "hi"}]}`
+
+	transformed, err := handler.TransformRequest(context.TODO(), []byte(request))
+	if err != nil {
+		t.Fatalf("TransformRequest failed: %v", err)
+	}
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(transformed, &req); err != nil {
+		t.Fatalf("transformed body is not JSON: %v", err)
+	}
+	if req["stream"] != true {
+		t.Errorf("stream = %v, want true", req["stream"])
+	}
+	if _, ok := req["messages"]; ok {
+		t.Error("Responses request should not contain Chat messages")
+	}
+}
+
+// responsesUpstreamStream is a minimal Responses stream with reasoning, text and usage.
+const responsesUpstreamStream = `event: response.created
+data: {"type":"response.created","response":{"id":"resp_1","object":"response","status":"in_progress","model":"gpt-5","output":[]}}
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1","summary":[]}}
+
+event: response.reasoning_summary_text.delta
+data: {"type":"response.reasoning_summary_text.delta","output_index":0,"item_id":"rs_1","delta":"thinking"}
+
+event: response.output_item.done
+data: {"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"thinking"}]}}
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","output_index":1,"item":{"type":"message","id":"msg_1","role":"assistant","content":[]}}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","output_index":1,"content_index":0,"item_id":"msg_1","delta":"Hello"}
+
+event: response.output_item.done
+data: {"type":"response.output_item.done","output_index":1,"item":{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"Hello"}]}}
+
+event: response.completed
+data: {"type":"response.completed","response":{"id":"resp_1","object":"response","status":"completed","model":"gpt-5","output":[{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"thinking"}]},{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"Hello"}]}],"usage":{"input_tokens":5,"output_tokens":7,"total_tokens":12}}}
+
+`
+
+// newResponsesOnlyRouter returns a router resolving "alias" to a Responses-only provider.
+func newResponsesOnlyRouter() *mockRouter {
+	r := newMockRouter()
+	r.models["alias"] = mockRoute(responsesOnlyProvider(), "gpt-5", "responses")
+	return r
+}
+
+// TestMessagesHandler_ResponsesTarget_EndToEnd tests a /v1/messages request
+// served by a Responses-only provider.
+func TestMessagesHandler_ResponsesTarget_EndToEnd(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(responsesUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"alias","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+
+	NewMessagesHandler(&config.Config{}, newResponsesOnlyRouter())(c)
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"event: message_start",
+		`"thinking":"thinking","type":"thinking_delta"`,
+		`"text":"Hello","type":"text_delta"`,
+		`"stop_reason":"end_turn"`,
+		"event: message_stop",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %q\n%s", want, body)
+		}
+	}
+}
+
+// TestCompletionsHandler_ResponsesTarget_EndToEnd tests a non-streaming
+// /v1/chat/completions request served by a Responses-only provider.
+func TestCompletionsHandler_ResponsesTarget_EndToEnd(t *testing.T) {
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(responsesUpstreamStream)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"alias","stream":false,"messages":[{"role":"user","content":"hi"}]}`))
+
+	NewCompletionsHandler(&config.Config{}, newResponsesOnlyRouter())(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, w.Body.String())
+	}
+	if len(completion.Choices) != 1 {
+		t.Fatalf("choices = %d, want 1", len(completion.Choices))
+	}
+	choice := completion.Choices[0]
+	if choice.Message.Content != "Hello" || choice.Message.ReasoningContent != "thinking" {
+		t.Errorf("message = %+v", choice.Message)
+	}
+	if choice.FinishReason != "stop" {
+		t.Errorf("finish_reason = %q, want stop", choice.FinishReason)
+	}
+	if completion.Usage.TotalTokens != 12 {
+		t.Errorf("total_tokens = %d, want 12", completion.Usage.TotalTokens)
+	}
+}
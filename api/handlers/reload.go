@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewReloadHandler creates a Gin handler for the /admin/reload endpoint, which
+// reloads the config file on demand, like SIGHUP.
+//
+// @param reload - loads, validates and applies the config file.
+// @return Gin handler function that answers {"status":"reloaded"}, or 422 with
+// the reason the file was rejected; the running configuration is then kept.
+func NewReloadHandler(reload func() error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := reload(); err != nil {
+			sendOpenAIError(c, http.StatusUnprocessableEntity,
+				"Config reload failed, keeping the running configuration: "+err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "reloaded"})
+	}
+}
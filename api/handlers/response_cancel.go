@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"sync"
 
+	"ai-proxy/auth"
 	"ai-proxy/stream"
 
 	"github.com/gin-gonic/gin"
@@ -24,11 +25,13 @@ func GetGlobalRegistry() *stream.Registry {
 }
 
 // ResponseCancelHandler handles requests to cancel an in-progress streaming response.
-// It allows clients to abort a streaming response by its ID.
+// It allows clients to abort a streaming response by its response ID.
 //
 // This handler:
 //   - Accepts POST requests to cancel active streams
-//   - Returns 404 if the stream is not found or already completed
+//   - Returns 404 if the stream is not found or already completed, if the ID is
+//     not a response ID, or if the stream belongs to another client key
+//   - Leaves cancelling by stream ID to /admin/streams/:id/cancel
 //   - Returns {cancelled: true, id: "..."} on successful cancellation
 //
 // @note This endpoint is part of the Responses API streaming lifecycle.
@@ -49,7 +52,9 @@ type ResponseCancelResponse struct {
 }
 
 // Handle processes the stream cancellation request.
-// It extracts the ID from the URL path, looks up the active stream, and cancels it.
+// It extracts the ID from the URL path, looks up the active stream by response
+// ID, and cancels it if the caller's client key started it. Streams of other
+// clients are reported as missing, so their IDs cannot be probed.
 //
 // @param c - Gin context for the HTTP request.
 func (h *ResponseCancelHandler) Handle(c *gin.Context) {
@@ -62,9 +67,9 @@ func (h *ResponseCancelHandler) Handle(c *gin.Context) {
 	// Get the global registry
 	registry := GetGlobalRegistry()
 
-	// Attempt to cancel the stream
-	cancelled := registry.Cancel(id)
-	if !cancelled {
+	// Attempt to cancel the caller's own stream
+	active := registry.GetByResponseID(id)
+	if active == nil || active.Info().Client != clientKeyName(auth.FromContext(c.Request.Context())) || !registry.Cancel(active.ID) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": gin.H{
 				"code":    "stream_not_found",
@@ -7,6 +7,10 @@ import (
 	"sync"
 	"testing"
 
+	"ai-proxy/auth"
+	"ai-proxy/stream"
+	"ai-proxy/transform"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -66,6 +70,67 @@ func TestResponseCancelHandler_Handle_NotFound(t *testing.T) {
 	}
 }
 
+// responseIDTransformer is a transformer with a response ID.
+type responseIDTransformer struct {
+	transform.SSETransformer
+	id string
+}
+
+func (t *responseIDTransformer) GetResponseID() string { return t.id }
+
+// cancelAs sends POST /v1/responses/:id/cancel as the client identified by id,
+// nil without authentication.
+func cancelAs(id *auth.Identity, responseID string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/v1/responses/:id/cancel", func(c *gin.Context) {
+		if id != nil {
+			c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), id))
+		}
+	}, NewResponseCancelHandler())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses/"+responseID+"/cancel", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCancelHandler_Handle_OwnStreamByResponseID(t *testing.T) {
+	globalRegistry = nil
+	globalRegistryOnce = sync.Once{}
+
+	registry := GetGlobalRegistry()
+	ctx, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+	registry.RegisterRequest(stream.Info{ID: "req-1", Client: "alice"}, interrupt)
+	registry.Attach("req-1", &responseIDTransformer{id: "resp_1"})
+
+	// The stream ID is not a response ID
+	if w := cancelAs(&auth.Identity{User: "alice"}, "req-1"); w.Code != http.StatusNotFound {
+		t.Errorf("cancel by stream ID: expected status 404, got %d", w.Code)
+	}
+	// Another client cannot cancel the stream, nor learn that it exists
+	if w := cancelAs(&auth.Identity{User: "bob"}, "resp_1"); w.Code != http.StatusNotFound {
+		t.Errorf("cancel by another client: expected status 404, got %d", w.Code)
+	}
+	if w := cancelAs(nil, "resp_1"); w.Code != http.StatusNotFound {
+		t.Errorf("cancel without authentication: expected status 404, got %d", w.Code)
+	}
+	if context.Cause(ctx) != nil {
+		t.Fatalf("stream cancelled by a rejected request: %v", context.Cause(ctx))
+	}
+
+	w := cancelAs(&auth.Identity{User: "alice"}, "resp_1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel by owner: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if cause := context.Cause(ctx); cause != stream.ErrCancelled {
+		t.Errorf("cause after cancel = %v, want stream.ErrCancelled", cause)
+	}
+	if registry.Get("req-1") != nil {
+		t.Error("stream should be removed from registry after cancellation")
+	}
+}
+
 func TestResponseCancelHandler_Handle_EmptyID(t *testing.T) {
 	router := gin.New()
 	router.POST("/v1/responses/:id/cancel", NewResponseCancelHandler())
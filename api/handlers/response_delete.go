@@ -45,7 +45,7 @@ func (h *ResponseDeleteHandler) Handle(c *gin.Context) {
 	}
 
 	// Check if conversation exists
-	conv := conversation.GetFromDefault(id)
+	conv := ownedConversation(c, id)
 	if conv == nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": gin.H{
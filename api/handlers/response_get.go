@@ -40,7 +40,7 @@ func (h *ResponseGetHandler) Handle(c *gin.Context) {
 	}
 
 	// Look up the conversation
-	conv := conversation.GetFromDefault(id)
+	conv := ownedConversation(c, id)
 	if conv == nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": gin.H{
@@ -106,7 +106,7 @@ func (h *ResponseInputItemsHandler) Handle(c *gin.Context) {
 	}
 
 	// Look up the conversation
-	conv := conversation.GetFromDefault(id)
+	conv := ownedConversation(c, id)
 	if conv == nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": gin.H{
@@ -8,11 +8,16 @@ import (
 	"net/http"
 	"strings"
 
+	"ai-proxy/auth"
+	"ai-proxy/balance"
 	"ai-proxy/capture"
+	"ai-proxy/circuit"
 	"ai-proxy/config"
 	"ai-proxy/convert"
+	"ai-proxy/proxy"
 	"ai-proxy/router"
 	"ai-proxy/transform"
+	"ai-proxy/transform/aggregate"
 	"ai-proxy/transform/toolcall"
 	wstransform "ai-proxy/transform/websearch"
 	"ai-proxy/types"
@@ -44,6 +49,9 @@ type ResponsesHandler struct {
 	// router resolves model names to providers and routes.
 	// Must not be nil after construction.
 	router router.Router
+	// plan is the resolved route plan for the current request.
+	// Set during ValidateRequest; nil if the model was not resolved.
+	plan *router.RoutePlan
 	// route is the resolved route for the current request.
 	// Set during ValidateRequest for use in subsequent methods.
 	route *router.ResolvedRoute
@@ -63,6 +71,12 @@ type ResponsesHandler struct {
 	// encryptedReasoning stores the encrypted reasoning blob from the request.
 	// Used in ZDR mode when store:false and encrypted_reasoning is provided.
 	encryptedReasoning string
+	// aggregating is true when the client sent "stream": false.
+	// Errors are then returned as JSON instead of SSE error events.
+	aggregating bool
+	// identity is the authenticated client, nil when authentication is disabled.
+	// Stored conversations are owned by its user and organization.
+	identity *auth.Identity
 }
 
 // NewResponsesHandler creates a Gin handler for the /v1/responses endpoint.
@@ -77,8 +91,9 @@ type ResponsesHandler struct {
 func NewResponsesHandler(cfg *config.Config, r router.Router) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := &ResponsesHandler{
-			cfg:    cfg,
-			router: r,
+			cfg:      cfg,
+			router:   r,
+			identity: auth.FromContext(c.Request.Context()),
 		}
 		Handle(h)(c)
 	}
@@ -99,14 +114,15 @@ func (h *ResponsesHandler) ValidateRequest(body []byte) error {
 		return fmt.Errorf("model is required")
 	}
 
-	// Resolve the model to a route with protocol context
-	route, err := h.router.ResolveWithProtocol(req.Model, "responses")
+	// Resolve the model to a route plan with protocol context
+	plan, err := h.router.ResolvePlan(req.Model, "responses")
 	if err != nil {
 		return fmt.Errorf("failed to resolve model '%s': %w", req.Model, err)
 	}
 
 	// Store the resolved route for use in other methods
-	h.route = route
+	h.plan = plan
+	h.route = plan.Primary()
 	h.originalModel = req.Model
 
 	// Parse and store input items for conversation storage
@@ -139,6 +155,8 @@ func (h *ResponsesHandler) ValidateRequest(body []byte) error {
 // TransformRequest converts the request body based on the upstream provider type.
 // For OpenAI providers, it converts to Chat Completions format.
 // For Anthropic providers, it converts to Anthropic Messages format.
+// For Gemini providers, it converts to Gemini format via Chat Completions.
+// For Ollama providers, it converts to Ollama format via Chat Completions.
 //
 // @param ctx - Context for the request, used for cache status tracking.
 // @param body - Raw request body in OpenAI Responses API format.
@@ -168,24 +186,66 @@ func (h *ResponsesHandler) TransformRequest(ctx context.Context, body []byte) ([
 	switch h.route.OutputProtocol {
 	case "openai":
 		// Convert ResponsesRequest to ChatCompletionRequest
-		converter := convert.NewResponsesToChatConverter()
-		converter.SetReasoningSplit(h.route.ReasoningSplit)
-		converter.SetStore(h.shouldStore)
-		result, err := converter.Convert(updatedBody)
-		if err == nil && converter.CacheHit() {
-			capture.SetCacheHit(ctx)
-		}
-		return result, err
+		return h.transformToChat(ctx, updatedBody)
 	case "anthropic":
 		// Convert ResponsesRequest to Anthropic MessageRequest
-		result, err := convert.TransformResponsesToAnthropicWithOptions(updatedBody, ctx, h.shouldStore)
+		result, err := convert.TransformResponsesToAnthropicWithOptions(updatedBody, ctx, h.shouldStore, h.maxContextTokens())
 		return result, err
+	case "gemini":
+		// Convert ResponsesRequest to Gemini format via Chat Completions
+		result, err := h.transformToChat(ctx, updatedBody)
+		if err != nil {
+			return nil, err
+		}
+		return convert.TransformChatToGemini(result)
+	case "ollama":
+		// Convert ResponsesRequest to Ollama format via Chat Completions
+		result, err := h.transformToChat(ctx, updatedBody)
+		if err != nil {
+			return nil, err
+		}
+		return convert.TransformChatToOllama(result)
 	default:
 		// Unknown protocol - pass through as-is
 		return updatedBody, nil
 	}
 }
 
+// transformToChat converts a Responses API request to a Chat Completions
+// request, expanding the previous_response_id conversation history.
+//
+// @param ctx - Context for the request, used for cache status tracking.
+// @param body - Request body in Responses API format with the upstream model.
+// @return Request body in Chat Completions format.
+func (h *ResponsesHandler) transformToChat(ctx context.Context, body []byte) ([]byte, error) {
+	converter := convert.NewResponsesToChatConverter()
+	converter.SetReasoningSplit(h.route.ReasoningSplit)
+	converter.SetStore(h.shouldStore)
+	converter.SetMaxContextTokens(h.maxContextTokens())
+	converter.SetUserID(auth.UserID(ctx))
+	result, err := converter.Convert(body)
+	if err == nil && converter.CacheHit() {
+		capture.SetCacheHit(ctx)
+	}
+	return result, err
+}
+
+// maxContextTokens returns the history token budget for the resolved model.
+// The model's max_context_tokens takes precedence over responses.max_context_tokens.
+//
+// @return Token budget for previous_response_id history, 0 for no limit.
+func (h *ResponsesHandler) maxContextTokens() int {
+	if h.route != nil && h.route.MaxContextTokens > 0 {
+		return h.route.MaxContextTokens
+	}
+	if h.cfg != nil {
+		if schema := h.cfg.GetSchema(); schema != nil {
+			return schema.Responses.MaxContextTokens
+		}
+	}
+	return 0
+}
+
 // UpstreamURL returns the upstream API URL based on the resolved provider.
 //
 // @return URL string for the upstream API endpoint.
@@ -193,7 +253,7 @@ func (h *ResponsesHandler) UpstreamURL() string {
 	if h.route == nil {
 		return ""
 	}
-	return h.route.Provider.GetEndpoint(h.route.OutputProtocol)
+	return h.route.Provider.GetModelEndpoint(h.route.OutputProtocol, h.route.Model)
 }
 
 // ResolveAPIKey returns the API key for the resolved provider.
@@ -204,12 +264,14 @@ func (h *ResponsesHandler) ResolveAPIKey(c *gin.Context) string {
 	if h.route == nil {
 		return ""
 	}
-	return h.route.Provider.GetAPIKey()
+	return h.route.GetAPIKey()
 }
 
 // ForwardHeaders copies relevant headers to the upstream request.
 // For OpenAI providers, it forwards X-* headers.
 // For Anthropic providers, it also forwards Anthropic-specific headers.
+// For other providers, it forwards X-* headers.
+// The API key and provider headers are set afterwards by the upstream client.
 //
 // @param c - Gin context containing the original request headers.
 // @param req - Upstream request to receive forwarded headers.
@@ -239,6 +301,8 @@ func (h *ResponsesHandler) ForwardHeaders(c *gin.Context, req *http.Request) {
 // CreateTransformer builds an SSE transformer for converting upstream responses.
 // For OpenAI providers, it converts Chat Completions to Responses API format.
 // For Anthropic providers, it converts Anthropic events to Responses API format.
+// For Gemini providers, it converts Gemini chunks to Responses API format via Chat Completions.
+// For Ollama providers, it converts Ollama lines to Responses API format via Chat Completions.
 // If web search service is enabled, wraps the transformer to intercept web_search tool calls.
 //
 // @param w - Writer to receive transformed output.
@@ -257,17 +321,13 @@ func (h *ResponsesHandler) CreateTransformer(w io.Writer) transform.SSETransform
 
 	switch h.route.OutputProtocol {
 	case "openai":
-		// ChatToResponsesTransformer converts Chat Completions to Responses format
-		// Tool call extraction from markup is enabled when tool_call_transform is true
-		t := convert.NewChatToResponsesTransformer(w)
-		t.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
-		t.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
-		t.SetInputItems(h.inputItems)
-		t.SetStore(h.shouldStore)
-		t.SetPreviousResponseID(h.previousResponseID)
-		t.SetReasoningSummaryMode(h.reasoningSummaryMode)
-		t.SetEncryptedReasoning(h.encryptedReasoning)
-		baseTransformer = t
+		baseTransformer = h.newChatToResponsesTransformer(w)
+	case "gemini":
+		// Gemini chunks are converted to Chat Completions chunks first
+		baseTransformer = convert.NewGeminiToChatTransformer(h.newChatToResponsesTransformer(w))
+	case "ollama":
+		// Ollama lines are converted to Chat Completions chunks first
+		baseTransformer = convert.NewOllamaToChatTransformer(h.newChatToResponsesTransformer(w))
 	case "anthropic":
 		// ResponsesTransformer converts Anthropic SSE to Responses format
 		// This conversion is always needed for /v1/responses endpoint
@@ -280,6 +340,10 @@ func (h *ResponsesHandler) CreateTransformer(w io.Writer) transform.SSETransform
 		t.SetPreviousResponseID(h.previousResponseID)
 		t.SetReasoningSummaryMode(h.reasoningSummaryMode)
 		t.SetEncryptedReasoning(h.encryptedReasoning)
+		if h.identity != nil {
+			t.SetUserID(h.identity.User)
+			t.SetOrgID(h.identity.Org)
+		}
 		baseTransformer = t
 	default:
 		return transform.NewPassthroughTransformer(w)
@@ -289,6 +353,28 @@ func (h *ResponsesHandler) CreateTransformer(w io.Writer) transform.SSETransform
 	return h.wrapWithWebSearch(baseTransformer)
 }
 
+// newChatToResponsesTransformer builds the transformer converting Chat
+// Completions chunks to Responses API events for the current request.
+//
+// @param w - Writer to receive transformed output.
+// @return Transformer for Chat Completions chunks.
+func (h *ResponsesHandler) newChatToResponsesTransformer(w io.Writer) transform.SSETransformer {
+	// Tool call extraction from markup is enabled when tool_call_transform is true
+	t := convert.NewChatToResponsesTransformer(w)
+	t.SetKimiToolCallTransform(h.route.KimiToolCallTransform)
+	t.SetGLM5ToolCallTransform(h.route.GLM5ToolCallTransform)
+	t.SetInputItems(h.inputItems)
+	t.SetStore(h.shouldStore)
+	t.SetPreviousResponseID(h.previousResponseID)
+	t.SetReasoningSummaryMode(h.reasoningSummaryMode)
+	t.SetEncryptedReasoning(h.encryptedReasoning)
+	if h.identity != nil {
+		t.SetUserID(h.identity.User)
+		t.SetOrgID(h.identity.Org)
+	}
+	return t
+}
+
 // wrapWithWebSearch wraps the base transformer with web search interception if enabled.
 //
 // @param base - The base transformer to wrap.
@@ -305,14 +391,28 @@ func (h *ResponsesHandler) wrapWithWebSearch(base transform.SSETransformer) tran
 }
 
 // WriteError sends an error response in OpenAI Responses API format.
+// Non-streaming requests receive a JSON error body instead of an SSE error event.
 //
 // @param c - Gin context for writing the response.
 // @param status - HTTP status code for the error.
 // @param msg - Human-readable error message.
 func (h *ResponsesHandler) WriteError(c *gin.Context, status int, msg string) {
+	if h.aggregating {
+		sendOpenAIError(c, status, msg)
+		return
+	}
 	sendOpenAIResponsesError(c, status, msg)
 }
 
+// NewAggregator switches the handler into non-streaming mode.
+// The assembled response is the final response object from the stream.
+//
+// @return Aggregator for Responses API streaming events.
+func (h *ResponsesHandler) NewAggregator() aggregate.Aggregator {
+	h.aggregating = true
+	return aggregate.NewResponsesAggregator()
+}
+
 // parseInputItems converts the input interface from a ResponsesRequest to a slice of InputItems.
 // This is needed for conversation storage to preserve the original input.
 func parseInputItems(input interface{}) []types.InputItem {
@@ -366,6 +466,100 @@ func parseInputItems(input interface{}) []types.InputItem {
 	return nil
 }
 
+// RetryPolicy returns the retry policy of the resolved provider.
+//
+// @return Policy from the provider's retry configuration, single attempt if none.
+func (h *ResponsesHandler) RetryPolicy() proxy.RetryPolicy {
+	return routeRetryPolicy(h.route)
+}
+
+// HasNextRoute reports whether the route plan has a fallback after the current route.
+func (h *ResponsesHandler) HasNextRoute() bool {
+	return h.plan.Next(h.route) != nil
+}
+
+// NextRoute switches to the next fallback route of the route plan.
+//
+// @return false if the current route is the last one.
+func (h *ResponsesHandler) NextRoute() bool {
+	next := h.plan.Next(h.route)
+	if next == nil {
+		return false
+	}
+	h.route = next
+	return true
+}
+
+// UpstreamKey returns the load-balanced API key of the current route.
+func (h *ResponsesHandler) UpstreamKey() *balance.Key {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Key
+}
+
+// UpstreamBreaker returns the circuit breaker of the current route's provider.
+func (h *ResponsesHandler) UpstreamBreaker() *circuit.Breaker {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Breaker
+}
+
+// FetchCredential fetches the current route's key from its provider's
+// credential source, if it has one.
+func (h *ResponsesHandler) FetchCredential(ctx context.Context) error {
+	if h.route == nil {
+		return nil
+	}
+	_, err := h.route.FetchAPIKey(ctx)
+	return err
+}
+
+// InvalidateCredential drops the current route's fetched key from its
+// provider's credential source.
+func (h *ResponsesHandler) InvalidateCredential() bool {
+	return h.route != nil && h.route.InvalidateCredential()
+}
+
+// MetricLabels returns the inbound protocol and the current route's provider
+// and upstream protocol.
+func (h *ResponsesHandler) MetricLabels() (inbound, provider, upstreamProtocol string) {
+	if h.route == nil {
+		return "responses", "", ""
+	}
+	return "responses", h.route.Provider.Name, h.route.OutputProtocol
+}
+
+// UpstreamDialect returns the dialect of the current route's provider.
+func (h *ResponsesHandler) UpstreamDialect() string {
+	if h.route == nil {
+		return ""
+	}
+	return h.route.Provider.Dialect
+}
+
+// UpstreamClientOptions returns the auth and header options of the current route's provider.
+func (h *ResponsesHandler) UpstreamClientOptions() []proxy.ClientOption {
+	return routeClientOptions(h.route)
+}
+
+// ModelLimits returns the per-client limits of the resolved model alias.
+func (h *ResponsesHandler) ModelLimits() *config.LimitsConfig {
+	if h.plan == nil {
+		return nil
+	}
+	return h.plan.Limits
+}
+
+// RoutePrice returns the price of the current route's upstream model.
+func (h *ResponsesHandler) RoutePrice() *config.ModelPrice {
+	if h.route == nil {
+		return nil
+	}
+	return h.route.Price
+}
+
 // ModelInfo returns the downstream and upstream model names for logging.
 func (h *ResponsesHandler) ModelInfo() (downstreamModel string, upstreamModel string) {
 	downstreamModel = h.originalModel
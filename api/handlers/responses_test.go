@@ -10,7 +10,10 @@ import (
 	"strings"
 	"testing"
 
+	"ai-proxy/balance"
+	"ai-proxy/circuit"
 	"ai-proxy/config"
+	"ai-proxy/proxy"
 	"ai-proxy/router"
 	"ai-proxy/types"
 
@@ -19,13 +22,18 @@ import (
 
 // mockRouter implements router.Router for testing.
 type mockRouter struct {
-	models    map[string]*router.ResolvedRoute
-	providers map[string]config.Provider
+	models      map[string]*router.ResolvedRoute
+	plans       map[string]*router.RoutePlan
+	providers   map[string]config.Provider
+	keyStats    []balance.KeyStats
+	health      []circuit.Stats
+	connections []proxy.PoolStats
 }
 
 func newMockRouter() *mockRouter {
 	return &mockRouter{
 		models:    make(map[string]*router.ResolvedRoute),
+		plans:     make(map[string]*router.RoutePlan),
 		providers: make(map[string]config.Provider),
 	}
 }
@@ -55,6 +63,31 @@ func (m *mockRouter) ResolveWithProtocol(modelName, incomingProtocol string) (*r
 	return m.Resolve(modelName)
 }
 
+func (m *mockRouter) ResolvePlan(modelName, incomingProtocol string) (*router.RoutePlan, error) {
+	if plan, ok := m.plans[modelName]; ok {
+		return plan, nil
+	}
+	route, err := m.ResolveWithProtocol(modelName, incomingProtocol)
+	if err != nil {
+		return nil, err
+	}
+	return &router.RoutePlan{Routes: []*router.ResolvedRoute{route}}, nil
+}
+
+func (m *mockRouter) KeyStats() []balance.KeyStats {
+	return m.keyStats
+}
+
+func (m *mockRouter) ProviderHealth() []circuit.Stats {
+	return m.health
+}
+
+func (m *mockRouter) ConnectionStats() []proxy.PoolStats {
+	return m.connections
+}
+
+func (m *mockRouter) CloseIdleConnections() {}
+
 // TestResponsesHandler_ValidateRequest tests request validation.
 func TestResponsesHandler_ValidateRequest(t *testing.T) {
 	mockR := newMockRouter()
@@ -231,6 +264,34 @@ func TestResponsesHandler_TransformRequest_OpenAI(t *testing.T) {
 	}
 }
 
+// TestResponsesHandler_MaxContextTokens tests that the model budget overrides the global one.
+func TestResponsesHandler_MaxContextTokens(t *testing.T) {
+	global := &config.Config{AppConfig: &config.Schema{
+		Responses: config.ResponsesConfig{MaxContextTokens: 4000},
+	}}
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		route   *router.ResolvedRoute
+		wantMax int
+	}{
+		{"no config", &config.Config{}, &router.ResolvedRoute{}, 0},
+		{"global budget", global, &router.ResolvedRoute{}, 4000},
+		{"model override", global, &router.ResolvedRoute{MaxContextTokens: 1000}, 1000},
+		{"nil route", global, nil, 4000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &ResponsesHandler{cfg: tt.cfg, route: tt.route}
+			if got := handler.maxContextTokens(); got != tt.wantMax {
+				t.Errorf("maxContextTokens() = %d, want %d", got, tt.wantMax)
+			}
+		})
+	}
+}
+
 // TestResponsesHandler_TransformRequest_Anthropic tests transformation for Anthropic provider.
 func TestResponsesHandler_TransformRequest_Anthropic(t *testing.T) {
 	mockR := newMockRouter()
@@ -676,3 +737,29 @@ func TestResponsesHandler_NoEncryptedReasoning(t *testing.T) {
 		t.Error("shouldStore should be true when store:true")
 	}
 }
+
+// TestResponsesHandler_WriteError_NonStreaming tests that non-streaming requests get JSON errors.
+func TestResponsesHandler_WriteError_NonStreaming(t *testing.T) {
+	handler := &ResponsesHandler{
+		cfg:    &config.Config{},
+		router: newMockRouter(),
+	}
+	handler.NewAggregator()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	handler.WriteError(c, http.StatusBadGateway, "upstream failed")
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+
+	var resp types.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Response should be a JSON error object: %v\n%s", err, w.Body.String())
+	}
+	if resp.Error.Message != "upstream failed" {
+		t.Errorf("Error message = %q, want %q", resp.Error.Message, "upstream failed")
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ai-proxy/capture"
+	"ai-proxy/logging"
+	"ai-proxy/proxy"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDrainBytes limits how much of a failed response body is read before retrying,
+// so the connection can be reused without buffering large error pages.
+const maxDrainBytes = 64 * 1024
+
+// routeRetryPolicy returns the retry policy of a route's provider.
+//
+// @param route - Resolved route, may be nil.
+// @return Policy from the provider's retry configuration, single attempt if none.
+func routeRetryPolicy(route *router.ResolvedRoute) proxy.RetryPolicy {
+	if route == nil {
+		return proxy.RetryPolicy{}
+	}
+	return proxy.NewRetryPolicy(route.Provider.Retry)
+}
+
+// doUpstream sends the upstream request, retrying transport errors and retryable
+// statuses according to the handler's retry policy. Each attempt is bounded by
+// the provider's first-byte timeout, if configured. Nothing is written to the client
+// here, so every retry happens before the first upstream byte is streamed.
+// Each attempt is logged and, when retries are configured, recorded in the capture.
+//
+// @param c - Gin context for the current request.
+// @param h - Handler providing the retry policy and forwarded headers.
+// @param client - Upstream client used for every attempt.
+// @param req - Request for the first attempt.
+// @param body - Request body, used to rebuild the request for later attempts.
+// @return The final response or transport error, as from client.Do.
+//
+// @post On success the caller must close the response body.
+func doUpstream(c *gin.Context, h Handler, client upstreamClient, req *http.Request, body []byte) (*http.Response, error) {
+	var policy proxy.RetryPolicy
+	if rh, ok := h.(RetryingHandler); ok {
+		policy = rh.RetryPolicy()
+	}
+	maxAttempts := policy.Attempts()
+	cc := capture.GetCaptureContext(c.Request.Context())
+
+	for attempt := 1; ; attempt++ {
+		resp, err := doWithFirstByteTimeout(client, req, upstreamBreaker(h).FirstByteTimeout())
+		delay, retry := policy.NextDelay(attempt, resp, err)
+
+		if maxAttempts > 1 {
+			if cc != nil {
+				cc.Recorder.RecordUpstreamAttempt(newUpstreamAttempt(attempt, resp, err, delay, retry))
+			}
+			if retry {
+				logging.InfoMsg("Upstream attempt %d/%d failed (%s), retrying in %v", attempt, maxAttempts, attemptOutcome(resp, err), delay)
+			} else if attempt > 1 {
+				logging.InfoMsg("Upstream attempt %d/%d finished (%s)", attempt, maxAttempts, attemptOutcome(resp, err))
+			}
+		}
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+			resp.Body.Close()
+		}
+
+		ctx := c.Request.Context()
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		// The previous request body has been consumed; build a fresh request
+		req, err = client.BuildRequest(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+		h.ForwardHeaders(c, req)
+		client.SetHeaders(req)
+	}
+}
+
+// newUpstreamAttempt builds the capture record of an upstream attempt.
+func newUpstreamAttempt(attempt int, resp *http.Response, err error, delay time.Duration, retry bool) capture.UpstreamAttempt {
+	a := capture.UpstreamAttempt{
+		Attempt: attempt,
+		At:      time.Now(),
+	}
+	if resp != nil {
+		a.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	if retry {
+		a.RetryDelayMS = delay.Milliseconds()
+	}
+	return a
+}
+
+// attemptOutcome describes an attempt's result for logging.
+func attemptOutcome(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode)
+}
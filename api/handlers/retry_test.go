@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/capture"
+	"ai-proxy/config"
+	"ai-proxy/proxy"
+	"ai-proxy/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryingMockHandler is a mockHandler with a retry policy.
+type retryingMockHandler struct {
+	mockHandler
+	policy proxy.RetryPolicy
+}
+
+func (m *retryingMockHandler) RetryPolicy() proxy.RetryPolicy {
+	return m.policy
+}
+
+func fastRetryPolicy(attempts int) proxy.RetryPolicy {
+	return proxy.RetryPolicy{
+		MaxAttempts:       attempts,
+		InitialBackoff:    time.Millisecond,
+		MaxBackoff:        10 * time.Millisecond,
+		Multiplier:        1,
+		RetryableStatuses: proxy.DefaultRetryableStatuses,
+	}
+}
+
+func sseOKResponse() *http.Response {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("data: {\"id\":\"ok\"}\n\ndata: [DONE]\n\n")),
+		Header:     make(http.Header),
+	}
+	resp.Header.Set("Content-Type", "text/event-stream")
+	return resp
+}
+
+func errorResponse(status int) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"upstream failed"}}`)),
+		Header:     make(http.Header),
+	}
+}
+
+func TestRouteRetryPolicy(t *testing.T) {
+	if got := routeRetryPolicy(nil).Attempts(); got != 1 {
+		t.Errorf("nil route: expected 1 attempt, got %d", got)
+	}
+
+	route := &router.ResolvedRoute{Provider: config.Provider{Name: "p"}}
+	if got := routeRetryPolicy(route).Attempts(); got != 1 {
+		t.Errorf("no retry config: expected 1 attempt, got %d", got)
+	}
+
+	route.Provider.Retry = &config.RetryConfig{MaxAttempts: 4}
+	if got := routeRetryPolicy(route).Attempts(); got != 4 {
+		t.Errorf("expected 4 attempts, got %d", got)
+	}
+}
+
+func TestHandle_RetriesRetryableStatus(t *testing.T) {
+	var calls int
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != `{"stream": true}` {
+			t.Errorf("attempt %d: expected full request body, got %q", calls, body)
+		}
+		if req.Header.Get("X-Custom-Header") != "custom-value" {
+			t.Errorf("attempt %d: expected forwarded header", calls)
+		}
+		if calls < 3 {
+			return errorResponse(http.StatusServiceUnavailable), nil
+		}
+		return sseOKResponse(), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"stream": true}`))
+	c.Request.Header.Set("X-Custom-Header", "custom-value")
+
+	cc := capture.NewCaptureContext(c.Request)
+	c.Request = c.Request.WithContext(capture.WithCaptureContext(c.Request.Context(), cc))
+
+	h := &retryingMockHandler{
+		mockHandler: mockHandler{
+			upstreamURL: "https://example.com/upstream",
+			forwardHeadersFn: func(c *gin.Context, req *http.Request) {
+				req.Header.Set("X-Custom-Header", c.GetHeader("X-Custom-Header"))
+			},
+		},
+		policy: fastRetryPolicy(3),
+	}
+
+	Handle(h)(c)
+
+	if calls != 3 {
+		t.Fatalf("expected 3 upstream attempts, got %d", calls)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	attempts := cc.Recorder.Data().UpstreamAttempts
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 recorded attempts, got %d", len(attempts))
+	}
+	for i, a := range attempts[:2] {
+		if a.Attempt != i+1 || a.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("attempt %d: unexpected record %+v", i+1, a)
+		}
+	}
+	if attempts[2].StatusCode != http.StatusOK || attempts[2].RetryDelayMS != 0 {
+		t.Errorf("final attempt: unexpected record %+v", attempts[2])
+	}
+}
+
+func TestHandle_RetryExhaustedReturnsUpstreamError(t *testing.T) {
+	var calls int
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return errorResponse(http.StatusTooManyRequests), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"stream": true}`))
+
+	h := &retryingMockHandler{
+		mockHandler: mockHandler{upstreamURL: "https://example.com/upstream"},
+		policy:      fastRetryPolicy(2),
+	}
+
+	Handle(h)(c)
+
+	if calls != 2 {
+		t.Errorf("expected 2 upstream attempts, got %d", calls)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", w.Code)
+	}
+}
+
+func TestHandle_NoRetryOnNonRetryableStatus(t *testing.T) {
+	var calls int
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return errorResponse(http.StatusBadRequest), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"stream": true}`))
+
+	cc := capture.NewCaptureContext(c.Request)
+	c.Request = c.Request.WithContext(capture.WithCaptureContext(c.Request.Context(), cc))
+
+	h := &retryingMockHandler{
+		mockHandler: mockHandler{upstreamURL: "https://example.com/upstream"},
+		policy:      fastRetryPolicy(3),
+	}
+
+	Handle(h)(c)
+
+	if calls != 1 {
+		t.Errorf("expected 1 upstream attempt, got %d", calls)
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	if got := len(cc.Recorder.Data().UpstreamAttempts); got != 1 {
+		t.Errorf("expected 1 recorded attempt, got %d", got)
+	}
+}
+
+func TestHandle_RetriesTransportError(t *testing.T) {
+	var calls int
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return sseOKResponse(), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"stream": false}`))
+
+	h := &retryingMockHandler{
+		mockHandler: mockHandler{upstreamURL: "https://example.com/upstream"},
+		policy:      fastRetryPolicy(2),
+	}
+
+	Handle(h)(c)
+
+	if calls != 2 {
+		t.Errorf("expected 2 upstream attempts, got %d", calls)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestHandle_NoRetryWithoutPolicy(t *testing.T) {
+	var calls int
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return errorResponse(http.StatusServiceUnavailable), nil
+	})
+
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"stream": true}`))
+
+	cc := capture.NewCaptureContext(c.Request)
+	c.Request = c.Request.WithContext(capture.WithCaptureContext(c.Request.Context(), cc))
+
+	h := &mockHandler{upstreamURL: "https://example.com/upstream"}
+
+	Handle(h)(c)
+
+	if calls != 1 {
+		t.Errorf("expected 1 upstream attempt, got %d", calls)
+	}
+	if got := len(cc.Recorder.Data().UpstreamAttempts); got != 0 {
+		t.Errorf("expected no recorded attempts without retries, got %d", got)
+	}
+}
+
+func TestDoUpstream_StopsWhenClientCancels(t *testing.T) {
+	var calls int
+	client := &fakeUpstreamClient{
+		do: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return errorResponse(http.StatusServiceUnavailable), nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+
+	policy := fastRetryPolicy(5)
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+	h := &retryingMockHandler{policy: policy}
+
+	req, _ := client.BuildRequest(ctx, []byte(`{}`))
+	cancel()
+	resp, err := doUpstream(c, h, client, req, []byte(`{}`))
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if resp != nil {
+		t.Error("expected nil response")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 upstream attempt, got %d", calls)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"ai-proxy/auth"
+	"ai-proxy/stream"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamsResponse is the response of the GET /admin/streams endpoint.
+type StreamsResponse struct {
+	Object string        `json:"object"`
+	Data   []stream.Info `json:"data"`
+}
+
+// registerRequest adds the request to the global stream registry, where
+// /admin/streams lists it and can cancel it, and publishes its progress there
+// through the request's metrics tracker.
+//
+// @param c - Gin context of a request that passed validation and admission,
+// tracked by startRequestMetrics.
+// @param h - Handler processing the request.
+// @param streaming - whether the client asked for a streaming response.
+// @param interrupt - cancels the context of the upstream request.
+// @return Cleanup function that unregisters the request. Never nil.
+func registerRequest(c *gin.Context, h Handler, streaming bool, interrupt context.CancelCauseFunc) func() {
+	m := requestMetricsFrom(c)
+	info := stream.Info{
+		ID:        m.id,
+		Client:    clientKeyName(auth.FromContext(c.Request.Context())),
+		Streaming: streaming,
+	}
+	info.Model, info.UpstreamModel = h.ModelInfo()
+	info.Protocol, info.Provider, _ = handlerLabels(h)
+
+	registry := GetGlobalRegistry()
+	active := registry.RegisterRequest(info, interrupt)
+	m.active = active
+	return func() {
+		registry.Remove(active.ID)
+	}
+}
+
+// NewStreamsHandler creates a Gin handler for the GET /admin/streams endpoint,
+// which lists every in-flight request, oldest first.
+//
+// @return Gin handler function that answers {"object":"list","data":[...]}.
+func NewStreamsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		streams := GetGlobalRegistry().Streams()
+		data := make([]stream.Info, 0, len(streams))
+		for _, s := range streams {
+			data = append(data, s.Info())
+		}
+		c.JSON(http.StatusOK, StreamsResponse{Object: "list", Data: data})
+	}
+}
+
+// NewStreamHandler creates a Gin handler for the GET /admin/streams/:id
+// endpoint, which describes one in-flight request.
+//
+// @return Gin handler function that answers the stream's info, or 404 if the
+// ID matches neither a stream ID nor a response ID.
+func NewStreamHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := GetGlobalRegistry().Get(c.Param("id"))
+		if s == nil {
+			sendOpenAIError(c, http.StatusNotFound, "Stream not found or already completed")
+			return
+		}
+		c.JSON(http.StatusOK, s.Info())
+	}
+}
+
+// NewStreamCancelHandler creates a Gin handler for the POST
+// /admin/streams/:id/cancel endpoint, which cancels an in-flight request of
+// any endpoint. Its client receives the end of the response the transformer
+// emits on cancellation, such as response.cancelled or message_stop.
+//
+// @return Gin handler function that answers {"cancelled":true,"id":"..."},
+// or 404 if the ID matches neither a stream ID nor a response ID.
+func NewStreamCancelHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !GetGlobalRegistry().Cancel(id) {
+			sendOpenAIError(c, http.StatusNotFound, "Stream not found or already completed")
+			return
+		}
+		c.JSON(http.StatusOK, ResponseCancelResponse{Cancelled: true, ID: id})
+	}
+}
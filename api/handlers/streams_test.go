@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"ai-proxy/stream"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamsRouter serves the /admin/streams endpoints on a fresh global registry.
+func streamsRouter() *gin.Engine {
+	globalRegistry = nil
+	globalRegistryOnce = sync.Once{}
+
+	router := gin.New()
+	router.GET("/admin/streams", NewStreamsHandler())
+	router.GET("/admin/streams/:id", NewStreamHandler())
+	router.POST("/admin/streams/:id/cancel", NewStreamCancelHandler())
+	return router
+}
+
+func TestStreamsHandlers(t *testing.T) {
+	router := streamsRouter()
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// An empty registry lists an empty array, not null
+	if w := serve(http.MethodGet, "/admin/streams"); w.Code != http.StatusOK || w.Body.String() != `{"object":"list","data":[]}` {
+		t.Errorf("empty list: got %d %s", w.Code, w.Body.String())
+	}
+
+	ctx, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+	GetGlobalRegistry().RegisterRequest(stream.Info{ID: "req_1", Protocol: "openai", Model: "kimi", Client: "alice", Streaming: true}, interrupt)
+
+	w := serve(http.MethodGet, "/admin/streams")
+	var list StreamsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to parse list: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != "req_1" || list.Data[0].Model != "kimi" || list.Data[0].Phase != stream.PhaseConnecting {
+		t.Errorf("unexpected list: %+v", list.Data)
+	}
+
+	w = serve(http.MethodGet, "/admin/streams/req_1")
+	var info stream.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("get: status %d, %v", w.Code, err)
+	}
+	if info.Client != "alice" || info.Protocol != "openai" || !info.Streaming {
+		t.Errorf("unexpected stream: %+v", info)
+	}
+
+	if w := serve(http.MethodGet, "/admin/streams/req_unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown stream: expected status 404, got %d", w.Code)
+	}
+
+	w = serve(http.MethodPost, "/admin/streams/req_1/cancel")
+	if w.Code != http.StatusOK || w.Body.String() != `{"cancelled":true,"id":"req_1"}` {
+		t.Errorf("cancel: got %d %s", w.Code, w.Body.String())
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, stream.ErrCancelled) {
+		t.Errorf("cancelled request context cause = %v, want %v", cause, stream.ErrCancelled)
+	}
+	if w := serve(http.MethodPost, "/admin/streams/req_1/cancel"); w.Code != http.StatusNotFound {
+		t.Errorf("second cancel: expected status 404, got %d", w.Code)
+	}
+}
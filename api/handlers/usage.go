@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"ai-proxy/auth"
+	"ai-proxy/capture"
+	"ai-proxy/config"
+	"ai-proxy/ledger"
+	"ai-proxy/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CostHeader reports the dollar cost of a request, priced from its upstream usage.
+// Streaming responses carry it as an HTTP trailer, since the usage is only
+// known once the stream has ended.
+const CostHeader = "X-Proxy-Cost"
+
+// requestTotals returns the usage and cost of a request as reported by the
+// upstream so far, priced at the route that served it.
+//
+// @param c - Gin context of the request.
+// @param h - Handler of the request.
+// @return The request's ledger totals; the cost is zero if the route has no price.
+func requestTotals(c *gin.Context, h Handler) ledger.Totals {
+	var price *config.ModelPrice
+	if lh, ok := h.(LimitedHandler); ok {
+		price = lh.RoutePrice()
+	}
+	return ledger.Request(requestMetricsFrom(c).tokenUsage(), price)
+}
+
+// setCostHeader sets the CostHeader of a request. Before the response is
+// written it is sent as a header; afterwards only if declared as a trailer.
+//
+// @param c - Gin context of the request.
+// @param t - the request's totals.
+func setCostHeader(c *gin.Context, t ledger.Totals) {
+	c.Writer.Header().Set(CostHeader, formatLimit(t.Cost))
+}
+
+// settleRequest accounts for a finished request: its upstream usage and cost
+// are charged to the limiters it was admitted by, recorded in the usage ledger
+// and reported in the CostHeader trailer. A request no upstream accepted is
+// neither charged nor recorded.
+//
+// @param c - Gin context of the finished request.
+// @param h - Handler of the request.
+// @param limiters - the limiters returned by admitRequest.
+func settleRequest(c *gin.Context, h Handler, limiters []*ratelimit.Limiter) {
+	if !requestMetricsFrom(c).upstreamServed() {
+		return
+	}
+	totals := requestTotals(c, h)
+	usage := requestMetricsFrom(c).tokenUsage()
+	for _, l := range limiters {
+		l.Charge(int64(usage.InputTokens+usage.OutputTokens), totals.Cost)
+	}
+	setCostHeader(c, totals)
+	if m := requestMetricsFrom(c); m != nil {
+		m.cost = totals.Cost
+	}
+
+	ctx := c.Request.Context()
+	var session string
+	if cc := capture.GetCaptureContext(ctx); cc != nil && cc.SessionProvided {
+		session = cc.SessionID
+	}
+	model, _ := h.ModelInfo()
+	ledger.Default.Record(session, clientKeyName(auth.FromContext(ctx)), model, totals)
+}
+
+// clientKeyName names an authenticated client in the usage ledger: the key's
+// name, or its user if the key is unnamed.
+//
+// @return The name, empty if the request is not authenticated.
+func clientKeyName(id *auth.Identity) string {
+	switch {
+	case id == nil:
+		return ""
+	case id.Name != "":
+		return id.Name
+	default:
+		return id.User
+	}
+}
+
+// UsageResponse is the response of the /admin/usage endpoint.
+type UsageResponse struct {
+	Object  string        `json:"object"`
+	GroupBy []string      `json:"group_by"`
+	Data    []ledger.Row  `json:"data"`
+	Total   ledger.Totals `json:"total"`
+}
+
+// NewUsageHandler creates a Gin handler for the /admin/usage endpoint, which
+// reports the usage and cost recorded in the usage ledger.
+//
+// Query parameters:
+//   - group_by: comma-separated dimensions among session, client_key, model
+//     and day. Default: all four.
+//   - session, client_key, model: keep only rows with that value.
+//   - from, to: keep only days in the inclusive range, as YYYY-MM-DD (UTC).
+//
+// @return Gin handler function that answers usage queries.
+//
+// @note Requests without an X-Session-ID header are recorded with an empty session.
+func NewUsageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupBy := []string{ledger.GroupSession, ledger.GroupClientKey, ledger.GroupModel, ledger.GroupDay}
+		if v, ok := c.GetQuery("group_by"); ok {
+			dims, err := ledger.ParseGroupBy(v)
+			if err != nil {
+				sendOpenAIError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			groupBy = dims
+		}
+		for _, param := range []string{"from", "to"} {
+			if v := c.Query(param); v != "" {
+				if _, err := time.Parse(ledger.DayFormat, v); err != nil {
+					sendOpenAIError(c, http.StatusBadRequest, "Invalid '"+param+"' date: expected YYYY-MM-DD")
+					return
+				}
+			}
+		}
+
+		rows, total := ledger.Default.Query(ledger.Query{
+			GroupBy:   groupBy,
+			Session:   c.Query("session"),
+			ClientKey: c.Query("client_key"),
+			Model:     c.Query("model"),
+			From:      c.Query("from"),
+			To:        c.Query("to"),
+		})
+		if groupBy == nil {
+			groupBy = []string{}
+		}
+		c.JSON(http.StatusOK, UsageResponse{Object: "list", GroupBy: groupBy, Data: rows, Total: total})
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-proxy/auth"
+	"ai-proxy/capture"
+	"ai-proxy/config"
+	"ai-proxy/ledger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withLedger gives the test a fresh ledger.Default.
+func withLedger(t *testing.T) {
+	t.Helper()
+	old := ledger.Default
+	ledger.Default = ledger.NewLedger()
+	t.Cleanup(func() { ledger.Default = old })
+}
+
+// usagePricing prices usageStream's 8 uncached input, 4 cached and 5 output tokens at $20.
+var usagePricing = map[string]config.ModelPrice{"gpt-4": {Input: 1e6, Output: 2e6, CacheRead: 0.5e6}}
+
+// serveInSession runs a request through a handler as the capture middleware
+// would, with the given session header, authenticated as id if non-nil.
+func serveInSession(id *auth.Identity, handler gin.HandlerFunc, session, body string) *mockResponseWriter {
+	w := newMockResponseWriter()
+	c, _ := gin.CreateTestContext(w)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	if session != "" {
+		req.Header.Set("X-Session-ID", session)
+	}
+	ctx := capture.WithCaptureContext(req.Context(), capture.NewCaptureContext(req))
+	if id != nil {
+		ctx = auth.WithIdentity(ctx, id)
+	}
+	c.Request = req.WithContext(ctx)
+	handler(c)
+	return w
+}
+
+func TestHandle_ReportsCostAsTrailer(t *testing.T) {
+	withRateLimits(t)
+	withLedger(t)
+	usageStream(t)
+	handler := NewCompletionsHandler(&config.Config{}, newLimitedRouter(t, nil, usagePricing))
+
+	w := serveInSession(nil, handler, "", limitedChatBody)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	res := w.Result()
+	if res.Header.Get("Trailer") != CostHeader {
+		t.Errorf("Trailer = %q, want %s", res.Header.Get("Trailer"), CostHeader)
+	}
+	if got := res.Trailer.Get(CostHeader); got != "20" {
+		t.Errorf("%s trailer = %q, want 20", CostHeader, got)
+	}
+}
+
+func TestHandle_ReportsCostAsHeaderWhenAggregating(t *testing.T) {
+	withRateLimits(t)
+	withLedger(t)
+	usageStream(t)
+	handler := NewCompletionsHandler(&config.Config{}, newLimitedRouter(t, nil, usagePricing))
+
+	w := serveInSession(nil, handler, "", `{"model":"gpt-4","stream":false,"messages":[{"role":"user","content":"hi"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	res := w.Result()
+	if got := res.Header.Get(CostHeader); got != "20" {
+		t.Errorf("%s header = %q, want 20", CostHeader, got)
+	}
+	if res.Header.Get("Trailer") != "" {
+		t.Errorf("unexpected Trailer header %q", res.Header.Get("Trailer"))
+	}
+}
+
+func TestHandle_RecordsUsageInLedger(t *testing.T) {
+	withRateLimits(t)
+	withLedger(t)
+	usageStream(t)
+	handler := NewCompletionsHandler(&config.Config{}, newLimitedRouter(t, nil, usagePricing))
+	alice := &auth.Identity{Name: "alice-laptop", User: "alice"}
+
+	serveInSession(alice, handler, "sess-1", limitedChatBody)
+	serveInSession(alice, handler, "sess-1", limitedChatBody)
+	serveInSession(&auth.Identity{User: "bob"}, handler, "", limitedChatBody)
+
+	rows, total := ledger.Default.Query(ledger.Query{GroupBy: []string{ledger.GroupSession, ledger.GroupClientKey, ledger.GroupModel}})
+	want := []ledger.Row{
+		{Key: ledger.Key{ClientKey: "bob", Model: "gpt-4"},
+			Totals: ledger.Totals{Requests: 1, InputTokens: 8, OutputTokens: 5, CacheReadTokens: 4, Cost: 20}},
+		{Key: ledger.Key{Session: "sess-1", ClientKey: "alice-laptop", Model: "gpt-4"},
+			Totals: ledger.Totals{Requests: 2, InputTokens: 16, OutputTokens: 10, CacheReadTokens: 8, Cost: 40}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("ledger rows = %+v, want %+v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+	if total.Requests != 3 || total.Cost != 60 {
+		t.Errorf("total = %+v, want 3 requests costing 60", total)
+	}
+}
+
+func TestHandle_FailedUpstreamRequestIsNotRecorded(t *testing.T) {
+	withRateLimits(t)
+	withLedger(t)
+	withFakeUpstreamClient(t, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"upstream failed"}}`)),
+			Header:     make(http.Header),
+		}, nil
+	})
+	handler := NewCompletionsHandler(&config.Config{}, newLimitedRouter(t, nil, usagePricing))
+
+	w := serveInSession(&auth.Identity{Name: "alice-laptop"}, handler, "sess-1", limitedChatBody)
+	if w.Code < http.StatusBadRequest {
+		t.Fatalf("expected an error status, got %d", w.Code)
+	}
+	if rows, total := ledger.Default.Query(ledger.Query{}); len(rows) != 0 || total.Requests != 0 {
+		t.Errorf("ledger = %+v, %+v; want the failed request not recorded", rows, total)
+	}
+}
+
+func TestUsageHandler(t *testing.T) {
+	withLedger(t)
+	ledger.Default.Record("s1", "alice-laptop", "gpt-4", ledger.Totals{Requests: 1, InputTokens: 10, Cost: 1.5})
+	ledger.Default.Record("s2", "alice-laptop", "claude", ledger.Totals{Requests: 1, OutputTokens: 3, Cost: 2})
+	ledger.Default.Record("s3", "bob-ci", "gpt-4", ledger.Totals{Requests: 1, InputTokens: 5, Cost: 0.5})
+
+	engine := gin.New()
+	engine.GET("/admin/usage", NewUsageHandler())
+	get := func(query string) (int, UsageResponse) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/usage"+query, nil))
+		var resp UsageResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	code, resp := get("")
+	if code != http.StatusOK || len(resp.Data) != 3 || len(resp.GroupBy) != 4 || resp.Total.Cost != 4 {
+		t.Errorf("default query: status %d, %+v; want 3 rows grouped by all dimensions costing 4", code, resp)
+	}
+
+	code, resp = get("?group_by=model&client_key=alice-laptop")
+	if code != http.StatusOK || len(resp.Data) != 2 || resp.Data[0].Model != "claude" || resp.Data[0].Session != "" || resp.Total.Cost != 3.5 {
+		t.Errorf("grouped query: status %d, %+v; want alice's two models costing 3.5", code, resp)
+	}
+
+	code, resp = get("?group_by=")
+	if code != http.StatusOK || len(resp.Data) != 1 || resp.Data[0].Requests != 3 {
+		t.Errorf("ungrouped query: status %d, %+v; want a single row of 3 requests", code, resp)
+	}
+
+	if code, _ := get("?group_by=provider"); code != http.StatusBadRequest {
+		t.Errorf("unknown group_by: expected status 400, got %d", code)
+	}
+	if code, _ := get("?from=yesterday"); code != http.StatusBadRequest {
+		t.Errorf("invalid from date: expected status 400, got %d", code)
+	}
+}
+
+func TestAdminMiddleware_RequiresAdminKey(t *testing.T) {
+	engine := gin.New()
+	engine.Use(NewAuthMiddleware(auth.New(&config.AuthConfig{Keys: []config.ClientKey{
+		{Key: "sk-alice", User: "alice"},
+		{Key: "sk-ops", User: "ops", Admin: true},
+	}})))
+	engine.GET("/admin/usage", NewAdminMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for key, want := range map[string]int{"sk-alice": http.StatusForbidden, "sk-ops": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/usage", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", key, want, w.Code)
+		}
+	}
+}
@@ -4,6 +4,8 @@
 package api
 
 import (
+	"sync"
+
 	"ai-proxy/capture"
 	"ai-proxy/logging"
 
@@ -19,6 +21,9 @@ type CaptureMiddleware struct {
 	// storage is the backend for persisting captured request/response data.
 	// May be nil to disable capture. Thread-safe for concurrent writes.
 	storage *capture.Storage
+
+	// pending tracks asynchronous writes that have not finished yet.
+	pending sync.WaitGroup
 }
 
 // NewCaptureMiddleware creates a new CaptureMiddleware with the given storage backend.
@@ -66,12 +71,14 @@ func (m *CaptureMiddleware) Handler() gin.HandlerFunc {
 
 		// Write captured data asynchronously to avoid blocking the response
 		// Goroutine is safe because capture context is self-contained
+		// Early exit if storage is not configured
+		// This allows capture to be disabled without code changes
+		if m.storage == nil {
+			return
+		}
+		m.pending.Add(1)
 		go func() {
-			// Early exit if storage is not configured
-			// This allows capture to be disabled without code changes
-			if m.storage == nil {
-				return
-			}
+			defer m.pending.Done()
 			// Log any errors to help with debugging
 			if err := m.storage.Write(cc.Recorder); err != nil {
 				logging.ErrorMsg("Failed to write capture: %v", err)
@@ -80,6 +87,15 @@ func (m *CaptureMiddleware) Handler() gin.HandlerFunc {
 	}
 }
 
+// Wait blocks until every captured request has been written to storage.
+// Called at shutdown, once the server has stopped serving requests, so no
+// capture is lost on exit.
+//
+// @pre No request is being served, or more writes may start after Wait returns.
+func (m *CaptureMiddleware) Wait() {
+	m.pending.Wait()
+}
+
 // InitStorage creates a new capture storage instance if a base directory is provided.
 // Returns nil if no directory is specified, effectively disabling capture.
 //
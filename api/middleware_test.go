@@ -218,6 +218,28 @@ func TestCaptureMiddleware_WithStorage_WritesToFile(t *testing.T) {
 	}
 }
 
+func TestCaptureMiddleware_Wait(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewCaptureMiddleware(capture.NewStorage(tmpDir))
+	handler := m.Handler()
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/test", nil)
+		handler(c)
+	}
+	m.Wait()
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, "*", "*.json"))
+	if err != nil {
+		t.Fatalf("failed to list captures: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 captures written after Wait, got %d", len(files))
+	}
+}
+
 func TestCaptureMiddleware_Handler_MultipleRequests(t *testing.T) {
 	m := NewCaptureMiddleware(nil)
 	handler := m.Handler()
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"ai-proxy/auth"
+	"ai-proxy/config"
+	"ai-proxy/logging"
+	"ai-proxy/router"
+	"ai-proxy/summarizer"
+	"ai-proxy/websearch"
+)
+
+// Reload replaces the running configuration with a reloaded, validated schema.
+// The model router, client keys, summarizer and web search service are
+// swapped for ones built from schema; requests that already resolved their
+// routes finish on the old ones.
+//
+// @param schema - the reloaded schema, validated by config.Loader
+// @return error - if the server was started without a config file
+//
+// @note Load-balancer ejections, circuit breaker states and connection pools start
+// afresh with the new router; idle connections of the old pools are closed.
+// @note Listeners, and the port, capture, and conversation store settings from
+// flags, need a restart.
+func (s *Server) Reload(schema *config.Schema) error {
+	if s.routes == nil {
+		return errors.New("server was started without a configuration")
+	}
+	r, err := router.NewRouter(schema)
+	if err != nil {
+		return err
+	}
+
+	old := s.config.GetSchema()
+	if old != nil && !reflect.DeepEqual(old.Listeners, schema.Listeners) {
+		logging.InfoMsg("Listener changes in the reloaded config take effect after a restart")
+	}
+	reloadServices(old, schema)
+	s.routes.Swap(r)
+	s.authenticator.Store(auth.New(schema.Auth))
+	s.config.SetSchema(schema)
+	return nil
+}
+
+// WatchConfig reloads the config file on SIGHUP and when it changes, until
+// ctx is done. It returns at once if the server was started without a config file.
+//
+// @param ctx - stops watching when done
+func (s *Server) WatchConfig(ctx context.Context) {
+	if s.watcher != nil {
+		s.watcher.Run(ctx)
+	}
+}
+
+// reloadServices replaces the summarizer and web search services if the
+// reloaded schema changes their configuration. Unchanged services are kept,
+// so a local summarizer model is not loaded again.
+//
+// @param old - the running schema, nil if none
+// @param schema - the reloaded schema
+func reloadServices(old, schema *config.Schema) {
+	if old == nil || !reflect.DeepEqual(old.WebSearch, schema.WebSearch) {
+		websearch.SetDefaultService(websearch.InitDefaultService(schema.WebSearch))
+	}
+	if old == nil || !reflect.DeepEqual(old.Summarizer, schema.Summarizer) ||
+		!reflect.DeepEqual(summarizerProvider(old), summarizerProvider(schema)) {
+		summarizer.InitDefaultService(schema)
+	}
+}
+
+// summarizerProvider returns the provider the summarizer of s calls, if any.
+func summarizerProvider(s *config.Schema) *config.Provider {
+	for i := range s.Providers {
+		if s.Providers[i].Name == s.Summarizer.Provider {
+			return &s.Providers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-proxy/api/handlers"
+	"ai-proxy/config"
+)
+
+// reloadSchema returns a schema serving the given model aliases.
+func reloadSchema(aliases ...string) *config.Schema {
+	s := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "provider-key"},
+		},
+		Models: map[string]config.ModelConfig{},
+	}
+	for _, alias := range aliases {
+		s.Models[alias] = config.ModelConfig{Provider: "openai", Model: alias + "-upstream"}
+	}
+	return s
+}
+
+// listModels returns the status and model IDs of GET /v1/models.
+func listModels(t *testing.T, s *Server, key string) (int, []string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	s.router.ServeHTTP(w, req)
+
+	var resp handlers.ModelsResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse models: %v", err)
+		}
+	}
+	var ids []string
+	for _, m := range resp.Data {
+		ids = append(ids, m.ID)
+	}
+	return w.Code, ids
+}
+
+func TestServer_Reload(t *testing.T) {
+	cfg := &config.Config{AppConfig: reloadSchema("fast")}
+	server := NewServer(cfg)
+
+	reloaded := reloadSchema("smart")
+	reloaded.Auth = &config.AuthConfig{Keys: []config.ClientKey{{Key: "client-key", User: "alice"}}}
+	if err := server.Reload(reloaded); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+
+	if cfg.GetSchema() != reloaded {
+		t.Error("Reload() should replace the config schema")
+	}
+	route, err := server.modelRouter.Resolve("smart")
+	if err != nil || route.Model != "smart-upstream" {
+		t.Errorf("Resolve(smart) after Reload = %+v, %v; want smart-upstream", route, err)
+	}
+	if code, _ := listModels(t, server, ""); code != http.StatusUnauthorized {
+		t.Errorf("reloaded auth section: expected status 401 without key, got %d", code)
+	}
+	if code, ids := listModels(t, server, "client-key"); code != http.StatusOK || len(ids) != 1 || ids[0] != "smart" {
+		t.Errorf("reloaded models: status %d, models %v; want [smart]", code, ids)
+	}
+
+	// Removing the auth section opens the API again
+	if err := server.Reload(reloadSchema("fast")); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if code, ids := listModels(t, server, ""); code != http.StatusOK || len(ids) != 1 || ids[0] != "fast" {
+		t.Errorf("after removing auth: status %d, models %v; want [fast]", code, ids)
+	}
+}
+
+func TestServer_Reload_WithoutConfig(t *testing.T) {
+	server := NewServer(&config.Config{})
+	if err := server.Reload(reloadSchema("fast")); err == nil {
+		t.Error("Reload() expected error for a server started without a configuration")
+	}
+}
+
+func TestServer_Routes_AdminReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+	schema := reloadSchema("fast")
+	schema.Auth = &config.AuthConfig{Keys: []config.ClientKey{{Key: "admin-key", User: "ops", Admin: true}}}
+	cfg := &config.Config{ConfigFile: path, AppConfig: schema}
+	server := NewServer(cfg)
+	reload := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// An invalid file is reported and the running configuration kept
+	writeFile(`{"providers": [{"name": "openai", "endpoints": {"openai": "https://api.example.com"}}]}`)
+	w := reload()
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "at least one of apiKey or envApiKey is required") {
+		t.Errorf("invalid config: expected 422 with validation error, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ids := listModels(t, server, "admin-key"); len(ids) != 1 || ids[0] != "fast" {
+		t.Errorf("invalid config must keep the running models, got %v", ids)
+	}
+
+	writeFile(`{
+		"providers": [{"name": "openai", "endpoints": {"openai": "https://api.example.com"}, "apiKey": "key"}],
+		"models": {"smart": {"provider": "openai", "model": "gpt-4o"}}
+	}`)
+	if w := reload(); w.Code != http.StatusOK {
+		t.Fatalf("valid config: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ids := listModels(t, server, ""); len(ids) != 1 || ids[0] != "smart" {
+		t.Errorf("reloaded models = %v, want [smart]", ids)
+	}
+}
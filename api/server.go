@@ -4,8 +4,16 @@
 package api
 
 import (
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+
 	"ai-proxy/api/handlers"
+	"ai-proxy/auth"
 	"ai-proxy/config"
+	"ai-proxy/events"
+	"ai-proxy/logging"
 	"ai-proxy/router"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +40,21 @@ type Server struct {
 	// modelRouter resolves model names to providers.
 	// May be nil if no config file was loaded.
 	modelRouter router.Router
+
+	// routes is modelRouter as a swappable router, replaced by Reload.
+	// Nil if no config file was loaded.
+	routes *router.Swappable
+
+	// authenticator resolves client API keys to identities.
+	// Holds nil if the config has no auth section, which leaves the API open.
+	authenticator atomic.Pointer[auth.Authenticator]
+
+	// watcher reloads the config file into the server.
+	// Nil if no config file was loaded.
+	watcher *config.Watcher
+
+	// httpServer serves the router once Run or Serve has been called; Shutdown stops it.
+	httpServer atomic.Pointer[http.Server]
 }
 
 // NewServer creates and initializes a new Server instance with the given configuration.
@@ -60,11 +83,16 @@ func NewServer(cfg *config.Config, middleware ...gin.HandlerFunc) *Server {
 		config: cfg,
 	}
 
-	// Create model router if config is loaded
+	// Create model router if config is loaded; reloads swap it for a new one
 	if cfg.AppConfig != nil {
 		if r, err := router.NewRouter(cfg.AppConfig); err == nil {
-			s.modelRouter = r
+			s.routes = router.NewSwappable(r)
+			s.modelRouter = s.routes
+		} else {
+			logging.ErrorMsg("Failed to create model router: %v", err)
 		}
+		s.authenticator.Store(auth.New(cfg.AppConfig.Auth))
+		s.watcher = config.NewWatcher(cfg.ConfigFile, cfg.ConfigWatchInterval, s.Reload)
 	}
 
 	// Apply middleware first so it runs before routes
@@ -87,37 +115,104 @@ func NewServer(cfg *config.Config, middleware ...gin.HandlerFunc) *Server {
 func (s *Server) setupRoutes() {
 	// Health check endpoint - used by load balancers and monitoring systems
 	// to verify service availability. Does not require authentication.
-	s.router.GET("/health", handlers.HealthCheck)
+	// With ?detailed=true, it also reports each provider's circuit breaker.
+	if s.modelRouter != nil {
+		s.router.GET("/health", handlers.NewHealthHandler(s.modelRouter))
+	} else {
+		s.router.GET("/health", handlers.HealthCheck)
+	}
+
+	// Metrics endpoint - Prometheus text format counters and histograms of
+	// proxy traffic, collected whether or not request capture is enabled.
+	s.router.GET("/metrics", handlers.NewMetricsHandler())
+
+	// All /v1 and /admin endpoints require a client API key when the config
+	// has an auth section; health and metrics stay open for monitoring.
+	api := s.router.Group("")
+	api.Use(s.authenticate)
 
 	// Models endpoint - returns list of available models from upstream API
 	// Supports OpenAI-compatible response format.
-	s.router.GET("/v1/models", handlers.NewModelsHandler(s.config))
+	api.GET("/v1/models", handlers.NewModelsHandler(s.config))
 
 	// Chat completions endpoint - unified OpenAI Chat format endpoint
 	// Routes to the appropriate provider based on model configuration.
 	// Supports OpenAI and Anthropic providers with automatic format conversion.
-	s.router.POST("/v1/chat/completions", handlers.NewCompletionsHandler(s.config, s.modelRouter))
+	api.POST("/v1/chat/completions", handlers.NewCompletionsHandler(s.config, s.modelRouter))
 
 	// Messages endpoint - unified Anthropic Messages format endpoint
 	// Routes to the appropriate provider based on model configuration.
 	// Supports Anthropic and OpenAI providers with automatic format conversion.
-	s.router.POST("/v1/messages", handlers.NewMessagesHandler(s.config, s.modelRouter))
+	api.POST("/v1/messages", handlers.NewMessagesHandler(s.config, s.modelRouter))
 
 	// Messages count tokens endpoint - Anthropic API format endpoint
 	// for counting tokens in messages before sending to upstream.
-	s.router.POST("/v1/messages/count_tokens", handlers.NewCountTokensHandler(s.config, s.modelRouter))
+	api.POST("/v1/messages/count_tokens", handlers.NewCountTokensHandler(s.config, s.modelRouter))
 
 	// Responses endpoint - unified OpenAI Responses API endpoint that routes to the
 	// appropriate provider based on model configuration.
 	if s.modelRouter != nil {
-		s.router.POST("/v1/responses", handlers.NewResponsesHandler(s.config, s.modelRouter))
+		api.POST("/v1/responses", handlers.NewResponsesHandler(s.config, s.modelRouter))
+	}
+
+	// Ollama endpoints - the Ollama chat and generate API over any configured
+	// model, streamed as NDJSON, for local tools that only speak Ollama.
+	if s.modelRouter != nil {
+		api.POST("/api/chat", handlers.NewOllamaChatHandler(s.config, s.modelRouter))
+		api.POST("/api/generate", handlers.NewOllamaGenerateHandler(s.config, s.modelRouter))
+	}
+	api.GET("/api/tags", handlers.NewOllamaTagsHandler(s.config))
+	api.POST("/api/show", handlers.NewOllamaShowHandler(s.config))
+
+	// Admin endpoints are for operators: only admin keys may use them, so
+	// without authentication they are closed
+	admin := api.Group("/admin", handlers.NewAdminMiddleware())
+
+	// Balancer endpoint - per-key in-flight, failure and ejection counters
+	// of the load-balanced provider API keys, for operators.
+	if s.modelRouter != nil {
+		admin.GET("/balancer", handlers.NewBalancerHandler(s.modelRouter))
+
+		// Connections endpoint - new and reused upstream connections of each
+		// provider's shared connection pool.
+		admin.GET("/connections", handlers.NewConnectionsHandler(s.modelRouter))
+	}
+
+	// Usage endpoint - tokens and cost of past requests from the usage ledger,
+	// grouped by session, client key, model and day.
+	admin.GET("/usage", handlers.NewUsageHandler())
+
+	// Streams endpoints - every in-flight request with its client, route and
+	// progress so far; any of them can be cancelled by stream or response ID.
+	admin.GET("/streams", handlers.NewStreamsHandler())
+	admin.GET("/streams/:id", handlers.NewStreamHandler())
+	admin.POST("/streams/:id/cancel", handlers.NewStreamCancelHandler())
+
+	// Events endpoint - live request lifecycle events as SSE, filtered by
+	// session, model or client; rendered by `ai-proxy tail`.
+	admin.GET("/events", handlers.NewEventsHandler(events.Default))
+
+	// Reload endpoint - reloads the config file like SIGHUP and reports
+	// validation errors, which keep the running configuration.
+	if s.watcher != nil {
+		admin.POST("/reload", handlers.NewReloadHandler(s.watcher.Reload))
 	}
 
 	// Responses CRUD endpoints - for managing stored conversations
-	s.router.GET("/v1/responses/:id", handlers.NewResponseGetHandler())
-	s.router.DELETE("/v1/responses/:id", handlers.NewResponseDeleteHandler())
-	s.router.GET("/v1/responses/:id/input_items", handlers.NewResponseInputItemsHandler())
-	s.router.POST("/v1/responses/:id/cancel", handlers.NewResponseCancelHandler())
+	api.GET("/v1/responses/:id", handlers.NewResponseGetHandler())
+	api.DELETE("/v1/responses/:id", handlers.NewResponseDeleteHandler())
+	api.GET("/v1/responses/:id/input_items", handlers.NewResponseInputItemsHandler())
+	api.POST("/v1/responses/:id/cancel", handlers.NewResponseCancelHandler())
+}
+
+// authenticate authenticates the client with the current authenticator.
+// Without one, every request passes.
+//
+// @param c - Gin context of the request.
+func (s *Server) authenticate(c *gin.Context) {
+	if a := s.authenticator.Load(); a != nil {
+		handlers.NewAuthMiddleware(a)(c)
+	}
 }
 
 // Use adds middleware to the server's router chain.
@@ -142,11 +237,49 @@ func (s *Server) Use(middleware ...gin.HandlerFunc) {
 //
 // @return Error if server fails to start or encounters a fatal error.
 //
-//	Returns nil only if server shuts down cleanly (rare).
+//	Returns nil once Shutdown has been called; Shutdown itself returns when
+//	the active streams have been drained.
 //
 // @pre s.router != nil
 // @post HTTP server is running and accepting connections (until error occurs).
 // @note This is a blocking call. Use in goroutine if non-blocking start needed.
 func (s *Server) Run(addr string) error {
-	return s.router.Run(addr)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on all listeners until the server fails or is
+// shut down. Listeners returning TLS connections that offer "h2" also serve
+// HTTP/2.
+//
+// @param listeners - the listeners to serve; closed when Serve returns
+// @return error - nil after Shutdown, otherwise why serving a listener failed
+//
+// @note Blocks like Run; Shutdown stops all listeners at once.
+func (s *Server) Serve(listeners ...net.Listener) error {
+	srv := &http.Server{Handler: s.router.Handler()}
+	// Event streams never end on their own; they must not hold up a shutdown
+	srv.RegisterOnShutdown(events.Default.Disconnect)
+	s.httpServer.Store(srv)
+
+	errc := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() {
+			errc <- srv.Serve(ln)
+		}()
+	}
+	var first error
+	for range listeners {
+		err := <-errc
+		if errors.Is(err, http.ErrServerClosed) || first != nil {
+			continue
+		}
+		// One failed listener takes the others down with it
+		first = err
+		srv.Close()
+	}
+	return first
 }
@@ -2,8 +2,11 @@ package api
 
 import (
 	"ai-proxy/config"
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -94,10 +97,18 @@ func TestServer_setupRoutes(t *testing.T) {
 		path   string
 	}{
 		{method: "GET", path: "/health"},
+		{method: "GET", path: "/metrics"},
 		{method: "GET", path: "/v1/models"},
 		{method: "POST", path: "/v1/chat/completions"},
 		{method: "POST", path: "/v1/messages"},
 		{method: "POST", path: "/v1/messages/count_tokens"},
+		{method: "GET", path: "/api/tags"},
+		{method: "POST", path: "/api/show"},
+		{method: "GET", path: "/admin/usage"},
+		{method: "GET", path: "/admin/streams"},
+		{method: "GET", path: "/admin/streams/:id"},
+		{method: "POST", path: "/admin/streams/:id/cancel"},
+		{method: "GET", path: "/admin/events"},
 	}
 
 	for _, expected := range expectedRoutes {
@@ -122,16 +133,24 @@ func TestServer_setupRoutes_RouteCount(t *testing.T) {
 
 	// Routes when modelRouter is nil:
 	// GET /health
+	// GET /metrics
 	// GET /v1/models
 	// POST /v1/chat/completions
 	// POST /v1/messages
 	// POST /v1/messages/count_tokens
+	// GET /api/tags
+	// POST /api/show
 	// GET /v1/responses/:id
 	// DELETE /v1/responses/:id
 	// GET /v1/responses/:id/input_items
 	// POST /v1/responses/:id/cancel
-	// Note: POST /v1/responses is only added when modelRouter is not nil
-	expectedCount := 9
+	// GET /admin/usage
+	// GET /admin/streams
+	// GET /admin/streams/:id
+	// POST /admin/streams/:id/cancel
+	// GET /admin/events
+	// Note: POST /v1/responses, /api/chat and /api/generate are only added when modelRouter is not nil
+	expectedCount := 17
 	if len(routes) != expectedCount {
 		t.Errorf("expected %d routes, got %d", expectedCount, len(routes))
 	}
@@ -247,6 +266,94 @@ func TestServer_Routes_Models_WithAPIKey(t *testing.T) {
 	}
 }
 
+func TestServer_Routes_ClientAuth(t *testing.T) {
+	cfg := &config.Config{
+		AppConfig: &config.Schema{
+			Providers: []config.Provider{
+				{
+					Name:      "openai",
+					Endpoints: map[string]string{"openai": "https://api.example.com/v1"},
+					APIKey:    "provider-key",
+				},
+			},
+			Auth: &config.AuthConfig{Keys: []config.ClientKey{
+				{Key: "client-key", User: "alice"},
+				{Key: "admin-key", User: "ops", Admin: true},
+			}},
+		},
+	}
+	server := NewServer(cfg)
+
+	tests := []struct {
+		path string
+		key  string
+		want int
+	}{
+		{path: "/health", want: http.StatusOK},
+		{path: "/metrics", want: http.StatusOK},
+		{path: "/v1/models", want: http.StatusUnauthorized},
+		{path: "/admin/balancer", want: http.StatusUnauthorized},
+		{path: "/v1/models", key: "provider-key", want: http.StatusUnauthorized},
+		{path: "/v1/models", key: "client-key", want: http.StatusOK},
+		{path: "/admin/usage", key: "client-key", want: http.StatusForbidden},
+		{path: "/admin/usage", key: "admin-key", want: http.StatusOK},
+		{path: "/admin/balancer", key: "admin-key", want: http.StatusOK},
+		{path: "/admin/connections", key: "client-key", want: http.StatusForbidden},
+		{path: "/admin/connections", key: "admin-key", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		server.router.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("GET %s with key %q: expected status %d, got %d", tt.path, tt.key, tt.want, w.Code)
+		}
+	}
+}
+
+func TestServer_Routes_AdminWithoutAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := &config.Config{
+		ConfigFile: path,
+		AppConfig: &config.Schema{
+			Providers: []config.Provider{
+				{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1"}, APIKey: "provider-key"},
+			},
+		},
+	}
+	server := NewServer(cfg)
+
+	// Without auth no client holds an admin key, so the admin API is closed
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/admin/streams"},
+		{http.MethodGet, "/admin/streams/req_1"},
+		{http.MethodPost, "/admin/streams/req_1/cancel"},
+		{http.MethodPost, "/admin/reload"},
+		{http.MethodGet, "/admin/usage"},
+		{http.MethodGet, "/admin/events"},
+		{http.MethodGet, "/admin/balancer"},
+		{http.MethodGet, "/admin/connections"},
+	}
+	for _, r := range routes {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(r.method, r.path, nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s without auth: expected status %d, got %d", r.method, r.path, http.StatusForbidden, w.Code)
+		}
+	}
+
+	// The rest of the API stays open
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /v1/models without auth: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
 func TestServer_Routes_Completions_InvalidMethod(t *testing.T) {
 	cfg := &config.Config{}
 	server := NewServer(cfg)
@@ -336,6 +443,45 @@ func TestServer_Run_ValidAddress(t *testing.T) {
 	}
 }
 
+func TestServer_Serve_MultipleListeners(t *testing.T) {
+	server := NewServer(&config.Config{})
+	var listeners []net.Listener
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		listeners = append(listeners, ln)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listeners...)
+	}()
+
+	for _, ln := range listeners {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/health")
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", ln.Addr(), err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d from %s, got %d", http.StatusOK, ln.Addr(), resp.StatusCode)
+		}
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected Serve to return nil after Shutdown, got %v", err)
+	}
+	for _, ln := range listeners {
+		if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+			t.Errorf("expected %s to be closed", ln.Addr())
+		}
+	}
+}
+
 func TestNewServer_SetsConfigCorrectly(t *testing.T) {
 	cfg := &config.Config{
 		AppConfig: &config.Schema{
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"ai-proxy/api/handlers"
+	"ai-proxy/logging"
+	"ai-proxy/stream"
+)
+
+// interruptGrace is how long streams interrupted at the drain deadline get to
+// send their final events before their connections are closed.
+const interruptGrace = 5 * time.Second
+
+// Shutdown gracefully stops the server. It stops accepting connections and
+// waits for active requests, streams included, to finish until ctx is done.
+// Streams still running then are interrupted: their transformers' HandleCancel
+// ends them with e.g. response.cancelled or message_stop, rather than cutting
+// clients off mid-token. Connections still open after interruptGrace are closed.
+//
+// @param ctx - the drain deadline
+// @return error - if connections had to be closed forcibly
+//
+// @post Run has returned, and no handler is running unless an error is returned.
+// @note Capture writes and the conversation store are flushed by the caller,
+// once Shutdown has returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	srv := s.httpServer.Load()
+	if srv == nil {
+		return nil
+	}
+
+	err := srv.Shutdown(ctx)
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	if n := handlers.GetGlobalRegistry().Interrupt(stream.ErrShutdown); n > 0 {
+		logging.InfoMsg("Shutdown deadline reached, interrupting %d active streams", n)
+	}
+	graceCtx, cancel := context.WithTimeout(context.Background(), interruptGrace)
+	defer cancel()
+	if err := srv.Shutdown(graceCtx); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/api/handlers"
+	"ai-proxy/config"
+)
+
+// slowUpstream serves a Responses API stream that sends its first text delta,
+// then waits for release before completing. Without release it streams until
+// the proxy cancels the request.
+func slowUpstream(t *testing.T, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"response.created","response":{"id":"resp_1","model":"gpt-4o","status":"in_progress","output":[]}}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"response.output_item.added","output_index":0,"item":{"type":"message","id":"msg_1","role":"assistant"}}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"Hello"}`+"\n\n")
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, `data: {"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":" world"}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[],"usage":{"input_tokens":3,"output_tokens":2,"total_tokens":5}}}`+"\n\n")
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// testAdminKey is the client key of the servers started by
+// startShutdownServer. It is an admin key, so tests can also use /admin.
+const testAdminKey = "admin-key"
+
+// adminRequest sends a request authenticated with testAdminKey.
+func adminRequest(t *testing.T, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return resp
+}
+
+// startShutdownServer serves a proxy for upstream on a local port, with the
+// capture middleware as in main.
+func startShutdownServer(t *testing.T, upstream *httptest.Server) (*Server, string, <-chan error) {
+	t.Helper()
+	cfg := &config.Config{AppConfig: &config.Schema{
+		Providers: []config.Provider{
+			{Name: "openai", Endpoints: map[string]string{"responses": upstream.URL + "/v1/responses"}, APIKey: "provider-key"},
+		},
+		Models: map[string]config.ModelConfig{"fast": {Provider: "openai", Model: "gpt-4o"}},
+		Auth:   &config.AuthConfig{Keys: []config.ClientKey{{Key: testAdminKey, User: "ops", Admin: true}}},
+	}}
+	server := NewServer(cfg, NewCaptureMiddleware(nil).Handler())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ln)
+	}()
+	return server, "http://" + ln.Addr().String(), served
+}
+
+// openStream starts a streaming Messages request and returns its body once the
+// first text delta has arrived, with the events read so far.
+func openStream(t *testing.T, baseURL string) (io.ReadCloser, string) {
+	t.Helper()
+	body := `{"model":"fast","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/v1/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", testAdminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var read strings.Builder
+	reader := bufio.NewReader(resp.Body)
+	for !strings.Contains(read.String(), "Hello") {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			resp.Body.Close()
+			t.Fatalf("stream ended before the first delta: %v\n%s", err, read.String())
+		}
+		read.WriteString(line)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}, read.String()
+}
+
+func TestServer_Shutdown_DrainsActiveStreams(t *testing.T) {
+	release := make(chan struct{})
+	server, baseURL, served := startShutdownServer(t, slowUpstream(t, release))
+
+	body, _ := openStream(t, baseURL)
+	defer body.Close()
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(context.Background())
+	}()
+	if err := <-served; err != nil {
+		t.Errorf("serve returned error after Shutdown: %v", err)
+	}
+
+	// No new connections are accepted while the stream drains
+	if _, err := http.Get(baseURL + "/health"); err == nil {
+		t.Error("expected new connections to be refused during shutdown")
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the stream finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	rest, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if !strings.Contains(string(rest), " world") || !strings.Contains(string(rest), "event: message_stop") {
+		t.Errorf("drained stream should finish normally, got:\n%s", rest)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() error: %v", err)
+	}
+}
+
+func TestServer_Shutdown_InterruptsStreamsAtDeadline(t *testing.T) {
+	server, baseURL, served := startShutdownServer(t, slowUpstream(t, nil))
+
+	body, _ := openStream(t, baseURL)
+	defer body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve returned error after Shutdown: %v", err)
+	}
+
+	// The interrupted stream ends with the transformer's final events
+	rest, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if strings.Contains(string(rest), " world") || !strings.Contains(string(rest), "event: message_stop") {
+		t.Errorf("interrupted stream should end with message_stop, got:\n%s", rest)
+	}
+	if n := handlers.GetGlobalRegistry().Len(); n != 0 {
+		t.Errorf("expected no registered streams after shutdown, got %d", n)
+	}
+}
+
+func TestServer_Shutdown_NotRunning(t *testing.T) {
+	server := NewServer(&config.Config{})
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() on a server that is not running: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/api/handlers"
+	"ai-proxy/stream"
+	"ai-proxy/transform"
+)
+
+func TestServer_AdminStreams_ListAndCancel(t *testing.T) {
+	server, baseURL, _ := startShutdownServer(t, slowUpstream(t, nil))
+	defer server.Shutdown(t.Context())
+
+	body, _ := openStream(t, baseURL)
+	defer body.Close()
+
+	resp := adminRequest(t, http.MethodGet, baseURL+"/admin/streams")
+	var list handlers.StreamsResponse
+	err := json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to parse streams: %v", err)
+	}
+	if len(list.Data) != 1 {
+		t.Fatalf("expected 1 stream, got %+v", list.Data)
+	}
+	info := list.Data[0]
+	if !strings.HasPrefix(info.ID, "req_") || info.Protocol != "anthropic" || info.Model != "fast" ||
+		info.Provider != "openai" || info.UpstreamModel != "gpt-4o" || !info.Streaming {
+		t.Errorf("unexpected stream description: %+v", info)
+	}
+	if info.Phase != stream.PhaseStreaming || info.BytesWritten == 0 ||
+		info.Transformer != "convert.ResponsesToAnthropicStreamingTransformer" || info.TransformerState != transform.StateText {
+		t.Errorf("unexpected stream progress: %+v", info)
+	}
+
+	resp = adminRequest(t, http.MethodPost, baseURL+"/admin/streams/"+info.ID+"/cancel")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cancel: expected status 200, got %d", resp.StatusCode)
+	}
+
+	// The cancelled stream ends with the transformer's final events
+	rest, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if !strings.Contains(string(rest), "event: message_stop") {
+		t.Errorf("cancelled stream should end with message_stop, got:\n%s", rest)
+	}
+
+	resp = adminRequest(t, http.MethodGet, baseURL+"/admin/streams/"+info.ID)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("cancelled stream: expected status 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_AdminStreams_AggregatedProgress(t *testing.T) {
+	release := make(chan struct{})
+	server, baseURL, _ := startShutdownServer(t, slowUpstream(t, release))
+	defer server.Shutdown(t.Context())
+
+	done := make(chan int, 1)
+	go func() {
+		body := `{"model":"fast","max_tokens":16,"stream":false,"messages":[{"role":"user","content":"Hi"}]}`
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", testAdminKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	// Nothing is written to the client before the upstream completes, so the
+	// progress counts the output assembled so far
+	var info stream.Info
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp := adminRequest(t, http.MethodGet, baseURL+"/admin/streams")
+		var list handlers.StreamsResponse
+		err := json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to parse streams: %v", err)
+		}
+		if len(list.Data) == 1 && list.Data[0].BytesWritten > 0 {
+			info = list.Data[0]
+			break
+		}
+	}
+	close(release)
+
+	if info.Streaming || info.Phase != stream.PhaseStreaming || info.BytesWritten <= 0 {
+		t.Errorf("expected the aggregated request to report its progress, got %+v", info)
+	}
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("expected status 200, got %d", status)
+	}
+}
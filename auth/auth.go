@@ -0,0 +1,143 @@
+// Package auth authenticates proxy clients by API key.
+// Each configured key maps to an identity: the user and organization that own
+// the conversations created with it, and the model aliases it may use.
+// Keys are only held as SHA-256 digests once the Authenticator is built.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"ai-proxy/config"
+	"ai-proxy/logging"
+)
+
+// Errors returned by Authenticate.
+var (
+	// ErrMissingKey means the request carried no API key.
+	ErrMissingKey = errors.New("missing API key")
+	// ErrInvalidKey means the request's API key is not configured.
+	ErrInvalidKey = errors.New("invalid API key")
+)
+
+// Identity is the authenticated client of a request.
+type Identity struct {
+	// Name labels the client key, may be empty.
+	Name string
+	// User is the user the key authenticates.
+	User string
+	// Org is the user's organization, may be empty.
+	Org string
+	// Models lists the model aliases the key may use; empty allows all.
+	Models []string
+	// Limits caps the key's use across all models, nil if unlimited.
+	Limits *config.LimitsConfig
+	// Admin grants access to the /admin endpoints.
+	Admin bool
+}
+
+// AllowsModel reports whether the identity may use a model alias.
+// A nil identity (authentication disabled) allows every model.
+func (id *Identity) AllowsModel(model string) bool {
+	if id == nil || len(id.Models) == 0 {
+		return true
+	}
+	return slices.Contains(id.Models, model)
+}
+
+// Authenticator resolves client API keys to identities.
+//
+// Thread Safety: Safe for concurrent use; the key set is fixed at creation.
+type Authenticator struct {
+	keys map[string]*Identity // hex SHA-256 digest -> identity
+}
+
+// New creates an authenticator for the configured client keys.
+// Keys without a value (an unset env_key) are skipped with a warning; if two
+// entries share a key, the first one wins.
+//
+// @param cfg - the auth configuration; nil disables authentication
+// @return *Authenticator - the authenticator, nil if cfg is nil
+func New(cfg *config.AuthConfig) *Authenticator {
+	if cfg == nil {
+		return nil
+	}
+	a := &Authenticator{keys: make(map[string]*Identity, len(cfg.Keys))}
+	for i, k := range cfg.Keys {
+		hash := k.GetKeyHash()
+		if hash == "" {
+			logging.InfoMsg("Warning: auth key %d (%s) has no value and will never match", i, k.Name)
+			continue
+		}
+		if _, exists := a.keys[hash]; exists {
+			logging.InfoMsg("Warning: auth key %d (%s) duplicates an earlier key and is ignored", i, k.Name)
+			continue
+		}
+		a.keys[hash] = &Identity{Name: k.Name, User: k.User, Org: k.Org, Models: k.Models, Limits: k.Limits, Admin: k.Admin}
+	}
+	return a
+}
+
+// Authenticate resolves the API key of a request, taken from
+// "Authorization: Bearer <key>" or else "x-api-key: <key>".
+//
+// @param r - the client request
+// @return *Identity - the key's identity on success
+// @return error - ErrMissingKey or ErrInvalidKey
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := requestKey(r)
+	if key == "" {
+		return nil, ErrMissingKey
+	}
+	id, ok := a.keys[config.HashClientKey(key)]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return id, nil
+}
+
+// requestKey extracts the API key presented by a request.
+func requestKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(r.Header.Get("X-Api-Key"))
+}
+
+// identityKey is the context key of the request's Identity.
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the authenticated identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity attached by WithIdentity.
+//
+// @return *Identity - the identity, nil if the request was not authenticated
+func FromContext(ctx context.Context) *Identity {
+	if ctx == nil {
+		return nil
+	}
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// UserID returns the user of the request's identity, empty if unauthenticated.
+func UserID(ctx context.Context) string {
+	if id := FromContext(ctx); id != nil {
+		return id.User
+	}
+	return ""
+}
+
+// OrgID returns the organization of the request's identity, empty if unauthenticated.
+func OrgID(ctx context.Context) string {
+	if id := FromContext(ctx); id != nil {
+		return id.Org
+	}
+	return ""
+}
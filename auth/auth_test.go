@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-proxy/config"
+)
+
+func newRequest(headers map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	t.Setenv("TEST_AUTH_KEY", "sk-env")
+	a := New(&config.AuthConfig{Keys: []config.ClientKey{
+		{Name: "inline", Key: "sk-inline", User: "alice", Org: "eng", Models: []string{"gpt-4"}},
+		{Name: "env", EnvKey: "TEST_AUTH_KEY", User: "bob"},
+		{Name: "hashed", KeyHash: config.KeyHashPrefix + config.HashClientKey("sk-hashed"), User: "carol"},
+		{Name: "unset", EnvKey: "TEST_AUTH_KEY_MISSING", User: "dave"},
+		{Name: "duplicate", Key: "sk-inline", User: "mallory"},
+	}})
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantUser string
+		wantErr  error
+	}{
+		{name: "bearer inline key", headers: map[string]string{"Authorization": "Bearer sk-inline"}, wantUser: "alice"},
+		{name: "x-api-key env key", headers: map[string]string{"x-api-key": "sk-env"}, wantUser: "bob"},
+		{name: "hashed key", headers: map[string]string{"Authorization": "Bearer sk-hashed"}, wantUser: "carol"},
+		{name: "bearer wins over x-api-key", headers: map[string]string{"Authorization": "Bearer sk-env", "x-api-key": "sk-inline"}, wantUser: "bob"},
+		{name: "missing key", headers: nil, wantErr: ErrMissingKey},
+		{name: "non-bearer authorization", headers: map[string]string{"Authorization": "Basic c2staW5saW5l"}, wantErr: ErrMissingKey},
+		{name: "unknown key", headers: map[string]string{"x-api-key": "sk-unknown"}, wantErr: ErrInvalidKey},
+		{name: "empty env key never matches", headers: map[string]string{"x-api-key": " "}, wantErr: ErrMissingKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.Authenticate(newRequest(tt.headers))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && id.User != tt.wantUser {
+				t.Errorf("Authenticate() user = %q, want %q", id.User, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	if New(nil) != nil {
+		t.Error("New(nil) should disable authentication")
+	}
+}
+
+func TestIdentity_AllowsModel(t *testing.T) {
+	var none *Identity
+	if !none.AllowsModel("gpt-4") {
+		t.Error("nil identity should allow every model")
+	}
+	if !(&Identity{User: "alice"}).AllowsModel("gpt-4") {
+		t.Error("identity without a model list should allow every model")
+	}
+	restricted := &Identity{User: "alice", Models: []string{"gpt-4"}}
+	if !restricted.AllowsModel("gpt-4") || restricted.AllowsModel("claude") {
+		t.Error("identity should only allow its listed models")
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != nil || UserID(ctx) != "" || OrgID(ctx) != "" {
+		t.Error("unauthenticated context should carry no identity")
+	}
+	ctx = WithIdentity(ctx, &Identity{User: "alice", Org: "eng"})
+	if UserID(ctx) != "alice" || OrgID(ctx) != "eng" {
+		t.Errorf("UserID/OrgID = %q/%q, want alice/eng", UserID(ctx), OrgID(ctx))
+	}
+}
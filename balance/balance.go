@@ -0,0 +1,365 @@
+// Package balance spreads upstream traffic across provider API keys and targets.
+// It tracks in-flight requests and failures per key, temporarily ejects keys
+// that the upstream rejected with 401 or 429, and orders the members of a
+// model alias according to its load-balancing strategy.
+package balance
+
+import (
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// DefaultEjectDuration is how long a key is ejected when its provider does not
+// configure key_eject_duration.
+const DefaultEjectDuration = 30 * time.Second
+
+// Strategy selects the order in which the members of a pool are tried.
+type Strategy string
+
+const (
+	// StrategyRoundRobin rotates the first member on every request.
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyWeighted picks members at random, proportionally to their weight.
+	StrategyWeighted Strategy = "weighted"
+	// StrategyLeastInFlight prefers the member whose key has the fewest
+	// in-flight requests; ties rotate like round-robin.
+	StrategyLeastInFlight Strategy = "least_in_flight"
+)
+
+// randFloat returns a pseudo-random number in [0, 1). Replaced in tests.
+var randFloat = rand.Float64
+
+// timeNow returns the current time. Replaced in tests.
+var timeNow = time.Now
+
+// Key is a single API key of a provider together with its usage counters.
+//
+// All methods are safe to call on a nil *Key, which stands for a provider
+// without configured keys.
+//
+// Thread Safety: Safe for concurrent use.
+type Key struct {
+	// Provider is the name of the provider the key belongs to.
+	Provider string
+	// Index is the position of the key in the provider's key list.
+	Index int
+
+	value    string
+	ejectFor time.Duration
+
+	inFlight     atomic.Int64
+	requests     atomic.Uint64
+	failures     atomic.Uint64
+	ejections    atomic.Uint64
+	ejectedUntil atomic.Int64 // Unix nanoseconds; 0 when never ejected
+}
+
+// KeyStats is a point-in-time snapshot of a key's counters.
+// The key itself is redacted to its last four characters.
+type KeyStats struct {
+	Provider     string     `json:"provider"`
+	Index        int        `json:"index"`
+	Key          string     `json:"key"`
+	InFlight     int64      `json:"in_flight"`
+	Requests     uint64     `json:"requests"`
+	Failures     uint64     `json:"failures"`
+	Ejections    uint64     `json:"ejections"`
+	Ejected      bool       `json:"ejected"`
+	EjectedUntil *time.Time `json:"ejected_until,omitempty"`
+}
+
+// Value returns the API key, or an empty string for a nil key.
+func (k *Key) Value() string {
+	if k == nil {
+		return ""
+	}
+	return k.value
+}
+
+// Acquire marks the start of a request using the key.
+// Every Acquire must be paired with a Release.
+func (k *Key) Acquire() {
+	if k == nil {
+		return
+	}
+	k.requests.Add(1)
+	k.inFlight.Add(1)
+}
+
+// Release marks the end of a request started with Acquire.
+func (k *Key) Release() {
+	if k == nil {
+		return
+	}
+	k.inFlight.Add(-1)
+}
+
+// InFlight returns the number of requests currently using the key.
+func (k *Key) InFlight() int64 {
+	if k == nil {
+		return 0
+	}
+	return k.inFlight.Load()
+}
+
+// Record records the upstream outcome of a request using the key.
+// Transport errors and error statuses count as failures. A 401 or 429 ejects
+// the key for the provider's eject duration, or for retryAfter if longer.
+//
+// @param status - the upstream status code, 0 if no response was received
+// @param err - the transport error, nil if a response was received
+// @param retryAfter - the delay requested by the upstream's Retry-After header, 0 if none
+func (k *Key) Record(status int, err error, retryAfter time.Duration) {
+	if k == nil {
+		return
+	}
+	if err == nil && status < 400 {
+		return
+	}
+	k.failures.Add(1)
+	if status != 401 && status != 429 {
+		return
+	}
+
+	d := k.ejectFor
+	if retryAfter > d {
+		d = retryAfter
+	}
+	if d <= 0 {
+		return
+	}
+	k.ejections.Add(1)
+	k.ejectedUntil.Store(timeNow().Add(d).UnixNano())
+}
+
+// Ejected reports whether the key is taken out of rotation at the given time.
+func (k *Key) Ejected(now time.Time) bool {
+	if k == nil {
+		return false
+	}
+	return now.UnixNano() < k.ejectedUntil.Load()
+}
+
+// Stats returns a snapshot of the key's counters.
+//
+// @pre k != nil
+func (k *Key) Stats(now time.Time) KeyStats {
+	stats := KeyStats{
+		Provider:  k.Provider,
+		Index:     k.Index,
+		Key:       redact(k.value),
+		InFlight:  k.inFlight.Load(),
+		Requests:  k.requests.Load(),
+		Failures:  k.failures.Load(),
+		Ejections: k.ejections.Load(),
+		Ejected:   k.Ejected(now),
+	}
+	if stats.Ejected {
+		until := time.Unix(0, k.ejectedUntil.Load())
+		stats.EjectedUntil = &until
+	}
+	return stats
+}
+
+// redact hides all but the last four characters of an API key.
+func redact(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// Member is one entry of a pool: a target served with a particular key.
+type Member struct {
+	// Target is the index of the target in the pool owner's target list.
+	Target int
+	// Key is the API key used for the target; nil if the provider has no keys.
+	Key *Key
+	// Weight is the member's weight for the weighted strategy. Values below 1 count as 1.
+	Weight int
+}
+
+// Pool orders the members of a model alias for each request.
+//
+// Thread Safety: Safe for concurrent use.
+type Pool struct {
+	strategy Strategy
+	members  []Member
+	next     atomic.Uint64
+}
+
+// NewPool creates a pool. An unknown or empty strategy falls back to round-robin.
+//
+// @param strategy - the member ordering strategy
+// @param members - the pool members, in configuration order
+// @return *Pool - the new pool
+func NewPool(strategy Strategy, members []Member) *Pool {
+	switch strategy {
+	case StrategyWeighted, StrategyLeastInFlight:
+	default:
+		strategy = StrategyRoundRobin
+	}
+	return &Pool{strategy: strategy, members: members}
+}
+
+// Members returns the pool members in configuration order.
+func (p *Pool) Members() []Member {
+	return p.members
+}
+
+// Order returns the members to try for one request, best first.
+// Members whose key is ejected are left out, unless every member is ejected,
+// in which case all members are returned so the request is still attempted.
+//
+// @return []Member - the members in try order; empty only for an empty pool
+func (p *Pool) Order() []Member {
+	now := timeNow()
+	available := make([]Member, 0, len(p.members))
+	for _, m := range p.members {
+		if !m.Key.Ejected(now) {
+			available = append(available, m)
+		}
+	}
+	if len(available) == 0 {
+		available = append(available, p.members...)
+	}
+	if len(available) == 0 {
+		return available
+	}
+
+	switch p.strategy {
+	case StrategyWeighted:
+		return weightedOrder(available)
+	case StrategyLeastInFlight:
+		ordered := p.rotate(available)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Key.InFlight() < ordered[j].Key.InFlight()
+		})
+		return ordered
+	default:
+		return p.rotate(available)
+	}
+}
+
+// rotate returns members rotated by the pool's round-robin counter.
+func (p *Pool) rotate(members []Member) []Member {
+	start := int((p.next.Add(1) - 1) % uint64(len(members)))
+	ordered := make([]Member, 0, len(members))
+	ordered = append(ordered, members[start:]...)
+	return append(ordered, members[:start]...)
+}
+
+// weightedOrder draws members at random without replacement, each draw
+// proportional to the remaining members' weights.
+func weightedOrder(members []Member) []Member {
+	remaining := append([]Member(nil), members...)
+	ordered := make([]Member, 0, len(members))
+	for len(remaining) > 0 {
+		total := 0
+		for _, m := range remaining {
+			total += memberWeight(m)
+		}
+		pick := randFloat() * float64(total)
+		i := 0
+		for ; i < len(remaining)-1; i++ {
+			pick -= float64(memberWeight(remaining[i]))
+			if pick < 0 {
+				break
+			}
+		}
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
+
+// memberWeight returns a member's effective weight.
+func memberWeight(m Member) int {
+	if m.Weight < 1 {
+		return 1
+	}
+	return m.Weight
+}
+
+// Registry holds the keys of all providers.
+//
+// Thread Safety: Safe for concurrent use; the key set is fixed at creation.
+type Registry struct {
+	providers []string
+	keys      map[string][]*Key
+	rotation  map[string]*Pool
+}
+
+// NewRegistry creates the keys of the given providers.
+// Key eject durations are assumed to be validated by the config loader;
+// invalid values fall back to DefaultEjectDuration.
+//
+// @param providers - the configured providers
+// @return *Registry - the registry, with one Key per distinct provider API key
+func NewRegistry(providers []config.Provider) *Registry {
+	r := &Registry{
+		keys:     make(map[string][]*Key, len(providers)),
+		rotation: make(map[string]*Pool, len(providers)),
+	}
+	for _, p := range providers {
+		ejectFor := DefaultEjectDuration
+		if p.KeyEjectDuration != "" {
+			if d, err := time.ParseDuration(p.KeyEjectDuration); err == nil && d >= 0 {
+				ejectFor = d
+			}
+		}
+
+		values := p.GetAPIKeys()
+		keys := make([]*Key, 0, len(values))
+		members := make([]Member, 0, len(values))
+		for i, value := range values {
+			key := &Key{Provider: p.Name, Index: i, value: value, ejectFor: ejectFor}
+			keys = append(keys, key)
+			members = append(members, Member{Key: key})
+		}
+		r.providers = append(r.providers, p.Name)
+		r.keys[p.Name] = keys
+		r.rotation[p.Name] = NewPool(StrategyRoundRobin, members)
+	}
+	return r
+}
+
+// Keys returns the keys of a provider in configuration order.
+//
+// @return []*Key - the keys, empty if the provider is unknown or has no keys
+func (r *Registry) Keys(provider string) []*Key {
+	return r.keys[provider]
+}
+
+// Next returns the next key of a provider in round-robin order, skipping
+// ejected keys unless all of them are ejected.
+//
+// @return *Key - the key, nil if the provider is unknown or has no keys
+func (r *Registry) Next(provider string) *Key {
+	pool, ok := r.rotation[provider]
+	if !ok {
+		return nil
+	}
+	order := pool.Order()
+	if len(order) == 0 {
+		return nil
+	}
+	return order[0].Key
+}
+
+// Stats returns a snapshot of every key, ordered by provider configuration
+// order and key index.
+func (r *Registry) Stats() []KeyStats {
+	now := timeNow()
+	stats := make([]KeyStats, 0)
+	for _, name := range r.providers {
+		for _, key := range r.keys[name] {
+			stats = append(stats, key.Stats(now))
+		}
+	}
+	return stats
+}
@@ -0,0 +1,290 @@
+package balance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// withClock fixes timeNow for the duration of a test and returns a function
+// that advances it.
+func withClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func testKeys(n int) []*Key {
+	keys := make([]*Key, n)
+	for i := range keys {
+		keys[i] = &Key{Provider: "p", Index: i, value: "key-value-" + string(rune('a'+i)), ejectFor: DefaultEjectDuration}
+	}
+	return keys
+}
+
+func targets(members []Member) []int {
+	out := make([]int, len(members))
+	for i, m := range members {
+		out[i] = m.Target
+	}
+	return out
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	withClock(t)
+	keys := testKeys(3)
+	pool := NewPool(StrategyRoundRobin, []Member{
+		{Target: 0, Key: keys[0]},
+		{Target: 1, Key: keys[1]},
+		{Target: 2, Key: keys[2]},
+	})
+
+	want := [][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}, {0, 1, 2}}
+	for i, w := range want {
+		if got := targets(pool.Order()); !equalInts(got, w) {
+			t.Errorf("Order() call %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestPool_UnknownStrategyIsRoundRobin(t *testing.T) {
+	withClock(t)
+	pool := NewPool("", []Member{{Target: 0}, {Target: 1}})
+	first := targets(pool.Order())
+	second := targets(pool.Order())
+	if !equalInts(first, []int{0, 1}) || !equalInts(second, []int{1, 0}) {
+		t.Errorf("Order() = %v then %v, want round-robin rotation", first, second)
+	}
+}
+
+func TestPool_Weighted(t *testing.T) {
+	withClock(t)
+	orig := randFloat
+	t.Cleanup(func() { randFloat = orig })
+
+	pool := NewPool(StrategyWeighted, []Member{
+		{Target: 0, Weight: 1},
+		{Target: 1, Weight: 3},
+	})
+
+	// First draw over total weight 4: 0.2*4 = 0.8 falls in target 0's [0,1).
+	randFloat = func() float64 { return 0.2 }
+	if got := targets(pool.Order()); !equalInts(got, []int{0, 1}) {
+		t.Errorf("Order() with low draw = %v, want [0 1]", got)
+	}
+
+	// 0.5*4 = 2 falls in target 1's [1,4).
+	randFloat = func() float64 { return 0.5 }
+	if got := targets(pool.Order()); !equalInts(got, []int{1, 0}) {
+		t.Errorf("Order() with high draw = %v, want [1 0]", got)
+	}
+}
+
+func TestPool_WeightedDistribution(t *testing.T) {
+	withClock(t)
+	pool := NewPool(StrategyWeighted, []Member{
+		{Target: 0, Weight: 1},
+		{Target: 1, Weight: 9},
+	})
+
+	firsts := map[int]int{}
+	for i := 0; i < 2000; i++ {
+		firsts[pool.Order()[0].Target]++
+	}
+	if firsts[1] < 1600 || firsts[0] == 0 {
+		t.Errorf("first picks = %v, want roughly 10%%/90%%", firsts)
+	}
+}
+
+func TestPool_LeastInFlight(t *testing.T) {
+	withClock(t)
+	keys := testKeys(3)
+	pool := NewPool(StrategyLeastInFlight, []Member{
+		{Target: 0, Key: keys[0]},
+		{Target: 1, Key: keys[1]},
+		{Target: 2, Key: keys[2]},
+	})
+
+	keys[0].Acquire()
+	keys[0].Acquire()
+	keys[1].Acquire()
+
+	if got := targets(pool.Order()); !equalInts(got, []int{2, 1, 0}) {
+		t.Errorf("Order() = %v, want [2 1 0]", got)
+	}
+
+	keys[0].Release()
+	keys[0].Release()
+	keys[1].Release()
+
+	// Ties rotate: the counter has advanced once already.
+	if got := targets(pool.Order()); !equalInts(got, []int{1, 2, 0}) {
+		t.Errorf("Order() with ties = %v, want [1 2 0]", got)
+	}
+}
+
+func TestPool_SkipsEjectedKeys(t *testing.T) {
+	advance := withClock(t)
+	keys := testKeys(2)
+	pool := NewPool(StrategyRoundRobin, []Member{
+		{Target: 0, Key: keys[0]},
+		{Target: 1, Key: keys[1]},
+	})
+
+	keys[0].Record(429, nil, 0)
+	for i := 0; i < 3; i++ {
+		if got := targets(pool.Order()); !equalInts(got, []int{1}) {
+			t.Fatalf("Order() with ejected key = %v, want [1]", got)
+		}
+	}
+
+	advance(DefaultEjectDuration + time.Second)
+	if got := pool.Order(); len(got) != 2 {
+		t.Errorf("Order() after ejection expired = %v, want both members", targets(got))
+	}
+}
+
+func TestPool_AllEjectedStillReturnsMembers(t *testing.T) {
+	withClock(t)
+	keys := testKeys(2)
+	pool := NewPool(StrategyRoundRobin, []Member{
+		{Target: 0, Key: keys[0]},
+		{Target: 1, Key: keys[1]},
+	})
+
+	keys[0].Record(401, nil, 0)
+	keys[1].Record(429, nil, 0)
+	if got := pool.Order(); len(got) != 2 {
+		t.Errorf("Order() with all keys ejected = %v, want both members", targets(got))
+	}
+}
+
+func TestKey_Record(t *testing.T) {
+	advance := withClock(t)
+
+	tests := []struct {
+		name       string
+		status     int
+		err        error
+		retryAfter time.Duration
+		wantFail   uint64
+		wantEject  bool
+		ejectedFor time.Duration
+	}{
+		{name: "success", status: 200},
+		{name: "bad request counts as failure", status: 400, wantFail: 1},
+		{name: "server error counts as failure", status: 503, wantFail: 1},
+		{name: "transport error counts as failure", err: errors.New("connection refused"), wantFail: 1},
+		{name: "unauthorized ejects", status: 401, wantFail: 1, wantEject: true, ejectedFor: DefaultEjectDuration},
+		{name: "rate limited ejects", status: 429, wantFail: 1, wantEject: true, ejectedFor: DefaultEjectDuration},
+		{name: "longer retry-after wins", status: 429, retryAfter: 2 * time.Minute, wantFail: 1, wantEject: true, ejectedFor: 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := testKeys(1)[0]
+			key.Record(tt.status, tt.err, tt.retryAfter)
+
+			stats := key.Stats(timeNow())
+			if stats.Failures != tt.wantFail {
+				t.Errorf("Failures = %d, want %d", stats.Failures, tt.wantFail)
+			}
+			if stats.Ejected != tt.wantEject {
+				t.Fatalf("Ejected = %v, want %v", stats.Ejected, tt.wantEject)
+			}
+			if !tt.wantEject {
+				return
+			}
+			if stats.Ejections != 1 {
+				t.Errorf("Ejections = %d, want 1", stats.Ejections)
+			}
+			advance(tt.ejectedFor - time.Second)
+			if !key.Ejected(timeNow()) {
+				t.Errorf("key no longer ejected before %v elapsed", tt.ejectedFor)
+			}
+			advance(2 * time.Second)
+			if key.Ejected(timeNow()) {
+				t.Errorf("key still ejected after %v", tt.ejectedFor)
+			}
+		})
+	}
+}
+
+func TestKey_NilIsSafe(t *testing.T) {
+	var key *Key
+	key.Acquire()
+	key.Release()
+	key.Record(429, nil, 0)
+	if key.Value() != "" || key.InFlight() != 0 || key.Ejected(time.Now()) {
+		t.Error("nil key should have no value, no in-flight requests and never be ejected")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	withClock(t)
+	t.Setenv("BALANCE_TEST_KEY", "sk-env-key-5678")
+
+	r := NewRegistry([]config.Provider{
+		{Name: "chutes", APIKey: "sk-first-key-1234", APIKeys: []string{"sk-second-key-abcd"}, EnvAPIKeys: []string{"BALANCE_TEST_KEY"}, KeyEjectDuration: "1m"},
+		{Name: "empty"},
+	})
+
+	keys := r.Keys("chutes")
+	if len(keys) != 3 {
+		t.Fatalf("Keys(chutes) = %d keys, want 3", len(keys))
+	}
+	if keys[2].Value() != "sk-env-key-5678" {
+		t.Errorf("keys[2] = %q, want env key", keys[2].Value())
+	}
+	if keys[0].ejectFor != time.Minute {
+		t.Errorf("ejectFor = %v, want 1m", keys[0].ejectFor)
+	}
+	if len(r.Keys("empty")) != 0 || r.Next("empty") != nil || r.Next("missing") != nil {
+		t.Error("providers without keys should have no keys")
+	}
+
+	if got := []*Key{r.Next("chutes"), r.Next("chutes"), r.Next("chutes"), r.Next("chutes")}; got[0] != keys[0] || got[1] != keys[1] || got[2] != keys[2] || got[3] != keys[0] {
+		t.Error("Next() should rotate through the provider's keys")
+	}
+
+	keys[1].Acquire()
+	keys[1].Record(429, nil, 0)
+
+	stats := r.Stats()
+	if len(stats) != 3 {
+		t.Fatalf("Stats() = %d entries, want 3", len(stats))
+	}
+	s := stats[1]
+	if s.Provider != "chutes" || s.Index != 1 || s.Key != "...abcd" {
+		t.Errorf("Stats()[1] identity = %+v", s)
+	}
+	if s.InFlight != 1 || s.Requests != 1 || s.Failures != 1 || s.Ejections != 1 || !s.Ejected || s.EjectedUntil == nil {
+		t.Errorf("Stats()[1] counters = %+v", s)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	if got := redact("sk-1234567890"); got != "...7890" {
+		t.Errorf("redact(long) = %q", got)
+	}
+	if got := redact("short"); got != "****" {
+		t.Errorf("redact(short) = %q", got)
+	}
+}
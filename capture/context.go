@@ -46,6 +46,10 @@ type CaptureContext struct {
 	// Valid values: any non-empty string; typically a UUID or meaningful identifier.
 	SessionID string
 
+	// SessionProvided indicates whether SessionID was taken from a request header.
+	// Valid values: false if SessionID defaulted to the initial RequestID.
+	SessionProvided bool
+
 	// StartTime is the timestamp when this capture context was created.
 	// Used for calculating elapsed time throughout the request lifecycle.
 	// Valid values: any valid time.Time, typically time.Now() at creation.
@@ -101,16 +105,18 @@ func NewCaptureContext(r *http.Request) *CaptureContext {
 			}
 		}
 	}
-	if sessionID == "" {
+	provided := sessionID != ""
+	if !provided {
 		sessionID = initialID
 	}
 
 	return &CaptureContext{
-		RequestID:   initialID,
-		SessionID:   sessionID,
-		StartTime:   time.Now(),
-		Recorder:    NewRecorder(initialID, r.Method, r.URL.Path, r.RemoteAddr),
-		IDExtracted: false,
+		RequestID:       initialID,
+		SessionID:       sessionID,
+		SessionProvided: provided,
+		StartTime:       time.Now(),
+		Recorder:        NewRecorder(initialID, r.Method, r.URL.Path, r.RemoteAddr),
+		IDExtracted:     false,
 	}
 }
 
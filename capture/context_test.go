@@ -42,6 +42,20 @@ func TestNewCaptureContext(t *testing.T) {
 	}
 }
 
+func TestNewCaptureContext_SessionID(t *testing.T) {
+	req := &http.Request{Method: "POST", URL: &url.URL{Path: "/v1/messages"}, Header: http.Header{}}
+	cc := NewCaptureContext(req)
+	if cc.SessionID != cc.RequestID || cc.SessionProvided {
+		t.Errorf("without header: SessionID = %q, SessionProvided = %v; want RequestID %q, false", cc.SessionID, cc.SessionProvided, cc.RequestID)
+	}
+
+	req.Header.Set("X-Session-ID", "sess-1")
+	cc = NewCaptureContext(req)
+	if cc.SessionID != "sess-1" || !cc.SessionProvided {
+		t.Errorf("with header: SessionID = %q, SessionProvided = %v; want sess-1, true", cc.SessionID, cc.SessionProvided)
+	}
+}
+
 func TestNewCaptureContext_RecorderFields(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -104,6 +104,19 @@ type RequestRecorder struct {
 	// Valid values: IP:port format string.
 	ClientIP string
 
+	// User is the authenticated user of the request.
+	// Empty when client authentication is disabled.
+	// Valid values: any string.
+	User string
+
+	// Org is the organization of the authenticated user.
+	// Valid values: any string, may be empty.
+	Org string
+
+	// ClientKey is the configured name of the client API key used.
+	// Valid values: any string, may be empty.
+	ClientKey string
+
 	// DownstreamRequest captures the client request received by the proxy.
 	// Nil until RecordDownstreamRequest is called.
 	// Valid values: pointer to HTTPRequestCapture, or nil.
@@ -123,6 +136,31 @@ type RequestRecorder struct {
 	// Nil until RecordDownstreamResponse is called.
 	// Valid values: pointer to SSEResponseCapture, or nil.
 	DownstreamResponse *SSEResponseCapture
+
+	// UpstreamAttempts lists every upstream attempt when the provider has retries configured.
+	// Empty until RecordUpstreamAttempt is called.
+	// Valid values: attempts in order, may be nil.
+	UpstreamAttempts []UpstreamAttempt
+}
+
+// UpstreamAttempt records the outcome of one attempt to reach the upstream API.
+//
+// Thread Safety: Value type; safe for concurrent read after creation.
+type UpstreamAttempt struct {
+	// Attempt is the 1-based attempt number.
+	Attempt int `json:"attempt"`
+
+	// At is when the attempt completed.
+	At time.Time `json:"at"`
+
+	// StatusCode is the upstream status code, or 0 on transport error.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// Error describes the transport error, if any.
+	Error string `json:"error,omitempty"`
+
+	// RetryDelayMS is the wait before the next attempt, or 0 if none followed.
+	RetryDelayMS int64 `json:"retry_delay_ms,omitempty"`
 }
 
 // RecordDownstreamRequest captures the incoming client request.
@@ -149,9 +187,11 @@ func (r *RequestRecorder) RecordDownstreamRequest(req *http.Request, body []byte
 }
 
 // SanitizeHeaders returns a header map with sensitive values masked.
-// Authorization, API keys, cookies, and auth tokens are replaced with "***".
+// Authorization, API keys (including Azure's api-key), cookies, and auth tokens are replaced with "***",
+// as are the extra headers, such as a provider's custom auth header and static headers.
 //
 // @param headers - HTTP headers to sanitize. May be nil (returns nil).
+// @param extra - Further sensitive header names, matched case-insensitively.
 // @return Map of header names to sanitized values.
 //
 // @pre None
@@ -162,15 +202,20 @@ func (r *RequestRecorder) RecordDownstreamRequest(req *http.Request, body []byte
 // @note This prevents accidental credential exposure in logs.
 // @note Header names preserve original case from input.
 // @note Thread-safe: pure function with no side effects.
-func SanitizeHeaders(headers http.Header) map[string]string {
+func SanitizeHeaders(headers http.Header, extra ...string) map[string]string {
 	// Define sensitive header names that should be masked
 	// Keys are lowercase for case-insensitive matching
 	sensitive := map[string]bool{
-		"authorization": true,
-		"x-api-key":     true,
-		"cookie":        true,
-		"set-cookie":    true,
-		"x-auth-token":  true,
+		"authorization":  true,
+		"x-api-key":      true,
+		"x-goog-api-key": true,
+		"api-key":        true,
+		"cookie":         true,
+		"set-cookie":     true,
+		"x-auth-token":   true,
+	}
+	for _, name := range extra {
+		sensitive[strings.ToLower(name)] = true
 	}
 
 	// Create result map with capacity hint for efficiency
@@ -287,8 +332,9 @@ func (r *Recorder) RecordDownstreamRequest(headers http.Header, body []byte) {
 
 // RecordUpstreamRequest captures the outgoing upstream API request with thread safety.
 //
-// @param headers - HTTP headers to capture. May be nil (results in empty map).
-// @param body    - Request body bytes. May be nil or empty.
+// @param headers   - HTTP headers to capture. May be nil (results in empty map).
+// @param body      - Request body bytes. May be nil or empty.
+// @param sensitive - Further header names to mask, see SanitizeHeaders.
 //
 // @pre r != nil (receiver must be valid)
 // @post r.data.UpstreamRequest != nil (after call)
@@ -296,7 +342,7 @@ func (r *Recorder) RecordDownstreamRequest(headers http.Header, body []byte) {
 //
 // @note Thread-safe: uses mutex for exclusive access.
 // @note Headers are sanitized to mask sensitive values.
-func (r *Recorder) RecordUpstreamRequest(headers http.Header, body []byte) {
+func (r *Recorder) RecordUpstreamRequest(headers http.Header, body []byte, sensitive ...string) {
 	// Lock for entire operation to ensure atomic update
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -304,12 +350,27 @@ func (r *Recorder) RecordUpstreamRequest(headers http.Header, body []byte) {
 	// Create capture with timestamp and sanitized headers
 	r.data.UpstreamRequest = &HTTPRequestCapture{
 		At:      time.Now(),
-		Headers: SanitizeHeaders(headers),
+		Headers: SanitizeHeaders(headers, sensitive...),
 		Body:    body,
 		RawBody: body,
 	}
 }
 
+// RecordUpstreamAttempt appends the outcome of an upstream attempt with thread safety.
+//
+// @param attempt - The attempt to record.
+//
+// @pre r != nil (receiver must be valid)
+// @post r.data.UpstreamAttempts ends with attempt
+//
+// @note Thread-safe: uses mutex for exclusive access.
+func (r *Recorder) RecordUpstreamAttempt(attempt UpstreamAttempt) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data.UpstreamAttempts = append(r.data.UpstreamAttempts, attempt)
+}
+
 // RecordUpstreamResponse initializes upstream response capture and returns a responseRecorder for chunk recording.
 //
 // @param statusCode - HTTP status code from upstream response.
@@ -408,6 +469,25 @@ func (r *Recorder) SetRequestID(id string) {
 	r.data.RequestID = id
 }
 
+// SetIdentity records the authenticated client of the request.
+//
+// @param user - The authenticated user.
+// @param org - The user's organization. May be empty.
+// @param key - The name of the client API key used. May be empty.
+//
+// @pre r != nil
+// @post r.data.User == user && r.data.Org == org && r.data.ClientKey == key
+//
+// @note Thread-safe: uses mutex for exclusive access.
+func (r *Recorder) SetIdentity(user, org, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data.User = user
+	r.data.Org = org
+	r.data.ClientKey = key
+}
+
 // responseRecorder records SSE chunks for a single response stream.
 // It writes chunks to a shared SSEResponseCapture.
 //
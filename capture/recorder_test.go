@@ -35,6 +35,24 @@ func TestSanitizeHeaders(t *testing.T) {
 				"Accept":    "application/json",
 			},
 		},
+		{
+			name: "x-goog-api-key header masked",
+			headers: http.Header{
+				"X-Goog-Api-Key": []string{"gemini-key"},
+			},
+			expected: map[string]string{
+				"X-Goog-Api-Key": "***",
+			},
+		},
+		{
+			name: "azure api-key header masked",
+			headers: http.Header{
+				"Api-Key": []string{"azure-key"},
+			},
+			expected: map[string]string{
+				"Api-Key": "***",
+			},
+		},
 		{
 			name: "cookie header masked",
 			headers: http.Header{
@@ -119,6 +137,23 @@ func TestSanitizeHeaders(t *testing.T) {
 	}
 }
 
+func TestSanitizeHeaders_Extra(t *testing.T) {
+	headers := http.Header{
+		"X-Tenant-Key": []string{"tenant-secret"},
+		"X-Tenant-Id":  []string{"acme"},
+		"Accept":       []string{"text/event-stream"},
+	}
+
+	result := SanitizeHeaders(headers, "x-tenant-key", "X-TENANT-ID")
+
+	if result["X-Tenant-Key"] != "***" || result["X-Tenant-Id"] != "***" {
+		t.Errorf("expected extra headers masked, got %v", result)
+	}
+	if result["Accept"] != "text/event-stream" {
+		t.Errorf("expected Accept preserved, got %q", result["Accept"])
+	}
+}
+
 func TestOffsetMS(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -537,3 +572,21 @@ func TestResponseRecorder_RecordChunkBytes(t *testing.T) {
 		t.Fatal("expected 1 chunk")
 	}
 }
+
+func TestRecorder_RecordUpstreamAttempt(t *testing.T) {
+	rec := NewRecorder("req-id", "POST", "/test", "localhost")
+
+	rec.RecordUpstreamAttempt(UpstreamAttempt{Attempt: 1, At: time.Now(), StatusCode: 503, RetryDelayMS: 500})
+	rec.RecordUpstreamAttempt(UpstreamAttempt{Attempt: 2, At: time.Now(), StatusCode: 200})
+
+	attempts := rec.Data().UpstreamAttempts
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	if attempts[0].Attempt != 1 || attempts[0].StatusCode != 503 || attempts[0].RetryDelayMS != 500 {
+		t.Errorf("unexpected first attempt: %+v", attempts[0])
+	}
+	if attempts[1].Attempt != 2 || attempts[1].StatusCode != 200 {
+		t.Errorf("unexpected second attempt: %+v", attempts[1])
+	}
+}
@@ -253,6 +253,16 @@ type logData struct {
 	// Valid values: IP:port format string.
 	ClientIP string `json:"client_ip,omitempty"`
 
+	// User is the authenticated user of the request.
+	// Empty when client authentication is disabled.
+	User string `json:"user,omitempty"`
+
+	// Org is the organization of the authenticated user.
+	Org string `json:"org,omitempty"`
+
+	// ClientKey is the configured name of the client API key used.
+	ClientKey string `json:"client_key,omitempty"`
+
 	// DownstreamRequest is the captured client request.
 	// Nil if not captured.
 	// Valid values: pointer to HTTPRequestCapture, or nil.
@@ -272,6 +282,11 @@ type logData struct {
 	// Nil if not captured.
 	// Valid values: pointer to SSEResponseCapture, or nil.
 	DownstreamResponse *SSEResponseCapture `json:"downstream_response,omitempty"`
+
+	// UpstreamAttempts lists each upstream attempt when the provider has retries configured.
+	// Nil otherwise.
+	// Valid values: slice of UpstreamAttempt, or nil.
+	UpstreamAttempts []UpstreamAttempt `json:"upstream_attempts,omitempty"`
 }
 
 // serialize converts a RequestRecorder to a logData struct for JSON encoding.
@@ -296,9 +311,13 @@ func (s *Storage) serialize(r *RequestRecorder) logData {
 		Method:             r.Method,
 		Path:               r.Path,
 		ClientIP:           r.ClientIP,
+		User:               r.User,
+		Org:                r.Org,
+		ClientKey:          r.ClientKey,
 		DownstreamRequest:  r.DownstreamRequest,
 		UpstreamRequest:    r.UpstreamRequest,
 		UpstreamResponse:   r.UpstreamResponse,
 		DownstreamResponse: r.DownstreamResponse,
+		UpstreamAttempts:   r.UpstreamAttempts,
 	}
 }
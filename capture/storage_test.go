@@ -361,6 +361,50 @@ func TestStorage_Serialize_EmptyRecorder(t *testing.T) {
 	}
 }
 
+func TestStorage_Serialize_UpstreamAttempts(t *testing.T) {
+	storage := NewStorage("/tmp")
+
+	recorder := NewRecorder("attempts-test", "POST", "/v1/messages", "localhost:8080")
+	recorder.RecordUpstreamAttempt(UpstreamAttempt{Attempt: 1, StatusCode: 429, RetryDelayMS: 1000})
+	recorder.RecordUpstreamAttempt(UpstreamAttempt{Attempt: 2, Error: "connection reset"})
+
+	data := storage.serialize(recorder.Data())
+
+	if len(data.UpstreamAttempts) != 2 {
+		t.Fatalf("expected 2 upstream attempts, got %d", len(data.UpstreamAttempts))
+	}
+
+	out, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(out), `"upstream_attempts":[{"attempt":1`) {
+		t.Errorf("expected upstream_attempts in output, got %s", out)
+	}
+}
+
+func TestStorage_Serialize_Identity(t *testing.T) {
+	storage := NewStorage("/tmp")
+
+	recorder := NewRecorder("identity-test", "POST", "/v1/messages", "localhost:8080")
+	out, err := json.Marshal(storage.serialize(recorder.Data()))
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if strings.Contains(string(out), `"user"`) {
+		t.Errorf("expected no user for an unauthenticated request, got %s", out)
+	}
+
+	recorder.SetIdentity("alice", "eng", "laptop")
+	out, err = json.Marshal(storage.serialize(recorder.Data()))
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(out), `"user":"alice","org":"eng","client_key":"laptop"`) {
+		t.Errorf("expected identity in output, got %s", out)
+	}
+}
+
 func TestStorage_Write_ReadOnlyPermissions(t *testing.T) {
 	tmpDir := t.TempDir()
 	readOnlyDir := filepath.Join(tmpDir, "readonly")
@@ -9,6 +9,7 @@
 package capture
 
 import (
+	"bytes"
 	"encoding/json"
 	"time"
 )
@@ -240,6 +241,29 @@ type TokenUsage struct {
 	CacheReadTokens int
 	// CacheCreationTokens is the number of tokens used to create cache
 	CacheCreationTokens int
+	// InputIncludesCache is true when InputTokens also counts CacheReadTokens,
+	// as OpenAI's prompt_tokens and the Responses API's input_tokens do
+	InputIncludesCache bool
+	// CacheCreationReported is true when the upstream reported CacheCreationTokens
+	// rather than it being estimated from the input and cache read tokens
+	CacheCreationReported bool
+}
+
+// UncachedInputTokens returns the input tokens that were neither read from
+// nor written to the prompt cache.
+//
+// @return InputTokens less the cache tokens it includes, never negative
+//
+// @note An estimated CacheCreationTokens is part of the uncached input and is not subtracted.
+func (u TokenUsage) UncachedInputTokens() int {
+	if !u.InputIncludesCache {
+		return u.InputTokens
+	}
+	uncached := u.InputTokens - u.CacheReadTokens
+	if u.CacheCreationReported {
+		uncached -= u.CacheCreationTokens
+	}
+	return max(0, uncached)
 }
 
 // ExtractTokenUsageFromChunks extracts token usage from SSE chunks.
@@ -249,6 +273,8 @@ type TokenUsage struct {
 //   - OpenAI: {"usage":{"prompt_tokens":X,"completion_tokens":Y,"prompt_tokens_details":{"cached_tokens":Z}}}
 //   - Anthropic: {"usage":{"input_tokens":X,"output_tokens":Y,"cache_read_input_tokens":Z,"cache_creation_input_tokens":W}}
 //   - Responses: {"usage":{"input_tokens":X,"output_tokens":Y,"input_tokens_details":{"cached_tokens":Z}}}
+//   - Gemini: {"usageMetadata":{"promptTokenCount":X,"candidatesTokenCount":Y,"thoughtsTokenCount":T,"cachedContentTokenCount":Z}}
+//   - Ollama: {"done":true,"prompt_eval_count":X,"eval_count":Y}
 //
 // @param chunks - SSE chunks to extract usage from
 // @return TokenUsage with aggregated token counts
@@ -270,6 +296,23 @@ func ExtractTokenUsageFromChunks(chunks []SSEChunk) TokenUsage {
 	return usage
 }
 
+// UpdateTokenUsage updates usage from a single SSE event payload, so token
+// counts can be tracked while streaming without capturing every chunk.
+// Payloads without a "usage" field are skipped without being parsed.
+//
+// @param data - SSE event data
+// @param usage - TokenUsage to update
+//
+// @pre usage != nil
+// @post Fields present in the event's usage object overwrite those in usage.
+func UpdateTokenUsage(data []byte, usage *TokenUsage) {
+	if !bytes.Contains(data, []byte(`"usage"`)) && !bytes.Contains(data, []byte(`"usageMetadata"`)) &&
+		!bytes.Contains(data, []byte(`"eval_count"`)) {
+		return
+	}
+	extractUsageFromJSON(data, usage)
+}
+
 // extractUsageFromJSON parses JSON data and extracts token usage.
 // Handles multiple API formats by checking various field names.
 //
@@ -312,6 +355,17 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 	}
 
 	if usageObj == nil {
+		// Gemini reports usageMetadata with its own field names
+		if u, ok := root["usageMetadata"].(map[string]interface{}); ok {
+			extractGeminiUsage(u, usage)
+		}
+		// Ollama reports token counts at the top level of its done line
+		if v, ok := root["prompt_eval_count"].(float64); ok {
+			usage.InputTokens = int(v)
+		}
+		if v, ok := root["eval_count"].(float64); ok {
+			usage.OutputTokens = int(v)
+		}
 		return
 	}
 
@@ -320,6 +374,7 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 		usage.InputTokens = int(v)
 	} else if v, ok := usageObj["prompt_tokens"].(float64); ok {
 		usage.InputTokens = int(v)
+		usage.InputIncludesCache = true
 	}
 
 	// Extract output tokens - try multiple field names
@@ -340,6 +395,7 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 	// Extract cache creation tokens
 	if v, ok := usageObj["cache_creation_input_tokens"].(float64); ok {
 		usage.CacheCreationTokens = int(v)
+		usage.CacheCreationReported = true
 	}
 
 	// Check for nested cache details in OpenAI format
@@ -349,6 +405,7 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 		}
 		if v, ok := details["cache_creation_input_tokens"].(float64); ok {
 			usage.CacheCreationTokens = int(v)
+			usage.CacheCreationReported = true
 		}
 	}
 
@@ -357,6 +414,7 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 	// This represents tokens that were NOT served from cache (fresh tokens)
 	// Anthropic provides cache_creation_input_tokens directly, but Responses API doesn't
 	if details, ok := usageObj["input_tokens_details"].(map[string]interface{}); ok {
+		usage.InputIncludesCache = true
 		if v, ok := details["cached_tokens"].(float64); ok {
 			usage.CacheReadTokens = int(v)
 		}
@@ -381,6 +439,24 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 	}
 }
 
+// extractGeminiUsage reads a Gemini usageMetadata object. The prompt token count
+// includes cached tokens, and reasoning tokens are counted as output.
+//
+// @param usageObj - the usageMetadata object
+// @param usage - TokenUsage to populate
+func extractGeminiUsage(usageObj map[string]interface{}, usage *TokenUsage) {
+	if v, ok := usageObj["promptTokenCount"].(float64); ok {
+		usage.InputTokens = int(v)
+		usage.InputIncludesCache = true
+	}
+	candidates, _ := usageObj["candidatesTokenCount"].(float64)
+	thoughts, _ := usageObj["thoughtsTokenCount"].(float64)
+	usage.OutputTokens = int(candidates + thoughts)
+	if v, ok := usageObj["cachedContentTokenCount"].(float64); ok {
+		usage.CacheReadTokens = int(v)
+	}
+}
+
 // ExtractFinishReasonFromChunks extracts the finish reason from SSE chunks.
 // It scans through all chunks looking for finish/stop reasons in various API formats.
 //
@@ -388,6 +464,7 @@ func extractUsageFromJSON(data json.RawMessage, usage *TokenUsage) {
 //   - OpenAI: {"choices":[{"finish_reason":"stop"}]}
 //   - Anthropic: {"type":"message_delta","delta":{"stop_reason":"end_turn"}}
 //   - Responses API: {"status":"completed"} (maps "completed" to "stop")
+//   - Ollama: {"done":true,"done_reason":"stop"}
 //
 // @param chunks - SSE chunks to extract finish reason from
 // @return The finish reason string, or "unknown" if not found
@@ -446,6 +523,11 @@ func extractFinishReasonFromJSON(data json.RawMessage) string {
 		return sr
 	}
 
+	// Check for Ollama done line format: done_reason
+	if dr, ok := root["done_reason"].(string); ok && dr != "" {
+		return dr
+	}
+
 	// Check for Responses API format: status at root level
 	if status, ok := root["status"].(string); ok && status != "" {
 		// Map Responses API status to finish reason
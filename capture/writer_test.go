@@ -507,6 +507,14 @@ func TestExtractFinishReasonFromChunks(t *testing.T) {
 			},
 			want: "content_filter",
 		},
+		{
+			name: "Ollama done_reason length",
+			chunks: []SSEChunk{
+				{Data: json.RawMessage(`{"message":{"role":"assistant","content":"Hi"},"done":false}`)},
+				{Data: json.RawMessage(`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length"}`)},
+			},
+			want: "length",
+		},
 		{
 			name: "Anthropic message_delta stop_reason end_turn",
 			chunks: []SSEChunk{
@@ -622,3 +630,78 @@ func TestExtractFinishReasonFromChunks(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateTokenUsage(t *testing.T) {
+	var usage TokenUsage
+	UpdateTokenUsage([]byte(`{"type":"message_start","message":{"usage":{"input_tokens":100}}}`), &usage)
+	UpdateTokenUsage([]byte(`{"type":"content_block_delta","delta":{"text":"hi"}}`), &usage)
+	UpdateTokenUsage([]byte(`{"type":"message_delta","usage":{"output_tokens":25}}`), &usage)
+
+	want := TokenUsage{InputTokens: 100, OutputTokens: 25}
+	if usage != want {
+		t.Errorf("UpdateTokenUsage() = %+v, want %+v", usage, want)
+	}
+}
+
+func TestUpdateTokenUsage_Gemini(t *testing.T) {
+	var usage TokenUsage
+	UpdateTokenUsage([]byte(`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":3,"thoughtsTokenCount":7,"totalTokenCount":22}}`), &usage)
+
+	want := TokenUsage{InputTokens: 12, OutputTokens: 10, InputIncludesCache: true}
+	if usage != want {
+		t.Errorf("UpdateTokenUsage() = %+v, want %+v", usage, want)
+	}
+}
+
+func TestUpdateTokenUsage_Ollama(t *testing.T) {
+	var usage TokenUsage
+	UpdateTokenUsage([]byte(`{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":26,"eval_count":290}`), &usage)
+
+	want := TokenUsage{InputTokens: 26, OutputTokens: 290}
+	if usage != want {
+		t.Errorf("UpdateTokenUsage() = %+v, want %+v", usage, want)
+	}
+}
+
+func TestTokenUsage_UncachedInputTokens(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            string
+		wantUncached    int
+		wantCrtReported bool
+	}{
+		{
+			name:         "OpenAI prompt tokens include cached tokens",
+			data:         `{"usage":{"prompt_tokens":100,"completion_tokens":5,"prompt_tokens_details":{"cached_tokens":40}}}`,
+			wantUncached: 60,
+		},
+		{
+			name:         "Responses input tokens include cached tokens",
+			data:         `{"response":{"usage":{"input_tokens":100,"output_tokens":5,"input_tokens_details":{"cached_tokens":40}}}}`,
+			wantUncached: 60,
+		},
+		{
+			name:         "Gemini prompt tokens include cached tokens",
+			data:         `{"candidates":[],"usageMetadata":{"promptTokenCount":100,"candidatesTokenCount":5,"cachedContentTokenCount":40}}`,
+			wantUncached: 60,
+		},
+		{
+			name:            "Anthropic input tokens exclude cache",
+			data:            `{"message":{"usage":{"input_tokens":100,"cache_read_input_tokens":40,"cache_creation_input_tokens":10}}}`,
+			wantUncached:    100,
+			wantCrtReported: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var usage TokenUsage
+			UpdateTokenUsage([]byte(tt.data), &usage)
+			if got := usage.UncachedInputTokens(); got != tt.wantUncached {
+				t.Errorf("UncachedInputTokens() = %d, want %d", got, tt.wantUncached)
+			}
+			if usage.CacheCreationReported != tt.wantCrtReported {
+				t.Errorf("CacheCreationReported = %v, want %v", usage.CacheCreationReported, tt.wantCrtReported)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+// Package circuit implements per-provider circuit breakers.
+// A breaker counts consecutive upstream failures; once they reach the
+// configured threshold it opens and requests to the provider are skipped.
+// After the open duration a limited number of probe requests are let through
+// (half-open); a successful probe closes the breaker, a failed one reopens it.
+package circuit
+
+import (
+	"sync"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// Circuit breaker defaults applied by New.
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenDuration     = 30 * time.Second
+	DefaultHalfOpenRequests = 1
+)
+
+// recentWindow is the number of most recent outcomes the error rate is computed over.
+const recentWindow = 50
+
+// State is the state of a circuit breaker.
+type State string
+
+const (
+	// StateDisabled means the provider has no circuit_breaker configuration;
+	// failures are tracked but the breaker never opens.
+	StateDisabled State = "disabled"
+	// StateClosed lets all requests through.
+	StateClosed State = "closed"
+	// StateOpen rejects requests until the open duration has elapsed.
+	StateOpen State = "open"
+	// StateHalfOpen lets a limited number of probe requests through.
+	StateHalfOpen State = "half_open"
+)
+
+// timeNow returns the current time. Replaced in tests.
+var timeNow = time.Now
+
+// Breaker is the circuit breaker of a single provider.
+//
+// All methods are safe to call on a nil *Breaker, which never opens.
+//
+// Thread Safety: Safe for concurrent use.
+type Breaker struct {
+	provider         string
+	enabled          bool
+	threshold        int
+	openDuration     time.Duration
+	halfOpenRequests int
+	firstByteTimeout time.Duration
+
+	mu          sync.Mutex
+	state       State
+	consecutive int
+	openedAt    time.Time
+	probes      int
+	recent      [recentWindow]bool // true for a failure
+	recentNext  int
+	recentCount int
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// Stats is a point-in-time snapshot of a breaker.
+type Stats struct {
+	Provider            string     `json:"provider"`
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	ErrorRate           float64    `json:"error_rate"`
+	RecentRequests      int        `json:"recent_requests"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
+}
+
+// New creates the breaker of a provider, filling defaults.
+// Durations are assumed to be validated by the config loader; invalid values
+// fall back to the defaults.
+//
+// @param provider - the provider name
+// @param cfg - the circuit breaker configuration; nil creates a disabled breaker
+// @return *Breaker - the new breaker, closed (or disabled)
+func New(provider string, cfg *config.CircuitBreakerConfig) *Breaker {
+	b := &Breaker{provider: provider, state: StateDisabled}
+	if cfg == nil {
+		return b
+	}
+
+	b.enabled = true
+	b.state = StateClosed
+	b.threshold = cfg.FailureThreshold
+	if b.threshold < 1 {
+		b.threshold = DefaultFailureThreshold
+	}
+	b.openDuration = parseDurationOr(cfg.OpenDuration, DefaultOpenDuration)
+	b.halfOpenRequests = cfg.HalfOpenRequests
+	if b.halfOpenRequests < 1 {
+		b.halfOpenRequests = DefaultHalfOpenRequests
+	}
+	b.firstByteTimeout = parseDurationOr(cfg.FirstByteTimeout, 0)
+	return b
+}
+
+// parseDurationOr parses a duration, returning def if empty or invalid.
+func parseDurationOr(value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// Provider returns the name of the breaker's provider.
+func (b *Breaker) Provider() string {
+	if b == nil {
+		return ""
+	}
+	return b.provider
+}
+
+// FirstByteTimeout returns how long an upstream attempt may wait for response
+// headers, 0 for no limit.
+func (b *Breaker) FirstByteTimeout() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return b.firstByteTimeout
+}
+
+// Available reports whether a request to the provider would currently be let
+// through. Unlike Allow, it does not reserve a probe slot or change state.
+func (b *Breaker) Available() bool {
+	if b == nil || !b.enabled {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		return !timeNow().Before(b.openedAt.Add(b.openDuration))
+	case StateHalfOpen:
+		return b.probes < b.halfOpenRequests
+	default:
+		return true
+	}
+}
+
+// Allow reports whether a request may be sent to the provider. Once the open
+// duration has elapsed, an open breaker turns half-open; while half-open, each
+// allowed request takes a probe slot that is returned by Success, Failure or Cancel.
+//
+// @post If true is returned, exactly one of Success, Failure or Cancel must follow.
+func (b *Breaker) Allow() bool {
+	if b == nil || !b.enabled {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if timeNow().Before(b.openedAt.Add(b.openDuration)) {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.probes = 0
+	}
+	if b.state == StateHalfOpen {
+		if b.probes >= b.halfOpenRequests {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// Success records a request the provider answered. A half-open breaker closes.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.recordRecent(false)
+	b.consecutive = 0
+	if b.state == StateHalfOpen {
+		b.state = StateClosed
+		b.probes = 0
+	}
+}
+
+// Failure records a failed request. The breaker opens when the consecutive
+// failures reach the threshold, or immediately when a half-open probe fails.
+//
+// @param reason - short description of the failure, reported as the last error
+func (b *Breaker) Failure(reason string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := timeNow()
+	b.recordRecent(true)
+	b.consecutive++
+	b.lastError = reason
+	b.lastErrorAt = now
+
+	switch b.state {
+	case StateHalfOpen:
+		b.open(now)
+	case StateClosed:
+		if b.consecutive >= b.threshold {
+			b.open(now)
+		}
+	}
+}
+
+// Cancel returns the probe slot of an allowed request that ended without an
+// upstream outcome, such as a request cancelled by the client.
+func (b *Breaker) Cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// open moves the breaker to the open state.
+//
+// @pre b.mu is held
+func (b *Breaker) open(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+	b.probes = 0
+}
+
+// recordRecent adds an outcome to the error rate window.
+//
+// @pre b.mu is held
+func (b *Breaker) recordRecent(failed bool) {
+	b.recent[b.recentNext] = failed
+	b.recentNext = (b.recentNext + 1) % recentWindow
+	if b.recentCount < recentWindow {
+		b.recentCount++
+	}
+}
+
+// Stats returns a snapshot of the breaker. An open breaker whose open
+// duration has elapsed is reported as half-open.
+//
+// @pre b != nil
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := Stats{
+		Provider:            b.provider,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutive,
+		RecentRequests:      b.recentCount,
+		LastError:           b.lastError,
+	}
+
+	failures := 0
+	for i := 0; i < b.recentCount; i++ {
+		if b.recent[i] {
+			failures++
+		}
+	}
+	if b.recentCount > 0 {
+		stats.ErrorRate = float64(failures) / float64(b.recentCount)
+	}
+	if !b.lastErrorAt.IsZero() {
+		at := b.lastErrorAt
+		stats.LastErrorAt = &at
+	}
+	if b.state == StateOpen {
+		until := b.openedAt.Add(b.openDuration)
+		if timeNow().Before(until) {
+			stats.OpenUntil = &until
+		} else {
+			stats.State = StateHalfOpen
+		}
+	}
+	return stats
+}
+
+// Registry holds the breakers of all providers.
+//
+// Thread Safety: Safe for concurrent use; the breaker set is fixed at creation.
+type Registry struct {
+	providers []string
+	breakers  map[string]*Breaker
+}
+
+// NewRegistry creates one breaker per provider.
+//
+// @param providers - the configured providers
+// @return *Registry - the registry
+func NewRegistry(providers []config.Provider) *Registry {
+	r := &Registry{breakers: make(map[string]*Breaker, len(providers))}
+	for _, p := range providers {
+		r.providers = append(r.providers, p.Name)
+		r.breakers[p.Name] = New(p.Name, p.CircuitBreaker)
+	}
+	return r
+}
+
+// Get returns the breaker of a provider.
+//
+// @return *Breaker - the breaker, nil if the provider is unknown
+func (r *Registry) Get(provider string) *Breaker {
+	return r.breakers[provider]
+}
+
+// Stats returns a snapshot of every breaker in provider configuration order.
+func (r *Registry) Stats() []Stats {
+	stats := make([]Stats, 0, len(r.providers))
+	for _, name := range r.providers {
+		stats = append(stats, r.breakers[name].Stats())
+	}
+	return stats
+}
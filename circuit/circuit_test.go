@@ -0,0 +1,157 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// withClock fixes timeNow for the duration of a test and returns a function
+// that advances it.
+func withClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestNew_Defaults(t *testing.T) {
+	b := New("p", &config.CircuitBreakerConfig{})
+	if b.threshold != DefaultFailureThreshold || b.openDuration != DefaultOpenDuration || b.halfOpenRequests != DefaultHalfOpenRequests {
+		t.Errorf("unexpected defaults: threshold=%d open=%v halfOpen=%d", b.threshold, b.openDuration, b.halfOpenRequests)
+	}
+	if b.FirstByteTimeout() != 0 {
+		t.Errorf("FirstByteTimeout() = %v, want 0", b.FirstByteTimeout())
+	}
+
+	b = New("p", &config.CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: "1m", HalfOpenRequests: 3, FirstByteTimeout: "20s"})
+	if b.threshold != 2 || b.openDuration != time.Minute || b.halfOpenRequests != 3 || b.FirstByteTimeout() != 20*time.Second {
+		t.Errorf("unexpected configuration: %+v", b)
+	}
+}
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	withClock(t)
+	b := New("p", &config.CircuitBreakerConfig{FailureThreshold: 3})
+
+	b.Failure("status 503")
+	b.Failure("status 503")
+	b.Success()
+	b.Failure("status 503")
+	b.Failure("status 503")
+	if !b.Allow() || b.Stats().State != StateClosed {
+		t.Fatal("breaker should stay closed: the success reset the consecutive count")
+	}
+	b.Cancel()
+
+	b.Failure("connection refused")
+	if b.Allow() || b.Available() {
+		t.Fatal("breaker should be open after 3 consecutive failures")
+	}
+
+	stats := b.Stats()
+	if stats.State != StateOpen || stats.ConsecutiveFailures != 3 || stats.LastError != "connection refused" || stats.OpenUntil == nil {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.RecentRequests != 6 || stats.ErrorRate != 5.0/6.0 {
+		t.Errorf("error rate = %v over %d, want 5/6 over 6", stats.ErrorRate, stats.RecentRequests)
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	advance := withClock(t)
+	b := New("p", &config.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: "10s"})
+
+	b.Failure("status 500")
+	if b.Allow() {
+		t.Fatal("breaker should be open")
+	}
+
+	advance(10 * time.Second)
+	if !b.Available() || b.Stats().State != StateHalfOpen {
+		t.Fatal("breaker should report half-open after the open duration")
+	}
+	if !b.Allow() {
+		t.Fatal("first probe should be allowed")
+	}
+	if b.Allow() || b.Available() {
+		t.Fatal("only one probe should be allowed while half-open")
+	}
+
+	// A cancelled probe frees its slot
+	b.Cancel()
+	if !b.Allow() {
+		t.Fatal("probe slot should be free after Cancel")
+	}
+
+	// A failed probe reopens the breaker
+	b.Failure("status 502")
+	if b.Allow() || b.Stats().State != StateOpen {
+		t.Fatal("failed probe should reopen the breaker")
+	}
+
+	advance(10 * time.Second)
+	if !b.Allow() {
+		t.Fatal("probe should be allowed after the breaker reopened and waited")
+	}
+	b.Success()
+	if b.Stats().State != StateClosed || !b.Allow() || !b.Allow() {
+		t.Fatal("successful probe should close the breaker")
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	withClock(t)
+	b := New("p", nil)
+	for i := 0; i < 20; i++ {
+		b.Failure("status 503")
+	}
+	if !b.Allow() || !b.Available() {
+		t.Error("disabled breaker should never open")
+	}
+	stats := b.Stats()
+	if stats.State != StateDisabled || stats.ErrorRate != 1 || stats.LastError != "status 503" {
+		t.Errorf("disabled breaker should still track failures: %+v", stats)
+	}
+}
+
+func TestBreaker_ErrorRateWindow(t *testing.T) {
+	b := New("p", nil)
+	for i := 0; i < recentWindow; i++ {
+		b.Failure("boom")
+	}
+	for i := 0; i < recentWindow/2; i++ {
+		b.Success()
+	}
+	stats := b.Stats()
+	if stats.RecentRequests != recentWindow || stats.ErrorRate != 0.5 {
+		t.Errorf("error rate = %v over %d, want 0.5 over %d", stats.ErrorRate, stats.RecentRequests, recentWindow)
+	}
+}
+
+func TestBreaker_NilIsSafe(t *testing.T) {
+	var b *Breaker
+	if !b.Allow() || !b.Available() || b.FirstByteTimeout() != 0 || b.Provider() != "" {
+		t.Error("nil breaker should always allow requests")
+	}
+	b.Success()
+	b.Failure("x")
+	b.Cancel()
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry([]config.Provider{
+		{Name: "a", CircuitBreaker: &config.CircuitBreakerConfig{}},
+		{Name: "b"},
+	})
+	if r.Get("a") == nil || r.Get("b") == nil || r.Get("missing") != nil {
+		t.Fatal("expected one breaker per provider")
+	}
+	stats := r.Stats()
+	if len(stats) != 2 || stats[0].Provider != "a" || stats[0].State != StateClosed || stats[1].State != StateDisabled {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
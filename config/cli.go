@@ -19,8 +19,12 @@ type CLIFlags struct {
 	ConfigFile            string
 	SSELogDir             string
 	Port                  string
+	BindAddress           string
 	ConversationStoreSize int
 	ConversationStoreTTL  string
+	ConversationStorePath string
+	ConfigWatchInterval   string
+	ShutdownTimeout       string
 }
 
 // ParseFlags parses CLI flags and returns the parsed flags.
@@ -34,16 +38,24 @@ func ParseFlags() (CLIFlags, error) {
 	configFilePath := flag.String("config-file", "", "Path to configuration file")
 	sseLogDir := flag.String("sse-log-dir", "", "Directory for SSE request/response logging")
 	port := flag.String("port", "", "Server port (default: 8080)")
+	bindAddress := flag.String("bind", "", "Address to bind the server port to (default: all interfaces)")
 	conversationStoreSize := flag.Int("conversation-store-size", 0, "Max conversations in memory (default: 1000)")
 	conversationStoreTTL := flag.String("conversation-store-ttl", "", "Conversation TTL duration (default: 24h)")
+	conversationStorePath := flag.String("conversation-store-path", "", "File to persist conversations in (default: in-memory only)")
+	configWatchInterval := flag.String("config-watch-interval", "", "How often to check the config file for changes, 0 to disable (default: 5s)")
+	shutdownTimeout := flag.String("shutdown-timeout", "", "How long active streams may finish after SIGTERM before they are cancelled (default: 30s)")
 
 	flag.Parse()
 
 	flags := CLIFlags{
 		SSELogDir:             *sseLogDir,
 		Port:                  *port,
+		BindAddress:           *bindAddress,
 		ConversationStoreSize: *conversationStoreSize,
 		ConversationStoreTTL:  *conversationStoreTTL,
+		ConversationStorePath: *conversationStorePath,
+		ConfigWatchInterval:   *configWatchInterval,
+		ShutdownTimeout:       *shutdownTimeout,
 	}
 
 	// Priority 1: explicit --config-file flag
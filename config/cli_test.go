@@ -181,6 +181,62 @@ func TestParseFlags_MultipleFlags(t *testing.T) {
 	}
 }
 
+func TestParseFlags_ConversationStorePath(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	os.Args = []string{"test", "--config-file=/config.yaml", "--conversation-store-path=/var/lib/ai-proxy/conversations.jsonl"}
+
+	flags, err := ParseFlags()
+
+	if err != nil {
+		t.Errorf("ParseFlags() unexpected error: %v", err)
+	}
+	if flags.ConversationStorePath != "/var/lib/ai-proxy/conversations.jsonl" {
+		t.Errorf("ParseFlags() conversation store path = %q, want /var/lib/ai-proxy/conversations.jsonl", flags.ConversationStorePath)
+	}
+}
+
+func TestParseFlags_ConfigWatchInterval(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	os.Args = []string{"test", "--config-file=/config.yaml", "--config-watch-interval=30s"}
+
+	flags, err := ParseFlags()
+
+	if err != nil {
+		t.Errorf("ParseFlags() unexpected error: %v", err)
+	}
+	if flags.ConfigWatchInterval != "30s" {
+		t.Errorf("ParseFlags() config watch interval = %q, want 30s", flags.ConfigWatchInterval)
+	}
+}
+
+func TestParseFlags_BindAddress(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	os.Args = []string{"test", "--config-file=/config.yaml", "--bind=127.0.0.1"}
+
+	flags, err := ParseFlags()
+
+	if err != nil {
+		t.Errorf("ParseFlags() unexpected error: %v", err)
+	}
+	if flags.BindAddress != "127.0.0.1" {
+		t.Errorf("ParseFlags() bind address = %q, want 127.0.0.1", flags.BindAddress)
+	}
+}
+
+func TestParseFlags_ShutdownTimeout(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	os.Args = []string{"test", "--config-file=/config.yaml", "--shutdown-timeout=1m"}
+
+	flags, err := ParseFlags()
+
+	if err != nil {
+		t.Errorf("ParseFlags() unexpected error: %v", err)
+	}
+	if flags.ShutdownTimeout != "1m" {
+		t.Errorf("ParseFlags() shutdown timeout = %q, want 1m", flags.ShutdownTimeout)
+	}
+}
+
 func TestParseFlags_FlagPrecedenceOverEnv(t *testing.T) {
 	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
 	os.Args = []string{"test", "--config-file=/flag-config.yaml"}
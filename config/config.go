@@ -4,16 +4,21 @@ package config
 
 import (
 	"os"
+	"sync"
 	"time"
 )
 
 // Config holds all configuration settings for the proxy server.
-// All settings are loaded at startup and remain immutable during runtime.
+// Settings are loaded at startup; only the schema from the config file is
+// replaced at runtime, when the file is reloaded.
 type Config struct {
 	// Port is the TCP port on which the proxy server listens.
 	// Default: "8080". Must be a valid port number (1-65535).
 	// Ports below 1024 may require elevated privileges.
 	Port string
+	// BindAddress is the host or IP address the port is bound to.
+	// Default: "" (all interfaces). Ignored if the config file sets listeners.
+	BindAddress string
 	// SSELogDir is the directory path for logging SSE request/response data.
 	// Default: "" (disabled). When set, all requests are logged to JSON files.
 	// Directory must exist and be writable; subdirectories are created per date.
@@ -23,23 +28,38 @@ type Config struct {
 	ConfigFile string
 	// AppConfig holds the loaded JSON configuration schema.
 	// Contains provider definitions, model mappings, and fallback settings.
+	// Once the server is running, read it via GetSchema; reloads replace it via SetSchema.
 	AppConfig *Schema
+	// ConfigWatchInterval is how often the config file is checked for changes.
+	// Default: 5 seconds. 0 disables watching; SIGHUP still reloads the file.
+	ConfigWatchInterval time.Duration
+	// ShutdownTimeout is how long active streams may run after a shutdown signal
+	// before they are cancelled. Default: 30 seconds. 0 cancels them at once.
+	ShutdownTimeout time.Duration
 	// ConversationStoreSize is the maximum number of conversations to store in memory.
 	// Default: 1000. When the limit is reached, oldest conversations are evicted.
 	ConversationStoreSize int
 	// ConversationStoreTTL is the time-to-live for stored conversations.
 	// Default: 24 hours. Conversations older than this are automatically removed.
 	ConversationStoreTTL time.Duration
+	// ConversationStorePath is the file that persists conversations across restarts.
+	// Default: "" (in-memory only). Set via --conversation-store-path flag or
+	// CONVERSATION_STORE_PATH environment variable.
+	ConversationStorePath string
+
+	// mu guards AppConfig against reloads.
+	mu sync.RWMutex
 }
 
 // Load reads configuration from command-line flags, environment variables, and JSON config file.
-// Flags take precedence over environment variables for Port and SSELogDir.
+// Flags take precedence over environment variables for Port, BindAddress and SSELogDir.
 // JSON config file is required and loaded via --config-file flag or CONFIG_FILE env var.
 //
 // @return *Config - a fully initialized Config instance
 // @post All configuration values are populated with resolved values
 // @post Flag.Parse() has been called, consuming command-line arguments
-// @note Environment variables: PORT, SSELOG_DIR, CONFIG_FILE
+// @note Environment variables: PORT, BIND_ADDRESS, SSELOG_DIR, CONFIG_FILE, CONVERSATION_STORE_PATH,
+// CONFIG_WATCH_INTERVAL, SHUTDOWN_TIMEOUT
 func Load() *Config {
 	flags, err := ParseFlags()
 	if err != nil {
@@ -47,9 +67,13 @@ func Load() *Config {
 		// The caller should check AppConfig == nil
 		return &Config{
 			Port:                  getEnvOrFlag("PORT", "", "8080"),
+			BindAddress:           getEnvOrFlag("BIND_ADDRESS", "", ""),
 			SSELogDir:             getEnvOrFlag("SSELOG_DIR", "", ""),
 			ConversationStoreSize: parseConversationStoreSize(flags.ConversationStoreSize),
 			ConversationStoreTTL:  parseConversationStoreTTL(flags.ConversationStoreTTL),
+			ConversationStorePath: getEnvOrFlag("CONVERSATION_STORE_PATH", flags.ConversationStorePath, ""),
+			ConfigWatchInterval:   parseConfigWatchInterval(getEnvOrFlag("CONFIG_WATCH_INTERVAL", flags.ConfigWatchInterval, "")),
+			ShutdownTimeout:       parseShutdownTimeout(getEnvOrFlag("SHUTDOWN_TIMEOUT", flags.ShutdownTimeout, "")),
 		}
 	}
 
@@ -60,21 +84,29 @@ func Load() *Config {
 		// Return config with nil AppConfig, caller should handle error
 		return &Config{
 			Port:                  getEnvOrFlag("PORT", flags.Port, "8080"),
+			BindAddress:           getEnvOrFlag("BIND_ADDRESS", flags.BindAddress, ""),
 			SSELogDir:             getEnvOrFlag("SSELOG_DIR", flags.SSELogDir, ""),
 			ConfigFile:            flags.ConfigFile,
 			ConversationStoreSize: parseConversationStoreSize(flags.ConversationStoreSize),
 			ConversationStoreTTL:  parseConversationStoreTTL(flags.ConversationStoreTTL),
+			ConversationStorePath: getEnvOrFlag("CONVERSATION_STORE_PATH", flags.ConversationStorePath, ""),
+			ConfigWatchInterval:   parseConfigWatchInterval(getEnvOrFlag("CONFIG_WATCH_INTERVAL", flags.ConfigWatchInterval, "")),
+			ShutdownTimeout:       parseShutdownTimeout(getEnvOrFlag("SHUTDOWN_TIMEOUT", flags.ShutdownTimeout, "")),
 		}
 	}
 
 	// Build config with precedence: flag > env var > default
 	return &Config{
 		Port:                  getEnvOrFlag("PORT", flags.Port, "8080"),
+		BindAddress:           getEnvOrFlag("BIND_ADDRESS", flags.BindAddress, ""),
 		SSELogDir:             getEnvOrFlag("SSELOG_DIR", flags.SSELogDir, ""),
 		ConfigFile:            flags.ConfigFile,
 		AppConfig:             appConfig,
 		ConversationStoreSize: parseConversationStoreSize(flags.ConversationStoreSize),
 		ConversationStoreTTL:  parseConversationStoreTTL(flags.ConversationStoreTTL),
+		ConversationStorePath: getEnvOrFlag("CONVERSATION_STORE_PATH", flags.ConversationStorePath, ""),
+		ConfigWatchInterval:   parseConfigWatchInterval(getEnvOrFlag("CONFIG_WATCH_INTERVAL", flags.ConfigWatchInterval, "")),
+		ShutdownTimeout:       parseShutdownTimeout(getEnvOrFlag("SHUTDOWN_TIMEOUT", flags.ShutdownTimeout, "")),
 	}
 }
 
@@ -82,10 +114,23 @@ func Load() *Config {
 // Returns nil if the config file was not loaded successfully.
 //
 // @return *Schema - the loaded schema, or nil if not loaded
+// @note Safe to call concurrently with SetSchema.
 func (c *Config) GetSchema() *Schema {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.AppConfig
 }
 
+// SetSchema replaces the configuration schema with a reloaded one.
+//
+// @param s - the new schema, already validated
+// @post GetSchema returns s
+func (c *Config) SetSchema(s *Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AppConfig = s
+}
+
 // getEnvOrFlag returns the flag value if non-empty, otherwise the environment variable if set, otherwise the default value.
 // This implements the precedence chain: flag > environment > default.
 //
@@ -129,3 +174,31 @@ func parseConversationStoreTTL(ttlStr string) time.Duration {
 	}
 	return ttl
 }
+
+// parseConfigWatchInterval parses the config file watch interval duration string.
+// If the string is empty or invalid, returns the default of 5 seconds.
+// "0" disables watching.
+func parseConfigWatchInterval(intervalStr string) time.Duration {
+	if intervalStr == "" {
+		return 5 * time.Second
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil || interval < 0 {
+		return 5 * time.Second
+	}
+	return interval
+}
+
+// parseShutdownTimeout parses the shutdown drain timeout duration string.
+// If the string is empty or invalid, returns the default of 30 seconds.
+// "0" cancels active streams without waiting.
+func parseShutdownTimeout(timeoutStr string) time.Duration {
+	if timeoutStr == "" {
+		return 30 * time.Second
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil || timeout < 0 {
+		return 30 * time.Second
+	}
+	return timeout
+}
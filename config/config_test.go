@@ -4,12 +4,16 @@ import (
 	"flag"
 	"os"
 	"testing"
+	"time"
 )
 
 func cleanupEnv() {
 	os.Unsetenv("PORT")
+	os.Unsetenv("BIND_ADDRESS")
 	os.Unsetenv("SSELOG_DIR")
 	os.Unsetenv("CONFIG_FILE")
+	os.Unsetenv("CONFIG_WATCH_INTERVAL")
+	os.Unsetenv("SHUTDOWN_TIMEOUT")
 }
 
 func TestGetEnvOrFlag(t *testing.T) {
@@ -206,6 +210,12 @@ func TestLoad_DefaultValues(t *testing.T) {
 	if cfg.AppConfig != nil {
 		t.Errorf("AppConfig should be nil when no config file is provided")
 	}
+	if cfg.ConfigWatchInterval != 5*time.Second {
+		t.Errorf("ConfigWatchInterval = %v, want 5s", cfg.ConfigWatchInterval)
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want 30s", cfg.ShutdownTimeout)
+	}
 }
 
 func TestLoad_EnvironmentVariables(t *testing.T) {
@@ -383,6 +393,51 @@ func TestGetSchema(t *testing.T) {
 	}
 }
 
+func TestSetSchema(t *testing.T) {
+	cfg := &Config{AppConfig: &Schema{}}
+	reloaded := &Schema{Providers: []Provider{{Name: "reloaded"}}}
+	cfg.SetSchema(reloaded)
+	if cfg.GetSchema() != reloaded {
+		t.Error("GetSchema() should return the schema set by SetSchema")
+	}
+}
+
+func TestParseConfigWatchInterval(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 5 * time.Second},
+		{"30s", 30 * time.Second},
+		{"0", 0},
+		{"-1s", 5 * time.Second},
+		{"soon", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := parseConfigWatchInterval(tt.in); got != tt.want {
+			t.Errorf("parseConfigWatchInterval(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"2m", 2 * time.Minute},
+		{"0", 0},
+		{"-1s", 30 * time.Second},
+		{"later", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := parseShutdownTimeout(tt.in); got != tt.want {
+			t.Errorf("parseShutdownTimeout(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
 func TestLoad_PortAndSSELogDirWithConfigFile(t *testing.T) {
 	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
 
@@ -413,6 +468,7 @@ func TestLoad_PortAndSSELogDirWithConfigFile(t *testing.T) {
 	defer func() { os.Args = []string{"test"} }()
 	cleanupEnv()
 	os.Setenv("PORT", "7070")
+	os.Setenv("BIND_ADDRESS", "127.0.0.1")
 	os.Setenv("SSELOG_DIR", "/var/log/test")
 	defer cleanupEnv()
 
@@ -421,6 +477,9 @@ func TestLoad_PortAndSSELogDirWithConfigFile(t *testing.T) {
 	if cfg.Port != "7070" {
 		t.Errorf("Port = %q, want 7070", cfg.Port)
 	}
+	if cfg.BindAddress != "127.0.0.1" {
+		t.Errorf("BindAddress = %q, want 127.0.0.1", cfg.BindAddress)
+	}
 	if cfg.SSELogDir != "/var/log/test" {
 		t.Errorf("SSELogDir = %q, want /var/log/test", cfg.SSELogDir)
 	}
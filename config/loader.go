@@ -4,20 +4,27 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // envVarRegex matches ${VAR_NAME} or $VAR_NAME patterns
 var envVarRegex = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}|\$([a-zA-Z_][a-zA-Z0-9_]*)`)
 
 // isValidProtocol checks if the given string is a valid protocol name.
-// Valid protocols are: "openai", "anthropic", "responses".
+// Valid protocols are: "openai", "anthropic", "responses", "gemini", "ollama".
 func isValidProtocol(p string) bool {
-	return p == "openai" || p == "anthropic" || p == "responses"
+	return p == "openai" || p == "anthropic" || p == "responses" || p == "gemini" || p == "ollama"
 }
 
 // Loader handles loading and validating configuration from JSON files.
@@ -61,10 +68,16 @@ func (l *Loader) Load(path string) (*Schema, error) {
 //   - At least one provider required
 //   - Each provider must have: name, endpoints (at least one)
 //   - Endpoints must use valid protocol names
+//   - A gemini endpoint must name the model with a {model} placeholder
+//   - Dialect must be empty or "azure"; azure providers only have openai and responses endpoints
+//   - Auth type must be bearer, x-api-key, header or query; header and query need a name
 //   - If multiple endpoints, default must be specified and valid
-//   - At least one API key source (apiKey or envApiKey) per provider
+//   - At least one API key source (apiKey, envApiKey, apiKeys or envApiKeys) per provider,
+//     or a credential source instead of all of them
 //   - Model mappings must reference existing providers
 //   - If fallback.enabled, provider must exist
+//   - Each auth key must have exactly one key source and a user
+//   - Limits and prices must not be negative
 //
 // @param s - the schema to validate
 // @return error - a descriptive error if validation fails, nil otherwise
@@ -95,19 +108,29 @@ func (l *Loader) validate(s *Schema) error {
 		}
 
 		// Validate endpoints format
-		for protocol := range p.Endpoints {
+		for protocol, endpoint := range p.Endpoints {
 			if !isValidProtocol(protocol) {
-				return fmt.Errorf("provider '%s': invalid protocol '%s' in endpoints (must be openai, anthropic, or responses)", p.Name, protocol)
+				return fmt.Errorf("provider '%s': invalid protocol '%s' in endpoints (must be openai, anthropic, responses, gemini, or ollama)", p.Name, protocol)
+			}
+			if protocol == "gemini" && !strings.Contains(endpoint, ModelPlaceholder) {
+				return fmt.Errorf("provider '%s': gemini endpoint must contain %s, e.g. .../models/%s:streamGenerateContent?alt=sse", p.Name, ModelPlaceholder, ModelPlaceholder)
 			}
 		}
 
+		if err := validateDialect(&p); err != nil {
+			return fmt.Errorf("provider '%s': %w", p.Name, err)
+		}
+		if err := validateProviderAuth(p.Auth); err != nil {
+			return fmt.Errorf("provider '%s': auth: %w", p.Name, err)
+		}
+
 		// Multi-protocol providers must have a default if more than one endpoint
 		if len(p.Endpoints) > 1 {
 			if p.Default == "" {
 				return fmt.Errorf("provider '%s': 'default' field is required when multiple endpoints are configured", p.Name)
 			}
 			if !isValidProtocol(p.Default) {
-				return fmt.Errorf("provider '%s': default protocol '%s' is invalid (must be openai, anthropic, or responses)", p.Name, p.Default)
+				return fmt.Errorf("provider '%s': default protocol '%s' is invalid (must be openai, anthropic, responses, gemini, or ollama)", p.Name, p.Default)
 			}
 			if _, exists := p.Endpoints[p.Default]; !exists {
 				return fmt.Errorf("provider '%s': default protocol '%s' not found in endpoints", p.Name, p.Default)
@@ -115,21 +138,53 @@ func (l *Loader) validate(s *Schema) error {
 		} else if p.Default != "" {
 			// Single-endpoint providers: validate Default if explicitly set
 			if !isValidProtocol(p.Default) {
-				return fmt.Errorf("provider '%s': default protocol '%s' is invalid (must be openai, anthropic, or responses)", p.Name, p.Default)
+				return fmt.Errorf("provider '%s': default protocol '%s' is invalid (must be openai, anthropic, responses, gemini, or ollama)", p.Name, p.Default)
 			}
 			if _, exists := p.Endpoints[p.Default]; !exists {
 				return fmt.Errorf("provider '%s': default protocol '%s' not found in endpoints", p.Name, p.Default)
 			}
 		}
 
-		// Validate at least one API key source
-		if p.APIKey == "" && p.EnvAPIKey == "" {
+		// Validate at least one API key source, or a credential source instead
+		hasStaticKey := p.APIKey != "" || p.EnvAPIKey != "" || len(p.APIKeys) > 0 || len(p.EnvAPIKeys) > 0
+		if p.Credential != nil {
+			if hasStaticKey {
+				return fmt.Errorf("provider '%s': credential cannot be combined with apiKey, envApiKey, apiKeys or envApiKeys", p.Name)
+			}
+			if err := validateCredential(p.Credential); err != nil {
+				return fmt.Errorf("provider '%s': credential: %w", p.Name, err)
+			}
+		} else if !hasStaticKey {
 			return fmt.Errorf("provider '%s': at least one of apiKey or envApiKey is required", p.Name)
 		}
 
+		if p.KeyEjectDuration != "" {
+			if d, err := time.ParseDuration(p.KeyEjectDuration); err != nil || d < 0 {
+				return fmt.Errorf("provider '%s': key_eject_duration must be a non-negative duration, got %q", p.Name, p.KeyEjectDuration)
+			}
+		}
+
 		providerNames[p.Name] = true
 	}
 
+	for _, p := range s.Providers {
+		if err := validateRetry(p.Retry); err != nil {
+			return fmt.Errorf("provider '%s': retry: %w", p.Name, err)
+		}
+		if err := validateCircuitBreaker(p.CircuitBreaker); err != nil {
+			return fmt.Errorf("provider '%s': circuit_breaker: %w", p.Name, err)
+		}
+		if err := validateConnectionPool(p.ConnectionPool); err != nil {
+			return fmt.Errorf("provider '%s': connection_pool: %w", p.Name, err)
+		}
+		if err := validateTLS(p.TLS); err != nil {
+			return fmt.Errorf("provider '%s': tls: %w", p.Name, err)
+		}
+		if err := validateProxyURL(p.ProxyURL); err != nil {
+			return fmt.Errorf("provider '%s': %w", p.Name, err)
+		}
+	}
+
 	// Validate model mappings reference existing providers
 	for name, mc := range s.Models {
 		if !providerNames[mc.Provider] {
@@ -140,6 +195,34 @@ func (l *Loader) validate(s *Schema) error {
 		if mc.Type != "" && mc.Type != "openai" && mc.Type != "anthropic" && mc.Type != "auto" {
 			return fmt.Errorf("model '%s': type must be 'openai', 'anthropic', or 'auto'", name)
 		}
+
+		if mc.MaxContextTokens < 0 {
+			return fmt.Errorf("model '%s': max_context_tokens must not be negative", name)
+		}
+
+		// Validate fallback routes like the primary route
+		for i, fr := range mc.Fallbacks {
+			if !providerNames[fr.Provider] {
+				return fmt.Errorf("model '%s': fallbacks[%d] references unknown provider '%s'", name, i, fr.Provider)
+			}
+			if fr.Model == "" {
+				return fmt.Errorf("model '%s': fallbacks[%d]: model is required", name, i)
+			}
+			if fr.Type != "" && fr.Type != "openai" && fr.Type != "anthropic" && fr.Type != "auto" {
+				return fmt.Errorf("model '%s': fallbacks[%d]: type must be 'openai', 'anthropic', or 'auto'", name, i)
+			}
+		}
+
+		if err := validateLoadBalance(mc.LoadBalance, providerNames); err != nil {
+			return fmt.Errorf("model '%s': load_balance: %w", name, err)
+		}
+		if err := validateLimits(mc.Limits); err != nil {
+			return fmt.Errorf("model '%s': limits: %w", name, err)
+		}
+	}
+
+	if s.Responses.MaxContextTokens < 0 {
+		return fmt.Errorf("responses: max_context_tokens must not be negative")
 	}
 
 	// Validate fallback configuration
@@ -154,6 +237,388 @@ func (l *Loader) validate(s *Schema) error {
 		}
 	}
 
+	if err := validateAuth(s.Auth); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	for model, p := range s.Pricing {
+		if p.Input < 0 || p.Output < 0 || p.CacheRead < 0 || p.CacheWrite < 0 {
+			return fmt.Errorf("pricing '%s': prices must not be negative", model)
+		}
+	}
+
+	for i, lc := range s.Listeners {
+		if err := validateListener(lc); err != nil {
+			return fmt.Errorf("listeners[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validateDialect checks the provider's dialect and that its endpoints use
+// protocols the dialect supports.
+//
+// @param p - the provider to validate
+// @return error - a descriptive error if validation fails, nil otherwise
+func validateDialect(p *Provider) error {
+	switch p.Dialect {
+	case "":
+		return nil
+	case DialectAzure:
+		for protocol := range p.Endpoints {
+			if protocol != "openai" && protocol != "responses" {
+				return fmt.Errorf("azure dialect supports openai and responses endpoints, got '%s'", protocol)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("dialect must be empty or azure, got %q", p.Dialect)
+	}
+}
+
+// validateProviderAuth checks how a provider's API key is sent upstream.
+//
+// @param a - the provider's auth configuration, may be nil
+// @return error - a descriptive error if validation fails, nil otherwise
+func validateProviderAuth(a *ProviderAuth) error {
+	if a == nil {
+		return nil
+	}
+	switch a.Type {
+	case AuthBearer, AuthXAPIKey:
+		return nil
+	case AuthHeader, AuthQuery:
+		if a.Name == "" {
+			return fmt.Errorf("name is required for type %s", a.Type)
+		}
+		return nil
+	default:
+		return fmt.Errorf("type must be bearer, x-api-key, header or query, got %q", a.Type)
+	}
+}
+
+// validateCredential checks where a provider's API key is fetched from.
+//
+// @param cr - the credential configuration, not nil
+// @return error - if the type is unknown, a field it needs is missing, or a
+// duration does not parse
+func validateCredential(cr *CredentialConfig) error {
+	switch cr.Type {
+	case CredentialFile:
+		if cr.Path == "" {
+			return fmt.Errorf("path is required for type file")
+		}
+	case CredentialCommand:
+		if len(cr.Command) == 0 || cr.Command[0] == "" {
+			return fmt.Errorf("command is required for type command")
+		}
+	case CredentialOAuth2:
+		if cr.TokenURL == "" || cr.ClientID == "" || cr.ClientSecret == "" {
+			return fmt.Errorf("token_url, client_id and client_secret are required for type oauth2")
+		}
+		if u, err := url.Parse(cr.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("token_url must be an http or https URL, got %q", cr.TokenURL)
+		}
+	default:
+		return fmt.Errorf("type must be file, command or oauth2, got %q", cr.Type)
+	}
+	durations := []struct{ name, value string }{
+		{"command_timeout", cr.CommandTimeout},
+		{"refresh_interval", cr.RefreshInterval},
+		{"refresh_before", cr.RefreshBefore},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed < 0 {
+			return fmt.Errorf("%s must be a non-negative duration, got %q", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// validateTLS checks a provider TLS configuration and loads its files the way
+// the router does, so that a configuration the router cannot use is rejected
+// at startup and on reload.
+//
+// @param t - the TLS configuration, may be nil
+// @return error - if only one of cert_file and key_file is set, ca_file holds
+// no certificate, or the client certificate cannot be loaded
+func validateTLS(t *TLSConfig) error {
+	if t == nil {
+		return nil
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return fmt.Errorf("ca_file: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ca_file: no certificates found in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateProxyURL checks a provider's outbound proxy URL.
+//
+// @param proxyURL - the proxy URL, may be empty
+// @return error - if the URL does not parse or its scheme is not http, https or socks5
+func validateProxyURL(proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("proxy_url must be a URL with a host, got %q", proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	default:
+		return fmt.Errorf("proxy_url scheme must be http, https or socks5, got %q", u.Scheme)
+	}
+}
+
+// validateListener checks where the server accepts connections.
+//
+// @param lc - the listener configuration
+// @return error - if the type is unknown, a field it needs is missing, or a
+// field does not apply to the type
+func validateListener(lc ListenerConfig) error {
+	switch lc.Type {
+	case ListenerTCP:
+		if _, _, err := net.SplitHostPort(lc.Address); err != nil {
+			return fmt.Errorf("address must be host:port for type tcp, got %q", lc.Address)
+		}
+	case ListenerUnix:
+		if lc.Path == "" {
+			return fmt.Errorf("path is required for type unix")
+		}
+		if lc.TLS != nil {
+			return fmt.Errorf("tls is not supported for type unix")
+		}
+		if lc.Mode != "" {
+			if mode, err := strconv.ParseUint(lc.Mode, 8, 32); err != nil || mode > 0777 {
+				return fmt.Errorf("mode must be an octal file mode such as \"0660\", got %q", lc.Mode)
+			}
+		}
+	case ListenerSystemd:
+	default:
+		return fmt.Errorf("type must be tcp, unix or systemd, got %q", lc.Type)
+	}
+	if lc.TLS != nil && (lc.TLS.CertFile == "" || lc.TLS.KeyFile == "") {
+		return fmt.Errorf("tls: cert_file and key_file are required")
+	}
+	return nil
+}
+
+// validateAuth checks the client authentication configuration.
+//
+// @param a - the auth configuration, may be nil
+// @return error - if no key is configured or a key is incomplete or ambiguous
+func validateAuth(a *AuthConfig) error {
+	if a == nil {
+		return nil
+	}
+	if len(a.Keys) == 0 {
+		return fmt.Errorf("at least one key is required")
+	}
+	for i, k := range a.Keys {
+		sources := 0
+		for _, v := range []string{k.Key, k.EnvKey, k.KeyHash} {
+			if v != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("keys[%d]: exactly one of key, env_key or key_hash is required", i)
+		}
+		if k.KeyHash != "" && !isSHA256Hash(k.KeyHash) {
+			return fmt.Errorf("keys[%d]: key_hash must be %q followed by 64 hex digits", i, KeyHashPrefix)
+		}
+		if k.User == "" {
+			return fmt.Errorf("keys[%d]: user is required", i)
+		}
+		for _, m := range k.Models {
+			if m == "" {
+				return fmt.Errorf("keys[%d]: models must not contain empty names", i)
+			}
+		}
+		if err := validateLimits(k.Limits); err != nil {
+			return fmt.Errorf("keys[%d]: limits: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateLimits checks a rate limit and quota configuration.
+//
+// @param l - the limits, may be nil
+// @return error - if a limit is negative
+func validateLimits(l *LimitsConfig) error {
+	if l == nil {
+		return nil
+	}
+	if l.RequestsPerMinute < 0 || l.Burst < 0 {
+		return fmt.Errorf("requests_per_minute and burst must not be negative")
+	}
+	if l.TokensPerHour < 0 || l.TokensPerDay < 0 {
+		return fmt.Errorf("token quotas must not be negative")
+	}
+	if l.SpendPerHour < 0 || l.SpendPerDay < 0 {
+		return fmt.Errorf("spend quotas must not be negative")
+	}
+	return nil
+}
+
+// isSHA256Hash reports whether s is a KeyHashPrefix-prefixed hex SHA-256 digest.
+func isSHA256Hash(s string) bool {
+	digest, ok := strings.CutPrefix(s, KeyHashPrefix)
+	if !ok || len(digest) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// validateRetry checks a provider retry policy.
+//
+// @param r - the retry configuration, may be nil
+// @return error - if a duration does not parse or a value is out of range
+func validateRetry(r *RetryConfig) error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative")
+	}
+	durations := []struct{ name, value string }{
+		{"initial_backoff", r.InitialBackoff},
+		{"max_backoff", r.MaxBackoff},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed < 0 {
+			return fmt.Errorf("%s must be a non-negative duration, got %q", d.name, d.value)
+		}
+	}
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1")
+	}
+	if r.Jitter != nil && (*r.Jitter < 0 || *r.Jitter > 1) {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	for _, status := range r.RetryableStatuses {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid retryable status %d", status)
+		}
+	}
+	return nil
+}
+
+// validateCircuitBreaker checks a provider circuit breaker configuration.
+//
+// @param cb - the circuit breaker configuration, may be nil
+// @return error - if a duration does not parse or a value is out of range
+func validateCircuitBreaker(cb *CircuitBreakerConfig) error {
+	if cb == nil {
+		return nil
+	}
+	if cb.FailureThreshold < 0 {
+		return fmt.Errorf("failure_threshold must not be negative")
+	}
+	if cb.HalfOpenRequests < 0 {
+		return fmt.Errorf("half_open_requests must not be negative")
+	}
+	durations := []struct{ name, value string }{
+		{"open_duration", cb.OpenDuration},
+		{"first_byte_timeout", cb.FirstByteTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed < 0 {
+			return fmt.Errorf("%s must be a non-negative duration, got %q", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// validateConnectionPool checks a provider connection pool configuration.
+//
+// @param cp - the connection pool configuration, may be nil
+// @return error - if a duration does not parse or a limit is negative
+func validateConnectionPool(cp *ConnectionPoolConfig) error {
+	if cp == nil {
+		return nil
+	}
+	if cp.MaxIdleConns < 0 {
+		return fmt.Errorf("max_idle_conns must not be negative")
+	}
+	if cp.MaxConns < 0 {
+		return fmt.Errorf("max_conns must not be negative")
+	}
+	durations := []struct{ name, value string }{
+		{"idle_timeout", cp.IdleTimeout},
+		{"dial_timeout", cp.DialTimeout},
+		{"tls_handshake_timeout", cp.TLSHandshakeTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed < 0 {
+			return fmt.Errorf("%s must be a non-negative duration, got %q", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// validateLoadBalance checks a model's load-balancing configuration.
+//
+// @param lb - the load-balancing configuration, may be nil
+// @param providerNames - the set of configured provider names
+// @return error - if the strategy is unknown or a target or weight is invalid
+func validateLoadBalance(lb *LoadBalanceConfig, providerNames map[string]bool) error {
+	if lb == nil {
+		return nil
+	}
+	switch lb.Strategy {
+	case "", "round_robin", "weighted", "least_in_flight":
+	default:
+		return fmt.Errorf("strategy must be 'round_robin', 'weighted', or 'least_in_flight'")
+	}
+	if lb.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+	for i, t := range lb.Targets {
+		if !providerNames[t.Provider] {
+			return fmt.Errorf("targets[%d] references unknown provider '%s'", i, t.Provider)
+		}
+		if t.Model == "" {
+			return fmt.Errorf("targets[%d]: model is required", i)
+		}
+		if t.Type != "" && t.Type != "openai" && t.Type != "anthropic" && t.Type != "auto" {
+			return fmt.Errorf("targets[%d]: type must be 'openai', 'anthropic', or 'auto'", i)
+		}
+		if t.Weight < 0 {
+			return fmt.Errorf("targets[%d]: weight must not be negative", i)
+		}
+	}
 	return nil
 }
 
@@ -172,6 +637,22 @@ func (l *Loader) resolveEnvVars(s *Schema) {
 		if p.APIKey == "" && p.EnvAPIKey != "" {
 			p.APIKey = os.Getenv(p.EnvAPIKey)
 		}
+		for j := range p.APIKeys {
+			p.APIKeys[j] = expandEnvVars(p.APIKeys[j])
+		}
+		for name, value := range p.Headers {
+			p.Headers[name] = expandEnvVars(value)
+		}
+		if cr := p.Credential; cr != nil {
+			cr.ClientID = expandEnvVars(cr.ClientID)
+			cr.ClientSecret = expandEnvVars(cr.ClientSecret)
+		}
+	}
+
+	if s.Auth != nil {
+		for i := range s.Auth.Keys {
+			s.Auth.Keys[i].Key = expandEnvVars(s.Auth.Keys[i].Key)
+		}
 	}
 
 	// Expand environment variables in websearch config
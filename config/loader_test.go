@@ -1,10 +1,18 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewLoader(t *testing.T) {
@@ -158,7 +166,46 @@ func TestLoaderLoad(t *testing.T) {
 	})
 }
 
+// writeTLSFiles writes a self-signed certificate, usable as CA bundle and
+// client certificate, and its key to a temporary directory.
+func writeTLSFiles(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ai-proxy"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate returned error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey returned error: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	return certFile, keyFile
+}
+
 func TestLoaderValidate(t *testing.T) {
+	certFile, keyFile := writeTLSFiles(t)
+	notPEM := filepath.Join(t.TempDir(), "not-pem.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0600)
+
 	tests := []struct {
 		name        string
 		schema      Schema
@@ -275,14 +322,13 @@ func TestLoaderValidate(t *testing.T) {
 			errContains: "invalid protocol",
 		},
 		{
-			name: "multi-endpoint provider missing default",
+			name: "gemini endpoint with model placeholder",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
+						Name: "google",
 						Endpoints: map[string]string{
-							"openai":    "https://api.example.com/v1/chat/completions",
-							"anthropic": "https://api.example.com/anthropic/v1/messages",
+							"gemini": "https://generativelanguage.googleapis.com/v1beta/models/{model}:streamGenerateContent?alt=sse",
 						},
 						APIKey: "key",
 					},
@@ -290,204 +336,172 @@ func TestLoaderValidate(t *testing.T) {
 				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
-			wantErr:     true,
-			errContains: "'default' field is required",
+			wantErr: false,
 		},
 		{
-			name: "multi-endpoint provider with invalid default",
+			name: "gemini endpoint without model placeholder",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
+						Name: "google",
 						Endpoints: map[string]string{
-							"openai":    "https://api.example.com/v1/chat/completions",
-							"anthropic": "https://api.example.com/anthropic/v1/messages",
+							"gemini": "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse",
 						},
-						Default: "invalid",
-						APIKey:  "key",
+						APIKey: "key",
 					},
 				},
 				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
 			wantErr:     true,
-			errContains: "default protocol 'invalid' is invalid",
+			errContains: "gemini endpoint must contain {model}",
 		},
 		{
-			name: "multi-endpoint provider with default not in endpoints",
+			name: "ollama endpoint",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
+						Name: "gpu-box",
 						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
+							"ollama": "http://gpu-box:11434/api/chat",
 						},
-						Default: "anthropic",
-						APIKey:  "key",
+						APIKey: "ollama",
 					},
 				},
 				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
-			wantErr:     true,
-			errContains: "default protocol 'anthropic' not found in endpoints",
+			wantErr: false,
 		},
 		{
-			name: "provider missing api key sources",
+			name: "azure dialect",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
+						Name:    "azure",
+						Dialect: "azure",
 						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
+							"openai":    "https://res.openai.azure.com/openai/deployments/{model}/chat/completions",
+							"responses": "https://res.openai.azure.com/openai/responses",
 						},
-						APIKey:    "",
-						EnvAPIKey: "",
+						Default:     "openai",
+						QueryParams: map[string]string{"api-version": "2024-10-21"},
+						APIKey:      "key",
 					},
 				},
 				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
-			wantErr:     true,
-			errContains: "at least one of apiKey or envApiKey is required",
+			wantErr: false,
 		},
 		{
-			name: "model references unknown provider",
+			name: "azure dialect with anthropic endpoint",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "known-provider",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey: "key",
+						Name:      "azure",
+						Dialect:   "azure",
+						Endpoints: map[string]string{"anthropic": "https://res.openai.azure.com/anthropic/v1/messages"},
+						APIKey:    "key",
 					},
 				},
-				Models: map[string]ModelConfig{
-					"test-model": {Provider: "unknown-provider", Model: "model-v1"},
-				},
+				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
 			wantErr:     true,
-			errContains: "model 'test-model' references unknown provider 'unknown-provider'",
+			errContains: "azure dialect supports openai and responses endpoints",
 		},
 		{
-			name: "fallback references unknown provider",
+			name: "unknown dialect",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "known-provider",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey: "key",
+						Name:      "test",
+						Dialect:   "bedrock",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
 					},
 				},
-				Models: map[string]ModelConfig{},
-				Fallback: FallbackConfig{
-					Enabled:  true,
-					Provider: "unknown-provider",
-					Model:    "fallback-model",
-				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
 			wantErr:     true,
-			errContains: "fallback references unknown provider 'unknown-provider'",
+			errContains: "dialect must be empty or azure",
 		},
 		{
-			name: "fallback disabled with missing provider is ok",
+			name: "provider auth header",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "known-provider",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey: "key",
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gw.example.com/v1/chat/completions"},
+						Auth:      &ProviderAuth{Type: "header", Name: "X-Gateway-Key"},
+						Headers:   map[string]string{"X-Tenant-Id": "acme"},
+						APIKey:    "key",
 					},
 				},
-				Models: map[string]ModelConfig{},
-				Fallback: FallbackConfig{
-					Enabled:  false,
-					Provider: "unknown-provider",
-					Model:    "fallback-model",
-				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
 			wantErr: false,
 		},
 		{
-			name: "provider with only envApiKey",
+			name: "provider auth query without name",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
-						Endpoints: map[string]string{
-							"anthropic": "https://api.example.com/v1/messages",
-						},
-						EnvAPIKey: "MY_API_KEY",
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gw.example.com/v1/chat/completions"},
+						Auth:      &ProviderAuth{Type: "query"},
+						APIKey:    "key",
 					},
 				},
 				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
-			wantErr: false,
+			wantErr:     true,
+			errContains: "auth: name is required for type query",
 		},
 		{
-			name: "provider with both apiKey and envApiKey",
+			name: "provider auth unknown type",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "direct-key",
-						EnvAPIKey: "MY_API_KEY",
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gw.example.com/v1/chat/completions"},
+						Auth:      &ProviderAuth{Type: "basic"},
+						APIKey:    "key",
 					},
 				},
 				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
-			wantErr: false,
+			wantErr:     true,
+			errContains: "auth: type must be bearer, x-api-key, header or query",
 		},
 		{
-			name: "multiple providers with mixed protocols",
+			name: "credential file",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "openai-provider",
-						Endpoints: map[string]string{
-							"openai": "https://api.openai.com/v1/chat/completions",
-						},
-						APIKey: "openai-key",
-					},
-					{
-						Name: "anthropic-provider",
-						Endpoints: map[string]string{
-							"anthropic": "https://api.anthropic.com/v1/messages",
-						},
-						APIKey: "anthropic-key",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "file", Path: "/run/secrets/llm-key"},
 					},
 				},
-				Models: map[string]ModelConfig{
-					"gpt-4":    {Provider: "openai-provider", Model: "gpt-4-turbo"},
-					"claude-3": {Provider: "anthropic-provider", Model: "claude-3-opus"},
-				},
+				Models:   map[string]ModelConfig{},
 				Fallback: FallbackConfig{Enabled: false},
 			},
 			wantErr: false,
 		},
 		{
-			name: "multi-protocol provider with valid default",
+			name: "credential oauth2",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "multi",
-						Endpoints: map[string]string{
-							"openai":    "https://api.example.com/v1/chat/completions",
-							"anthropic": "https://api.example.com/anthropic/v1/messages",
-						},
-						Default: "openai",
-						APIKey:  "key",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "oauth2", TokenURL: "https://login.example.com/oauth2/token", ClientID: "proxy", ClientSecret: "${CLIENT_SECRET}", RefreshBefore: "2m"},
 					},
 				},
 				Models:   map[string]ModelConfig{},
@@ -495,147 +509,1181 @@ func TestLoaderValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			err := loader.validate(&tt.schema)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("validate() expected error containing %q, got nil", tt.errContains)
-					return
-				}
-				if tt.errContains != "" && !containsString(err.Error(), tt.errContains) {
-					t.Errorf("validate() error = %q, want error containing %q", err.Error(), tt.errContains)
-				}
-			} else {
-				if err != nil {
-					t.Errorf("validate() unexpected error: %v", err)
-				}
-			}
-		})
-	}
-}
-
-func TestLoaderResolveEnvVars(t *testing.T) {
-	tests := []struct {
-		name        string
-		schema      Schema
-		envVars     map[string]string
-		wantAPIKeys map[string]string
-	}{
 		{
-			name: "resolve envApiKey when apiKey is empty",
+			name: "credential with apiKey",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "",
-						EnvAPIKey: "TEST_KEY_1",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "file", Path: "/run/secrets/llm-key"},
+						APIKey:     "key",
 					},
 				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
-			envVars:     map[string]string{"TEST_KEY_1": "resolved-key-1"},
-			wantAPIKeys: map[string]string{"test": "resolved-key-1"},
+			wantErr:     true,
+			errContains: "credential cannot be combined with apiKey",
 		},
 		{
-			name: "do not override existing apiKey",
+			name: "credential command missing",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "direct-key",
-						EnvAPIKey: "TEST_KEY_2",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "command"},
 					},
 				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
-			envVars:     map[string]string{"TEST_KEY_2": "env-key-should-not-be-used"},
-			wantAPIKeys: map[string]string{"test": "direct-key"},
+			wantErr:     true,
+			errContains: "credential: command is required for type command",
 		},
 		{
-			name: "empty env var results in empty apiKey",
+			name: "credential oauth2 missing client",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "",
-						EnvAPIKey: "NON_EXISTENT_KEY",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "oauth2", TokenURL: "https://login.example.com/oauth2/token"},
 					},
 				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
-			envVars:     map[string]string{},
-			wantAPIKeys: map[string]string{"test": ""},
+			wantErr:     true,
+			errContains: "credential: token_url, client_id and client_secret are required",
 		},
 		{
-			name: "multiple providers with mixed resolution",
+			name: "credential oauth2 invalid token url",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "provider1",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "",
-						EnvAPIKey: "KEY_1",
-					},
-					{
-						Name: "provider2",
-						Endpoints: map[string]string{
-							"anthropic": "https://api.anthropic.com/v1/messages",
-						},
-						APIKey:    "direct-key",
-						EnvAPIKey: "KEY_2",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "oauth2", TokenURL: "login.example.com", ClientID: "a", ClientSecret: "b"},
 					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "credential: token_url must be an http or https URL",
+		},
+		{
+			name: "credential invalid refresh interval",
+			schema: Schema{
+				Providers: []Provider{
 					{
-						Name: "provider3",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "",
-						EnvAPIKey: "KEY_3",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "file", Path: "key", RefreshInterval: "soon"},
 					},
 				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
-			envVars: map[string]string{
-				"KEY_1": "env-key-1",
-				"KEY_3": "env-key-3",
-			},
-			wantAPIKeys: map[string]string{
-				"provider1": "env-key-1",
-				"provider2": "direct-key",
-				"provider3": "env-key-3",
-			},
+			wantErr:     true,
+			errContains: "credential: refresh_interval must be a non-negative duration",
 		},
 		{
-			name: "provider with no envApiKey",
+			name: "credential unknown type",
 			schema: Schema{
 				Providers: []Provider{
 					{
-						Name: "test",
-						Endpoints: map[string]string{
-							"openai": "https://api.example.com/v1/chat/completions",
-						},
-						APIKey:    "",
-						EnvAPIKey: "",
+						Name:       "secure",
+						Endpoints:  map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						Credential: &CredentialConfig{Type: "vault"},
 					},
 				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
 			},
-			envVars:     map[string]string{},
-			wantAPIKeys: map[string]string{"test": ""},
+			wantErr:     true,
+			errContains: "credential: type must be file, command or oauth2",
 		},
-	}
-
+		{
+			name: "connection pool valid",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:           "pooled",
+						Endpoints:      map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						ConnectionPool: &ConnectionPoolConfig{MaxIdleConns: 64, MaxConns: 128, IdleTimeout: "2m", DialTimeout: "5s", TLSHandshakeTimeout: "5s", DisableHTTP2: true},
+						APIKey:         "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "connection pool negative max_conns",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:           "pooled",
+						Endpoints:      map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						ConnectionPool: &ConnectionPoolConfig{MaxConns: -1},
+						APIKey:         "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "connection_pool: max_conns must not be negative",
+		},
+		{
+			name: "connection pool invalid idle_timeout",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:           "pooled",
+						Endpoints:      map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						ConnectionPool: &ConnectionPoolConfig{IdleTimeout: "forever"},
+						APIKey:         "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "connection_pool: idle_timeout must be a non-negative duration",
+		},
+		{
+			name: "valid listeners",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{},
+				Listeners: []ListenerConfig{
+					{Type: ListenerTCP, Address: "127.0.0.1:8443", TLS: &ListenerTLSConfig{CertFile: "server.pem", KeyFile: "server-key.pem"}},
+					{Type: ListenerUnix, Path: "/run/ai-proxy/ai-proxy.sock", Mode: "0660", Group: "developers"},
+					{Type: ListenerSystemd, Name: "https"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "listener unknown type",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models:    map[string]ModelConfig{},
+				Listeners: []ListenerConfig{{Type: "udp", Address: ":8080"}},
+			},
+			wantErr:     true,
+			errContains: "listeners[0]: type must be tcp, unix or systemd",
+		},
+		{
+			name: "tcp listener without port",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models:    map[string]ModelConfig{},
+				Listeners: []ListenerConfig{{Type: ListenerTCP, Address: "127.0.0.1"}},
+			},
+			wantErr:     true,
+			errContains: "listeners[0]: address must be host:port",
+		},
+		{
+			name: "unix listener without path",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models:    map[string]ModelConfig{},
+				Listeners: []ListenerConfig{{Type: ListenerUnix}},
+			},
+			wantErr:     true,
+			errContains: "listeners[0]: path is required for type unix",
+		},
+		{
+			name: "unix listener invalid mode",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models:    map[string]ModelConfig{},
+				Listeners: []ListenerConfig{{Type: ListenerUnix, Path: "/tmp/ai-proxy.sock", Mode: "rw-rw----"}},
+			},
+			wantErr:     true,
+			errContains: "listeners[0]: mode must be an octal file mode",
+		},
+		{
+			name: "unix listener with tls",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models:    map[string]ModelConfig{},
+				Listeners: []ListenerConfig{{Type: ListenerUnix, Path: "/tmp/ai-proxy.sock", TLS: &ListenerTLSConfig{CertFile: "c", KeyFile: "k"}}},
+			},
+			wantErr:     true,
+			errContains: "listeners[0]: tls is not supported for type unix",
+		},
+		{
+			name: "listener tls without key",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "openai", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models:    map[string]ModelConfig{},
+				Listeners: []ListenerConfig{{Type: ListenerSystemd, TLS: &ListenerTLSConfig{CertFile: "server.pem"}}},
+			},
+			wantErr:     true,
+			errContains: "listeners[0]: tls: cert_file and key_file are required",
+		},
+		{
+			name: "valid tls and proxy_url",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						TLS:       &TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "gateway"},
+						ProxyURL:  "http://egress.internal:3128",
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tls cert_file without key_file",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						TLS:       &TLSConfig{CertFile: "/etc/ai-proxy/client.pem"},
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "tls: cert_file and key_file must be set together",
+		},
+		{
+			name: "tls ca_file missing",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						TLS:       &TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "provider 'gateway': tls: ca_file",
+		},
+		{
+			name: "tls ca_file without certificates",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						TLS:       &TLSConfig{CAFile: notPEM},
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "tls: ca_file: no certificates found",
+		},
+		{
+			name: "tls client key not matching certificate",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						TLS:       &TLSConfig{CertFile: certFile, KeyFile: notPEM},
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "tls: client certificate",
+		},
+		{
+			name: "proxy_url without host",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						ProxyURL:  "egress.internal:3128",
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "proxy_url must be a URL with a host",
+		},
+		{
+			name: "proxy_url unsupported scheme",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "gateway",
+						Endpoints: map[string]string{"openai": "https://gateway.internal/v1/chat/completions"},
+						ProxyURL:  "ftp://egress.internal:21",
+						APIKey:    "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "proxy_url scheme must be http, https or socks5",
+		},
+		{
+			name: "multi-endpoint provider missing default",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai":    "https://api.example.com/v1/chat/completions",
+							"anthropic": "https://api.example.com/anthropic/v1/messages",
+						},
+						APIKey: "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "'default' field is required",
+		},
+		{
+			name: "multi-endpoint provider with invalid default",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai":    "https://api.example.com/v1/chat/completions",
+							"anthropic": "https://api.example.com/anthropic/v1/messages",
+						},
+						Default: "invalid",
+						APIKey:  "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "default protocol 'invalid' is invalid",
+		},
+		{
+			name: "multi-endpoint provider with default not in endpoints",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						Default: "anthropic",
+						APIKey:  "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "default protocol 'anthropic' not found in endpoints",
+		},
+		{
+			name: "provider missing api key sources",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "",
+						EnvAPIKey: "",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "at least one of apiKey or envApiKey is required",
+		},
+		{
+			name: "model references unknown provider",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "known-provider",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey: "key",
+					},
+				},
+				Models: map[string]ModelConfig{
+					"test-model": {Provider: "unknown-provider", Model: "model-v1"},
+				},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr:     true,
+			errContains: "model 'test-model' references unknown provider 'unknown-provider'",
+		},
+		{
+			name: "fallback references unknown provider",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "known-provider",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey: "key",
+					},
+				},
+				Models: map[string]ModelConfig{},
+				Fallback: FallbackConfig{
+					Enabled:  true,
+					Provider: "unknown-provider",
+					Model:    "fallback-model",
+				},
+			},
+			wantErr:     true,
+			errContains: "fallback references unknown provider 'unknown-provider'",
+		},
+		{
+			name: "fallback disabled with missing provider is ok",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "known-provider",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey: "key",
+					},
+				},
+				Models: map[string]ModelConfig{},
+				Fallback: FallbackConfig{
+					Enabled:  false,
+					Provider: "unknown-provider",
+					Model:    "fallback-model",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "provider with only envApiKey",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"anthropic": "https://api.example.com/v1/messages",
+						},
+						EnvAPIKey: "MY_API_KEY",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "provider with both apiKey and envApiKey",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "direct-key",
+						EnvAPIKey: "MY_API_KEY",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "multiple providers with mixed protocols",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "openai-provider",
+						Endpoints: map[string]string{
+							"openai": "https://api.openai.com/v1/chat/completions",
+						},
+						APIKey: "openai-key",
+					},
+					{
+						Name: "anthropic-provider",
+						Endpoints: map[string]string{
+							"anthropic": "https://api.anthropic.com/v1/messages",
+						},
+						APIKey: "anthropic-key",
+					},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4":    {Provider: "openai-provider", Model: "gpt-4-turbo"},
+					"claude-3": {Provider: "anthropic-provider", Model: "claude-3-opus"},
+				},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "multi-protocol provider with valid default",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "multi",
+						Endpoints: map[string]string{
+							"openai":    "https://api.example.com/v1/chat/completions",
+							"anthropic": "https://api.example.com/anthropic/v1/messages",
+						},
+						Default: "openai",
+						APIKey:  "key",
+					},
+				},
+				Models:   map[string]ModelConfig{},
+				Fallback: FallbackConfig{Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative model max_context_tokens",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+					},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "test", Model: "gpt-4", MaxContextTokens: -1},
+				},
+			},
+			wantErr:     true,
+			errContains: "max_context_tokens must not be negative",
+		},
+		{
+			name: "negative responses max_context_tokens",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+					},
+				},
+				Responses: ResponsesConfig{MaxContextTokens: -100},
+			},
+			wantErr:     true,
+			errContains: "responses: max_context_tokens",
+		},
+		{
+			name: "valid retry policy",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+						Retry: &RetryConfig{
+							MaxAttempts:       3,
+							InitialBackoff:    "250ms",
+							MaxBackoff:        "5s",
+							Multiplier:        2,
+							RetryableStatuses: []int{429, 503},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "retry with invalid backoff duration",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+						Retry:     &RetryConfig{MaxAttempts: 3, InitialBackoff: "soon"},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "provider 'test': retry: initial_backoff",
+		},
+		{
+			name: "retry with negative max_attempts",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+						Retry:     &RetryConfig{MaxAttempts: -1},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "max_attempts must not be negative",
+		},
+		{
+			name: "retry with multiplier below one",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+						Retry:     &RetryConfig{MaxAttempts: 2, Multiplier: 0.5},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "multiplier must be at least 1",
+		},
+		{
+			name: "retry with jitter out of range",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+						Retry:     &RetryConfig{MaxAttempts: 2, Jitter: func() *float64 { v := 1.5; return &v }()},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "jitter must be between 0 and 1",
+		},
+		{
+			name: "retry with invalid status",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:      "test",
+						Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:    "key",
+						Retry:     &RetryConfig{MaxAttempts: 2, RetryableStatuses: []int{42}},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "invalid retryable status 42",
+		},
+		{
+			name: "valid circuit breaker",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:           "test",
+						Endpoints:      map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:         "key",
+						CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: 3, OpenDuration: "1m", HalfOpenRequests: 2, FirstByteTimeout: "20s"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "circuit breaker with negative threshold",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:           "test",
+						Endpoints:      map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:         "key",
+						CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: -1},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "provider 'test': circuit_breaker: failure_threshold must not be negative",
+		},
+		{
+			name: "circuit breaker with invalid first byte timeout",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name:           "test",
+						Endpoints:      map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+						APIKey:         "key",
+						CircuitBreaker: &CircuitBreakerConfig{FirstByteTimeout: "fast"},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "first_byte_timeout must be a non-negative duration",
+		},
+		{
+			name: "valid model fallbacks",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"anthropic": "https://api.anthropic.com/v1/messages"}, APIKey: "key"},
+					{Name: "secondary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"claude": {
+						Provider:  "primary",
+						Model:     "claude-sonnet",
+						Fallbacks: []FallbackRoute{{Provider: "secondary", Model: "gpt-4o", Type: "openai"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "model fallback with unknown provider",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {
+						Provider:  "primary",
+						Model:     "gpt-4",
+						Fallbacks: []FallbackRoute{{Provider: "missing", Model: "gpt-4"}},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "fallbacks[0] references unknown provider 'missing'",
+		},
+		{
+			name: "model fallback without model",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {
+						Provider:  "primary",
+						Model:     "gpt-4",
+						Fallbacks: []FallbackRoute{{Provider: "primary"}},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "fallbacks[0]: model is required",
+		},
+		{
+			name: "model fallback with invalid type",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {
+						Provider:  "primary",
+						Model:     "gpt-4",
+						Fallbacks: []FallbackRoute{{Provider: "primary", Model: "gpt-4o", Type: "grpc"}},
+					},
+				},
+			},
+			wantErr:     true,
+			errContains: "fallbacks[0]: type must be",
+		},
+		{
+			name: "provider with apiKeys only",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "test", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKeys: []string{"key-1", "key-2"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "provider with envApiKeys only",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "test", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, EnvAPIKeys: []string{"KEY_1"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "provider with invalid key_eject_duration",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "test", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key", KeyEjectDuration: "soon"},
+				},
+			},
+			wantErr:     true,
+			errContains: "key_eject_duration must be a non-negative duration",
+		},
+		{
+			name: "valid load balance",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "chutes", Endpoints: map[string]string{"openai": "https://llm.chutes.ai/v1/chat/completions"}, APIKeys: []string{"a", "b"}},
+					{Name: "dashscope", Endpoints: map[string]string{"openai": "https://dashscope.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"qwen": {
+						Provider: "chutes",
+						Model:    "Qwen/Qwen3",
+						LoadBalance: &LoadBalanceConfig{
+							Strategy: "weighted",
+							Weight:   3,
+							Targets:  []BalanceTarget{{Provider: "dashscope", Model: "qwen3", Weight: 1}},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "load balance with invalid strategy",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "primary", Model: "gpt-4", LoadBalance: &LoadBalanceConfig{Strategy: "random"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "model 'gpt-4': load_balance: strategy must be",
+		},
+		{
+			name: "load balance target with unknown provider",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "primary", Model: "gpt-4", LoadBalance: &LoadBalanceConfig{Targets: []BalanceTarget{{Provider: "missing", Model: "gpt-4"}}}},
+				},
+			},
+			wantErr:     true,
+			errContains: "targets[0] references unknown provider 'missing'",
+		},
+		{
+			name: "load balance target without model",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "primary", Model: "gpt-4", LoadBalance: &LoadBalanceConfig{Targets: []BalanceTarget{{Provider: "primary"}}}},
+				},
+			},
+			wantErr:     true,
+			errContains: "targets[0]: model is required",
+		},
+		{
+			name: "load balance with negative weight",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "primary", Model: "gpt-4", LoadBalance: &LoadBalanceConfig{Targets: []BalanceTarget{{Provider: "primary", Model: "gpt-4o", Weight: -1}}}},
+				},
+			},
+			wantErr:     true,
+			errContains: "targets[0]: weight must not be negative",
+		},
+		{
+			name: "valid auth keys",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Auth: &AuthConfig{Keys: []ClientKey{
+					{Name: "ci", Key: "sk-proxy-1", User: "alice", Org: "eng", Models: []string{"gpt-4"}},
+					{EnvKey: "PROXY_KEY", User: "bob"},
+					{KeyHash: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", User: "carol"},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "auth without keys",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Auth: &AuthConfig{},
+			},
+			wantErr:     true,
+			errContains: "auth: at least one key is required",
+		},
+		{
+			name: "auth key with two sources",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Auth: &AuthConfig{Keys: []ClientKey{{Key: "sk-proxy-1", EnvKey: "PROXY_KEY", User: "alice"}}},
+			},
+			wantErr:     true,
+			errContains: "auth: keys[0]: exactly one of key, env_key or key_hash is required",
+		},
+		{
+			name: "auth key without user",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Auth: &AuthConfig{Keys: []ClientKey{{Key: "sk-proxy-1"}}},
+			},
+			wantErr:     true,
+			errContains: "auth: keys[0]: user is required",
+		},
+		{
+			name: "auth key with malformed hash",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Auth: &AuthConfig{Keys: []ClientKey{{KeyHash: "md5:abc", User: "alice"}}},
+			},
+			wantErr:     true,
+			errContains: "auth: keys[0]: key_hash must be",
+		},
+		{
+			name: "valid limits and pricing",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "primary", Model: "gpt-4", Limits: &LimitsConfig{RequestsPerMinute: 60, TokensPerDay: 1000000}},
+				},
+				Auth: &AuthConfig{Keys: []ClientKey{
+					{Key: "sk-proxy-1", User: "alice", Limits: &LimitsConfig{RequestsPerMinute: 10, Burst: 20, SpendPerDay: 5}},
+				}},
+				Pricing: map[string]ModelPrice{"gpt-4": {Input: 30, Output: 60}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "model with negative limit",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Models: map[string]ModelConfig{
+					"gpt-4": {Provider: "primary", Model: "gpt-4", Limits: &LimitsConfig{TokensPerHour: -1}},
+				},
+			},
+			wantErr:     true,
+			errContains: "model 'gpt-4': limits: token quotas must not be negative",
+		},
+		{
+			name: "auth key with negative rate",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Auth: &AuthConfig{Keys: []ClientKey{{Key: "sk-proxy-1", User: "alice", Limits: &LimitsConfig{RequestsPerMinute: -5}}}},
+			},
+			wantErr:     true,
+			errContains: "auth: keys[0]: limits: requests_per_minute and burst must not be negative",
+		},
+		{
+			name: "negative price",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Pricing: map[string]ModelPrice{"gpt-4": {Input: -1}},
+			},
+			wantErr:     true,
+			errContains: "pricing 'gpt-4': prices must not be negative",
+		},
+		{
+			name: "negative cache price",
+			schema: Schema{
+				Providers: []Provider{
+					{Name: "primary", Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"}, APIKey: "key"},
+				},
+				Pricing: map[string]ModelPrice{"claude": {Input: 3, Output: 15, CacheWrite: -3.75}},
+			},
+			wantErr:     true,
+			errContains: "pricing 'claude': prices must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			err := loader.validate(&tt.schema)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("validate() expected error containing %q, got nil", tt.errContains)
+					return
+				}
+				if tt.errContains != "" && !containsString(err.Error(), tt.errContains) {
+					t.Errorf("validate() error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("validate() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoaderResolveEnvVars(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      Schema
+		envVars     map[string]string
+		wantAPIKeys map[string]string
+	}{
+		{
+			name: "resolve envApiKey when apiKey is empty",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "",
+						EnvAPIKey: "TEST_KEY_1",
+					},
+				},
+			},
+			envVars:     map[string]string{"TEST_KEY_1": "resolved-key-1"},
+			wantAPIKeys: map[string]string{"test": "resolved-key-1"},
+		},
+		{
+			name: "do not override existing apiKey",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "direct-key",
+						EnvAPIKey: "TEST_KEY_2",
+					},
+				},
+			},
+			envVars:     map[string]string{"TEST_KEY_2": "env-key-should-not-be-used"},
+			wantAPIKeys: map[string]string{"test": "direct-key"},
+		},
+		{
+			name: "empty env var results in empty apiKey",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "",
+						EnvAPIKey: "NON_EXISTENT_KEY",
+					},
+				},
+			},
+			envVars:     map[string]string{},
+			wantAPIKeys: map[string]string{"test": ""},
+		},
+		{
+			name: "multiple providers with mixed resolution",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "provider1",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "",
+						EnvAPIKey: "KEY_1",
+					},
+					{
+						Name: "provider2",
+						Endpoints: map[string]string{
+							"anthropic": "https://api.anthropic.com/v1/messages",
+						},
+						APIKey:    "direct-key",
+						EnvAPIKey: "KEY_2",
+					},
+					{
+						Name: "provider3",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "",
+						EnvAPIKey: "KEY_3",
+					},
+				},
+			},
+			envVars: map[string]string{
+				"KEY_1": "env-key-1",
+				"KEY_3": "env-key-3",
+			},
+			wantAPIKeys: map[string]string{
+				"provider1": "env-key-1",
+				"provider2": "direct-key",
+				"provider3": "env-key-3",
+			},
+		},
+		{
+			name: "provider with no envApiKey",
+			schema: Schema{
+				Providers: []Provider{
+					{
+						Name: "test",
+						Endpoints: map[string]string{
+							"openai": "https://api.example.com/v1/chat/completions",
+						},
+						APIKey:    "",
+						EnvAPIKey: "",
+					},
+				},
+			},
+			envVars:     map[string]string{},
+			wantAPIKeys: map[string]string{"test": ""},
+		},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			for key, value := range tt.envVars {
@@ -660,6 +1708,52 @@ func TestLoaderResolveEnvVars(t *testing.T) {
 	}
 }
 
+func TestLoaderResolveEnvVars_Headers(t *testing.T) {
+	t.Setenv("TEST_TENANT_ID", "acme")
+	schema := Schema{
+		Providers: []Provider{
+			{
+				Name:      "gateway",
+				Endpoints: map[string]string{"openai": "https://gw.example.com/v1/chat/completions"},
+				Headers:   map[string]string{"X-Tenant-Id": "${TEST_TENANT_ID}", "X-Static": "fixed"},
+			},
+		},
+	}
+
+	NewLoader().resolveEnvVars(&schema)
+
+	headers := schema.Providers[0].Headers
+	if headers["X-Tenant-Id"] != "acme" || headers["X-Static"] != "fixed" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+}
+
+func TestLoaderResolveEnvVars_Credential(t *testing.T) {
+	t.Setenv("TEST_CLIENT_ID", "proxy")
+	t.Setenv("TEST_CLIENT_SECRET", "s3cret")
+	schema := Schema{
+		Providers: []Provider{
+			{
+				Name:      "oauth",
+				Endpoints: map[string]string{"openai": "https://api.example.com/v1/chat/completions"},
+				Credential: &CredentialConfig{
+					Type:         CredentialOAuth2,
+					TokenURL:     "https://login.example.com/oauth2/token",
+					ClientID:     "${TEST_CLIENT_ID}",
+					ClientSecret: "${TEST_CLIENT_SECRET}",
+				},
+			},
+		},
+	}
+
+	NewLoader().resolveEnvVars(&schema)
+
+	cr := schema.Providers[0].Credential
+	if cr.ClientID != "proxy" || cr.ClientSecret != "s3cret" {
+		t.Errorf("unexpected client credentials: %q, %q", cr.ClientID, cr.ClientSecret)
+	}
+}
+
 func TestLoaderValidateIntegration(t *testing.T) {
 	t.Run("complete valid config file", func(t *testing.T) {
 		os.Setenv("INTEGRATION_TEST_KEY", "integration-key-value")
@@ -3,7 +3,11 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
 	"os"
+	"strings"
 
 	"ai-proxy/types"
 )
@@ -15,12 +19,22 @@ type Provider struct {
 	Name string `json:"name"`
 	// Endpoints maps protocol names to their specific endpoint URLs.
 	// Required: at least one endpoint must be specified.
-	// Protocols: "openai", "anthropic", "responses"
+	// Protocols: "openai", "anthropic", "responses", "gemini", "ollama"
 	// Example: {"openai": "https://api.provider.com/v1/chat/completions"}
+	// A {model} placeholder is replaced by the upstream model; gemini endpoints
+	// require it, e.g. ".../v1beta/models/{model}:streamGenerateContent?alt=sse".
 	Endpoints map[string]string `json:"endpoints"`
+	// Dialect selects provider-specific authentication and error handling (optional).
+	// Values: "" (standard Bearer authentication) or "azure" (Azure OpenAI:
+	// api-key header, Azure error envelope; openai and responses endpoints only).
+	Dialect string `json:"dialect,omitempty"`
+	// QueryParams are added to the query of every endpoint URL (optional),
+	// e.g. {"api-version": "2024-10-21"} for Azure OpenAI.
+	// They replace parameters of the same name already in the endpoint.
+	QueryParams map[string]string `json:"query_params,omitempty"`
 	// Default specifies the default protocol for multi-protocol providers.
 	// Required when Endpoints has more than one entry.
-	// Must be one of: "openai", "anthropic", "responses".
+	// Must be one of: "openai", "anthropic", "responses", "gemini", "ollama".
 	Default string `json:"default,omitempty"`
 	// APIKey is the direct API key for authentication (optional).
 	// If not set, EnvAPIKey is used to fetch from environment.
@@ -28,6 +42,184 @@ type Provider struct {
 	// EnvAPIKey is the environment variable name containing the API key.
 	// Used when APIKey is not directly set.
 	EnvAPIKey string `json:"envApiKey,omitempty"`
+	// APIKeys lists further API keys of this provider (optional).
+	// Requests are spread across all keys; ${VAR} patterns are expanded.
+	APIKeys []string `json:"apiKeys,omitempty"`
+	// EnvAPIKeys lists environment variable names containing further API keys (optional).
+	EnvAPIKeys []string `json:"envApiKeys,omitempty"`
+	// KeyEjectDuration is how long a key that returned 401 or 429 is taken out
+	// of rotation (e.g. "30s"). A longer Retry-After wins. Default: "30s".
+	KeyEjectDuration string `json:"key_eject_duration,omitempty"`
+	// Retry configures retries of failed upstream requests (optional).
+	// If nil, each request is attempted once.
+	Retry *RetryConfig `json:"retry,omitempty"`
+	// CircuitBreaker configures the provider's circuit breaker (optional).
+	// If nil, failures are tracked for /health but the breaker never opens.
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+	// Auth configures how the API key is sent upstream (optional).
+	// If nil, the protocol's default is used; see UpstreamAuth.
+	Auth *ProviderAuth `json:"auth,omitempty"`
+	// Headers are static headers sent with every upstream request (optional),
+	// e.g. tenant IDs. They replace headers of the same name forwarded from the
+	// client, are masked in captures, and ${VAR} patterns are expanded.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Credential fetches the API key from a file, a command or an OAuth2
+	// token endpoint instead of apiKey or envApiKey (optional).
+	// It cannot be combined with the static key fields.
+	Credential *CredentialConfig `json:"credential,omitempty"`
+	// ConnectionPool tunes the provider's shared upstream connection pool (optional).
+	// If nil, the defaults apply.
+	ConnectionPool *ConnectionPoolConfig `json:"connection_pool,omitempty"`
+	// TLS configures how connections to the provider are verified and which
+	// client certificate is presented (optional). If nil, the server
+	// certificate is verified against the system roots.
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// ProxyURL sends the provider's requests through an outbound proxy
+	// (optional), e.g. "http://egress.internal:3128" or "socks5://127.0.0.1:1080".
+	// If empty, HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply.
+	ProxyURL string `json:"proxy_url,omitempty"`
+}
+
+// TLSConfig configures the TLS connections to a provider.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CA certificates trusted in addition to the
+	// system roots, e.g. for gateways with a private CA.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the PEM client certificate and private key
+	// presented for mutual TLS. Both or neither must be set.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// ServerName overrides the name the server certificate is verified
+	// against and sent in SNI. Default: the endpoint's host.
+	ServerName string `json:"server_name,omitempty"`
+	// InsecureSkipVerify turns off server certificate verification.
+	// Only meant for local development.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// ConnectionPoolConfig tunes the HTTP connections kept open to a provider.
+// Each provider has one pool shared by all its requests, created at startup
+// and on config reload.
+type ConnectionPoolConfig struct {
+	// MaxIdleConns is the number of idle HTTP/1.1 connections kept open per
+	// upstream host. Default: 32.
+	MaxIdleConns int `json:"max_idle_conns,omitempty"`
+	// MaxConns caps the connections per upstream host, including active ones.
+	// 0 means no limit.
+	MaxConns int `json:"max_conns,omitempty"`
+	// IdleTimeout closes connections idle for this long (e.g. "90s"). Default: "90s".
+	IdleTimeout string `json:"idle_timeout,omitempty"`
+	// DialTimeout bounds establishing a TCP connection (e.g. "10s"). Default: "30s".
+	DialTimeout string `json:"dial_timeout,omitempty"`
+	// TLSHandshakeTimeout bounds the TLS handshake (e.g. "5s"). Default: "10s".
+	TLSHandshakeTimeout string `json:"tls_handshake_timeout,omitempty"`
+	// DisableHTTP2 keeps connections on HTTP/1.1 for upstreams that mishandle HTTP/2.
+	DisableHTTP2 bool `json:"disable_http2,omitempty"`
+}
+
+// Credential source types of CredentialConfig.
+const (
+	// CredentialFile reads the key from a file, e.g. a mounted secret that rotates.
+	CredentialFile = "file"
+	// CredentialCommand runs a helper command, e.g. a vault CLI, and uses its output.
+	CredentialCommand = "command"
+	// CredentialOAuth2 fetches access tokens with the OAuth2 client-credentials grant.
+	CredentialOAuth2 = "oauth2"
+)
+
+// CredentialConfig defines where a provider's API key is fetched from.
+// Fetched keys are cached; they are fetched again when they expire, when the
+// refresh interval elapses, or after the upstream rejects them with 401.
+type CredentialConfig struct {
+	// Type is "file", "command" or "oauth2".
+	Type string `json:"type"`
+	// Path is the file holding the key for type "file". Surrounding
+	// whitespace is trimmed.
+	Path string `json:"path,omitempty"`
+	// Command is the program and arguments printing the key to stdout for
+	// type "command", e.g. ["vault", "kv", "get", "-field=key", "secret/llm"].
+	// It is run without a shell; surrounding whitespace of its output is trimmed.
+	Command []string `json:"command,omitempty"`
+	// CommandTimeout bounds a run of Command (e.g. "10s"). Default: "10s".
+	CommandTimeout string `json:"command_timeout,omitempty"`
+	// TokenURL is the OAuth2 token endpoint for type "oauth2".
+	TokenURL string `json:"token_url,omitempty"`
+	// ClientID is the OAuth2 client ID; ${VAR} patterns are expanded.
+	ClientID string `json:"client_id,omitempty"`
+	// ClientSecret is the OAuth2 client secret; ${VAR} patterns are expanded.
+	ClientSecret string `json:"client_secret,omitempty"`
+	// Scopes are the OAuth2 scopes requested (optional).
+	Scopes []string `json:"scopes,omitempty"`
+	// RefreshInterval is how long a key without an expiry is cached (e.g. "5m"):
+	// file and command keys, and tokens without expires_in. Default: "5m".
+	RefreshInterval string `json:"refresh_interval,omitempty"`
+	// RefreshBefore is how long before its expiry a token is fetched again
+	// (e.g. "1m"). Default: "1m".
+	RefreshBefore string `json:"refresh_before,omitempty"`
+}
+
+// Upstream authentication types of ProviderAuth.
+const (
+	// AuthBearer sends the key as "Authorization: Bearer <key>".
+	AuthBearer = "bearer"
+	// AuthXAPIKey sends the key in the x-api-key header, as Anthropic expects.
+	AuthXAPIKey = "x-api-key"
+	// AuthHeader sends the key as the value of the header named by ProviderAuth.Name.
+	AuthHeader = "header"
+	// AuthQuery sends the key as the query parameter named by ProviderAuth.Name.
+	AuthQuery = "query"
+)
+
+// ProviderAuth defines how a provider's API key is sent upstream.
+type ProviderAuth struct {
+	// Type is "bearer", "x-api-key", "header" or "query".
+	Type string `json:"type"`
+	// Name is the header name for type "header" (e.g. "X-Tenant-Key") or the
+	// query parameter name for type "query" (e.g. "key").
+	Name string `json:"name,omitempty"`
+}
+
+// anthropicVersion is the anthropic-version header sent to anthropic endpoints
+// when the client did not send one.
+const anthropicVersion = "2023-06-01"
+
+// CircuitBreakerConfig defines when requests to a provider are stopped after
+// repeated failures. Connection errors, first-byte timeouts, 408 and 5xx
+// responses count as failures; any other response counts as a success.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	// Default: 5.
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+	// OpenDuration is how long the breaker stays open before probe requests
+	// are let through (e.g. "30s"). Default: "30s".
+	OpenDuration string `json:"open_duration,omitempty"`
+	// HalfOpenRequests is the number of concurrent probe requests allowed while
+	// half-open. Default: 1.
+	HalfOpenRequests int `json:"half_open_requests,omitempty"`
+	// FirstByteTimeout fails an upstream attempt whose response headers have not
+	// arrived within this duration (e.g. "20s"). Empty disables the timeout.
+	FirstByteTimeout string `json:"first_byte_timeout,omitempty"`
+}
+
+// RetryConfig defines how failed upstream requests to a provider are retried.
+// Retries only happen before any upstream response data has been streamed to the client.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoff is the delay before the first retry (e.g. "500ms").
+	// Default: "500ms".
+	InitialBackoff string `json:"initial_backoff,omitempty"`
+	// MaxBackoff caps the delay between attempts (e.g. "10s").
+	// A Retry-After longer than this ends retrying. Default: "10s".
+	MaxBackoff string `json:"max_backoff,omitempty"`
+	// Multiplier grows the backoff after each attempt. Default: 2.
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Jitter randomizes each backoff by up to this fraction (0 to 1). Default: 0.2.
+	Jitter *float64 `json:"jitter,omitempty"`
+	// RetryableStatuses lists upstream status codes that are retried.
+	// Default: 429, 500, 502, 503, 504.
+	RetryableStatuses []int `json:"retryable_statuses,omitempty"`
 }
 
 // GetAPIKey returns the API key for this provider.
@@ -42,14 +234,105 @@ func (p *Provider) GetAPIKey() string {
 	return os.Getenv(p.EnvAPIKey)
 }
 
+// GetAPIKeys returns all API keys of this provider in configuration order:
+// the key returned by GetAPIKey, then APIKeys, then the values of EnvAPIKeys.
+// Empty and duplicate keys are skipped.
+//
+// @return []string - the resolved API keys, empty if none is configured
+func (p *Provider) GetAPIKeys() []string {
+	candidates := []string{p.GetAPIKey()}
+	candidates = append(candidates, p.APIKeys...)
+	for _, name := range p.EnvAPIKeys {
+		candidates = append(candidates, os.Getenv(name))
+	}
+
+	keys := make([]string, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	for _, key := range candidates {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// ModelPlaceholder is replaced by the upstream model name in endpoint URLs.
+const ModelPlaceholder = "{model}"
+
+// DialectAzure is the Provider.Dialect of Azure OpenAI deployments.
+const DialectAzure = "azure"
+
 // GetEndpoint returns the endpoint URL for the specified protocol.
 //
-// @param protocol - the protocol name ("openai", "anthropic", "responses")
+// @param protocol - the protocol name ("openai", "anthropic", "responses", "gemini", "ollama")
 // @return the endpoint URL, or empty string if not found
 func (p *Provider) GetEndpoint(protocol string) string {
 	return p.Endpoints[protocol]
 }
 
+// GetModelEndpoint returns the endpoint URL for the specified protocol with
+// the {model} placeholder replaced by the path-escaped model name, e.g. an
+// Azure deployment name, and QueryParams added to the query.
+//
+// @param protocol - the protocol name
+// @param model - the upstream model name
+// @return the endpoint URL, or empty string if not found
+func (p *Provider) GetModelEndpoint(protocol, model string) string {
+	endpoint := strings.ReplaceAll(p.Endpoints[protocol], ModelPlaceholder, url.PathEscape(model))
+	if endpoint == "" || len(p.QueryParams) == 0 {
+		return endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		// Leave invalid URLs for the upstream request to report
+		return endpoint
+	}
+	query := u.Query()
+	for name, value := range p.QueryParams {
+		query.Set(name, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// UpstreamAuth returns how the API key is sent to the endpoint of the given
+// protocol. A configured Auth wins; otherwise azure providers use the api-key
+// header, gemini endpoints x-goog-api-key, anthropic endpoints x-api-key, and
+// all others a Bearer token.
+//
+// @param protocol - the protocol of the endpoint
+// @return the authentication to use
+func (p *Provider) UpstreamAuth(protocol string) ProviderAuth {
+	switch {
+	case p.Auth != nil:
+		return *p.Auth
+	case p.Dialect == DialectAzure:
+		return ProviderAuth{Type: AuthHeader, Name: "api-key"}
+	case protocol == "gemini":
+		return ProviderAuth{Type: AuthHeader, Name: "x-goog-api-key"}
+	case protocol == "anthropic":
+		return ProviderAuth{Type: AuthXAPIKey}
+	default:
+		return ProviderAuth{Type: AuthBearer}
+	}
+}
+
+// DefaultHeaders returns the headers sent to the endpoint of the given protocol
+// unless the request already has them, such as anthropic-version for anthropic
+// endpoints.
+//
+// @param protocol - the protocol of the endpoint
+// @return the default headers, nil if the protocol has none
+func DefaultHeaders(protocol string) map[string]string {
+	if protocol == "anthropic" {
+		return map[string]string{"anthropic-version": anthropicVersion}
+	}
+	return nil
+}
+
 // SupportedProtocols returns the list of protocols this provider supports.
 //
 // @return slice of supported protocol names
@@ -109,6 +392,72 @@ type ModelConfig struct {
 	// Supported by MiniMax M2.7 to return reasoning in reasoning_details field
 	// instead of embedded aisaI tags in content.
 	ReasoningSplit bool `json:"reasoning_split,omitempty"`
+	// MaxContextTokens limits the conversation history token count for this model
+	// when a Responses request continues from previous_response_id.
+	// Overrides responses.max_context_tokens. 0 uses the global setting.
+	MaxContextTokens int `json:"max_context_tokens,omitempty"`
+	// Fallbacks lists alternative routes tried in order when the primary route
+	// fails before any response data has been streamed to the client (optional).
+	Fallbacks []FallbackRoute `json:"fallbacks,omitempty"`
+	// LoadBalance spreads the model's traffic across further providers and
+	// models (optional). Without it, requests still rotate across the keys
+	// of the model's provider.
+	LoadBalance *LoadBalanceConfig `json:"load_balance,omitempty"`
+	// Limits caps each client's use of this model alias (optional).
+	// Every client key is limited separately; without auth, all callers share the limits.
+	Limits *LimitsConfig `json:"limits,omitempty"`
+}
+
+// LoadBalanceConfig defines how traffic for a model alias is spread across
+// provider/key pairs. The model's own provider and model form the first target.
+// Every target contributes one member per API key of its provider.
+type LoadBalanceConfig struct {
+	// Strategy selects the member tried first: "round_robin", "weighted"
+	// (weighted random) or "least_in_flight". Default: "round_robin".
+	Strategy string `json:"strategy,omitempty"`
+	// Weight is the weight of the model's own target for the "weighted" strategy.
+	// Default: 1.
+	Weight int `json:"weight,omitempty"`
+	// Targets lists further providers and models sharing the model's traffic.
+	Targets []BalanceTarget `json:"targets,omitempty"`
+}
+
+// BalanceTarget defines an additional provider and model serving a load-balanced model.
+type BalanceTarget struct {
+	// Provider is the name of the provider to use for this target.
+	Provider string `json:"provider"`
+	// Model is the actual model identifier to use on the provider.
+	Model string `json:"model"`
+	// Type specifies the output protocol: "openai", "anthropic", or "auto".
+	// Empty defaults to the provider's default protocol.
+	Type string `json:"type,omitempty"`
+	// KimiToolCallTransform enables tool call transformation for this target.
+	KimiToolCallTransform bool `json:"kimi_tool_call_transform"`
+	// GLM5ToolCallTransform enables GLM-5 style XML tool call extraction for this target.
+	GLM5ToolCallTransform bool `json:"glm5_tool_call_transform"`
+	// ReasoningSplit enables separate reasoning output for this target.
+	ReasoningSplit bool `json:"reasoning_split,omitempty"`
+	// Weight is the weight of this target for the "weighted" strategy. Default: 1.
+	Weight int `json:"weight,omitempty"`
+}
+
+// FallbackRoute defines an alternative provider and model for a configured model.
+// Each route has its own protocol and tool-call settings, so a model served over
+// the Anthropic protocol can fall back to a provider speaking the OpenAI protocol.
+type FallbackRoute struct {
+	// Provider is the name of the provider to use for this route.
+	Provider string `json:"provider"`
+	// Model is the actual model identifier to use on the provider.
+	Model string `json:"model"`
+	// Type specifies the output protocol: "openai", "anthropic", or "auto".
+	// Empty defaults to the provider's default protocol.
+	Type string `json:"type,omitempty"`
+	// KimiToolCallTransform enables tool call transformation for this route.
+	KimiToolCallTransform bool `json:"kimi_tool_call_transform"`
+	// GLM5ToolCallTransform enables GLM-5 style XML tool call extraction for this route.
+	GLM5ToolCallTransform bool `json:"glm5_tool_call_transform"`
+	// ReasoningSplit enables separate reasoning output for this route.
+	ReasoningSplit bool `json:"reasoning_split,omitempty"`
 }
 
 // FallbackConfig defines the fallback behavior when a request fails.
@@ -128,6 +477,9 @@ type FallbackConfig struct {
 	GLM5ToolCallTransform bool `json:"glm5_tool_call_transform"`
 	// ReasoningSplit enables separate reasoning output for fallback requests.
 	ReasoningSplit bool `json:"reasoning_split,omitempty"`
+	// MaxContextTokens limits the conversation history token count for fallback requests.
+	// Overrides responses.max_context_tokens. 0 uses the global setting.
+	MaxContextTokens int `json:"max_context_tokens,omitempty"`
 }
 
 // SummarizerConfig defines the configuration for the reasoning summarizer.
@@ -171,6 +523,7 @@ type LocalSummarizerConfig struct {
 type ResponsesConfig struct {
 	// MaxContextTokens limits the conversation history token count.
 	// If set, older turns are truncated to stay within this limit.
+	// Models may override it with their own max_context_tokens.
 	// Default: 0 (no limit)
 	MaxContextTokens int `json:"max_context_tokens"`
 }
@@ -190,4 +543,170 @@ type Schema struct {
 	Responses ResponsesConfig `json:"responses"`
 	// WebSearch defines the web search service configuration.
 	WebSearch types.WebSearchConfig `json:"websearch"`
+	// Auth defines the API keys clients must present (optional).
+	// If nil, the proxy accepts any caller.
+	Auth *AuthConfig `json:"auth,omitempty"`
+	// Pricing maps upstream model identifiers to their token prices (optional).
+	// Used for per-request costs and spend quotas; models without a price cost nothing.
+	Pricing map[string]ModelPrice `json:"pricing,omitempty"`
+	// Listeners lists where the server accepts connections (optional).
+	// If empty, it listens on the --bind address and --port. Changes need a restart.
+	Listeners []ListenerConfig `json:"listeners,omitempty"`
+}
+
+// Listener types of ListenerConfig.
+const (
+	// ListenerTCP listens on a TCP address, optionally with TLS.
+	ListenerTCP = "tcp"
+	// ListenerUnix listens on a unix socket, access controlled by its file permissions.
+	ListenerUnix = "unix"
+	// ListenerSystemd serves sockets passed in by systemd socket activation.
+	ListenerSystemd = "systemd"
+)
+
+// ListenerConfig defines one place the server accepts connections.
+type ListenerConfig struct {
+	// Type is "tcp", "unix" or "systemd".
+	Type string `json:"type"`
+	// Address is the "host:port" of a tcp listener, e.g. "127.0.0.1:8443".
+	// An empty host listens on all interfaces.
+	Address string `json:"address,omitempty"`
+	// TLS terminates TLS on a tcp or systemd listener (optional).
+	TLS *ListenerTLSConfig `json:"tls,omitempty"`
+	// Path is the socket file of a unix listener. A stale socket left by a
+	// previous run is replaced.
+	Path string `json:"path,omitempty"`
+	// Mode is the octal file mode of a unix socket, e.g. "0660". Default: "0600".
+	Mode string `json:"mode,omitempty"`
+	// Owner and Group own a unix socket, as names or numeric IDs (optional).
+	// Changing them usually needs root.
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	// Name selects the systemd socket with this FileDescriptorName. If empty,
+	// every socket passed in by systemd is served.
+	Name string `json:"name,omitempty"`
+}
+
+// ListenerTLSConfig configures the TLS a listener terminates.
+type ListenerTLSConfig struct {
+	// CertFile and KeyFile are the PEM server certificate chain and private key.
+	// They are loaded again when either file changes, without a restart.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// AuthConfig defines inbound client authentication. Clients present a key in
+// "Authorization: Bearer <key>" or "x-api-key: <key>"; requests without a
+// configured key are rejected.
+type AuthConfig struct {
+	// Keys lists the accepted client keys and the identity each maps to.
+	Keys []ClientKey `json:"keys"`
+}
+
+// ClientKey defines one proxy API key and the identity it authenticates.
+// Exactly one of Key, EnvKey or KeyHash must be set.
+type ClientKey struct {
+	// Name labels the key in logs and capture records (optional).
+	Name string `json:"name,omitempty"`
+	// Key is the key itself; ${VAR} patterns are expanded.
+	Key string `json:"key,omitempty"`
+	// EnvKey is the environment variable name containing the key.
+	EnvKey string `json:"env_key,omitempty"`
+	// KeyHash is the key's SHA-256 digest as "sha256:<64 hex digits>", so the
+	// key itself does not have to be stored in the configuration.
+	KeyHash string `json:"key_hash,omitempty"`
+	// User is the user the key authenticates. Owns the conversations it creates.
+	User string `json:"user"`
+	// Org is the user's organization (optional).
+	Org string `json:"org,omitempty"`
+	// Models lists the model aliases the key may use. Empty allows all models.
+	Models []string `json:"models,omitempty"`
+	// Limits caps the key's use across all models (optional).
+	Limits *LimitsConfig `json:"limits,omitempty"`
+	// Admin grants the key access to the /admin endpoints.
+	Admin bool `json:"admin,omitempty"`
+}
+
+// LimitsConfig defines a request rate limit and rolling token and spend quotas.
+// Zero values leave the corresponding limit off.
+type LimitsConfig struct {
+	// RequestsPerMinute is the sustained request rate of the token bucket.
+	RequestsPerMinute float64 `json:"requests_per_minute,omitempty"`
+	// Burst is the token bucket size. Default: RequestsPerMinute rounded up.
+	Burst int `json:"burst,omitempty"`
+	// TokensPerHour caps input plus output tokens over the last hour.
+	TokensPerHour int64 `json:"tokens_per_hour,omitempty"`
+	// TokensPerDay caps input plus output tokens over the last 24 hours.
+	TokensPerDay int64 `json:"tokens_per_day,omitempty"`
+	// SpendPerHour caps the cost in dollars over the last hour, priced from Schema.Pricing.
+	SpendPerHour float64 `json:"spend_per_hour,omitempty"`
+	// SpendPerDay caps the cost in dollars over the last 24 hours.
+	SpendPerDay float64 `json:"spend_per_day,omitempty"`
+}
+
+// ModelPrice defines the price of an upstream model in dollars per million tokens.
+type ModelPrice struct {
+	// Input is the price of a million uncached input tokens.
+	Input float64 `json:"input"`
+	// Output is the price of a million output tokens.
+	Output float64 `json:"output"`
+	// CacheRead is the price of a million input tokens read from the prompt cache.
+	// Default: the Input price.
+	CacheRead float64 `json:"cache_read,omitempty"`
+	// CacheWrite is the price of a million input tokens written to the prompt cache.
+	// Default: the Input price.
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// Cost returns the price in dollars of the given token counts.
+//
+// @param input - uncached input tokens
+// @param output - output tokens
+// @param cacheRead - input tokens read from the prompt cache
+// @param cacheWrite - input tokens written to the prompt cache
+// @return float64 - the cost in dollars, 0 for a nil price
+func (p *ModelPrice) Cost(input, output, cacheRead, cacheWrite int) float64 {
+	if p == nil {
+		return 0
+	}
+	readPrice, writePrice := p.CacheRead, p.CacheWrite
+	if readPrice == 0 {
+		readPrice = p.Input
+	}
+	if writePrice == 0 {
+		writePrice = p.Input
+	}
+	return (float64(input)*p.Input + float64(output)*p.Output +
+		float64(cacheRead)*readPrice + float64(cacheWrite)*writePrice) / 1e6
+}
+
+// KeyHashPrefix prefixes ClientKey.KeyHash values.
+const KeyHashPrefix = "sha256:"
+
+// HashClientKey returns the hex SHA-256 digest of a client key, as compared
+// against ClientKey.GetKeyHash.
+//
+// @param key - the key presented by a client
+// @return string - 64 lowercase hex digits
+func HashClientKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetKeyHash returns the hex SHA-256 digest of this key: KeyHash without its
+// prefix, or the digest of Key or of the EnvKey environment variable.
+//
+// @return string - the lowercase hex digest, or empty string if no key is configured
+func (k *ClientKey) GetKeyHash() string {
+	switch {
+	case k.KeyHash != "":
+		return strings.ToLower(strings.TrimPrefix(k.KeyHash, KeyHashPrefix))
+	case k.Key != "":
+		return HashClientKey(k.Key)
+	case k.EnvKey != "":
+		if key := os.Getenv(k.EnvKey); key != "" {
+			return HashClientKey(key)
+		}
+	}
+	return ""
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -91,6 +92,162 @@ func TestProviderGetAPIKey(t *testing.T) {
 	}
 }
 
+func TestProviderGetAPIKeys(t *testing.T) {
+	t.Setenv("TEST_EXTRA_KEY_1", "env-key-1")
+	t.Setenv("TEST_EXTRA_KEY_2", "")
+
+	provider := Provider{
+		Name:       "test-provider",
+		Endpoints:  map[string]string{"openai": "https://api.example.com/v1"},
+		APIKey:     "direct-key",
+		APIKeys:    []string{"list-key", "direct-key", ""},
+		EnvAPIKeys: []string{"TEST_EXTRA_KEY_1", "TEST_EXTRA_KEY_2", "TEST_EXTRA_KEY_MISSING"},
+	}
+
+	got := provider.GetAPIKeys()
+	want := []string{"direct-key", "list-key", "env-key-1"}
+	if len(got) != len(want) {
+		t.Fatalf("GetAPIKeys() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GetAPIKeys()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	empty := Provider{Name: "empty"}
+	if keys := empty.GetAPIKeys(); len(keys) != 0 {
+		t.Errorf("GetAPIKeys() without keys = %v, want empty", keys)
+	}
+}
+
+func TestProviderGetModelEndpoint(t *testing.T) {
+	provider := Provider{
+		Name: "google",
+		Endpoints: map[string]string{
+			"gemini": "https://generativelanguage.googleapis.com/v1beta/models/{model}:streamGenerateContent?alt=sse",
+			"openai": "https://api.example.com/v1/chat/completions",
+		},
+	}
+
+	tests := []struct {
+		protocol string
+		model    string
+		want     string
+	}{
+		{"gemini", "gemini-2.5-flash", "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse"},
+		{"gemini", "tuned/my model", "https://generativelanguage.googleapis.com/v1beta/models/tuned%2Fmy%20model:streamGenerateContent?alt=sse"},
+		{"openai", "gpt-4o", "https://api.example.com/v1/chat/completions"},
+		{"anthropic", "claude", ""},
+	}
+	for _, tt := range tests {
+		if got := provider.GetModelEndpoint(tt.protocol, tt.model); got != tt.want {
+			t.Errorf("GetModelEndpoint(%q, %q) = %q, want %q", tt.protocol, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestProviderGetModelEndpoint_QueryParams(t *testing.T) {
+	provider := Provider{
+		Name:    "azure",
+		Dialect: DialectAzure,
+		Endpoints: map[string]string{
+			"openai":    "https://res.openai.azure.com/openai/deployments/{model}/chat/completions?api-version=2024-02-01",
+			"responses": "https://res.openai.azure.com/openai/responses",
+		},
+		QueryParams: map[string]string{"api-version": "2024-10-21"},
+	}
+
+	tests := []struct {
+		protocol string
+		model    string
+		want     string
+	}{
+		{"openai", "gpt-4o-prod", "https://res.openai.azure.com/openai/deployments/gpt-4o-prod/chat/completions?api-version=2024-10-21"},
+		{"responses", "gpt-4o-prod", "https://res.openai.azure.com/openai/responses?api-version=2024-10-21"},
+		{"anthropic", "claude", ""},
+	}
+	for _, tt := range tests {
+		if got := provider.GetModelEndpoint(tt.protocol, tt.model); got != tt.want {
+			t.Errorf("GetModelEndpoint(%q, %q) = %q, want %q", tt.protocol, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestProviderUpstreamAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		protocol string
+		want     ProviderAuth
+	}{
+		{"openai", Provider{}, "openai", ProviderAuth{Type: AuthBearer}},
+		{"responses", Provider{}, "responses", ProviderAuth{Type: AuthBearer}},
+		{"anthropic", Provider{}, "anthropic", ProviderAuth{Type: AuthXAPIKey}},
+		{"gemini", Provider{}, "gemini", ProviderAuth{Type: AuthHeader, Name: "x-goog-api-key"}},
+		{"azure", Provider{Dialect: DialectAzure}, "responses", ProviderAuth{Type: AuthHeader, Name: "api-key"}},
+		{"configured", Provider{Auth: &ProviderAuth{Type: AuthBearer}}, "anthropic", ProviderAuth{Type: AuthBearer}},
+	}
+	for _, tt := range tests {
+		if got := tt.provider.UpstreamAuth(tt.protocol); got != tt.want {
+			t.Errorf("%s: UpstreamAuth(%q) = %+v, want %+v", tt.name, tt.protocol, got, tt.want)
+		}
+	}
+
+	if got := DefaultHeaders("anthropic")["anthropic-version"]; got != "2023-06-01" {
+		t.Errorf("DefaultHeaders(anthropic) anthropic-version = %q", got)
+	}
+	if got := DefaultHeaders("openai"); got != nil {
+		t.Errorf("DefaultHeaders(openai) = %v, want nil", got)
+	}
+}
+
+func TestClientKeyGetKeyHash(t *testing.T) {
+	t.Setenv("TEST_PROXY_KEY", "sk-proxy")
+	want := HashClientKey("sk-proxy")
+	if len(want) != 64 {
+		t.Fatalf("HashClientKey() = %q, want 64 hex digits", want)
+	}
+
+	tests := []struct {
+		name string
+		key  ClientKey
+		want string
+	}{
+		{name: "inline key", key: ClientKey{Key: "sk-proxy"}, want: want},
+		{name: "env key", key: ClientKey{EnvKey: "TEST_PROXY_KEY"}, want: want},
+		{name: "hashed key", key: ClientKey{KeyHash: KeyHashPrefix + strings.ToUpper(want)}, want: want},
+		{name: "unset env key", key: ClientKey{EnvKey: "TEST_PROXY_KEY_MISSING"}, want: ""},
+		{name: "no key", key: ClientKey{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.GetKeyHash(); got != tt.want {
+				t.Errorf("GetKeyHash() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelPriceCost(t *testing.T) {
+	price := &ModelPrice{Input: 3, Output: 15}
+	if got := price.Cost(1000000, 200000, 0, 0); got != 6 {
+		t.Errorf("Cost() = %v, want 6", got)
+	}
+	// Cache reads and writes default to the input price
+	if got := price.Cost(0, 0, 1000000, 1000000); got != 6 {
+		t.Errorf("Cost() with default cache prices = %v, want 6", got)
+	}
+	cached := &ModelPrice{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	if got := cached.Cost(1000000, 0, 10000000, 1000000); got != 9.75 {
+		t.Errorf("Cost() with cache prices = %v, want 9.75", got)
+	}
+	var none *ModelPrice
+	if got := none.Cost(1000, 1000, 1000, 1000); got != 0 {
+		t.Errorf("nil price Cost() = %v, want 0", got)
+	}
+}
+
 func TestSchemaJSONUnmarshal(t *testing.T) {
 	jsonData := `{
 		"providers": [
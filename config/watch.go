@@ -0,0 +1,132 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"ai-proxy/logging"
+)
+
+// Watcher reloads the configuration file when it changes on disk or the
+// process receives SIGHUP.
+//
+// Each reload runs the file through Loader.Load, so it is validated exactly
+// like at startup. A file that fails to load or validate is reported and the
+// running configuration is kept; only a valid schema is passed to apply.
+//
+// Thread Safety: Safe for concurrent use; reloads are serialized.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	apply    func(*Schema) error
+	loader   *Loader
+
+	mu      sync.Mutex
+	modTime time.Time // modification time of the file at the last reload
+	size    int64     // size of the file at the last reload
+}
+
+// NewWatcher creates a watcher for a config file that has already been loaded.
+//
+// @param path - the config file
+// @param interval - how often to check the file for changes; 0 disables checking,
+// leaving SIGHUP and Reload
+// @param apply - installs a reloaded schema; an error keeps the running configuration
+// @return *Watcher - the watcher, not yet running
+func NewWatcher(path string, interval time.Duration, apply func(*Schema) error) *Watcher {
+	w := &Watcher{path: path, interval: interval, apply: apply, loader: NewLoader()}
+	w.modTime, w.size, _ = w.stat()
+	return w
+}
+
+// stat returns the file's modification time and size.
+func (w *Watcher) stat() (time.Time, int64, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return info.ModTime(), info.Size(), nil
+}
+
+// Reload loads, validates and applies the config file.
+//
+// @return error - why the file was not applied; the running configuration is kept
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reload()
+}
+
+// reload is Reload with w.mu held.
+func (w *Watcher) reload() error {
+	// Remember the file as seen now, so an invalid file is reported once
+	// rather than on every check until it is fixed
+	w.modTime, w.size, _ = w.stat()
+
+	schema, err := w.loader.Load(w.path)
+	if err != nil {
+		return err
+	}
+	if err := w.apply(schema); err != nil {
+		return fmt.Errorf("failed to apply config: %w", err)
+	}
+	return nil
+}
+
+// changed reports whether the file differs from the last reload.
+//
+// @pre w.mu is held
+func (w *Watcher) changed() bool {
+	modTime, size, err := w.stat()
+	if err != nil {
+		// Editors may replace the file by renaming; wait for it to reappear
+		return false
+	}
+	return !modTime.Equal(w.modTime) || size != w.size
+}
+
+// Run reloads the config file on SIGHUP and whenever it changes, until ctx is done.
+// Outcomes are logged; failed reloads keep the running configuration.
+//
+// @param ctx - stops the watcher when done
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.report("SIGHUP", w.Reload())
+		case <-tick:
+			w.mu.Lock()
+			if w.changed() {
+				w.report("file change", w.reload())
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// report logs the outcome of a reload.
+func (w *Watcher) report(trigger string, err error) {
+	if err != nil {
+		logging.ErrorMsg("Config reload on %s failed, keeping the running configuration: %v", trigger, err)
+		return
+	}
+	logging.InfoMsg("Config reloaded from %s on %s", w.path, trigger)
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// watchedConfig returns a valid config file serving alias.
+func watchedConfig(alias string) string {
+	return `{
+		"providers": [{"name": "openai", "endpoints": {"openai": "https://api.openai.com/v1/chat/completions"}, "apiKey": "key"}],
+		"models": {"` + alias + `": {"provider": "openai", "model": "gpt-4o"}}
+	}`
+}
+
+// appliedSchemas records the schemas a Watcher applies.
+type appliedSchemas struct {
+	mu      sync.Mutex
+	schemas []*Schema
+	err     error
+}
+
+func (a *appliedSchemas) apply(s *Schema) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	a.schemas = append(a.schemas, s)
+	return nil
+}
+
+func (a *appliedSchemas) last() *Schema {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.schemas) == 0 {
+		return nil
+	}
+	return a.schemas[len(a.schemas)-1]
+}
+
+// writeConfig writes a config file with a distinct modification time.
+func writeConfig(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("failed to set config mtime: %v", err)
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, watchedConfig("fast"), time.Now())
+	applied := &appliedSchemas{}
+	w := NewWatcher(path, 0, applied.apply)
+
+	writeConfig(t, path, watchedConfig("smart"), time.Now())
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if s := applied.last(); s == nil || s.Models["smart"].Model != "gpt-4o" {
+		t.Fatalf("expected the reloaded schema to be applied, got %+v", s)
+	}
+
+	// An invalid file is not applied
+	writeConfig(t, path, `{"providers": []}`, time.Now())
+	if err := w.Reload(); err == nil || err.Error() != "at least one provider required" {
+		t.Errorf("Reload() error = %v, want validation error", err)
+	}
+	if len(applied.schemas) != 1 {
+		t.Errorf("invalid config must not be applied, got %d schemas", len(applied.schemas))
+	}
+
+	// Apply errors are reported
+	writeConfig(t, path, watchedConfig("fast"), time.Now())
+	applied.err = errors.New("router failed")
+	if err := w.Reload(); err == nil || err.Error() != "failed to apply config: router failed" {
+		t.Errorf("Reload() error = %v, want apply error", err)
+	}
+}
+
+func TestWatcher_RunReloadsOnChangeAndSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, path, watchedConfig("fast"), start)
+	applied := &appliedSchemas{}
+	w := NewWatcher(path, 10*time.Millisecond, applied.apply)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(alias string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if s := applied.last(); s != nil {
+				if _, ok := s.Models[alias]; ok {
+					return
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("config serving %q was not applied", alias)
+	}
+
+	writeConfig(t, path, watchedConfig("smart"), start.Add(time.Minute))
+	waitFor("smart")
+
+	// Rewriting the file with its old modification time and size goes unnoticed
+	// by the watch; SIGHUP reloads it regardless
+	writeConfig(t, path, watchedConfig("small"), start.Add(time.Minute))
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP: %v", err)
+	}
+	waitFor("small")
+}
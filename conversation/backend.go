@@ -0,0 +1,84 @@
+package conversation
+
+// Backend is a conversation storage backend.
+// Store is the in-memory LRU implementation; FileStore persists it to disk.
+//
+// Implementations must be safe for concurrent use and must not return
+// conversations whose ExpiresAt has passed.
+type Backend interface {
+	// Get retrieves a conversation by ID, or nil if it is missing or expired.
+	Get(id string) *Conversation
+	// Store saves a conversation, replacing any with the same ID.
+	Store(conv *Conversation)
+	// Delete removes a conversation by ID.
+	Delete(id string)
+	// Size returns the number of stored conversations.
+	Size() int
+	// Clear removes all conversations.
+	Clear()
+	// Close releases resources held by the backend.
+	Close() error
+}
+
+// OpenStore creates the backend selected by config.
+// If config.Path is set, a FileStore is opened and reloaded from that path;
+// otherwise an in-memory Store is returned.
+func OpenStore(config Config) (Backend, error) {
+	if config.Path == "" {
+		return NewStore(config), nil
+	}
+	return OpenFileStore(config)
+}
+
+// WalkChain walks the linked-list chain of conversations backward from the given ID.
+// It returns all conversations in chronological order (oldest first).
+// The chain traversal follows PreviousResponseID pointers until it reaches
+// a conversation with no parent or a missing conversation.
+func WalkChain(b Backend, id string) []*Conversation {
+	var chain []*Conversation
+	seen := make(map[string]bool)
+	cursor := id
+	for cursor != "" && !seen[cursor] {
+		conv := b.Get(cursor)
+		if conv == nil {
+			break
+		}
+		seen[cursor] = true
+		// Append to slice (O(1) amortized)
+		chain = append(chain, conv)
+		cursor = conv.PreviousResponseID
+	}
+	// Reverse to get chronological order (oldest first)
+	// This is O(n) instead of O(n²) prepending
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// WalkChainWithOwnership walks the conversation chain and validates ownership.
+// Returns OwnershipError if the root conversation belongs to a different user.
+// If userID is empty, ownership check is skipped (for backwards compatibility).
+func WalkChainWithOwnership(b Backend, id string, userID string) ([]*Conversation, error) {
+	chain := WalkChain(b, id)
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	// Skip ownership check if no userID provided (backwards compatibility)
+	if userID == "" {
+		return chain, nil
+	}
+
+	// Validate ownership of root conversation
+	root := chain[0]
+	if root.UserID != "" && root.UserID != userID {
+		return nil, &OwnershipError{
+			ResponseID: id,
+			UserID:     userID,
+			ExpectedID: root.UserID,
+		}
+	}
+
+	return chain, nil
+}
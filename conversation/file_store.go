@@ -0,0 +1,275 @@
+package conversation
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-proxy/logging"
+)
+
+// Log record operations.
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// minCompactRecords is the log length below which compaction is never triggered.
+const minCompactRecords = 64
+
+// logRecord is one line of the append-only conversation log.
+type logRecord struct {
+	Op           string        `json:"op"`
+	ID           string        `json:"id"`
+	Conversation *Conversation `json:"conversation,omitempty"`
+}
+
+// FileStore is a Backend that persists conversations to an append-only
+// JSON-lines log and serves reads from an in-memory Store.
+//
+// Every Store and Delete appends one record. When the log grows past twice
+// the number of live conversations it is compacted: live, unexpired entries
+// are rewritten to a temporary file that atomically replaces the log.
+// On open the log is replayed, skipping expired entries, and then compacted.
+//
+// LRU recency survives a restart only approximately, as write order.
+// Records are written without fsync; a crash may lose the latest writes,
+// and a torn final line is skipped on replay.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records int // lines in the log file
+	memory  *Store
+}
+
+// OpenFileStore opens or creates the log at config.Path and reloads it.
+//
+// @param config - Store configuration; Path must be set.
+// @return *FileStore - the reloaded store.
+// @return error - if the log cannot be created, read, or compacted.
+func OpenFileStore(config Config) (*FileStore, error) {
+	if config.Path == "" {
+		return nil, errors.New("conversation store path is required")
+	}
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create conversation store directory: %w", err)
+		}
+	}
+
+	fs := &FileStore{
+		path:   config.Path,
+		memory: NewStore(config),
+	}
+	if err := fs.replay(); err != nil {
+		return nil, err
+	}
+	if err := fs.compactLocked(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// replay loads the log into memory.
+// A missing log is treated as empty; malformed lines are skipped.
+func (fs *FileStore) replay() error {
+	f, err := os.Open(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open conversation store: %w", err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	reader := bufio.NewReader(f)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var rec logRecord
+			if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+				logging.InfoMsg("Warning: skipping malformed conversation store record at %s:%d: %v", fs.path, lineNum, jsonErr)
+			} else {
+				fs.apply(rec, now)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read conversation store: %w", err)
+		}
+	}
+}
+
+// apply replays a single record into memory.
+func (fs *FileStore) apply(rec logRecord, now time.Time) {
+	switch rec.Op {
+	case opPut:
+		if rec.Conversation == nil || rec.Conversation.ID == "" {
+			return
+		}
+		// Expired on disk: drop it and anything it replaced
+		if !rec.Conversation.ExpiresAt.IsZero() && now.After(rec.Conversation.ExpiresAt) {
+			fs.memory.Delete(rec.Conversation.ID)
+			return
+		}
+		fs.memory.Store(rec.Conversation)
+	case opDelete:
+		fs.memory.Delete(rec.ID)
+	}
+}
+
+// Get retrieves a conversation by ID.
+// Returns nil if the conversation is not found or has expired.
+func (fs *FileStore) Get(id string) *Conversation {
+	return fs.memory.Get(id)
+}
+
+// Store saves a conversation and appends it to the log.
+func (fs *FileStore) Store(conv *Conversation) {
+	if conv == nil || conv.ID == "" {
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Store sets ExpiresAt, so the persisted record carries the TTL
+	fs.memory.Store(conv)
+	fs.appendLocked(logRecord{Op: opPut, ID: conv.ID, Conversation: conv})
+}
+
+// Delete removes a conversation by ID and records the deletion in the log.
+func (fs *FileStore) Delete(id string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.memory.Delete(id)
+	fs.appendLocked(logRecord{Op: opDelete, ID: id})
+}
+
+// Size returns the current number of stored conversations.
+func (fs *FileStore) Size() int {
+	return fs.memory.Size()
+}
+
+// Clear removes all conversations and truncates the log.
+func (fs *FileStore) Clear() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.memory.Clear()
+	if err := fs.compactLocked(); err != nil {
+		logging.ErrorMsg("Failed to clear conversation store: %v", err)
+	}
+}
+
+// Close syncs the log file to disk and closes it. The store must not be used afterwards.
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		return nil
+	}
+	err := fs.file.Sync()
+	if closeErr := fs.file.Close(); err == nil {
+		err = closeErr
+	}
+	fs.file = nil
+	return err
+}
+
+// Compact rewrites the log with only the live conversations.
+func (fs *FileStore) Compact() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.compactLocked()
+}
+
+// appendLocked writes a record to the log and compacts it when it has grown
+// past twice the live size. Write errors are logged; the in-memory state stays
+// authoritative until the next successful compaction.
+// Must be called with fs.mu held.
+func (fs *FileStore) appendLocked(rec logRecord) {
+	if fs.file == nil {
+		return
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		logging.ErrorMsg("Failed to encode conversation %s: %v", rec.ID, err)
+		return
+	}
+	if _, err := fs.file.Write(append(data, '\n')); err != nil {
+		logging.ErrorMsg("Failed to persist conversation %s: %v", rec.ID, err)
+		return
+	}
+	fs.records++
+
+	if fs.records >= minCompactRecords && fs.records > 2*fs.memory.Size() {
+		if err := fs.compactLocked(); err != nil {
+			logging.ErrorMsg("Failed to compact conversation store: %v", err)
+		}
+	}
+}
+
+// compactLocked rewrites the log from memory and reopens it for appending.
+// Must be called with fs.mu held (or before the store is shared).
+func (fs *FileStore) compactLocked() error {
+	convs := fs.memory.snapshot()
+
+	tmpPath := fs.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create compacted conversation store: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, conv := range convs {
+		if err := enc.Encode(logRecord{Op: opPut, ID: conv.ID, Conversation: conv}); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("write compacted conversation store: %w", err)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write compacted conversation store: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, fs.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replace conversation store: %w", err)
+	}
+
+	// The old handle points at the replaced file; appends must go to the new one
+	if fs.file != nil {
+		fs.file.Close()
+		fs.file = nil
+	}
+	file, err := os.OpenFile(fs.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("reopen conversation store: %w", err)
+	}
+	fs.file = file
+	fs.records = len(convs)
+	return nil
+}
@@ -0,0 +1,181 @@
+package conversation
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ai-proxy/types"
+)
+
+func openTestFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	fs, err := OpenFileStore(Config{MaxSize: 100, TTL: time.Hour, Path: path})
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestFileStore_ReloadsAfterReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store", "conversations.jsonl")
+
+	fs := openTestFileStore(t, path)
+	fs.Store(&Conversation{
+		ID:     "resp_1",
+		Input:  []types.InputItem{{Type: "message", Role: "user", Content: "Hello"}},
+		Output: []types.OutputItem{{Type: "reasoning", Summary: "thinking"}},
+		UserID: "user_1",
+	})
+	fs.Store(&Conversation{ID: "resp_2", PreviousResponseID: "resp_1"})
+	if err := fs.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reopened := openTestFileStore(t, path)
+	if reopened.Size() != 2 {
+		t.Fatalf("Size() after reopen = %d, want 2", reopened.Size())
+	}
+	got := reopened.Get("resp_1")
+	if got == nil {
+		t.Fatal("Get(resp_1) after reopen = nil")
+	}
+	if got.Input[0].Content != "Hello" || got.UserID != "user_1" || got.Output[0].Summary != "thinking" {
+		t.Errorf("reloaded conversation = %+v", got)
+	}
+	if chain := WalkChain(reopened, "resp_2"); len(chain) != 2 || chain[0].ID != "resp_1" {
+		t.Errorf("WalkChain() after reopen = %v, want [resp_1 resp_2]", chainIDs(chain))
+	}
+}
+
+func TestFileStore_DeletePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+
+	fs := openTestFileStore(t, path)
+	fs.Store(&Conversation{ID: "resp_1"})
+	fs.Store(&Conversation{ID: "resp_2"})
+	fs.Delete("resp_1")
+	fs.Close()
+
+	reopened := openTestFileStore(t, path)
+	if reopened.Get("resp_1") != nil {
+		t.Error("deleted conversation was reloaded")
+	}
+	if reopened.Get("resp_2") == nil {
+		t.Error("Get(resp_2) after reopen = nil")
+	}
+}
+
+func TestFileStore_ExpiredNotReloaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+
+	fs := openTestFileStore(t, path)
+	fs.Store(&Conversation{ID: "resp_old", ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	fs.Store(&Conversation{ID: "resp_new"})
+	fs.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	reopened := openTestFileStore(t, path)
+	if reopened.Get("resp_old") != nil {
+		t.Error("expired conversation was reloaded")
+	}
+	if reopened.Size() != 1 {
+		t.Errorf("Size() = %d, want 1", reopened.Size())
+	}
+	// Reopening compacts the log, dropping the expired record from disk
+	if lines := countLines(t, path); lines != 1 {
+		t.Errorf("log has %d lines after reopen, want 1", lines)
+	}
+}
+
+func TestFileStore_CompactsLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+	fs := openTestFileStore(t, path)
+
+	// Rewriting one conversation grows the log until compaction kicks in
+	for i := 0; i < 3*minCompactRecords; i++ {
+		fs.Store(&Conversation{ID: "resp_1"})
+	}
+
+	if lines := countLines(t, path); lines >= minCompactRecords {
+		t.Errorf("log has %d lines, want fewer than %d after compaction", lines, minCompactRecords)
+	}
+
+	if err := fs.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if lines := countLines(t, path); lines != 1 {
+		t.Errorf("log has %d lines after Compact(), want 1", lines)
+	}
+}
+
+func TestFileStore_SkipsMalformedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+	data := `{"op":"put","id":"resp_1","conversation":{"id":"resp_1"}}
+not json
+{"op":"put","id":"resp_2","conv`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := openTestFileStore(t, path)
+	if fs.Get("resp_1") == nil {
+		t.Error("Get(resp_1) = nil, want record before the malformed lines")
+	}
+	if fs.Size() != 1 {
+		t.Errorf("Size() = %d, want 1", fs.Size())
+	}
+}
+
+func TestFileStore_Clear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+
+	fs := openTestFileStore(t, path)
+	fs.Store(&Conversation{ID: "resp_1"})
+	fs.Clear()
+	fs.Close()
+
+	reopened := openTestFileStore(t, path)
+	if reopened.Size() != 0 {
+		t.Errorf("Size() after Clear and reopen = %d, want 0", reopened.Size())
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	memory, err := OpenStore(Config{})
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	if _, ok := memory.(*Store); !ok {
+		t.Errorf("OpenStore() without path = %T, want *Store", memory)
+	}
+
+	file, err := OpenStore(Config{Path: filepath.Join(t.TempDir(), "conversations.jsonl")})
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	defer file.Close()
+	if _, ok := file.(*FileStore); !ok {
+		t.Errorf("OpenStore() with path = %T, want *FileStore", file)
+	}
+}
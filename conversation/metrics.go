@@ -0,0 +1,26 @@
+package conversation
+
+import "ai-proxy/metrics"
+
+// Conversation store metrics. Lookups are those of the default store, made when
+// resolving previous_response_id or serving stored responses.
+var (
+	storeLookups = metrics.NewCounterVec("ai_proxy_conversation_store_lookups_total",
+		"Conversation store lookups, by result: hit or miss.", "result")
+	_ = metrics.NewGaugeFunc("ai_proxy_conversation_store_size",
+		"Number of conversations in the conversation store.", func() float64 {
+			if DefaultStore == nil {
+				return 0
+			}
+			return float64(DefaultStore.Size())
+		})
+)
+
+// recordLookup counts a default store lookup as a hit or a miss.
+func recordLookup(found bool) {
+	if found {
+		storeLookups.Inc("hit")
+	} else {
+		storeLookups.Inc("miss")
+	}
+}
@@ -1,6 +1,7 @@
-// Package conversation provides in-memory storage for multi-turn conversations.
+// Package conversation provides storage for multi-turn conversations.
 // It implements an LRU (Least Recently Used) cache with TTL-based expiration
-// to store conversation history for the Responses API's previous_response_id feature.
+// to store conversation history for the Responses API's previous_response_id feature,
+// and a file-backed backend that persists the cache across restarts.
 package conversation
 
 import (
@@ -16,31 +17,31 @@ import (
 // from the response, allowing the full conversation to be reconstructed.
 type Conversation struct {
 	// ID is the unique identifier for this conversation (response_id).
-	ID string
+	ID string `json:"id"`
 	// PreviousResponseID points to the parent conversation in the chain.
 	// This enables linked-list traversal for multi-turn conversations.
-	PreviousResponseID string
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
 	// Input contains the original input items from the request.
-	Input []types.InputItem
+	Input []types.InputItem `json:"input,omitempty"`
 	// Output contains the response output items.
-	Output []types.OutputItem
+	Output []types.OutputItem `json:"output,omitempty"`
 	// ReasoningItemID is the ID of the reasoning output item, if any.
 	// This is passed to the upstream LLM on continuation to enable
 	// reasoning continuity across turns.
-	ReasoningItemID string
+	ReasoningItemID string `json:"reasoning_item_id,omitempty"`
 	// EncryptedReasoning stores the encrypted blob in ZDR mode.
 	// When store:false, this contains the encrypted reasoning data.
-	EncryptedReasoning string
+	EncryptedReasoning string `json:"encrypted_reasoning,omitempty"`
 	// UserID is the owner of this conversation.
 	// Used for access control to prevent cross-user access.
-	UserID string
+	UserID string `json:"user_id,omitempty"`
 	// OrgID is the organization ID for this conversation.
 	// Used for organization-level access control.
-	OrgID string
+	OrgID string `json:"org_id,omitempty"`
 	// CreatedAt is the timestamp when the conversation was created.
-	CreatedAt time.Time
+	CreatedAt time.Time `json:"created_at"`
 	// ExpiresAt is the timestamp when the conversation should be expired.
-	ExpiresAt time.Time
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // Config holds configuration for the conversation store.
@@ -53,6 +54,9 @@ type Config struct {
 	// Conversations older than this are automatically expired.
 	// Default: 24 hours.
 	TTL time.Duration
+	// Path is the log file of the file-backed store.
+	// If empty, conversations are kept in memory only.
+	Path string
 }
 
 // Store provides thread-safe LRU storage for conversations.
@@ -113,27 +117,9 @@ func (s *Store) Get(id string) *Conversation {
 }
 
 // WalkChain walks the linked-list chain of conversations backward from the given ID.
-// It returns all conversations in chronological order (oldest first).
-// The chain traversal follows PreviousResponseID pointers until it reaches
-// a conversation with no parent or a missing conversation.
+// See the package-level WalkChain.
 func (s *Store) WalkChain(id string) []*Conversation {
-	var chain []*Conversation
-	cursor := id
-	for cursor != "" {
-		conv := s.Get(cursor)
-		if conv == nil {
-			break
-		}
-		// Append to slice (O(1) amortized)
-		chain = append(chain, conv)
-		cursor = conv.PreviousResponseID
-	}
-	// Reverse to get chronological order (oldest first)
-	// This is O(n) instead of O(n²) prepending
-	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
-		chain[i], chain[j] = chain[j], chain[i]
-	}
-	return chain
+	return WalkChain(s, id)
 }
 
 // OwnershipError indicates that a conversation chain access was denied
@@ -149,30 +135,15 @@ func (e *OwnershipError) Error() string {
 }
 
 // WalkChainWithOwnership walks the conversation chain and validates ownership.
-// Returns OwnershipError if the root conversation belongs to a different user.
-// If userID is empty, ownership check is skipped (for backwards compatibility).
+// See the package-level WalkChainWithOwnership.
 func (s *Store) WalkChainWithOwnership(id string, userID string) ([]*Conversation, error) {
-	chain := s.WalkChain(id)
-	if len(chain) == 0 {
-		return nil, nil
-	}
-
-	// Skip ownership check if no userID provided (backwards compatibility)
-	if userID == "" {
-		return chain, nil
-	}
-
-	// Validate ownership of root conversation
-	root := chain[0]
-	if root.UserID != "" && root.UserID != userID {
-		return nil, &OwnershipError{
-			ResponseID: id,
-			UserID:     userID,
-			ExpectedID: root.UserID,
-		}
-	}
+	return WalkChainWithOwnership(s, id, userID)
+}
 
-	return chain, nil
+// TokenCounter counts the tokens in a piece of text.
+// tokens.Counter satisfies this interface with a tiktoken encoder.
+type TokenCounter interface {
+	CountText(text string) int
 }
 
 // WalkChainOptions provides options for walking the conversation chain.
@@ -180,71 +151,156 @@ type WalkChainOptions struct {
 	// MaxTokens is the maximum number of tokens to include in the chain.
 	// If 0, no limit is applied.
 	MaxTokens int
+	// Counter counts tokens for the budget.
+	// If nil, a heuristic of ~4 characters per token is used.
+	Counter TokenCounter
+	// ReservedTokens is deducted from MaxTokens before any history is selected,
+	// e.g. for the input of the request the history is prepended to.
+	ReservedTokens int
+	// KeepLatest keeps the most recent turn even when it exceeds the budget.
+	// Set this when the current input answers tool calls made in that turn,
+	// so a function_call_output is never sent without its function_call.
+	KeepLatest bool
 }
 
 // WalkChainWithOptions walks the conversation chain with optional limits.
 // If MaxTokens is set, the chain is truncated to stay within the token budget,
-// dropping the oldest turns first.
+// dropping the oldest turns first. See TrimChain.
 func (s *Store) WalkChainWithOptions(id string, opts WalkChainOptions) []*Conversation {
-	chain := s.WalkChain(id)
+	return TrimChain(s.WalkChain(id), opts)
+}
+
+// TrimChain truncates a chronological chain to fit the token budget in opts,
+// dropping the oldest turns first. The latest turn is never dropped when
+// opts.KeepLatest is set.
+//
+// A turn's function_call outputs arrive as function_call_output items in the
+// next turn's input. If the turn that made the calls is dropped, the first kept
+// turn is returned as a copy without those function_call_output items, so no
+// tool result is sent without its function_call.
+func TrimChain(chain []*Conversation, opts WalkChainOptions) []*Conversation {
 	if opts.MaxTokens <= 0 || len(chain) == 0 {
 		return chain
 	}
 
-	// Count tokens and truncate from the beginning (oldest turns)
-	// Simple estimation: ~4 characters per token
-	totalTokens := 0
-	result := make([]*Conversation, 0, len(chain))
+	budget := opts.MaxTokens - opts.ReservedTokens
+	total := 0
+	start := len(chain)
 
 	// Walk from newest to oldest, accumulate until we hit limit
 	for i := len(chain) - 1; i >= 0; i-- {
-		conv := chain[i]
-		convTokens := estimateConversationTokens(conv)
-		if totalTokens+convTokens > opts.MaxTokens {
+		convTokens := CountConversationTokens(chain[i], opts.Counter)
+		if total+convTokens > budget && !(opts.KeepLatest && i == len(chain)-1) {
 			break
 		}
-		totalTokens += convTokens
-		result = append(result, conv)
+		total += convTokens
+		start = i
 	}
 
-	// Reverse to get chronological order
-	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
-		result[i], result[j] = result[j], result[i]
+	kept := chain[start:]
+	if start == 0 || len(kept) == 0 || !answersToolCalls(kept[0]) {
+		return kept
+	}
+	// The first kept turn answers tool calls of a dropped turn
+	trimmed := make([]*Conversation, len(kept))
+	copy(trimmed, kept)
+	trimmed[0] = withoutToolOutputs(kept[0])
+	return trimmed
+}
+
+// withoutToolOutputs returns a copy of conv whose input has no
+// function_call_output items.
+func withoutToolOutputs(conv *Conversation) *Conversation {
+	stripped := *conv
+	stripped.Input = make([]types.InputItem, 0, len(conv.Input))
+	for _, item := range conv.Input {
+		if item.Type != "function_call_output" {
+			stripped.Input = append(stripped.Input, item)
+		}
 	}
+	return &stripped
+}
 
-	return result
+// answersToolCalls reports whether the turn's input carries function_call_output
+// items, i.e. results for tool calls made in the previous turn.
+func answersToolCalls(conv *Conversation) bool {
+	for _, item := range conv.Input {
+		if item.Type == "function_call_output" {
+			return true
+		}
+	}
+	return false
 }
 
-// estimateConversationTokens estimates the token count for a conversation.
-// Uses a simple heuristic of ~4 characters per token.
-func estimateConversationTokens(conv *Conversation) int {
-	chars := 0
+// CountConversationTokens counts the tokens of a stored turn, including message text,
+// tool call names and arguments, tool outputs and reasoning summaries.
+// If counter is nil, a heuristic of ~4 characters per token is used.
+func CountConversationTokens(conv *Conversation, counter TokenCounter) int {
+	total := 0
 
 	// Count input content
 	for _, item := range conv.Input {
-		switch content := item.Content.(type) {
-		case string:
-			chars += len(content)
-		case []interface{}:
-			for _, part := range content {
-				if m, ok := part.(map[string]interface{}); ok {
-					if text, ok := m["text"].(string); ok {
-						chars += len(text)
-					}
-				}
-			}
-		}
+		total += itemOverheadTokens
+		total += CountValueTokens(item.Content, counter)
+		total += countText(counter, item.Name)
+		total += countText(counter, item.Arguments)
+		total += countText(counter, item.Output)
 	}
 
 	// Count output content
 	for _, item := range conv.Output {
+		total += itemOverheadTokens
 		for _, content := range item.Content {
-			chars += len(content.Text)
+			total += countText(counter, content.Text)
+		}
+		total += countText(counter, item.Name)
+		total += countText(counter, item.Arguments)
+		total += countText(counter, string(item.Summary))
+	}
+
+	return total
+}
+
+// itemOverheadTokens approximates the per-item cost of role markers and structure.
+const itemOverheadTokens = 4
+
+// CountValueTokens counts the tokens of every string in a decoded JSON value,
+// such as a Responses input that is either a string or an array of items.
+// If counter is nil, a heuristic of ~4 characters per token is used.
+func CountValueTokens(v interface{}, counter TokenCounter) int {
+	switch val := v.(type) {
+	case string:
+		return countText(counter, val)
+	case []interface{}:
+		total := 0
+		for _, elem := range val {
+			total += CountValueTokens(elem, counter)
+		}
+		return total
+	case map[string]interface{}:
+		total := itemOverheadTokens
+		for key, elem := range val {
+			// Type tags are structural, not content
+			if key == "type" {
+				continue
+			}
+			total += CountValueTokens(elem, counter)
 		}
+		return total
+	default:
+		return 0
 	}
+}
 
-	// Simple estimation: ~4 characters per token
-	return chars / 4
+// countText counts tokens in text with counter, or estimates ~4 characters per token.
+func countText(counter TokenCounter, text string) int {
+	if text == "" {
+		return 0
+	}
+	if counter != nil {
+		return counter.CountText(text)
+	}
+	return (len(text) + 3) / 4
 }
 
 // Store saves a conversation, evicting the oldest if at capacity.
@@ -309,6 +365,24 @@ func (s *Store) Clear() {
 	s.lru.Init()
 }
 
+// Close is a no-op; the in-memory store holds no external resources.
+func (s *Store) Close() error {
+	return nil
+}
+
+// snapshot returns the live conversations from least to most recently used.
+func (s *Store) snapshot() []*Conversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupExpired()
+	convs := make([]*Conversation, 0, s.lru.Len())
+	for elem := s.lru.Back(); elem != nil; elem = elem.Prev() {
+		convs = append(convs, elem.Value.(*entry).conversation)
+	}
+	return convs
+}
+
 // deleteElement removes an element from both the map and the list.
 // Must be called with lock held.
 func (s *Store) deleteElement(elem *list.Element) {
@@ -346,9 +420,9 @@ func (s *Store) cleanupExpired() {
 
 // DefaultStore is the global conversation store instance.
 // It is initialized by the main package at startup.
-var DefaultStore *Store
+var DefaultStore Backend
 
-// InitDefaultStore initializes the global conversation store.
+// InitDefaultStore initializes the global conversation store in memory.
 func InitDefaultStore(config Config) {
 	DefaultStore = NewStore(config)
 }
@@ -359,7 +433,9 @@ func GetFromDefault(id string) *Conversation {
 	if DefaultStore == nil {
 		return nil
 	}
-	return DefaultStore.Get(id)
+	conv := DefaultStore.Get(id)
+	recordLookup(conv != nil)
+	return conv
 }
 
 // WalkChainFromDefault walks the conversation chain from the default store.
@@ -368,7 +444,9 @@ func WalkChainFromDefault(id string) []*Conversation {
 	if DefaultStore == nil {
 		return nil
 	}
-	return DefaultStore.WalkChain(id)
+	chain := WalkChain(DefaultStore, id)
+	recordLookup(len(chain) > 0)
+	return chain
 }
 
 // WalkChainFromDefaultWithOwnership walks the conversation chain with ownership validation.
@@ -377,7 +455,10 @@ func WalkChainFromDefaultWithOwnership(id string, userID string) ([]*Conversatio
 	if DefaultStore == nil {
 		return nil, nil
 	}
-	return DefaultStore.WalkChainWithOwnership(id, userID)
+	chain, err := WalkChainWithOwnership(DefaultStore, id, userID)
+	// A chain owned by another user was still found
+	recordLookup(len(chain) > 0 || err != nil)
+	return chain, err
 }
 
 // StoreInDefault saves a conversation to the default store.
@@ -394,5 +475,7 @@ func WalkChainFromDefaultWithOptions(id string, opts WalkChainOptions) []*Conver
 	if DefaultStore == nil {
 		return nil
 	}
-	return DefaultStore.WalkChainWithOptions(id, opts)
+	chain := WalkChain(DefaultStore, id)
+	recordLookup(len(chain) > 0)
+	return TrimChain(chain, opts)
 }
@@ -1,6 +1,7 @@
 package conversation
 
 import (
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -354,6 +355,24 @@ func TestDefaultStore(t *testing.T) {
 	}
 }
 
+func TestDefaultStore_LookupMetrics(t *testing.T) {
+	DefaultStore = nil
+	InitDefaultStore(Config{MaxSize: 10, TTL: time.Hour})
+	t.Cleanup(func() { DefaultStore = nil })
+
+	StoreInDefault(&Conversation{ID: "resp_1", CreatedAt: time.Now()})
+	hits, misses := storeLookups.Value("hit"), storeLookups.Value("miss")
+
+	GetFromDefault("resp_1")
+	WalkChainFromDefault("resp_1")
+	GetFromDefault("missing")
+	WalkChainFromDefaultWithOptions("missing", WalkChainOptions{})
+
+	if storeLookups.Value("hit")-hits != 2 || storeLookups.Value("miss")-misses != 2 {
+		t.Errorf("hits=%v misses=%v, want 2 and 2", storeLookups.Value("hit")-hits, storeLookups.Value("miss")-misses)
+	}
+}
+
 func TestStore_WalkChain(t *testing.T) {
 	store := NewStore(Config{MaxSize: 10, TTL: time.Hour})
 
@@ -498,3 +517,186 @@ func TestWalkChainFromDefault(t *testing.T) {
 		t.Errorf("Unexpected chain order: %v", chain)
 	}
 }
+
+// wordCounter counts one token per whitespace-separated word.
+type wordCounter struct{}
+
+func (wordCounter) CountText(text string) int {
+	return len(strings.Fields(text))
+}
+
+// textTurn builds a turn with a user message and an assistant reply.
+func textTurn(id, prev, user, assistant string) *Conversation {
+	return &Conversation{
+		ID:                 id,
+		PreviousResponseID: prev,
+		Input:              []types.InputItem{{Type: "message", Role: "user", Content: user}},
+		Output: []types.OutputItem{{
+			Type:    "message",
+			Role:    "assistant",
+			Content: []types.OutputContent{{Type: "output_text", Text: assistant}},
+		}},
+	}
+}
+
+func TestCountConversationTokens(t *testing.T) {
+	conv := &Conversation{
+		Input: []types.InputItem{
+			{Type: "function_call_output", CallID: "call_1", Output: "sunny and warm"},
+		},
+		Output: []types.OutputItem{
+			{Type: "reasoning", Summary: "think about it"},
+			{Type: "function_call", Name: "get_weather", Arguments: `{"city": "Paris"}`},
+		},
+	}
+
+	// 3 items * 4 overhead + 3 (output) + 3 (summary) + 1 (name) + 2 (arguments)
+	if got := CountConversationTokens(conv, wordCounter{}); got != 21 {
+		t.Errorf("CountConversationTokens() = %d, want 21", got)
+	}
+
+	if got := CountConversationTokens(conv, nil); got <= 12 {
+		t.Errorf("CountConversationTokens() with estimate = %d, want more than overhead", got)
+	}
+}
+
+func TestTrimChain_DropsOldestTurns(t *testing.T) {
+	chain := []*Conversation{
+		textTurn("resp_1", "", "one two three", "four five six"),
+		textTurn("resp_2", "resp_1", "one two three", "four five six"),
+		textTurn("resp_3", "resp_2", "one two three", "four five six"),
+	}
+
+	// Each turn costs 2*4 + 6 = 14 tokens
+	tests := []struct {
+		name    string
+		opts    WalkChainOptions
+		wantIDs []string
+	}{
+		{"no limit", WalkChainOptions{Counter: wordCounter{}}, []string{"resp_1", "resp_2", "resp_3"}},
+		{"fits all", WalkChainOptions{MaxTokens: 42, Counter: wordCounter{}}, []string{"resp_1", "resp_2", "resp_3"}},
+		{"fits two", WalkChainOptions{MaxTokens: 41, Counter: wordCounter{}}, []string{"resp_2", "resp_3"}},
+		{"reserved tokens", WalkChainOptions{MaxTokens: 42, ReservedTokens: 10, Counter: wordCounter{}}, []string{"resp_2", "resp_3"}},
+		{"fits none", WalkChainOptions{MaxTokens: 10, Counter: wordCounter{}}, nil},
+		{"keep latest", WalkChainOptions{MaxTokens: 10, Counter: wordCounter{}, KeepLatest: true}, []string{"resp_3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TrimChain(chain, tt.opts)
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("TrimChain() returned %d turns, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, conv := range got {
+				if conv.ID != tt.wantIDs[i] {
+					t.Errorf("turn %d = %s, want %s", i, conv.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrimChain_KeepsFunctionCallWithOutput(t *testing.T) {
+	chain := []*Conversation{
+		textTurn("resp_1", "", "hello", "hi"),
+		{
+			ID:                 "resp_2",
+			PreviousResponseID: "resp_1",
+			Input:              []types.InputItem{{Type: "message", Role: "user", Content: "weather in Paris please"}},
+			Output:             []types.OutputItem{{Type: "function_call", CallID: "call_1", Name: "get_weather", Arguments: "Paris"}},
+		},
+		{
+			ID:                 "resp_3",
+			PreviousResponseID: "resp_2",
+			Input:              []types.InputItem{{Type: "function_call_output", CallID: "call_1", Output: "sunny"}},
+			Output: []types.OutputItem{{
+				Type:    "message",
+				Role:    "assistant",
+				Content: []types.OutputContent{{Type: "output_text", Text: "It is sunny"}},
+			}},
+		},
+	}
+
+	// resp_3 costs 12 and resp_2 costs 14: a budget of 20 fits resp_3 alone,
+	// but its function_call_output must not be sent without resp_2's call.
+	got := TrimChain(chain, WalkChainOptions{MaxTokens: 20, Counter: wordCounter{}})
+	if len(got) != 1 || got[0].ID != "resp_3" {
+		t.Fatalf("TrimChain() = %v, want [resp_3]", chainIDs(got))
+	}
+	if len(got[0].Input) != 0 || len(got[0].Output) != 1 {
+		t.Errorf("expected resp_3 without its function_call_output, got input %+v", got[0].Input)
+	}
+	if len(chain[2].Input) != 1 {
+		t.Error("TrimChain() must not modify the stored turn")
+	}
+
+	got = TrimChain(chain, WalkChainOptions{MaxTokens: 26, Counter: wordCounter{}})
+	if len(got) != 2 || got[0].ID != "resp_2" || got[1].ID != "resp_3" {
+		t.Fatalf("TrimChain() = %v, want [resp_2 resp_3]", chainIDs(got))
+	}
+}
+
+// toolTurn returns a turn that answers the tool call of the previous turn, if
+// any, and makes a tool call of its own, as in an agent's tool loop.
+func toolTurn(id, prev string) *Conversation {
+	conv := &Conversation{
+		ID:                 id,
+		PreviousResponseID: prev,
+		Output:             []types.OutputItem{{Type: "function_call", CallID: "call_" + id, Name: "shell", Arguments: "ls"}},
+	}
+	if prev != "" {
+		conv.Input = []types.InputItem{{Type: "function_call_output", CallID: "call_" + prev, Output: "main.go"}}
+	} else {
+		conv.Input = []types.InputItem{{Type: "message", Role: "user", Content: "list files"}}
+	}
+	return conv
+}
+
+func TestTrimChain_ConsecutiveToolTurns(t *testing.T) {
+	chain := []*Conversation{
+		toolTurn("resp_1", ""),
+		toolTurn("resp_2", "resp_1"),
+		toolTurn("resp_3", "resp_2"),
+		toolTurn("resp_4", "resp_3"),
+		toolTurn("resp_5", "resp_4"),
+	}
+
+	// Each turn costs 2*4 + 1 + 1 + 1 = 11 tokens: the budget fits the last two
+	for _, keepLatest := range []bool{false, true} {
+		got := TrimChain(chain, WalkChainOptions{MaxTokens: 22, Counter: wordCounter{}, KeepLatest: keepLatest})
+		if len(got) != 2 || got[0].ID != "resp_4" || got[1].ID != "resp_5" {
+			t.Fatalf("KeepLatest=%v: TrimChain() = %v, want [resp_4 resp_5]", keepLatest, chainIDs(got))
+		}
+		if answersToolCalls(got[0]) {
+			t.Errorf("KeepLatest=%v: resp_4 answers the dropped call of resp_3: %+v", keepLatest, got[0].Input)
+		}
+		if !answersToolCalls(got[1]) {
+			t.Errorf("KeepLatest=%v: resp_5 should keep the output of resp_4's call", keepLatest)
+		}
+	}
+
+	// The latest turn is kept even when nothing fits
+	got := TrimChain(chain, WalkChainOptions{MaxTokens: 5, Counter: wordCounter{}, KeepLatest: true})
+	if len(got) != 1 || got[0].ID != "resp_5" || answersToolCalls(got[0]) {
+		t.Fatalf("TrimChain() = %v, want resp_5 without tool outputs", chainIDs(got))
+	}
+}
+
+func TestStore_WalkChainWithOptions(t *testing.T) {
+	store := NewStore(Config{})
+	store.Store(textTurn("resp_1", "", "one two three", "four five six"))
+	store.Store(textTurn("resp_2", "resp_1", "one two three", "four five six"))
+
+	got := store.WalkChainWithOptions("resp_2", WalkChainOptions{MaxTokens: 20, Counter: wordCounter{}})
+	if len(got) != 1 || got[0].ID != "resp_2" {
+		t.Fatalf("WalkChainWithOptions() = %v, want [resp_2]", chainIDs(got))
+	}
+}
+
+func chainIDs(chain []*Conversation) []string {
+	ids := make([]string, len(chain))
+	for i, conv := range chain {
+		ids[i] = conv.ID
+	}
+	return ids
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"time"
 
+	"ai-proxy/transform"
 	"ai-proxy/types"
 
 	"github.com/tmaxmax/go-sse"
@@ -375,6 +376,7 @@ type AnthropicToResponsesTransformer struct {
 
 	outputIndex   int
 	blockTypeMap  map[int]string // block index -> type
+	openBlock     string         // type of the open content block, "" if none
 	toolCallItems map[int]*types.OutputItem
 	toolCallArgs  map[int]string // block index -> accumulated arguments
 
@@ -471,6 +473,7 @@ func (t *AnthropicToResponsesTransformer) handleContentBlockStart(data string) e
 		return err
 	}
 	t.blockTypeMap[e.Index] = e.ContentBlock.Type
+	t.openBlock = e.ContentBlock.Type
 
 	switch e.ContentBlock.Type {
 	case "text":
@@ -558,6 +561,7 @@ func (t *AnthropicToResponsesTransformer) handleContentBlockStop(data string) er
 	}
 
 	blockType := t.blockTypeMap[e.Index]
+	t.openBlock = ""
 	switch blockType {
 	case "text":
 		if err := t.emitEvent(string(types.EventResponseOutputTextDone), map[string]interface{}{
@@ -673,3 +677,20 @@ func (t *AnthropicToResponsesTransformer) Initialize() error {
 func (t *AnthropicToResponsesTransformer) HandleCancel() error {
 	return nil
 }
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *AnthropicToResponsesTransformer) State() string {
+	switch {
+	case t.completed:
+		return transform.StateDone
+	case t.openBlock == "thinking":
+		return transform.StateReasoning
+	case t.openBlock == "text":
+		return transform.StateText
+	case t.openBlock == "tool_use":
+		return transform.StateToolCall
+	default:
+		return transform.StateWaiting
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 
+	"ai-proxy/transform"
 	"ai-proxy/types"
 
 	"github.com/tmaxmax/go-sse"
@@ -859,3 +860,20 @@ func (t *ChatToAnthropicTransformer) Initialize() error {
 func (t *ChatToAnthropicTransformer) HandleCancel() error {
 	return nil
 }
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *ChatToAnthropicTransformer) State() string {
+	switch {
+	case t.messageStopSent:
+		return transform.StateDone
+	case t.contentOpen && t.contentType == "thinking":
+		return transform.StateReasoning
+	case t.contentOpen:
+		return transform.StateText
+	case len(t.toolCalls) > 0:
+		return transform.StateToolCall
+	default:
+		return transform.StateWaiting
+	}
+}
@@ -0,0 +1,309 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"ai-proxy/types"
+)
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Chat Completions → Gemini — Request
+// ─────────────────────────────────────────────────────────────────────────────
+
+// geminiSkipThoughtSignature is the thought signature Gemini documents for
+// function calls in histories it did not generate itself. Thinking models
+// reject a function call turn without a signature.
+const geminiSkipThoughtSignature = "skip_thought_signature_validator"
+
+// ChatToGeminiConverter converts OpenAI ChatCompletionRequest to a Gemini
+// generateContent request. It implements the RequestConverter interface.
+type ChatToGeminiConverter struct{}
+
+// NewChatToGeminiConverter creates a new converter for Chat to Gemini format.
+func NewChatToGeminiConverter() *ChatToGeminiConverter {
+	return &ChatToGeminiConverter{}
+}
+
+// Convert transforms an OpenAI ChatCompletionRequest body to Gemini format.
+func (c *ChatToGeminiConverter) Convert(body []byte) ([]byte, error) {
+	return TransformChatToGemini(body)
+}
+
+// TransformChatToGemini converts a Chat Completions request body to a Gemini
+// generateContent request. Messages and Responses requests reach Gemini by
+// being converted to Chat Completions first.
+//
+// Field mappings:
+//   - system/developer messages → systemInstruction (joined with \n\n)
+//   - user messages → "user" contents; image_url parts → inlineData or fileData
+//   - assistant messages → "model" contents; tool_calls → functionCall parts
+//   - tool messages → functionResponse parts, named after the call they answer
+//   - tools → tools[0].functionDeclarations with parametersJsonSchema
+//   - tool_choice → toolConfig.functionCallingConfig (auto/required/none → AUTO/ANY/NONE)
+//   - max_tokens, temperature, top_p, top_k, stop, n, penalties, seed → generationConfig
+//   - response_format → responseMimeType and responseJsonSchema
+//   - reasoning_effort → thinkingConfig ("none" disables thinking)
+//
+// Dropped: model (named in the URL), stream (chosen by the URL), logprobs,
+// logit_bias, user, parallel_tool_calls.
+func TransformChatToGemini(body []byte) ([]byte, error) {
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse ChatCompletionRequest: %w", err)
+	}
+
+	out, err := ChatToGeminiRequest(&req)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(out)
+}
+
+// ChatToGeminiRequest converts a Chat Completions request into a Gemini request.
+func ChatToGeminiRequest(req *types.ChatCompletionRequest) (*types.GeminiRequest, error) {
+	out := &types.GeminiRequest{Contents: []types.GeminiContent{}}
+
+	var system []string
+	if req.System != "" {
+		system = append(system, req.System)
+	}
+
+	// Tool messages only carry the call ID; Gemini needs the function name
+	callNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := ExtractTextFromContent(msg.Content); text != "" {
+				system = append(system, text)
+			}
+		case "assistant":
+			parts := chatContentToGeminiParts(msg.Content)
+			for i, tc := range msg.ToolCalls {
+				callNames[tc.ID] = tc.Function.Name
+				part := types.GeminiPart{FunctionCall: &types.GeminiFunctionCall{
+					ID:   tc.ID,
+					Name: tc.Function.Name,
+					Args: argumentsObject(tc.Function.Arguments),
+				}}
+				if i == 0 {
+					part.ThoughtSignature = geminiSkipThoughtSignature
+				}
+				parts = append(parts, part)
+			}
+			out.Contents = appendGeminiContent(out.Contents, "model", parts)
+		case "tool":
+			part := types.GeminiPart{FunctionResponse: &types.GeminiFunctionResponse{
+				ID:       msg.ToolCallID,
+				Name:     callNames[msg.ToolCallID],
+				Response: geminiFunctionResult(msg.Content),
+			}}
+			out.Contents = appendGeminiContent(out.Contents, "user", []types.GeminiPart{part})
+		default:
+			out.Contents = appendGeminiContent(out.Contents, "user", chatContentToGeminiParts(msg.Content))
+		}
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &types.GeminiContent{
+			Parts: []types.GeminiPart{{Text: strings.Join(system, "\n\n")}},
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		decls := make([]types.GeminiFunctionDeclaration, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			decls = append(decls, types.GeminiFunctionDeclaration{
+				Name:                 t.Function.Name,
+				Description:          t.Function.Description,
+				ParametersJSONSchema: t.Function.Parameters,
+			})
+		}
+		out.Tools = []types.GeminiTool{{FunctionDeclarations: decls}}
+		out.ToolConfig = chatToolChoiceToGemini(req.ToolChoice)
+	}
+
+	out.GenerationConfig = chatToGeminiGenerationConfig(req)
+	return out, nil
+}
+
+// appendGeminiContent appends a turn to contents, merging it into the last
+// turn if that has the same role. Gemini expects the parallel function
+// responses of one model turn in a single user turn.
+func appendGeminiContent(contents []types.GeminiContent, role string, parts []types.GeminiPart) []types.GeminiContent {
+	if len(parts) == 0 {
+		return contents
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, types.GeminiContent{Role: role, Parts: parts})
+}
+
+// chatContentToGeminiParts converts Chat message content, a string or an array
+// of content parts, to Gemini parts. Empty text and unknown parts are skipped.
+func chatContentToGeminiParts(content interface{}) []types.GeminiPart {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []types.GeminiPart{{Text: c}}
+	case []interface{}:
+		var parts []types.GeminiPart
+		for _, item := range c {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				if text, _ := block["text"].(string); text != "" {
+					parts = append(parts, types.GeminiPart{Text: text})
+				}
+			case "image_url":
+				url := ""
+				switch u := block["image_url"].(type) {
+				case string:
+					url = u
+				case map[string]interface{}:
+					url, _ = u["url"].(string)
+				}
+				if part, ok := geminiMediaPart(url); ok {
+					parts = append(parts, part)
+				}
+			}
+		}
+		return parts
+	default:
+		return nil
+	}
+}
+
+// geminiMediaPart converts an image URL to an inlineData part for data URIs
+// and a fileData part otherwise.
+func geminiMediaPart(url string) (types.GeminiPart, bool) {
+	if url == "" {
+		return types.GeminiPart{}, false
+	}
+	if mediaType, data, err := ParseDataURI(url); err == nil {
+		return types.GeminiPart{InlineData: &types.GeminiBlob{MimeType: mediaType, Data: data}}, true
+	}
+	return types.GeminiPart{FileData: &types.GeminiFileData{
+		MimeType: imageMimeTypes[strings.ToLower(path.Ext(url))],
+		FileURI:  url,
+	}}, true
+}
+
+// imageMimeTypes maps image file extensions to the media type sent with
+// fileData parts.
+var imageMimeTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// argumentsObject converts a tool call's JSON arguments string to the args
+// object of a Gemini functionCall or an Ollama tool call. Invalid or
+// non-object arguments become an empty object.
+func argumentsObject(args string) json.RawMessage {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(args), &obj); err != nil || obj == nil {
+		return json.RawMessage(`{}`)
+	}
+	return json.RawMessage(args)
+}
+
+// geminiFunctionResult converts tool message content to the response object of
+// a functionResponse. A JSON object result is sent as-is; anything else is
+// wrapped as {"content": text}.
+func geminiFunctionResult(content interface{}) json.RawMessage {
+	text := ExtractTextFromContent(content)
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &obj); err == nil && obj != nil {
+		return json.RawMessage(text)
+	}
+	return MustMarshal(map[string]string{"content": text})
+}
+
+// chatToolChoiceToGemini converts a Chat tool_choice to a Gemini tool config.
+// Returns nil for "auto" and unset, which is Gemini's default.
+func chatToolChoiceToGemini(toolChoice interface{}) *types.GeminiToolConfig {
+	config := func(mode string, names ...string) *types.GeminiToolConfig {
+		return &types.GeminiToolConfig{FunctionCallingConfig: &types.GeminiFunctionCallingConfig{
+			Mode:                 mode,
+			AllowedFunctionNames: names,
+		}}
+	}
+	switch tc := toolChoice.(type) {
+	case string:
+		switch tc {
+		case "none":
+			return config("NONE")
+		case "required":
+			return config("ANY")
+		}
+	case map[string]interface{}:
+		if name := extractToolChoiceName(tc); name != "" {
+			return config("ANY", name)
+		}
+	}
+	return nil
+}
+
+// chatToGeminiGenerationConfig collects the sampling and output parameters of
+// a Chat request. Returns nil if none is set.
+func chatToGeminiGenerationConfig(req *types.ChatCompletionRequest) *types.GeminiGenerationConfig {
+	gc := &types.GeminiGenerationConfig{
+		TopK:            req.TopK,
+		MaxOutputTokens: req.MaxTokens,
+		StopSequences:   NewStopConverter().ConvertOpenAIToAnthropic(req.Stop),
+		Seed:            req.Seed,
+	}
+	if req.N > 1 {
+		gc.CandidateCount = req.N
+	}
+	if req.Temperature != 0 {
+		gc.Temperature = &req.Temperature
+	}
+	if req.TopP != 0 {
+		gc.TopP = &req.TopP
+	}
+	if req.PresencePenalty != 0 {
+		gc.PresencePenalty = &req.PresencePenalty
+	}
+	if req.FrequencyPenalty != 0 {
+		gc.FrequencyPenalty = &req.FrequencyPenalty
+	}
+
+	if rf := req.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case "json_object":
+			gc.ResponseMimeType = "application/json"
+		case "json_schema":
+			gc.ResponseMimeType = "application/json"
+			if rf.JSONSchema != nil {
+				gc.ResponseJSONSchema = rf.JSONSchema.Schema
+			}
+		}
+	}
+
+	switch req.ReasoningEffort {
+	case "":
+	case "none":
+		budget := 0
+		gc.ThinkingConfig = &types.GeminiThinkingConfig{ThinkingBudget: &budget}
+	default:
+		budget := ReasoningEffortToBudget(req.ReasoningEffort)
+		gc.ThinkingConfig = &types.GeminiThinkingConfig{IncludeThoughts: true, ThinkingBudget: &budget}
+	}
+
+	if b, _ := json.Marshal(gc); string(b) == "{}" {
+		return nil
+	}
+	return gc
+}
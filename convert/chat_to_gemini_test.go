@@ -0,0 +1,186 @@
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ai-proxy/types"
+)
+
+// convertChatToGemini converts a Chat request body and decodes the Gemini request.
+func convertChatToGemini(t *testing.T, body string) types.GeminiRequest {
+	t.Helper()
+	out, err := TransformChatToGemini([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformChatToGemini() error: %v", err)
+	}
+	var req types.GeminiRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid Gemini request: %v\n%s", err, out)
+	}
+	return req
+}
+
+func TestTransformChatToGemini_Messages(t *testing.T) {
+	req := convertChatToGemini(t, `{
+		"model": "gemini-2.5-flash",
+		"stream": true,
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "developer", "content": "Use tools."},
+			{"role": "user", "content": [
+				{"type": "text", "text": "What is in this image?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBORw0KGgo="}},
+				{"type": "image_url", "image_url": {"url": "https://example.com/cat.jpg"}}
+			]},
+			{"role": "assistant", "content": "Let me check.", "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"cat\"}"}},
+				{"id": "call_2", "type": "function", "function": {"name": "weather", "arguments": ""}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "{\"animal\":\"cat\"}"},
+			{"role": "tool", "tool_call_id": "call_2", "content": "sunny"},
+			{"role": "user", "content": "Thanks"}
+		]
+	}`)
+
+	if req.SystemInstruction == nil || len(req.SystemInstruction.Parts) != 1 ||
+		req.SystemInstruction.Parts[0].Text != "Be brief.\n\nUse tools." {
+		t.Errorf("systemInstruction = %+v", req.SystemInstruction)
+	}
+
+	if len(req.Contents) != 3 {
+		t.Fatalf("expected 3 contents, got %d: %+v", len(req.Contents), req.Contents)
+	}
+	user := req.Contents[0]
+	if user.Role != "user" || len(user.Parts) != 3 || user.Parts[0].Text != "What is in this image?" {
+		t.Fatalf("unexpected user turn: %+v", user)
+	}
+	if b := user.Parts[1].InlineData; b == nil || b.MimeType != "image/png" || b.Data != "iVBORw0KGgo=" {
+		t.Errorf("data URI should become inlineData, got %+v", user.Parts[1])
+	}
+	if f := user.Parts[2].FileData; f == nil || f.FileURI != "https://example.com/cat.jpg" || f.MimeType != "image/jpeg" {
+		t.Errorf("image URL should become fileData, got %+v", user.Parts[2])
+	}
+
+	model := req.Contents[1]
+	if model.Role != "model" || len(model.Parts) != 3 || model.Parts[0].Text != "Let me check." {
+		t.Fatalf("unexpected model turn: %+v", model)
+	}
+	call := model.Parts[1].FunctionCall
+	if call == nil || call.ID != "call_1" || call.Name != "lookup" || string(call.Args) != `{"q":"cat"}` {
+		t.Errorf("unexpected functionCall: %+v", call)
+	}
+	if model.Parts[1].ThoughtSignature == "" || model.Parts[2].ThoughtSignature != "" {
+		t.Errorf("only the first functionCall of a turn should carry a thought signature: %+v", model.Parts)
+	}
+	if call := model.Parts[2].FunctionCall; call == nil || string(call.Args) != `{}` {
+		t.Errorf("empty arguments should become {}, got %+v", call)
+	}
+
+	// Both function responses and the following user message share one user turn
+	responses := req.Contents[2]
+	if responses.Role != "user" || len(responses.Parts) != 3 {
+		t.Fatalf("unexpected function response turn: %+v", responses)
+	}
+	if r := responses.Parts[0].FunctionResponse; r == nil || r.Name != "lookup" || r.ID != "call_1" || string(r.Response) != `{"animal":"cat"}` {
+		t.Errorf("JSON object result should be sent as-is, got %+v", r)
+	}
+	if r := responses.Parts[1].FunctionResponse; r == nil || r.Name != "weather" || string(r.Response) != `{"content":"sunny"}` {
+		t.Errorf("text result should be wrapped, got %+v", r)
+	}
+
+	if text := responses.Parts[2].Text; text != "Thanks" {
+		t.Errorf("unexpected last part: %q", text)
+	}
+}
+
+func TestTransformChatToGemini_Tools(t *testing.T) {
+	tests := []struct {
+		name       string
+		toolChoice string
+		wantMode   string
+		wantNames  []string
+	}{
+		{"auto", `"auto"`, "", nil},
+		{"required", `"required"`, "ANY", nil},
+		{"none", `"none"`, "NONE", nil},
+		{"named", `{"type":"function","function":{"name":"lookup"}}`, "ANY", []string{"lookup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := convertChatToGemini(t, `{
+				"model": "gemini-2.5-flash",
+				"messages": [{"role": "user", "content": "hi"}],
+				"tools": [{"type": "function", "function": {"name": "lookup", "description": "Look up",
+					"parameters": {"type": "object", "properties": {"q": {"type": "string"}}, "additionalProperties": false}}}],
+				"tool_choice": `+tt.toolChoice+`
+			}`)
+
+			if len(req.Tools) != 1 || len(req.Tools[0].FunctionDeclarations) != 1 {
+				t.Fatalf("unexpected tools: %+v", req.Tools)
+			}
+			decl := req.Tools[0].FunctionDeclarations[0]
+			if decl.Name != "lookup" || decl.Description != "Look up" ||
+				string(decl.ParametersJSONSchema) != `{"type":"object","properties":{"q":{"type":"string"}},"additionalProperties":false}` {
+				t.Errorf("unexpected declaration: %+v", decl)
+			}
+
+			if tt.wantMode == "" {
+				if req.ToolConfig != nil {
+					t.Errorf("expected no toolConfig, got %+v", req.ToolConfig.FunctionCallingConfig)
+				}
+				return
+			}
+			fc := req.ToolConfig.FunctionCallingConfig
+			if fc.Mode != tt.wantMode || len(fc.AllowedFunctionNames) != len(tt.wantNames) {
+				t.Errorf("functionCallingConfig = %+v, want mode %s names %v", fc, tt.wantMode, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestTransformChatToGemini_GenerationConfig(t *testing.T) {
+	req := convertChatToGemini(t, `{
+		"model": "gemini-2.5-pro",
+		"messages": [{"role": "user", "content": "hi"}],
+		"max_tokens": 1000,
+		"temperature": 0.5,
+		"top_p": 0.9,
+		"top_k": 40,
+		"stop": ["END"],
+		"seed": 7,
+		"reasoning_effort": "high",
+		"response_format": {"type": "json_schema", "json_schema": {"name": "out", "schema": {"type": "object"}}}
+	}`)
+
+	gc := req.GenerationConfig
+	if gc == nil {
+		t.Fatal("expected generationConfig")
+	}
+	if gc.MaxOutputTokens != 1000 || *gc.Temperature != 0.5 || *gc.TopP != 0.9 || gc.TopK != 40 ||
+		len(gc.StopSequences) != 1 || gc.StopSequences[0] != "END" || *gc.Seed != 7 {
+		t.Errorf("unexpected sampling config: %+v", gc)
+	}
+	if gc.ResponseMimeType != "application/json" || string(gc.ResponseJSONSchema) != `{"type":"object"}` {
+		t.Errorf("unexpected response format: %s %s", gc.ResponseMimeType, gc.ResponseJSONSchema)
+	}
+	if tc := gc.ThinkingConfig; tc == nil || !tc.IncludeThoughts || *tc.ThinkingBudget != ReasoningEffortToBudget("high") {
+		t.Errorf("unexpected thinkingConfig: %+v", tc)
+	}
+
+	req = convertChatToGemini(t, `{"model": "gemini-2.5-flash", "messages": [{"role": "user", "content": "hi"}], "reasoning_effort": "none"}`)
+	if tc := req.GenerationConfig.ThinkingConfig; tc == nil || tc.IncludeThoughts || *tc.ThinkingBudget != 0 {
+		t.Errorf("reasoning_effort none should disable thinking, got %+v", tc)
+	}
+
+	req = convertChatToGemini(t, `{"model": "gemini-2.5-flash", "messages": [{"role": "user", "content": "hi"}]}`)
+	if req.GenerationConfig != nil {
+		t.Errorf("expected no generationConfig, got %+v", req.GenerationConfig)
+	}
+}
+
+func TestTransformChatToGemini_InvalidJSON(t *testing.T) {
+	if _, err := TransformChatToGemini([]byte(`{invalid`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
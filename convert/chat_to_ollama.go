@@ -0,0 +1,509 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-proxy/types"
+)
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Chat Completions → Ollama — Streaming Response
+// ─────────────────────────────────────────────────────────────────────────────
+
+// ChatToOllamaWriter receives the Chat Completions SSE output of a transformer
+// chain and writes it as an Ollama /api/chat or /api/generate response.
+//
+// Streaming, each content or reasoning delta becomes an NDJSON line; tool
+// calls, including those extracted from Kimi/GLM-5 markup upstream, are
+// assembled and sent in one message.tool_calls line when the stream finishes,
+// followed by the done line with the finish reason and token counts.
+// Aggregating, the whole response becomes a single object returned by Result.
+//
+// @note NOT thread-safe. Use from a single goroutine.
+type ChatToOllamaWriter struct {
+	w            io.Writer // nil when aggregating
+	model        string    // model name reported to the client
+	generate     bool      // /api/generate response shape instead of /api/chat
+	hideThinking bool      // the client sent "think": false
+
+	buf        bytes.Buffer // partial SSE event
+	start      time.Time
+	firstToken time.Time
+
+	content      strings.Builder // aggregated content
+	thinking     strings.Builder // aggregated reasoning
+	toolCalls    map[int]*types.ToolCall
+	finishReason string
+	usage        *types.Usage
+	done         bool
+	err          error // stream error, or the first failed write
+}
+
+// NewChatToOllamaWriter creates a writer streaming an Ollama NDJSON response.
+//
+// @param w - Writer receiving NDJSON lines. Must not be nil.
+// @param model - Model name reported in every line.
+// @param generate - true for /api/generate lines, false for /api/chat lines.
+func NewChatToOllamaWriter(w io.Writer, model string, generate bool) *ChatToOllamaWriter {
+	return &ChatToOllamaWriter{
+		w:         w,
+		model:     model,
+		generate:  generate,
+		start:     time.Now(),
+		toolCalls: make(map[int]*types.ToolCall),
+	}
+}
+
+// NewOllamaAggregator creates a writer assembling a non-streaming Ollama
+// response, returned by Result. It implements aggregate.Aggregator.
+//
+// @param model - Model name reported in the response.
+// @param generate - true for an /api/generate response, false for /api/chat.
+func NewOllamaAggregator(model string, generate bool) *ChatToOllamaWriter {
+	return NewChatToOllamaWriter(nil, model, generate)
+}
+
+// SetModel sets the model name reported to the client, for aggregators
+// created before the request was parsed.
+func (o *ChatToOllamaWriter) SetModel(model string) {
+	o.model = model
+}
+
+// SetHideThinking leaves reasoning out of the response, for clients that
+// sent "think": false.
+func (o *ChatToOllamaWriter) SetHideThinking(hide bool) {
+	o.hideThinking = hide
+}
+
+// Write implements io.Writer. Complete SSE events are converted as they
+// arrive; a partial event is buffered until its terminating blank line.
+//
+// @return The first error writing to the underlying writer, if any.
+func (o *ChatToOllamaWriter) Write(p []byte) (int, error) {
+	o.buf.Write(p)
+	data := o.buf.Bytes()
+	for {
+		idx := bytes.Index(data, []byte("\n\n"))
+		if idx == -1 {
+			break
+		}
+		o.handleEvent(data[:idx])
+		data = data[idx+2:]
+	}
+	rest := append([]byte(nil), data...)
+	o.buf.Reset()
+	o.buf.Write(rest)
+
+	if o.w != nil && o.err != nil {
+		return len(p), o.err
+	}
+	return len(p), nil
+}
+
+// handleEvent converts the data of one SSE event block.
+func (o *ChatToOllamaWriter) handleEvent(block []byte) {
+	var lines []string
+	for _, line := range strings.Split(string(block), "\n") {
+		if strings.HasPrefix(line, "data:") {
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(lines) == 0 || o.done {
+		return
+	}
+	data := strings.Join(lines, "\n")
+	if data == "[DONE]" {
+		o.finish()
+		return
+	}
+
+	var envelope struct {
+		Error *types.ErrorDetail `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &envelope); err == nil && envelope.Error != nil {
+		o.Fail(envelope.Error.Message)
+		return
+	}
+
+	var chunk types.Chunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+	if chunk.Usage != nil {
+		o.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		delta := choice.Delta
+		reasoning := delta.ReasoningContent
+		if reasoning == "" {
+			reasoning = delta.Reasoning
+		}
+		if o.hideThinking {
+			reasoning = ""
+		}
+		if reasoning != "" || delta.Content != "" {
+			o.emit(delta.Content, reasoning)
+		}
+		for _, tc := range delta.ToolCalls {
+			o.addToolCall(tc)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			o.finishReason = *choice.FinishReason
+		}
+	}
+}
+
+// emit sends a content and reasoning delta, or adds it to the aggregate.
+func (o *ChatToOllamaWriter) emit(content, thinking string) {
+	if o.firstToken.IsZero() {
+		o.firstToken = time.Now()
+	}
+	if o.w == nil {
+		o.content.WriteString(content)
+		o.thinking.WriteString(thinking)
+		return
+	}
+	o.writeLine(o.response(content, thinking, nil, nil))
+}
+
+// addToolCall merges a streamed tool call delta into the call at its index.
+func (o *ChatToOllamaWriter) addToolCall(tc types.ToolCall) {
+	call, ok := o.toolCalls[tc.Index]
+	if !ok {
+		call = &types.ToolCall{Index: tc.Index}
+		o.toolCalls[tc.Index] = call
+	}
+	if tc.ID != "" {
+		call.ID = tc.ID
+	}
+	if tc.Function.Name != "" {
+		call.Function.Name = tc.Function.Name
+	}
+	call.Function.Arguments += tc.Function.Arguments
+}
+
+// ollamaToolCalls returns the assembled tool calls in Ollama's shape, with
+// the arguments as a JSON object.
+func (o *ChatToOllamaWriter) ollamaToolCalls() []types.OllamaToolCall {
+	indexes := make([]int, 0, len(o.toolCalls))
+	for i := range o.toolCalls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]types.OllamaToolCall, 0, len(indexes))
+	for i, idx := range indexes {
+		call := o.toolCalls[idx]
+		calls = append(calls, types.OllamaToolCall{Function: types.OllamaToolCallFunction{
+			Index:     i,
+			Name:      call.Function.Name,
+			Arguments: argumentsObject(call.Function.Arguments),
+		}})
+	}
+	return calls
+}
+
+// finish sends the assembled tool calls and the done line once.
+func (o *ChatToOllamaWriter) finish() {
+	if o.done {
+		return
+	}
+	o.done = true
+	if o.w == nil {
+		return
+	}
+	if calls := o.ollamaToolCalls(); len(calls) > 0 && !o.generate {
+		o.writeLine(o.response("", "", calls, nil))
+	}
+	stats := o.stats()
+	o.writeLine(o.response("", "", nil, &stats))
+}
+
+// stats returns the statistics of the done line.
+func (o *ChatToOllamaWriter) stats() types.OllamaStats {
+	now := time.Now()
+	stats := types.OllamaStats{
+		DoneReason:    "stop",
+		TotalDuration: now.Sub(o.start).Nanoseconds(),
+	}
+	if o.finishReason == "length" {
+		stats.DoneReason = "length"
+	}
+	if !o.firstToken.IsZero() {
+		stats.PromptEvalDuration = o.firstToken.Sub(o.start).Nanoseconds()
+		stats.EvalDuration = now.Sub(o.firstToken).Nanoseconds()
+	}
+	if o.usage != nil {
+		stats.PromptEvalCount = o.usage.PromptTokens
+		stats.EvalCount = o.usage.CompletionTokens
+	}
+	return stats
+}
+
+// response builds an /api/chat or /api/generate line; stats is set on the done line.
+func (o *ChatToOllamaWriter) response(content, thinking string, calls []types.OllamaToolCall, stats *types.OllamaStats) interface{} {
+	createdAt := time.Now().UTC().Format(time.RFC3339Nano)
+	if o.generate {
+		resp := types.OllamaGenerateResponse{Model: o.model, CreatedAt: createdAt, Response: content, Thinking: thinking}
+		if stats != nil {
+			resp.Done = true
+			resp.OllamaStats = *stats
+		}
+		return resp
+	}
+	resp := types.OllamaChatResponse{
+		Model:     o.model,
+		CreatedAt: createdAt,
+		Message:   types.OllamaMessage{Role: "assistant", Content: content, Thinking: thinking, ToolCalls: calls},
+	}
+	if stats != nil {
+		resp.Done = true
+		resp.OllamaStats = *stats
+	}
+	return resp
+}
+
+// writeLine writes v as an NDJSON line, keeping the first write error.
+func (o *ChatToOllamaWriter) writeLine(v interface{}) {
+	if o.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		o.err = err
+		return
+	}
+	if _, err := o.w.Write(append(data, '\n')); err != nil {
+		o.err = err
+	}
+}
+
+// Fail ends the response with an error: an {"error": msg} line when
+// streaming, an error from Result when aggregating.
+//
+// @param msg - Human-readable error message.
+func (o *ChatToOllamaWriter) Fail(msg string) {
+	if o.done {
+		return
+	}
+	o.done = true
+	if o.w == nil {
+		o.err = errors.New(msg)
+		return
+	}
+	o.writeLine(types.OllamaErrorResponse{Error: msg})
+}
+
+// Close sends the done line of a stream that ended without [DONE].
+func (o *ChatToOllamaWriter) Close() error {
+	o.finish()
+	return o.err
+}
+
+// Result returns the non-streaming Ollama response.
+// Implements aggregate.Aggregator.
+//
+// @return The response JSON, or the error the stream reported.
+func (o *ChatToOllamaWriter) Result() ([]byte, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	stats := o.stats()
+	if o.generate {
+		return json.Marshal(types.OllamaGenerateResponse{
+			Model:       o.model,
+			CreatedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+			Response:    o.content.String(),
+			Thinking:    o.thinking.String(),
+			Done:        true,
+			OllamaStats: stats,
+		})
+	}
+	return json.Marshal(types.OllamaChatResponse{
+		Model:     o.model,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Message: types.OllamaMessage{
+			Role:      "assistant",
+			Content:   o.content.String(),
+			Thinking:  o.thinking.String(),
+			ToolCalls: o.ollamaToolCalls(),
+		},
+		Done:        true,
+		OllamaStats: stats,
+	})
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Chat Completions → Ollama — Request
+// ─────────────────────────────────────────────────────────────────────────────
+
+// ChatToOllamaConverter converts OpenAI ChatCompletionRequest to an Ollama
+// /api/chat request. It implements the RequestConverter interface.
+type ChatToOllamaConverter struct{}
+
+// NewChatToOllamaConverter creates a new converter for Chat to Ollama format.
+func NewChatToOllamaConverter() *ChatToOllamaConverter {
+	return &ChatToOllamaConverter{}
+}
+
+// Convert transforms an OpenAI ChatCompletionRequest body to Ollama format.
+func (c *ChatToOllamaConverter) Convert(body []byte) ([]byte, error) {
+	return TransformChatToOllama(body)
+}
+
+// TransformChatToOllama converts a Chat Completions request body to a streaming
+// Ollama /api/chat request. Messages and Responses requests reach Ollama by
+// being converted to Chat Completions first.
+//
+// Field mappings:
+//   - system/developer, user and assistant messages → messages of the same role
+//     ("developer" becomes "system"); text parts are joined
+//   - image_url parts with data URIs → images (base64); remote URLs are dropped
+//   - assistant tool_calls → tool_calls with the arguments as a JSON object
+//   - tool messages → tool messages with the tool_name of the call they answer
+//   - tools → tools (same shape)
+//   - max_tokens → options.num_predict; temperature, top_p, top_k, stop, seed,
+//     penalties → options
+//   - response_format json_object → format "json"; json_schema → the schema
+//   - reasoning_effort → think: "low"/"medium"/"high", false for "none"/"minimal"
+//
+// Dropped: tool_choice, n, logprobs, logit_bias, user, parallel_tool_calls.
+func TransformChatToOllama(body []byte) ([]byte, error) {
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse ChatCompletionRequest: %w", err)
+	}
+	return json.Marshal(ChatToOllamaRequest(&req))
+}
+
+// ChatToOllamaRequest converts a Chat Completions request into a streaming
+// Ollama chat request.
+func ChatToOllamaRequest(req *types.ChatCompletionRequest) *types.OllamaChatRequest {
+	stream := true
+	out := &types.OllamaChatRequest{
+		Model:    req.Model,
+		Messages: []types.OllamaMessage{},
+		Tools:    req.Tools,
+		Stream:   &stream,
+		Options:  chatToOllamaOptions(req),
+	}
+
+	if req.System != "" {
+		out.Messages = append(out.Messages, types.OllamaMessage{Role: "system", Content: req.System})
+	}
+
+	// Tool messages only carry the call ID; Ollama needs the function name
+	callNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "tool":
+			out.Messages = append(out.Messages, types.OllamaMessage{
+				Role:     "tool",
+				Content:  ExtractTextFromContent(msg.Content),
+				ToolName: callNames[msg.ToolCallID],
+			})
+		case "assistant":
+			m := types.OllamaMessage{Role: "assistant", Content: ExtractTextFromContent(msg.Content)}
+			for i, tc := range msg.ToolCalls {
+				callNames[tc.ID] = tc.Function.Name
+				m.ToolCalls = append(m.ToolCalls, types.OllamaToolCall{Function: types.OllamaToolCallFunction{
+					Index:     i,
+					Name:      tc.Function.Name,
+					Arguments: argumentsObject(tc.Function.Arguments),
+				}})
+			}
+			out.Messages = append(out.Messages, m)
+		default:
+			role := msg.Role
+			if role == "developer" {
+				role = "system"
+			}
+			text, images := chatContentToOllama(msg.Content)
+			out.Messages = append(out.Messages, types.OllamaMessage{Role: role, Content: text, Images: images})
+		}
+	}
+
+	if rf := req.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case "json_object":
+			out.Format = json.RawMessage(`"json"`)
+		case "json_schema":
+			if rf.JSONSchema != nil && len(rf.JSONSchema.Schema) > 0 {
+				out.Format = rf.JSONSchema.Schema
+			} else {
+				out.Format = json.RawMessage(`"json"`)
+			}
+		}
+	}
+
+	switch req.ReasoningEffort {
+	case "":
+	case "none", "minimal":
+		out.Think = json.RawMessage(`false`)
+	case "low", "medium", "high":
+		out.Think = MustMarshal(req.ReasoningEffort)
+	default:
+		out.Think = json.RawMessage(`true`)
+	}
+	return out
+}
+
+// chatContentToOllama splits Chat message content into the text and the
+// base64 images of an Ollama message. Only data URI images can be sent.
+func chatContentToOllama(content interface{}) (string, []string) {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return ExtractTextFromContent(content), nil
+	}
+	var images []string
+	for _, item := range parts {
+		block, ok := item.(map[string]interface{})
+		if !ok || block["type"] != "image_url" {
+			continue
+		}
+		url := ""
+		switch u := block["image_url"].(type) {
+		case string:
+			url = u
+		case map[string]interface{}:
+			url, _ = u["url"].(string)
+		}
+		if _, data, err := ParseDataURI(url); err == nil {
+			images = append(images, data)
+		}
+	}
+	return ExtractTextFromContent(content), images
+}
+
+// chatToOllamaOptions collects the sampling parameters of a Chat request.
+// Returns nil if none is set.
+func chatToOllamaOptions(req *types.ChatCompletionRequest) *types.OllamaOptions {
+	opts := &types.OllamaOptions{
+		TopK:             req.TopK,
+		NumPredict:       req.MaxTokens,
+		Stop:             NewStopConverter().ConvertOpenAIToAnthropic(req.Stop),
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	if req.Temperature != 0 {
+		opts.Temperature = &req.Temperature
+	}
+	if req.TopP != 0 {
+		opts.TopP = &req.TopP
+	}
+	if b, _ := json.Marshal(opts); string(b) == "{}" {
+		return nil
+	}
+	return opts
+}
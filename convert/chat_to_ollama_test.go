@@ -0,0 +1,300 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ai-proxy/types"
+)
+
+// ollamaChatStream is Chat Completions SSE output with reasoning, text, a
+// truncated finish and usage.
+const ollamaChatStream = `data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Hmm"}}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hel"}}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"length"}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12}}
+
+data: [DONE]
+
+`
+
+// ollamaToolCallStream is Chat Completions SSE output with two streamed tool calls.
+const ollamaToolCallStream = `data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}},{"index":1,"id":"b","type":"function","function":{"name":"weather","arguments":""}}]}}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"cat\"}"}}]},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`
+
+// ollamaLines decodes the NDJSON lines written by a ChatToOllamaWriter.
+func ollamaLines(t *testing.T, out string) []types.OllamaChatResponse {
+	t.Helper()
+	var lines []types.OllamaChatResponse
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var resp types.OllamaChatResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", line, err)
+		}
+		lines = append(lines, resp)
+	}
+	return lines
+}
+
+func TestChatToOllamaWriter_Chat(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChatToOllamaWriter(&buf, "llama3", false)
+	// Writes split mid-event must be reassembled
+	for _, part := range []string{ollamaChatStream[:40], ollamaChatStream[40:]} {
+		if _, err := w.Write([]byte(part)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	lines := ollamaLines(t, buf.String())
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d\n%s", len(lines), buf.String())
+	}
+	if lines[0].Message.Thinking != "Hmm" || lines[1].Message.Content != "Hel" || lines[2].Message.Content != "lo" {
+		t.Errorf("unexpected delta lines:\n%s", buf.String())
+	}
+	for _, line := range lines[:3] {
+		if line.Done || line.Model != "llama3" || line.Message.Role != "assistant" {
+			t.Errorf("unexpected delta line: %+v", line)
+		}
+	}
+	done := lines[3]
+	if !done.Done || done.DoneReason != "length" || done.PromptEvalCount != 9 || done.EvalCount != 3 {
+		t.Errorf("unexpected done line: %+v", done)
+	}
+	if done.TotalDuration <= 0 {
+		t.Errorf("expected a total duration, got %d", done.TotalDuration)
+	}
+}
+
+func TestChatToOllamaWriter_ToolCalls(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChatToOllamaWriter(&buf, "llama3", false)
+	w.Write([]byte(ollamaToolCallStream))
+
+	lines := ollamaLines(t, buf.String())
+	if len(lines) != 2 {
+		t.Fatalf("expected tool call and done lines, got %d\n%s", len(lines), buf.String())
+	}
+	calls := lines[0].Message.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %+v", calls)
+	}
+	if calls[0].Function.Name != "lookup" || string(calls[0].Function.Arguments) != `{"q":"cat"}` {
+		t.Errorf("unexpected first call: %+v", calls[0].Function)
+	}
+	// Missing arguments become an empty object
+	if calls[1].Function.Index != 1 || string(calls[1].Function.Arguments) != `{}` {
+		t.Errorf("unexpected second call: %+v", calls[1].Function)
+	}
+	if !lines[1].Done || lines[1].DoneReason != "stop" {
+		t.Errorf("unexpected done line: %+v", lines[1])
+	}
+}
+
+func TestChatToOllamaWriter_Generate(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChatToOllamaWriter(&buf, "llama3", true)
+	w.SetHideThinking(true)
+	w.Write([]byte(ollamaChatStream))
+
+	var lines []types.OllamaGenerateResponse
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var resp types.OllamaGenerateResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", line, err)
+		}
+		lines = append(lines, resp)
+	}
+	// Reasoning is hidden, so only the two content deltas and the done line remain
+	if len(lines) != 3 || lines[0].Response != "Hel" || lines[1].Response != "lo" || !lines[2].Done {
+		t.Errorf("unexpected generate stream:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), `"message"`) || strings.Contains(buf.String(), "Hmm") {
+		t.Errorf("generate lines should carry neither message nor hidden thinking:\n%s", buf.String())
+	}
+}
+
+func TestChatToOllamaWriter_Error(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChatToOllamaWriter(&buf, "llama3", false)
+	w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+		"data: {\"error\":{\"message\":\"upstream failed\",\"type\":\"server_error\"}}\n\n"))
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[1] != `{"error":"upstream failed"}` {
+		t.Errorf("expected an error line and no done line, got:\n%s", buf.String())
+	}
+}
+
+func TestChatToOllamaWriter_CloseWithoutDone(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChatToOllamaWriter(&buf, "llama3", false)
+	w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n"))
+	w.Close()
+	w.Close()
+
+	lines := ollamaLines(t, buf.String())
+	if len(lines) != 2 || !lines[1].Done {
+		t.Errorf("expected a single done line on Close, got:\n%s", buf.String())
+	}
+}
+
+func TestOllamaAggregator(t *testing.T) {
+	agg := NewOllamaAggregator("", false)
+	agg.SetModel("llama3")
+	agg.Write([]byte(ollamaChatStream))
+	agg.Write([]byte(ollamaToolCallStream))
+
+	result, err := agg.Result()
+	if err != nil {
+		t.Fatalf("Result returned error: %v", err)
+	}
+	var resp types.OllamaChatResponse
+	if err := json.Unmarshal(result, &resp); err != nil {
+		t.Fatalf("invalid result: %v", err)
+	}
+	if resp.Model != "llama3" || resp.Message.Content != "Hello" || resp.Message.Thinking != "Hmm" || !resp.Done {
+		t.Errorf("unexpected response: %s", result)
+	}
+	if resp.DoneReason != "length" || resp.EvalCount != 3 {
+		t.Errorf("unexpected stats: %+v", resp.OllamaStats)
+	}
+
+	failed := NewOllamaAggregator("llama3", true)
+	failed.Write([]byte("data: {\"error\":{\"message\":\"boom\"}}\n\n"))
+	if _, err := failed.Result(); err == nil || err.Error() != "boom" {
+		t.Errorf("expected the stream error from Result, got %v", err)
+	}
+}
+
+func TestTransformChatToOllama(t *testing.T) {
+	body := `{
+		"model": "qwen3:32b",
+		"system": "Be brief.",
+		"messages": [
+			{"role": "developer", "content": "Answer in English."},
+			{"role": "user", "content": [
+				{"type": "text", "text": "What is this?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBORw0KGgo="}},
+				{"type": "image_url", "image_url": {"url": "https://example.com/cat.png"}}
+			]},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"cat\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "a cat"}
+		],
+		"tools": [{"type": "function", "function": {"name": "lookup", "parameters": {"type": "object"}}}],
+		"max_tokens": 256,
+		"temperature": 0.3,
+		"stop": "END",
+		"response_format": {"type": "json_object"},
+		"reasoning_effort": "high"
+	}`
+
+	out, err := TransformChatToOllama([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformChatToOllama returned error: %v", err)
+	}
+	var req types.OllamaChatRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+
+	if req.Model != "qwen3:32b" || req.Stream == nil || !*req.Stream {
+		t.Errorf("expected a streaming request for qwen3:32b, got model=%q stream=%v", req.Model, req.Stream)
+	}
+	if len(req.Messages) != 5 {
+		t.Fatalf("expected 5 messages, got %d: %+v", len(req.Messages), req.Messages)
+	}
+	if req.Messages[0].Role != "system" || req.Messages[1].Role != "system" {
+		t.Errorf("expected system and developer messages as system, got %q, %q", req.Messages[0].Role, req.Messages[1].Role)
+	}
+	user := req.Messages[2]
+	if user.Content != "What is this?" || len(user.Images) != 1 || user.Images[0] != "iVBORw0KGgo=" {
+		t.Errorf("unexpected user message: %+v", user)
+	}
+	calls := req.Messages[3].ToolCalls
+	if len(calls) != 1 || calls[0].Function.Name != "lookup" || string(calls[0].Function.Arguments) != `{"q":"cat"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+	if tool := req.Messages[4]; tool.Role != "tool" || tool.ToolName != "lookup" || tool.Content != "a cat" {
+		t.Errorf("unexpected tool message: %+v", tool)
+	}
+	if len(req.Tools) != 1 {
+		t.Errorf("expected tools to be forwarded, got %+v", req.Tools)
+	}
+
+	opts := req.Options
+	if opts == nil || opts.NumPredict != 256 || opts.Temperature == nil || *opts.Temperature != 0.3 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	if len(opts.Stop) != 1 || opts.Stop[0] != "END" {
+		t.Errorf("expected stop [END], got %v", opts.Stop)
+	}
+	if string(req.Format) != `"json"` {
+		t.Errorf(`expected format "json", got %s`, req.Format)
+	}
+	if string(req.Think) != `"high"` {
+		t.Errorf(`expected think "high", got %s`, req.Think)
+	}
+}
+
+func TestTransformChatToOllama_Think(t *testing.T) {
+	tests := []struct {
+		effort string
+		want   string
+	}{
+		{"", ""},
+		{"none", "false"},
+		{"minimal", "false"},
+		{"low", `"low"`},
+		{"xhigh", "true"},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]interface{}{"model": "m", "messages": []interface{}{}, "reasoning_effort": tt.effort})
+		out, err := TransformChatToOllama(body)
+		if err != nil {
+			t.Fatalf("TransformChatToOllama returned error: %v", err)
+		}
+		var req types.OllamaChatRequest
+		if err := json.Unmarshal(out, &req); err != nil {
+			t.Fatalf("invalid output: %v", err)
+		}
+		if string(req.Think) != tt.want {
+			t.Errorf("reasoning_effort %q: think = %s, want %s", tt.effort, req.Think, tt.want)
+		}
+		if req.Options != nil {
+			t.Errorf("expected no options, got %+v", req.Options)
+		}
+	}
+}
+
+func TestTransformChatToOllama_SchemaFormat(t *testing.T) {
+	body := `{"model":"m","messages":[],"response_format":{"type":"json_schema","json_schema":{"name":"r","schema":{"type":"object"}}}}`
+	out, err := TransformChatToOllama([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformChatToOllama returned error: %v", err)
+	}
+	var req types.OllamaChatRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+	if string(req.Format) != `{"type":"object"}` {
+		t.Errorf("expected the schema as format, got %s", req.Format)
+	}
+}
@@ -10,8 +10,10 @@ import (
 
 	"ai-proxy/capture"
 	"ai-proxy/conversation"
+	"ai-proxy/events"
 	"ai-proxy/logging"
 	"ai-proxy/summarizer"
+	"ai-proxy/transform"
 	"ai-proxy/transform/toolcall"
 	"ai-proxy/types"
 
@@ -321,6 +323,8 @@ type ChatToResponsesTransformer struct {
 
 	// userID stores the user ID for conversation storage
 	userID string
+	// orgID stores the organization ID for conversation storage
+	orgID string
 }
 
 type chatToRespToolCallState struct {
@@ -389,6 +393,7 @@ func (t *ChatToResponsesTransformer) SetGLM5ToolCallTransform(enabled bool) {
 
 // SetContext sets the request context for cache status tracking.
 // When a conversation is stored, the cache-created status is set in the capture context.
+// Extracted tool calls are published to the request's lifecycle events.
 func (t *ChatToResponsesTransformer) SetContext(ctx context.Context) {
 	t.ctx = ctx
 }
@@ -399,6 +404,11 @@ func (t *ChatToResponsesTransformer) SetUserID(userID string) {
 	t.userID = userID
 }
 
+// SetOrgID sets the organization ID for conversation storage.
+func (t *ChatToResponsesTransformer) SetOrgID(orgID string) {
+	t.orgID = orgID
+}
+
 // Initialize prepares the transformer and emits response.created before upstream request.
 // Per the spec, response.created must be emitted BEFORE the upstream call starts.
 func (t *ChatToResponsesTransformer) Initialize() error {
@@ -758,6 +768,7 @@ func (t *ChatToResponsesTransformer) writeToolCallParserEvent(e toolcall.Event)
 		}
 	case toolcall.EventToolStart:
 		// Start a new function_call output item
+		events.FromContext(t.ctx).ToolCallExtracted(e.Name)
 		t.extractedToolArgs.Reset()
 		return t.emitExtractedToolCallStart(e.ID, e.Name)
 	case toolcall.EventToolArgs:
@@ -1150,6 +1161,7 @@ func (t *ChatToResponsesTransformer) storeConversation(outputItems []map[string]
 		ID:                 t.responseID,
 		PreviousResponseID: t.previousResponseID,
 		UserID:             t.userID,
+		OrgID:              t.orgID,
 		Input:              t.inputItems,
 		Output:             outputs,
 		ReasoningItemID:    reasoningItemID,
@@ -1294,6 +1306,23 @@ func (t *ChatToResponsesTransformer) GetResponseID() string {
 	return t.responseID
 }
 
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *ChatToResponsesTransformer) State() string {
+	switch {
+	case t.completed:
+		return transform.StateDone
+	case t.currentToolCall != nil, t.toolCallTransform && !t.parser.IsIdle():
+		return transform.StateToolCall
+	case t.inReasoning:
+		return transform.StateReasoning
+	case t.messageStarted:
+		return transform.StateText
+	default:
+		return transform.StateWaiting
+	}
+}
+
 // EmitError sends a response.failed event for stream errors.
 // This notifies clients when the stream terminates unexpectedly.
 func (t *ChatToResponsesTransformer) EmitError(streamErr error) error {
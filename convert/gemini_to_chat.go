@@ -0,0 +1,301 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ai-proxy/events"
+	"ai-proxy/transform"
+	"ai-proxy/types"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Gemini → Chat Completions — Streaming Response
+// ─────────────────────────────────────────────────────────────────────────────
+
+// geminiFinishReasons maps Gemini finish reasons to Chat Completions finish
+// reasons. Unlisted reasons map to "stop".
+var geminiFinishReasons = map[string]string{
+	"STOP":               "stop",
+	"MAX_TOKENS":         "length",
+	"SAFETY":             "content_filter",
+	"RECITATION":         "content_filter",
+	"BLOCKLIST":          "content_filter",
+	"PROHIBITED_CONTENT": "content_filter",
+	"SPII":               "content_filter",
+	"IMAGE_SAFETY":       "content_filter",
+}
+
+// MapGeminiFinishReason converts a Gemini finish reason to a Chat Completions
+// finish reason. Returns "stop" for unknown reasons.
+func MapGeminiFinishReason(reason string) string {
+	if mapped, ok := geminiFinishReasons[reason]; ok {
+		return mapped
+	}
+	return "stop"
+}
+
+// GeminiToChatTransformer converts a Gemini streamGenerateContent?alt=sse
+// stream to Chat Completions chunks and passes them to the next transformer,
+// which writes them in the client's format. Chat clients get the chunks
+// through a passthrough or tool call transformer; Messages and Responses
+// clients through the transformers used for Chat Completions upstreams.
+//
+// Text parts become content deltas, thought parts reasoning_content deltas and
+// functionCall parts complete tool_calls deltas. The finish reason, the usage
+// from usageMetadata and [DONE] follow the last candidate chunk.
+type GeminiToChatTransformer struct {
+	next transform.SSETransformer
+
+	id      string
+	model   string
+	created int64
+
+	started   bool // the role chunk was sent
+	toolCalls int  // number of function calls emitted
+	usage     *types.GeminiUsageMetadata
+	done      bool // [DONE] was sent, or the stream was cancelled
+
+	ctx context.Context // request context, whose lifecycle events tool calls are published to
+}
+
+// NewGeminiToChatTransformer creates a transformer for Gemini to Chat
+// Completions stream conversion.
+//
+// @param next - Transformer receiving Chat Completions chunks. Must not be nil.
+func NewGeminiToChatTransformer(next transform.SSETransformer) *GeminiToChatTransformer {
+	return &GeminiToChatTransformer{
+		next:    next,
+		created: time.Now().Unix(),
+	}
+}
+
+// SetContext sets the request context on this transformer and on the next
+// transformer, if it accepts one.
+func (t *GeminiToChatTransformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+	if ct, ok := t.next.(interface{ SetContext(context.Context) }); ok {
+		ct.SetContext(ctx)
+	}
+}
+
+// Initialize delegates to the next transformer.
+func (t *GeminiToChatTransformer) Initialize() error {
+	return t.next.Initialize()
+}
+
+// geminiStreamChunk is a Gemini stream chunk, or an error sent in its place.
+type geminiStreamChunk struct {
+	types.GeminiResponse
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// Transform converts one Gemini chunk to Chat Completions chunks.
+// Errors and unparseable data are passed to the next transformer unchanged.
+func (t *GeminiToChatTransformer) Transform(event *sse.Event) error {
+	if event.Data == "" || t.done {
+		return nil
+	}
+
+	var chunk geminiStreamChunk
+	if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil || chunk.Error != nil {
+		return t.next.Transform(event)
+	}
+
+	if chunk.UsageMetadata != nil {
+		t.usage = chunk.UsageMetadata
+	}
+	if !t.started {
+		t.start(&chunk.GeminiResponse)
+		if err := t.emit(types.Delta{Role: "assistant"}, nil); err != nil {
+			return err
+		}
+	}
+
+	if len(chunk.Candidates) == 0 {
+		// A blocked prompt gets no candidates at all
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			return t.finish("content_filter")
+		}
+		return nil
+	}
+
+	candidate := chunk.Candidates[0]
+	if candidate.Content != nil {
+		for _, part := range candidate.Content.Parts {
+			if err := t.emitPart(part); err != nil {
+				return err
+			}
+		}
+	}
+	if candidate.FinishReason != "" {
+		reason := MapGeminiFinishReason(candidate.FinishReason)
+		if reason == "stop" && t.toolCalls > 0 {
+			reason = "tool_calls"
+		}
+		return t.finish(reason)
+	}
+	return nil
+}
+
+// start takes the chunk ID and model from the first chunk.
+func (t *GeminiToChatTransformer) start(resp *types.GeminiResponse) {
+	t.started = true
+	t.model = resp.ModelVersion
+	if resp.ResponseID != "" {
+		t.id = "chatcmpl-" + resp.ResponseID
+	} else {
+		t.id = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+}
+
+// emitPart emits the delta of one Gemini part.
+func (t *GeminiToChatTransformer) emitPart(part types.GeminiPart) error {
+	switch {
+	case part.FunctionCall != nil:
+		call := part.FunctionCall
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%s_%d", t.id[len("chatcmpl-"):], t.toolCalls)
+		}
+		args := string(call.Args)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		events.FromContext(t.ctx).ToolCallExtracted(call.Name)
+		delta := types.Delta{ToolCalls: []types.ToolCall{{
+			ID:       id,
+			Type:     "function",
+			Index:    t.toolCalls,
+			Function: types.Function{Name: call.Name, Arguments: args},
+		}}}
+		t.toolCalls++
+		return t.emit(delta, nil)
+	case part.Text == "":
+		return nil
+	case part.Thought:
+		return t.emit(types.Delta{ReasoningContent: part.Text}, nil)
+	default:
+		return t.emit(types.Delta{Content: part.Text}, nil)
+	}
+}
+
+// finish emits the finish reason, the usage and [DONE].
+func (t *GeminiToChatTransformer) finish(reason string) error {
+	if err := t.emit(types.Delta{}, &reason); err != nil {
+		return err
+	}
+	return t.end()
+}
+
+// end emits the usage, if reported, and [DONE] once.
+func (t *GeminiToChatTransformer) end() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	if u := t.usage; u != nil {
+		usage := &types.Usage{
+			PromptTokens:     u.PromptTokenCount,
+			CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+			TotalTokens:      u.TotalTokenCount,
+		}
+		if u.CachedContentTokenCount > 0 {
+			usage.PromptTokensDetails = &types.PromptTokensDetails{CachedTokens: u.CachedContentTokenCount}
+		}
+		if u.ThoughtsTokenCount > 0 {
+			usage.CompletionTokensDetails = &types.CompletionTokensDetails{ReasoningTokens: u.ThoughtsTokenCount}
+		}
+		if err := t.send(t.chunk(nil, usage)); err != nil {
+			return err
+		}
+	}
+	return t.next.Transform(&sse.Event{Data: "[DONE]"})
+}
+
+// emit passes a chunk with one choice to the next transformer.
+func (t *GeminiToChatTransformer) emit(delta types.Delta, finishReason *string) error {
+	return t.send(t.chunk([]types.Choice{{Delta: delta, FinishReason: finishReason}}, nil))
+}
+
+// chunk builds a Chat Completions chunk.
+func (t *GeminiToChatTransformer) chunk(choices []types.Choice, usage *types.Usage) types.Chunk {
+	if choices == nil {
+		choices = []types.Choice{}
+	}
+	return types.Chunk{
+		ID:      t.id,
+		Object:  "chat.completion.chunk",
+		Created: t.created,
+		Model:   t.model,
+		Choices: choices,
+		Usage:   usage,
+	}
+}
+
+// send passes a chunk to the next transformer as an SSE event.
+func (t *GeminiToChatTransformer) send(chunk types.Chunk) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return t.next.Transform(&sse.Event{Data: string(data)})
+}
+
+// Flush delegates to the next transformer.
+func (t *GeminiToChatTransformer) Flush() error {
+	return t.next.Flush()
+}
+
+// Close ends a stream that stopped without a finish reason, then closes the
+// next transformer.
+func (t *GeminiToChatTransformer) Close() error {
+	if t.started {
+		if err := t.end(); err != nil {
+			return err
+		}
+	}
+	return t.next.Close()
+}
+
+// HandleCancel ends the stream without [DONE] and delegates to the next transformer.
+func (t *GeminiToChatTransformer) HandleCancel() error {
+	t.done = true
+	return t.next.HandleCancel()
+}
+
+// GetResponseID returns the response ID of the next transformer, if it has one.
+// Implements transform.ResponseIDGetter interface.
+func (t *GeminiToChatTransformer) GetResponseID() string {
+	if getter, ok := t.next.(transform.ResponseIDGetter); ok {
+		return getter.GetResponseID()
+	}
+	return ""
+}
+
+// State reports the state of the next transformer, if it reports one.
+// Implements transform.StateReporter interface.
+func (t *GeminiToChatTransformer) State() string {
+	if reporter, ok := t.next.(transform.StateReporter); ok {
+		return reporter.State()
+	}
+	if t.done {
+		return transform.StateDone
+	}
+	if t.started {
+		return transform.StateText
+	}
+	return transform.StateWaiting
+}
+
+// EmitError passes a stream error to the next transformer, if it reports errors.
+func (t *GeminiToChatTransformer) EmitError(streamErr error) error {
+	if et, ok := t.next.(interface{ EmitError(error) error }); ok {
+		return et.EmitError(streamErr)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ai-proxy/events"
+	"ai-proxy/transform"
+	"ai-proxy/types"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// runGeminiToChat feeds raw Gemini chunks through a GeminiToChatTransformer
+// writing to a passthrough transformer, closes it and returns the data lines.
+func runGeminiToChat(t *testing.T, ctx context.Context, payloads ...string) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	transformer := NewGeminiToChatTransformer(transform.NewPassthroughTransformer(&buf))
+	if ctx != nil {
+		transformer.SetContext(ctx)
+	}
+	for _, p := range payloads {
+		if err := transformer.Transform(&sse.Event{Data: p}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+	if err := transformer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	var lines []string
+	for _, block := range strings.Split(strings.TrimSpace(buf.String()), "\n\n") {
+		lines = append(lines, strings.TrimPrefix(block, "data: "))
+	}
+	return lines
+}
+
+// decodeChunks decodes Chat chunks, stopping at [DONE].
+func decodeChunks(t *testing.T, lines []string) []types.Chunk {
+	t.Helper()
+	var chunks []types.Chunk
+	for _, line := range lines {
+		if line == "[DONE]" {
+			break
+		}
+		var chunk types.Chunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", line, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestGeminiToChat_TextAndThoughts(t *testing.T) {
+	lines := runGeminiToChat(t, nil,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Thinking...","thought":true}]},"index":0}],"modelVersion":"gemini-2.5-pro","responseId":"abc"}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"index":0}],"modelVersion":"gemini-2.5-pro","responseId":"abc"}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":" world"}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":3,"cachedContentTokenCount":4,"totalTokenCount":18},"modelVersion":"gemini-2.5-pro","responseId":"abc"}`,
+	)
+
+	if lines[len(lines)-1] != "[DONE]" {
+		t.Fatalf("stream should end with [DONE], got %q", lines[len(lines)-1])
+	}
+	chunks := decodeChunks(t, lines)
+	if len(chunks) != 6 {
+		t.Fatalf("expected 6 chunks, got %d: %v", len(chunks), lines)
+	}
+	for _, c := range chunks {
+		if c.ID != "chatcmpl-abc" || c.Model != "gemini-2.5-pro" || c.Object != "chat.completion.chunk" {
+			t.Errorf("unexpected chunk header: %+v", c)
+		}
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Errorf("first chunk should carry the role, got %+v", chunks[0].Choices[0].Delta)
+	}
+	if got := chunks[1].Choices[0].Delta.ReasoningContent; got != "Thinking..." {
+		t.Errorf("thought part should become reasoning_content, got %q", got)
+	}
+	if chunks[2].Choices[0].Delta.Content != "Hello" || chunks[3].Choices[0].Delta.Content != " world" {
+		t.Errorf("unexpected content deltas: %+v %+v", chunks[2].Choices[0].Delta, chunks[3].Choices[0].Delta)
+	}
+	if fr := chunks[4].Choices[0].FinishReason; fr == nil || *fr != "stop" {
+		t.Errorf("expected finish_reason stop, got %v", fr)
+	}
+
+	usage := chunks[5].Usage
+	if len(chunks[5].Choices) != 0 || usage == nil {
+		t.Fatalf("last chunk should carry usage only: %+v", chunks[5])
+	}
+	if usage.PromptTokens != 10 || usage.CompletionTokens != 8 || usage.TotalTokens != 18 ||
+		usage.PromptTokensDetails == nil || usage.PromptTokensDetails.CachedTokens != 4 ||
+		usage.CompletionTokensDetails == nil || usage.CompletionTokensDetails.ReasoningTokens != 3 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestGeminiToChat_FunctionCalls(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(events.Filter{}, 8)
+	defer sub.Close()
+	ctx := events.WithRequest(context.Background(), events.NewRequest(bus, events.Event{RequestID: "req_1"}))
+
+	lines := runGeminiToChat(t, ctx,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"cat"}},"thoughtSignature":"sig"},{"functionCall":{"id":"fc_2","name":"weather"}}]},"finishReason":"STOP","index":0}],"responseId":"r1"}`,
+	)
+
+	chunks := decodeChunks(t, lines)
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d: %v", len(chunks), lines)
+	}
+	first := chunks[1].Choices[0].Delta.ToolCalls
+	if len(first) != 1 || first[0].ID != "call_r1_0" || first[0].Index != 0 ||
+		first[0].Function.Name != "lookup" || first[0].Function.Arguments != `{"q":"cat"}` {
+		t.Errorf("unexpected first tool call: %+v", first)
+	}
+	second := chunks[2].Choices[0].Delta.ToolCalls
+	if len(second) != 1 || second[0].ID != "fc_2" || second[0].Index != 1 || second[0].Function.Arguments != "{}" {
+		t.Errorf("unexpected second tool call: %+v", second)
+	}
+	if fr := chunks[3].Choices[0].FinishReason; fr == nil || *fr != "tool_calls" {
+		t.Errorf("expected finish_reason tool_calls, got %v", fr)
+	}
+
+	for _, want := range []string{"lookup", "weather"} {
+		select {
+		case e := <-sub.Events():
+			if e.Type != events.ToolCallExtracted || e.Tool != want || e.RequestID != "req_1" {
+				t.Errorf("unexpected event: %+v", e)
+			}
+		default:
+			t.Fatalf("no tool_call.extracted event for %s", want)
+		}
+	}
+}
+
+func TestGeminiToChat_BlockedPrompt(t *testing.T) {
+	lines := runGeminiToChat(t, nil,
+		`{"promptFeedback":{"blockReason":"SAFETY"},"usageMetadata":{"promptTokenCount":7,"totalTokenCount":7}}`,
+	)
+
+	chunks := decodeChunks(t, lines)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %v", len(chunks), lines)
+	}
+	if fr := chunks[1].Choices[0].FinishReason; fr == nil || *fr != "content_filter" {
+		t.Errorf("expected finish_reason content_filter, got %v", fr)
+	}
+	if chunks[2].Usage == nil || chunks[2].Usage.PromptTokens != 7 {
+		t.Errorf("unexpected usage: %+v", chunks[2].Usage)
+	}
+}
+
+func TestGeminiToChat_ErrorPassthrough(t *testing.T) {
+	errData := `{"error":{"code":429,"message":"Resource exhausted","status":"RESOURCE_EXHAUSTED"}}`
+	lines := runGeminiToChat(t, nil, errData)
+
+	if len(lines) != 1 || lines[0] != errData {
+		t.Errorf("error should be passed through unchanged, got %v", lines)
+	}
+}
+
+func TestGeminiToChat_CloseWithoutFinish(t *testing.T) {
+	lines := runGeminiToChat(t, nil,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"partial"}]},"index":0}]}`,
+	)
+
+	if lines[len(lines)-1] != "[DONE]" {
+		t.Errorf("Close should end the stream with [DONE], got %v", lines)
+	}
+	if chunks := decodeChunks(t, lines); len(chunks) != 2 || chunks[1].Choices[0].Delta.Content != "partial" {
+		t.Errorf("unexpected chunks: %v", lines)
+	}
+}
+
+func TestGeminiToChat_HandleCancel(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewGeminiToChatTransformer(transform.NewPassthroughTransformer(&buf))
+	transformer.Transform(&sse.Event{Data: `{"candidates":[{"content":{"parts":[{"text":"hi"}]},"index":0}]}`})
+	if err := transformer.HandleCancel(); err != nil {
+		t.Fatalf("HandleCancel returned error: %v", err)
+	}
+	transformer.Transform(&sse.Event{Data: `{"candidates":[{"content":{"parts":[{"text":"late"}]},"index":0}]}`})
+	transformer.Close()
+
+	if out := buf.String(); strings.Contains(out, "late") || strings.Contains(out, "[DONE]") {
+		t.Errorf("nothing should follow a cancel, got %q", out)
+	}
+}
+
+func TestGeminiToChat_ToAnthropic(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewGeminiToChatTransformer(NewChatToAnthropicTransformer(&buf))
+
+	for _, p := range []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Let me look."}]},"index":0}],"responseId":"r2"}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"cat"}}}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15},"responseId":"r2"}`,
+	} {
+		if err := transformer.Transform(&sse.Event{Data: p}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+	if err := transformer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"event: message_start",
+		`"text":"Let me look."`,
+		`"type":"tool_use"`,
+		`"name":"lookup"`,
+		`"stop_reason":"tool_use"`,
+		`"output_tokens":5`,
+		"event: message_stop",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Anthropic output missing %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestMapGeminiFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"STOP":       "stop",
+		"MAX_TOKENS": "length",
+		"SAFETY":     "content_filter",
+		"RECITATION": "content_filter",
+		"OTHER":      "stop",
+		"":           "stop",
+	}
+	for reason, want := range tests {
+		if got := MapGeminiFinishReason(reason); got != want {
+			t.Errorf("MapGeminiFinishReason(%q) = %q, want %q", reason, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package convert provides converters between different API formats.
+// This file implements previous_response_id history expansion with a token budget.
+package convert
+
+import (
+	"sync"
+
+	"ai-proxy/conversation"
+	"ai-proxy/logging"
+	"ai-proxy/tokens"
+)
+
+var (
+	historyCounterOnce sync.Once
+	historyCounter     conversation.TokenCounter
+)
+
+// historyTokenCounter returns the shared tiktoken counter used for history budgets.
+// The encoder is loaded once. If it is unavailable (e.g. the BPE ranks cannot be
+// downloaded), nil is returned and budgets fall back to a character estimate.
+func historyTokenCounter() conversation.TokenCounter {
+	historyCounterOnce.Do(func() {
+		counter, err := tokens.CountTokensForModel("")
+		if err != nil {
+			logging.InfoMsg("Warning: tokenizer unavailable, estimating history tokens: %v", err)
+			return
+		}
+		historyCounter = counter
+	})
+	return historyCounter
+}
+
+// expandHistory prepends a chronological conversation chain to the current input.
+// When maxContextTokens is positive, the oldest turns are dropped until the history
+// and the current input fit the budget. The current input is never trimmed.
+//
+// Returns the turns that were kept and the combined input.
+func expandHistory(chain []*conversation.Conversation, input interface{}, maxContextTokens int) ([]*conversation.Conversation, interface{}) {
+	var counter conversation.TokenCounter
+	if maxContextTokens > 0 {
+		counter = historyTokenCounter()
+	}
+	return expandHistoryWithCounter(chain, input, maxContextTokens, counter)
+}
+
+// expandHistoryWithCounter is expandHistory with an explicit token counter.
+func expandHistoryWithCounter(chain []*conversation.Conversation, input interface{}, maxContextTokens int, counter conversation.TokenCounter) ([]*conversation.Conversation, interface{}) {
+	kept := conversation.TrimChain(chain, conversation.WalkChainOptions{
+		MaxTokens:      maxContextTokens,
+		Counter:        counter,
+		ReservedTokens: conversation.CountValueTokens(input, counter),
+		KeepLatest:     hasFunctionCallOutput(input),
+	})
+	if dropped := len(chain) - len(kept); dropped > 0 {
+		logging.InfoMsg("Dropped %d of %d conversation turns to fit max_context_tokens=%d", dropped, len(chain), maxContextTokens)
+	}
+
+	// Prepend newest first so the oldest turn ends up at the front
+	for i := len(kept) - 1; i >= 0; i-- {
+		input = prependHistoryToInput(kept[i], input)
+	}
+	return kept, input
+}
+
+// hasFunctionCallOutput reports whether a Responses input carries tool results.
+func hasFunctionCallOutput(input interface{}) bool {
+	items, ok := input.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok && m["type"] == "function_call_output" {
+			return true
+		}
+	}
+	return false
+}
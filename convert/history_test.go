@@ -0,0 +1,162 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+
+	"ai-proxy/conversation"
+	"ai-proxy/types"
+)
+
+// wordCounter counts one token per whitespace-separated word.
+type wordCounter struct{}
+
+func (wordCounter) CountText(text string) int {
+	return len(strings.Fields(text))
+}
+
+func historyTestChain() []*conversation.Conversation {
+	return []*conversation.Conversation{
+		{
+			ID:    "resp_1",
+			Input: []types.InputItem{{Type: "message", Role: "user", Content: "first question"}},
+			Output: []types.OutputItem{{
+				Type:    "message",
+				Role:    "assistant",
+				Content: []types.OutputContent{{Type: "output_text", Text: "first answer"}},
+			}},
+		},
+		{
+			ID:                 "resp_2",
+			PreviousResponseID: "resp_1",
+			Input:              []types.InputItem{{Type: "message", Role: "user", Content: "second question"}},
+			Output:             []types.OutputItem{{Type: "function_call", CallID: "call_1", Name: "lookup", Arguments: `{"q":"x"}`}},
+		},
+	}
+}
+
+// inputTexts returns the content or output of each item in an expanded input.
+func inputTexts(t *testing.T, input interface{}) []string {
+	t.Helper()
+	items, ok := input.([]interface{})
+	if !ok {
+		t.Fatalf("expected []interface{} input, got %T", input)
+	}
+	var texts []string
+	for _, item := range items {
+		m := item.(map[string]interface{})
+		switch {
+		case m["type"] == "function_call":
+			texts = append(texts, "call:"+m["name"].(string))
+		case m["output"] != nil:
+			texts = append(texts, "output:"+m["output"].(string))
+		default:
+			switch content := m["content"].(type) {
+			case string:
+				texts = append(texts, content)
+			case []interface{}:
+				texts = append(texts, content[0].(map[string]interface{})["text"].(string))
+			}
+		}
+	}
+	return texts
+}
+
+func TestExpandHistory_ChronologicalOrder(t *testing.T) {
+	kept, input := expandHistoryWithCounter(historyTestChain(), "third question", 0, nil)
+
+	if len(kept) != 2 {
+		t.Fatalf("expected 2 kept turns, got %d", len(kept))
+	}
+	want := []string{"first question", "first answer", "second question", "call:lookup", "third question"}
+	got := inputTexts(t, input)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expanded input = %v, want %v", got, want)
+	}
+}
+
+func TestExpandHistory_TrimsOldestTurns(t *testing.T) {
+	// resp_2 costs 14 tokens, the current input 4 + 2
+	kept, input := expandHistoryWithCounter(historyTestChain(), "third question", 20, wordCounter{})
+
+	if len(kept) != 1 || kept[0].ID != "resp_2" {
+		t.Fatalf("expected only resp_2 kept, got %d turns", len(kept))
+	}
+	want := []string{"second question", "call:lookup", "third question"}
+	got := inputTexts(t, input)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expanded input = %v, want %v", got, want)
+	}
+}
+
+func TestExpandHistory_KeepsCallAnsweredByCurrentInput(t *testing.T) {
+	current := []interface{}{
+		map[string]interface{}{"type": "function_call_output", "call_id": "call_1", "output": "result"},
+	}
+
+	// The budget cannot fit resp_2, but the current input answers its call
+	kept, input := expandHistoryWithCounter(historyTestChain(), current, 5, wordCounter{})
+
+	if len(kept) != 1 || kept[0].ID != "resp_2" {
+		t.Fatalf("expected resp_2 kept for its function_call, got %d turns", len(kept))
+	}
+	want := []string{"second question", "call:lookup", "output:result"}
+	got := inputTexts(t, input)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expanded input = %v, want %v", got, want)
+	}
+}
+
+func TestResponsesToChatConverter_MaxContextTokens(t *testing.T) {
+	oldStore := conversation.DefaultStore
+	conversation.DefaultStore = conversation.NewStore(conversation.Config{})
+	t.Cleanup(func() {
+		conversation.DefaultStore = oldStore
+	})
+	for _, conv := range historyTestChain() {
+		conversation.StoreInDefault(conv)
+	}
+
+	converter := NewResponsesToChatConverter()
+	converter.SetMaxContextTokens(1)
+	output, err := converter.Convert([]byte(`{"model":"gpt-4o","input":"third question","previous_response_id":"resp_2"}`))
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+
+	if strings.Contains(string(output), "first question") || strings.Contains(string(output), "second question") {
+		t.Errorf("expected history to be trimmed, got: %s", output)
+	}
+	if !strings.Contains(string(output), "third question") {
+		t.Errorf("expected current input to be kept, got: %s", output)
+	}
+}
+
+func TestTransformResponsesToAnthropicWithOptions_MaxContextTokens(t *testing.T) {
+	oldStore := conversation.DefaultStore
+	conversation.DefaultStore = conversation.NewStore(conversation.Config{})
+	t.Cleanup(func() {
+		conversation.DefaultStore = oldStore
+	})
+	for _, conv := range historyTestChain() {
+		conversation.StoreInDefault(conv)
+	}
+
+	body := []byte(`{"model":"claude","input":"third question","previous_response_id":"resp_2"}`)
+
+	output, err := TransformResponsesToAnthropicWithOptions(body, nil, true, 0)
+	if err != nil {
+		t.Fatalf("TransformResponsesToAnthropicWithOptions returned error: %v", err)
+	}
+	if !strings.Contains(string(output), "first question") {
+		t.Errorf("expected full history without a budget, got: %s", output)
+	}
+
+	output, err = TransformResponsesToAnthropicWithOptions(body, nil, true, 1)
+	if err != nil {
+		t.Fatalf("TransformResponsesToAnthropicWithOptions returned error: %v", err)
+	}
+	if strings.Contains(string(output), "first question") || strings.Contains(string(output), "second question") {
+		t.Errorf("expected history to be trimmed, got: %s", output)
+	}
+}
@@ -0,0 +1,477 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-proxy/events"
+	"ai-proxy/transform"
+	"ai-proxy/types"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Ollama → Chat Completions — Request
+// ─────────────────────────────────────────────────────────────────────────────
+
+// TransformOllamaChatToChat converts an Ollama /api/chat request body to a
+// streaming Chat Completions request, which the /v1/chat/completions pipeline
+// then routes to any upstream protocol.
+//
+// Field mappings:
+//   - messages → messages; images → image_url parts with base64 data URIs
+//   - assistant tool_calls → tool_calls with generated IDs, arguments as a JSON string
+//   - tool messages → tool messages answering the oldest open call of tool_name
+//   - tools → tools (same shape)
+//   - format "json" → response_format json_object; a schema → json_schema
+//   - options.num_predict, temperature, top_p, top_k, stop, seed, penalties → same-named fields
+//   - think "low"/"medium"/"high" → reasoning_effort
+//
+// Dropped: keep_alive, runner options such as num_ctx, and assistant thinking.
+func TransformOllamaChatToChat(body []byte) ([]byte, error) {
+	var req types.OllamaChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse Ollama chat request: %w", err)
+	}
+	return json.Marshal(OllamaChatToChatRequest(&req))
+}
+
+// OllamaChatToChatRequest converts an Ollama chat request into a streaming
+// Chat Completions request that asks for usage.
+func OllamaChatToChatRequest(req *types.OllamaChatRequest) *types.ChatCompletionRequest {
+	out := newOllamaChatRequest(req.Model, req.Options, req.Format, req.Think)
+	out.Tools = req.Tools
+
+	// Ollama tool calls and results carry no IDs; results are matched to the
+	// oldest open call of the same function
+	var open []types.ToolCall
+	calls := 0
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "assistant":
+			m := types.Message{Role: "assistant", Content: msg.Content}
+			for i, tc := range msg.ToolCalls {
+				call := types.ToolCall{
+					ID:       fmt.Sprintf("call_%d", calls),
+					Type:     "function",
+					Index:    i,
+					Function: types.Function{Name: tc.Function.Name, Arguments: ollamaArguments(tc.Function.Arguments)},
+				}
+				calls++
+				m.ToolCalls = append(m.ToolCalls, call)
+				open = append(open, call)
+			}
+			out.Messages = append(out.Messages, m)
+		case "tool":
+			id := ""
+			for i, call := range open {
+				if msg.ToolName == "" || call.Function.Name == msg.ToolName {
+					id = call.ID
+					open = append(open[:i], open[i+1:]...)
+					break
+				}
+			}
+			if id == "" {
+				id = fmt.Sprintf("call_%d", calls)
+				calls++
+			}
+			out.Messages = append(out.Messages, types.Message{Role: "tool", Content: msg.Content, ToolCallID: id})
+		default:
+			out.Messages = append(out.Messages, types.Message{
+				Role:    msg.Role,
+				Content: ollamaContent(msg.Content, msg.Images),
+			})
+		}
+	}
+	return out
+}
+
+// TransformOllamaGenerateToChat converts an Ollama /api/generate request body
+// to a streaming Chat Completions request: system becomes a system message and
+// prompt and images a user message. Options map as for /api/chat.
+//
+// Dropped: suffix, template, context, raw and keep_alive.
+func TransformOllamaGenerateToChat(body []byte) ([]byte, error) {
+	var req types.OllamaGenerateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse Ollama generate request: %w", err)
+	}
+
+	out := newOllamaChatRequest(req.Model, req.Options, req.Format, req.Think)
+	if req.System != "" {
+		out.Messages = append(out.Messages, types.Message{Role: "system", Content: req.System})
+	}
+	out.Messages = append(out.Messages, types.Message{Role: "user", Content: ollamaContent(req.Prompt, req.Images)})
+	return json.Marshal(out)
+}
+
+// newOllamaChatRequest creates a Chat Completions request with the parameters
+// shared by /api/chat and /api/generate.
+func newOllamaChatRequest(model string, opts *types.OllamaOptions, format, think json.RawMessage) *types.ChatCompletionRequest {
+	out := &types.ChatCompletionRequest{
+		Model:         model,
+		Messages:      []types.Message{},
+		Stream:        true,
+		StreamOptions: &types.StreamOptions{IncludeUsage: true},
+	}
+
+	if opts != nil {
+		if opts.NumPredict > 0 {
+			out.MaxTokens = opts.NumPredict
+		}
+		if opts.Temperature != nil {
+			out.Temperature = *opts.Temperature
+		}
+		if opts.TopP != nil {
+			out.TopP = *opts.TopP
+		}
+		out.TopK = opts.TopK
+		if len(opts.Stop) > 0 {
+			out.Stop = opts.Stop
+		}
+		out.Seed = opts.Seed
+		out.PresencePenalty = opts.PresencePenalty
+		out.FrequencyPenalty = opts.FrequencyPenalty
+	}
+
+	var formatName string
+	switch {
+	case len(format) == 0 || string(format) == "null" || string(format) == `""`:
+	case json.Unmarshal(format, &formatName) == nil:
+		if formatName == "json" {
+			out.ResponseFormat = &types.ResponseFormat{Type: "json_object"}
+		}
+	default:
+		out.ResponseFormat = &types.ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &types.JSONSchemaConfig{Name: "response", Schema: format},
+		}
+	}
+
+	var level string
+	if json.Unmarshal(think, &level) == nil {
+		switch level {
+		case "low", "medium", "high":
+			out.ReasoningEffort = level
+		}
+	}
+	return out
+}
+
+// OllamaHidesThinking reports whether an Ollama request turned thinking off
+// with "think": false, in which case reasoning is left out of the response.
+func OllamaHidesThinking(think json.RawMessage) bool {
+	var enabled bool
+	return json.Unmarshal(think, &enabled) == nil && !enabled
+}
+
+// ollamaContent converts Ollama message text and base64 images to Chat
+// message content: the text alone, or text and image_url parts.
+func ollamaContent(text string, images []string) interface{} {
+	if len(images) == 0 {
+		return text
+	}
+	parts := make([]interface{}, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+	}
+	for _, img := range images {
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": ollamaImageURL(img)},
+		})
+	}
+	return parts
+}
+
+// ollamaImageURL converts a base64 Ollama image to a data URI, sniffing the
+// media type from the decoded bytes.
+func ollamaImageURL(img string) string {
+	if strings.HasPrefix(img, "data:") {
+		return img
+	}
+	mediaType := "image/png"
+	if decoded, err := base64.StdEncoding.DecodeString(img); err == nil {
+		if sniffed := http.DetectContentType(decoded); strings.HasPrefix(sniffed, "image/") {
+			mediaType = sniffed
+		}
+	}
+	return "data:" + mediaType + ";base64," + img
+}
+
+// ollamaArguments converts Ollama tool call arguments, a JSON object, to the
+// compact JSON string of a Chat tool call. A JSON string is unwrapped; a
+// missing value becomes an empty object.
+func ollamaArguments(args json.RawMessage) string {
+	if len(args) == 0 || string(args) == "null" {
+		return "{}"
+	}
+	var s string
+	if err := json.Unmarshal(args, &s); err == nil {
+		return s
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, args); err == nil {
+		return compact.String()
+	}
+	return string(args)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Ollama → Chat Completions — Streaming Response
+// ─────────────────────────────────────────────────────────────────────────────
+
+// OllamaToChatTransformer converts an Ollama /api/chat stream to Chat
+// Completions chunks and passes them to the next transformer, which writes
+// them in the client's format. The NDJSON lines of the upstream must have
+// been framed as SSE events, one per line, by proxy.NDJSONEventReader.
+//
+// message.content becomes content deltas, message.thinking reasoning_content
+// deltas and message.tool_calls complete tool_calls deltas. The done line
+// becomes the finish reason, the usage from prompt_eval_count and eval_count,
+// and [DONE]. Lines of /api/generate streams (response and thinking) are
+// accepted as well.
+type OllamaToChatTransformer struct {
+	next transform.SSETransformer
+
+	id      string
+	model   string
+	created int64
+
+	started   bool // the role chunk was sent
+	toolCalls int  // number of tool calls emitted
+	done      bool // [DONE] was sent, or the stream was cancelled
+
+	ctx context.Context // request context, whose lifecycle events tool calls are published to
+}
+
+// NewOllamaToChatTransformer creates a transformer for Ollama to Chat
+// Completions stream conversion.
+//
+// @param next - Transformer receiving Chat Completions chunks. Must not be nil.
+func NewOllamaToChatTransformer(next transform.SSETransformer) *OllamaToChatTransformer {
+	now := time.Now()
+	return &OllamaToChatTransformer{
+		next:    next,
+		id:      fmt.Sprintf("chatcmpl-%d", now.UnixNano()),
+		created: now.Unix(),
+	}
+}
+
+// SetContext sets the request context on this transformer and on the next
+// transformer, if it accepts one.
+func (t *OllamaToChatTransformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+	if ct, ok := t.next.(interface{ SetContext(context.Context) }); ok {
+		ct.SetContext(ctx)
+	}
+}
+
+// Initialize delegates to the next transformer.
+func (t *OllamaToChatTransformer) Initialize() error {
+	return t.next.Initialize()
+}
+
+// ollamaStreamLine is one line of an Ollama /api/chat or /api/generate
+// stream, or an error sent in its place.
+type ollamaStreamLine struct {
+	types.OllamaChatResponse
+	// Response and Thinking carry the text of /api/generate lines.
+	Response string `json:"response"`
+	Thinking string `json:"thinking"`
+	// Error is set instead of everything else when the stream fails.
+	Error string `json:"error"`
+}
+
+// Transform converts one Ollama line to Chat Completions chunks.
+// An error line ends the stream with an error; unparseable data is passed to
+// the next transformer unchanged.
+func (t *OllamaToChatTransformer) Transform(event *sse.Event) error {
+	if event.Data == "" || t.done {
+		return nil
+	}
+
+	var line ollamaStreamLine
+	if err := json.Unmarshal([]byte(event.Data), &line); err != nil {
+		return t.next.Transform(event)
+	}
+	if line.Error != "" {
+		return t.fail(line.Error)
+	}
+
+	if !t.started {
+		t.started = true
+		t.model = line.Model
+		if err := t.emit(types.Delta{Role: "assistant"}, nil); err != nil {
+			return err
+		}
+	}
+
+	thinking := line.Message.Thinking + line.Thinking
+	if thinking != "" {
+		if err := t.emit(types.Delta{ReasoningContent: thinking}, nil); err != nil {
+			return err
+		}
+	}
+	if content := line.Message.Content + line.Response; content != "" {
+		if err := t.emit(types.Delta{Content: content}, nil); err != nil {
+			return err
+		}
+	}
+	for _, call := range line.Message.ToolCalls {
+		if err := t.emitToolCall(call); err != nil {
+			return err
+		}
+	}
+
+	if !line.Done {
+		return nil
+	}
+	reason := "stop"
+	switch {
+	case line.DoneReason == "length":
+		reason = "length"
+	case t.toolCalls > 0:
+		reason = "tool_calls"
+	}
+	if err := t.emit(types.Delta{}, &reason); err != nil {
+		return err
+	}
+	if line.PromptEvalCount > 0 || line.EvalCount > 0 {
+		usage := &types.Usage{
+			PromptTokens:     line.PromptEvalCount,
+			CompletionTokens: line.EvalCount,
+			TotalTokens:      line.PromptEvalCount + line.EvalCount,
+		}
+		if err := t.send(t.chunk(nil, usage)); err != nil {
+			return err
+		}
+	}
+	return t.end()
+}
+
+// emitToolCall emits one complete Ollama tool call as a tool_calls delta.
+// Ollama tool calls have no IDs, so one is generated.
+func (t *OllamaToChatTransformer) emitToolCall(call types.OllamaToolCall) error {
+	events.FromContext(t.ctx).ToolCallExtracted(call.Function.Name)
+	delta := types.Delta{ToolCalls: []types.ToolCall{{
+		ID:       fmt.Sprintf("call_%s_%d", t.id[len("chatcmpl-"):], t.toolCalls),
+		Type:     "function",
+		Index:    t.toolCalls,
+		Function: types.Function{Name: call.Function.Name, Arguments: ollamaArguments(call.Function.Arguments)},
+	}}}
+	t.toolCalls++
+	return t.emit(delta, nil)
+}
+
+// fail ends the stream with an upstream error: the next transformer reports
+// it in the client's format if it can, otherwise it receives an OpenAI error
+// object in place of a chunk.
+func (t *OllamaToChatTransformer) fail(msg string) error {
+	t.done = true
+	if et, ok := t.next.(interface{ EmitError(error) error }); ok {
+		return et.EmitError(errors.New(msg))
+	}
+	data := MustMarshal(types.ErrorResponse{Error: types.ErrorDetail{Type: "upstream_error", Message: msg}})
+	return t.next.Transform(&sse.Event{Data: string(data)})
+}
+
+// end emits [DONE] once.
+func (t *OllamaToChatTransformer) end() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	return t.next.Transform(&sse.Event{Data: "[DONE]"})
+}
+
+// emit passes a chunk with one choice to the next transformer.
+func (t *OllamaToChatTransformer) emit(delta types.Delta, finishReason *string) error {
+	return t.send(t.chunk([]types.Choice{{Delta: delta, FinishReason: finishReason}}, nil))
+}
+
+// chunk builds a Chat Completions chunk.
+func (t *OllamaToChatTransformer) chunk(choices []types.Choice, usage *types.Usage) types.Chunk {
+	if choices == nil {
+		choices = []types.Choice{}
+	}
+	return types.Chunk{
+		ID:      t.id,
+		Object:  "chat.completion.chunk",
+		Created: t.created,
+		Model:   t.model,
+		Choices: choices,
+		Usage:   usage,
+	}
+}
+
+// send passes a chunk to the next transformer as an SSE event.
+func (t *OllamaToChatTransformer) send(chunk types.Chunk) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return t.next.Transform(&sse.Event{Data: string(data)})
+}
+
+// Flush delegates to the next transformer.
+func (t *OllamaToChatTransformer) Flush() error {
+	return t.next.Flush()
+}
+
+// Close ends a stream that stopped without a done line, then closes the next
+// transformer.
+func (t *OllamaToChatTransformer) Close() error {
+	if t.started {
+		if err := t.end(); err != nil {
+			return err
+		}
+	}
+	return t.next.Close()
+}
+
+// HandleCancel ends the stream without [DONE] and delegates to the next transformer.
+func (t *OllamaToChatTransformer) HandleCancel() error {
+	t.done = true
+	return t.next.HandleCancel()
+}
+
+// GetResponseID returns the response ID of the next transformer, if it has one.
+// Implements transform.ResponseIDGetter interface.
+func (t *OllamaToChatTransformer) GetResponseID() string {
+	if getter, ok := t.next.(transform.ResponseIDGetter); ok {
+		return getter.GetResponseID()
+	}
+	return ""
+}
+
+// State reports the state of the next transformer, if it reports one.
+// Implements transform.StateReporter interface.
+func (t *OllamaToChatTransformer) State() string {
+	if reporter, ok := t.next.(transform.StateReporter); ok {
+		return reporter.State()
+	}
+	if t.done {
+		return transform.StateDone
+	}
+	if t.started {
+		return transform.StateText
+	}
+	return transform.StateWaiting
+}
+
+// EmitError passes a stream error to the next transformer, if it reports errors.
+func (t *OllamaToChatTransformer) EmitError(streamErr error) error {
+	if et, ok := t.next.(interface{ EmitError(error) error }); ok {
+		return et.EmitError(streamErr)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ai-proxy/transform"
+	"ai-proxy/types"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+func TestTransformOllamaChatToChat_Messages(t *testing.T) {
+	body := `{
+		"model": "llama3",
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "What is this?", "images": ["iVBORw0KGgo="]},
+			{"role": "assistant", "content": "", "tool_calls": [
+				{"function": {"name": "lookup", "arguments": {"q": "cat"}}},
+				{"function": {"name": "weather", "arguments": {"city": "Paris"}}}
+			]},
+			{"role": "tool", "tool_name": "weather", "content": "sunny"},
+			{"role": "tool", "tool_name": "lookup", "content": "a cat"}
+		]
+	}`
+
+	out, err := TransformOllamaChatToChat([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformOllamaChatToChat returned error: %v", err)
+	}
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+
+	if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+		t.Errorf("expected a streaming request with usage, got stream=%v options=%+v", req.Stream, req.StreamOptions)
+	}
+	if len(req.Messages) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(req.Messages))
+	}
+
+	parts, ok := req.Messages[1].Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("expected text and image parts, got %v", req.Messages[1].Content)
+	}
+	image := parts[1].(map[string]interface{})["image_url"].(map[string]interface{})
+	if image["url"] != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("unexpected image URL: %v", image["url"])
+	}
+
+	calls := req.Messages[2].ToolCalls
+	if len(calls) != 2 || calls[0].Function.Arguments != `{"q":"cat"}` {
+		t.Fatalf("unexpected tool calls: %+v", calls)
+	}
+	// Results answer the open call of the same function, whatever their order
+	if req.Messages[3].ToolCallID != calls[1].ID || req.Messages[4].ToolCallID != calls[0].ID {
+		t.Errorf("tool results matched wrong calls: %q, %q for %q, %q",
+			req.Messages[3].ToolCallID, req.Messages[4].ToolCallID, calls[0].ID, calls[1].ID)
+	}
+}
+
+func TestTransformOllamaChatToChat_Options(t *testing.T) {
+	body := `{
+		"model": "llama3",
+		"messages": [{"role": "user", "content": "hi"}],
+		"format": "json",
+		"think": "high",
+		"options": {"num_predict": 100, "temperature": 0.2, "top_k": 40, "stop": ["END"], "seed": 7, "num_ctx": 8192}
+	}`
+
+	out, err := TransformOllamaChatToChat([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformOllamaChatToChat returned error: %v", err)
+	}
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+
+	if req.MaxTokens != 100 || req.Temperature != 0.2 || req.TopK != 40 {
+		t.Errorf("unexpected sampling parameters: max_tokens=%d temperature=%v top_k=%d", req.MaxTokens, req.Temperature, req.TopK)
+	}
+	if req.Seed == nil || *req.Seed != 7 {
+		t.Errorf("expected seed 7, got %v", req.Seed)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+		t.Errorf("expected json_object response format, got %+v", req.ResponseFormat)
+	}
+	if req.ReasoningEffort != "high" {
+		t.Errorf("expected reasoning_effort high, got %q", req.ReasoningEffort)
+	}
+}
+
+func TestTransformOllamaChatToChat_SchemaFormat(t *testing.T) {
+	body := `{"model":"llama3","messages":[],"format":{"type":"object","properties":{"age":{"type":"integer"}}}}`
+
+	out, err := TransformOllamaChatToChat([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformOllamaChatToChat returned error: %v", err)
+	}
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" || req.ResponseFormat.JSONSchema == nil {
+		t.Fatalf("expected json_schema response format, got %+v", req.ResponseFormat)
+	}
+	if req.ResponseFormat.JSONSchema.Name != "response" {
+		t.Errorf("expected schema name response, got %q", req.ResponseFormat.JSONSchema.Name)
+	}
+}
+
+func TestTransformOllamaGenerateToChat(t *testing.T) {
+	out, err := TransformOllamaGenerateToChat([]byte(`{"model":"llama3","system":"Be brief.","prompt":"hi","raw":true}`))
+	if err != nil {
+		t.Fatalf("TransformOllamaGenerateToChat returned error: %v", err)
+	}
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Content != "hi" {
+		t.Errorf("unexpected messages: %+v", req.Messages)
+	}
+
+	if _, err := TransformOllamaGenerateToChat([]byte(`{`)); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestOllamaHidesThinking(t *testing.T) {
+	tests := []struct {
+		think string
+		want  bool
+	}{
+		{``, false},
+		{`true`, false},
+		{`false`, true},
+		{`"low"`, false},
+	}
+	for _, tt := range tests {
+		if got := OllamaHidesThinking(json.RawMessage(tt.think)); got != tt.want {
+			t.Errorf("OllamaHidesThinking(%q) = %v, want %v", tt.think, got, tt.want)
+		}
+	}
+}
+
+// runOllamaToChat feeds Ollama lines through an OllamaToChatTransformer
+// writing to a passthrough transformer, closes it and returns the data lines.
+func runOllamaToChat(t *testing.T, lines ...string) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	transformer := NewOllamaToChatTransformer(transform.NewPassthroughTransformer(&buf))
+	for _, line := range lines {
+		if err := transformer.Transform(&sse.Event{Data: line}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+	if err := transformer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	var out []string
+	for _, block := range strings.Split(strings.TrimSpace(buf.String()), "\n\n") {
+		out = append(out, strings.TrimPrefix(block, "data: "))
+	}
+	return out
+}
+
+func TestOllamaToChat_ThinkingAndContent(t *testing.T) {
+	lines := runOllamaToChat(t,
+		`{"model":"qwen3","created_at":"2026-01-01T00:00:00Z","message":{"role":"assistant","content":"","thinking":"Let me see"},"done":false}`,
+		`{"model":"qwen3","created_at":"2026-01-01T00:00:00Z","message":{"role":"assistant","content":"Hello"},"done":false}`,
+		`{"model":"qwen3","created_at":"2026-01-01T00:00:01Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":26,"eval_count":8}`,
+	)
+
+	if lines[len(lines)-1] != "[DONE]" {
+		t.Fatalf("expected [DONE] last, got %v", lines)
+	}
+	chunks := decodeChunks(t, lines)
+	if len(chunks) != 5 {
+		t.Fatalf("expected role, reasoning, content, finish and usage chunks, got %d: %v", len(chunks), lines)
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" || chunks[0].Model != "qwen3" {
+		t.Errorf("unexpected first chunk: %+v", chunks[0])
+	}
+	if chunks[1].Choices[0].Delta.ReasoningContent != "Let me see" {
+		t.Errorf("expected reasoning_content, got %+v", chunks[1].Choices[0].Delta)
+	}
+	if chunks[2].Choices[0].Delta.Content != "Hello" {
+		t.Errorf("expected content, got %+v", chunks[2].Choices[0].Delta)
+	}
+	if fr := chunks[3].Choices[0].FinishReason; fr == nil || *fr != "length" {
+		t.Errorf("expected finish_reason length, got %v", fr)
+	}
+	if u := chunks[4].Usage; u == nil || u.PromptTokens != 26 || u.CompletionTokens != 8 || u.TotalTokens != 34 {
+		t.Errorf("unexpected usage: %+v", chunks[4].Usage)
+	}
+	for _, c := range chunks {
+		if c.ID != chunks[0].ID {
+			t.Errorf("chunk IDs differ: %q, %q", c.ID, chunks[0].ID)
+		}
+	}
+}
+
+func TestOllamaToChat_ToolCalls(t *testing.T) {
+	lines := runOllamaToChat(t,
+		`{"model":"qwen3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"lookup","arguments":{"q":"cat"}}},{"function":{"name":"weather","arguments":{}}}]},"done":false}`,
+		`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`,
+	)
+
+	chunks := decodeChunks(t, lines)
+	var calls []types.ToolCall
+	for _, c := range chunks {
+		if len(c.Choices) > 0 {
+			calls = append(calls, c.Choices[0].Delta.ToolCalls...)
+		}
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %+v", calls)
+	}
+	if calls[0].Function.Name != "lookup" || calls[0].Function.Arguments != `{"q":"cat"}` || calls[0].Index != 0 {
+		t.Errorf("unexpected first call: %+v", calls[0])
+	}
+	if calls[1].Index != 1 || calls[0].ID == "" || calls[0].ID == calls[1].ID {
+		t.Errorf("expected distinct IDs and indexes, got %+v", calls)
+	}
+	last := chunks[len(chunks)-1]
+	if fr := last.Choices[0].FinishReason; fr == nil || *fr != "tool_calls" {
+		t.Errorf("expected finish_reason tool_calls, got %v", fr)
+	}
+}
+
+func TestOllamaToChat_GenerateLines(t *testing.T) {
+	lines := runOllamaToChat(t,
+		`{"model":"llama3","response":"Hi","thinking":"Hmm","done":false}`,
+		`{"model":"llama3","response":"","done":true,"done_reason":"stop"}`,
+	)
+
+	chunks := decodeChunks(t, lines)
+	if chunks[1].Choices[0].Delta.ReasoningContent != "Hmm" || chunks[2].Choices[0].Delta.Content != "Hi" {
+		t.Errorf("unexpected chunks: %v", lines)
+	}
+}
+
+func TestOllamaToChat_Error(t *testing.T) {
+	lines := runOllamaToChat(t,
+		`{"model":"llama3","message":{"role":"assistant","content":"Hi"},"done":false}`,
+		`{"error":"model runner has unexpectedly stopped"}`,
+		`{"model":"llama3","message":{"role":"assistant","content":"ignored"},"done":false}`,
+	)
+
+	last := lines[len(lines)-1]
+	var resp types.ErrorResponse
+	if err := json.Unmarshal([]byte(last), &resp); err != nil || resp.Error.Message != "model runner has unexpectedly stopped" {
+		t.Errorf("expected an OpenAI error object last, got %v", lines)
+	}
+	if strings.Contains(strings.Join(lines, "\n"), "ignored") || strings.Contains(strings.Join(lines, "\n"), "[DONE]") {
+		t.Errorf("expected the stream to end at the error, got %v", lines)
+	}
+}
+
+func TestOllamaToChat_CloseWithoutDone(t *testing.T) {
+	lines := runOllamaToChat(t, `{"model":"llama3","message":{"role":"assistant","content":"Hi"},"done":false}`)
+	if lines[len(lines)-1] != "[DONE]" {
+		t.Errorf("expected [DONE] on Close, got %v", lines)
+	}
+}
@@ -0,0 +1,93 @@
+// Package convert provides converters between different API formats.
+// This file implements tool call extraction from streamed reasoning text.
+package convert
+
+import (
+	"ai-proxy/transform/toolcall"
+)
+
+// reasoningToolCallExtractor routes reasoning text through the Kimi and GLM-5
+// tool call parsers. Models such as Kimi-K2.5 and GLM-5 sometimes emit tool calls
+// as markup inside their reasoning; the extractor turns that markup into
+// toolcall events so transformers can emit proper tool calls.
+//
+// GLM-5 parsing runs first; its content events are then fed to the Kimi parser.
+// A disabled parser passes text through unchanged.
+type reasoningToolCallExtractor struct {
+	kimi *toolcall.Parser
+	glm5 *toolcall.GLM5Parser
+}
+
+// newReasoningToolCallExtractor creates an extractor with the given parsers enabled.
+func newReasoningToolCallExtractor(kimi, glm5 bool) *reasoningToolCallExtractor {
+	e := &reasoningToolCallExtractor{}
+	if kimi {
+		e.kimi = toolcall.NewParser(toolcall.DefaultTokens)
+	}
+	if glm5 {
+		e.glm5 = toolcall.NewGLM5Parser()
+	}
+	return e
+}
+
+// Enabled reports whether any parser is active.
+func (e *reasoningToolCallExtractor) Enabled() bool {
+	return e != nil && (e.kimi != nil || e.glm5 != nil)
+}
+
+// InToolCall reports whether the Kimi parser is inside tool call markup.
+func (e *reasoningToolCallExtractor) InToolCall() bool {
+	return e != nil && e.kimi != nil && !e.kimi.IsIdle()
+}
+
+// Parse feeds a reasoning delta through the enabled parsers.
+// Plain reasoning text is returned as EventContent events.
+func (e *reasoningToolCallExtractor) Parse(text string) []toolcall.Event {
+	events := []toolcall.Event{{Type: toolcall.EventContent, Text: text}}
+	if e.glm5 != nil {
+		events = e.glm5.Parse(text)
+	}
+	if e.kimi == nil {
+		return events
+	}
+
+	var out []toolcall.Event
+	for _, ev := range events {
+		if ev.Type != toolcall.EventContent {
+			out = append(out, ev)
+			continue
+		}
+		out = append(out, e.kimi.Parse(ev.Text)...)
+	}
+	return out
+}
+
+// Flush drains any events still buffered in the parsers at the end of reasoning.
+func (e *reasoningToolCallExtractor) Flush() []toolcall.Event {
+	var out []toolcall.Event
+	if e.glm5 != nil {
+		out = append(out, e.glm5.Parse("")...)
+		// Unterminated markup is surfaced as reasoning rather than dropped
+		if rest := e.glm5.Buffer(); rest != "" {
+			e.glm5.Reset()
+			out = append(out, toolcall.Event{Type: toolcall.EventContent, Text: rest})
+		}
+	}
+	if e.kimi != nil {
+		// Leftover GLM-5 text may itself hold Kimi markup
+		var flushed []toolcall.Event
+		for _, ev := range out {
+			if ev.Type == toolcall.EventContent {
+				flushed = append(flushed, e.kimi.Parse(ev.Text)...)
+			} else {
+				flushed = append(flushed, ev)
+			}
+		}
+		out = append(flushed, e.kimi.Parse("")...)
+		if rest := e.kimi.Buffer(); rest != "" {
+			e.kimi.Reset()
+			out = append(out, toolcall.Event{Type: toolcall.EventContent, Text: rest})
+		}
+	}
+	return out
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"strings"
 
+	"ai-proxy/auth"
 	"ai-proxy/capture"
 	"ai-proxy/conversation"
 	"ai-proxy/logging"
@@ -40,7 +41,7 @@ func TransformResponsesToAnthropic(body []byte) ([]byte, error) {
 // and prepends all history to the current input.
 // If ctx contains a CaptureContext, sets CacheHit when conversation is found.
 func TransformResponsesToAnthropicWithCache(body []byte, ctx context.Context) ([]byte, error) {
-	return TransformResponsesToAnthropicWithOptions(body, ctx, true)
+	return TransformResponsesToAnthropicWithOptions(body, ctx, true, 0)
 }
 
 // TransformResponsesToAnthropicWithOptions converts an OpenAI ResponsesRequest to an Anthropic MessageRequest.
@@ -49,7 +50,9 @@ func TransformResponsesToAnthropicWithCache(body []byte, ctx context.Context) ([
 // and prepends all history to the current input.
 // If ctx contains a CaptureContext, sets CacheHit when conversation is found.
 // The shouldStore parameter controls whether to fetch conversation history from the store (ZDR mode when false).
-func TransformResponsesToAnthropicWithOptions(body []byte, ctx context.Context, shouldStore bool) ([]byte, error) {
+// The maxContextTokens parameter is the token budget for that history; the oldest turns
+// are dropped to stay within it. 0 means no limit.
+func TransformResponsesToAnthropicWithOptions(body []byte, ctx context.Context, shouldStore bool, maxContextTokens int) ([]byte, error) {
 	// Parse the OpenAI Responses API format request
 	var openReq types.ResponsesRequest
 	if err := json.Unmarshal(body, &openReq); err != nil {
@@ -59,18 +62,16 @@ func TransformResponsesToAnthropicWithOptions(body []byte, ctx context.Context,
 	// Fetch conversation history chain if previous_response_id is provided and store is true
 	// In ZDR mode (store:false), we skip the DB chain walk
 	if openReq.PreviousResponseID != "" && shouldStore {
-		// TODO: Wire userID from request headers for proper ownership validation
-		chain, err := conversation.WalkChainFromDefaultWithOwnership(openReq.PreviousResponseID, "")
+		// Only the authenticated owner may continue a conversation
+		chain, err := conversation.WalkChainFromDefaultWithOwnership(openReq.PreviousResponseID, auth.UserID(ctx))
 		if err != nil {
 			// Ownership error - log warning
 			logging.InfoMsg("Warning: Conversation access denied: %s", err.Error())
 		} else if len(chain) > 0 {
 			// Mark cache hit
 			capture.SetCacheHit(ctx)
-			// Prepend all conversations in the chain (oldest first)
-			for _, hist := range chain {
-				openReq.Input = prependHistoryToInput(hist, openReq.Input)
-			}
+			// Prepend the chain (oldest first), trimmed to the token budget
+			_, openReq.Input = expandHistory(chain, openReq.Input, maxContextTokens)
 		} else {
 			logging.InfoMsg("Warning: Previous response ID not found in conversation store: %s", openReq.PreviousResponseID)
 		}
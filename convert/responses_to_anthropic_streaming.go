@@ -3,11 +3,14 @@
 package convert
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 
+	"ai-proxy/events"
 	"ai-proxy/logging"
 	"ai-proxy/transform"
+	"ai-proxy/transform/toolcall"
 	"ai-proxy/types"
 
 	"github.com/tmaxmax/go-sse"
@@ -16,31 +19,70 @@ import (
 // ResponsesToAnthropicStreamingTransformer converts OpenAI Responses API SSE events
 // to Anthropic Messages API SSE format.
 // It implements the transform.SSETransformer interface.
+//
+// Responses output items are streamed one after another, so the transformer keeps
+// at most one open Anthropic content block. Blocks are opened lazily on the first
+// delta for an item and closed when the item finishes or a different item starts:
+//   - reasoning summary/text deltas → thinking block
+//   - output_text deltas → text block
+//   - function_call items → tool_use block
 type ResponsesToAnthropicStreamingTransformer struct {
 	w io.Writer
 
 	// State tracked during streaming
-	responseID string
-	model      string
-
-	// Block index tracking
-	blockIndex         int
-	outputIndexToBlock map[int]int // Maps output_index from Responses to block index in Anthropic
+	responseID   string
+	model        string
+	messageStart bool
+	finished     bool
+
+	// Block tracking
+	blockIndex   int    // index of the next block to open
+	openKind     string // "thinking", "text", "tool_use", or "" when no block is open
+	openOutput   int    // output_index of the item that owns the open block
+	sawToolUse   bool
+	toolCallMeta map[int]*types.OutputItem // function_call items by output_index
+
+	// Tool call extraction from reasoning text (Kimi/GLM-5 markup)
+	extractor *reasoningToolCallExtractor
+	kimi      bool
+	glm5      bool
 
 	// Token usage tracking
 	inputTokens  int
 	outputTokens int
+
+	ctx context.Context
 }
 
 // NewResponsesToAnthropicStreamingTransformer creates a new streaming transformer
 // that converts Responses API SSE events to Anthropic Messages format.
 func NewResponsesToAnthropicStreamingTransformer(w io.Writer) *ResponsesToAnthropicStreamingTransformer {
 	return &ResponsesToAnthropicStreamingTransformer{
-		w:                  w,
-		outputIndexToBlock: make(map[int]int),
+		w:            w,
+		openOutput:   -1,
+		toolCallMeta: make(map[int]*types.OutputItem),
 	}
 }
 
+// SetContext sets the request context, whose lifecycle events extracted tool calls are published to.
+func (t *ResponsesToAnthropicStreamingTransformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+}
+
+// SetKimiToolCallTransform enables or disables Kimi tool call extraction from reasoning text.
+// Extracted tool calls are emitted as tool_use blocks.
+func (t *ResponsesToAnthropicStreamingTransformer) SetKimiToolCallTransform(enabled bool) {
+	t.kimi = enabled
+	t.extractor = newReasoningToolCallExtractor(t.kimi, t.glm5)
+}
+
+// SetGLM5ToolCallTransform enables or disables GLM-5 XML tool call extraction from reasoning text.
+// Extracted tool calls are emitted as tool_use blocks.
+func (t *ResponsesToAnthropicStreamingTransformer) SetGLM5ToolCallTransform(enabled bool) {
+	t.glm5 = enabled
+	t.extractor = newReasoningToolCallExtractor(t.kimi, t.glm5)
+}
+
 // Transform processes a Responses API SSE event and converts it to Anthropic format.
 func (t *ResponsesToAnthropicStreamingTransformer) Transform(event *sse.Event) error {
 	if event.Data == "" || event.Data == "[DONE]" {
@@ -53,6 +95,9 @@ func (t *ResponsesToAnthropicStreamingTransformer) Transform(event *sse.Event) e
 		logging.DebugMsg("Failed to parse Responses event: %v", err)
 		return nil
 	}
+	if respEvent.Type == "" {
+		respEvent.Type = event.Type
+	}
 
 	return t.handleEvent(&respEvent)
 }
@@ -64,16 +109,12 @@ func (t *ResponsesToAnthropicStreamingTransformer) handleEvent(event *types.Resp
 		return t.handleResponseCreated(event)
 	case "response.output_item.added":
 		return t.handleOutputItemAdded(event)
-	case "response.content_part.added":
-		return t.handleContentPartAdded(event)
-	case "response.output_text.delta":
+	case "response.output_text.delta", "response.refusal.delta":
 		return t.handleOutputTextDelta(event)
+	case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+		return t.handleReasoningDelta(event)
 	case "response.function_call_arguments.delta":
 		return t.handleFunctionCallArgsDelta(event)
-	case "response.output_text.done":
-		return t.handleOutputTextDone(event)
-	case "response.function_call_arguments.done":
-		return t.handleFunctionCallArgsDone(event)
 	case "response.output_item.done":
 		return t.handleOutputItemDone(event)
 	case "response.completed":
@@ -82,6 +123,8 @@ func (t *ResponsesToAnthropicStreamingTransformer) handleEvent(event *types.Resp
 		return t.handleResponseIncomplete(event)
 	case "response.failed":
 		return t.handleResponseFailed(event)
+	case "error":
+		return t.handleError(event)
 	default:
 		// Skip unknown events
 		return nil
@@ -96,17 +139,29 @@ func (t *ResponsesToAnthropicStreamingTransformer) handleResponseCreated(event *
 
 	t.responseID = event.Response.ID
 	t.model = event.Response.Model
+	return t.ensureMessageStart()
+}
+
+// ensureMessageStart emits message_start once, before any content block.
+func (t *ResponsesToAnthropicStreamingTransformer) ensureMessageStart() error {
+	if t.messageStart {
+		return nil
+	}
+	t.messageStart = true
 
-	// Emit message_start event
 	msgStart := map[string]interface{}{
 		"type": "message_start",
 		"message": map[string]interface{}{
-			"id":    t.responseID,
-			"type":  "message",
-			"role":  "assistant",
-			"model": t.model,
+			"id":            t.responseID,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         t.model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
 			"usage": map[string]int{
-				"input_tokens": t.inputTokens,
+				"input_tokens":  t.inputTokens,
+				"output_tokens": 0,
 			},
 		},
 	}
@@ -116,81 +171,99 @@ func (t *ResponsesToAnthropicStreamingTransformer) handleResponseCreated(event *
 // handleOutputItemAdded handles response.output_item.added event.
 // For function_call type, emits content_block_start with tool_use.
 func (t *ResponsesToAnthropicStreamingTransformer) handleOutputItemAdded(event *types.ResponsesStreamEvent) error {
-	if event.OutputItem == nil {
+	if event.OutputItem == nil || event.OutputItem.Type != "function_call" {
 		return nil
 	}
 
-	// Track the mapping from output_index to block_index
-	t.outputIndexToBlock[event.ContentIndex] = t.blockIndex
+	t.toolCallMeta[event.OutputIndex] = event.OutputItem
+	return t.startToolUse(event.OutputIndex, toolUseID(event.OutputItem), event.OutputItem.Name)
+}
 
-	if event.OutputItem.Type == "function_call" {
-		// Emit content_block_start for tool_use
-		blockStart := map[string]interface{}{
-			"type":  "content_block_start",
-			"index": t.blockIndex,
-			"content_block": map[string]interface{}{
-				"type": "tool_use",
-				"id":   event.OutputItem.CallID,
-				"name": event.OutputItem.Name,
-			},
-		}
-		if err := t.emitEvent("content_block_start", blockStart); err != nil {
+// handleOutputTextDelta handles response.output_text.delta event → emits content_block_delta with text_delta.
+func (t *ResponsesToAnthropicStreamingTransformer) handleOutputTextDelta(event *types.ResponsesStreamEvent) error {
+	if event.Delta == "" {
+		return nil
+	}
+	if err := t.finishReasoning(); err != nil {
+		return err
+	}
+	if err := t.ensureBlock("text", event.OutputIndex); err != nil {
+		return err
+	}
+	return t.emitDelta(map[string]interface{}{
+		"type": "text_delta",
+		"text": event.Delta,
+	})
+}
+
+// handleReasoningDelta handles reasoning summary and raw reasoning text deltas
+// → emits content_block_delta with thinking_delta.
+// When tool call extraction is enabled, markup in the reasoning is converted to tool_use blocks.
+func (t *ResponsesToAnthropicStreamingTransformer) handleReasoningDelta(event *types.ResponsesStreamEvent) error {
+	if event.Delta == "" {
+		return nil
+	}
+	if !t.extractor.Enabled() {
+		return t.emitThinking(event.OutputIndex, event.Delta)
+	}
+
+	for _, e := range t.extractor.Parse(event.Delta) {
+		if err := t.writeExtractedEvent(event.OutputIndex, e); err != nil {
 			return err
 		}
-		t.blockIndex++
 	}
-
 	return nil
 }
 
-// handleContentPartAdded handles response.content_part.added event.
-// For output_text type, emits content_block_start with text.
-func (t *ResponsesToAnthropicStreamingTransformer) handleContentPartAdded(event *types.ResponsesStreamEvent) error {
-	if event.OutputItem == nil {
-		return nil
+// emitThinking writes reasoning text into the thinking block for outputIndex.
+func (t *ResponsesToAnthropicStreamingTransformer) emitThinking(outputIndex int, text string) error {
+	if err := t.ensureBlock("thinking", outputIndex); err != nil {
+		return err
 	}
+	return t.emitDelta(map[string]interface{}{
+		"type":     "thinking_delta",
+		"thinking": text,
+	})
+}
 
-	// Check if this is an output_text content part being added to a message
-	// The OutputItem here represents the content part
-	if event.OutputItem.Type == "output_text" || (event.OutputItem.Content != nil && len(event.OutputItem.Content) > 0) {
-		// For content_part.added with output_text, emit content_block_start for text
-		// Track the mapping
-		t.outputIndexToBlock[event.ContentIndex] = t.blockIndex
-
-		blockStart := map[string]interface{}{
-			"type":  "content_block_start",
-			"index": t.blockIndex,
-			"content_block": map[string]interface{}{
-				"type": "text",
-				"text": "",
-			},
+// writeExtractedEvent converts a parser event from reasoning text to Anthropic events.
+// Extracted tool calls are not tied to a Responses output item, so they use output index -1.
+func (t *ResponsesToAnthropicStreamingTransformer) writeExtractedEvent(outputIndex int, e toolcall.Event) error {
+	switch e.Type {
+	case toolcall.EventContent:
+		if e.Text == "" {
+			return nil
 		}
-		if err := t.emitEvent("content_block_start", blockStart); err != nil {
-			return err
+		return t.emitThinking(outputIndex, e.Text)
+	case toolcall.EventToolStart:
+		events.FromContext(t.ctx).ToolCallExtracted(e.Name)
+		return t.startToolUse(-1, e.ID, e.Name)
+	case toolcall.EventToolArgs:
+		if t.openKind != "tool_use" || e.Args == "" {
+			return nil
 		}
-		t.blockIndex++
+		return t.emitDelta(map[string]interface{}{
+			"type":         "input_json_delta",
+			"partial_json": e.Args,
+		})
+	case toolcall.EventToolEnd:
+		return t.closeBlock()
 	}
-
 	return nil
 }
 
-// handleOutputTextDelta handles response.output_text.delta event → emits content_block_delta with text_delta.
-func (t *ResponsesToAnthropicStreamingTransformer) handleOutputTextDelta(event *types.ResponsesStreamEvent) error {
-	if event.Delta == "" {
+// finishReasoning drains the tool call extractor before non-reasoning output.
+func (t *ResponsesToAnthropicStreamingTransformer) finishReasoning() error {
+	if !t.extractor.Enabled() {
 		return nil
 	}
-
-	idx := t.outputIndexToBlock[event.ContentIndex]
-
-	delta := map[string]interface{}{
-		"type":  "content_block_delta",
-		"index": idx,
-		"delta": map[string]interface{}{
-			"type": "text_delta",
-			"text": event.Delta,
-		},
+	outputIndex := t.openOutput
+	for _, e := range t.extractor.Flush() {
+		if err := t.writeExtractedEvent(outputIndex, e); err != nil {
+			return err
+		}
 	}
-	return t.emitEvent("content_block_delta", delta)
+	return nil
 }
 
 // handleFunctionCallArgsDelta handles response.function_call_arguments.delta event
@@ -200,119 +273,231 @@ func (t *ResponsesToAnthropicStreamingTransformer) handleFunctionCallArgsDelta(e
 		return nil
 	}
 
-	idx := t.outputIndexToBlock[event.ContentIndex]
-
-	delta := map[string]interface{}{
-		"type":  "content_block_delta",
-		"index": idx,
-		"delta": map[string]interface{}{
-			"type":         "input_json_delta",
-			"partial_json": event.Delta,
-		},
-	}
-	return t.emitEvent("content_block_delta", delta)
-}
-
-// handleOutputTextDone handles response.output_text.done event → emits content_block_stop.
-func (t *ResponsesToAnthropicStreamingTransformer) handleOutputTextDone(event *types.ResponsesStreamEvent) error {
-	idx := t.outputIndexToBlock[event.ContentIndex]
-
-	blockStop := map[string]interface{}{
-		"type":  "content_block_stop",
-		"index": idx,
+	// Reopen the tool_use block if another block interrupted it (should not happen
+	// with sequential Responses output, but keeps the Anthropic stream well-formed)
+	if t.openKind != "tool_use" || t.openOutput != event.OutputIndex {
+		item := t.toolCallMeta[event.OutputIndex]
+		if item == nil {
+			item = &types.OutputItem{ID: event.ItemID}
+		}
+		if err := t.startToolUse(event.OutputIndex, toolUseID(item), item.Name); err != nil {
+			return err
+		}
 	}
-	return t.emitEvent("content_block_stop", blockStop)
-}
-
-// handleFunctionCallArgsDone handles response.function_call_arguments.done event → emits content_block_stop.
-func (t *ResponsesToAnthropicStreamingTransformer) handleFunctionCallArgsDone(event *types.ResponsesStreamEvent) error {
-	idx := t.outputIndexToBlock[event.ContentIndex]
 
-	blockStop := map[string]interface{}{
-		"type":  "content_block_stop",
-		"index": idx,
-	}
-	return t.emitEvent("content_block_stop", blockStop)
+	return t.emitDelta(map[string]interface{}{
+		"type":         "input_json_delta",
+		"partial_json": event.Delta,
+	})
 }
 
-// handleOutputItemDone handles response.output_item.done event.
-// Currently no specific action needed as content_block_stop is emitted on text/function done events.
+// handleOutputItemDone handles response.output_item.done event → emits content_block_stop.
 func (t *ResponsesToAnthropicStreamingTransformer) handleOutputItemDone(event *types.ResponsesStreamEvent) error {
-	// No action needed - content_block_stop already emitted
+	if event.OutputItem != nil && event.OutputItem.Type == "reasoning" {
+		if err := t.finishReasoning(); err != nil {
+			return err
+		}
+	}
+	if t.openKind != "" && t.openOutput == event.OutputIndex {
+		return t.closeBlock()
+	}
 	return nil
 }
 
 // handleResponseCompleted handles response.completed event → emits message_delta + message_stop.
 func (t *ResponsesToAnthropicStreamingTransformer) handleResponseCompleted(event *types.ResponsesStreamEvent) error {
-	if event.Response == nil {
-		return nil
-	}
-
-	// Determine stop_reason based on output items
+	// Determine stop_reason based on output items and extracted tool calls
 	stopReason := "end_turn"
-	for _, item := range event.Response.Output {
-		if item.Type == "function_call" {
-			stopReason = "tool_use"
-			break
+	if event.Response != nil {
+		for _, item := range event.Response.Output {
+			if item.Type == "function_call" {
+				stopReason = "tool_use"
+				break
+			}
 		}
 	}
+	if err := t.finishReasoning(); err != nil {
+		return err
+	}
+	if t.sawToolUse {
+		stopReason = "tool_use"
+	}
+	return t.finishMessage(event.Response, stopReason)
+}
 
-	// Update usage if available
-	if event.Response.Usage != nil {
-		t.inputTokens = event.Response.Usage.InputTokens
-		t.outputTokens = event.Response.Usage.OutputTokens
+// handleResponseIncomplete handles response.incomplete event → emits message_delta with max_tokens + message_stop.
+func (t *ResponsesToAnthropicStreamingTransformer) handleResponseIncomplete(event *types.ResponsesStreamEvent) error {
+	if err := t.finishReasoning(); err != nil {
+		return err
+	}
+	stopReason := "max_tokens"
+	if event.Response != nil && event.Response.IncompleteDetails != nil &&
+		event.Response.IncompleteDetails.Reason == "content_filter" {
+		stopReason = "refusal"
 	}
+	return t.finishMessage(event.Response, stopReason)
+}
 
-	// Emit message_delta
-	msgDelta := map[string]interface{}{
-		"type": "message_delta",
-		"delta": map[string]interface{}{
-			"stop_reason": stopReason,
-		},
-		"usage": map[string]int{
-			"output_tokens": t.outputTokens,
-		},
+// finishMessage closes the open block and emits message_delta and message_stop.
+func (t *ResponsesToAnthropicStreamingTransformer) finishMessage(resp *types.ResponsesResponse, stopReason string) error {
+	if t.finished {
+		return nil
 	}
-	if err := t.emitEvent("message_delta", msgDelta); err != nil {
+	if err := t.ensureMessageStart(); err != nil {
+		return err
+	}
+	if err := t.closeBlock(); err != nil {
 		return err
 	}
+	t.finished = true
 
-	// Emit message_stop
-	return t.emitEvent("message_stop", map[string]string{"type": "message_stop"})
-}
+	// Update usage if available
+	if resp != nil && resp.Usage != nil {
+		t.inputTokens = resp.Usage.InputTokens
+		t.outputTokens = resp.Usage.OutputTokens
+	}
+
+	usage := map[string]int{
+		"input_tokens":  t.inputTokens,
+		"output_tokens": t.outputTokens,
+	}
+	if resp != nil && resp.Usage != nil && resp.Usage.InputTokensDetails != nil && resp.Usage.InputTokensDetails.CachedTokens > 0 {
+		usage["cache_read_input_tokens"] = resp.Usage.InputTokensDetails.CachedTokens
+	}
 
-// handleResponseIncomplete handles response.incomplete event → emits message_delta with max_tokens + message_stop.
-func (t *ResponsesToAnthropicStreamingTransformer) handleResponseIncomplete(event *types.ResponsesStreamEvent) error {
-	// Emit message_delta with max_tokens stop reason
 	msgDelta := map[string]interface{}{
 		"type": "message_delta",
 		"delta": map[string]interface{}{
-			"stop_reason": "max_tokens",
-		},
-		"usage": map[string]int{
-			"output_tokens": t.outputTokens,
+			"stop_reason":   stopReason,
+			"stop_sequence": nil,
 		},
+		"usage": usage,
 	}
 	if err := t.emitEvent("message_delta", msgDelta); err != nil {
 		return err
 	}
 
-	// Emit message_stop
 	return t.emitEvent("message_stop", map[string]string{"type": "message_stop"})
 }
 
 // handleResponseFailed handles response.failed event → emits error event.
 func (t *ResponsesToAnthropicStreamingTransformer) handleResponseFailed(event *types.ResponsesStreamEvent) error {
+	msg := "upstream response failed"
+	if event.Response != nil && event.Response.Error != nil && event.Response.Error.Message != "" {
+		msg = event.Response.Error.Message
+	}
+	return t.emitError(msg)
+}
+
+// handleError handles a top-level error event → emits error event.
+func (t *ResponsesToAnthropicStreamingTransformer) handleError(event *types.ResponsesStreamEvent) error {
+	msg := "upstream stream error"
+	if event.Error != nil && event.Error.Message != "" {
+		msg = event.Error.Message
+	}
+	return t.emitError(msg)
+}
+
+// emitError writes an Anthropic error event and marks the message finished.
+func (t *ResponsesToAnthropicStreamingTransformer) emitError(msg string) error {
+	t.finished = true
 	errEvent := map[string]interface{}{
 		"type": "error",
 		"error": map[string]interface{}{
 			"type":    "api_error",
-			"message": "upstream response failed",
+			"message": msg,
 		},
 	}
 	return t.emitEvent("error", errEvent)
 }
 
+// ensureBlock opens a block of the given kind for outputIndex, closing any other open block.
+func (t *ResponsesToAnthropicStreamingTransformer) ensureBlock(kind string, outputIndex int) error {
+	if t.openKind == kind && t.openOutput == outputIndex {
+		return nil
+	}
+	if err := t.ensureMessageStart(); err != nil {
+		return err
+	}
+	if err := t.closeBlock(); err != nil {
+		return err
+	}
+
+	var block map[string]interface{}
+	switch kind {
+	case "thinking":
+		block = map[string]interface{}{"type": "thinking", "thinking": "", "signature": ""}
+	default:
+		block = map[string]interface{}{"type": "text", "text": ""}
+	}
+	return t.openBlock(kind, outputIndex, block)
+}
+
+// startToolUse opens a tool_use block, closing any other open block.
+func (t *ResponsesToAnthropicStreamingTransformer) startToolUse(outputIndex int, id, name string) error {
+	if err := t.ensureMessageStart(); err != nil {
+		return err
+	}
+	if err := t.closeBlock(); err != nil {
+		return err
+	}
+	t.sawToolUse = true
+	return t.openBlock("tool_use", outputIndex, map[string]interface{}{
+		"type":  "tool_use",
+		"id":    id,
+		"name":  name,
+		"input": map[string]interface{}{},
+	})
+}
+
+// openBlock emits content_block_start and records the block as open.
+func (t *ResponsesToAnthropicStreamingTransformer) openBlock(kind string, outputIndex int, block map[string]interface{}) error {
+	blockStart := map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         t.blockIndex,
+		"content_block": block,
+	}
+	if err := t.emitEvent("content_block_start", blockStart); err != nil {
+		return err
+	}
+	t.openKind = kind
+	t.openOutput = outputIndex
+	return nil
+}
+
+// closeBlock emits content_block_stop for the open block, if any.
+func (t *ResponsesToAnthropicStreamingTransformer) closeBlock() error {
+	if t.openKind == "" {
+		return nil
+	}
+	blockStop := map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": t.blockIndex,
+	}
+	t.openKind = ""
+	t.openOutput = -1
+	t.blockIndex++
+	return t.emitEvent("content_block_stop", blockStop)
+}
+
+// emitDelta writes a content_block_delta for the open block.
+func (t *ResponsesToAnthropicStreamingTransformer) emitDelta(delta map[string]interface{}) error {
+	return t.emitEvent("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": t.blockIndex,
+		"delta": delta,
+	})
+}
+
+// toolUseID returns the ID clients must echo back in tool_result blocks.
+// Responses uses call_id for that purpose; the item id is only a fallback.
+func toolUseID(item *types.OutputItem) string {
+	if item.CallID != "" {
+		return item.CallID
+	}
+	return item.ID
+}
+
 // emitEvent writes an Anthropic SSE event with the given name and data.
 func (t *ResponsesToAnthropicStreamingTransformer) emitEvent(name string, data interface{}) error {
 	dataBytes, err := json.Marshal(data)
@@ -350,8 +535,35 @@ func (t *ResponsesToAnthropicStreamingTransformer) Initialize() error {
 }
 
 // HandleCancel handles cancellation requests.
+// Closes the open block and emits message_delta and message_stop, so the client
+// receives a complete message with the content streamed so far.
 func (t *ResponsesToAnthropicStreamingTransformer) HandleCancel() error {
-	return nil
+	return t.finishMessage(nil, "end_turn")
+}
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *ResponsesToAnthropicStreamingTransformer) State() string {
+	switch {
+	case t.finished:
+		return transform.StateDone
+	case t.openKind == "tool_use", t.extractor.InToolCall():
+		return transform.StateToolCall
+	case t.openKind == "thinking":
+		return transform.StateReasoning
+	case t.openKind == "text":
+		return transform.StateText
+	default:
+		return transform.StateWaiting
+	}
+}
+
+// EmitError sends an Anthropic error event when the upstream stream breaks.
+func (t *ResponsesToAnthropicStreamingTransformer) EmitError(err error) error {
+	if t.finished {
+		return nil
+	}
+	return t.emitError(err.Error())
 }
 
 // Verify interface compliance
@@ -0,0 +1,296 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ai-proxy/transform"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// anthropicStreamEvent is a decoded Anthropic SSE event for assertions.
+type anthropicStreamEvent struct {
+	Name string
+	Data map[string]interface{}
+}
+
+// runResponsesToAnthropic feeds raw Responses event payloads through the transformer
+// and returns the decoded Anthropic events.
+func runResponsesToAnthropic(t *testing.T, transformer *ResponsesToAnthropicStreamingTransformer, buf *bytes.Buffer, payloads ...string) []anthropicStreamEvent {
+	t.Helper()
+	for _, p := range payloads {
+		if err := transformer.Transform(&sse.Event{Data: p}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+
+	var events []anthropicStreamEvent
+	for _, block := range strings.Split(strings.TrimSpace(buf.String()), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		if len(lines) != 2 {
+			t.Fatalf("malformed SSE block: %q", block)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data); err != nil {
+			t.Fatalf("invalid event data: %v", err)
+		}
+		events = append(events, anthropicStreamEvent{Name: strings.TrimPrefix(lines[0], "event: "), Data: data})
+	}
+	return events
+}
+
+// eventNames returns the event names in order.
+func eventNames(events []anthropicStreamEvent) []string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// TestResponsesToAnthropicStreaming_TextAndReasoning tests block sequencing for
+// a reasoning item followed by a message item.
+func TestResponsesToAnthropicStreaming_TextAndReasoning(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+
+	events := runResponsesToAnthropic(t, transformer, &buf,
+		`{"type":"response.created","response":{"id":"resp_1","model":"gpt-5","status":"in_progress","output":[]}}`,
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1","summary":[]}}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"item_id":"rs_1","delta":"hmm"}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"hmm"}]}}`,
+		`{"type":"response.output_item.added","output_index":1,"item":{"type":"message","id":"msg_1","role":"assistant"}}`,
+		`{"type":"response.content_part.added","output_index":1,"content_index":0,"part":{"type":"output_text","text":""}}`,
+		`{"type":"response.output_text.delta","output_index":1,"content_index":0,"delta":"Hi"}`,
+		`{"type":"response.output_text.done","output_index":1,"content_index":0,"text":"Hi"}`,
+		`{"type":"response.output_item.done","output_index":1,"item":{"type":"message","id":"msg_1"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"hmm"}]}],"usage":{"input_tokens":3,"output_tokens":4,"total_tokens":7}}}`,
+	)
+
+	want := []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if got := eventNames(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	thinking := events[1].Data["content_block"].(map[string]interface{})
+	if thinking["type"] != "thinking" || events[1].Data["index"] != float64(0) {
+		t.Errorf("first block = %v", events[1].Data)
+	}
+	text := events[4].Data["content_block"].(map[string]interface{})
+	if text["type"] != "text" || events[4].Data["index"] != float64(1) {
+		t.Errorf("second block = %v", events[4].Data)
+	}
+
+	msgDelta := events[7].Data
+	if msgDelta["delta"].(map[string]interface{})["stop_reason"] != "end_turn" {
+		t.Errorf("stop_reason = %v", msgDelta["delta"])
+	}
+	usage := msgDelta["usage"].(map[string]interface{})
+	if usage["input_tokens"] != float64(3) || usage["output_tokens"] != float64(4) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+// TestResponsesToAnthropicStreaming_FunctionCall tests tool_use conversion using call_id.
+func TestResponsesToAnthropicStreaming_FunctionCall(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+
+	events := runResponsesToAnthropic(t, transformer, &buf,
+		`{"type":"response.created","response":{"id":"resp_1","model":"gpt-5","output":[]}}`,
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"function_call","id":"fc_1","call_id":"call_abc","name":"get_weather","arguments":""}}`,
+		`{"type":"response.function_call_arguments.delta","output_index":0,"item_id":"fc_1","delta":"{\"city\":"}`,
+		`{"type":"response.function_call_arguments.delta","output_index":0,"item_id":"fc_1","delta":"\"Paris\"}"}`,
+		`{"type":"response.function_call_arguments.done","output_index":0,"item_id":"fc_1","arguments":"{\"city\":\"Paris\"}"}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"function_call","id":"fc_1","call_id":"call_abc","name":"get_weather"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[{"type":"function_call","id":"fc_1","call_id":"call_abc","name":"get_weather"}]}}`,
+	)
+
+	block := events[1].Data["content_block"].(map[string]interface{})
+	if block["type"] != "tool_use" || block["id"] != "call_abc" || block["name"] != "get_weather" {
+		t.Errorf("tool_use block = %v", block)
+	}
+
+	var args strings.Builder
+	for _, e := range events {
+		if e.Name == "content_block_delta" {
+			args.WriteString(e.Data["delta"].(map[string]interface{})["partial_json"].(string))
+		}
+	}
+	if args.String() != `{"city":"Paris"}` {
+		t.Errorf("arguments = %q", args.String())
+	}
+
+	var stopReason interface{}
+	for _, e := range events {
+		if e.Name == "message_delta" {
+			stopReason = e.Data["delta"].(map[string]interface{})["stop_reason"]
+		}
+	}
+	if stopReason != "tool_use" {
+		t.Errorf("stop_reason = %v, want tool_use", stopReason)
+	}
+}
+
+// TestResponsesToAnthropicStreaming_KimiToolCallInReasoning tests extraction of
+// Kimi tool call markup from the reasoning stream.
+func TestResponsesToAnthropicStreaming_KimiToolCallInReasoning(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+	transformer.SetKimiToolCallTransform(true)
+
+	reasoning := `Let me check.<|tool_calls_section_begin|><|tool_call_begin|>functions.bash:0<|tool_call_argument_begin|>{"cmd":"ls"}<|tool_call_end|><|tool_calls_section_end|>`
+	delta, _ := json.Marshal(reasoning)
+
+	events := runResponsesToAnthropic(t, transformer, &buf,
+		`{"type":"response.created","response":{"id":"resp_1","model":"kimi","output":[]}}`,
+		`{"type":"response.reasoning_text.delta","output_index":0,"item_id":"rs_1","delta":`+string(delta)+`}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[]}}`,
+	)
+
+	output := buf.String()
+	if strings.Contains(output, "tool_calls_section_begin") {
+		t.Errorf("tool call markup leaked into output: %s", output)
+	}
+
+	var toolBlock map[string]interface{}
+	var stopReason interface{}
+	for _, e := range events {
+		if e.Name == "content_block_start" {
+			if b := e.Data["content_block"].(map[string]interface{}); b["type"] == "tool_use" {
+				toolBlock = b
+			}
+		}
+		if e.Name == "message_delta" {
+			stopReason = e.Data["delta"].(map[string]interface{})["stop_reason"]
+		}
+	}
+	if toolBlock == nil || toolBlock["name"] != "bash" {
+		t.Fatalf("expected extracted bash tool_use block, got events: %v", eventNames(events))
+	}
+	if !strings.Contains(output, `"partial_json":"{\"cmd\":\"ls\"}"`) {
+		t.Errorf("expected extracted arguments in output: %s", output)
+	}
+	if !strings.Contains(output, `"thinking":"Let me check."`) {
+		t.Errorf("expected reasoning before markup as thinking: %s", output)
+	}
+	if stopReason != "tool_use" {
+		t.Errorf("stop_reason = %v, want tool_use", stopReason)
+	}
+}
+
+// TestResponsesToAnthropicStreaming_TruncatedGLM5Markup tests that GLM-5 tool
+// call markup cut off by the end of reasoning is surfaced as thinking.
+func TestResponsesToAnthropicStreaming_TruncatedGLM5Markup(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+	transformer.SetGLM5ToolCallTransform(true)
+
+	reasoning := `Let me check.<tool_call>get_weather<arg_key>city</arg_key><arg_value>Par`
+	delta, _ := json.Marshal(reasoning)
+
+	events := runResponsesToAnthropic(t, transformer, &buf,
+		`{"type":"response.created","response":{"id":"resp_1","model":"glm-5","output":[]}}`,
+		`{"type":"response.reasoning_text.delta","output_index":0,"item_id":"rs_1","delta":`+string(delta)+`}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[]}}`,
+	)
+
+	var thinking strings.Builder
+	for _, e := range events {
+		if e.Name == "content_block_start" {
+			if b := e.Data["content_block"].(map[string]interface{}); b["type"] == "tool_use" {
+				t.Errorf("expected no tool_use block for truncated markup, got %v", b)
+			}
+		}
+		if e.Name == "content_block_delta" {
+			if text, ok := e.Data["delta"].(map[string]interface{})["thinking"].(string); ok {
+				thinking.WriteString(text)
+			}
+		}
+	}
+	if thinking.String() != reasoning {
+		t.Errorf("thinking = %q, want %q", thinking.String(), reasoning)
+	}
+}
+
+// TestResponsesToAnthropicStreaming_Failed tests that response.failed carries the upstream message.
+func TestResponsesToAnthropicStreaming_Failed(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+
+	events := runResponsesToAnthropic(t, transformer, &buf,
+		`{"type":"response.failed","response":{"id":"resp_1","status":"failed","error":{"code":"server_error","message":"model overloaded"}}}`,
+	)
+
+	if len(events) != 1 || events[0].Name != "error" {
+		t.Fatalf("events = %v, want [error]", eventNames(events))
+	}
+	errObj := events[0].Data["error"].(map[string]interface{})
+	if errObj["message"] != "model overloaded" {
+		t.Errorf("error message = %v", errObj["message"])
+	}
+}
+
+// TestResponsesToAnthropicStreaming_HandleCancel tests that a cancelled stream
+// ends with message_stop after the content streamed so far.
+func TestResponsesToAnthropicStreaming_HandleCancel(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+
+	runResponsesToAnthropic(t, transformer, &buf,
+		`{"type":"response.created","response":{"id":"resp_1","model":"gpt-5","status":"in_progress","output":[]}}`,
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"message","id":"msg_1","role":"assistant"}}`,
+		`{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"Hi"}`,
+	)
+	if err := transformer.HandleCancel(); err != nil {
+		t.Fatalf("HandleCancel returned error: %v", err)
+	}
+	// Close after HandleCancel must not end the message twice
+	if err := transformer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	events := runResponsesToAnthropic(t, transformer, &buf)
+
+	want := []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if got := eventNames(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+// TestResponsesToAnthropicStreaming_State tests the reported state as blocks open and close.
+func TestResponsesToAnthropicStreaming_State(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToAnthropicStreamingTransformer(&buf)
+
+	steps := []struct {
+		payload string
+		want    string
+	}{
+		{`{"type":"response.created","response":{"id":"resp_1","model":"gpt-5","status":"in_progress","output":[]}}`, transform.StateWaiting},
+		{`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1","summary":[]}}`, transform.StateWaiting},
+		{`{"type":"response.reasoning_summary_text.delta","output_index":0,"item_id":"rs_1","delta":"hmm"}`, transform.StateReasoning},
+		{`{"type":"response.output_item.added","output_index":1,"item":{"type":"message","id":"msg_1","role":"assistant"}}`, transform.StateReasoning},
+		{`{"type":"response.output_text.delta","output_index":1,"content_index":0,"delta":"Hi"}`, transform.StateText},
+		{`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[]}}`, transform.StateDone},
+	}
+	for _, step := range steps {
+		runResponsesToAnthropic(t, transformer, &buf, step.payload)
+		if got := transformer.State(); got != step.want {
+			t.Errorf("State() after %s = %q, want %q", step.payload, got, step.want)
+		}
+	}
+}
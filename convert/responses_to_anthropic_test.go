@@ -1,9 +1,11 @@
 package convert
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
+	"ai-proxy/auth"
 	"ai-proxy/conversation"
 	"ai-proxy/types"
 )
@@ -974,3 +976,44 @@ func TestPrependHistoryToInput_CombinesMessageAndFunctionCall(t *testing.T) {
 		}
 	}
 }
+
+func TestResponsesToAnthropicConverter_PreviousResponseIDOwnership(t *testing.T) {
+	oldStore := conversation.DefaultStore
+	conversation.DefaultStore = conversation.NewStore(conversation.Config{})
+	t.Cleanup(func() {
+		conversation.DefaultStore = oldStore
+	})
+
+	conversation.StoreInDefault(&conversation.Conversation{
+		ID:     "resp_owned",
+		UserID: "alice",
+		Input:  []types.InputItem{{Type: "message", Role: "user", Content: "Alice's question"}},
+		Output: []types.OutputItem{{
+			Type:    "message",
+			Role:    "assistant",
+			Content: []types.OutputContent{{Type: "output_text", Text: "Alice's answer"}},
+		}},
+	})
+	input := []byte(`{"model": "claude-3-opus", "input": "Continue", "previous_response_id": "resp_owned"}`)
+
+	for _, tt := range []struct {
+		user         string
+		wantMessages int
+	}{
+		{user: "alice", wantMessages: 3},
+		{user: "bob", wantMessages: 1},
+	} {
+		ctx := auth.WithIdentity(context.Background(), &auth.Identity{User: tt.user})
+		output, err := TransformResponsesToAnthropicWithOptions(input, ctx, true, 0)
+		if err != nil {
+			t.Fatalf("TransformResponsesToAnthropicWithOptions returned error: %v", err)
+		}
+		var req types.MessageRequest
+		if err := json.Unmarshal(output, &req); err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		if len(req.Messages) != tt.wantMessages {
+			t.Errorf("user %s: expected %d messages, got %d", tt.user, tt.wantMessages, len(req.Messages))
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package convert
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,7 +11,10 @@ import (
 	"time"
 
 	"ai-proxy/conversation"
+	"ai-proxy/events"
 	"ai-proxy/logging"
+	"ai-proxy/transform"
+	"ai-proxy/transform/toolcall"
 	"ai-proxy/types"
 
 	"github.com/tmaxmax/go-sse"
@@ -18,9 +22,11 @@ import (
 
 // ResponsesToChatConverter converts OpenAI ResponsesRequest to ChatCompletionRequest.
 type ResponsesToChatConverter struct {
-	reasoningSplit bool
-	cacheHit       bool
-	shouldStore    bool // Controls whether to store conversation (default: true)
+	reasoningSplit   bool
+	cacheHit         bool
+	shouldStore      bool // Controls whether to store conversation (default: true)
+	maxContextTokens int
+	userID           string // Owner whose conversations may be continued; empty skips the check
 }
 
 // NewResponsesToChatConverter creates a new converter for Responses to Chat format.
@@ -49,6 +55,18 @@ func (c *ResponsesToChatConverter) SetStore(store bool) {
 	c.shouldStore = store
 }
 
+// SetUserID sets the authenticated user continuing a conversation.
+// History from previous_response_id is only used if that user owns it.
+func (c *ResponsesToChatConverter) SetUserID(userID string) {
+	c.userID = userID
+}
+
+// SetMaxContextTokens sets the token budget for history expanded from previous_response_id.
+// The oldest turns are dropped to stay within the budget. 0 means no limit.
+func (c *ResponsesToChatConverter) SetMaxContextTokens(maxTokens int) {
+	c.maxContextTokens = maxTokens
+}
+
 // Convert transforms a ResponsesRequest body to ChatCompletionRequest format.
 func (c *ResponsesToChatConverter) Convert(body []byte) ([]byte, error) {
 	var req types.ResponsesRequest
@@ -70,17 +88,16 @@ func (c *ResponsesToChatConverter) convertRequest(req *types.ResponsesRequest) *
 	// Fetch conversation history chain if previous_response_id is provided and store is true
 	// In ZDR mode (store:false), we skip the DB chain walk and rely on encrypted_reasoning
 	if req.PreviousResponseID != "" && c.shouldStore {
-		// TODO: Wire userID from request headers for proper ownership validation
-		chain, err := conversation.WalkChainFromDefaultWithOwnership(req.PreviousResponseID, "")
+		chain, err := conversation.WalkChainFromDefaultWithOwnership(req.PreviousResponseID, c.userID)
 		if err != nil {
 			// Ownership error - return nil to trigger warning below
 			logging.InfoMsg("Warning: Conversation access denied: %s", err.Error())
 		} else if len(chain) > 0 {
 			// Mark cache hit
 			c.cacheHit = true
-			// Prepend all conversations in the chain (oldest first)
+			// Prepend the chain (oldest first), trimmed to the token budget
+			chain, req.Input = expandHistory(chain, req.Input, c.maxContextTokens)
 			for _, hist := range chain {
-				req.Input = prependHistoryToInput(hist, req.Input)
 				// Capture reasoning_item_id from the most recent conversation
 				// (the last one in the chain, which is the most recent turn)
 				if hist.ReasoningItemID != "" {
@@ -725,8 +742,17 @@ type ResponsesToChatTransformer struct {
 	contentBuilder strings.Builder
 
 	finishReason string
+	doneSent     bool
 
 	toolNames map[string]string
+
+	// Tool call extraction from reasoning text (Kimi/GLM-5 markup)
+	extractor         *reasoningToolCallExtractor
+	kimi              bool
+	glm5              bool
+	extractedToolCall bool
+
+	ctx context.Context
 }
 
 type responsesToolCallState struct {
@@ -745,6 +771,25 @@ func NewResponsesToChatTransformer(w io.Writer) *ResponsesToChatTransformer {
 	}
 }
 
+// SetContext sets the request context, whose lifecycle events extracted tool calls are published to.
+func (t *ResponsesToChatTransformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+}
+
+// SetKimiToolCallTransform enables or disables Kimi tool call extraction from reasoning text.
+// Extracted tool calls are emitted as tool_calls deltas.
+func (t *ResponsesToChatTransformer) SetKimiToolCallTransform(enabled bool) {
+	t.kimi = enabled
+	t.extractor = newReasoningToolCallExtractor(t.kimi, t.glm5)
+}
+
+// SetGLM5ToolCallTransform enables or disables GLM-5 XML tool call extraction from reasoning text.
+// Extracted tool calls are emitted as tool_calls deltas.
+func (t *ResponsesToChatTransformer) SetGLM5ToolCallTransform(enabled bool) {
+	t.glm5 = enabled
+	t.extractor = newReasoningToolCallExtractor(t.kimi, t.glm5)
+}
+
 // Transform processes a Responses API SSE event and converts it to Chat Completions format.
 func (t *ResponsesToChatTransformer) Transform(event *sse.Event) error {
 	if event.Data == "" {
@@ -779,7 +824,7 @@ func (t *ResponsesToChatTransformer) handleEvent(event *types.ResponsesStreamEve
 		return t.handleOutputTextDelta(event)
 	case "response.reasoning_summary_part.added":
 		return t.handleReasoningSummaryPartAdded(event)
-	case "response.reasoning_summary_text.delta":
+	case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
 		return t.handleReasoningSummaryTextDelta(event)
 	case "response.reasoning_summary_text.done":
 		return t.handleReasoningSummaryTextDone(event)
@@ -822,8 +867,13 @@ func (t *ResponsesToChatTransformer) handleOutputItemAdded(event *types.Response
 
 	switch event.OutputItem.Type {
 	case "function_call":
+		// Chat clients echo the ID back as tool_call_id, which must match call_id
+		id := event.OutputItem.CallID
+		if id == "" {
+			id = event.OutputItem.ID
+		}
 		t.currentToolCall = &responsesToolCallState{
-			id:   event.OutputItem.ID,
+			id:   id,
 			name: event.OutputItem.Name,
 		}
 		t.toolNames[event.OutputItem.ID] = event.OutputItem.Name
@@ -842,6 +892,9 @@ func (t *ResponsesToChatTransformer) handleOutputTextDelta(event *types.Response
 	if event.Delta == "" {
 		return nil
 	}
+	if err := t.finishReasoning(); err != nil {
+		return err
+	}
 
 	t.contentBuilder.WriteString(event.Delta)
 
@@ -914,6 +967,7 @@ func (t *ResponsesToChatTransformer) handleOutputItemDone(event *types.Responses
 	case "reasoning":
 		// Clear reasoning state
 		t.currentReasoningID = ""
+		return t.finishReasoning()
 	}
 
 	return nil
@@ -921,6 +975,13 @@ func (t *ResponsesToChatTransformer) handleOutputItemDone(event *types.Responses
 
 // handleResponseCompleted handles response.completed event.
 func (t *ResponsesToChatTransformer) handleResponseCompleted(event *types.ResponsesStreamEvent) error {
+	if err := t.finishReasoning(); err != nil {
+		return err
+	}
+	if t.extractedToolCall {
+		t.finishReason = "tool_calls"
+	}
+
 	// Determine finish reason
 	if event.Response != nil {
 		if len(event.Response.Output) > 0 {
@@ -955,7 +1016,11 @@ func (t *ResponsesToChatTransformer) handleResponseCompleted(event *types.Respon
 		}
 	}
 
-	return t.writeChunk(chunk)
+	if err := t.writeChunk(chunk); err != nil {
+		return err
+	}
+	// Responses streams have no [DONE] marker; Chat clients expect one
+	return t.writeDone()
 }
 
 // handleError handles error events.
@@ -1001,7 +1066,21 @@ func (t *ResponsesToChatTransformer) handleReasoningSummaryTextDelta(event *type
 		return nil
 	}
 
-	t.reasoningBuilder.WriteString(event.Delta)
+	if t.extractor.Enabled() {
+		for _, e := range t.extractor.Parse(event.Delta) {
+			if err := t.writeExtractedEvent(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return t.emitReasoning(event.Delta)
+}
+
+// emitReasoning writes a reasoning delta chunk.
+func (t *ResponsesToChatTransformer) emitReasoning(text string) error {
+	t.reasoningBuilder.WriteString(text)
 
 	// Emit the reasoning delta in the Chat Completions format
 	chunk := t.createChunk()
@@ -1009,7 +1088,7 @@ func (t *ResponsesToChatTransformer) handleReasoningSummaryTextDelta(event *type
 		{
 			Index: t.contentIndex,
 			Delta: types.Delta{
-				Reasoning: event.Delta,
+				Reasoning: text,
 			},
 		},
 	}
@@ -1017,6 +1096,64 @@ func (t *ResponsesToChatTransformer) handleReasoningSummaryTextDelta(event *type
 	return t.writeChunk(chunk)
 }
 
+// writeExtractedEvent converts a parser event from reasoning text to Chat chunks.
+// Extracted tool calls share the index space of regular function_call items.
+func (t *ResponsesToChatTransformer) writeExtractedEvent(e toolcall.Event) error {
+	switch e.Type {
+	case toolcall.EventContent:
+		if e.Text == "" {
+			return nil
+		}
+		return t.emitReasoning(e.Text)
+	case toolcall.EventToolStart:
+		events.FromContext(t.ctx).ToolCallExtracted(e.Name)
+		t.extractedToolCall = true
+		t.toolCallIndex++
+		return t.writeToolCallDelta(types.ToolCall{
+			Index:    t.toolCallIndex - 1,
+			ID:       e.ID,
+			Type:     "function",
+			Function: types.Function{Name: e.Name},
+		})
+	case toolcall.EventToolArgs:
+		if e.Args == "" || t.toolCallIndex == 0 {
+			return nil
+		}
+		return t.writeToolCallDelta(types.ToolCall{
+			Index:    t.toolCallIndex - 1,
+			Function: types.Function{Arguments: e.Args},
+		})
+	}
+	return nil
+}
+
+// writeToolCallDelta writes a chunk carrying a single tool call delta.
+func (t *ResponsesToChatTransformer) writeToolCallDelta(tc types.ToolCall) error {
+	chunk := t.createChunk()
+	chunk.Choices = []types.Choice{
+		{
+			Index: 0,
+			Delta: types.Delta{
+				ToolCalls: []types.ToolCall{tc},
+			},
+		},
+	}
+	return t.writeChunk(chunk)
+}
+
+// finishReasoning drains the tool call extractor when reasoning ends.
+func (t *ResponsesToChatTransformer) finishReasoning() error {
+	if !t.extractor.Enabled() {
+		return nil
+	}
+	for _, e := range t.extractor.Flush() {
+		if err := t.writeExtractedEvent(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // handleReasoningSummaryTextDone handles response.reasoning_summary_text.done event.
 func (t *ResponsesToChatTransformer) handleReasoningSummaryTextDone(event *types.ResponsesStreamEvent) error {
 	// Reasoning text is complete, clear state
@@ -1028,6 +1165,9 @@ func (t *ResponsesToChatTransformer) handleReasoningSummaryTextDone(event *types
 
 // handleResponseIncomplete handles response.incomplete event.
 func (t *ResponsesToChatTransformer) handleResponseIncomplete(event *types.ResponsesStreamEvent) error {
+	if err := t.finishReasoning(); err != nil {
+		return err
+	}
 	t.finishReason = "length"
 
 	// Send final chunk with finish_reason
@@ -1049,7 +1189,10 @@ func (t *ResponsesToChatTransformer) handleResponseIncomplete(event *types.Respo
 		}
 	}
 
-	return t.writeChunk(chunk)
+	if err := t.writeChunk(chunk); err != nil {
+		return err
+	}
+	return t.writeDone()
 }
 
 // handleResponseFailed handles response.failed event.
@@ -1119,8 +1262,12 @@ func (t *ResponsesToChatTransformer) writeData(data []byte) error {
 	return err
 }
 
-// writeDone writes the [DONE] marker.
+// writeDone writes the [DONE] marker once.
 func (t *ResponsesToChatTransformer) writeDone() error {
+	if t.doneSent {
+		return nil
+	}
+	t.doneSent = true
 	_, err := t.w.Write([]byte("data: [DONE]\n\n"))
 	return err
 }
@@ -1146,3 +1293,20 @@ func (t *ResponsesToChatTransformer) Initialize() error {
 func (t *ResponsesToChatTransformer) HandleCancel() error {
 	return nil
 }
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *ResponsesToChatTransformer) State() string {
+	switch {
+	case t.doneSent:
+		return transform.StateDone
+	case t.currentToolCall != nil, t.extractor.InToolCall():
+		return transform.StateToolCall
+	case t.currentReasoningID != "":
+		return transform.StateReasoning
+	case t.contentBuilder.Len() > 0:
+		return transform.StateText
+	default:
+		return transform.StateWaiting
+	}
+}
@@ -3128,3 +3128,120 @@ func TestResponsesToChatConverter_SetReasoningSplit(t *testing.T) {
 		}
 	})
 }
+
+// TestResponsesToChatTransformer_UsesCallID tests that tool call IDs use call_id,
+// which Chat clients echo back as tool_call_id.
+func TestResponsesToChatTransformer_UsesCallID(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToChatTransformer(&buf)
+
+	for _, data := range []string{
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"function_call","id":"fc_1","call_id":"call_abc","name":"get_weather"}}`,
+		`{"type":"response.function_call_arguments.delta","output_index":0,"item_id":"fc_1","delta":"{}"}`,
+	} {
+		if err := transformer.Transform(&sse.Event{Data: data}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"id":"call_abc"`) {
+		t.Errorf("Expected call_id as tool call ID, got: %s", output)
+	}
+	if strings.Contains(output, `"id":"fc_1"`) {
+		t.Errorf("Item ID should not be used as tool call ID, got: %s", output)
+	}
+}
+
+// TestResponsesToChatTransformer_DoneAfterCompleted tests that [DONE] is emitted
+// exactly once after response.completed, even if the upstream also sends it.
+func TestResponsesToChatTransformer_DoneAfterCompleted(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToChatTransformer(&buf)
+
+	for _, data := range []string{
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[]}}`,
+		"[DONE]",
+	} {
+		if err := transformer.Transform(&sse.Event{Data: data}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+
+	if got := strings.Count(buf.String(), "data: [DONE]"); got != 1 {
+		t.Errorf("Expected exactly one [DONE], got %d: %s", got, buf.String())
+	}
+}
+
+// TestResponsesToChatTransformer_GLM5ToolCallInReasoning tests extraction of GLM-5
+// tool call markup from the reasoning stream.
+func TestResponsesToChatTransformer_GLM5ToolCallInReasoning(t *testing.T) {
+	var buf bytes.Buffer
+	transformer := NewResponsesToChatTransformer(&buf)
+	transformer.SetGLM5ToolCallTransform(true)
+
+	reasoning, _ := json.Marshal("Checking.<tool_call>get_weather<arg_key>city</arg_key><arg_value>Paris</arg_value></tool_call>")
+	for _, data := range []string{
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"item_id":"rs_1","delta":` + string(reasoning) + `}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","output":[]}}`,
+	} {
+		if err := transformer.Transform(&sse.Event{Data: data}); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "<tool_call>") {
+		t.Errorf("Tool call markup leaked into output: %s", output)
+	}
+	if !strings.Contains(output, `"name":"get_weather"`) {
+		t.Errorf("Expected extracted tool call name, got: %s", output)
+	}
+	if !strings.Contains(output, `"arguments":"{\"city\":\"Paris\"}"`) {
+		t.Errorf("Expected extracted arguments, got: %s", output)
+	}
+	if !strings.Contains(output, `"reasoning":"Checking."`) {
+		t.Errorf("Expected reasoning before markup, got: %s", output)
+	}
+	if !strings.Contains(output, `"finish_reason":"tool_calls"`) {
+		t.Errorf("Expected tool_calls finish reason, got: %s", output)
+	}
+}
+
+func TestResponsesToChatConverter_PreviousResponseIDOwnership(t *testing.T) {
+	oldStore := conversation.DefaultStore
+	conversation.DefaultStore = conversation.NewStore(conversation.Config{})
+	t.Cleanup(func() {
+		conversation.DefaultStore = oldStore
+	})
+
+	conversation.StoreInDefault(&conversation.Conversation{
+		ID:     "resp_owned",
+		UserID: "alice",
+		Input:  []types.InputItem{{Type: "message", Role: "user", Content: "Alice's question"}},
+	})
+	input := []byte(`{"model": "gpt-4o", "input": "Continue", "previous_response_id": "resp_owned"}`)
+
+	for _, tt := range []struct {
+		user         string
+		wantMessages int
+	}{
+		{user: "alice", wantMessages: 2},
+		{user: "bob", wantMessages: 1},
+	} {
+		converter := NewResponsesToChatConverter()
+		converter.SetUserID(tt.user)
+		output, err := converter.Convert(input)
+		if err != nil {
+			t.Fatalf("Convert returned error: %v", err)
+		}
+		var req types.ChatCompletionRequest
+		if err := json.Unmarshal(output, &req); err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		if len(req.Messages) != tt.wantMessages {
+			t.Errorf("user %s: expected %d messages, got %d", tt.user, tt.wantMessages, len(req.Messages))
+		}
+	}
+}
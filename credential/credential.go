@@ -0,0 +1,234 @@
+// Package credential fetches provider API keys from files, helper commands and
+// OAuth2 client-credentials token endpoints. Fetched keys are cached, fetched
+// again shortly before they expire, and dropped when the upstream rejects them.
+package credential
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-proxy/config"
+	"ai-proxy/logging"
+)
+
+// DefaultRefreshInterval is how long a key without an expiry is cached when
+// the provider does not configure refresh_interval.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// DefaultRefreshBefore is how long before its expiry a token is fetched again
+// when the provider does not configure refresh_before.
+const DefaultRefreshBefore = time.Minute
+
+// DefaultCommandTimeout bounds a credential command run when the provider does
+// not configure command_timeout.
+const DefaultCommandTimeout = 10 * time.Second
+
+// failedRefreshDelay is how long a still-valid cached key is served after a
+// failed refresh before the next fetch is attempted.
+const failedRefreshDelay = 5 * time.Second
+
+// timeNow returns the current time. Replaced in tests.
+var timeNow = time.Now
+
+// fetchFunc fetches a fresh key.
+//
+// @return the key, and its expiry; the zero time if the key does not expire
+type fetchFunc func(ctx context.Context) (string, time.Time, error)
+
+// Source is the credential source of a provider. It caches the fetched key
+// and fetches it again once it is due for refresh: after the refresh interval
+// for keys without an expiry, or refresh_before ahead of a token's expiry.
+// If a refresh fails while the cached key has not expired yet, the cached key
+// keeps being served.
+//
+// Thread Safety: Safe for concurrent use. Concurrent callers needing a fresh
+// key wait for a single fetch.
+type Source struct {
+	provider        string
+	fetch           fetchFunc
+	refreshInterval time.Duration
+	refreshBefore   time.Duration
+
+	mu        sync.Mutex
+	key       string
+	expiresAt time.Time // zero if the key does not expire
+	refreshAt time.Time // when the key is fetched again
+}
+
+// New creates the credential source of a provider.
+// Durations are assumed to be validated by the config loader; invalid values
+// fall back to the defaults.
+//
+// @param provider - the provider name
+// @param cfg - the credential configuration; nil means the provider has none
+// @return *Source - the source, nil if cfg is nil
+func New(provider string, cfg *config.CredentialConfig) *Source {
+	if cfg == nil {
+		return nil
+	}
+
+	s := &Source{
+		provider:        provider,
+		refreshInterval: parseDurationOr(cfg.RefreshInterval, DefaultRefreshInterval),
+		refreshBefore:   parseDurationOr(cfg.RefreshBefore, DefaultRefreshBefore),
+	}
+	switch cfg.Type {
+	case config.CredentialFile:
+		s.fetch = fileFetcher(cfg.Path)
+	case config.CredentialCommand:
+		s.fetch = commandFetcher(cfg.Command, parseDurationOr(cfg.CommandTimeout, DefaultCommandTimeout))
+	case config.CredentialOAuth2:
+		s.fetch = oauth2Fetcher(cfg)
+	default:
+		s.fetch = func(context.Context) (string, time.Time, error) {
+			return "", time.Time{}, fmt.Errorf("unknown credential type %q", cfg.Type)
+		}
+	}
+	return s
+}
+
+// parseDurationOr parses a duration, returning def if empty or invalid.
+func parseDurationOr(value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// Provider returns the name of the source's provider.
+func (s *Source) Provider() string {
+	return s.provider
+}
+
+// Token returns the provider's key, fetching it if none is cached or the
+// cached one is due for refresh.
+//
+// @param ctx - bounds the fetch, if one is needed
+// @return string - the key, never empty on success
+// @return error - if the fetch failed and no unexpired key is cached
+func (s *Source) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := timeNow()
+	if s.key != "" && now.Before(s.refreshAt) {
+		return s.key, nil
+	}
+
+	key, expiresAt, err := s.fetch(ctx)
+	if err == nil && key == "" {
+		err = fmt.Errorf("fetched key is empty")
+	}
+	if err != nil {
+		if s.key != "" && (s.expiresAt.IsZero() || now.Before(s.expiresAt)) {
+			logging.ErrorMsg("Refreshing credential of provider %s failed, using cached key: %v", s.provider, err)
+			s.refreshAt = now.Add(failedRefreshDelay)
+			return s.key, nil
+		}
+		return "", fmt.Errorf("credential of provider %s: %w", s.provider, err)
+	}
+
+	s.key = key
+	s.expiresAt = expiresAt
+	if expiresAt.IsZero() {
+		s.refreshAt = now.Add(s.refreshInterval)
+	} else {
+		// Short-lived tokens are refreshed halfway through their lifetime at the latest
+		before := s.refreshBefore
+		if half := expiresAt.Sub(now) / 2; before > half {
+			before = half
+		}
+		s.refreshAt = expiresAt.Add(-before)
+	}
+	return key, nil
+}
+
+// Invalidate drops the cached key after the upstream rejected it, so the next
+// Token call fetches a fresh one. Keys fetched since the rejected one was
+// handed out are kept, so concurrent rejections cause a single fetch.
+//
+// @param rejected - the key the upstream rejected
+func (s *Source) Invalidate(rejected string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rejected == "" || s.key != rejected {
+		return
+	}
+	s.key = ""
+	s.expiresAt = time.Time{}
+	s.refreshAt = time.Time{}
+}
+
+// fileFetcher returns a fetcher reading the key from a file. Rotated files
+// are picked up at the next refresh.
+func fileFetcher(path string) fetchFunc {
+	return func(context.Context) (string, time.Time, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return strings.TrimSpace(string(data)), time.Time{}, nil
+	}
+}
+
+// commandFetcher returns a fetcher running a command and using its standard
+// output as the key. The command is run without a shell.
+func commandFetcher(argv []string, timeout time.Duration) fetchFunc {
+	return func(ctx context.Context) (string, time.Time, error) {
+		if len(argv) == 0 {
+			return "", time.Time{}, fmt.Errorf("no command configured")
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", time.Time{}, fmt.Errorf("command %s: %w: %s", argv[0], err, msg)
+			}
+			return "", time.Time{}, fmt.Errorf("command %s: %w", argv[0], err)
+		}
+		return strings.TrimSpace(stdout.String()), time.Time{}, nil
+	}
+}
+
+// Registry holds the credential sources of all providers that configure one.
+//
+// Thread Safety: Safe for concurrent use; the source set is fixed at creation.
+type Registry struct {
+	sources map[string]*Source
+}
+
+// NewRegistry creates one source per provider with a credential configuration.
+//
+// @param providers - the configured providers
+// @return *Registry - the registry
+func NewRegistry(providers []config.Provider) *Registry {
+	r := &Registry{sources: make(map[string]*Source)}
+	for _, p := range providers {
+		if s := New(p.Name, p.Credential); s != nil {
+			r.sources[p.Name] = s
+		}
+	}
+	return r
+}
+
+// Get returns the credential source of a provider.
+//
+// @return *Source - the source, nil if the provider has none or is unknown
+func (r *Registry) Get(provider string) *Source {
+	return r.sources[provider]
+}
@@ -0,0 +1,281 @@
+package credential
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// withClock fixes timeNow for the duration of a test and returns a function
+// that advances it.
+func withClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+// countingSource returns a source whose fetcher returns the keys in order,
+// expiring after the given lifetime (0 for none), and a pointer to the number
+// of fetches.
+func countingSource(lifetime time.Duration, keys ...string) (*Source, *int) {
+	fetches := 0
+	s := &Source{
+		provider:        "p",
+		refreshInterval: DefaultRefreshInterval,
+		refreshBefore:   DefaultRefreshBefore,
+		fetch: func(context.Context) (string, time.Time, error) {
+			key := keys[fetches%len(keys)]
+			fetches++
+			if lifetime == 0 {
+				return key, time.Time{}, nil
+			}
+			return key, timeNow().Add(lifetime), nil
+		},
+	}
+	return s, &fetches
+}
+
+func mustToken(t *testing.T, s *Source) string {
+	t.Helper()
+	key, err := s.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token returned error: %v", err)
+	}
+	return key
+}
+
+func TestNew_Nil(t *testing.T) {
+	if s := New("p", nil); s != nil {
+		t.Errorf("New(nil) = %v, want nil", s)
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	s := New("p", &config.CredentialConfig{Type: config.CredentialFile, Path: "key"})
+	if s.refreshInterval != DefaultRefreshInterval || s.refreshBefore != DefaultRefreshBefore {
+		t.Errorf("unexpected defaults: interval=%v before=%v", s.refreshInterval, s.refreshBefore)
+	}
+
+	s = New("p", &config.CredentialConfig{Type: config.CredentialFile, Path: "key", RefreshInterval: "30s", RefreshBefore: "5m"})
+	if s.refreshInterval != 30*time.Second || s.refreshBefore != 5*time.Minute {
+		t.Errorf("unexpected durations: interval=%v before=%v", s.refreshInterval, s.refreshBefore)
+	}
+}
+
+func TestSource_CachesUntilRefreshInterval(t *testing.T) {
+	advance := withClock(t)
+	s, fetches := countingSource(0, "k1", "k2")
+
+	if key := mustToken(t, s); key != "k1" {
+		t.Fatalf("Token() = %q, want k1", key)
+	}
+	advance(DefaultRefreshInterval - time.Second)
+	if key := mustToken(t, s); key != "k1" || *fetches != 1 {
+		t.Errorf("expected the cached key, got %q after %d fetches", key, *fetches)
+	}
+	advance(time.Second)
+	if key := mustToken(t, s); key != "k2" || *fetches != 2 {
+		t.Errorf("expected a refreshed key, got %q after %d fetches", key, *fetches)
+	}
+}
+
+func TestSource_RefreshesBeforeExpiry(t *testing.T) {
+	advance := withClock(t)
+	s, fetches := countingSource(time.Hour, "k1", "k2")
+
+	mustToken(t, s)
+	advance(time.Hour - DefaultRefreshBefore - time.Second)
+	if key := mustToken(t, s); key != "k1" {
+		t.Errorf("expected the cached token, got %q", key)
+	}
+	advance(time.Second)
+	if key := mustToken(t, s); key != "k2" || *fetches != 2 {
+		t.Errorf("expected a token refreshed %v before expiry, got %q after %d fetches", DefaultRefreshBefore, key, *fetches)
+	}
+}
+
+func TestSource_ShortLivedTokenRefreshedHalfway(t *testing.T) {
+	advance := withClock(t)
+	s, fetches := countingSource(time.Minute, "k1", "k2")
+
+	mustToken(t, s)
+	advance(29 * time.Second)
+	mustToken(t, s)
+	if *fetches != 1 {
+		t.Fatalf("expected the cached token before half its lifetime, got %d fetches", *fetches)
+	}
+	advance(time.Second)
+	mustToken(t, s)
+	if *fetches != 2 {
+		t.Errorf("expected a refresh at half its lifetime, got %d fetches", *fetches)
+	}
+}
+
+func TestSource_Invalidate(t *testing.T) {
+	withClock(t)
+	s, fetches := countingSource(0, "k1", "k2")
+
+	mustToken(t, s)
+	// A key other than the cached one was rejected: keep the cache
+	s.Invalidate("old")
+	if key := mustToken(t, s); key != "k1" || *fetches != 1 {
+		t.Errorf("expected the cached key, got %q after %d fetches", key, *fetches)
+	}
+
+	s.Invalidate("k1")
+	s.Invalidate("k1")
+	if key := mustToken(t, s); key != "k2" || *fetches != 2 {
+		t.Errorf("expected one refetch, got %q after %d fetches", key, *fetches)
+	}
+}
+
+func TestSource_FailedRefreshServesCachedKey(t *testing.T) {
+	advance := withClock(t)
+	fail := false
+	fetches := 0
+	s := &Source{
+		provider:        "p",
+		refreshInterval: time.Minute,
+		refreshBefore:   time.Minute,
+		fetch: func(context.Context) (string, time.Time, error) {
+			fetches++
+			if fail {
+				return "", time.Time{}, os.ErrNotExist
+			}
+			return "k1", timeNow().Add(10 * time.Minute), nil
+		},
+	}
+
+	mustToken(t, s)
+	fail = true
+	advance(9 * time.Minute)
+	if key := mustToken(t, s); key != "k1" {
+		t.Errorf("expected the unexpired cached key, got %q", key)
+	}
+	// The failed refresh is not retried on every call
+	mustToken(t, s)
+	if fetches != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetches)
+	}
+
+	advance(time.Minute)
+	if _, err := s.Token(context.Background()); err == nil || !strings.Contains(err.Error(), "provider p") {
+		t.Errorf("expected an error once the key expired, got %v", err)
+	}
+}
+
+func TestSource_EmptyKey(t *testing.T) {
+	s, _ := countingSource(0, "")
+	if _, err := s.Token(context.Background()); err == nil {
+		t.Error("expected an error for an empty key")
+	}
+}
+
+func TestSource_ConcurrentCallersShareOneFetch(t *testing.T) {
+	s, fetches := countingSource(0, "k1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if key, err := s.Token(context.Background()); err != nil || key != "k1" {
+				t.Errorf("Token() = %q, %v", key, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if *fetches != 1 {
+		t.Errorf("expected a single fetch, got %d", *fetches)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	advance := withClock(t)
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("  sk-first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := New("p", &config.CredentialConfig{Type: config.CredentialFile, Path: path, RefreshInterval: "1m"})
+
+	if key := mustToken(t, s); key != "sk-first" {
+		t.Fatalf("Token() = %q, want sk-first", key)
+	}
+
+	// The rotated file is read at the next refresh
+	if err := os.WriteFile(path, []byte("sk-second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if key := mustToken(t, s); key != "sk-first" {
+		t.Errorf("expected the cached key before the refresh, got %q", key)
+	}
+	advance(time.Minute)
+	if key := mustToken(t, s); key != "sk-second" {
+		t.Errorf("expected the rotated key, got %q", key)
+	}
+}
+
+func TestFileSource_Missing(t *testing.T) {
+	s := New("p", &config.CredentialConfig{Type: config.CredentialFile, Path: filepath.Join(t.TempDir(), "missing")})
+	if _, err := s.Token(context.Background()); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	s := New("p", &config.CredentialConfig{Type: config.CredentialCommand, Command: []string{"echo", "sk-from-vault"}})
+	if key := mustToken(t, s); key != "sk-from-vault" {
+		t.Errorf("Token() = %q, want sk-from-vault", key)
+	}
+}
+
+func TestCommandSource_Failure(t *testing.T) {
+	s := New("p", &config.CredentialConfig{
+		Type:    config.CredentialCommand,
+		Command: []string{"sh", "-c", "echo permission denied >&2; exit 2"},
+	})
+	_, err := s.Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected the command's stderr in the error, got %v", err)
+	}
+}
+
+func TestCommandSource_Timeout(t *testing.T) {
+	s := New("p", &config.CredentialConfig{
+		Type:           config.CredentialCommand,
+		Command:        []string{"sleep", "5"},
+		CommandTimeout: "50ms",
+	})
+	start := time.Now()
+	if _, err := s.Token(context.Background()); err == nil {
+		t.Error("expected an error for a command exceeding its timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("command was not stopped at its timeout, took %v", elapsed)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry([]config.Provider{
+		{Name: "static", APIKey: "sk"},
+		{Name: "vault", Credential: &config.CredentialConfig{Type: config.CredentialCommand, Command: []string{"echo", "k"}}},
+	})
+	if s := r.Get("static"); s != nil {
+		t.Errorf("expected no source for a provider without credential, got %v", s)
+	}
+	if s := r.Get("vault"); s == nil || s.Provider() != "vault" {
+		t.Errorf("expected the vault source, got %v", s)
+	}
+	if s := r.Get("unknown"); s != nil {
+		t.Errorf("expected no source for an unknown provider, got %v", s)
+	}
+}
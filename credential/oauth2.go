@@ -0,0 +1,103 @@
+// Package credential fetches provider API keys from files, helper commands and
+// OAuth2 client-credentials token endpoints.
+// This file implements the OAuth2 client-credentials grant (RFC 6749, section 4.4).
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// maxTokenResponseBytes limits how much of a token endpoint response is read.
+const maxTokenResponseBytes = 1 << 20
+
+// httpClient sends token requests.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// tokenResponse is the successful response of a token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the token lifetime in seconds. Some endpoints send it as a string.
+	ExpiresIn json.Number `json:"expires_in"`
+}
+
+// tokenError is the error response of a token endpoint.
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oauth2Fetcher returns a fetcher requesting access tokens with the
+// client-credentials grant. The client authenticates with HTTP Basic
+// authentication, and the token expires after the returned expires_in.
+func oauth2Fetcher(cfg *config.CredentialConfig) fetchFunc {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	encoded := form.Encode()
+	tokenURL, clientID, clientSecret := cfg.TokenURL, cfg.ClientID, cfg.ClientSecret
+
+	return func(ctx context.Context) (string, time.Time, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(encoded))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		// RFC 6749 section 2.3.1: the credentials are form-encoded before Basic encoding
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+		requestedAt := timeNow()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("token request: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseBytes))
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("token response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var te tokenError
+			if json.Unmarshal(body, &te) == nil && te.Error != "" {
+				if te.ErrorDescription != "" {
+					return "", time.Time{}, fmt.Errorf("token endpoint returned %d: %s: %s", resp.StatusCode, te.Error, te.ErrorDescription)
+				}
+				return "", time.Time{}, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, te.Error)
+			}
+			return "", time.Time{}, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+		}
+
+		var tr tokenResponse
+		if err := json.Unmarshal(body, &tr); err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid token response: %w", err)
+		}
+		if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+			return "", time.Time{}, fmt.Errorf("unsupported token type %q", tr.TokenType)
+		}
+
+		var expiresAt time.Time
+		if tr.ExpiresIn != "" {
+			seconds, err := tr.ExpiresIn.Int64()
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("invalid expires_in %q", tr.ExpiresIn)
+			}
+			// Measured from the request, so the token never outlives its cached expiry
+			if seconds > 0 {
+				expiresAt = requestedAt.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+		return tr.AccessToken, expiresAt, nil
+	}
+}
@@ -0,0 +1,175 @@
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// stubTokenServer is a local OAuth2 token endpoint issuing numbered tokens to
+// the client "proxy" with secret "s3cret/+".
+type stubTokenServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	// expiresIn is the raw JSON value of expires_in; empty leaves it out.
+	expiresIn string
+	// status, if set, is returned instead of a token.
+	status int
+	// lastScope is the scope of the last token request.
+	lastScope string
+}
+
+func newStubTokenServer(t *testing.T) *stubTokenServer {
+	t.Helper()
+	s := &stubTokenServer{expiresIn: "3600"}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_request"}`)
+			return
+		}
+		id, secret, ok := r.BasicAuth()
+		// Basic credentials are form-encoded first
+		if !ok || id != "proxy" || secret != "s3cret%2F%2B" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"Client authentication failed"}`)
+			return
+		}
+		if r.PostFormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"unsupported_grant_type"}`)
+			return
+		}
+		s.lastScope = r.PostFormValue("scope")
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			fmt.Fprint(w, `upstream unavailable`)
+			return
+		}
+
+		resp := map[string]interface{}{"access_token": fmt.Sprintf("token-%d", n), "token_type": "Bearer"}
+		if s.expiresIn != "" {
+			resp["expires_in"] = json.RawMessage(s.expiresIn)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubTokenServer) config() *config.CredentialConfig {
+	return &config.CredentialConfig{
+		Type:         config.CredentialOAuth2,
+		TokenURL:     s.URL + "/oauth2/token",
+		ClientID:     "proxy",
+		ClientSecret: "s3cret/+",
+		Scopes:       []string{"llm.invoke", "llm.read"},
+	}
+}
+
+func TestOAuth2Source(t *testing.T) {
+	advance := withClock(t)
+	server := newStubTokenServer(t)
+	s := New("p", server.config())
+
+	if key := mustToken(t, s); key != "token-1" {
+		t.Fatalf("Token() = %q, want token-1", key)
+	}
+	if server.lastScope != "llm.invoke llm.read" {
+		t.Errorf("expected space-separated scopes, got %q", server.lastScope)
+	}
+
+	advance(time.Hour - DefaultRefreshBefore - time.Second)
+	if key := mustToken(t, s); key != "token-1" || server.requests.Load() != 1 {
+		t.Errorf("expected the cached token, got %q after %d requests", key, server.requests.Load())
+	}
+	advance(time.Second)
+	if key := mustToken(t, s); key != "token-2" {
+		t.Errorf("expected a token refreshed before expiry, got %q", key)
+	}
+
+	// A token rejected by the upstream is fetched again
+	s.Invalidate("token-2")
+	if key := mustToken(t, s); key != "token-3" {
+		t.Errorf("expected a fresh token after invalidation, got %q", key)
+	}
+}
+
+func TestOAuth2Source_ExpiresIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn string
+		// cachedFor is how long the token is served from the cache.
+		cachedFor time.Duration
+	}{
+		{"number", `600`, 600*time.Second - DefaultRefreshBefore},
+		{"string", `"600"`, 600*time.Second - DefaultRefreshBefore},
+		{"missing", ``, DefaultRefreshInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance := withClock(t)
+			server := newStubTokenServer(t)
+			server.expiresIn = tt.expiresIn
+			s := New("p", server.config())
+
+			mustToken(t, s)
+			advance(tt.cachedFor - time.Second)
+			mustToken(t, s)
+			if n := server.requests.Load(); n != 1 {
+				t.Errorf("expected the cached token, got %d requests", n)
+			}
+			advance(time.Second)
+			mustToken(t, s)
+			if n := server.requests.Load(); n != 2 {
+				t.Errorf("expected a refresh after %v, got %d requests", tt.cachedFor, n)
+			}
+		})
+	}
+}
+
+func TestOAuth2Source_Errors(t *testing.T) {
+	server := newStubTokenServer(t)
+
+	cfg := server.config()
+	cfg.ClientSecret = "wrong"
+	_, err := New("p", cfg).Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "401: invalid_client: Client authentication failed") {
+		t.Errorf("expected the token endpoint's error, got %v", err)
+	}
+
+	server.status = http.StatusServiceUnavailable
+	_, err = New("p", server.config()).Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "token endpoint returned 503") {
+		t.Errorf("expected the token endpoint's status, got %v", err)
+	}
+
+	cfg = server.config()
+	cfg.TokenURL = "http://127.0.0.1:1/token"
+	if _, err := New("p", cfg).Token(context.Background()); err == nil {
+		t.Error("expected an error for an unreachable token endpoint")
+	}
+}
+
+func TestOAuth2Source_UnsupportedTokenType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token":"t","token_type":"mac","expires_in":60}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.CredentialConfig{Type: config.CredentialOAuth2, TokenURL: server.URL, ClientID: "a", ClientSecret: "b"}
+	if _, err := New("p", cfg).Token(context.Background()); err == nil || !strings.Contains(err.Error(), "mac") {
+		t.Errorf("expected an unsupported token type error, got %v", err)
+	}
+}
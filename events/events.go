@@ -0,0 +1,255 @@
+// Package events provides an in-process bus of request lifecycle events, which
+// the /admin/events endpoint streams to operators and `ai-proxy tail` renders.
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types, in the order a request usually goes through them.
+const (
+	// RequestReceived is published once a request has been validated and admitted.
+	RequestReceived = "request.received"
+	// RouteResolved is published for the route a request is sent to, and again
+	// for each fallback route.
+	RouteResolved = "route.resolved"
+	// UpstreamConnected is published when an upstream accepted the request.
+	UpstreamConnected = "upstream.connected"
+	// FirstToken is published when the first transformed event reached a streaming client.
+	FirstToken = "first_token"
+	// ToolCallExtracted is published for each tool call parsed from tool call
+	// markup in the model output, such as Kimi's or GLM-5's.
+	ToolCallExtracted = "tool_call.extracted"
+	// WebSearchExecuted is published for each web search the proxy executed.
+	WebSearchExecuted = "web_search.executed"
+	// UpstreamError is published when an upstream attempt fails or its stream breaks.
+	UpstreamError = "upstream.error"
+	// ClientDisconnected is published when the client went away before the response was complete.
+	ClientDisconnected = "client.disconnected"
+	// RequestCompleted is published last, with the final status and usage.
+	RequestCompleted = "request.completed"
+)
+
+// Event is a lifecycle event of a proxied request.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// RequestID is the request's ID in /admin/streams.
+	RequestID string `json:"request_id"`
+	// Session is the X-Session-ID header of the request, if it had one.
+	Session string `json:"session,omitempty"`
+	// Client names the authenticated client key, empty without authentication.
+	Client string `json:"client,omitempty"`
+	// Protocol is the protocol the client spoke: "anthropic", "openai" or "responses".
+	Protocol      string `json:"protocol,omitempty"`
+	Model         string `json:"model,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	UpstreamModel string `json:"upstream_model,omitempty"`
+	// Elapsed is the time since the request was received, in milliseconds.
+	Elapsed int64 `json:"elapsed_ms"`
+
+	// Status is the upstream status of UpstreamError, or the client status of RequestCompleted.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Tool is the name of the extracted tool call.
+	Tool string `json:"tool,omitempty"`
+	// Query and Results describe an executed web search.
+	Query   string `json:"query,omitempty"`
+	Results int    `json:"results,omitempty"`
+	// Token usage and cost of RequestCompleted.
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+}
+
+// Filter selects the events a subscriber receives. Empty fields match any event.
+type Filter struct {
+	Session string
+	// Model matches the model alias or the upstream model.
+	Model  string
+	Client string
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e *Event) bool {
+	return (f.Session == "" || f.Session == e.Session) &&
+		(f.Model == "" || f.Model == e.Model || f.Model == e.UpstreamModel) &&
+		(f.Client == "" || f.Client == e.Client)
+}
+
+// Bus delivers published events to its subscribers.
+//
+// Thread Safety: Safe for concurrent use.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Default is the bus the proxy publishes its request events to.
+var Default = NewBus()
+
+// Subscribe starts delivering the events that pass f.
+//
+// @param f - selects the events to deliver.
+// @param buffer - how many events may wait for the subscriber; when its buffer
+// is full, further events are dropped rather than slowing requests down.
+// @return *Subscription - to be closed by the subscriber.
+func (b *Bus) Subscribe(f Filter, buffer int) *Subscription {
+	s := &Subscription{bus: b, filter: f, ch: make(chan Event, buffer)}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Publish delivers e to every subscriber whose filter it passes, without blocking.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if !s.filter.Match(&e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Len returns the number of subscribers.
+func (b *Bus) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Disconnect ends every current subscription, closing their channels.
+// Called at shutdown, so subscribers streaming events do not hold the server up.
+func (b *Bus) Disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscription receives the events of a Bus that pass its filter.
+type Subscription struct {
+	bus     *Bus
+	filter  Filter
+	ch      chan Event
+	dropped atomic.Int64
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription is closed or the bus disconnects its subscribers.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription. Safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// Request publishes the events of one request, stamped with its identity.
+// All methods are safe to call on a nil *Request, which publishes nothing.
+//
+// Thread Safety: Safe for concurrent use.
+type Request struct {
+	bus   *Bus
+	start time.Time
+
+	mu       sync.Mutex
+	identity Event
+}
+
+// NewRequest creates the publisher of a request's events.
+//
+// @param bus - the bus to publish to.
+// @param identity - the request's ID, session, client, protocol and route,
+// copied into every event it publishes.
+func NewRequest(bus *Bus, identity Event) *Request {
+	return &Request{bus: bus, start: time.Now(), identity: identity}
+}
+
+// SetRoute changes the route stamped on subsequent events, after a fallback.
+func (r *Request) SetRoute(provider, upstreamModel string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identity.Provider = provider
+	r.identity.UpstreamModel = upstreamModel
+}
+
+// Publish publishes e with the request's identity, time and elapsed time.
+//
+// @param e - the event; its Type and event-specific fields are kept.
+func (r *Request) Publish(e Event) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	id := r.identity
+	r.mu.Unlock()
+
+	now := time.Now()
+	e.Time = now
+	e.Elapsed = now.Sub(r.start).Milliseconds()
+	e.RequestID = id.RequestID
+	e.Session = id.Session
+	e.Client = id.Client
+	e.Protocol = id.Protocol
+	e.Model = id.Model
+	e.Provider = id.Provider
+	e.UpstreamModel = id.UpstreamModel
+	r.bus.Publish(e)
+}
+
+// ToolCallExtracted publishes a ToolCallExtracted event for the named tool.
+func (r *Request) ToolCallExtracted(name string) {
+	r.Publish(Event{Type: ToolCallExtracted, Tool: name})
+}
+
+// requestKey is the context key of a request's *Request.
+type requestKey struct{}
+
+// WithRequest returns a copy of ctx carrying r, so code that only sees the
+// request context, such as transformers, can publish events.
+func WithRequest(ctx context.Context, r *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// FromContext returns the *Request carried by ctx, or nil.
+//
+// @param ctx - may be nil.
+func FromContext(ctx context.Context) *Request {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(requestKey{}).(*Request)
+	return r
+}
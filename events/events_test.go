@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+// receive returns the next event of s, failing if none is waiting.
+func receive(t *testing.T, s *Subscription) Event {
+	t.Helper()
+	select {
+	case e := <-s.Events():
+		return e
+	default:
+		t.Fatal("expected an event")
+		return Event{}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	e := &Event{Session: "s1", Model: "fast", UpstreamModel: "gpt-4o", Client: "alice"}
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{Session: "s1"}, true},
+		{Filter{Session: "s2"}, false},
+		{Filter{Model: "fast"}, true},
+		{Filter{Model: "gpt-4o"}, true},
+		{Filter{Model: "smart"}, false},
+		{Filter{Client: "alice", Model: "fast"}, true},
+		{Filter{Client: "bob", Model: "fast"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(e); got != tt.want {
+			t.Errorf("%+v.Match() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(Filter{}, 1)
+	alice := bus.Subscribe(Filter{Client: "alice"}, 4)
+	if bus.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", bus.Len())
+	}
+
+	bus.Publish(Event{Type: RequestReceived, Client: "alice"})
+	bus.Publish(Event{Type: RequestReceived, Client: "bob"})
+
+	if e := receive(t, alice); e.Client != "alice" {
+		t.Errorf("filtered subscriber got %+v", e)
+	}
+	select {
+	case e := <-alice.Events():
+		t.Errorf("filtered subscriber should not get %+v", e)
+	default:
+	}
+
+	// The full buffer drops the second event instead of blocking
+	if e := receive(t, all); e.Client != "alice" {
+		t.Errorf("subscriber got %+v", e)
+	}
+	if all.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", all.Dropped())
+	}
+
+	all.Close()
+	all.Close()
+	if _, ok := <-all.Events(); ok {
+		t.Error("closed subscription's channel should be closed")
+	}
+	bus.Disconnect()
+	if _, ok := <-alice.Events(); ok {
+		t.Error("Disconnect should close every subscription's channel")
+	}
+	alice.Close()
+	if bus.Len() != 0 {
+		t.Errorf("Len() after Disconnect = %d, want 0", bus.Len())
+	}
+}
+
+func TestRequest_Publish(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(Filter{}, 4)
+	r := NewRequest(bus, Event{RequestID: "req_1", Session: "s1", Client: "alice", Protocol: "anthropic",
+		Model: "fast", Provider: "openai", UpstreamModel: "gpt-4o"})
+
+	r.Publish(Event{Type: RequestReceived, RequestID: "ignored"})
+	e := receive(t, sub)
+	if e.Type != RequestReceived || e.RequestID != "req_1" || e.Session != "s1" || e.Client != "alice" ||
+		e.Protocol != "anthropic" || e.Model != "fast" || e.Provider != "openai" || e.UpstreamModel != "gpt-4o" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if e.Time.IsZero() || e.Elapsed < 0 {
+		t.Errorf("event should be stamped with its time, got %+v", e)
+	}
+
+	r.SetRoute("anthropic", "claude-sonnet")
+	r.Publish(Event{Type: UpstreamError, Status: 503})
+	if e := receive(t, sub); e.Provider != "anthropic" || e.UpstreamModel != "claude-sonnet" || e.Status != 503 {
+		t.Errorf("event after SetRoute: %+v", e)
+	}
+}
+
+func TestRequest_Context(t *testing.T) {
+	// Code without a request publishes nothing
+	var r *Request
+	r.Publish(Event{Type: RequestReceived})
+	r.SetRoute("openai", "gpt-4o")
+	FromContext(nil).ToolCallExtracted("bash")
+	FromContext(context.Background()).ToolCallExtracted("bash")
+
+	bus := NewBus()
+	sub := bus.Subscribe(Filter{}, 1)
+	ctx := WithRequest(context.Background(), NewRequest(bus, Event{RequestID: "req_1"}))
+	FromContext(ctx).ToolCallExtracted("bash")
+	if e := receive(t, sub); e.Type != ToolCallExtracted || e.Tool != "bash" || e.RequestID != "req_1" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// Tail streams the events of a running proxy from its /admin/events endpoint
+// until ctx is done or the proxy ends the stream.
+//
+// @param ctx - stops tailing when done.
+// @param baseURL - the proxy's base URL, e.g. "http://localhost:8080".
+// @param apiKey - an admin client key, empty if the proxy has no auth section.
+// @param f - the events to receive; filtering happens on the proxy.
+// @param handle - called with each event, in order.
+// @return error - if the proxy could not be reached or refused the request;
+// nil when ctx is done or the proxy ended the stream.
+func Tail(ctx context.Context, baseURL, apiKey string, f Filter, handle func(Event)) error {
+	query := url.Values{}
+	for param, value := range map[string]string{"session": f.Session, "model": f.Model, "client": f.Client} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	u := strings.TrimSuffix(baseURL, "/") + "/admin/events"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+
+	for ev, err := range sse.Read(resp.Body, nil) {
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(ev.Data), &e); err != nil {
+			continue
+		}
+		handle(e)
+	}
+	return nil
+}
+
+// Format renders an event as one line of `ai-proxy tail` output:
+// the time, request ID and type, then the fields the event carries.
+func Format(e Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %-20s", e.Time.Local().Format("15:04:05.000"), e.RequestID, e.Type)
+
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, " %s=%s", name, value)
+		}
+	}
+	switch e.Type {
+	case RequestReceived:
+		field("protocol", e.Protocol)
+		field("model", e.Model)
+		field("client", e.Client)
+		field("session", e.Session)
+	case RouteResolved, UpstreamConnected:
+		field("provider", e.Provider)
+		field("upstream_model", e.UpstreamModel)
+	case ToolCallExtracted:
+		field("tool", e.Tool)
+	case WebSearchExecuted:
+		field("query", fmt.Sprintf("%q", e.Query))
+		field("results", fmt.Sprint(e.Results))
+	case RequestCompleted:
+		field("status", fmt.Sprint(e.Status))
+		field("input_tokens", fmt.Sprint(e.InputTokens))
+		field("output_tokens", fmt.Sprint(e.OutputTokens))
+		if e.Cost > 0 {
+			field("cost", fmt.Sprintf("$%.6f", e.Cost))
+		}
+	}
+	if e.Type == UpstreamError && e.Status != 0 {
+		field("status", fmt.Sprint(e.Status))
+	}
+	field("error", e.Error)
+	if e.Type != RequestReceived {
+		field("elapsed", fmt.Sprintf("%dms", e.Elapsed))
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	var query, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, auth = r.URL.RawQuery, r.Header.Get("Authorization")
+		if r.URL.Path != "/admin/events" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "event: request.received\ndata: {\"type\":\"request.received\",\"request_id\":\"req_1\",\"model\":\"fast\"}\n\n")
+		fmt.Fprint(w, "event: request.completed\ndata: {\"type\":\"request.completed\",\"request_id\":\"req_1\",\"status\":200}\n\n")
+	}))
+	defer server.Close()
+
+	var got []Event
+	err := Tail(context.Background(), server.URL+"/", "admin-key", Filter{Model: "fast", Client: "alice"}, func(e Event) {
+		got = append(got, e)
+	})
+	if err != nil {
+		t.Fatalf("Tail() error: %v", err)
+	}
+	if query != "client=alice&model=fast" || auth != "Bearer admin-key" {
+		t.Errorf("request query %q, authorization %q", query, auth)
+	}
+	if len(got) != 2 || got[0].Type != RequestReceived || got[0].Model != "fast" || got[1].Status != 200 {
+		t.Errorf("unexpected events: %+v", got)
+	}
+}
+
+func TestTail_Refused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Tail(context.Background(), server.URL, "", Filter{}, func(Event) {})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Tail() error = %v, want 403", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	at := time.Date(2026, 10, 16, 12, 30, 5, 250e6, time.Local)
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{
+			Event{Type: RequestReceived, Time: at, RequestID: "req_1", Protocol: "anthropic", Model: "fast", Client: "alice"},
+			"12:30:05.250 req_1 request.received     protocol=anthropic model=fast client=alice",
+		},
+		{
+			Event{Type: ToolCallExtracted, Time: at, RequestID: "req_1", Tool: "bash", Elapsed: 812},
+			"12:30:05.250 req_1 tool_call.extracted  tool=bash elapsed=812ms",
+		},
+		{
+			Event{Type: UpstreamError, Time: at, RequestID: "req_1", Status: 503, Elapsed: 40},
+			"12:30:05.250 req_1 upstream.error       status=503 elapsed=40ms",
+		},
+		{
+			Event{Type: RequestCompleted, Time: at, RequestID: "req_1", Status: 200, InputTokens: 12, OutputTokens: 5, Cost: 0.00042, Elapsed: 1500},
+			"12:30:05.250 req_1 request.completed    status=200 input_tokens=12 output_tokens=5 cost=$0.000420 elapsed=1500ms",
+		},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.event); got != tt.want {
+			t.Errorf("Format(%s) =\n%q\nwant\n%q", tt.event.Type, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package ledger aggregates the token usage and cost of proxied requests.
+//
+// The Ledger keeps one row of totals per session, client key, model alias and
+// UTC day, and answers queries that filter those rows and sum them over any
+// subset of the four dimensions. Rows are held in memory and dropped once
+// their day is older than Retention. Each client key keeps at most MaxSessions
+// sessions apart per day, so the number of rows is bounded by the configured
+// client keys and model aliases rather than by the session IDs clients send.
+package ledger
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-proxy/capture"
+	"ai-proxy/config"
+)
+
+// Retention is how long rows are kept after their day has started.
+const Retention = 31 * 24 * time.Hour
+
+// DayFormat is the layout of ledger days.
+const DayFormat = "2006-01-02"
+
+// MaxSessions is how many sessions of a client key are kept apart per day.
+// The usage of further sessions is added to the OtherSession rows.
+const MaxSessions = 1000
+
+// OtherSession is the session of rows summing a client key's sessions past
+// MaxSessions on a day.
+const OtherSession = "(other)"
+
+// Dimensions rows can be grouped by.
+const (
+	GroupSession   = "session"
+	GroupClientKey = "client_key"
+	GroupModel     = "model"
+	GroupDay       = "day"
+)
+
+// timeNow is replaced in tests.
+var timeNow = time.Now
+
+// Totals is the usage and cost of one or more requests.
+type Totals struct {
+	// Requests is the number of requests.
+	Requests int64 `json:"requests"`
+	// InputTokens counts input tokens neither read from nor written to the prompt cache.
+	InputTokens int64 `json:"input_tokens"`
+	// OutputTokens counts output tokens.
+	OutputTokens int64 `json:"output_tokens"`
+	// CacheReadTokens counts input tokens read from the prompt cache.
+	CacheReadTokens int64 `json:"cache_read_tokens"`
+	// CacheWriteTokens counts input tokens written to the prompt cache.
+	CacheWriteTokens int64 `json:"cache_write_tokens"`
+	// Cost is the price of the tokens in dollars.
+	Cost float64 `json:"cost"`
+}
+
+// Request returns the totals of one request from its upstream usage,
+// priced at the model's price.
+//
+// Cache writes are only counted when the upstream reported them; an estimated
+// cache creation count is already part of the uncached input.
+//
+// @param u - the upstream usage of the request
+// @param price - the price of the upstream model, nil if it is not priced
+// @return Totals - one request with its tokens and cost
+func Request(u capture.TokenUsage, price *config.ModelPrice) Totals {
+	t := Totals{
+		Requests:        1,
+		InputTokens:     int64(u.UncachedInputTokens()),
+		OutputTokens:    int64(u.OutputTokens),
+		CacheReadTokens: int64(u.CacheReadTokens),
+	}
+	if u.CacheCreationReported {
+		t.CacheWriteTokens = int64(u.CacheCreationTokens)
+	}
+	t.Cost = price.Cost(int(t.InputTokens), int(t.OutputTokens), int(t.CacheReadTokens), int(t.CacheWriteTokens))
+	return t
+}
+
+// add adds o to t.
+func (t *Totals) add(o Totals) {
+	t.Requests += o.Requests
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.CacheReadTokens += o.CacheReadTokens
+	t.CacheWriteTokens += o.CacheWriteTokens
+	t.Cost += o.Cost
+}
+
+// Key identifies a ledger row. In query results, fields that are not
+// grouped by are empty.
+type Key struct {
+	Day       string `json:"day,omitempty"`
+	Session   string `json:"session,omitempty"`
+	ClientKey string `json:"client_key,omitempty"`
+	Model     string `json:"model,omitempty"`
+}
+
+// Row is one group of a query result.
+type Row struct {
+	Key
+	Totals
+}
+
+// Query selects and groups ledger rows. Empty filters match every row.
+type Query struct {
+	// GroupBy lists the dimensions to group by; empty sums all rows into one.
+	GroupBy []string
+	// Session, ClientKey and Model keep only rows with that value.
+	Session   string
+	ClientKey string
+	Model     string
+	// From and To keep only rows of days in the inclusive range, as DayFormat.
+	From string
+	To   string
+}
+
+// ParseGroupBy parses a comma-separated list of dimensions.
+//
+// @param s - e.g. "model,day"; empty groups by nothing
+// @return []string - the dimensions, without duplicates
+// @return error - if a dimension is unknown
+func ParseGroupBy(s string) ([]string, error) {
+	var dims []string
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		switch d {
+		case "":
+			continue
+		case GroupSession, GroupClientKey, GroupModel, GroupDay:
+			if !slices.Contains(dims, d) {
+				dims = append(dims, d)
+			}
+		default:
+			return nil, fmt.Errorf("unknown group_by dimension '%s': must be %s, %s, %s or %s",
+				d, GroupSession, GroupClientKey, GroupModel, GroupDay)
+		}
+	}
+	return dims, nil
+}
+
+// Ledger accumulates request totals.
+//
+// Thread Safety: Safe for concurrent use.
+type Ledger struct {
+	mu   sync.Mutex
+	rows map[Key]*Totals
+	// sessions holds the sessions kept apart per day and client key; their
+	// session fields are empty.
+	sessions    map[Key]map[string]struct{}
+	maxSessions int
+}
+
+// NewLedger creates an empty ledger.
+func NewLedger() *Ledger {
+	return &Ledger{
+		rows:        make(map[Key]*Totals),
+		sessions:    make(map[Key]map[string]struct{}),
+		maxSessions: MaxSessions,
+	}
+}
+
+// Default is the process-wide ledger the request handlers record to.
+var Default = NewLedger()
+
+// Record adds the totals of a finished request to today's row of its
+// session, client key and model, and drops rows past Retention. Once the
+// client key has MaxSessions sessions today, new sessions are recorded as
+// OtherSession.
+//
+// @param session - the request's session ID, empty if the client sent none
+// @param clientKey - the name of the client key, empty without authentication
+// @param model - the model alias the client requested
+// @param t - the request's totals, see Request
+func (l *Ledger) Record(session, clientKey, model string, t Totals) {
+	now := timeNow().UTC()
+	key := Key{Day: now.Format(DayFormat), Session: session, ClientKey: clientKey, Model: model}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	row, ok := l.rows[key]
+	if !ok {
+		l.prune(now)
+		key.Session = l.admitSession(key)
+		if row, ok = l.rows[key]; !ok {
+			row = &Totals{}
+			l.rows[key] = row
+		}
+	}
+	row.add(t)
+}
+
+// admitSession returns the session a row is recorded under: its own if the
+// session is already kept apart or there is room for it, else OtherSession.
+//
+// @pre l.mu is held
+func (l *Ledger) admitSession(key Key) string {
+	if key.Session == "" {
+		return ""
+	}
+	session := key.Session
+	key.Session, key.Model = "", ""
+	seen, ok := l.sessions[key]
+	if _, kept := seen[session]; kept {
+		return session
+	}
+	if len(seen) >= l.maxSessions {
+		return OtherSession
+	}
+	if !ok {
+		seen = make(map[string]struct{})
+		l.sessions[key] = seen
+	}
+	seen[session] = struct{}{}
+	return session
+}
+
+// prune drops rows whose day started more than Retention ago.
+//
+// @pre l.mu is held
+func (l *Ledger) prune(now time.Time) {
+	oldest := now.Add(-Retention).Format(DayFormat)
+	for key := range l.rows {
+		if key.Day < oldest {
+			delete(l.rows, key)
+		}
+	}
+	for key := range l.sessions {
+		if key.Day < oldest {
+			delete(l.sessions, key)
+		}
+	}
+}
+
+// Query returns the matching rows grouped by q.GroupBy, ordered by day,
+// session, client key and model, and the totals of all of them.
+//
+// @param q - the filters and grouping
+// @return []Row - one row per group, empty if nothing matches
+// @return Totals - the sum of all matching rows
+func (l *Ledger) Query(q Query) ([]Row, Totals) {
+	groups := make(map[Key]*Totals)
+	var total Totals
+
+	l.mu.Lock()
+	for key, t := range l.rows {
+		if !q.matches(key) {
+			continue
+		}
+		group := q.group(key)
+		g, ok := groups[group]
+		if !ok {
+			g = &Totals{}
+			groups[group] = g
+		}
+		g.add(*t)
+		total.add(*t)
+	}
+	l.mu.Unlock()
+
+	rows := make([]Row, 0, len(groups))
+	for key, t := range groups {
+		rows = append(rows, Row{Key: key, Totals: *t})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i].Key, rows[j].Key
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Session != b.Session {
+			return a.Session < b.Session
+		}
+		if a.ClientKey != b.ClientKey {
+			return a.ClientKey < b.ClientKey
+		}
+		return a.Model < b.Model
+	})
+	return rows, total
+}
+
+// matches reports whether a row passes the query's filters.
+func (q Query) matches(key Key) bool {
+	return (q.Session == "" || key.Session == q.Session) &&
+		(q.ClientKey == "" || key.ClientKey == q.ClientKey) &&
+		(q.Model == "" || key.Model == q.Model) &&
+		(q.From == "" || key.Day >= q.From) &&
+		(q.To == "" || key.Day <= q.To)
+}
+
+// group returns the key of the group a row belongs to.
+func (q Query) group(key Key) Key {
+	var g Key
+	for _, d := range q.GroupBy {
+		switch d {
+		case GroupSession:
+			g.Session = key.Session
+		case GroupClientKey:
+			g.ClientKey = key.ClientKey
+		case GroupModel:
+			g.Model = key.Model
+		case GroupDay:
+			g.Day = key.Day
+		}
+	}
+	return g
+}
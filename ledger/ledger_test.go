@@ -0,0 +1,168 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"ai-proxy/capture"
+	"ai-proxy/config"
+)
+
+// withClock makes timeNow return the returned pointer's value.
+func withClock(t *testing.T) *time.Time {
+	t.Helper()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	old := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = old })
+	return &now
+}
+
+func TestRequest(t *testing.T) {
+	price := &config.ModelPrice{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+
+	tests := []struct {
+		name  string
+		usage capture.TokenUsage
+		want  Totals
+	}{
+		{
+			name: "OpenAI cached tokens are split from the prompt",
+			usage: capture.TokenUsage{InputTokens: 1000000, OutputTokens: 100000, CacheReadTokens: 400000,
+				InputIncludesCache: true},
+			want: Totals{Requests: 1, InputTokens: 600000, OutputTokens: 100000, CacheReadTokens: 400000, Cost: 3.42},
+		},
+		{
+			name: "Anthropic cache writes are priced when reported",
+			usage: capture.TokenUsage{InputTokens: 1000000, CacheReadTokens: 1000000, CacheCreationTokens: 1000000,
+				CacheCreationReported: true},
+			want: Totals{Requests: 1, InputTokens: 1000000, CacheReadTokens: 1000000, CacheWriteTokens: 1000000, Cost: 7.05},
+		},
+		{
+			name:  "estimated cache creation is not priced twice",
+			usage: capture.TokenUsage{InputTokens: 1000000, CacheReadTokens: 200000, CacheCreationTokens: 800000},
+			want:  Totals{Requests: 1, InputTokens: 1000000, CacheReadTokens: 200000, Cost: 3.06},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Request(tt.usage, price)
+			if roundCost(got.Cost) != tt.want.Cost {
+				t.Errorf("Request() cost = %v, want %v", got.Cost, tt.want.Cost)
+			}
+			got.Cost, tt.want.Cost = 0, 0
+			if got != tt.want {
+				t.Errorf("Request() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Request(capture.TokenUsage{InputTokens: 10, OutputTokens: 5}, nil); got.Cost != 0 || got.InputTokens != 10 {
+		t.Errorf("unpriced Request() = %+v, want tokens without cost", got)
+	}
+}
+
+// roundCost rounds a dollar amount to millionths for comparisons.
+func roundCost(v float64) float64 {
+	return float64(int64(v*1e6+0.5)) / 1e6
+}
+
+func TestParseGroupBy(t *testing.T) {
+	got, err := ParseGroupBy(" model,day,model ")
+	if err != nil || len(got) != 2 || got[0] != GroupModel || got[1] != GroupDay {
+		t.Errorf("ParseGroupBy() = %v, %v; want [model day]", got, err)
+	}
+	if got, err := ParseGroupBy(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseGroupBy(\"\") = %v, %v; want none", got, err)
+	}
+	if _, err := ParseGroupBy("model,provider"); err == nil {
+		t.Error("ParseGroupBy() expected error for unknown dimension")
+	}
+}
+
+func TestLedger_QueryGroupsAndFilters(t *testing.T) {
+	now := withClock(t)
+	l := NewLedger()
+	l.Record("s1", "alice-laptop", "gpt-4", Totals{Requests: 1, InputTokens: 10, Cost: 1})
+	l.Record("s1", "alice-laptop", "gpt-4", Totals{Requests: 1, InputTokens: 20, Cost: 2})
+	l.Record("s2", "alice-laptop", "claude", Totals{Requests: 1, OutputTokens: 5, Cost: 4})
+	*now = now.Add(24 * time.Hour)
+	l.Record("s3", "bob-ci", "gpt-4", Totals{Requests: 1, CacheReadTokens: 7, Cost: 8})
+
+	rows, total := l.Query(Query{})
+	if len(rows) != 1 || rows[0].Key != (Key{}) || total.Requests != 4 || total.Cost != 15 {
+		t.Errorf("ungrouped Query() = %+v, %+v; want one row of 4 requests costing 15", rows, total)
+	}
+
+	rows, _ = l.Query(Query{GroupBy: []string{GroupModel, GroupDay}})
+	want := []Row{
+		{Key{Day: "2026-03-10", Model: "claude"}, Totals{Requests: 1, OutputTokens: 5, Cost: 4}},
+		{Key{Day: "2026-03-10", Model: "gpt-4"}, Totals{Requests: 2, InputTokens: 30, Cost: 3}},
+		{Key{Day: "2026-03-11", Model: "gpt-4"}, Totals{Requests: 1, CacheReadTokens: 7, Cost: 8}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("grouped Query() = %+v, want %+v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+
+	rows, total = l.Query(Query{GroupBy: []string{GroupSession}, ClientKey: "alice-laptop", From: "2026-03-10", To: "2026-03-10"})
+	if len(rows) != 2 || rows[0].Session != "s1" || rows[1].Session != "s2" || total.Cost != 7 {
+		t.Errorf("filtered Query() = %+v, %+v; want sessions s1 and s2 costing 7", rows, total)
+	}
+
+	if rows, total := l.Query(Query{Model: "gemini"}); len(rows) != 0 || total.Requests != 0 {
+		t.Errorf("Query() for unknown model = %+v, %+v; want nothing", rows, total)
+	}
+}
+
+func TestLedger_DropsRowsPastRetention(t *testing.T) {
+	now := withClock(t)
+	l := NewLedger()
+	l.Record("s1", "", "gpt-4", Totals{Requests: 1})
+
+	*now = now.Add(Retention + 24*time.Hour)
+	l.Record("s2", "", "gpt-4", Totals{Requests: 1})
+
+	rows, _ := l.Query(Query{GroupBy: []string{GroupSession}})
+	if len(rows) != 1 || rows[0].Session != "s2" {
+		t.Errorf("Query() = %+v, want only the recent session", rows)
+	}
+}
+
+func TestLedger_CapsSessionsPerClientKeyAndDay(t *testing.T) {
+	now := withClock(t)
+	l := NewLedger()
+	l.maxSessions = 2
+	for _, session := range []string{"s1", "s2", "s3", "s4", "s1"} {
+		l.Record(session, "alice-laptop", "gpt-4", Totals{Requests: 1})
+	}
+	l.Record("s2", "alice-laptop", "claude", Totals{Requests: 1})
+	l.Record("s5", "bob-ci", "gpt-4", Totals{Requests: 1})
+
+	rows, total := l.Query(Query{GroupBy: []string{GroupSession, GroupClientKey}})
+	want := []Row{
+		{Key{Session: OtherSession, ClientKey: "alice-laptop"}, Totals{Requests: 2}},
+		{Key{Session: "s1", ClientKey: "alice-laptop"}, Totals{Requests: 2}},
+		{Key{Session: "s2", ClientKey: "alice-laptop"}, Totals{Requests: 2}},
+		{Key{Session: "s5", ClientKey: "bob-ci"}, Totals{Requests: 1}},
+	}
+	if len(rows) != len(want) || total.Requests != 7 {
+		t.Fatalf("Query() = %+v, %+v; want %+v", rows, total, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+
+	*now = now.Add(24 * time.Hour)
+	l.Record("s3", "alice-laptop", "gpt-4", Totals{Requests: 1})
+	rows, _ = l.Query(Query{GroupBy: []string{GroupSession}, From: "2026-03-11"})
+	if len(rows) != 1 || rows[0].Session != "s3" {
+		t.Errorf("Query() = %+v, want session s3 kept apart on a new day", rows)
+	}
+}
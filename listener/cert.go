@@ -0,0 +1,72 @@
+// Package listener opens the sockets the server accepts connections on.
+// This file implements reloading listener certificates when their files change.
+package listener
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"ai-proxy/logging"
+)
+
+// certReloader serves a certificate, loading it again from its files when
+// their modification times change, e.g. after a renewal.
+//
+// Thread Safety: Safe for concurrent use.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// newCertReloader loads a certificate and its key.
+//
+// @return error - if the files cannot be read or do not form a key pair
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	r.certMod, r.keyMod = modTime(certFile), modTime(keyFile)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	r.cert = &cert
+	return r, nil
+}
+
+// GetCertificate returns the current certificate for a TLS handshake.
+// If a file changed, the certificate is loaded again; if that fails, the
+// previous certificate is kept until the files change again.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certMod, keyMod := modTime(r.certFile), modTime(r.keyFile)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if certMod.Equal(r.certMod) && keyMod.Equal(r.keyMod) {
+		return r.cert, nil
+	}
+	r.certMod, r.keyMod = certMod, keyMod
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		logging.ErrorMsg("Failed to reload listener certificate %s, keeping the previous one: %v", r.certFile, err)
+		return r.cert, nil
+	}
+	logging.InfoMsg("Reloaded listener certificate %s", r.certFile)
+	r.cert = &cert
+	return r.cert, nil
+}
+
+// modTime returns the modification time of a file, or the zero time if it
+// cannot be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
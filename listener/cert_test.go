@@ -0,0 +1,61 @@
+package listener
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// touch moves the modification time of files forward, as a rewrite would.
+func touch(t *testing.T, at time.Time, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		if err := os.Chtimes(f, at, at); err != nil {
+			t.Fatalf("Chtimes returned error: %v", err)
+		}
+	}
+}
+
+// commonName returns the subject of the certificate r currently serves.
+func commonName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate returned error: %v", err)
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestCertReloader_ReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCert(t, dir, "first")
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader returned error: %v", err)
+	}
+	if got := commonName(t, r); got != "first" {
+		t.Fatalf("expected the first certificate, got %q", got)
+	}
+
+	writeCert(t, dir, "renewed")
+	touch(t, time.Now().Add(time.Minute), certFile, keyFile)
+	if got := commonName(t, r); got != "renewed" {
+		t.Errorf("expected the renewed certificate, got %q", got)
+	}
+}
+
+func TestCertReloader_KeepsCertificateOnInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCert(t, dir, "first")
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader returned error: %v", err)
+	}
+
+	// A renewal that has written the certificate but not yet the key
+	os.WriteFile(keyFile, []byte("partial"), 0600)
+	touch(t, time.Now().Add(time.Minute), keyFile)
+	if got := commonName(t, r); got != "first" {
+		t.Errorf("expected the previous certificate to be kept, got %q", got)
+	}
+}
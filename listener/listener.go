@@ -0,0 +1,256 @@
+// Package listener opens the sockets the server accepts connections on: TCP
+// addresses with optional TLS, unix sockets with file permissions, and
+// sockets passed in by systemd socket activation.
+package listener
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ai-proxy/config"
+)
+
+// DefaultSocketMode is the file mode of a unix socket that does not configure mode.
+const DefaultSocketMode os.FileMode = 0600
+
+// Listener is an open listener with a description for logs.
+type Listener struct {
+	net.Listener
+	// Description names the listener, e.g. "https://127.0.0.1:8443" or
+	// "unix:/run/ai-proxy.sock".
+	Description string
+}
+
+// Open opens the configured listeners. Without any configured listener, it
+// listens on defaultAddr over plain HTTP.
+//
+// @param cfgs - the listener configurations, validated by the config loader
+// @param defaultAddr - the "host:port" used if cfgs is empty
+// @return []*Listener - the open listeners, in configuration order
+// @return error - if any listener cannot be opened; the ones already opened are closed
+func Open(cfgs []config.ListenerConfig, defaultAddr string) ([]*Listener, error) {
+	if len(cfgs) == 0 {
+		cfgs = []config.ListenerConfig{{Type: config.ListenerTCP, Address: defaultAddr}}
+	}
+
+	var (
+		opened    []*Listener
+		inherited *systemdSockets
+	)
+	closeAll := func() {
+		for _, l := range opened {
+			l.Close()
+		}
+		if inherited != nil {
+			inherited.closeUnclaimed()
+		}
+	}
+
+	for i, cfg := range cfgs {
+		var (
+			lns []*Listener
+			err error
+		)
+		switch cfg.Type {
+		case config.ListenerTCP:
+			var ln net.Listener
+			if ln, err = net.Listen("tcp", cfg.Address); err == nil {
+				lns = []*Listener{{Listener: ln, Description: "http://" + ln.Addr().String()}}
+			}
+		case config.ListenerUnix:
+			var l *Listener
+			if l, err = listenUnix(cfg); err == nil {
+				lns = []*Listener{l}
+			}
+		case config.ListenerSystemd:
+			if inherited == nil {
+				if inherited, err = inheritSystemdSockets(); err != nil {
+					break
+				}
+			}
+			lns, err = inherited.claim(cfg.Name)
+		default:
+			err = fmt.Errorf("unknown type %q", cfg.Type)
+		}
+		if err == nil && cfg.TLS != nil {
+			err = wrapTLS(lns, cfg.TLS)
+		}
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+			closeAll()
+			return nil, fmt.Errorf("listeners[%d]: %w", i, err)
+		}
+		opened = append(opened, lns...)
+	}
+
+	// Sockets systemd passed in that no listener serves are not kept open
+	if inherited != nil {
+		inherited.closeUnclaimed()
+	}
+	return opened, nil
+}
+
+// wrapTLS makes listeners terminate TLS with a certificate that is loaded
+// again whenever its files change.
+func wrapTLS(lns []*Listener, cfg *config.ListenerTLSConfig) error {
+	certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: certs.GetCertificate,
+	}
+	for _, l := range lns {
+		l.Listener = tls.NewListener(l.Listener, tlsConfig)
+		l.Description = "https" + strings.TrimPrefix(l.Description, "http")
+	}
+	return nil
+}
+
+// listenUnix listens on a unix socket and applies its mode and ownership.
+// A stale socket left by a process that is gone is replaced; a socket that
+// still accepts connections is not.
+func listenUnix(cfg config.ListenerConfig) (*Listener, error) {
+	mode := DefaultSocketMode
+	if cfg.Mode != "" {
+		parsed, err := strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode %q", cfg.Mode)
+		}
+		mode = os.FileMode(parsed)
+	}
+	uid, gid, err := lookupOwner(cfg.Owner, cfg.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := removeStaleSocket(cfg.Path); err != nil {
+		return nil, err
+	}
+	ln, err := listenPrivate(cfg.Path, mode, uid, gid)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{Listener: ln, Description: "unix:" + cfg.Path}, nil
+}
+
+// listenPrivate listens on a unix socket at path with the given mode and
+// ownership. The socket is created in a private directory next to path and
+// renamed into place once its mode and owner are applied, so nobody can
+// connect to it in between, whatever the process umask.
+//
+// @param uid, gid - the owner, -1 to keep the process's
+// @return net.Listener - removes the socket file at path when closed
+func listenPrivate(path string, mode os.FileMode, uid, gid int) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket file moves, so the listener cannot unlink it by its name
+	ln.SetUnlinkOnClose(false)
+	setup := func() error {
+		if uid != -1 || gid != -1 {
+			if err := os.Lchown(tmp, uid, gid); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(tmp, mode); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+	if err := setup(); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: ln, path: path}, nil
+}
+
+// unixListener is a unix socket listener whose socket file was renamed to path.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+// Addr returns the address of the socket file.
+func (l *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+// Close stops listening and removes the socket file.
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	if err == nil {
+		os.Remove(l.path)
+	}
+	return err
+}
+
+// removeStaleSocket removes a unix socket nobody accepts connections on.
+//
+// @return error - if path is in use or is not a socket
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// lookupOwner resolves a user and group, given as names or numeric IDs.
+//
+// @return uid, gid - the IDs, -1 for those not given
+func lookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return 0, 0, err
+			}
+			id = u.Uid
+		}
+		uid, _ = strconv.Atoi(id)
+	}
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, err
+			}
+			id = g.Gid
+		}
+		gid, _ = strconv.Atoi(id)
+	}
+	return uid, gid, nil
+}
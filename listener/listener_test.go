@@ -0,0 +1,282 @@
+package listener
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 with the given
+// common name to cert.pem and key.pem in dir.
+func writeCert(t *testing.T, dir, name string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate returned error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey returned error: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	return certFile, keyFile, cert
+}
+
+// serve answers every request on the listeners with "ok" until the test ends.
+func serve(t *testing.T, lns []*Listener) {
+	t.Helper()
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	for _, l := range lns {
+		go srv.Serve(l)
+	}
+	t.Cleanup(func() { srv.Close() })
+}
+
+// get sends a GET request with client and returns the response, with its body read.
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("unexpected body %q", body)
+	}
+	return resp
+}
+
+// unixClient returns a client sending every request to the unix socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestOpen_Default(t *testing.T) {
+	lns, err := Open(nil, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if len(lns) != 1 || !strings.HasPrefix(lns[0].Description, "http://127.0.0.1:") {
+		t.Fatalf("expected one plain HTTP listener on 127.0.0.1, got %+v", lns)
+	}
+	serve(t, lns)
+	get(t, http.DefaultClient, lns[0].Description)
+}
+
+func TestOpen_TCPWithTLS(t *testing.T) {
+	certFile, keyFile, cert := writeCert(t, t.TempDir(), "proxy")
+	lns, err := Open([]config.ListenerConfig{{
+		Type:    config.ListenerTCP,
+		Address: "127.0.0.1:0",
+		TLS:     &config.ListenerTLSConfig{CertFile: certFile, KeyFile: keyFile},
+	}}, "")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if !strings.HasPrefix(lns[0].Description, "https://127.0.0.1:") {
+		t.Fatalf("expected an HTTPS listener, got %q", lns[0].Description)
+	}
+	serve(t, lns)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+	if resp := get(t, client, lns[0].Description); resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2 to be negotiated, got %s", resp.Proto)
+	}
+}
+
+func TestOpen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ai-proxy.sock")
+	lns, err := Open([]config.ListenerConfig{{
+		Type:  config.ListenerUnix,
+		Path:  path,
+		Mode:  "0660",
+		Owner: strconv.Itoa(os.Getuid()),
+		Group: strconv.Itoa(os.Getgid()),
+	}}, "")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if lns[0].Description != "unix:"+path {
+		t.Errorf("unexpected description %q", lns[0].Description)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat returned error: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0660 {
+		t.Errorf("expected a socket with mode 0660, got %v", info.Mode())
+	}
+	serve(t, lns)
+	get(t, unixClient(path), "http://ai-proxy/health")
+
+	lns[0].Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestOpen_UnixDefaultMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ai-proxy.sock")
+	lns, err := Open([]config.ListenerConfig{{Type: config.ListenerUnix, Path: path}}, "")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer lns[0].Close()
+	if info, _ := os.Stat(path); info.Mode().Perm() != DefaultSocketMode {
+		t.Errorf("expected mode %v, got %v", DefaultSocketMode, info.Mode().Perm())
+	}
+}
+
+func TestListenPrivate(t *testing.T) {
+	// The process umask is left alone, even a permissive one
+	old := syscall.Umask(0)
+	defer syscall.Umask(old)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ai-proxy.sock")
+	ln, err := listenPrivate(path, 0600, -1, -1)
+	if err != nil {
+		t.Fatalf("listenPrivate returned error: %v", err)
+	}
+	if umask := syscall.Umask(0); umask != 0 {
+		t.Errorf("expected the umask to stay 0, got %#o", umask)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if ln.Addr().String() != path {
+		t.Errorf("expected address %s, got %s", path, ln.Addr())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the socket in %s, got %v", dir, entries)
+	}
+
+	ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestOpen_UnixStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ai-proxy.sock")
+	// A crashed process leaves its socket file behind
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	lns, err := Open([]config.ListenerConfig{{Type: config.ListenerUnix, Path: path}}, "")
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	lns[0].Close()
+}
+
+func TestOpen_UnixErrors(t *testing.T) {
+	dir := t.TempDir()
+	inUse := filepath.Join(dir, "in-use.sock")
+	ln, err := net.Listen("unix", inUse)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	regular := filepath.Join(dir, "regular")
+	os.WriteFile(regular, nil, 0600)
+
+	tests := []struct {
+		name        string
+		cfg         config.ListenerConfig
+		errContains string
+	}{
+		{"socket in use", config.ListenerConfig{Type: config.ListenerUnix, Path: inUse}, "in use by another process"},
+		{"not a socket", config.ListenerConfig{Type: config.ListenerUnix, Path: regular}, "is not a socket"},
+		{"unknown owner", config.ListenerConfig{Type: config.ListenerUnix, Path: filepath.Join(dir, "a.sock"), Owner: "no-such-user-ai-proxy"}, "no-such-user-ai-proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open([]config.ListenerConfig{tt.cfg}, "")
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected an error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestOpen_ClosesOpenedOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ai-proxy.sock")
+	_, err := Open([]config.ListenerConfig{
+		{Type: config.ListenerUnix, Path: path},
+		{Type: config.ListenerTCP, Address: "127.0.0.1:-1"},
+	}, "")
+	if err == nil || !strings.Contains(err.Error(), "listeners[1]") {
+		t.Fatalf("expected the second listener to fail, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the first listener to be closed, got %v", err)
+	}
+}
+
+func TestOpen_TLSCertificateMissing(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Open([]config.ListenerConfig{{
+		Type:    config.ListenerTCP,
+		Address: "127.0.0.1:0",
+		TLS:     &config.ListenerTLSConfig{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")},
+	}}, "")
+	if err == nil || !strings.Contains(err.Error(), "listeners[0]: tls") {
+		t.Errorf("expected a TLS error, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+// Package listener opens the sockets the server accepts connections on.
+// This file implements systemd socket activation (sd_listen_fds(3)).
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets in.
+// Replaced in tests.
+var listenFDsStart = 3
+
+// systemdSocket is a socket passed in by systemd.
+type systemdSocket struct {
+	name    string
+	ln      net.Listener
+	claimed bool
+}
+
+// systemdSockets are the sockets passed in by systemd, each served by at
+// most one listener.
+type systemdSockets struct {
+	sockets []*systemdSocket
+}
+
+// inheritSystemdSockets takes over the sockets systemd passed to this process
+// in LISTEN_FDS, named by LISTEN_FDNAMES. The variables are unset so that
+// child processes do not take the sockets too.
+//
+// @return error - if no sockets were passed to this process, or one is not a listening socket
+func inheritSystemdSockets() (*systemdSockets, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	names := os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("no sockets passed in by systemd (LISTEN_PID is %q)", pid)
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("no sockets passed in by systemd (LISTEN_FDS is %q)", fds)
+	}
+	nameList := strings.Split(names, ":")
+
+	s := &systemdSockets{}
+	for i := 0; i < n; i++ {
+		fd := listenFDsStart + i
+		syscall.CloseOnExec(fd)
+		name := "unknown"
+		if i < len(nameList) && nameList[i] != "" {
+			name = nameList[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		// FileListener duplicates the descriptor, so the file is closed either way
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			s.closeUnclaimed()
+			return nil, fmt.Errorf("systemd socket %d (%s): %w", fd, name, err)
+		}
+		s.sockets = append(s.sockets, &systemdSocket{name: name, ln: ln})
+	}
+	return s, nil
+}
+
+// claim returns the sockets named name that no other listener serves yet, or
+// all of them if name is empty.
+//
+// @return error - if no such socket was passed in
+func (s *systemdSockets) claim(name string) ([]*Listener, error) {
+	var lns []*Listener
+	for _, sock := range s.sockets {
+		if sock.claimed || (name != "" && sock.name != name) {
+			continue
+		}
+		sock.claimed = true
+		lns = append(lns, &Listener{
+			Listener:    sock.ln,
+			Description: fmt.Sprintf("http://%s (systemd %s)", sock.ln.Addr(), sock.name),
+		})
+	}
+	if len(lns) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("no systemd socket named %q", name)
+		}
+		return nil, fmt.Errorf("no unclaimed systemd sockets")
+	}
+	return lns, nil
+}
+
+// closeUnclaimed closes the sockets no listener serves.
+func (s *systemdSockets) closeUnclaimed() {
+	for _, sock := range s.sockets {
+		if !sock.claimed {
+			sock.ln.Close()
+			sock.claimed = true
+		}
+	}
+}
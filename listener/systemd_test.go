@@ -0,0 +1,121 @@
+package listener
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"ai-proxy/config"
+)
+
+// passSockets emulates systemd socket activation: it opens one listening TCP
+// socket per name on consecutive file descriptors and sets the LISTEN_*
+// variables for this process. It returns the socket addresses.
+func passSockets(t *testing.T, names ...string) []string {
+	t.Helper()
+	var (
+		addrs []string
+		fds   []int
+	)
+	for range names {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		f, err := ln.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatalf("File returned error: %v", err)
+		}
+		// A raw descriptor, so that no *os.File closes it behind our back
+		fd, err := syscall.Dup(int(f.Fd()))
+		if err != nil {
+			t.Fatalf("Dup returned error: %v", err)
+		}
+		f.Close()
+		ln.Close()
+		addrs = append(addrs, ln.Addr().String())
+		fds = append(fds, fd)
+	}
+	for i := 1; i < len(fds); i++ {
+		if fds[i] != fds[0]+i {
+			for _, fd := range fds {
+				syscall.Close(fd)
+			}
+			t.Skipf("could not allocate consecutive file descriptors: %v", fds)
+		}
+	}
+
+	start := listenFDsStart
+	listenFDsStart = fds[0]
+	t.Cleanup(func() { listenFDsStart = start })
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", strconv.Itoa(len(fds)))
+	t.Setenv("LISTEN_FDNAMES", strings.Join(names, ":"))
+	return addrs
+}
+
+func TestOpen_Systemd(t *testing.T) {
+	addrs := passSockets(t, "http", "admin")
+
+	lns, err := Open([]config.ListenerConfig{{Type: config.ListenerSystemd}}, "")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if len(lns) != 2 {
+		t.Fatalf("expected both sockets to be served, got %d", len(lns))
+	}
+	if want := "http://" + addrs[1] + " (systemd admin)"; lns[1].Description != want {
+		t.Errorf("expected description %q, got %q", want, lns[1].Description)
+	}
+	serve(t, lns)
+	for _, addr := range addrs {
+		get(t, http.DefaultClient, "http://"+addr+"/health")
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("expected LISTEN_FDS to be unset")
+	}
+}
+
+func TestOpen_SystemdNamed(t *testing.T) {
+	addrs := passSockets(t, "http", "admin")
+
+	lns, err := Open([]config.ListenerConfig{{Type: config.ListenerSystemd, Name: "admin"}}, "")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if len(lns) != 1 || lns[0].Addr().String() != addrs[1] {
+		t.Fatalf("expected only the admin socket, got %+v", lns)
+	}
+	defer lns[0].Close()
+	// The socket no listener serves is closed
+	if conn, err := net.Dial("tcp", addrs[0]); err == nil {
+		conn.Close()
+		t.Errorf("expected the unclaimed socket %s to be closed", addrs[0])
+	}
+}
+
+func TestOpen_SystemdErrors(t *testing.T) {
+	t.Run("not activated", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "1")
+		t.Setenv("LISTEN_FDS", "1")
+		_, err := Open([]config.ListenerConfig{{Type: config.ListenerSystemd}}, "")
+		if err == nil || !strings.Contains(err.Error(), "no sockets passed in by systemd") {
+			t.Errorf("expected an activation error, got %v", err)
+		}
+	})
+	t.Run("unknown name", func(t *testing.T) {
+		addrs := passSockets(t, "http")
+		_, err := Open([]config.ListenerConfig{{Type: config.ListenerSystemd, Name: "admin"}}, "")
+		if err == nil || !strings.Contains(err.Error(), `no systemd socket named "admin"`) {
+			t.Errorf("expected an unknown name error, got %v", err)
+		}
+		if conn, err := net.Dial("tcp", addrs[0]); err == nil {
+			conn.Close()
+			t.Errorf("expected the passed socket to be closed after the error")
+		}
+	})
+}
@@ -3,12 +3,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"ai-proxy/api"
 	"ai-proxy/config"
 	"ai-proxy/conversation"
+	"ai-proxy/listener"
 	"ai-proxy/logging"
 	"ai-proxy/summarizer"
 	"ai-proxy/websearch"
@@ -18,11 +23,19 @@ import (
 // This is the application entry point and orchestrates all startup tasks.
 //
 // @pre Environment variables and command-line flags are available for configuration
-// @post Server is running and listening on the configured port
+// @post Server is running and listening on the configured listeners, or the bind address and port
 // @post All capture middleware is initialized if SSELogDir is configured
 // @note Exits with code 1 if config file is missing or server fails to start
 // @note Blocks until server is stopped (SIGINT, SIGTERM, or fatal error)
+// @note SIGINT and SIGTERM drain active streams for up to --shutdown-timeout,
+// then flush capture writes and the conversation store before exiting
+// @note `ai-proxy tail` prints the request events of a running proxy instead
 func main() {
+	// `ai-proxy tail` prints the events of a running proxy instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "tail" {
+		os.Exit(runTail(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Load configuration from config file, flags, environment variables, and defaults.
 	// config.Load() internally parses CLI flags and loads the JSON config file.
 	cfg := config.Load()
@@ -38,19 +51,31 @@ func main() {
 	logging.Init()
 
 	// Initialize conversation store for previous_response_id support
-	conversation.InitDefaultStore(conversation.Config{
+	// With a store path, conversations persisted by a previous run are reloaded
+	store, err := conversation.OpenStore(conversation.Config{
 		MaxSize: cfg.ConversationStoreSize,
 		TTL:     cfg.ConversationStoreTTL,
+		Path:    cfg.ConversationStorePath,
 	})
-	logging.InfoMsg("Conversation store initialized: maxSize=%d, ttl=%v", cfg.ConversationStoreSize, cfg.ConversationStoreTTL)
+	if err != nil {
+		logging.ErrorMsg("Failed to open conversation store: %v", err)
+		os.Exit(1)
+	}
+	conversation.DefaultStore = store
+	defer store.Close()
+	if cfg.ConversationStorePath != "" {
+		logging.InfoMsg("Conversation store initialized: path=%s, conversations=%d, maxSize=%d, ttl=%v", cfg.ConversationStorePath, store.Size(), cfg.ConversationStoreSize, cfg.ConversationStoreTTL)
+	} else {
+		logging.InfoMsg("Conversation store initialized: maxSize=%d, ttl=%v", cfg.ConversationStoreSize, cfg.ConversationStoreTTL)
+	}
 
 	// Initialize summarizer service for reasoning summarization
 	summarizer.InitDefaultService(cfg.AppConfig)
 
 	// Initialize web search service for web_search tool execution
-	websearch.DefaultService = websearch.InitDefaultService(cfg.AppConfig.WebSearch)
-	if websearch.DefaultService != nil {
-		logging.InfoMsg("Web search service initialized: backend=%s", websearch.DefaultService.GetBackend())
+	websearch.SetDefaultService(websearch.InitDefaultService(cfg.AppConfig.WebSearch))
+	if svc := websearch.GetDefaultService(); svc != nil {
+		logging.InfoMsg("Web search service initialized: backend=%s", svc.GetBackend())
 	}
 
 	// Initialize storage for request capture if logging is enabled
@@ -61,17 +86,61 @@ func main() {
 		logging.InfoMsg("SSE capture disabled (use --sse-log-dir to enable)")
 	}
 
+	// SIGINT and SIGTERM shut the server down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Create server with loaded configuration
 	// Middleware is added first so it applies to all routes
-	server := api.NewServer(cfg, api.NewCaptureMiddleware(storage).Handler())
+	captureMiddleware := api.NewCaptureMiddleware(storage)
+	server := api.NewServer(cfg, captureMiddleware.Handler())
 
-	// Build listen address from configured port
-	addr := ":" + cfg.Port
-	logging.InfoMsg("ai-proxy server starting on %s", addr)
+	// Reload the config file on SIGHUP and when it changes, without dropping
+	// in-flight requests
+	go server.WatchConfig(ctx)
+	if cfg.ConfigWatchInterval > 0 {
+		logging.InfoMsg("Watching config file %s every %v (SIGHUP also reloads)", cfg.ConfigFile, cfg.ConfigWatchInterval)
+	}
 
-	// Start server; this blocks until server stops
-	if err := server.Run(addr); err != nil {
+	// Open the configured listeners, or the bind address and port
+	listeners, err := listener.Open(cfg.AppConfig.Listeners, net.JoinHostPort(cfg.BindAddress, cfg.Port))
+	if err != nil {
 		logging.ErrorMsg("Failed to start server: %v", err)
-		os.Exit(1) // Exit with error code if server fails to start
+		os.Exit(1)
+	}
+	lns := make([]net.Listener, len(listeners))
+	for i, l := range listeners {
+		logging.InfoMsg("ai-proxy server listening on %s", l.Description)
+		lns[i] = l
+	}
+
+	// Start server; it serves until it fails or a signal shuts it down
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(lns...)
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			logging.ErrorMsg("Failed to start server: %v", err)
+			os.Exit(1) // Exit with error code if server fails to start
+		}
+		return
+	case <-ctx.Done():
 	}
+	// A second signal terminates the process without waiting
+	stop()
+
+	// Stop accepting connections and let active streams finish; streams still
+	// running at the deadline are ended with their cancellation events
+	logging.InfoMsg("Shutting down, waiting up to %v for active streams", cfg.ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		logging.ErrorMsg("Closed connections still open at shutdown: %v", err)
+	}
+
+	// Finish pending capture writes; the deferred store.Close flushes conversations
+	captureMiddleware.Wait()
+	logging.InfoMsg("ai-proxy server stopped")
 }
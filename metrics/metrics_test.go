@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatalf("WritePrometheus() error: %v", err)
+	}
+	return b.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Requests handled.", "provider", "status")
+	c.Inc("b", "200")
+	c.Inc("a", "500")
+	c.Add(2, "a", "500")
+	c.Add(-1, "a", "500")
+
+	if got := c.Value("a", "500"); got != 3 {
+		t.Errorf("Value() = %v, want 3", got)
+	}
+	want := `# HELP test_requests_total Requests handled.
+# TYPE test_requests_total counter
+test_requests_total{provider="a",status="500"} 3
+test_requests_total{provider="b",status="200"} 1
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "provider")
+	h.Observe(0.05, "p")
+	h.Observe(0.5, "p")
+	h.Observe(1, "p")
+	h.Observe(3, "p")
+
+	if got := h.Count("p"); got != 4 {
+		t.Errorf("Count() = %d, want 4", got)
+	}
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{provider="p",le="0.1"} 1
+test_latency_seconds_bucket{provider="p",le="1"} 3
+test_latency_seconds_bucket{provider="p",le="+Inf"} 4
+test_latency_seconds_sum{provider="p"} 4.55
+test_latency_seconds_count{provider="p"} 4
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeFuncAndOrdering(t *testing.T) {
+	r := NewRegistry()
+	size := 7
+	r.NewGaugeFunc("test_b_size", "Size.", func() float64 { return float64(size) })
+	r.NewCounterVec("test_a_total", "Unlabeled.").Inc()
+
+	want := `# HELP test_a_total Unlabeled.
+# TYPE test_a_total counter
+test_a_total 1
+# HELP test_b_size Size.
+# TYPE test_b_size gauge
+test_b_size 7
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Help with \\ and\nnewline.", "model").Inc("a\"b\\c\nd")
+
+	got := scrape(t, r)
+	if !strings.Contains(got, `# HELP test_total Help with \\ and\nnewline.`) {
+		t.Errorf("help not escaped:\n%s", got)
+	}
+	if !strings.Contains(got, `test_total{model="a\"b\\c\nd"} 1`) {
+		t.Errorf("label value not escaped:\n%s", got)
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "x", "a")
+
+	assertPanics(t, "duplicate name", func() { r.NewCounterVec("test_total", "x") })
+	assertPanics(t, "label count mismatch", func() { c.Inc() })
+}
+
+func assertPanics(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: expected panic", name)
+		}
+	}()
+	fn()
+}
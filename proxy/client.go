@@ -6,13 +6,16 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"ai-proxy/capture"
+	"ai-proxy/config"
 	"ai-proxy/logging"
 )
 
@@ -29,6 +32,15 @@ type Client struct {
 	// httpClient is the underlying HTTP client for making requests.
 	// Configured with connection pooling and TLS settings.
 	httpClient *http.Client
+	// auth defines how apiKey is sent. The zero value sends a Bearer token.
+	auth config.ProviderAuth
+	// headers are static headers set on every request.
+	headers map[string]string
+	// defaultHeaders are set on requests that do not have them yet.
+	defaultHeaders map[string]string
+	// pooled is true when the transport is shared with other clients, so
+	// Close keeps its connections open.
+	pooled bool
 }
 
 // ClientOption is a functional option for configuring a Client.
@@ -47,18 +59,20 @@ type ClientOption func(*Client)
 // @pre apiKey is not empty for authenticated endpoints
 // @post returned client is initialized with sensible defaults
 // @note The client uses TLS 1.2 minimum for secure connections
+// @note Without WithTransport or WithPooledTransport the client gets its own transport
 func NewClient(baseURL, apiKey string, opts ...ClientOption) *Client {
 	c := &Client{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Transport: defaultTransport(), // Use secure transport with connection pooling
-		},
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{},
 	}
 	// Apply functional options in order, later options override earlier ones
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.httpClient.Transport == nil {
+		c.httpClient.Transport = defaultTransport() // Use secure transport with connection pooling
+	}
 	return c
 }
 
@@ -85,6 +99,59 @@ func WithTimeout(d time.Duration) ClientOption {
 func WithTransport(t *http.Transport) ClientOption {
 	return func(c *Client) {
 		c.httpClient.Transport = t
+		c.pooled = false
+	}
+}
+
+// WithPooledTransport returns a ClientOption that sends requests through a
+// provider's shared transport, reusing its open connections.
+//
+// @param t - the provider's transport from a Pool
+// @return ClientOption - a functional option to set the transport
+// @pre t must not be nil
+// @post Client will use the shared transport for all requests
+// @note Close keeps the shared transport's connections open for other clients
+func WithPooledTransport(t *Transport) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Transport = t
+		c.pooled = true
+	}
+}
+
+// WithAuth returns a ClientOption that sets how the API key is sent upstream.
+//
+// @param auth - the authentication type, and header or query parameter name
+// @return ClientOption - a functional option to set the authentication
+// @pre auth is valid per the config loader (name set for header and query types)
+// @post SetHeaders sends the API key as configured instead of a Bearer token
+// @note The custom header named by auth is masked in captures
+func WithAuth(auth config.ProviderAuth) ClientOption {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithHeaders returns a ClientOption that adds static headers to every request.
+//
+// @param headers - header names and values, e.g. tenant IDs
+// @return ClientOption - a functional option to set the headers
+// @post SetHeaders sets the headers, replacing values already on the request
+// @note The headers are masked in captures
+func WithHeaders(headers map[string]string) ClientOption {
+	return func(c *Client) {
+		c.headers = headers
+	}
+}
+
+// WithDefaultHeaders returns a ClientOption that adds headers to requests
+// that do not have them, such as anthropic-version when the client sent none.
+//
+// @param headers - header names and values
+// @return ClientOption - a functional option to set the default headers
+// @post SetHeaders sets each header only if the request does not have it
+func WithDefaultHeaders(headers map[string]string) ClientOption {
+	return func(c *Client) {
+		c.defaultHeaders = headers
 	}
 }
 
@@ -140,19 +207,30 @@ func (c *Client) BuildRequest(ctx context.Context, body []byte) (*http.Request,
 	return req, nil
 }
 
-// SetHeaders sets the required headers on the request including Content-Type, Authorization, and Accept headers.
+// SetHeaders sets the required headers on the request including Content-Type, authentication, and Accept headers,
+// then the default and static headers.
 // It also records sanitized headers in the capture context if one exists.
 //
 // @param req - the HTTP request to modify
 // @pre req must not be nil
 // @post Request has Content-Type: application/json
-// @post Request has Authorization: Bearer <apiKey>
+// @post Request carries the API key as configured by WithAuth, Authorization: Bearer <apiKey> by default
 // @post Request has Accept: text/event-stream for SSE support
-// @note Authorization header value is sanitized in capture logs
+// @post Request has the static headers and any default header it did not have
+// @note Call after headers forwarded from the client are set, so static headers replace them
+// @note Authentication and static header values are sanitized in capture logs
 func (c *Client) SetHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	c.setAuth(req)
 	req.Header.Set("Accept", "text/event-stream") // Required for streaming responses
+	for name, value := range c.defaultHeaders {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
 
 	// Record headers for debugging, sanitizing sensitive values
 	if cc := capture.GetCaptureContext(req.Context()); cc != nil {
@@ -164,10 +242,66 @@ func (c *Client) SetHeaders(req *http.Request) {
 		if cc.Recorder.Data().UpstreamRequest != nil {
 			body = cc.Recorder.Data().UpstreamRequest.Body
 		}
-		cc.Recorder.RecordUpstreamRequest(req.Header, body)
+		cc.Recorder.RecordUpstreamRequest(req.Header, body, c.sensitiveHeaders()...)
+	}
+}
+
+// setAuth sends the API key as configured by WithAuth.
+//
+// @param req - the HTTP request to modify
+// @post A query parameter key replaces a parameter of the same name in req.URL
+func (c *Client) setAuth(req *http.Request) {
+	switch c.auth.Type {
+	case config.AuthXAPIKey:
+		req.Header.Set("X-Api-Key", c.apiKey)
+	case config.AuthHeader:
+		req.Header.Set(c.auth.Name, c.apiKey)
+	case config.AuthQuery:
+		query := req.URL.Query()
+		query.Set(c.auth.Name, c.apiKey)
+		req.URL.RawQuery = query.Encode()
+	default:
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
 }
 
+// redactAuth masks a query parameter API key in the URL of a failed request's
+// error, which http.Client.Do includes in the error text, so the key does not
+// reach logs, captures or the event feed.
+//
+// @param err - the error returned by http.Client.Do, modified in place
+func (c *Client) redactAuth(err error) {
+	var urlErr *url.Error
+	if c.auth.Type != config.AuthQuery || !errors.As(err, &urlErr) {
+		return
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		urlErr.URL = ""
+		return
+	}
+	query := u.Query()
+	if query.Has(c.auth.Name) {
+		query.Set(c.auth.Name, "REDACTED")
+		u.RawQuery = query.Encode()
+	}
+	urlErr.URL = u.String()
+}
+
+// sensitiveHeaders returns the names of headers masked in captures beyond
+// the ones capture.SanitizeHeaders always masks: the custom auth header and
+// the static headers.
+func (c *Client) sensitiveHeaders() []string {
+	names := make([]string, 0, len(c.headers)+1)
+	if c.auth.Type == config.AuthHeader {
+		names = append(names, c.auth.Name)
+	}
+	for name := range c.headers {
+		names = append(names, name)
+	}
+	return names
+}
+
 // Do executes the HTTP request and returns the response.
 // It logs the request and captures response metadata for debugging.
 //
@@ -181,6 +315,7 @@ func (c *Client) SetHeaders(req *http.Request) {
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		c.redactAuth(err)
 		logging.ErrorMsg("Upstream request failed: %v", err)
 		return nil, fmt.Errorf("upstream request: %w", err)
 	}
@@ -198,9 +333,13 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 // This should be called when the client is no longer needed to release resources.
 //
 // @pre Client is no longer in use
-// @post All idle connections are closed
+// @post All idle connections are closed, unless the transport is pooled
 // @note Active connections are not affected; they close when their requests complete
+// @note A pooled transport's connections are kept for the provider's next requests
 func (c *Client) Close() {
+	if c.pooled {
+		return
+	}
 	c.httpClient.CloseIdleConnections()
 }
 
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"ai-proxy/capture"
+	"ai-proxy/config"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -182,6 +184,87 @@ func TestSetHeaders_WithCaptureContext(t *testing.T) {
 	}
 }
 
+func TestSetHeaders_WithAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       config.ProviderAuth
+		wantHeader string
+		wantQuery  string
+	}{
+		{name: "bearer", auth: config.ProviderAuth{Type: config.AuthBearer}, wantHeader: "Authorization"},
+		{name: "x-api-key", auth: config.ProviderAuth{Type: config.AuthXAPIKey}, wantHeader: "X-Api-Key"},
+		{name: "custom header", auth: config.ProviderAuth{Type: config.AuthHeader, Name: "X-Gateway-Key"}, wantHeader: "X-Gateway-Key"},
+		{name: "query", auth: config.ProviderAuth{Type: config.AuthQuery, Name: "key"}, wantQuery: "a=1&key=my-api-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("https://api.example.com", "my-api-key", WithAuth(tt.auth))
+			req := httptest.NewRequest("POST", "/test?a=1", nil)
+
+			client.SetHeaders(req)
+
+			for _, name := range []string{"Authorization", "X-Api-Key", "X-Gateway-Key"} {
+				want := ""
+				if name == tt.wantHeader {
+					want = "my-api-key"
+					if name == "Authorization" {
+						want = "Bearer my-api-key"
+					}
+				}
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.wantQuery != "" && req.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestSetHeaders_StaticAndDefaultHeaders(t *testing.T) {
+	client := NewClient("https://api.example.com", "my-api-key",
+		WithHeaders(map[string]string{"X-Tenant-Id": "acme"}),
+		WithDefaultHeaders(map[string]string{"Anthropic-Version": "2023-06-01", "Anthropic-Beta": "tools"}))
+	req := httptest.NewRequest("POST", "/test", nil)
+	// Headers forwarded from the client
+	req.Header.Set("X-Tenant-Id", "other")
+	req.Header.Set("Anthropic-Version", "2024-01-01")
+
+	client.SetHeaders(req)
+
+	if got := req.Header.Get("X-Tenant-Id"); got != "acme" {
+		t.Errorf("static header should replace the forwarded one, got %q", got)
+	}
+	if got := req.Header.Get("Anthropic-Version"); got != "2024-01-01" {
+		t.Errorf("default header should not replace the forwarded one, got %q", got)
+	}
+	if got := req.Header.Get("Anthropic-Beta"); got != "tools" {
+		t.Errorf("default header should be added when missing, got %q", got)
+	}
+}
+
+func TestSetHeaders_CaptureMasksProviderHeaders(t *testing.T) {
+	client := NewClient("https://api.example.com", "secret-key",
+		WithAuth(config.ProviderAuth{Type: config.AuthHeader, Name: "X-Gateway-Key"}),
+		WithHeaders(map[string]string{"X-Tenant-Id": "acme"}))
+	httpReq := httptest.NewRequest("POST", "/test", nil)
+	cc := capture.NewCaptureContext(httpReq)
+	ctx := capture.WithCaptureContext(context.Background(), cc)
+	req := httptest.NewRequest("POST", "/test", nil).WithContext(ctx)
+
+	client.SetHeaders(req)
+
+	headers := cc.Recorder.Data().UpstreamRequest.Headers
+	if headers["X-Gateway-Key"] != "***" || headers["X-Tenant-Id"] != "***" {
+		t.Errorf("expected auth and static headers masked, got %v", headers)
+	}
+	if headers["Accept"] != "text/event-stream" {
+		t.Errorf("expected Accept recorded, got %v", headers)
+	}
+}
+
 func TestDo(t *testing.T) {
 	client := NewClient("https://api.example.com/test", "test-key")
 	client.httpClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
@@ -267,6 +350,34 @@ func TestDo_Error(t *testing.T) {
 	}
 }
 
+func TestDo_ErrorRedactsQueryAuth(t *testing.T) {
+	// A port nobody listens on, so the dial fails
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	endpoint := "http://" + ln.Addr().String() + "/v1/chat/completions?a=1"
+	ln.Close()
+
+	client := NewClient(endpoint, "secret-api-key", WithAuth(config.ProviderAuth{Type: config.AuthQuery, Name: "key"}))
+	req, err := client.BuildRequest(context.Background(), []byte(`{}`))
+	if err != nil {
+		t.Fatalf("BuildRequest returned error: %v", err)
+	}
+	client.SetHeaders(req)
+
+	_, err = client.Do(req)
+	if err == nil {
+		t.Fatal("expected the dial to fail")
+	}
+	if strings.Contains(err.Error(), "secret-api-key") {
+		t.Errorf("error leaks the API key: %v", err)
+	}
+	if !strings.Contains(err.Error(), "key=REDACTED") || !strings.Contains(err.Error(), "a=1") {
+		t.Errorf("expected the URL with the key masked, got %v", err)
+	}
+}
+
 func TestClose(t *testing.T) {
 	client := NewClient("https://api.example.com", "test-key")
 	client.Close()
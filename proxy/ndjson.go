@@ -0,0 +1,57 @@
+// Package proxy provides an HTTP client for making requests to upstream LLM APIs.
+// This file implements the framing adapter for upstreams streaming NDJSON.
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// NDJSONEventReader reframes a newline-delimited JSON stream, as streamed by
+// Ollama and llama-server's native APIs, as server-sent events: each non-empty
+// line becomes the data of one event. The SSE reader and the transformers
+// then consume NDJSON upstreams like any other upstream.
+//
+// Thread Safety: NOT thread-safe. Use from a single goroutine.
+type NDJSONEventReader struct {
+	// src reads the NDJSON stream.
+	src *bufio.Reader
+	// pending holds framed output not yet returned by Read.
+	pending bytes.Buffer
+	// err is the error that ended src, returned once pending is drained.
+	err error
+}
+
+// NewNDJSONEventReader creates a reader framing the NDJSON stream r as SSE.
+//
+// @param r - the NDJSON stream, typically an upstream response body
+// @return *NDJSONEventReader - reader producing "data: <line>\n\n" events
+func NewNDJSONEventReader(r io.Reader) *NDJSONEventReader {
+	return &NDJSONEventReader{src: bufio.NewReader(r)}
+}
+
+// Read implements io.Reader. It returns framed events, reading one more line
+// from the source whenever the framed output is drained. A final line without
+// a trailing newline is framed as well, unless the source failed.
+//
+// @return The source's error, io.EOF at the end, once all framed data is read.
+func (r *NDJSONEventReader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		line, err := r.src.ReadBytes('\n')
+		// A line cut off by a failed read is incomplete JSON; drop it
+		if err != nil && err != io.EOF {
+			line = nil
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			r.pending.WriteString("data: ")
+			r.pending.Write(line)
+			r.pending.WriteString("\n\n")
+		}
+		r.err = err
+	}
+	return r.pending.Read(p)
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNDJSONEventReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "lines",
+			input: "{\"a\":1}\n{\"b\":2}\n",
+			want:  "data: {\"a\":1}\n\ndata: {\"b\":2}\n\n",
+		},
+		{
+			name:  "blank lines and CRLF are skipped",
+			input: "{\"a\":1}\r\n\n  \n{\"b\":2}\n",
+			want:  "data: {\"a\":1}\n\ndata: {\"b\":2}\n\n",
+		},
+		{
+			name:  "final line without newline",
+			input: "{\"a\":1}\n{\"done\":true}",
+			want:  "data: {\"a\":1}\n\ndata: {\"done\":true}\n\n",
+		},
+		{
+			name:  "empty",
+			input: "",
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time, so lines span many source reads
+			got, err := io.ReadAll(NewNDJSONEventReader(iotest.OneByteReader(strings.NewReader(tt.input))))
+			if err != nil {
+				t.Fatalf("ReadAll returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSONEventReader_SmallBuffer(t *testing.T) {
+	r := NewNDJSONEventReader(strings.NewReader("{\"message\":\"hello\"}\n"))
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if want := "data: {\"message\":\"hello\"}\n\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNDJSONEventReader_SourceError(t *testing.T) {
+	boom := errors.New("connection reset")
+	src := io.MultiReader(strings.NewReader("{\"a\":1}\n{\"b\""), iotest.ErrReader(boom))
+
+	got, err := io.ReadAll(NewNDJSONEventReader(src))
+	if !errors.Is(err, boom) {
+		t.Errorf("expected source error, got %v", err)
+	}
+	// The line cut off by the error is dropped
+	if want := "data: {\"a\":1}\n\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,255 @@
+// Package proxy provides an HTTP client for making requests to upstream LLM APIs.
+// This file implements the shared per-provider connection pools.
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"ai-proxy/config"
+	"ai-proxy/logging"
+	"ai-proxy/metrics"
+)
+
+// Connection pool defaults, used when a provider does not configure connection_pool.
+const (
+	// DefaultMaxIdleConns is the number of idle connections kept per upstream host.
+	DefaultMaxIdleConns = 32
+	// DefaultIdleTimeout is how long an idle connection is kept open.
+	DefaultIdleTimeout = 90 * time.Second
+	// DefaultDialTimeout bounds establishing a TCP connection.
+	DefaultDialTimeout = 30 * time.Second
+	// DefaultTLSHandshakeTimeout bounds the TLS handshake.
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// upstreamConnectionsTotal counts the connections upstream requests were sent
+// on, by provider and whether the connection was reused from the pool.
+var upstreamConnectionsTotal = metrics.NewCounterVec("ai_proxy_upstream_connections_total",
+	"Connections upstream requests were sent on, by provider and whether an idle pooled connection was reused.",
+	"provider", "reused")
+
+// Transport is the shared HTTP transport of a provider. It keeps connections
+// open across requests, negotiates HTTP/2 where the upstream supports it, and
+// counts how often a pooled connection was reused.
+//
+// Thread Safety: Safe for concurrent use.
+type Transport struct {
+	provider string
+	base     *http.Transport
+
+	requests       atomic.Uint64
+	newConns       atomic.Uint64
+	reusedConns    atomic.Uint64
+	http2Responses atomic.Uint64
+	connectNanos   atomic.Int64
+}
+
+// PoolStats is a point-in-time snapshot of a provider transport's counters.
+type PoolStats struct {
+	Provider string `json:"provider"`
+	// Requests is the number of requests sent, including failed ones.
+	Requests uint64 `json:"requests"`
+	// NewConnections is the number of requests that opened a connection.
+	NewConnections uint64 `json:"new_connections"`
+	// ReusedConnections is the number of requests sent on a pooled connection.
+	ReusedConnections uint64 `json:"reused_connections"`
+	// HTTP2Responses is the number of responses received over HTTP/2.
+	HTTP2Responses uint64 `json:"http2_responses"`
+	// ConnectSeconds is the total time requests waited for new connections,
+	// including DNS, TCP and TLS setup.
+	ConnectSeconds float64 `json:"connect_seconds"`
+}
+
+// NewTransport creates the shared transport of a provider from its
+// connection pool, TLS and proxy configuration. Durations are assumed to be
+// validated by the config loader; invalid values fall back to the defaults.
+//
+// @param p - the provider
+// @return *Transport - the transport, without open connections
+// @return error - if a CA bundle or client certificate cannot be loaded, or
+// the proxy URL does not parse
+func NewTransport(p config.Provider) (*Transport, error) {
+	cfg := p.ConnectionPool
+	if cfg == nil {
+		cfg = &config.ConnectionPoolConfig{}
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = DefaultMaxIdleConns
+	}
+
+	base := defaultTransport()
+	base.DialContext = (&net.Dialer{
+		Timeout:   parseDurationOr(cfg.DialTimeout, DefaultDialTimeout),
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	base.MaxIdleConns = maxIdle
+	base.MaxIdleConnsPerHost = maxIdle
+	base.MaxConnsPerHost = cfg.MaxConns
+	base.IdleConnTimeout = parseDurationOr(cfg.IdleTimeout, DefaultIdleTimeout)
+	base.TLSHandshakeTimeout = parseDurationOr(cfg.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout)
+	if err := applyTLS(base.TLSClientConfig, p.TLS); err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	if p.TLS != nil && p.TLS.InsecureSkipVerify {
+		logging.InfoMsg("Provider '%s': TLS certificate verification is disabled", p.Name)
+	}
+	if p.ProxyURL != "" {
+		proxyURL, err := url.Parse(p.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("proxy_url: %w", err)
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map turns off HTTP/2 negotiation
+		base.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		// Custom dialers and TLS configs need HTTP/2 to be requested explicitly
+		base.ForceAttemptHTTP2 = true
+	}
+	return &Transport{provider: p.Name, base: base}, nil
+}
+
+// applyTLS adds a provider's CA bundle, client certificate, server name and
+// verification setting to a TLS configuration.
+//
+// @param tc - the transport's TLS configuration, modified in place
+// @param cfg - the provider TLS configuration, may be nil
+// @return error - if a file cannot be read or holds no valid PEM data
+func applyTLS(tc *tls.Config, cfg *config.TLSConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("ca_file: %w", err)
+		}
+		// The bundle extends the system roots, so public endpoints keep working
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ca_file: no certificates found in %s", cfg.CAFile)
+		}
+		tc.RootCAs = roots
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	tc.ServerName = cfg.ServerName
+	tc.InsecureSkipVerify = cfg.InsecureSkipVerify
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper, recording whether the request was
+// sent on a new or a pooled connection.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	var getConn time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.reusedConns.Add(1)
+			} else {
+				t.newConns.Add(1)
+				if !getConn.IsZero() {
+					t.connectNanos.Add(int64(time.Since(getConn)))
+				}
+			}
+			upstreamConnectionsTotal.Inc(t.provider, strconv.FormatBool(info.Reused))
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.ProtoMajor == 2 {
+		t.http2Responses.Add(1)
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes the transport's idle connections.
+// Connections in use are closed once their requests complete.
+func (t *Transport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// Stats returns a snapshot of the transport's counters.
+func (t *Transport) Stats() PoolStats {
+	return PoolStats{
+		Provider:          t.provider,
+		Requests:          t.requests.Load(),
+		NewConnections:    t.newConns.Load(),
+		ReusedConnections: t.reusedConns.Load(),
+		HTTP2Responses:    t.http2Responses.Load(),
+		ConnectSeconds:    time.Duration(t.connectNanos.Load()).Seconds(),
+	}
+}
+
+// Pool holds the shared transports of all providers.
+//
+// Thread Safety: Safe for concurrent use; the transport set is fixed at creation.
+type Pool struct {
+	providers  []string
+	transports map[string]*Transport
+}
+
+// NewPool creates one transport per provider.
+//
+// @param providers - the configured providers
+// @return *Pool - the pool, without open connections
+// @return error - if a provider's transport cannot be created
+func NewPool(providers []config.Provider) (*Pool, error) {
+	p := &Pool{transports: make(map[string]*Transport, len(providers))}
+	for _, provider := range providers {
+		t, err := NewTransport(provider)
+		if err != nil {
+			return nil, fmt.Errorf("provider '%s': %w", provider.Name, err)
+		}
+		p.providers = append(p.providers, provider.Name)
+		p.transports[provider.Name] = t
+	}
+	return p, nil
+}
+
+// Get returns the transport of a provider.
+//
+// @return *Transport - the transport, nil if the provider is unknown
+func (p *Pool) Get(provider string) *Transport {
+	return p.transports[provider]
+}
+
+// Stats returns a snapshot of every transport in provider configuration order.
+func (p *Pool) Stats() []PoolStats {
+	stats := make([]PoolStats, 0, len(p.providers))
+	for _, name := range p.providers {
+		stats = append(stats, p.transports[name].Stats())
+	}
+	return stats
+}
+
+// CloseIdleConnections closes the idle connections of every transport, e.g.
+// after a config reload replaced the pool.
+func (p *Pool) CloseIdleConnections() {
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,435 @@
+package proxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// newUpstream starts a TLS test server answering every request with a short
+// SSE body, negotiating HTTP/2 if http2 is set.
+func newUpstream(t testing.TB, http2 bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[]}\n\ndata: [DONE]\n\n")
+	}))
+	server.EnableHTTP2 = http2
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// trustServer makes transport trust the test server's certificate.
+func trustServer(transport *http.Transport, server *httptest.Server) {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	transport.TLSClientConfig.RootCAs = roots
+}
+
+// mustTransport creates the shared transport of a provider, failing the test on error.
+func mustTransport(t testing.TB, p config.Provider) *Transport {
+	t.Helper()
+	transport, err := NewTransport(p)
+	if err != nil {
+		t.Fatalf("NewTransport returned error: %v", err)
+	}
+	return transport
+}
+
+// newTrustingTransport returns the shared transport of a provider, trusting server.
+func newTrustingTransport(t testing.TB, server *httptest.Server, cfg *config.ConnectionPoolConfig) *Transport {
+	transport := mustTransport(t, config.Provider{Name: "p", ConnectionPool: cfg})
+	trustServer(transport.base, server)
+	return transport
+}
+
+// send posts a request through client and drains the response.
+func send(t testing.TB, client *Client) *http.Response {
+	t.Helper()
+	req, err := client.BuildRequest(context.Background(), []byte(`{}`))
+	if err != nil {
+		t.Fatalf("BuildRequest returned error: %v", err)
+	}
+	client.SetHeaders(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestNewTransport_Defaults(t *testing.T) {
+	transport := mustTransport(t, config.Provider{Name: "p"}).base
+
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConns || transport.MaxConnsPerHost != 0 {
+		t.Errorf("unexpected connection limits: idle=%d max=%d", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != DefaultIdleTimeout || transport.TLSHandshakeTimeout != DefaultTLSHandshakeTimeout {
+		t.Errorf("unexpected timeouts: idle=%v tls=%v", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("expected HTTP/2 to be attempted")
+	}
+}
+
+func TestNewTransport_Config(t *testing.T) {
+	transport := mustTransport(t, config.Provider{Name: "p", ConnectionPool: &config.ConnectionPoolConfig{
+		MaxIdleConns:        4,
+		MaxConns:            8,
+		IdleTimeout:         "30s",
+		TLSHandshakeTimeout: "5s",
+		DisableHTTP2:        true,
+	}}).base
+
+	if transport.MaxIdleConnsPerHost != 4 || transport.MaxConnsPerHost != 8 {
+		t.Errorf("unexpected connection limits: idle=%d max=%d", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 30*time.Second || transport.TLSHandshakeTimeout != 5*time.Second {
+		t.Errorf("unexpected timeouts: idle=%v tls=%v", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("expected HTTP/2 to be disabled")
+	}
+}
+
+func TestTransport_ReusesConnections(t *testing.T) {
+	server := newUpstream(t, false)
+	transport := newTrustingTransport(t, server, nil)
+	defer transport.CloseIdleConnections()
+	reusedBefore := upstreamConnectionsTotal.Value("p", "true")
+
+	for i := 0; i < 3; i++ {
+		client := NewClient(server.URL, "sk", WithPooledTransport(transport))
+		send(t, client)
+		// Closing a pooled client keeps the connection for the next one
+		client.Close()
+	}
+
+	stats := transport.Stats()
+	if stats.Requests != 3 || stats.NewConnections != 1 || stats.ReusedConnections != 2 {
+		t.Errorf("expected one connection reused twice, got %+v", stats)
+	}
+	if stats.ConnectSeconds <= 0 {
+		t.Errorf("expected the connection setup time to be recorded, got %v", stats.ConnectSeconds)
+	}
+	if got := upstreamConnectionsTotal.Value("p", "true") - reusedBefore; got != 2 {
+		t.Errorf("expected 2 reused connections in the metric, got %v", got)
+	}
+}
+
+func TestTransport_HTTP2(t *testing.T) {
+	server := newUpstream(t, true)
+	transport := newTrustingTransport(t, server, nil)
+	defer transport.CloseIdleConnections()
+
+	client := NewClient(server.URL, "sk", WithPooledTransport(transport))
+	if resp := send(t, client); resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, got %s", resp.Proto)
+	}
+	send(t, client)
+	if stats := transport.Stats(); stats.HTTP2Responses != 2 || stats.ReusedConnections != 1 {
+		t.Errorf("expected two HTTP/2 responses on one connection, got %+v", stats)
+	}
+}
+
+func TestTransport_DisableHTTP2(t *testing.T) {
+	server := newUpstream(t, true)
+	transport := newTrustingTransport(t, server, &config.ConnectionPoolConfig{DisableHTTP2: true})
+	defer transport.CloseIdleConnections()
+
+	if resp := send(t, NewClient(server.URL, "sk", WithPooledTransport(transport))); resp.ProtoMajor != 1 {
+		t.Errorf("expected HTTP/1.1, got %s", resp.Proto)
+	}
+}
+
+func TestClient_CloseUnpooled(t *testing.T) {
+	server := newUpstream(t, false)
+	transport := newTrustingTransport(t, server, nil)
+
+	// A client owning its transport throws its connections away on Close
+	client := NewClient(server.URL, "sk", WithTransport(transport.base))
+	send(t, client)
+	client.Close()
+	send(t, NewClient(server.URL, "sk", WithPooledTransport(transport)))
+
+	if stats := transport.Stats(); stats.NewConnections != 1 || stats.ReusedConnections != 0 {
+		t.Errorf("expected a new connection after Close, got %+v", stats)
+	}
+}
+
+func TestPool(t *testing.T) {
+	pool, err := NewPool([]config.Provider{{Name: "b"}, {Name: "a"}})
+	if err != nil {
+		t.Fatalf("NewPool returned error: %v", err)
+	}
+
+	if pool.Get("a") == nil || pool.Get("a") == pool.Get("b") {
+		t.Error("expected one transport per provider")
+	}
+	if pool.Get("unknown") != nil {
+		t.Error("expected no transport for an unknown provider")
+	}
+	stats := pool.Stats()
+	if len(stats) != 2 || stats[0].Provider != "b" || stats[1].Provider != "a" {
+		t.Errorf("expected stats in configuration order, got %+v", stats)
+	}
+	pool.CloseIdleConnections()
+}
+
+// benchmarkRequests sends requests to an HTTP/2 TLS upstream, creating the
+// client of each request with newClient.
+func benchmarkRequests(b *testing.B, newClient func(server *httptest.Server) *Client) {
+	server := newUpstream(b, true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client := newClient(server)
+		send(b, client)
+		client.Close()
+	}
+}
+
+// BenchmarkClient_TransportPerRequest measures requests on a fresh transport
+// each, paying for a TCP connection and TLS handshake every time.
+func BenchmarkClient_TransportPerRequest(b *testing.B) {
+	benchmarkRequests(b, func(server *httptest.Server) *Client {
+		transport := defaultTransport()
+		trustServer(transport, server)
+		return NewClient(server.URL, "sk", WithTransport(transport))
+	})
+}
+
+// BenchmarkClient_PooledTransport measures requests sharing a provider
+// transport, reusing its HTTP/2 connection.
+func BenchmarkClient_PooledTransport(b *testing.B) {
+	var transport *Transport
+	benchmarkRequests(b, func(server *httptest.Server) *Client {
+		if transport == nil {
+			transport = newTrustingTransport(b, server, nil)
+		}
+		return NewClient(server.URL, "sk", WithPooledTransport(transport))
+	})
+}
+
+// testCA is a certificate authority issuing certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCA creates a self-signed certificate authority.
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate returned error: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue creates a certificate for name, usable for the given purpose, and
+// returns it as a tls.Certificate and as PEM certificate and key.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate returned error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey returned error: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair returned error: %v", err)
+	}
+	return pair, certPEM, keyPEM
+}
+
+// writeFile writes data to a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	return path
+}
+
+// newMTLSUpstream starts an upstream serving a certificate for
+// gateway.internal from ca and requiring a client certificate issued by ca.
+func newMTLSUpstream(t *testing.T, ca *testCA) *httptest.Server {
+	t.Helper()
+	serverCert, _, _ := ca.issue(t, "gateway.internal", x509.ExtKeyUsageServerAuth)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTransport_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	server := newMTLSUpstream(t, ca)
+	_, certPEM, keyPEM := ca.issue(t, "ai-proxy", x509.ExtKeyUsageClientAuth)
+
+	transport := mustTransport(t, config.Provider{Name: "gateway", TLS: &config.TLSConfig{
+		CAFile:     writeFile(t, "ca.pem", ca.pem),
+		CertFile:   writeFile(t, "client.pem", certPEM),
+		KeyFile:    writeFile(t, "client-key.pem", keyPEM),
+		ServerName: "gateway.internal",
+	}})
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the mTLS request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ai-proxy" {
+		t.Errorf("expected the upstream to see the client certificate, got %q", body)
+	}
+}
+
+func TestTransport_MutualTLSWithoutClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	server := newMTLSUpstream(t, ca)
+
+	transport := mustTransport(t, config.Provider{Name: "gateway", TLS: &config.TLSConfig{
+		CAFile:     writeFile(t, "ca.pem", ca.pem),
+		ServerName: "gateway.internal",
+	}})
+	defer transport.CloseIdleConnections()
+
+	if resp, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the upstream to reject a connection without a client certificate")
+	}
+}
+
+func TestTransport_UntrustedCA(t *testing.T) {
+	server := newUpstream(t, false)
+
+	// Without the test server's CA, verification fails unless it is skipped
+	strict := mustTransport(t, config.Provider{Name: "p"})
+	if resp, err := (&http.Client{Transport: strict}).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected an untrusted certificate to be rejected")
+	}
+	insecure := mustTransport(t, config.Provider{Name: "p", TLS: &config.TLSConfig{InsecureSkipVerify: true}})
+	resp, err := (&http.Client{Transport: insecure}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected insecure_skip_verify to accept the certificate, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransport_TLSErrors(t *testing.T) {
+	ca := newTestCA(t)
+	tests := []struct {
+		name        string
+		tls         *config.TLSConfig
+		errContains string
+	}{
+		{"missing CA file", &config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, "ca_file"},
+		{"CA file without certificates", &config.TLSConfig{CAFile: writeFile(t, "ca.pem", []byte("not a certificate"))}, "no certificates found"},
+		{"key not matching certificate", &config.TLSConfig{
+			CertFile: writeFile(t, "client.pem", ca.pem),
+			KeyFile:  writeFile(t, "client-key.pem", []byte("not a key")),
+		}, "client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTransport(config.Provider{Name: "p", TLS: tt.tls})
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected an error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestTransport_ProxyURL(t *testing.T) {
+	var proxied string
+	egress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute upstream URL
+		proxied = r.URL.String()
+		io.WriteString(w, "ok")
+	}))
+	defer egress.Close()
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+
+	transport := mustTransport(t, config.Provider{Name: "p", ProxyURL: egress.URL})
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get("http://llm.internal/v1/models")
+	if err != nil {
+		t.Fatalf("expected the request to go through the proxy, got %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://llm.internal/v1/models" {
+		t.Errorf("expected the proxy to receive the upstream URL, got %q", proxied)
+	}
+}
+
+func TestNewPool_Error(t *testing.T) {
+	_, err := NewPool([]config.Provider{{Name: "gateway", TLS: &config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}}})
+	if err == nil || !strings.Contains(err.Error(), "provider 'gateway': tls: ca_file") {
+		t.Errorf("expected the provider to be named in the error, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Package proxy provides an HTTP client for making requests to upstream LLM APIs.
+// This file implements the retry policy for failed upstream requests.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// Retry policy defaults applied by NewRetryPolicy.
+const (
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 10 * time.Second
+	DefaultMultiplier     = 2.0
+	DefaultJitter         = 0.2
+)
+
+// DefaultRetryableStatuses are the upstream status codes retried when a
+// provider does not configure its own list.
+var DefaultRetryableStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// randFloat returns a pseudo-random number in [0, 1). Replaced in tests.
+var randFloat = rand.Float64
+
+// RetryPolicy controls how failed upstream requests are retried.
+// The zero value performs a single attempt.
+//
+// Thread Safety: Value type; safe for concurrent use.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts and the accepted Retry-After.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each attempt.
+	Multiplier float64
+	// Jitter randomizes each backoff by up to this fraction in either direction.
+	Jitter float64
+	// RetryableStatuses lists the upstream status codes that are retried.
+	RetryableStatuses []int
+}
+
+// NewRetryPolicy builds a policy from provider configuration, filling defaults.
+// Durations are assumed to be validated by the config loader; invalid values
+// fall back to the defaults.
+//
+// @param cfg - the provider retry configuration, may be nil
+// @return RetryPolicy - a single-attempt policy if cfg is nil or disables retries
+func NewRetryPolicy(cfg *config.RetryConfig) RetryPolicy {
+	if cfg == nil || cfg.MaxAttempts < 2 {
+		return RetryPolicy{MaxAttempts: 1}
+	}
+
+	p := RetryPolicy{
+		MaxAttempts:       cfg.MaxAttempts,
+		InitialBackoff:    parseDurationOr(cfg.InitialBackoff, DefaultInitialBackoff),
+		MaxBackoff:        parseDurationOr(cfg.MaxBackoff, DefaultMaxBackoff),
+		Multiplier:        cfg.Multiplier,
+		Jitter:            DefaultJitter,
+		RetryableStatuses: cfg.RetryableStatuses,
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultMultiplier
+	}
+	if cfg.Jitter != nil {
+		p.Jitter = *cfg.Jitter
+	}
+	if len(p.RetryableStatuses) == 0 {
+		p.RetryableStatuses = DefaultRetryableStatuses
+	}
+	return p
+}
+
+// parseDurationOr parses s, returning def if s is empty or invalid.
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// Attempts returns the total number of attempts allowed, at least 1.
+func (p RetryPolicy) Attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// IsRetryableStatus reports whether an upstream status code should be retried.
+func (p RetryPolicy) IsRetryableStatus(status int) bool {
+	for _, s := range p.RetryableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// NextDelay decides whether a failed attempt is retried and how long to wait.
+// Transport errors are retried unless the request context was cancelled;
+// responses are retried if their status is retryable. A Retry-After header is
+// honored as the delay, and a Retry-After longer than MaxBackoff ends retrying.
+//
+// @param attempt - the 1-based number of the attempt that just failed
+// @param resp - the upstream response, nil on transport error
+// @param err - the transport error, nil if a response was received
+// @return time.Duration - the delay before the next attempt
+// @return bool - false if the attempt must not be retried
+func (p RetryPolicy) NextDelay(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.Attempts() {
+		return 0, false
+	}
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+	if resp == nil || !p.IsRetryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	if retryAfter, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		if retryAfter > p.MaxBackoff {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+	return p.backoff(attempt), true
+}
+
+// backoff returns the jittered exponential backoff after the given attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		// Spread uniformly over [d*(1-jitter), d*(1+jitter)]
+		d *= 1 + p.Jitter*(2*randFloat()-1)
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// ParseRetryAfter parses a Retry-After header value in either delay-seconds
+// or HTTP-date form.
+//
+// @param value - the header value
+// @param now - the reference time for HTTP-date values
+// @return time.Duration - the delay, never negative
+// @return bool - false if the header is empty or malformed
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+func withFixedRand(t *testing.T, v float64) {
+	t.Helper()
+	old := randFloat
+	randFloat = func() float64 { return v }
+	t.Cleanup(func() { randFloat = old })
+}
+
+func statusResponse(status int, retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return resp
+}
+
+func TestNewRetryPolicy_Disabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.RetryConfig
+	}{
+		{"nil config", nil},
+		{"zero attempts", &config.RetryConfig{}},
+		{"single attempt", &config.RetryConfig{MaxAttempts: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRetryPolicy(tt.cfg)
+			if p.Attempts() != 1 {
+				t.Errorf("expected 1 attempt, got %d", p.Attempts())
+			}
+			if _, retry := p.NextDelay(1, statusResponse(503, ""), nil); retry {
+				t.Error("expected no retry")
+			}
+		})
+	}
+}
+
+func TestNewRetryPolicy_Defaults(t *testing.T) {
+	p := NewRetryPolicy(&config.RetryConfig{MaxAttempts: 3})
+
+	if p.MaxAttempts != 3 {
+		t.Errorf("expected MaxAttempts 3, got %d", p.MaxAttempts)
+	}
+	if p.InitialBackoff != DefaultInitialBackoff {
+		t.Errorf("expected InitialBackoff %v, got %v", DefaultInitialBackoff, p.InitialBackoff)
+	}
+	if p.MaxBackoff != DefaultMaxBackoff {
+		t.Errorf("expected MaxBackoff %v, got %v", DefaultMaxBackoff, p.MaxBackoff)
+	}
+	if p.Multiplier != DefaultMultiplier {
+		t.Errorf("expected Multiplier %v, got %v", DefaultMultiplier, p.Multiplier)
+	}
+	if p.Jitter != DefaultJitter {
+		t.Errorf("expected Jitter %v, got %v", DefaultJitter, p.Jitter)
+	}
+	for _, status := range DefaultRetryableStatuses {
+		if !p.IsRetryableStatus(status) {
+			t.Errorf("expected status %d to be retryable", status)
+		}
+	}
+}
+
+func TestNewRetryPolicy_Configured(t *testing.T) {
+	jitter := 0.0
+	p := NewRetryPolicy(&config.RetryConfig{
+		MaxAttempts:       4,
+		InitialBackoff:    "100ms",
+		MaxBackoff:        "2s",
+		Multiplier:        3,
+		Jitter:            &jitter,
+		RetryableStatuses: []int{529},
+	})
+
+	if p.InitialBackoff != 100*time.Millisecond {
+		t.Errorf("expected InitialBackoff 100ms, got %v", p.InitialBackoff)
+	}
+	if p.MaxBackoff != 2*time.Second {
+		t.Errorf("expected MaxBackoff 2s, got %v", p.MaxBackoff)
+	}
+	if p.Multiplier != 3 {
+		t.Errorf("expected Multiplier 3, got %v", p.Multiplier)
+	}
+	if p.Jitter != 0 {
+		t.Errorf("expected Jitter 0, got %v", p.Jitter)
+	}
+	if !p.IsRetryableStatus(529) {
+		t.Error("expected 529 to be retryable")
+	}
+	if p.IsRetryableStatus(503) {
+		t.Error("expected 503 not to be retryable when statuses are configured")
+	}
+}
+
+func TestRetryPolicy_NextDelay_Backoff(t *testing.T) {
+	withFixedRand(t, 0.5) // no jitter offset
+	p := RetryPolicy{
+		MaxAttempts:       5,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        350 * time.Millisecond,
+		Multiplier:        2,
+		Jitter:            0.2,
+		RetryableStatuses: DefaultRetryableStatuses,
+	}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 350 * time.Millisecond, 350 * time.Millisecond}
+	for i, w := range want {
+		delay, retry := p.NextDelay(i+1, statusResponse(503, ""), nil)
+		if !retry {
+			t.Fatalf("attempt %d: expected retry", i+1)
+		}
+		if delay != w {
+			t.Errorf("attempt %d: expected delay %v, got %v", i+1, w, delay)
+		}
+	}
+
+	if _, retry := p.NextDelay(5, statusResponse(503, ""), nil); retry {
+		t.Error("expected no retry after the last attempt")
+	}
+}
+
+func TestRetryPolicy_NextDelay_JitterBounds(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:       2,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        time.Minute,
+		Multiplier:        2,
+		Jitter:            0.2,
+		RetryableStatuses: DefaultRetryableStatuses,
+	}
+
+	tests := []struct {
+		rand float64
+		want time.Duration
+	}{
+		{0, 800 * time.Millisecond},
+		{0.5, time.Second},
+		{0.999999, 1200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("rand=%v", tt.rand), func(t *testing.T) {
+			withFixedRand(t, tt.rand)
+			delay, _ := p.NextDelay(1, statusResponse(429, ""), nil)
+			if diff := delay - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("expected delay ~%v, got %v", tt.want, delay)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_NextDelay_Statuses(t *testing.T) {
+	p := NewRetryPolicy(&config.RetryConfig{MaxAttempts: 3})
+
+	tests := []struct {
+		status int
+		retry  bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			_, retry := p.NextDelay(1, statusResponse(tt.status, ""), nil)
+			if retry != tt.retry {
+				t.Errorf("status %d: expected retry=%v, got %v", tt.status, tt.retry, retry)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_NextDelay_TransportErrors(t *testing.T) {
+	p := NewRetryPolicy(&config.RetryConfig{MaxAttempts: 3})
+
+	if _, retry := p.NextDelay(1, nil, errors.New("connection refused")); !retry {
+		t.Error("expected transport error to be retried")
+	}
+	if _, retry := p.NextDelay(1, nil, fmt.Errorf("request failed: %w", context.Canceled)); retry {
+		t.Error("expected cancelled request not to be retried")
+	}
+	if _, retry := p.NextDelay(1, nil, context.DeadlineExceeded); retry {
+		t.Error("expected deadline exceeded not to be retried")
+	}
+}
+
+func TestRetryPolicy_NextDelay_RetryAfter(t *testing.T) {
+	p := NewRetryPolicy(&config.RetryConfig{MaxAttempts: 3, MaxBackoff: "5s"})
+
+	delay, retry := p.NextDelay(1, statusResponse(429, "2"), nil)
+	if !retry {
+		t.Fatal("expected retry")
+	}
+	if delay != 2*time.Second {
+		t.Errorf("expected Retry-After delay 2s, got %v", delay)
+	}
+
+	if _, retry := p.NextDelay(1, statusResponse(429, "60"), nil); retry {
+		t.Error("expected no retry when Retry-After exceeds max backoff")
+	}
+
+	// A malformed header falls back to exponential backoff
+	if _, retry := p.NextDelay(1, statusResponse(503, "later"), nil); !retry {
+		t.Error("expected retry with malformed Retry-After")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "3", 3 * time.Second, true},
+		{"zero seconds", "0", 0, true},
+		{"negative seconds", "-1", 0, false},
+		{"http date", now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{"past http date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"malformed", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+// Package ratelimit implements per-client request rate limits and rolling
+// token and spend quotas.
+//
+// A Limiter enforces one LimitsConfig for one scope, such as a client key or a
+// client's use of a model alias: a token bucket for the request rate, and the
+// input plus output tokens and dollar cost charged over the last hour and day.
+// Requests are admitted against all limiters of their scopes at once and charged
+// once their upstream usage is known, so a request can overshoot a quota that
+// was not yet exhausted when it started.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// Quota windows.
+const (
+	Hour = time.Hour
+	Day  = 24 * time.Hour
+)
+
+// timeNow is replaced in tests.
+var timeNow = time.Now
+
+// LimitError is returned when a request exceeds a limit.
+type LimitError struct {
+	// Scope is the scope of the exceeded limiter.
+	Scope string
+	// Limit is the exceeded limit: "requests", "tokens" or "spend".
+	Limit string
+	// Window is the limit's window: "minute" for the request rate, else "hour" or "day".
+	Window string
+	// RetryAfter is how long until the limit admits requests again.
+	RetryAfter time.Duration
+}
+
+// Error describes the exceeded limit.
+func (e *LimitError) Error() string {
+	if e.Limit == "requests" {
+		return fmt.Sprintf("request rate limit exceeded for %s", e.Scope)
+	}
+	window := "hourly"
+	if e.Window == "day" {
+		window = "daily"
+	}
+	return fmt.Sprintf("%s %s quota exceeded for %s", window, tokenOrSpend(e.Limit), e.Scope)
+}
+
+// tokenOrSpend names a quota limit in error messages.
+func tokenOrSpend(limit string) string {
+	if limit == "tokens" {
+		return "token"
+	}
+	return "spend"
+}
+
+// Window reports the state of one limit for rate-limit response headers.
+type Window struct {
+	// Limit is the configured limit: the bucket size, tokens, or dollars.
+	Limit float64
+	// Remaining is what is left of the limit, never negative.
+	Remaining float64
+	// Reset is how long until the limit is fully available again.
+	Reset time.Duration
+}
+
+// Status reports the tightest request, token and spend limits of a request's scopes.
+// A nil field means no limit of that kind applies.
+type Status struct {
+	Requests *Window
+	Tokens   *Window
+	Spend    *Window
+}
+
+// charge is the usage of one finished request.
+type charge struct {
+	at     time.Time
+	tokens int64
+	cost   float64
+}
+
+// Limiter enforces the limits of one scope.
+//
+// All methods are safe to call on a nil *Limiter, which limits nothing.
+//
+// Thread Safety: Safe for concurrent use.
+type Limiter struct {
+	scope string
+
+	mu       sync.Mutex
+	cfg      config.LimitsConfig
+	bucket   float64   // available requests in the token bucket
+	filled   time.Time // when bucket was last refilled
+	charges  []charge  // oldest first, none older than Day
+	lastUsed time.Time // when the limiter was last returned by Get or charged
+}
+
+// rate returns the bucket refill rate in requests per second and its size.
+func (l *Limiter) rate() (perSecond, burst float64) {
+	perSecond = l.cfg.RequestsPerMinute / 60
+	burst = float64(l.cfg.Burst)
+	if burst == 0 {
+		burst = math.Max(1, math.Ceil(l.cfg.RequestsPerMinute))
+	}
+	return perSecond, burst
+}
+
+// refill adds the requests accrued since the last refill to the bucket.
+//
+// @pre l.mu is held and l.cfg.RequestsPerMinute > 0
+func (l *Limiter) refill(now time.Time) {
+	perSecond, burst := l.rate()
+	if l.filled.IsZero() {
+		l.bucket = burst
+	} else {
+		l.bucket = math.Min(burst, l.bucket+now.Sub(l.filled).Seconds()*perSecond)
+	}
+	l.filled = now
+}
+
+// prune drops charges older than the longest quota window.
+//
+// @pre l.mu is held
+func (l *Limiter) prune(now time.Time) {
+	i := 0
+	for i < len(l.charges) && now.Sub(l.charges[i].at) >= Day {
+		i++
+	}
+	l.charges = l.charges[i:]
+}
+
+// quota describes one rolling quota of the limiter.
+type quota struct {
+	limit  string // "tokens" or "spend"
+	window string // "hour" or "day"
+	span   time.Duration
+	max    float64
+	amount func(charge) float64
+}
+
+// quotas returns the limiter's configured rolling quotas.
+//
+// @pre l.mu is held
+func (l *Limiter) quotas() []quota {
+	tokens := func(c charge) float64 { return float64(c.tokens) }
+	cost := func(c charge) float64 { return c.cost }
+	all := []quota{
+		{"tokens", "hour", Hour, float64(l.cfg.TokensPerHour), tokens},
+		{"tokens", "day", Day, float64(l.cfg.TokensPerDay), tokens},
+		{"spend", "hour", Hour, l.cfg.SpendPerHour, cost},
+		{"spend", "day", Day, l.cfg.SpendPerDay, cost},
+	}
+	configured := all[:0]
+	for _, q := range all {
+		if q.max > 0 {
+			configured = append(configured, q)
+		}
+	}
+	return configured
+}
+
+// used returns the amount charged against q within its window, and how long
+// until the oldest charge in the window expires.
+//
+// @pre l.mu is held
+func (l *Limiter) used(q quota, now time.Time) (total float64, firstExpiry time.Duration) {
+	for _, c := range l.charges {
+		if age := now.Sub(c.at); age < q.span {
+			if firstExpiry == 0 {
+				firstExpiry = q.span - age
+			}
+			total += q.amount(c)
+		}
+	}
+	return total, firstExpiry
+}
+
+// retryAfter returns how long until enough charges leave q's window for its
+// usage to drop below the limit.
+//
+// @pre l.mu is held
+func (l *Limiter) retryAfter(q quota, used float64, now time.Time) time.Duration {
+	for _, c := range l.charges {
+		age := now.Sub(c.at)
+		if age >= q.span {
+			continue
+		}
+		used -= q.amount(c)
+		if used < q.max {
+			return q.span - age
+		}
+	}
+	return q.span
+}
+
+// checkQuotas returns a LimitError if a quota is used up.
+func (l *Limiter) checkQuotas(now time.Time) *LimitError {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+	for _, q := range l.quotas() {
+		if used, _ := l.used(q, now); used >= q.max {
+			return &LimitError{Scope: l.scope, Limit: q.limit, Window: q.window, RetryAfter: l.retryAfter(q, used, now)}
+		}
+	}
+	return nil
+}
+
+// take removes one request from the token bucket.
+func (l *Limiter) take(now time.Time) *LimitError {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.RequestsPerMinute <= 0 {
+		return nil
+	}
+	l.refill(now)
+	if l.bucket < 1 {
+		perSecond, _ := l.rate()
+		wait := time.Duration((1 - l.bucket) / perSecond * float64(time.Second))
+		return &LimitError{Scope: l.scope, Limit: "requests", Window: "minute", RetryAfter: wait}
+	}
+	l.bucket--
+	return nil
+}
+
+// refund returns a request taken by take to the token bucket.
+func (l *Limiter) refund() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.RequestsPerMinute > 0 {
+		_, burst := l.rate()
+		l.bucket = math.Min(burst, l.bucket+1)
+	}
+}
+
+// Charge records the usage of a finished request against the quotas.
+//
+// @param tokens - input plus output tokens used
+// @param cost - dollar cost of the request
+func (l *Limiter) Charge(tokens int64, cost float64) {
+	if l == nil || (tokens <= 0 && cost <= 0) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := timeNow()
+	l.prune(now)
+	l.charges = append(l.charges, charge{at: now, tokens: tokens, cost: cost})
+	l.lastUsed = now
+}
+
+// idle reports whether the limiter has not been used for the longest quota
+// window, so it holds no charges and a full bucket, like a new limiter.
+func (l *Limiter) idle(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Sub(l.lastUsed) >= Day
+}
+
+// status reports the limiter's limits for rate-limit headers.
+func (l *Limiter) status(now time.Time) Status {
+	var s Status
+	if l == nil {
+		return s
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.RequestsPerMinute > 0 {
+		l.refill(now)
+		perSecond, burst := l.rate()
+		s.Requests = &Window{
+			Limit:     burst,
+			Remaining: math.Floor(l.bucket),
+			Reset:     time.Duration((burst - l.bucket) / perSecond * float64(time.Second)),
+		}
+	}
+	for _, q := range l.quotas() {
+		used, expiry := l.used(q, now)
+		w := &Window{Limit: q.max, Remaining: math.Max(0, q.max-used), Reset: expiry}
+		if q.limit == "tokens" {
+			s.Tokens = tighter(s.Tokens, w)
+		} else {
+			s.Spend = tighter(s.Spend, w)
+		}
+	}
+	return s
+}
+
+// tighter returns the window with less remaining; a nil window loses.
+func tighter(a, b *Window) *Window {
+	if a == nil || (b != nil && b.Remaining < a.Remaining) {
+		return b
+	}
+	return a
+}
+
+// Admit admits a request against every given limiter, or none of them.
+// Nil limiters are skipped.
+//
+// @param limiters - the limiters of the request's scopes
+// @return Status - the tightest limits after admission, for response headers
+// @return *LimitError - the first exceeded limit; nothing is taken if non-nil
+func Admit(limiters ...*Limiter) (Status, *LimitError) {
+	now := timeNow()
+	for _, l := range limiters {
+		if err := l.checkQuotas(now); err != nil {
+			return Status{}, err
+		}
+	}
+	for i, l := range limiters {
+		if err := l.take(now); err != nil {
+			for _, taken := range limiters[:i] {
+				taken.refund()
+			}
+			return Status{}, err
+		}
+	}
+
+	var status Status
+	for _, l := range limiters {
+		s := l.status(now)
+		status.Requests = tighter(status.Requests, s.Requests)
+		status.Tokens = tighter(status.Tokens, s.Tokens)
+		status.Spend = tighter(status.Spend, s.Spend)
+	}
+	return status, nil
+}
+
+// sweepInterval is how often Get looks for idle limiters to evict.
+const sweepInterval = Hour
+
+// Registry holds the limiters of the scopes seen within the longest quota
+// window. Limiters idle for longer are evicted, since they limit nothing a new
+// limiter would not.
+//
+// Thread Safety: Safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+	swept    time.Time // when idle limiters were last evicted
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Default is the process-wide registry used by the request handlers.
+var Default = NewRegistry()
+
+// Get returns the limiter of a scope, creating it on first use or once the
+// previous one was evicted as idle. The limiter adopts cfg, so changed limits
+// apply without losing the scope's usage.
+//
+// @param scope - the scope's name, used in LimitError messages
+// @param cfg - the scope's limits; nil means the scope is not limited
+// @return *Limiter - the limiter, nil if cfg is nil
+func (r *Registry) Get(scope string, cfg *config.LimitsConfig) *Limiter {
+	if cfg == nil {
+		return nil
+	}
+	now := timeNow()
+	r.mu.Lock()
+	if now.Sub(r.swept) >= sweepInterval {
+		r.evict(now)
+	}
+	l, ok := r.limiters[scope]
+	if !ok {
+		l = &Limiter{scope: scope}
+		r.limiters[scope] = l
+	}
+	l.mu.Lock()
+	l.cfg = *cfg
+	l.lastUsed = now
+	l.mu.Unlock()
+	r.mu.Unlock()
+	return l
+}
+
+// evict drops the limiters that have been idle for the longest quota window.
+//
+// @pre r.mu is held
+func (r *Registry) evict(now time.Time) {
+	for scope, l := range r.limiters {
+		if l.idle(now) {
+			delete(r.limiters, scope)
+		}
+	}
+	r.swept = now
+}
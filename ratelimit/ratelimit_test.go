@@ -0,0 +1,192 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"ai-proxy/config"
+)
+
+// withClock fixes timeNow for the duration of a test and returns a function
+// that advances it.
+func withClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAdmit_TokenBucket(t *testing.T) {
+	advance := withClock(t)
+	l := NewRegistry().Get("client 'alice'", &config.LimitsConfig{RequestsPerMinute: 60, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := Admit(l); err != nil {
+			t.Fatalf("request %d: unexpected rejection: %v", i+1, err)
+		}
+	}
+	_, err := Admit(l)
+	if err == nil || err.Limit != "requests" {
+		t.Fatalf("expected the request rate limit, got %v", err)
+	}
+	if err.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", err.RetryAfter)
+	}
+	if err.Error() != "request rate limit exceeded for client 'alice'" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	advance(time.Second)
+	status, err := Admit(l)
+	if err != nil {
+		t.Fatalf("expected a refilled request after 1s, got %v", err)
+	}
+	if status.Requests == nil || status.Requests.Limit != 2 || status.Requests.Remaining != 0 || status.Requests.Reset != 2*time.Second {
+		t.Errorf("unexpected request status: %+v", status.Requests)
+	}
+}
+
+func TestAdmit_DefaultBurst(t *testing.T) {
+	withClock(t)
+	l := NewRegistry().Get("s", &config.LimitsConfig{RequestsPerMinute: 2.5})
+	for i := 0; i < 3; i++ {
+		if _, err := Admit(l); err != nil {
+			t.Fatalf("request %d: unexpected rejection: %v", i+1, err)
+		}
+	}
+	if _, err := Admit(l); err == nil {
+		t.Fatal("expected rejection after a burst of 3")
+	}
+}
+
+func TestAdmit_RollingTokenQuota(t *testing.T) {
+	advance := withClock(t)
+	l := NewRegistry().Get("client 'alice'", &config.LimitsConfig{TokensPerHour: 1000})
+
+	l.Charge(600, 0)
+	advance(10 * time.Minute)
+	l.Charge(500, 0)
+
+	_, err := Admit(l)
+	if err == nil || err.Limit != "tokens" || err.Window != "hour" {
+		t.Fatalf("expected the hourly token quota, got %v", err)
+	}
+	// The first charge leaves the window after 50 more minutes, bringing usage to 500
+	if err.RetryAfter != 50*time.Minute {
+		t.Errorf("RetryAfter = %v, want 50m", err.RetryAfter)
+	}
+	if err.Error() != "hourly token quota exceeded for client 'alice'" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	advance(50 * time.Minute)
+	status, err := Admit(l)
+	if err != nil {
+		t.Fatalf("expected admission once the first charge expired, got %v", err)
+	}
+	if status.Tokens == nil || status.Tokens.Remaining != 500 || status.Tokens.Reset != 10*time.Minute {
+		t.Errorf("unexpected token status: %+v", status.Tokens)
+	}
+}
+
+func TestAdmit_DailySpendQuota(t *testing.T) {
+	advance := withClock(t)
+	l := NewRegistry().Get("s", &config.LimitsConfig{SpendPerHour: 10, SpendPerDay: 2.5})
+
+	l.Charge(100, 2.5)
+	advance(2 * time.Hour)
+	_, err := Admit(l)
+	if err == nil || err.Limit != "spend" || err.Window != "day" {
+		t.Fatalf("expected the daily spend quota, got %v", err)
+	}
+	if err.RetryAfter != 22*time.Hour {
+		t.Errorf("RetryAfter = %v, want 22h", err.RetryAfter)
+	}
+
+	advance(22 * time.Hour)
+	if _, err := Admit(l); err != nil {
+		t.Fatalf("expected admission after a day, got %v", err)
+	}
+}
+
+func TestAdmit_AllOrNothing(t *testing.T) {
+	withClock(t)
+	r := NewRegistry()
+	client := r.Get("client", &config.LimitsConfig{RequestsPerMinute: 60, Burst: 5})
+	model := r.Get("model", &config.LimitsConfig{RequestsPerMinute: 60, Burst: 1})
+
+	if _, err := Admit(client, model); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	_, err := Admit(client, model)
+	if err == nil || err.Scope != "model" {
+		t.Fatalf("expected the model limit, got %v", err)
+	}
+	// The client's request taken before the model rejected it is refunded
+	status, _ := Admit(client)
+	if status.Requests.Remaining != 3 {
+		t.Errorf("client remaining = %v, want 3", status.Requests.Remaining)
+	}
+}
+
+func TestAdmit_TightestStatus(t *testing.T) {
+	withClock(t)
+	r := NewRegistry()
+	a := r.Get("a", &config.LimitsConfig{TokensPerDay: 1000})
+	b := r.Get("b", &config.LimitsConfig{TokensPerHour: 5000})
+	b.Charge(4800, 0)
+
+	status, err := Admit(a, nil, b)
+	if err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	if status.Requests != nil || status.Spend != nil {
+		t.Errorf("expected no request or spend limits, got %+v", status)
+	}
+	if status.Tokens == nil || status.Tokens.Limit != 5000 || status.Tokens.Remaining != 200 {
+		t.Errorf("unexpected token status: %+v", status.Tokens)
+	}
+}
+
+func TestRegistry_Get(t *testing.T) {
+	withClock(t)
+	r := NewRegistry()
+	if r.Get("s", nil) != nil {
+		t.Error("a scope without limits should have no limiter")
+	}
+
+	l := r.Get("s", &config.LimitsConfig{TokensPerHour: 100})
+	l.Charge(100, 0)
+	if _, err := Admit(r.Get("s", &config.LimitsConfig{TokensPerHour: 100})); err == nil {
+		t.Error("expected the scope's usage to persist across Get calls")
+	}
+	if _, err := Admit(r.Get("s", &config.LimitsConfig{TokensPerHour: 200})); err != nil {
+		t.Errorf("expected raised limits to apply, got %v", err)
+	}
+}
+
+func TestRegistry_EvictsIdleLimiters(t *testing.T) {
+	advance := withClock(t)
+	r := NewRegistry()
+	cfg := &config.LimitsConfig{TokensPerDay: 100}
+
+	r.Get("alice", cfg).Charge(100, 0)
+	r.Get("bob", cfg)
+	advance(Day - time.Minute)
+	r.Get("bob", cfg)
+	advance(sweepInterval)
+
+	// alice's only charge left the day window, bob was used an hour ago
+	r.Get("carol", cfg)
+	if _, ok := r.limiters["alice"]; ok {
+		t.Error("expected the idle limiter to be evicted")
+	}
+	if _, ok := r.limiters["bob"]; !ok {
+		t.Error("expected the recently used limiter to be kept")
+	}
+	if _, err := Admit(r.Get("alice", cfg)); err != nil {
+		t.Errorf("expected a fresh limiter for an evicted scope, got %v", err)
+	}
+}
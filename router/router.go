@@ -3,10 +3,16 @@
 package router
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"ai-proxy/balance"
+	"ai-proxy/circuit"
 	"ai-proxy/config"
+	"ai-proxy/credential"
+	"ai-proxy/logging"
+	"ai-proxy/proxy"
 )
 
 // Router defines the interface for model resolution.
@@ -16,6 +22,19 @@ type Router interface {
 	// ResolveWithProtocol resolves a model name with incoming protocol context.
 	// Used for "auto" type routing to enable passthrough optimization.
 	ResolveWithProtocol(modelName, incomingProtocol string) (*ResolvedRoute, error)
+	// ResolvePlan resolves a model name to its load-balanced routes followed by
+	// its configured fallback routes, each resolved for the incoming protocol.
+	// Routes to providers with an open circuit breaker are skipped.
+	ResolvePlan(modelName, incomingProtocol string) (*RoutePlan, error)
+	// KeyStats returns the usage counters of every provider API key.
+	KeyStats() []balance.KeyStats
+	// ProviderHealth returns the circuit breaker state of every provider.
+	ProviderHealth() []circuit.Stats
+	// ConnectionStats returns the connection pool counters of every provider.
+	ConnectionStats() []proxy.PoolStats
+	// CloseIdleConnections closes the idle upstream connections of every
+	// provider, once the router has been replaced.
+	CloseIdleConnections()
 	// GetProvider retrieves a provider by name.
 	GetProvider(name string) (config.Provider, bool)
 	// ListModels returns all configured model names.
@@ -36,9 +55,110 @@ type ResolvedRoute struct {
 	GLM5ToolCallTransform bool
 	// ReasoningSplit enables separate reasoning output for this route.
 	ReasoningSplit bool
+	// MaxContextTokens is the model's conversation history token budget.
+	// 0 means the model does not override responses.max_context_tokens.
+	MaxContextTokens int
 	// IsPassthrough indicates when no protocol transformation is needed.
 	// True when incoming protocol matches output protocol (passthrough mode).
 	IsPassthrough bool
+	// Key is the provider API key selected for this route by the load balancer.
+	// nil means the provider's default key is used.
+	Key *balance.Key
+	// Breaker is the circuit breaker of the route's provider. May be nil.
+	Breaker *circuit.Breaker
+	// Credential is the credential source of the route's provider, nil if
+	// the provider uses static keys.
+	Credential *credential.Source
+	// Transport is the shared connection pool of the route's provider.
+	// nil gives each upstream client its own connections.
+	Transport *proxy.Transport
+	// Price is the configured price of Model, nil if it has none.
+	// Set by ResolvePlan.
+	Price *config.ModelPrice
+
+	// credentialKey is the key last fetched from Credential for this route.
+	credentialKey string
+}
+
+// GetAPIKey returns the API key to authenticate this route with: the key
+// fetched from the provider's credential source, the selected key if any,
+// otherwise the provider's key.
+// A failed credential fetch is logged and yields an empty key; use FetchAPIKey
+// to handle it.
+func (r *ResolvedRoute) GetAPIKey() string {
+	if r.Credential != nil {
+		key, err := r.FetchAPIKey(context.Background())
+		if err != nil {
+			logging.ErrorMsg("Failed to fetch API key: %v", err)
+		}
+		return key
+	}
+	if r.Key != nil {
+		return r.Key.Value()
+	}
+	return r.Provider.GetAPIKey()
+}
+
+// FetchAPIKey returns the API key to authenticate this route with, like
+// GetAPIKey, reporting a failed credential fetch.
+//
+// @param ctx - bounds the credential fetch, if one is needed
+// @return error - if the provider's credential source failed
+func (r *ResolvedRoute) FetchAPIKey(ctx context.Context) (string, error) {
+	if r.Credential == nil {
+		return r.GetAPIKey(), nil
+	}
+	key, err := r.Credential.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	r.credentialKey = key
+	return key, nil
+}
+
+// InvalidateCredential drops the key last fetched for this route from its
+// provider's credential source after the upstream rejected it, so the next
+// GetAPIKey fetches a fresh one.
+//
+// @return true if the route has a credential source, false if its keys are static
+func (r *ResolvedRoute) InvalidateCredential() bool {
+	if r.Credential == nil {
+		return false
+	}
+	r.Credential.Invalidate(r.credentialKey)
+	return true
+}
+
+// RoutePlan is the ordered list of routes to try for a request.
+// The first route is the primary; the others are fallbacks, tried in order when
+// the previous route fails before any response data is streamed.
+type RoutePlan struct {
+	// Routes holds the primary route followed by the fallback routes.
+	// Contains at least one route.
+	Routes []*ResolvedRoute
+	// Limits caps each client's use of the model alias, nil if it has none.
+	Limits *config.LimitsConfig
+}
+
+// Primary returns the first route of the plan.
+//
+// @pre len(p.Routes) > 0
+func (p *RoutePlan) Primary() *ResolvedRoute {
+	return p.Routes[0]
+}
+
+// Next returns the route that follows route in the plan.
+// Returns nil if route is the last route or is not part of the plan.
+func (p *RoutePlan) Next(route *ResolvedRoute) *ResolvedRoute {
+	if p == nil {
+		return nil
+	}
+	for i, r := range p.Routes {
+		if r == route && i+1 < len(p.Routes) {
+			return p.Routes[i+1]
+		}
+	}
+	return nil
 }
 
 // router implements the Router interface.
@@ -46,10 +166,29 @@ type router struct {
 	schema *config.Schema
 	// providersMap is a lookup map from provider name to Provider.
 	providersMap map[string]config.Provider
+	// keys holds the API keys of all providers.
+	keys *balance.Registry
+	// balanced maps configured model names to their load-balancing pools.
+	balanced map[string]*balancedModel
+	// breakers holds the circuit breakers of all providers.
+	breakers *circuit.Registry
+	// credentials holds the credential sources of providers that fetch their keys.
+	credentials *credential.Registry
+	// transports holds the shared connection pools of all providers.
+	transports *proxy.Pool
+}
+
+// balancedModel is the set of targets serving a model alias and the pool
+// spreading requests across their provider keys.
+type balancedModel struct {
+	// targets holds one route per target; OutputProtocol may still be "auto".
+	targets []*ResolvedRoute
+	pool    *balance.Pool
 }
 
 // NewRouter creates a new Router from the given schema.
-// Returns an error if the schema is nil.
+// Returns an error if the schema is nil or a provider's transport cannot be
+// created, e.g. because its TLS files cannot be loaded.
 func NewRouter(s *config.Schema) (Router, error) {
 	if s == nil {
 		return nil, fmt.Errorf("schema cannot be nil")
@@ -61,10 +200,88 @@ func NewRouter(s *config.Schema) (Router, error) {
 		providersMap[p.Name] = p
 	}
 
-	return &router{
+	transports, err := proxy.NewPool(s.Providers)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &router{
 		schema:       s,
 		providersMap: providersMap,
-	}, nil
+		keys:         balance.NewRegistry(s.Providers),
+		balanced:     make(map[string]*balancedModel, len(s.Models)),
+		breakers:     circuit.NewRegistry(s.Providers),
+		credentials:  credential.NewRegistry(s.Providers),
+		transports:   transports,
+	}
+	for name := range s.Models {
+		if bm := r.newBalancedModel(name); bm != nil {
+			r.balanced[name] = bm
+		}
+	}
+	return r, nil
+}
+
+// newBalancedModel builds the targets and pool of a configured model.
+// The model's own provider and model form the first target, followed by the
+// load_balance targets. Each target contributes one member per provider key,
+// or a single keyless member if its provider has no keys.
+//
+// @return *balancedModel - nil if the model's provider is unknown
+func (r *router) newBalancedModel(modelName string) *balancedModel {
+	primary, err := r.Resolve(modelName)
+	if err != nil {
+		return nil
+	}
+
+	modelConfig := r.schema.Models[modelName]
+	lb := modelConfig.LoadBalance
+	if lb == nil {
+		lb = &config.LoadBalanceConfig{}
+	}
+
+	bm := &balancedModel{targets: []*ResolvedRoute{primary}}
+	weights := []int{lb.Weight}
+	for _, target := range lb.Targets {
+		provider, ok := r.providersMap[target.Provider]
+		if !ok {
+			continue
+		}
+
+		// Determine output protocol
+		outputProtocol := provider.GetDefaultProtocol() // default to provider's default
+		if target.Type != "" {
+			outputProtocol = target.Type
+		}
+
+		bm.targets = append(bm.targets, &ResolvedRoute{
+			Provider:              provider,
+			Model:                 target.Model,
+			OutputProtocol:        outputProtocol,
+			KimiToolCallTransform: target.KimiToolCallTransform,
+			GLM5ToolCallTransform: target.GLM5ToolCallTransform,
+			ReasoningSplit:        target.ReasoningSplit,
+			MaxContextTokens:      modelConfig.MaxContextTokens,
+			IsPassthrough:         false,
+			Credential:            r.credentials.Get(provider.Name),
+			Transport:             r.transports.Get(provider.Name),
+		})
+		weights = append(weights, target.Weight)
+	}
+
+	var members []balance.Member
+	for i, target := range bm.targets {
+		keys := r.keys.Keys(target.Provider.Name)
+		if len(keys) == 0 {
+			members = append(members, balance.Member{Target: i, Weight: weights[i]})
+			continue
+		}
+		for _, key := range keys {
+			members = append(members, balance.Member{Target: i, Key: key, Weight: weights[i]})
+		}
+	}
+	bm.pool = balance.NewPool(balance.Strategy(lb.Strategy), members)
+	return bm
 }
 
 // Resolve resolves a model name to a route with provider information.
@@ -96,7 +313,10 @@ func (r *router) Resolve(modelName string) (*ResolvedRoute, error) {
 			KimiToolCallTransform: modelConfig.KimiToolCallTransform,
 			GLM5ToolCallTransform: modelConfig.GLM5ToolCallTransform,
 			ReasoningSplit:        modelConfig.ReasoningSplit,
+			MaxContextTokens:      modelConfig.MaxContextTokens,
 			IsPassthrough:         false,
+			Credential:            r.credentials.Get(provider.Name),
+			Transport:             r.transports.Get(provider.Name),
 		}, nil
 	}
 
@@ -124,7 +344,10 @@ func (r *router) Resolve(modelName string) (*ResolvedRoute, error) {
 			KimiToolCallTransform: r.schema.Fallback.KimiToolCallTransform,
 			GLM5ToolCallTransform: r.schema.Fallback.GLM5ToolCallTransform,
 			ReasoningSplit:        r.schema.Fallback.ReasoningSplit,
+			MaxContextTokens:      r.schema.Fallback.MaxContextTokens,
 			IsPassthrough:         false,
+			Credential:            r.credentials.Get(provider.Name),
+			Transport:             r.transports.Get(provider.Name),
 		}, nil
 	}
 
@@ -148,9 +371,115 @@ func (r *router) ResolveWithProtocol(modelName, incomingProtocol string) (*Resol
 		return nil, err
 	}
 
-	// If not auto type, return as-is
+	resolveAutoProtocol(route, incomingProtocol)
+	return route, nil
+}
+
+// ResolvePlan resolves a model name to a route plan with incoming protocol context.
+// A configured model's plan starts with one route per member of its load-balancing
+// pool, in the order chosen by its strategy; members whose key is ejected are
+// left out. The model's fallback routes follow in configuration order, each using
+// the next key of its provider. Every route gets its own protocol and passthrough
+// detection, inherits the model's max_context_tokens, and carries the price of
+// its upstream model. The plan carries the model's limits.
+// Models resolved through the global fallback have a single route.
+// Routes whose provider's circuit breaker is open are left out, unless that
+// would leave no route; the request then fails fast at the breaker.
+//
+// @pre modelName must not be empty
+// @pre incomingProtocol should be "openai", "anthropic", or "responses"
+// @post returned plan contains at least one route
+func (r *router) ResolvePlan(modelName, incomingProtocol string) (*RoutePlan, error) {
+	primary, err := r.ResolveWithProtocol(modelName, incomingProtocol)
+	if err != nil {
+		return nil, err
+	}
+
+	modelConfig, ok := r.schema.Models[modelName]
+	if !ok {
+		primary.Key = r.keys.Next(primary.Provider.Name)
+		primary.Breaker = r.breakers.Get(primary.Provider.Name)
+		primary.Price = r.price(primary.Model)
+		return &RoutePlan{Routes: []*ResolvedRoute{primary}}, nil
+	}
+
+	plan := &RoutePlan{Limits: modelConfig.Limits}
+	for _, member := range r.balanced[modelName].pool.Order() {
+		route := *r.balanced[modelName].targets[member.Target]
+		route.Key = member.Key
+		resolveAutoProtocol(&route, incomingProtocol)
+		plan.Routes = append(plan.Routes, &route)
+	}
+
+	for _, fallback := range modelConfig.Fallbacks {
+		provider, ok := r.providersMap[fallback.Provider]
+		if !ok {
+			return nil, fmt.Errorf("provider '%s' not found for fallback of model '%s'", fallback.Provider, modelName)
+		}
+
+		// Determine output protocol
+		outputProtocol := provider.GetDefaultProtocol() // default to provider's default
+		if fallback.Type != "" {
+			outputProtocol = fallback.Type
+		}
+
+		route := &ResolvedRoute{
+			Provider:              provider,
+			Model:                 fallback.Model,
+			OutputProtocol:        outputProtocol,
+			KimiToolCallTransform: fallback.KimiToolCallTransform,
+			GLM5ToolCallTransform: fallback.GLM5ToolCallTransform,
+			ReasoningSplit:        fallback.ReasoningSplit,
+			MaxContextTokens:      modelConfig.MaxContextTokens,
+			IsPassthrough:         false,
+			Key:                   r.keys.Next(fallback.Provider),
+			Credential:            r.credentials.Get(fallback.Provider),
+			Transport:             r.transports.Get(fallback.Provider),
+		}
+		resolveAutoProtocol(route, incomingProtocol)
+		plan.Routes = append(plan.Routes, route)
+	}
+
+	for _, route := range plan.Routes {
+		route.Price = r.price(route.Model)
+	}
+	return r.skipOpenCircuits(plan), nil
+}
+
+// price returns the configured price of an upstream model, nil if it has none.
+func (r *router) price(model string) *config.ModelPrice {
+	if p, ok := r.schema.Pricing[model]; ok {
+		return &p
+	}
+	return nil
+}
+
+// skipOpenCircuits attaches provider circuit breakers to the plan's routes and
+// removes the routes whose breaker is open.
+//
+// @return the filtered plan, or the unfiltered plan if every breaker is open
+func (r *router) skipOpenCircuits(plan *RoutePlan) *RoutePlan {
+	available := make([]*ResolvedRoute, 0, len(plan.Routes))
+	for _, route := range plan.Routes {
+		route.Breaker = r.breakers.Get(route.Provider.Name)
+		if route.Breaker.Available() {
+			available = append(available, route)
+		}
+	}
+	if len(available) > 0 {
+		plan.Routes = available
+	}
+	return plan
+}
+
+// resolveAutoProtocol resolves an "auto" output protocol for the incoming protocol.
+// Routes with any other output protocol are left unchanged.
+//
+// @post If OutputProtocol was "auto", it is the incoming protocol when the provider
+// supports it (IsPassthrough true), otherwise the provider's default protocol.
+func resolveAutoProtocol(route *ResolvedRoute, incomingProtocol string) {
 	if route.OutputProtocol != "auto" {
-		return route, nil
+		return
 	}
 
 	// Handle auto type - check if provider supports incoming protocol
@@ -162,8 +491,6 @@ func (r *router) ResolveWithProtocol(modelName, incomingProtocol string) (*Resol
 		route.OutputProtocol = route.Provider.GetDefaultProtocol()
 		route.IsPassthrough = false
 	}
-
-	return route, nil
 }
 
 // GetProvider retrieves a provider by name.
@@ -173,6 +500,26 @@ func (r *router) GetProvider(name string) (config.Provider, bool) {
 	return provider, ok
 }
 
+// KeyStats returns the usage counters of every provider API key.
+func (r *router) KeyStats() []balance.KeyStats {
+	return r.keys.Stats()
+}
+
+// ProviderHealth returns the circuit breaker state of every provider.
+func (r *router) ProviderHealth() []circuit.Stats {
+	return r.breakers.Stats()
+}
+
+// ConnectionStats returns the connection pool counters of every provider.
+func (r *router) ConnectionStats() []proxy.PoolStats {
+	return r.transports.Stats()
+}
+
+// CloseIdleConnections closes the idle upstream connections of every provider.
+func (r *router) CloseIdleConnections() {
+	r.transports.CloseIdleConnections()
+}
+
 // ListModels returns all configured model names.
 func (r *router) ListModels() []string {
 	models := make([]string, 0, len(r.schema.Models))
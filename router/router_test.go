@@ -1,9 +1,14 @@
 package router
 
 import (
+	"context"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"ai-proxy/circuit"
 	"ai-proxy/config"
+	"ai-proxy/credential"
 )
 
 func TestNewRouter_NilSchema(t *testing.T) {
@@ -25,6 +30,22 @@ func TestNewRouter_ValidSchema(t *testing.T) {
 	}
 }
 
+func TestNewRouter_InvalidTLS(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{
+				Name:      "gateway",
+				Endpoints: map[string]string{"openai": "https://gateway.internal"},
+				TLS:       &config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			},
+		},
+	}
+	_, err := NewRouter(schema)
+	if err == nil || !strings.Contains(err.Error(), "provider 'gateway'") {
+		t.Errorf("expected an error naming the provider, got %v", err)
+	}
+}
+
 func TestResolve_ExactModelMatch(t *testing.T) {
 	schema := &config.Schema{
 		Providers: []config.Provider{
@@ -692,3 +713,506 @@ func TestResolveWithProtocol_FallbackWithAutoType(t *testing.T) {
 		t.Error("expected IsPassthrough to be true for fallback with auto type and matching protocol")
 	}
 }
+
+func TestResolve_MaxContextTokens(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "provider", Endpoints: map[string]string{"openai": "https://api.example.com"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"small": {Provider: "provider", Model: "small-model", MaxContextTokens: 8000},
+		},
+		Fallback: config.FallbackConfig{
+			Enabled:          true,
+			Provider:         "provider",
+			Model:            "{model}",
+			MaxContextTokens: 32000,
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	route, err := r.Resolve("small")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if route.MaxContextTokens != 8000 {
+		t.Errorf("expected MaxContextTokens 8000 from model config, got %d", route.MaxContextTokens)
+	}
+
+	route, err = r.Resolve("unknown-model")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if route.MaxContextTokens != 32000 {
+		t.Errorf("expected MaxContextTokens 32000 from fallback config, got %d", route.MaxContextTokens)
+	}
+}
+
+func TestResolvePlan_FallbackRoutes(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "anthropic", Endpoints: map[string]string{"anthropic": "https://api.anthropic.com"}},
+			{Name: "openai", Endpoints: map[string]string{"openai": "https://api.openai.com"}},
+			{
+				Name: "multi",
+				Endpoints: map[string]string{
+					"openai":    "https://multi.example.com/v1/chat/completions",
+					"anthropic": "https://multi.example.com/v1/messages",
+				},
+				Default: "openai",
+			},
+		},
+		Models: map[string]config.ModelConfig{
+			"claude": {
+				Provider:         "anthropic",
+				Model:            "claude-sonnet",
+				MaxContextTokens: 16000,
+				Fallbacks: []config.FallbackRoute{
+					{Provider: "openai", Model: "gpt-4o", KimiToolCallTransform: true},
+					{Provider: "multi", Model: "multi-model", Type: "auto", GLM5ToolCallTransform: true, ReasoningSplit: true},
+				},
+			},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, err := r.ResolvePlan("claude", "anthropic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(plan.Routes))
+	}
+
+	primary := plan.Primary()
+	if primary.Provider.Name != "anthropic" || primary.Model != "claude-sonnet" || primary.OutputProtocol != "anthropic" {
+		t.Errorf("unexpected primary route: %+v", primary)
+	}
+
+	first := plan.Routes[1]
+	if first.Provider.Name != "openai" || first.Model != "gpt-4o" {
+		t.Errorf("unexpected first fallback: %+v", first)
+	}
+	if first.OutputProtocol != "openai" {
+		t.Errorf("expected first fallback to use provider default 'openai', got %q", first.OutputProtocol)
+	}
+	if !first.KimiToolCallTransform || first.GLM5ToolCallTransform {
+		t.Error("expected first fallback to carry its own tool-call flags")
+	}
+	if first.MaxContextTokens != 16000 {
+		t.Errorf("expected fallback to inherit MaxContextTokens 16000, got %d", first.MaxContextTokens)
+	}
+
+	second := plan.Routes[2]
+	if second.OutputProtocol != "anthropic" || !second.IsPassthrough {
+		t.Errorf("expected auto fallback to pass through anthropic, got protocol=%q passthrough=%v", second.OutputProtocol, second.IsPassthrough)
+	}
+	if !second.GLM5ToolCallTransform || !second.ReasoningSplit {
+		t.Error("expected second fallback to carry its own flags")
+	}
+
+	if plan.Next(primary) != first || plan.Next(first) != second {
+		t.Error("expected Next to follow plan order")
+	}
+	if plan.Next(second) != nil {
+		t.Error("expected no route after the last fallback")
+	}
+	if plan.Next(&ResolvedRoute{}) != nil {
+		t.Error("expected nil for a route outside the plan")
+	}
+}
+
+func TestResolvePlan_NoFallbacks(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "openai", Endpoints: map[string]string{"openai": "https://api.openai.com"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"gpt-4": {Provider: "openai", Model: "gpt-4-turbo"},
+		},
+		Fallback: config.FallbackConfig{
+			Enabled:  true,
+			Provider: "openai",
+			Model:    "{model}",
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, model := range []string{"gpt-4", "unlisted-model"} {
+		plan, err := r.ResolvePlan(model, "openai")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", model, err)
+		}
+		if len(plan.Routes) != 1 {
+			t.Errorf("%s: expected a single route, got %d", model, len(plan.Routes))
+		}
+		if plan.Next(plan.Primary()) != nil {
+			t.Errorf("%s: expected no fallback route", model)
+		}
+	}
+}
+
+func TestResolvePlan_LimitsAndPrices(t *testing.T) {
+	limits := &config.LimitsConfig{RequestsPerMinute: 10}
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "openai", Endpoints: map[string]string{"openai": "https://api.openai.com"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"gpt-4": {
+				Provider:  "openai",
+				Model:     "gpt-4-turbo",
+				Limits:    limits,
+				Fallbacks: []config.FallbackRoute{{Provider: "openai", Model: "gpt-4o-mini"}},
+			},
+		},
+		Fallback: config.FallbackConfig{Enabled: true, Provider: "openai", Model: "{model}"},
+		Pricing: map[string]config.ModelPrice{
+			"gpt-4-turbo": {Input: 10, Output: 30},
+			"unlisted":    {Input: 1, Output: 2},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, err := r.ResolvePlan("gpt-4", "openai")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Limits != limits {
+		t.Errorf("expected the model's limits on the plan, got %+v", plan.Limits)
+	}
+	if p := plan.Routes[0].Price; p == nil || p.Input != 10 || p.Output != 30 {
+		t.Errorf("expected the primary model's price, got %+v", p)
+	}
+	if p := plan.Routes[1].Price; p != nil {
+		t.Errorf("expected no price for an unpriced fallback model, got %+v", p)
+	}
+
+	plan, err = r.ResolvePlan("unlisted", "openai")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Limits != nil || plan.Primary().Price == nil || plan.Primary().Price.Input != 1 {
+		t.Errorf("expected an unlimited global fallback route with its price, got %+v", plan)
+	}
+}
+
+func TestResolvePlan_Errors(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "openai", Endpoints: map[string]string{"openai": "https://api.openai.com"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"broken": {
+				Provider:  "openai",
+				Model:     "gpt-4",
+				Fallbacks: []config.FallbackRoute{{Provider: "missing", Model: "x"}},
+			},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := r.ResolvePlan("unknown", "openai"); err == nil {
+		t.Error("expected error for unknown model")
+	}
+	if _, err := r.ResolvePlan("broken", "openai"); err == nil {
+		t.Error("expected error for fallback with unknown provider")
+	}
+}
+
+func TestResolvePlan_RotatesProviderKeys(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "chutes", Endpoints: map[string]string{"openai": "https://llm.chutes.ai"}, APIKeys: []string{"key-a", "key-b"}},
+			{Name: "backup", Endpoints: map[string]string{"openai": "https://backup.example.com"}, APIKey: "backup-key"},
+		},
+		Models: map[string]config.ModelConfig{
+			"qwen": {
+				Provider:  "chutes",
+				Model:     "Qwen/Qwen3",
+				Fallbacks: []config.FallbackRoute{{Provider: "backup", Model: "qwen3"}},
+			},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first, err := r.ResolvePlan("qwen", "openai")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first.Routes) != 3 {
+		t.Fatalf("expected one route per key plus the fallback, got %d", len(first.Routes))
+	}
+	if first.Routes[0].GetAPIKey() != "key-a" || first.Routes[1].GetAPIKey() != "key-b" {
+		t.Errorf("expected keys [key-a key-b], got [%s %s]", first.Routes[0].GetAPIKey(), first.Routes[1].GetAPIKey())
+	}
+	if first.Routes[0].Model != "Qwen/Qwen3" || first.Routes[1].Model != "Qwen/Qwen3" {
+		t.Error("expected both key routes to serve the model's own target")
+	}
+	if first.Routes[2].Provider.Name != "backup" || first.Routes[2].GetAPIKey() != "backup-key" {
+		t.Errorf("unexpected fallback route: %+v", first.Routes[2])
+	}
+
+	second, err := r.ResolvePlan("qwen", "openai")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Primary().GetAPIKey() != "key-b" {
+		t.Errorf("expected the next plan to start with key-b, got %s", second.Primary().GetAPIKey())
+	}
+}
+
+func TestResolvePlan_SkipsEjectedKeys(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "chutes", Endpoints: map[string]string{"openai": "https://llm.chutes.ai"}, APIKeys: []string{"key-a", "key-b"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"qwen": {Provider: "chutes", Model: "Qwen/Qwen3"},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, _ := r.ResolvePlan("qwen", "openai")
+	plan.Primary().Key.Record(429, nil, 0)
+
+	for i := 0; i < 3; i++ {
+		plan, err := r.ResolvePlan("qwen", "openai")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plan.Routes) != 1 || plan.Primary().GetAPIKey() != "key-b" {
+			t.Fatalf("expected only key-b while key-a is ejected, got %d routes starting with %s", len(plan.Routes), plan.Primary().GetAPIKey())
+		}
+	}
+
+	stats := r.KeyStats()
+	if len(stats) != 2 || !stats[0].Ejected || stats[0].Ejections != 1 || stats[1].Ejected {
+		t.Errorf("unexpected key stats: %+v", stats)
+	}
+}
+
+func TestResolvePlan_LoadBalanceTargets(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "chutes", Endpoints: map[string]string{"openai": "https://llm.chutes.ai"}, APIKey: "chutes-key"},
+			{
+				Name: "dashscope",
+				Endpoints: map[string]string{
+					"openai":    "https://dashscope.example.com/v1/chat/completions",
+					"anthropic": "https://dashscope.example.com/v1/messages",
+				},
+				Default: "openai",
+				APIKey:  "dashscope-key",
+			},
+		},
+		Models: map[string]config.ModelConfig{
+			"qwen": {
+				Provider:         "chutes",
+				Model:            "Qwen/Qwen3",
+				MaxContextTokens: 32000,
+				LoadBalance: &config.LoadBalanceConfig{
+					Strategy: "round_robin",
+					Targets: []config.BalanceTarget{
+						{Provider: "dashscope", Model: "qwen3-max", Type: "auto", ReasoningSplit: true},
+					},
+				},
+			},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, err := r.ResolvePlan("qwen", "anthropic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(plan.Routes))
+	}
+	if plan.Routes[0].Provider.Name != "chutes" || plan.Routes[0].OutputProtocol != "openai" {
+		t.Errorf("unexpected first route: %+v", plan.Routes[0])
+	}
+	target := plan.Routes[1]
+	if target.Provider.Name != "dashscope" || target.Model != "qwen3-max" || target.GetAPIKey() != "dashscope-key" {
+		t.Errorf("unexpected target route: %+v", target)
+	}
+	if target.OutputProtocol != "anthropic" || !target.IsPassthrough {
+		t.Errorf("expected auto target to pass through anthropic, got protocol=%q passthrough=%v", target.OutputProtocol, target.IsPassthrough)
+	}
+	if !target.ReasoningSplit || target.MaxContextTokens != 32000 {
+		t.Error("expected target to carry its own flags and inherit MaxContextTokens")
+	}
+
+	next, _ := r.ResolvePlan("qwen", "anthropic")
+	if next.Primary().Provider.Name != "dashscope" {
+		t.Errorf("expected round-robin to start the next plan with dashscope, got %s", next.Primary().Provider.Name)
+	}
+}
+
+func TestResolvedRoute_GetAPIKey_ProviderDefault(t *testing.T) {
+	route := &ResolvedRoute{Provider: config.Provider{Name: "p", APIKey: "provider-key"}}
+	if got := route.GetAPIKey(); got != "provider-key" {
+		t.Errorf("GetAPIKey() = %q, want provider-key", got)
+	}
+}
+
+func TestResolvePlan_CredentialSource(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "vault", Endpoints: map[string]string{"openai": "https://vault.example.com"},
+				Credential: &config.CredentialConfig{Type: config.CredentialCommand, Command: []string{"echo", "sk-from-vault"}}},
+			{Name: "static", Endpoints: map[string]string{"openai": "https://static.example.com"}, APIKey: "sk-static"},
+		},
+		Models: map[string]config.ModelConfig{
+			"m": {Provider: "vault", Model: "a", Fallbacks: []config.FallbackRoute{{Provider: "static", Model: "b"}}},
+		},
+	}
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	plan, err := r.ResolvePlan("m", "openai")
+	if err != nil {
+		t.Fatalf("ResolvePlan returned error: %v", err)
+	}
+	primary, fallback := plan.Routes[0], plan.Routes[1]
+	if primary.Credential == nil || fallback.Credential != nil {
+		t.Fatalf("expected a credential source on the vault route only, got %v and %v", primary.Credential, fallback.Credential)
+	}
+	if got := primary.GetAPIKey(); got != "sk-from-vault" {
+		t.Errorf("GetAPIKey() = %q, want sk-from-vault", got)
+	}
+	if got := fallback.GetAPIKey(); got != "sk-static" {
+		t.Errorf("GetAPIKey() = %q, want sk-static", got)
+	}
+	if !primary.InvalidateCredential() || fallback.InvalidateCredential() {
+		t.Error("expected InvalidateCredential to report credential sources only")
+	}
+
+	route, err := r.Resolve("m")
+	if err != nil || route.Credential != primary.Credential {
+		t.Errorf("expected Resolve to share the provider's credential source, got %v, %v", route, err)
+	}
+}
+
+func TestResolvePlan_SharedTransports(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "primary", Endpoints: map[string]string{"openai": "https://primary.example.com"}, APIKey: "key"},
+			{Name: "backup", Endpoints: map[string]string{"openai": "https://backup.example.com"}, APIKey: "key"},
+		},
+		Models: map[string]config.ModelConfig{
+			"m": {Provider: "primary", Model: "a", Fallbacks: []config.FallbackRoute{{Provider: "backup", Model: "b"}}},
+		},
+	}
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	first, _ := r.ResolvePlan("m", "openai")
+	second, _ := r.ResolvePlan("m", "openai")
+	if first.Routes[0].Transport == nil || first.Routes[0].Transport != second.Routes[0].Transport {
+		t.Error("expected requests to the same provider to share its transport")
+	}
+	if first.Routes[0].Transport == first.Routes[1].Transport {
+		t.Error("expected each provider to have its own transport")
+	}
+	if route, _ := r.Resolve("m"); route.Transport != first.Routes[0].Transport {
+		t.Error("expected Resolve to use the provider's shared transport")
+	}
+
+	stats := r.ConnectionStats()
+	if len(stats) != 2 || stats[0].Provider != "primary" || stats[1].Provider != "backup" {
+		t.Errorf("unexpected connection stats: %+v", stats)
+	}
+}
+
+func TestResolvedRoute_FetchAPIKey_Error(t *testing.T) {
+	route := &ResolvedRoute{
+		Provider:   config.Provider{Name: "vault"},
+		Credential: credential.New("vault", &config.CredentialConfig{Type: config.CredentialCommand, Command: []string{"false"}}),
+	}
+	if _, err := route.FetchAPIKey(context.Background()); err == nil {
+		t.Error("expected an error from a failing credential command")
+	}
+	if got := route.GetAPIKey(); got != "" {
+		t.Errorf("GetAPIKey() = %q, want an empty key", got)
+	}
+}
+
+func TestResolvePlan_SkipsOpenCircuits(t *testing.T) {
+	schema := &config.Schema{
+		Providers: []config.Provider{
+			{Name: "primary", Endpoints: map[string]string{"openai": "https://primary.example.com"}, CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 1}},
+			{Name: "backup", Endpoints: map[string]string{"openai": "https://backup.example.com"}, CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 1}},
+		},
+		Models: map[string]config.ModelConfig{
+			"gpt-4": {
+				Provider:  "primary",
+				Model:     "gpt-4",
+				Fallbacks: []config.FallbackRoute{{Provider: "backup", Model: "gpt-4o"}},
+			},
+		},
+	}
+
+	r, err := NewRouter(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, _ := r.ResolvePlan("gpt-4", "openai")
+	if len(plan.Routes) != 2 || plan.Primary().Breaker == nil {
+		t.Fatalf("expected 2 routes with breakers, got %d", len(plan.Routes))
+	}
+
+	plan.Primary().Breaker.Failure("status 503")
+	plan, _ = r.ResolvePlan("gpt-4", "openai")
+	if len(plan.Routes) != 1 || plan.Primary().Provider.Name != "backup" {
+		t.Fatalf("expected only the backup route while primary's breaker is open, got %+v", plan.Routes)
+	}
+
+	plan.Primary().Breaker.Failure("status 503")
+	plan, _ = r.ResolvePlan("gpt-4", "openai")
+	if len(plan.Routes) != 2 {
+		t.Errorf("expected all routes when every breaker is open, got %d", len(plan.Routes))
+	}
+
+	health := r.ProviderHealth()
+	if len(health) != 2 || health[0].State != circuit.StateOpen || health[1].State != circuit.StateOpen {
+		t.Errorf("unexpected provider health: %+v", health)
+	}
+}
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"sync/atomic"
+
+	"ai-proxy/balance"
+	"ai-proxy/circuit"
+	"ai-proxy/config"
+	"ai-proxy/proxy"
+)
+
+// Swappable is a Router whose underlying router can be replaced while requests
+// are being served, so a reloaded configuration takes effect without a restart.
+//
+// Each call is served by the router current at the time of the call. Requests
+// keep the routes they already resolved: their plan, provider key, circuit
+// breaker and connection pool stay those of the old router until they finish.
+//
+// Thread Safety: Safe for concurrent use.
+type Swappable struct {
+	current atomic.Pointer[routerBox]
+}
+
+// routerBox holds a Router so it can be stored in an atomic.Pointer.
+type routerBox struct {
+	Router
+}
+
+// NewSwappable creates a Swappable serving r.
+//
+// @param r - the initial router. Must not be nil.
+func NewSwappable(r Router) *Swappable {
+	s := &Swappable{}
+	s.Swap(r)
+	return s
+}
+
+// Swap replaces the underlying router. Calls made after Swap returns are
+// served by r. The idle upstream connections of the replaced router are
+// closed; its requests in flight keep their connections until they finish.
+//
+// @param r - the new router. Must not be nil.
+func (s *Swappable) Swap(r Router) {
+	if old := s.current.Swap(&routerBox{r}); old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+// Current returns the underlying router.
+func (s *Swappable) Current() Router {
+	return s.current.Load().Router
+}
+
+// Resolve resolves a model name with the current router.
+func (s *Swappable) Resolve(modelName string) (*ResolvedRoute, error) {
+	return s.Current().Resolve(modelName)
+}
+
+// ResolveWithProtocol resolves a model name for a protocol with the current router.
+func (s *Swappable) ResolveWithProtocol(modelName, incomingProtocol string) (*ResolvedRoute, error) {
+	return s.Current().ResolveWithProtocol(modelName, incomingProtocol)
+}
+
+// ResolvePlan resolves a model name to its route plan with the current router.
+func (s *Swappable) ResolvePlan(modelName, incomingProtocol string) (*RoutePlan, error) {
+	return s.Current().ResolvePlan(modelName, incomingProtocol)
+}
+
+// KeyStats returns the key counters of the current router.
+func (s *Swappable) KeyStats() []balance.KeyStats {
+	return s.Current().KeyStats()
+}
+
+// ProviderHealth returns the circuit breaker states of the current router.
+func (s *Swappable) ProviderHealth() []circuit.Stats {
+	return s.Current().ProviderHealth()
+}
+
+// ConnectionStats returns the connection pool counters of the current router.
+func (s *Swappable) ConnectionStats() []proxy.PoolStats {
+	return s.Current().ConnectionStats()
+}
+
+// CloseIdleConnections closes the idle upstream connections of the current router.
+func (s *Swappable) CloseIdleConnections() {
+	s.Current().CloseIdleConnections()
+}
+
+// GetProvider retrieves a provider by name from the current router.
+func (s *Swappable) GetProvider(name string) (config.Provider, bool) {
+	return s.Current().GetProvider(name)
+}
+
+// ListModels returns the model names of the current router.
+func (s *Swappable) ListModels() []string {
+	return s.Current().ListModels()
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"testing"
+
+	"ai-proxy/config"
+)
+
+// newModelRouter returns a router serving alias as model on a single provider.
+func newModelRouter(t *testing.T, alias, model string) Router {
+	t.Helper()
+	r, err := NewRouter(&config.Schema{
+		Providers: []config.Provider{
+			{Name: "openai", Endpoints: map[string]string{"openai": "https://api.openai.com/v1/chat/completions"}, APIKey: "key"},
+		},
+		Models: map[string]config.ModelConfig{alias: {Provider: "openai", Model: model}},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() error: %v", err)
+	}
+	return r
+}
+
+func TestSwappable_Swap(t *testing.T) {
+	s := NewSwappable(newModelRouter(t, "fast", "gpt-4o-mini"))
+
+	before, err := s.ResolvePlan("fast", "openai")
+	if err != nil {
+		t.Fatalf("ResolvePlan() error: %v", err)
+	}
+
+	s.Swap(newModelRouter(t, "fast", "gpt-4.1-mini"))
+
+	after, err := s.ResolvePlan("fast", "openai")
+	if err != nil {
+		t.Fatalf("ResolvePlan() after Swap error: %v", err)
+	}
+	if after.Primary().Model != "gpt-4.1-mini" {
+		t.Errorf("after Swap: model = %q, want gpt-4.1-mini", after.Primary().Model)
+	}
+	// Plans resolved before the swap keep their routes
+	if before.Primary().Model != "gpt-4o-mini" {
+		t.Errorf("plan resolved before Swap: model = %q, want gpt-4o-mini", before.Primary().Model)
+	}
+	if models := s.ListModels(); len(models) != 1 || models[0] != "fast" {
+		t.Errorf("ListModels() = %v, want [fast]", models)
+	}
+}
+
+// closeCountingRouter counts the CloseIdleConnections calls of a router.
+type closeCountingRouter struct {
+	Router
+	closed int
+}
+
+func (r *closeCountingRouter) CloseIdleConnections() {
+	r.closed++
+}
+
+func TestSwappable_SwapClosesOldConnections(t *testing.T) {
+	old := &closeCountingRouter{Router: newModelRouter(t, "fast", "gpt-4o-mini")}
+	s := NewSwappable(old)
+	if old.closed != 0 {
+		t.Fatalf("expected no connections closed before Swap, got %d", old.closed)
+	}
+
+	s.Swap(newModelRouter(t, "fast", "gpt-4.1-mini"))
+	if old.closed != 1 {
+		t.Errorf("expected the replaced router's idle connections closed once, got %d", old.closed)
+	}
+}
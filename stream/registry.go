@@ -2,35 +2,112 @@ package stream
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"ai-proxy/transform"
 )
 
+// ErrShutdown is the cause with which streams are interrupted when the server shuts down.
+var ErrShutdown = errors.New("server is shutting down")
+
+// ErrCancelled is the cause with which Cancel interrupts a request registered
+// with RegisterRequest.
+var ErrCancelled = errors.New("stream cancelled")
+
+// Stream phases reported in Info.Phase.
+const (
+	// PhaseConnecting means the request is waiting for an upstream to respond.
+	PhaseConnecting = "connecting"
+	// PhaseStreaming means an upstream responded and its stream is being transformed.
+	PhaseStreaming = "streaming"
+)
+
+// Info describes the request a stream serves and its progress so far.
+type Info struct {
+	ID         string `json:"id"`
+	ResponseID string `json:"response_id,omitempty"`
+	// Protocol is the protocol the client spoke: "anthropic", "openai" or "responses".
+	Protocol string `json:"protocol,omitempty"`
+	// Model is the model alias the client requested.
+	Model         string `json:"model,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	UpstreamModel string `json:"upstream_model,omitempty"`
+	// Client names the authenticated client key, empty without authentication.
+	Client    string    `json:"client,omitempty"`
+	Streaming bool      `json:"streaming"`
+	StartedAt time.Time `json:"started_at"`
+
+	Phase        string `json:"phase"`
+	BytesWritten int    `json:"bytes_written"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	// Transformer is the type of the stream's transformer, once created.
+	Transformer string `json:"transformer,omitempty"`
+	// TransformerState is what the transformer is emitting, for transformers
+	// that implement transform.StateReporter.
+	TransformerState string `json:"transformer_state,omitempty"`
+}
+
 // ActiveStream represents an in-progress streaming response.
 type ActiveStream struct {
 	ID          string
 	Cancel      context.CancelFunc
 	Transformer transform.SSETransformer
 	StartedAt   time.Time
+
+	// interrupt cancels the request serving the stream with a cause.
+	// Nil for streams added with Register.
+	interrupt context.CancelCauseFunc
+
+	// mu guards info, which the goroutine serving the stream updates.
+	mu   sync.Mutex
+	info Info
 }
 
-// Registry tracks active streams for cancellation support.
+// Info returns a snapshot of the stream's description and progress.
+func (s *ActiveStream) Info() Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := s.info
+	info.ID = s.ID
+	info.StartedAt = s.StartedAt
+	return info
+}
+
+// Update changes the stream's description or progress.
+//
+// @param f - modifies the info; called with the stream's lock held
+func (s *ActiveStream) Update(f func(*Info)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(&s.info)
+}
+
+// Registry tracks active streams for inspection and cancellation.
+// Streams are found by their ID or, once known, their response ID.
 type Registry struct {
 	mu      sync.RWMutex
 	streams map[string]*ActiveStream
+	// responseIDs maps response IDs to the IDs of the streams that serve them.
+	responseIDs map[string]string
 }
 
 // NewRegistry creates a new stream registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		streams: make(map[string]*ActiveStream),
+		streams:     make(map[string]*ActiveStream),
+		responseIDs: make(map[string]string),
 	}
 }
 
 // Register adds a new active stream to the registry.
 // The transformer is stored to enable proper cancellation handling.
+// The stream's ID is its response ID, so GetByResponseID finds it too.
 func (r *Registry) Register(id string, cancel context.CancelFunc, transformer transform.SSETransformer) *ActiveStream {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -40,30 +117,126 @@ func (r *Registry) Register(id string, cancel context.CancelFunc, transformer tr
 		Cancel:      cancel,
 		Transformer: transformer,
 		StartedAt:   time.Now(),
+		info:        Info{ResponseID: id},
 	}
 	r.streams[id] = stream
+	r.responseIDs[id] = id
 	return stream
 }
 
-// Cancel attempts to cancel an active stream by ID.
-// Returns true if the stream was found and cancelled.
-// Calls HandleCancel() on the transformer to flush buffered content and emit response.cancelled.
-func (r *Registry) Cancel(id string) bool {
+// RegisterRequest adds an in-flight request, before its upstream has responded.
+// Unlike Register, ending the stream is left to the goroutine serving it: Cancel
+// and Interrupt cancel the request's context with a cause, which that goroutine
+// sees via context.Cause, and it alone calls its transformer.
+//
+// @param info - describes the request; info.ID must be unique
+// @param interrupt - cancels the context of the request
+// @return *ActiveStream - the registered stream, in PhaseConnecting
+func (r *Registry) RegisterRequest(info Info, interrupt context.CancelCauseFunc) *ActiveStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info.Phase = PhaseConnecting
+	stream := &ActiveStream{
+		ID:        info.ID,
+		Cancel:    func() { interrupt(ErrCancelled) },
+		StartedAt: time.Now(),
+		interrupt: interrupt,
+		info:      info,
+	}
+	r.streams[info.ID] = stream
+	return stream
+}
+
+// Attach sets the transformer of a stream registered with RegisterRequest, once
+// its upstream has responded. A transformer with a response ID makes the stream
+// available under that ID too.
+//
+// @param id - the stream ID
+// @param transformer - the stream's transformer; nil detaches it when it is closed
+func (r *Registry) Attach(id string, transformer transform.SSETransformer) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	stream, ok := r.streams[id]
 	if !ok {
-		return false
+		return
+	}
+	stream.Transformer = transformer
+	if transformer == nil {
+		return
+	}
+
+	var responseID string
+	if getter, ok := transformer.(transform.ResponseIDGetter); ok {
+		responseID = getter.GetResponseID()
 	}
+	if responseID != "" {
+		r.responseIDs[responseID] = id
+	}
+	stream.Update(func(info *Info) {
+		info.Phase = PhaseStreaming
+		info.ResponseID = responseID
+		info.Transformer = transformerName(transformer)
+	})
+}
+
+// Interrupt cancels every active stream with cause. Streams added with
+// RegisterRequest stay registered until the goroutines serving them have ended
+// them; streams added with Register are cancelled like Cancel does and removed.
+//
+// @param cause - why the streams are interrupted, e.g. ErrShutdown
+// @return int - the number of streams interrupted
+func (r *Registry) Interrupt(cause error) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	// Call HandleCancel to flush buffered content and emit response.cancelled
-	if stream.Transformer != nil {
-		stream.Transformer.HandleCancel()
+	n := len(r.streams)
+	for id, stream := range r.streams {
+		if stream.interrupt != nil {
+			stream.interrupt(cause)
+			continue
+		}
+		if stream.Transformer != nil {
+			stream.Transformer.HandleCancel()
+		}
+		stream.Cancel()
+		r.removeLocked(id)
 	}
+	return n
+}
 
-	stream.Cancel()
-	delete(r.streams, id)
+// Len returns the number of active streams.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.streams)
+}
+
+// Cancel attempts to cancel an active stream by ID or response ID.
+// Returns true if the stream was found and cancelled.
+// HandleCancel() flushes buffered content and emits e.g. response.cancelled:
+// it is called here for streams added with Register, and by the goroutine
+// serving the request for streams added with RegisterRequest.
+func (r *Registry) Cancel(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stream := r.getLocked(id)
+	if stream == nil {
+		return false
+	}
+
+	if stream.interrupt != nil {
+		stream.interrupt(ErrCancelled)
+	} else {
+		// Call HandleCancel to flush buffered content and emit response.cancelled
+		if stream.Transformer != nil {
+			stream.Transformer.HandleCancel()
+		}
+		stream.Cancel()
+	}
+	r.removeLocked(stream.ID)
 	return true
 }
 
@@ -71,14 +244,25 @@ func (r *Registry) Cancel(id string) bool {
 func (r *Registry) Remove(id string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	delete(r.streams, id)
+	r.removeLocked(id)
 }
 
-// Get retrieves an active stream by ID.
+// Get retrieves an active stream by ID or response ID.
 func (r *Registry) Get(id string) *ActiveStream {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.streams[id]
+	return r.getLocked(id)
+}
+
+// GetByResponseID retrieves an active stream by its response ID only, unlike
+// Get, which also accepts stream IDs.
+func (r *Registry) GetByResponseID(responseID string) *ActiveStream {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if streamID, ok := r.responseIDs[responseID]; ok {
+		return r.streams[streamID]
+	}
+	return nil
 }
 
 // List returns all active stream IDs.
@@ -92,3 +276,52 @@ func (r *Registry) List() []string {
 	}
 	return ids
 }
+
+// Streams returns all active streams, oldest first.
+func (r *Registry) Streams() []*ActiveStream {
+	r.mu.RLock()
+	streams := make([]*ActiveStream, 0, len(r.streams))
+	for _, stream := range r.streams {
+		streams = append(streams, stream)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(streams, func(i, j int) bool {
+		if !streams[i].StartedAt.Equal(streams[j].StartedAt) {
+			return streams[i].StartedAt.Before(streams[j].StartedAt)
+		}
+		return streams[i].ID < streams[j].ID
+	})
+	return streams
+}
+
+// getLocked looks a stream up by ID, then by response ID.
+// Must be called with r.mu held.
+func (r *Registry) getLocked(id string) *ActiveStream {
+	if stream, ok := r.streams[id]; ok {
+		return stream
+	}
+	if streamID, ok := r.responseIDs[id]; ok {
+		return r.streams[streamID]
+	}
+	return nil
+}
+
+// removeLocked removes a stream and its response ID.
+// Must be called with r.mu held.
+func (r *Registry) removeLocked(id string) {
+	stream, ok := r.streams[id]
+	if !ok {
+		return
+	}
+	delete(r.streams, id)
+	if responseID := stream.Info().ResponseID; responseID != "" && r.responseIDs[responseID] == id {
+		delete(r.responseIDs, responseID)
+	}
+}
+
+// transformerName returns the type name of a transformer, e.g. "toolcall.ResponsesTransformer".
+func transformerName(t transform.SSETransformer) string {
+	name := fmt.Sprintf("%T", t)
+	return strings.TrimPrefix(name, "*")
+}
@@ -2,9 +2,12 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
+
+	"ai-proxy/transform"
 )
 
 func TestNewRegistry(t *testing.T) {
@@ -195,3 +198,117 @@ func TestRegistry_ConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestRegistry_RegisterRequest(t *testing.T) {
+	r := NewRegistry()
+	ctx, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+
+	stream := r.RegisterRequest(Info{ID: "req-1", Protocol: "responses", Model: "fast", Client: "ops"}, interrupt)
+	info := stream.Info()
+	if info.ID != "req-1" || info.Model != "fast" || info.Client != "ops" || info.Phase != PhaseConnecting {
+		t.Errorf("Info() = %+v, want the registered request in phase connecting", info)
+	}
+	if info.StartedAt.IsZero() {
+		t.Error("StartedAt is zero")
+	}
+
+	stream.Update(func(info *Info) { info.OutputTokens = 42 })
+	if got := stream.Info().OutputTokens; got != 42 {
+		t.Errorf("OutputTokens after Update = %d, want 42", got)
+	}
+
+	// Cancel leaves ending the stream to the goroutine serving the request
+	if !r.Cancel("req-1") {
+		t.Fatal("Cancel() returned false, expected true")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrCancelled) {
+		t.Errorf("cause after Cancel() = %v, want ErrCancelled", cause)
+	}
+	if r.Get("req-1") != nil {
+		t.Error("Get() returned non-nil after Cancel()")
+	}
+}
+
+// idTransformer is a transformer with a response ID.
+type idTransformer struct {
+	transform.SSETransformer
+	id string
+}
+
+func (t *idTransformer) GetResponseID() string { return t.id }
+
+func TestRegistry_Attach(t *testing.T) {
+	r := NewRegistry()
+	_, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+
+	stream := r.RegisterRequest(Info{ID: "req-1"}, interrupt)
+	transformer := &idTransformer{id: "resp_1"}
+	r.Attach("req-1", transformer)
+
+	info := stream.Info()
+	if info.Phase != PhaseStreaming || info.ResponseID != "resp_1" || info.Transformer != "stream.idTransformer" {
+		t.Errorf("Info() after Attach = %+v", info)
+	}
+	if got := r.Get("resp_1"); got != stream {
+		t.Errorf("Get(response ID) = %v, want the attached stream", got)
+	}
+
+	r.Remove("req-1")
+	if r.Get("resp_1") != nil || r.Get("req-1") != nil {
+		t.Error("Remove() should drop the stream and its response ID")
+	}
+	// Attaching to a finished request is a no-op
+	r.Attach("req-1", transformer)
+	if r.Len() != 0 {
+		t.Errorf("Len() = %d after Attach to a removed stream, want 0", r.Len())
+	}
+}
+
+func TestRegistry_Streams(t *testing.T) {
+	r := NewRegistry()
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, id := range []string{"c", "a", "b"} {
+		r.Register(id, cancel, nil)
+	}
+	streams := r.Streams()
+	if len(streams) != 3 {
+		t.Fatalf("Streams() returned %d streams, want 3", len(streams))
+	}
+	for i := 1; i < len(streams); i++ {
+		if streams[i].StartedAt.Before(streams[i-1].StartedAt) {
+			t.Errorf("Streams() not ordered by start time: %v before %v", streams[i-1].ID, streams[i].ID)
+		}
+	}
+}
+
+func TestRegistry_Interrupt(t *testing.T) {
+	r := NewRegistry()
+	ctx, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+	legacyCtx, cancel := context.WithCancel(context.Background())
+
+	r.RegisterRequest(Info{ID: "interruptible"}, interrupt)
+	r.Register("legacy", cancel, nil)
+
+	if n := r.Interrupt(ErrShutdown); n != 2 {
+		t.Errorf("Interrupt() = %d, want 2", n)
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrShutdown) {
+		t.Errorf("interruptible stream cause = %v, want ErrShutdown", cause)
+	}
+	if legacyCtx.Err() == nil {
+		t.Error("stream added with Register was not cancelled")
+	}
+
+	// The goroutine serving an interruptible stream removes it once it has ended it
+	if r.Get("interruptible") == nil || r.Get("legacy") != nil {
+		t.Errorf("after Interrupt() streams = %v, want [interruptible]", r.List())
+	}
+	if r.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", r.Len())
+	}
+}
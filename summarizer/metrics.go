@@ -0,0 +1,8 @@
+package summarizer
+
+import "ai-proxy/metrics"
+
+// summarizeSeconds observes summarization latency, labeled by mode ("local" or
+// "http") and result ("ok" or "error").
+var summarizeSeconds = metrics.NewHistogramVec("ai_proxy_summarizer_duration_seconds",
+	"Reasoning summarization latency, by mode and result.", nil, "mode", "result")
@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"ai-proxy/config"
 	"ai-proxy/logging"
@@ -22,9 +24,9 @@ import (
 const DefaultPrompt = `Extract the main point in under 10 words.
 Output as JSON: {"summary": "your summary here"}`
 
-// DefaultService is the global summarizer service instance.
-// It is initialized by InitDefaultService and accessed via GetDefaultService.
-var DefaultService *Service
+// defaultService is the global summarizer service instance.
+// It is set by InitDefaultService and accessed via GetDefaultService.
+var defaultService atomic.Pointer[Service]
 
 // localSummarizer is an interface for local summarization (implemented in service_local.go).
 type localSummarizer interface {
@@ -55,10 +57,12 @@ type Service struct {
 }
 
 // InitDefaultService initializes the global summarizer service from the schema.
-// This should be called once at startup, similar to conversation.InitDefaultStore.
+// This is called at startup, similar to conversation.InitDefaultStore, and
+// again when a reloaded configuration changes the summarizer.
 //
 // @param schema - the loaded configuration schema containing providers and summarizer config
-// @post DefaultService is initialized (or nil if disabled/misconfigured)
+// @post GetDefaultService returns the new service (or nil if disabled/misconfigured)
+// @note A replaced service is not closed: streams that already fetched it may still use it.
 func InitDefaultService(schema *config.Schema) {
 	if schema == nil {
 		logging.DebugMsg("Summarizer not initialized: schema is nil")
@@ -71,8 +75,9 @@ func InitDefaultService(schema *config.Schema) {
 		providersMap[p.Name] = p
 	}
 
-	DefaultService = NewService(schema.Summarizer, providersMap)
-	if DefaultService != nil {
+	svc := NewService(schema.Summarizer, providersMap)
+	defaultService.Store(svc)
+	if svc != nil {
 		logging.InfoMsg("Summarizer service initialized and ready")
 	}
 }
@@ -80,7 +85,7 @@ func InitDefaultService(schema *config.Schema) {
 // GetDefaultService returns the global summarizer service instance.
 // Returns nil if InitDefaultService hasn't been called or if summarizer is disabled.
 func GetDefaultService() *Service {
-	return DefaultService
+	return defaultService.Load()
 }
 
 // NewService creates a new summarizer service.
@@ -182,11 +187,22 @@ func (s *Service) Summarize(ctx context.Context, reasoningContent string) (strin
 		return "", fmt.Errorf("summarizer service not initialized")
 	}
 
+	start := time.Now()
+	mode := "http"
+	summarize := s.summarizeHTTP
 	// Use local or HTTP based on mode
 	if s.local != nil {
-		return s.summarizeLocal(ctx, reasoningContent)
+		mode = "local"
+		summarize = s.summarizeLocal
 	}
-	return s.summarizeHTTP(ctx, reasoningContent)
+
+	summary, err := summarize(ctx, reasoningContent)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	summarizeSeconds.Observe(time.Since(start).Seconds(), mode, result)
+	return summary, err
 }
 
 // summarizeLocal uses local llama.cpp inference for summarization.
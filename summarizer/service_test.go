@@ -225,10 +225,14 @@ func TestSummarize_Error(t *testing.T) {
 		t.Fatal("expected non-nil service")
 	}
 
+	before := summarizeSeconds.Count("http", "error")
 	_, err := svc.Summarize(context.Background(), "content")
 	if err == nil {
 		t.Error("expected error from summarizer")
 	}
+	if summarizeSeconds.Count("http", "error")-before != 1 {
+		t.Error("expected the failed summarization latency to be observed")
+	}
 }
 
 func TestSummarize_NilService(t *testing.T) {
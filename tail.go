@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ai-proxy/events"
+)
+
+// runTail implements `ai-proxy tail`: it prints the request lifecycle events of
+// a running proxy, one line per event, until interrupted.
+//
+// @param args - the arguments after "tail".
+// @param stdout - where events are printed.
+// @param stderr - where usage and errors are printed.
+// @return int - the process exit code.
+//
+// @note The proxy URL and admin key default to the AI_PROXY_URL and
+// AI_PROXY_API_KEY environment variables.
+func runTail(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	baseURL := fs.String("url", envOr("AI_PROXY_URL", "http://localhost:8080"), "Base URL of the proxy")
+	apiKey := fs.String("api-key", os.Getenv("AI_PROXY_API_KEY"), "Admin client key, if the proxy requires authentication")
+	session := fs.String("session", "", "Only show requests of this X-Session-ID")
+	model := fs.String("model", "", "Only show requests for this model alias or upstream model")
+	client := fs.String("client", "", "Only show requests of this client key name")
+	asJSON := fs.Bool("json", false, "Print events as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	filter := events.Filter{Session: *session, Model: *model, Client: *client}
+	enc := json.NewEncoder(stdout)
+	err := events.Tail(ctx, *baseURL, *apiKey, filter, func(e events.Event) {
+		if *asJSON {
+			enc.Encode(e)
+			return
+		}
+		fmt.Fprintln(stdout, events.Format(e))
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// envOr returns the environment variable key, or def if it is unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -45,6 +45,14 @@ func CountTokensForModel(model string) (*Counter, error) {
 	return NewCounter("cl100k_base")
 }
 
+// CountText counts tokens in a plain text string.
+//
+// @param text - The text to encode.
+// @return int - Token count.
+func (c *Counter) CountText(text string) int {
+	return len(c.encoder.Encode(text, nil, nil))
+}
+
 // CountMessageTokens counts tokens for a complete message request.
 // Includes tokens for messages, system prompt, tools, and Anthropic formatting overhead.
 //
@@ -327,3 +327,19 @@ func TestCounter_countFormattingOverhead(t *testing.T) {
 		t.Errorf("Overhead should increase with more messages/tools: %d <= %d", overhead3, overhead2)
 	}
 }
+
+func TestCounter_CountText(t *testing.T) {
+	counter, err := NewCounter("cl100k_base")
+	if err != nil {
+		t.Fatalf("NewCounter() error: %v", err)
+	}
+
+	if got := counter.CountText(""); got != 0 {
+		t.Errorf("CountText(\"\") = %d, want 0", got)
+	}
+	short := counter.CountText("Hello")
+	long := counter.CountText("Hello, world! This is a longer sentence.")
+	if short <= 0 || long <= short {
+		t.Errorf("CountText() = %d (short), %d (long); want 0 < short < long", short, long)
+	}
+}
@@ -0,0 +1,98 @@
+// Package aggregate assembles streamed SSE output into non-streaming responses.
+// The proxy always streams from upstream so that tool-call extraction, web search
+// interception, and conversation storage keep working. For clients that send
+// "stream": false, the transformer chain writes into an Aggregator instead of the
+// HTTP response, and the aggregator builds the single JSON body the client expects.
+package aggregate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Aggregator collects the SSE output of a transformer chain and assembles it
+// into the equivalent non-streaming response body.
+//
+// @note Implementations are NOT thread-safe. Use from a single goroutine.
+type Aggregator interface {
+	io.Writer
+
+	// Result returns the assembled JSON response body.
+	//
+	// @return The JSON body in the downstream protocol's non-streaming format.
+	// @return *StreamError if the stream reported a failure or never produced a response.
+	//
+	// @pre All transformer output has been written (the transformer is closed).
+	Result() ([]byte, error)
+}
+
+// StreamError reports a failure carried inside the event stream itself,
+// such as an Anthropic "error" event or a Responses "response.failed" event.
+type StreamError struct {
+	// Message is the human-readable error description from the stream.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *StreamError) Error() string {
+	return e.Message
+}
+
+// eventReader splits written bytes into complete SSE events and hands each
+// event to a callback. Partial events are buffered until the terminating
+// blank line arrives.
+type eventReader struct {
+	buf     bytes.Buffer
+	onEvent func(eventType, data string)
+}
+
+// Write implements io.Writer. It never fails; malformed events are ignored.
+func (r *eventReader) Write(p []byte) (int, error) {
+	r.buf.Write(p)
+
+	data := r.buf.Bytes()
+	for {
+		idx := bytes.Index(data, []byte("\n\n"))
+		if idx == -1 {
+			break
+		}
+		r.dispatch(data[:idx])
+		data = data[idx+2:]
+	}
+
+	// Keep the remaining partial event for the next write
+	rest := append([]byte(nil), data...)
+	r.buf.Reset()
+	r.buf.Write(rest)
+
+	return len(p), nil
+}
+
+// dispatch parses a single SSE event block and invokes the callback.
+// Multiple data lines are joined with newlines as required by the SSE spec.
+func (r *eventReader) dispatch(block []byte) {
+	var eventType string
+	var dataLines []string
+
+	for _, line := range strings.Split(string(block), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(line[len("event:"):])
+		case strings.HasPrefix(line, "data:"):
+			dataLines = append(dataLines, strings.TrimPrefix(line[len("data:"):], " "))
+		}
+	}
+
+	if len(dataLines) == 0 {
+		return
+	}
+	r.onEvent(eventType, strings.Join(dataLines, "\n"))
+}
+
+// errIncomplete is returned when the stream ended before any response data arrived.
+func errIncomplete(protocol string) error {
+	return &StreamError{Message: fmt.Sprintf("upstream stream ended without a %s response", protocol)}
+}
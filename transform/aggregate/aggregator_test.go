@@ -0,0 +1,295 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// writeChunked writes s in small pieces to exercise partial-event buffering.
+func writeChunked(t *testing.T, a Aggregator, s string) {
+	t.Helper()
+	for len(s) > 0 {
+		n := 7
+		if n > len(s) {
+			n = len(s)
+		}
+		if _, err := a.Write([]byte(s[:n])); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		s = s[n:]
+	}
+}
+
+func decode(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, body)
+	}
+	return out
+}
+
+func TestEventReader_JoinsMultipleDataLines(t *testing.T) {
+	var got []string
+	r := &eventReader{onEvent: func(_, data string) { got = append(got, data) }}
+
+	r.Write([]byte("event: x\ndata: a\ndata: b\n\ndata: c\n"))
+	if len(got) != 1 || got[0] != "a\nb" {
+		t.Fatalf("got %q, want [\"a\\nb\"]", got)
+	}
+	r.Write([]byte("\n"))
+	if len(got) != 2 || got[1] != "c" {
+		t.Fatalf("got %q, want second event \"c\"", got)
+	}
+}
+
+func TestChatAggregator_TextAndToolCalls(t *testing.T) {
+	stream := `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":100,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"think "}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":100,"model":"m","choices":[{"index":0,"delta":{"content":"Hello"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":100,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get","arguments":"{\"a\":"}}]}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":100,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":100,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":100,"model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}
+
+data: [DONE]
+
+`
+	a := NewChatAggregator()
+	writeChunked(t, a, stream)
+
+	body, err := a.Result()
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	out := decode(t, body)
+
+	if out["object"] != "chat.completion" || out["id"] != "chatcmpl-1" || out["model"] != "m" {
+		t.Errorf("unexpected envelope: %v", out)
+	}
+	choice := out["choices"].([]interface{})[0].(map[string]interface{})
+	if choice["finish_reason"] != "tool_calls" {
+		t.Errorf("finish_reason = %v, want tool_calls", choice["finish_reason"])
+	}
+	msg := choice["message"].(map[string]interface{})
+	if msg["content"] != "Hello" {
+		t.Errorf("content = %v, want Hello", msg["content"])
+	}
+	if msg["reasoning_content"] != "think " {
+		t.Errorf("reasoning_content = %v", msg["reasoning_content"])
+	}
+	call := msg["tool_calls"].([]interface{})[0].(map[string]interface{})
+	fn := call["function"].(map[string]interface{})
+	if call["id"] != "call_1" || fn["name"] != "get" || fn["arguments"] != `{"a":1}` {
+		t.Errorf("unexpected tool call: %v", call)
+	}
+	usage := out["usage"].(map[string]interface{})
+	if usage["total_tokens"] != float64(7) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestChatAggregator_NullContentForToolOnly(t *testing.T) {
+	a := NewChatAggregator()
+	writeChunked(t, a, `data: {"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"x","function":{"name":"f"}}]}}]}`+"\n\n")
+
+	body, err := a.Result()
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	msg := decode(t, body)["choices"].([]interface{})[0].(map[string]interface{})["message"].(map[string]interface{})
+	if v, ok := msg["content"]; !ok || v != nil {
+		t.Errorf("content = %v, want explicit null", v)
+	}
+	fn := msg["tool_calls"].([]interface{})[0].(map[string]interface{})["function"].(map[string]interface{})
+	if fn["arguments"] != "{}" {
+		t.Errorf("arguments = %v, want {}", fn["arguments"])
+	}
+}
+
+func TestChatAggregator_Errors(t *testing.T) {
+	t.Run("error chunk", func(t *testing.T) {
+		a := NewChatAggregator()
+		writeChunked(t, a, `data: {"error":{"message":"boom","type":"server_error"}}`+"\n\n")
+		_, err := a.Result()
+		var se *StreamError
+		if !errors.As(err, &se) || se.Message != "boom" {
+			t.Fatalf("Result() error = %v, want StreamError boom", err)
+		}
+	})
+	t.Run("empty stream", func(t *testing.T) {
+		if _, err := NewChatAggregator().Result(); err == nil {
+			t.Fatal("expected error for empty stream")
+		}
+	})
+}
+
+func TestAnthropicAggregator_Message(t *testing.T) {
+	stream := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hi "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"there"}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"x\"}"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":20}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	a := NewAnthropicAggregator()
+	writeChunked(t, a, stream)
+
+	body, err := a.Result()
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	out := decode(t, body)
+
+	if out["id"] != "msg_1" || out["type"] != "message" || out["model"] != "claude" {
+		t.Errorf("unexpected envelope: %v", out)
+	}
+	if out["stop_reason"] != "tool_use" {
+		t.Errorf("stop_reason = %v", out["stop_reason"])
+	}
+	usage := out["usage"].(map[string]interface{})
+	if usage["input_tokens"] != float64(10) || usage["output_tokens"] != float64(20) {
+		t.Errorf("usage = %v", usage)
+	}
+
+	content := out["content"].([]interface{})
+	if len(content) != 3 {
+		t.Fatalf("len(content) = %d, want 3", len(content))
+	}
+	thinking := content[0].(map[string]interface{})
+	if thinking["thinking"] != "hmm" || thinking["signature"] != "sig" {
+		t.Errorf("thinking block = %v", thinking)
+	}
+	text := content[1].(map[string]interface{})
+	if text["text"] != "Hi there" {
+		t.Errorf("text block = %v", text)
+	}
+	tool := content[2].(map[string]interface{})
+	input := tool["input"].(map[string]interface{})
+	if tool["id"] != "toolu_1" || input["q"] != "x" {
+		t.Errorf("tool block = %v", tool)
+	}
+}
+
+func TestAnthropicAggregator_ErrorEvent(t *testing.T) {
+	a := NewAnthropicAggregator()
+	writeChunked(t, a, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+
+	_, err := a.Result()
+	if err == nil || err.Error() != "Overloaded" {
+		t.Fatalf("Result() error = %v, want Overloaded", err)
+	}
+}
+
+func TestResponsesAggregator_Completed(t *testing.T) {
+	stream := `event: response.created
+data: {"type":"response.created","response":{"id":"resp_1","object":"response","status":"in_progress","output":[]}}
+
+event: response.output_item.done
+data: {"type":"response.output_item.done","output_index":0,"item":{"type":"message","id":"msg_1","content":[{"type":"output_text","text":"Hi"}]}}
+
+event: response.completed
+data: {"type":"response.completed","response":{"id":"resp_1","object":"response","status":"completed","output":[{"type":"message","id":"msg_1","content":[{"type":"output_text","text":"Hi"}]}],"usage":{"input_tokens":1,"output_tokens":2,"total_tokens":3}}}
+
+`
+	a := NewResponsesAggregator()
+	writeChunked(t, a, stream)
+
+	body, err := a.Result()
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	out := decode(t, body)
+	if out["id"] != "resp_1" || out["status"] != "completed" {
+		t.Errorf("unexpected response: %v", out)
+	}
+	if len(out["output"].([]interface{})) != 1 {
+		t.Errorf("output = %v", out["output"])
+	}
+}
+
+func TestResponsesAggregator_FallsBackToItems(t *testing.T) {
+	stream := `data: {"type":"response.created","response":{"id":"resp_1","status":"in_progress","output":[]}}
+
+data: {"type":"response.output_item.done","item":{"type":"function_call","call_id":"c1"}}
+
+`
+	a := NewResponsesAggregator()
+	writeChunked(t, a, stream)
+
+	body, err := a.Result()
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	output := decode(t, body)["output"].([]interface{})
+	if len(output) != 1 || output[0].(map[string]interface{})["call_id"] != "c1" {
+		t.Errorf("output = %v", output)
+	}
+}
+
+func TestResponsesAggregator_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{
+			name:   "failed response",
+			stream: `data: {"type":"response.failed","response":{"id":"r","status":"failed","error":{"code":"server_error","message":"bad"}}}` + "\n\n",
+			want:   "bad",
+		},
+		{
+			name:   "error event",
+			stream: `data: {"type":"error","message":"rate limited"}` + "\n\n",
+			want:   "rate limited",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewResponsesAggregator()
+			writeChunked(t, a, tt.stream)
+			_, err := a.Result()
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("Result() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// AnthropicAggregator assembles Anthropic Messages streaming events into a
+// single message object.
+type AnthropicAggregator struct {
+	reader eventReader
+
+	message map[string]interface{}
+	usage   map[string]interface{}
+	blocks  map[int]*anthropicBlockState
+	err     *StreamError
+
+	stopReason   interface{}
+	stopSequence interface{}
+}
+
+// anthropicBlockState accumulates the deltas of a single content block.
+type anthropicBlockState struct {
+	block       map[string]interface{}
+	text        strings.Builder
+	thinking    strings.Builder
+	signature   strings.Builder
+	partialJSON strings.Builder
+	citations   []interface{}
+}
+
+// NewAnthropicAggregator creates an aggregator for the /v1/messages endpoint.
+func NewAnthropicAggregator() *AnthropicAggregator {
+	a := &AnthropicAggregator{
+		usage:  make(map[string]interface{}),
+		blocks: make(map[int]*anthropicBlockState),
+	}
+	a.reader.onEvent = a.handleEvent
+	return a
+}
+
+// Write implements io.Writer.
+func (a *AnthropicAggregator) Write(p []byte) (int, error) {
+	return a.reader.Write(p)
+}
+
+// anthropicEvent is the union of the streaming event fields used for aggregation.
+type anthropicEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      map[string]interface{} `json:"message"`
+	ContentBlock map[string]interface{} `json:"content_block"`
+	Delta        json.RawMessage        `json:"delta"`
+	Usage        map[string]interface{} `json:"usage"`
+	Error        *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicDelta is the union of content_block_delta and message_delta payloads.
+type anthropicDelta struct {
+	Type         string      `json:"type"`
+	Text         string      `json:"text"`
+	Thinking     string      `json:"thinking"`
+	Signature    string      `json:"signature"`
+	PartialJSON  string      `json:"partial_json"`
+	Citation     interface{} `json:"citation"`
+	StopReason   interface{} `json:"stop_reason"`
+	StopSequence interface{} `json:"stop_sequence"`
+}
+
+// handleEvent processes a single Anthropic streaming event.
+func (a *AnthropicAggregator) handleEvent(eventType string, data string) {
+	var event anthropicEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return
+	}
+	if event.Type == "" {
+		event.Type = eventType
+	}
+
+	switch event.Type {
+	case "message_start":
+		a.message = event.Message
+		if usage, ok := event.Message["usage"].(map[string]interface{}); ok {
+			mergeUsage(a.usage, usage)
+		}
+
+	case "content_block_start":
+		a.blocks[event.Index] = &anthropicBlockState{block: event.ContentBlock}
+
+	case "content_block_delta":
+		state, ok := a.blocks[event.Index]
+		if !ok {
+			state = &anthropicBlockState{block: map[string]interface{}{}}
+			a.blocks[event.Index] = state
+		}
+		var delta anthropicDelta
+		if err := json.Unmarshal(event.Delta, &delta); err != nil {
+			return
+		}
+		switch delta.Type {
+		case "text_delta":
+			state.text.WriteString(delta.Text)
+		case "thinking_delta":
+			state.thinking.WriteString(delta.Thinking)
+		case "signature_delta":
+			state.signature.WriteString(delta.Signature)
+		case "input_json_delta":
+			state.partialJSON.WriteString(delta.PartialJSON)
+		case "citations_delta":
+			if delta.Citation != nil {
+				state.citations = append(state.citations, delta.Citation)
+			}
+		}
+
+	case "message_delta":
+		var delta anthropicDelta
+		if err := json.Unmarshal(event.Delta, &delta); err == nil {
+			if delta.StopReason != nil {
+				a.stopReason = delta.StopReason
+			}
+			if delta.StopSequence != nil {
+				a.stopSequence = delta.StopSequence
+			}
+		}
+		mergeUsage(a.usage, event.Usage)
+
+	case "error":
+		msg := "upstream stream error"
+		if event.Error != nil && event.Error.Message != "" {
+			msg = event.Error.Message
+		}
+		a.err = &StreamError{Message: msg}
+	}
+}
+
+// Result returns the assembled message JSON body.
+func (a *AnthropicAggregator) Result() ([]byte, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if a.message == nil {
+		return nil, errIncomplete("message")
+	}
+
+	indexes := make([]int, 0, len(a.blocks))
+	for idx := range a.blocks {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	content := make([]interface{}, 0, len(indexes))
+	for _, idx := range indexes {
+		content = append(content, a.blocks[idx].finish())
+	}
+
+	out := map[string]interface{}{
+		"id":            a.message["id"],
+		"type":          "message",
+		"role":          "assistant",
+		"model":         a.message["model"],
+		"content":       content,
+		"stop_reason":   a.stopReason,
+		"stop_sequence": a.stopSequence,
+		"usage":         a.usage,
+	}
+	return json.Marshal(out)
+}
+
+// finish merges the accumulated deltas into the content block.
+func (s *anthropicBlockState) finish() map[string]interface{} {
+	block := s.block
+	if block == nil {
+		block = map[string]interface{}{}
+	}
+
+	switch block["type"] {
+	case "text":
+		block["text"] = stringField(block, "text") + s.text.String()
+		if len(s.citations) > 0 {
+			block["citations"] = s.citations
+		}
+	case "thinking":
+		block["thinking"] = stringField(block, "thinking") + s.thinking.String()
+		block["signature"] = stringField(block, "signature") + s.signature.String()
+	case "tool_use", "server_tool_use":
+		if s.partialJSON.Len() > 0 {
+			var input interface{}
+			if err := json.Unmarshal([]byte(s.partialJSON.String()), &input); err == nil {
+				block["input"] = input
+			}
+		}
+		if block["input"] == nil {
+			block["input"] = map[string]interface{}{}
+		}
+	}
+	return block
+}
+
+// stringField returns a string field of a block, or "" when absent.
+func stringField(block map[string]interface{}, key string) string {
+	s, _ := block[key].(string)
+	return s
+}
+
+// mergeUsage copies non-nil usage counters from src into dst.
+// Later events carry cumulative counts, so values are overwritten rather than summed.
+func mergeUsage(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if v != nil {
+			dst[k] = v
+		}
+	}
+}
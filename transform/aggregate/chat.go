@@ -0,0 +1,202 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"ai-proxy/types"
+)
+
+// ChatAggregator assembles Chat Completions streaming chunks into a single
+// chat.completion object.
+type ChatAggregator struct {
+	reader eventReader
+
+	id      string
+	model   string
+	created int64
+	usage   *types.Usage
+	err     *StreamError
+
+	choices map[int]*chatChoiceState
+}
+
+// chatChoiceState accumulates the deltas of a single choice.
+type chatChoiceState struct {
+	content      strings.Builder
+	hasContent   bool
+	reasoning    strings.Builder
+	finishReason string
+	toolCalls    map[int]*types.ToolCall
+}
+
+// NewChatAggregator creates an aggregator for the /v1/chat/completions endpoint.
+func NewChatAggregator() *ChatAggregator {
+	a := &ChatAggregator{choices: make(map[int]*chatChoiceState)}
+	a.reader.onEvent = a.handleEvent
+	return a
+}
+
+// Write implements io.Writer.
+func (a *ChatAggregator) Write(p []byte) (int, error) {
+	return a.reader.Write(p)
+}
+
+// handleEvent processes a single chat.completion.chunk event.
+func (a *ChatAggregator) handleEvent(_ string, data string) {
+	if data == "[DONE]" {
+		return
+	}
+
+	// Error payloads use the OpenAI error envelope instead of a chunk
+	var envelope struct {
+		Error *types.ErrorDetail `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &envelope); err == nil && envelope.Error != nil {
+		a.err = &StreamError{Message: envelope.Error.Message}
+		return
+	}
+
+	var chunk types.Chunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if a.id == "" && chunk.ID != "" {
+		a.id = chunk.ID
+	}
+	if a.model == "" && chunk.Model != "" {
+		a.model = chunk.Model
+	}
+	if a.created == 0 && chunk.Created != 0 {
+		a.created = chunk.Created
+	}
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+
+	for _, choice := range chunk.Choices {
+		a.applyChoice(choice)
+	}
+}
+
+// applyChoice merges a streamed choice delta into the accumulated state.
+func (a *ChatAggregator) applyChoice(choice types.Choice) {
+	state, ok := a.choices[choice.Index]
+	if !ok {
+		state = &chatChoiceState{toolCalls: make(map[int]*types.ToolCall)}
+		a.choices[choice.Index] = state
+	}
+
+	delta := choice.Delta
+	if delta.Content != "" {
+		state.content.WriteString(delta.Content)
+		state.hasContent = true
+	}
+	if delta.ReasoningContent != "" {
+		state.reasoning.WriteString(delta.ReasoningContent)
+	} else if delta.Reasoning != "" {
+		state.reasoning.WriteString(delta.Reasoning)
+	}
+
+	for _, tc := range delta.ToolCalls {
+		call, ok := state.toolCalls[tc.Index]
+		if !ok {
+			call = &types.ToolCall{Index: tc.Index, Type: "function"}
+			state.toolCalls[tc.Index] = call
+		}
+		if tc.ID != "" {
+			call.ID = tc.ID
+		}
+		if tc.Type != "" {
+			call.Type = tc.Type
+		}
+		if tc.Function.Name != "" {
+			call.Function.Name = tc.Function.Name
+		}
+		call.Function.Arguments += tc.Function.Arguments
+	}
+
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		state.finishReason = *choice.FinishReason
+	} else if delta.FinishReason != nil && *delta.FinishReason != "" {
+		state.finishReason = *delta.FinishReason
+	}
+}
+
+// Result returns the assembled chat.completion JSON body.
+func (a *ChatAggregator) Result() ([]byte, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if a.id == "" && len(a.choices) == 0 {
+		return nil, errIncomplete("chat.completion")
+	}
+
+	indexes := make([]int, 0, len(a.choices))
+	for idx := range a.choices {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	completion := types.ChatCompletion{
+		ID:      a.id,
+		Object:  "chat.completion",
+		Created: a.created,
+		Model:   a.model,
+		Choices: make([]types.CompletionChoice, 0, len(indexes)),
+		Usage:   a.usage,
+	}
+
+	for _, idx := range indexes {
+		state := a.choices[idx]
+		msg := types.CompletionMessage{
+			Role:             "assistant",
+			ReasoningContent: state.reasoning.String(),
+			ToolCalls:        sortedToolCalls(state.toolCalls),
+		}
+		if state.hasContent {
+			content := state.content.String()
+			msg.Content = &content
+		}
+
+		finishReason := state.finishReason
+		if finishReason == "" {
+			finishReason = "stop"
+			if len(msg.ToolCalls) > 0 {
+				finishReason = "tool_calls"
+			}
+		}
+
+		completion.Choices = append(completion.Choices, types.CompletionChoice{
+			Index:        idx,
+			Message:      msg,
+			FinishReason: finishReason,
+		})
+	}
+
+	return json.Marshal(completion)
+}
+
+// sortedToolCalls returns the accumulated tool calls ordered by stream index.
+func sortedToolCalls(calls map[int]*types.ToolCall) []types.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(calls))
+	for idx := range calls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	out := make([]types.ToolCall, 0, len(indexes))
+	for _, idx := range indexes {
+		call := *calls[idx]
+		if call.Function.Arguments == "" {
+			call.Function.Arguments = "{}"
+		}
+		out = append(out, call)
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package aggregate
+
+import (
+	"encoding/json"
+)
+
+// ResponsesAggregator assembles OpenAI Responses API streaming events into a
+// single response object.
+type ResponsesAggregator struct {
+	reader eventReader
+
+	response map[string]interface{}
+	items    []interface{}
+	err      *StreamError
+}
+
+// NewResponsesAggregator creates an aggregator for the /v1/responses endpoint.
+func NewResponsesAggregator() *ResponsesAggregator {
+	a := &ResponsesAggregator{}
+	a.reader.onEvent = a.handleEvent
+	return a
+}
+
+// Write implements io.Writer.
+func (a *ResponsesAggregator) Write(p []byte) (int, error) {
+	return a.reader.Write(p)
+}
+
+// responsesEvent is the union of the streaming event fields used for aggregation.
+type responsesEvent struct {
+	Type     string                 `json:"type"`
+	Response map[string]interface{} `json:"response"`
+	Item     interface{}            `json:"item"`
+	Message  string                 `json:"message"`
+	Error    *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// handleEvent processes a single Responses streaming event.
+// Every lifecycle event carries a snapshot of the response; the last one wins.
+func (a *ResponsesAggregator) handleEvent(eventType string, data string) {
+	var event responsesEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return
+	}
+	if event.Type == "" {
+		event.Type = eventType
+	}
+
+	switch event.Type {
+	case "response.created", "response.in_progress", "response.completed",
+		"response.incomplete", "response.failed", "response.cancelled":
+		if event.Response != nil {
+			a.response = event.Response
+		}
+
+	case "response.output_item.done":
+		if event.Item != nil {
+			a.items = append(a.items, event.Item)
+		}
+
+	case "error":
+		msg := event.Message
+		if msg == "" && event.Error != nil {
+			msg = event.Error.Message
+		}
+		if msg == "" {
+			msg = "upstream stream error"
+		}
+		a.err = &StreamError{Message: msg}
+	}
+}
+
+// Result returns the assembled response JSON body.
+func (a *ResponsesAggregator) Result() ([]byte, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if a.response == nil {
+		return nil, errIncomplete("response")
+	}
+
+	if status, _ := a.response["status"].(string); status == "failed" {
+		msg := "upstream response failed"
+		if errObj, ok := a.response["error"].(map[string]interface{}); ok {
+			if m, ok := errObj["message"].(string); ok && m != "" {
+				msg = m
+			}
+		}
+		return nil, &StreamError{Message: msg}
+	}
+
+	// Snapshots from early events have an empty output; fall back to the
+	// items collected from output_item.done events.
+	if output, _ := a.response["output"].([]interface{}); len(output) == 0 && len(a.items) > 0 {
+		a.response["output"] = a.items
+	}
+
+	return json.Marshal(a.response)
+}
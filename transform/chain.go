@@ -0,0 +1,176 @@
+// Package transform provides interfaces and utilities for transforming SSE events.
+// This file chains two transformers, so that the output of one is transformed by another.
+package transform
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// ChainTransformer runs two transformers in sequence: the SSE events the first
+// writes are parsed and transformed by the second. This combines a protocol
+// conversion with a transformer of the converted protocol, e.g. tool call
+// markup extraction.
+//
+// @note NOT thread-safe. Use from a single goroutine.
+type ChainTransformer struct {
+	first  SSETransformer
+	second SSETransformer
+	events *eventWriter
+}
+
+// NewChainTransformer creates a transformer feeding the output of a first
+// transformer into second.
+//
+// @param newFirst - Builds the first transformer, writing to the given writer.
+// @param second - Transformer receiving the events of the first. Must not be nil.
+func NewChainTransformer(newFirst func(w io.Writer) SSETransformer, second SSETransformer) *ChainTransformer {
+	events := &eventWriter{next: second}
+	return &ChainTransformer{
+		first:  newFirst(events),
+		second: second,
+		events: events,
+	}
+}
+
+// SetContext sets the request context on both transformers, if they accept one.
+func (t *ChainTransformer) SetContext(ctx context.Context) {
+	for _, tr := range []SSETransformer{t.first, t.second} {
+		if ct, ok := tr.(interface{ SetContext(context.Context) }); ok {
+			ct.SetContext(ctx)
+		}
+	}
+}
+
+// Initialize initializes the second transformer, then the first, so that the
+// initial events of the first are transformed too.
+func (t *ChainTransformer) Initialize() error {
+	if err := t.second.Initialize(); err != nil {
+		return err
+	}
+	return t.first.Initialize()
+}
+
+// Transform passes an event to the first transformer.
+//
+// @return The first transformer's error, or the second's for the events written.
+func (t *ChainTransformer) Transform(event *sse.Event) error {
+	if err := t.first.Transform(event); err != nil {
+		return err
+	}
+	return t.events.takeErr()
+}
+
+// Flush flushes the first transformer, then the second.
+func (t *ChainTransformer) Flush() error {
+	if err := t.first.Flush(); err != nil {
+		return err
+	}
+	if err := t.events.takeErr(); err != nil {
+		return err
+	}
+	return t.second.Flush()
+}
+
+// Close closes the first transformer, so that its final events reach the
+// second, then closes the second.
+func (t *ChainTransformer) Close() error {
+	if err := t.first.Close(); err != nil {
+		return err
+	}
+	if err := t.events.takeErr(); err != nil {
+		return err
+	}
+	return t.second.Close()
+}
+
+// HandleCancel cancels the first transformer, then the second.
+func (t *ChainTransformer) HandleCancel() error {
+	if err := t.first.HandleCancel(); err != nil {
+		return err
+	}
+	if err := t.events.takeErr(); err != nil {
+		return err
+	}
+	return t.second.HandleCancel()
+}
+
+// GetResponseID returns the response ID of the first transformer, if it has one.
+// Implements ResponseIDGetter interface.
+func (t *ChainTransformer) GetResponseID() string {
+	if getter, ok := t.first.(ResponseIDGetter); ok {
+		return getter.GetResponseID()
+	}
+	return ""
+}
+
+// State reports a tool call the second transformer emits, and otherwise the
+// state of the first.
+// Implements StateReporter interface.
+func (t *ChainTransformer) State() string {
+	if reporter, ok := t.second.(StateReporter); ok && reporter.State() == StateToolCall {
+		return StateToolCall
+	}
+	if reporter, ok := t.first.(StateReporter); ok {
+		return reporter.State()
+	}
+	return StateWaiting
+}
+
+// eventWriter parses the SSE output of a transformer and passes each complete
+// event to the next one.
+type eventWriter struct {
+	next SSETransformer
+	buf  bytes.Buffer // partial SSE event
+	err  error        // first error of next, until taken
+}
+
+// Write implements io.Writer. A partial event is buffered until its
+// terminating blank line.
+func (w *eventWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	data := w.buf.Bytes()
+	for {
+		idx := bytes.Index(data, []byte("\n\n"))
+		if idx == -1 {
+			break
+		}
+		if err := w.next.Transform(parseEvent(data[:idx])); err != nil && w.err == nil {
+			w.err = err
+		}
+		data = data[idx+2:]
+	}
+	rest := append([]byte(nil), data...)
+	w.buf.Reset()
+	w.buf.Write(rest)
+	return len(p), nil
+}
+
+// takeErr returns the first error of the next transformer since the last call.
+func (w *eventWriter) takeErr() error {
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// parseEvent parses the event and data lines of one SSE event block.
+func parseEvent(block []byte) *sse.Event {
+	var (
+		event sse.Event
+		data  []string
+	)
+	for _, line := range strings.Split(string(block), "\n") {
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event.Type = strings.TrimPrefix(strings.TrimPrefix(line, "event:"), " ")
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	event.Data = strings.Join(data, "\n")
+	return &event
+}
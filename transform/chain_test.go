@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// recordingTransformer records the events and calls it receives.
+type recordingTransformer struct {
+	events []sse.Event
+	calls  []string
+	err    error
+}
+
+func (r *recordingTransformer) Initialize() error {
+	r.calls = append(r.calls, "initialize")
+	return nil
+}
+
+func (r *recordingTransformer) HandleCancel() error {
+	r.calls = append(r.calls, "cancel")
+	return nil
+}
+
+func (r *recordingTransformer) Transform(event *sse.Event) error {
+	r.events = append(r.events, *event)
+	return r.err
+}
+
+func (r *recordingTransformer) Flush() error {
+	r.calls = append(r.calls, "flush")
+	return nil
+}
+
+func (r *recordingTransformer) Close() error {
+	r.calls = append(r.calls, "close")
+	return nil
+}
+
+// splitWriter writes every byte separately, as a transformer writing an event
+// in several calls would.
+type splitWriter struct{ w io.Writer }
+
+func (s splitWriter) Write(p []byte) (int, error) {
+	for i := range p {
+		if _, err := s.w.Write(p[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(p), nil
+}
+
+func TestChainTransformer_PassesEventsToSecond(t *testing.T) {
+	second := &recordingTransformer{}
+	chain := NewChainTransformer(func(w io.Writer) SSETransformer {
+		return NewPassthroughTransformer(splitWriter{w})
+	}, second)
+
+	if err := chain.Initialize(); err != nil {
+		t.Fatalf("Initialize returned error: %v", err)
+	}
+	for _, ev := range []sse.Event{
+		{Type: "message_start", Data: `{"id":"1"}`},
+		{Type: "content_block_delta", Data: `{"delta":"Hi"}`},
+		{Data: "[DONE]"},
+	} {
+		if err := chain.Transform(&ev); err != nil {
+			t.Fatalf("Transform returned error: %v", err)
+		}
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	want := []sse.Event{
+		{Type: "message_start", Data: `{"id":"1"}`},
+		{Type: "content_block_delta", Data: `{"delta":"Hi"}`},
+		{Data: "[DONE]"},
+	}
+	if len(second.events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), second.events)
+	}
+	for i := range want {
+		if second.events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, second.events[i], want[i])
+		}
+	}
+	if got := second.calls; len(got) != 2 || got[0] != "initialize" || got[1] != "close" {
+		t.Errorf("expected the second transformer to be initialized and closed, got %v", got)
+	}
+}
+
+func TestChainTransformer_ReturnsErrorOfSecond(t *testing.T) {
+	second := &recordingTransformer{err: errors.New("write failed")}
+	chain := NewChainTransformer(func(w io.Writer) SSETransformer {
+		return NewPassthroughTransformer(w)
+	}, second)
+
+	err := chain.Transform(&sse.Event{Data: `{"id":"1"}`})
+	if err == nil || err.Error() != "write failed" {
+		t.Errorf("expected the error of the second transformer, got %v", err)
+	}
+	if err := chain.Flush(); err != nil {
+		t.Errorf("expected the error to be reported once, got %v", err)
+	}
+}
@@ -16,6 +16,29 @@ type ResponseIDGetter interface {
 	GetResponseID() string
 }
 
+// Transformer states reported by StateReporter.
+const (
+	// StateWaiting means no content has been emitted yet.
+	StateWaiting = "waiting"
+	// StateReasoning means reasoning (thinking) content is being emitted.
+	StateReasoning = "reasoning"
+	// StateText means text content is being emitted.
+	StateText = "text"
+	// StateToolCall means a tool call is being emitted, including one parsed
+	// from tool call markup in the model output.
+	StateToolCall = "tool_call"
+	// StateDone means the response has been completed or cancelled.
+	StateDone = "done"
+)
+
+// StateReporter is an optional interface for transformers that can report what
+// they are emitting. This is used to show stream progress in /admin/streams.
+type StateReporter interface {
+	// State returns one of StateWaiting, StateReasoning, StateText,
+	// StateToolCall or StateDone.
+	State() string
+}
+
 // SSETransformer defines the interface for transforming server-sent events.
 // Implementations process SSE events and write transformed output.
 //
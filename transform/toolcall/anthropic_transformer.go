@@ -1,11 +1,13 @@
 package toolcall
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
 
+	"ai-proxy/events"
 	"ai-proxy/logging"
 	"ai-proxy/transform"
 	"ai-proxy/types"
@@ -37,6 +39,8 @@ type AnthropicTransformer struct {
 
 	// Kimi tool call extraction
 	kimiToolCallTransform bool
+
+	ctx context.Context
 }
 
 type anthropicState int
@@ -58,6 +62,11 @@ func NewAnthropicTransformer(output io.Writer) *AnthropicTransformer {
 	}
 }
 
+// SetContext sets the request context, whose lifecycle events extracted tool calls are published to.
+func (t *AnthropicTransformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+}
+
 // SetGLM5ToolCallTransform enables or disables GLM-5 XML tool call extraction.
 func (t *AnthropicTransformer) SetGLM5ToolCallTransform(enabled bool) {
 	t.glm5ToolCallTransform = enabled
@@ -630,6 +639,7 @@ func (t *AnthropicTransformer) makeTextBlockStop(index int) []byte {
 
 func (t *AnthropicTransformer) makeToolUseBlockStart(name string) []byte {
 	t.toolsEmitted = true
+	events.FromContext(t.ctx).ToolCallExtracted(name)
 	event := types.Event{
 		Type:         "content_block_start",
 		Index:        intPtr(t.blockIndex),
@@ -759,3 +769,20 @@ func (t *AnthropicTransformer) Initialize() error {
 func (t *AnthropicTransformer) HandleCancel() error {
 	return nil
 }
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *AnthropicTransformer) State() string {
+	switch {
+	case t.state == anthropicStateInSection, t.state == anthropicStateReadingID, t.state == anthropicStateReadingArgs:
+		return transform.StateToolCall
+	case t.inThinking:
+		return transform.StateReasoning
+	case t.inText:
+		return transform.StateText
+	case t.toolsEmitted:
+		return transform.StateToolCall
+	default:
+		return transform.StateWaiting
+	}
+}
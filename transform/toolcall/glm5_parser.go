@@ -34,6 +34,8 @@ type GLM5Parser struct {
 	currentKey    string
 	currentValue  strings.Builder
 	args          map[string]string
+	// markup is the raw text of the tool call being parsed, from <tool_call> on.
+	markup string
 }
 
 // NewGLM5Parser creates a new GLM-5 parser.
@@ -47,6 +49,9 @@ func NewGLM5Parser() *GLM5Parser {
 // Parse processes text and returns any complete tool call events.
 // Text is buffered until complete tool calls are recognized.
 func (p *GLM5Parser) Parse(text string) []Event {
+	if p.state != glm5StateIdle {
+		p.markup += text
+	}
 	p.buf += text
 	return p.processBuffer()
 }
@@ -116,6 +121,7 @@ func (p *GLM5Parser) processIdle() []Event {
 	}
 
 	// Remove up to and including <tool_call>
+	p.markup = p.buf[idx:]
 	p.buf = p.buf[idx+len("<tool_call>"):]
 	p.state = glm5StateInToolCall
 	p.toolName = ""
@@ -172,6 +178,7 @@ func (p *GLM5Parser) processInToolCall() []Event {
 		// </tool_call> comes first - emit the tool call
 		p.buf = p.buf[endIdx+len("</tool_call>"):]
 		p.state = glm5StateIdle
+		p.markup = ""
 		return p.emitToolCallEvents()
 	}
 
@@ -269,6 +276,7 @@ func (p *GLM5Parser) emitToolCallEvents() []Event {
 	argsBuilder.WriteString("}")
 
 	toolIndex := 0 // GLM-5 typically has one tool call at a time
+	toolCallsExtracted.Inc("glm5")
 
 	return []Event{
 		{Type: EventToolStart, ID: generateToolCallID(toolIndex), Name: p.toolName, Index: toolIndex},
@@ -313,6 +321,16 @@ func (p *GLM5Parser) Reset() {
 	p.currentKey = ""
 	p.currentValue.Reset()
 	p.args = make(map[string]string)
+	p.markup = ""
+}
+
+// Buffer returns the raw text the parser holds back: the markup of an
+// unterminated tool call, or text ending in a partial <tool_call> tag.
+func (p *GLM5Parser) Buffer() string {
+	if p.state == glm5StateIdle {
+		return p.buf
+	}
+	return p.markup
 }
 
 // State returns the current parser state (for testing).
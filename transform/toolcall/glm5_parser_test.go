@@ -220,3 +220,30 @@ func TestGLM5Parser_Reset(t *testing.T) {
 		t.Fatalf("expected 3 events after reset, got %d", len(events))
 	}
 }
+
+func TestGLM5Parser_BufferUnterminated(t *testing.T) {
+	p := NewGLM5Parser()
+	events := p.Parse(`Checking <tool_call>get_weather<arg_key>city</arg_key><arg_va`)
+	if len(events) != 1 || events[0].Text != "Checking " {
+		t.Fatalf("expected only the text before the tool call, got %v", events)
+	}
+	p.Parse(`lue>Par`)
+
+	if got, want := p.Buffer(), `<tool_call>get_weather<arg_key>city</arg_key><arg_value>Par`; got != want {
+		t.Errorf("Buffer() = %q, want %q", got, want)
+	}
+	p.Reset()
+	if p.Buffer() != "" {
+		t.Errorf("expected an empty buffer after reset, got %q", p.Buffer())
+	}
+}
+
+func TestGLM5Parser_BufferAfterToolCall(t *testing.T) {
+	p := NewGLM5Parser()
+	p.Parse(`<tool_call>f<arg_key>k</arg_key><arg_value>v</arg_value></tool_call>done <tool`)
+
+	// Text ending in a partial tag is held back until the tag is complete
+	if got := p.Buffer(); got != "done <tool" {
+		t.Errorf("expected the text after the tool call to be held back, got %q", got)
+	}
+}
@@ -560,6 +560,7 @@ func (p *Parser) processReadingArgs() []Event {
 	}
 	// Emit the tool end event.
 	events = append(events, Event{Type: EventToolEnd, Index: p.toolIndex})
+	toolCallsExtracted.Inc("kimi")
 	// Remove processed content and CallEnd from buffer.
 	p.buf = p.buf[endIdx+len(p.tokens.CallEnd):]
 	// Increment tool index for next tool call.
@@ -0,0 +1,8 @@
+package toolcall
+
+import "ai-proxy/metrics"
+
+// toolCallsExtracted counts tool calls extracted from model text output,
+// labeled by the markup they were written in: "kimi" or "glm5".
+var toolCallsExtracted = metrics.NewCounterVec("ai_proxy_tool_calls_extracted_total",
+	"Tool calls extracted from Kimi or GLM-5 text markup.", "format")
@@ -0,0 +1,19 @@
+package toolcall
+
+import "testing"
+
+func TestToolCallsExtractedMetric(t *testing.T) {
+	kimi, glm5 := toolCallsExtracted.Value("kimi"), toolCallsExtracted.Value("glm5")
+
+	p := NewParser(DefaultTokens)
+	p.Parse(`<|tool_calls_section_begin|><|tool_call_begin|>bash<|tool_call_argument_begin|>{"command":"ls"}<|tool_call_end|>`)
+	p.Parse(`<|tool_call_begin|>bash<|tool_call_argument_begin|>{"command":"pwd"}<|tool_call_end|><|tool_calls_section_end|>`)
+	NewGLM5Parser().Parse(`<tool_call>exec_command<arg_key>cmd</arg_key><arg_value>echo hello</arg_value></tool_call>`)
+
+	if got := toolCallsExtracted.Value("kimi") - kimi; got != 2 {
+		t.Errorf("kimi tool calls counted = %v, want 2", got)
+	}
+	if got := toolCallsExtracted.Value("glm5") - glm5; got != 1 {
+		t.Errorf("glm5 tool calls counted = %v, want 1", got)
+	}
+}
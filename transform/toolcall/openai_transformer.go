@@ -1,9 +1,11 @@
 package toolcall
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 
+	"ai-proxy/events"
 	"ai-proxy/logging"
 	"ai-proxy/transform"
 	"ai-proxy/types"
@@ -19,8 +21,10 @@ type OpenAITransformer struct {
 	messageID             string
 	model                 string
 	inReasoning           bool
+	inToolCalls           bool
 	toolCallTransform     bool
 	glm5ToolCallTransform bool
+	ctx                   context.Context
 }
 
 func NewOpenAITransformer(output io.Writer) *OpenAITransformer {
@@ -32,6 +36,11 @@ func NewOpenAITransformer(output io.Writer) *OpenAITransformer {
 	}
 }
 
+// SetContext sets the request context, whose lifecycle events extracted tool calls are published to.
+func (t *OpenAITransformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+}
+
 // SetKimiToolCallTransform enables or disables tool call extraction from reasoning content.
 func (t *OpenAITransformer) SetKimiToolCallTransform(enabled bool) {
 	t.toolCallTransform = enabled
@@ -79,11 +88,13 @@ func (t *OpenAITransformer) handleChunk(chunk types.Chunk, rawData string) error
 
 	if delta.Content != "" {
 		t.inReasoning = false
+		t.inToolCalls = false
 		return t.write(t.formatter.FormatContent(delta.Content))
 	}
 
 	if len(delta.ToolCalls) > 0 {
 		t.inReasoning = false
+		t.inToolCalls = true
 		for _, tc := range delta.ToolCalls {
 			if tc.ID != "" && tc.Function.Name != "" {
 				if err := t.write(t.formatter.FormatToolStart(tc.ID, tc.Function.Name, tc.Index)); err != nil {
@@ -106,6 +117,7 @@ func (t *OpenAITransformer) handleChunk(chunk types.Chunk, rawData string) error
 
 	if text != "" {
 		t.inReasoning = true
+		t.inToolCalls = false
 		return t.processText(text)
 	}
 
@@ -163,6 +175,7 @@ func (t *OpenAITransformer) writeEvent(e Event) error {
 		return t.write(t.formatter.FormatContent(e.Text))
 	case EventToolStart:
 		logging.InfoMsg("[%s] Tool call parsed: name=%s, id=%s, index=%d", t.messageID, e.Name, e.ID, e.Index)
+		events.FromContext(t.ctx).ToolCallExtracted(e.Name)
 		return t.write(t.formatter.FormatToolStart(e.ID, e.Name, e.Index))
 	case EventToolArgs:
 		return t.write(t.formatter.FormatToolArgs(e.Args, e.Index))
@@ -228,3 +241,18 @@ func (t *OpenAITransformer) Initialize() error {
 func (t *OpenAITransformer) HandleCancel() error {
 	return nil
 }
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *OpenAITransformer) State() string {
+	switch {
+	case t.messageID == "":
+		return transform.StateWaiting
+	case t.inToolCalls, t.inReasoning && !t.parser.IsIdle():
+		return transform.StateToolCall
+	case t.inReasoning:
+		return transform.StateReasoning
+	default:
+		return transform.StateText
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"ai-proxy/capture"
 	"ai-proxy/conversation"
+	"ai-proxy/events"
 	"ai-proxy/logging"
 	"ai-proxy/summarizer"
 	"ai-proxy/transform"
@@ -90,6 +91,8 @@ type ResponsesTransformer struct {
 
 	// userID is the owner of this conversation for access control
 	userID string
+	// orgID is the organization of the conversation owner
+	orgID string
 
 	// reasoningSummaryMode controls how reasoning is summarized.
 	// Values: "" (no summary), "concise", "detailed"
@@ -623,6 +626,11 @@ func (t *ResponsesTransformer) SetUserID(userID string) {
 	t.userID = userID
 }
 
+// SetOrgID sets the organization ID of the conversation owner.
+func (t *ResponsesTransformer) SetOrgID(orgID string) {
+	t.orgID = orgID
+}
+
 // SetReasoningSummaryMode sets the reasoning summary mode.
 // Values: "" (no summary), "concise", "detailed"
 // When set, the summarizer service is called to generate a summary of reasoning content.
@@ -652,6 +660,7 @@ func (t *ResponsesTransformer) SetGLM5ToolCallTransform(enabled bool) {
 
 // SetContext sets the request context for cache status tracking.
 // When a conversation is stored, the cache-created status is set in the capture context.
+// Extracted tool calls are published to the request's lifecycle events.
 func (t *ResponsesTransformer) SetContext(ctx context.Context) {
 	t.ctx = ctx
 }
@@ -920,6 +929,7 @@ func (t *ResponsesTransformer) writeParserEvent(e Event) error {
 	case EventToolStart:
 		// Start a new function_call output item
 		logging.InfoMsg("[%s] Tool call extracted: id=%s, name=%s", t.messageID, e.ID, e.Name)
+		events.FromContext(t.ctx).ToolCallExtracted(e.Name)
 		t.extractedToolArgs.Reset() // Reset args builder for new tool call
 		return t.emitToolCallStart(e.ID, e.Name)
 	case EventToolArgs:
@@ -1234,6 +1244,7 @@ func (t *ResponsesTransformer) storeConversation() {
 		ID:                 t.responseID,
 		PreviousResponseID: t.previousResponseID,
 		UserID:             t.userID,
+		OrgID:              t.orgID,
 		Input:              t.inputItems,
 		Output:             outputItems,
 		ReasoningItemID:    reasoningItemID,
@@ -1449,3 +1460,20 @@ func (t *ResponsesTransformer) Initialize() error {
 func (t *ResponsesTransformer) GetResponseID() string {
 	return t.responseID
 }
+
+// State reports what the transformer is emitting.
+// Implements transform.StateReporter interface.
+func (t *ResponsesTransformer) State() string {
+	switch {
+	case t.stopReason != "":
+		return transform.StateDone
+	case t.inToolCall, t.inReasoning && !t.parser.IsIdle():
+		return transform.StateToolCall
+	case t.inReasoning:
+		return transform.StateReasoning
+	case t.inText:
+		return transform.StateText
+	default:
+		return transform.StateWaiting
+	}
+}
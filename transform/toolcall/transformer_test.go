@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"ai-proxy/transform"
 	"ai-proxy/types"
 
 	"github.com/tmaxmax/go-sse"
@@ -701,3 +702,33 @@ func TestGLM5RealWorldChunkingFromSSELog(t *testing.T) {
 		t.Error("BUG: function arguments were not extracted")
 	}
 }
+
+func TestOpenAITransformer_State(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tr := NewOpenAITransformer(buf)
+	if got := tr.State(); got != transform.StateWaiting {
+		t.Errorf("State() before any chunk = %q, want %q", got, transform.StateWaiting)
+	}
+
+	send := func(delta types.Delta) {
+		t.Helper()
+		data, _ := json.Marshal(types.Chunk{ID: "msg-1", Model: "kimi-k2.5", Choices: []types.Choice{{Delta: delta}}})
+		if err := tr.Transform(&sse.Event{Data: string(data)}); err != nil {
+			t.Fatalf("Transform failed: %v", err)
+		}
+	}
+
+	send(types.Delta{Reasoning: "Let me check."})
+	if got := tr.State(); got != transform.StateReasoning {
+		t.Errorf("State() while reasoning = %q, want %q", got, transform.StateReasoning)
+	}
+	// Kimi tool call markup in the reasoning is reported as a tool call
+	send(types.Delta{Reasoning: "<|tool_calls_section_begin|><|tool_call_begin|>functions.bash:0"})
+	if got := tr.State(); got != transform.StateToolCall {
+		t.Errorf("State() inside tool call markup = %q, want %q", got, transform.StateToolCall)
+	}
+	send(types.Delta{Content: "Done."})
+	if got := tr.State(); got != transform.StateText {
+		t.Errorf("State() while writing text = %q, want %q", got, transform.StateText)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/tmaxmax/go-sse"
 
+	"ai-proxy/events"
 	"ai-proxy/logging"
 	"ai-proxy/transform"
 	"ai-proxy/types"
@@ -52,6 +53,8 @@ type Transformer struct {
 	pendingBlocks map[string]*pendingBlock // keyed by block ID
 	blockIndex    int                      // tracks current content block index
 	indexToID     map[int]string           // maps index to block ID for delta matching
+
+	ctx context.Context // request context, whose lifecycle events searches are published to
 }
 
 // NewTransformer creates a new web search interception transformer.
@@ -64,6 +67,15 @@ func NewTransformer(base transform.SSETransformer, service Service) *Transformer
 	}
 }
 
+// SetContext sets the request context on this transformer and on the base
+// transformer, if it accepts one.
+func (t *Transformer) SetContext(ctx context.Context) {
+	t.ctx = ctx
+	if ct, ok := t.base.(interface{ SetContext(context.Context) }); ok {
+		ct.SetContext(ctx)
+	}
+}
+
 // Initialize prepares the transformer by delegating to the base transformer.
 func (t *Transformer) Initialize() error {
 	return t.base.Initialize()
@@ -234,6 +246,11 @@ func (t *Transformer) handleContentBlockStop(event *sse.Event, evt *eventParser)
 
 	// Execute the search
 	results, err := t.executeSearch(&input)
+	searched := events.Event{Type: events.WebSearchExecuted, Query: input.Query, Results: len(results)}
+	if err != nil {
+		searched.Error = err.Error()
+	}
+	events.FromContext(t.ctx).Publish(searched)
 	if err != nil {
 		logging.ErrorMsg("[WebSearch] Search failed: %v", err)
 		return t.emitWebSearchResult(pending.blockID, []types.WebSearchResult{{
@@ -326,6 +343,15 @@ func (t *Transformer) HandleCancel() error {
 	return t.base.HandleCancel()
 }
 
+// State reports the state of the base transformer, if it reports one.
+// Implements transform.StateReporter interface.
+func (t *Transformer) State() string {
+	if reporter, ok := t.base.(transform.StateReporter); ok {
+		return reporter.State()
+	}
+	return ""
+}
+
 // Close cleans up resources and closes the base transformer.
 func (t *Transformer) Close() error {
 	// Clean up any pending blocks
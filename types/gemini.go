@@ -0,0 +1,196 @@
+// Package types defines data structures for OpenAI and Anthropic API formats.
+// This file contains types specific to the Google Gemini generateContent API format.
+package types
+
+import "encoding/json"
+
+// GeminiRequest represents a Gemini generateContent / streamGenerateContent request.
+// The model is not part of the body; it is named in the request URL.
+type GeminiRequest struct {
+	// Contents is the conversation history, alternating "user" and "model" turns.
+	Contents []GeminiContent `json:"contents"`
+	// SystemInstruction holds the system prompt. Its role is ignored.
+	SystemInstruction *GeminiContent `json:"systemInstruction,omitempty"`
+	// Tools lists the functions the model may call.
+	Tools []GeminiTool `json:"tools,omitempty"`
+	// ToolConfig restricts how the model calls functions.
+	ToolConfig *GeminiToolConfig `json:"toolConfig,omitempty"`
+	// GenerationConfig holds sampling and output parameters.
+	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is one turn of a Gemini conversation.
+type GeminiContent struct {
+	// Role is "user" or "model". Function responses are sent in "user" turns.
+	Role string `json:"role,omitempty"`
+	// Parts holds the turn's text, media, function calls and function responses.
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is one piece of a Gemini turn. Exactly one of Text, InlineData,
+// FileData, FunctionCall or FunctionResponse is set.
+type GeminiPart struct {
+	// Text is plain text content.
+	Text string `json:"text,omitempty"`
+	// Thought marks Text as the model's reasoning rather than its answer.
+	Thought bool `json:"thought,omitempty"`
+	// ThoughtSignature is an opaque signature of the model's reasoning,
+	// returned with function calls and sent back with them on later turns.
+	ThoughtSignature string `json:"thoughtSignature,omitempty"`
+	// InlineData is base64-encoded media, such as an image.
+	InlineData *GeminiBlob `json:"inlineData,omitempty"`
+	// FileData references media by URI.
+	FileData *GeminiFileData `json:"fileData,omitempty"`
+	// FunctionCall is a call of a declared function by the model.
+	FunctionCall *GeminiFunctionCall `json:"functionCall,omitempty"`
+	// FunctionResponse is the result of a function call.
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline base64-encoded media.
+type GeminiBlob struct {
+	// MimeType is the media type, e.g. "image/png".
+	MimeType string `json:"mimeType"`
+	// Data is the base64-encoded content.
+	Data string `json:"data"`
+}
+
+// GeminiFileData is media referenced by URI.
+type GeminiFileData struct {
+	// MimeType is the media type, if known.
+	MimeType string `json:"mimeType,omitempty"`
+	// FileURI is the URI of the media.
+	FileURI string `json:"fileUri"`
+}
+
+// GeminiFunctionCall is a function call made by the model.
+type GeminiFunctionCall struct {
+	// ID identifies the call, if the model assigned one.
+	ID string `json:"id,omitempty"`
+	// Name is the name of the called function.
+	Name string `json:"name"`
+	// Args holds the call's arguments as a JSON object.
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// GeminiFunctionResponse is the result of a function call, sent back to the model.
+type GeminiFunctionResponse struct {
+	// ID is the ID of the answered call, if it had one.
+	ID string `json:"id,omitempty"`
+	// Name is the name of the called function.
+	Name string `json:"name"`
+	// Response is the function's result as a JSON object.
+	Response json.RawMessage `json:"response"`
+}
+
+// GeminiTool groups function declarations.
+type GeminiTool struct {
+	// FunctionDeclarations lists the callable functions.
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+// GeminiFunctionDeclaration declares a function the model may call.
+type GeminiFunctionDeclaration struct {
+	// Name is the function name.
+	Name string `json:"name"`
+	// Description tells the model what the function does.
+	Description string `json:"description,omitempty"`
+	// ParametersJSONSchema is the JSON Schema of the function's arguments.
+	// Unlike "parameters", it accepts full JSON Schema as clients send it.
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+// GeminiToolConfig configures function calling.
+type GeminiToolConfig struct {
+	// FunctionCallingConfig selects the function calling mode.
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+// GeminiFunctionCallingConfig selects when the model calls functions.
+type GeminiFunctionCallingConfig struct {
+	// Mode is "AUTO", "ANY" (must call a function) or "NONE".
+	Mode string `json:"mode"`
+	// AllowedFunctionNames restricts the functions callable in "ANY" mode.
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// GeminiGenerationConfig holds Gemini sampling and output parameters.
+type GeminiGenerationConfig struct {
+	// Temperature controls randomness in output generation. Range: 0.0 to 2.0.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP controls diversity via nucleus sampling.
+	TopP *float64 `json:"topP,omitempty"`
+	// TopK limits sampling to the K most likely tokens.
+	TopK int `json:"topK,omitempty"`
+	// MaxOutputTokens is the maximum number of tokens to generate.
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	// StopSequences stop generation when produced.
+	StopSequences []string `json:"stopSequences,omitempty"`
+	// CandidateCount is the number of candidates to generate.
+	CandidateCount int `json:"candidateCount,omitempty"`
+	// PresencePenalty penalizes tokens that already appeared.
+	PresencePenalty *float64 `json:"presencePenalty,omitempty"`
+	// FrequencyPenalty penalizes tokens by how often they appeared.
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+	// Seed makes sampling deterministic.
+	Seed *int `json:"seed,omitempty"`
+	// ResponseMimeType is "application/json" for JSON output.
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+	// ResponseJSONSchema constrains JSON output to a JSON Schema.
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+	// ThinkingConfig configures the model's reasoning.
+	ThinkingConfig *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// GeminiThinkingConfig configures the reasoning of thinking models.
+type GeminiThinkingConfig struct {
+	// IncludeThoughts streams the model's reasoning as thought parts.
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+	// ThinkingBudget caps the reasoning tokens; 0 disables thinking.
+	ThinkingBudget *int `json:"thinkingBudget,omitempty"`
+}
+
+// GeminiResponse is a generateContent response, or one chunk of a
+// streamGenerateContent?alt=sse stream.
+type GeminiResponse struct {
+	// Candidates holds the generated candidates; the proxy uses the first.
+	Candidates []GeminiCandidate `json:"candidates,omitempty"`
+	// PromptFeedback reports a blocked prompt, in which case there are no candidates.
+	PromptFeedback *GeminiPromptFeedback `json:"promptFeedback,omitempty"`
+	// UsageMetadata holds the token counts so far; the last chunk has the totals.
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+	// ModelVersion is the model that generated the response.
+	ModelVersion string `json:"modelVersion,omitempty"`
+	// ResponseID identifies the response.
+	ResponseID string `json:"responseId,omitempty"`
+}
+
+// GeminiCandidate is one generated response candidate.
+type GeminiCandidate struct {
+	// Content holds the parts generated in this chunk.
+	Content *GeminiContent `json:"content,omitempty"`
+	// FinishReason is set on the last chunk: "STOP", "MAX_TOKENS", "SAFETY", ...
+	FinishReason string `json:"finishReason,omitempty"`
+	// Index is the candidate's index.
+	Index int `json:"index"`
+}
+
+// GeminiPromptFeedback reports why a prompt was blocked.
+type GeminiPromptFeedback struct {
+	// BlockReason is set when the prompt was blocked, e.g. "SAFETY".
+	BlockReason string `json:"blockReason,omitempty"`
+}
+
+// GeminiUsageMetadata holds Gemini token counts.
+type GeminiUsageMetadata struct {
+	// PromptTokenCount is the number of input tokens, including cached ones.
+	PromptTokenCount int `json:"promptTokenCount"`
+	// CandidatesTokenCount is the number of generated tokens, excluding reasoning.
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	// ThoughtsTokenCount is the number of reasoning tokens.
+	ThoughtsTokenCount int `json:"thoughtsTokenCount,omitempty"`
+	// CachedContentTokenCount is the number of input tokens read from the cache.
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+	// TotalTokenCount is the sum of all token counts.
+	TotalTokenCount int `json:"totalTokenCount"`
+}
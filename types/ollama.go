@@ -0,0 +1,218 @@
+// Package types defines data structures for OpenAI and Anthropic API formats.
+// This file contains types specific to the Ollama API format.
+package types
+
+import "encoding/json"
+
+// OllamaChatRequest represents an Ollama /api/chat request.
+type OllamaChatRequest struct {
+	// Model is the name of the model to use.
+	Model string `json:"model"`
+	// Messages is the conversation history.
+	Messages []OllamaMessage `json:"messages"`
+	// Tools lists the functions the model may call, in the OpenAI tool format.
+	Tools []Tool `json:"tools,omitempty"`
+	// Format is "json" or a JSON Schema the response must follow.
+	Format json.RawMessage `json:"format,omitempty"`
+	// Options holds sampling parameters.
+	Options *OllamaOptions `json:"options,omitempty"`
+	// Stream defaults to true; false returns a single response object.
+	Stream *bool `json:"stream,omitempty"`
+	// Think is true or false, or a reasoning level: "low", "medium" or "high".
+	Think json.RawMessage `json:"think,omitempty"`
+	// KeepAlive controls how long Ollama keeps the model loaded. Ignored by the proxy.
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// OllamaGenerateRequest represents an Ollama /api/generate request.
+type OllamaGenerateRequest struct {
+	// Model is the name of the model to use.
+	Model string `json:"model"`
+	// Prompt is the prompt to complete.
+	Prompt string `json:"prompt"`
+	// Suffix is text after the completion, for fill-in-the-middle. Ignored by the proxy.
+	Suffix string `json:"suffix,omitempty"`
+	// System is the system prompt.
+	System string `json:"system,omitempty"`
+	// Images holds base64-encoded images for multimodal models.
+	Images []string `json:"images,omitempty"`
+	// Format is "json" or a JSON Schema the response must follow.
+	Format json.RawMessage `json:"format,omitempty"`
+	// Options holds sampling parameters.
+	Options *OllamaOptions `json:"options,omitempty"`
+	// Stream defaults to true; false returns a single response object.
+	Stream *bool `json:"stream,omitempty"`
+	// Think is true or false, or a reasoning level: "low", "medium" or "high".
+	Think json.RawMessage `json:"think,omitempty"`
+	// Template, Context, Raw and KeepAlive control local prompt templating
+	// and model loading. Ignored by the proxy.
+	Template  string          `json:"template,omitempty"`
+	Context   []int           `json:"context,omitempty"`
+	Raw       bool            `json:"raw,omitempty"`
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// OllamaMessage is one message of an Ollama conversation.
+type OllamaMessage struct {
+	// Role is "system", "user", "assistant" or "tool".
+	Role string `json:"role"`
+	// Content is the message text.
+	Content string `json:"content"`
+	// Thinking is the model's reasoning, on assistant messages.
+	Thinking string `json:"thinking,omitempty"`
+	// Images holds base64-encoded images, on user messages.
+	Images []string `json:"images,omitempty"`
+	// ToolCalls holds the function calls of an assistant message.
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	// ToolName names the function whose result a tool message carries.
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// OllamaToolCall is a function call made by the model.
+type OllamaToolCall struct {
+	// Function holds the called function and its arguments.
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+// OllamaToolCallFunction is the function of an Ollama tool call.
+type OllamaToolCallFunction struct {
+	// Index is the position of the call among the message's calls.
+	Index int `json:"index,omitempty"`
+	// Name is the name of the called function.
+	Name string `json:"name"`
+	// Arguments holds the call's arguments as a JSON object, not a string.
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// OllamaOptions holds the Ollama sampling parameters the proxy forwards.
+// Runner options such as num_ctx and num_gpu are ignored.
+type OllamaOptions struct {
+	// Temperature controls randomness in output generation.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP controls diversity via nucleus sampling.
+	TopP *float64 `json:"top_p,omitempty"`
+	// TopK limits sampling to the K most likely tokens.
+	TopK int `json:"top_k,omitempty"`
+	// NumPredict is the maximum number of tokens to generate; -1 is unlimited.
+	NumPredict int `json:"num_predict,omitempty"`
+	// Stop holds stop sequences.
+	Stop []string `json:"stop,omitempty"`
+	// Seed makes sampling deterministic.
+	Seed *int `json:"seed,omitempty"`
+	// PresencePenalty penalizes tokens that already appeared.
+	PresencePenalty float64 `json:"presence_penalty,omitempty"`
+	// FrequencyPenalty penalizes tokens by how often they appeared.
+	FrequencyPenalty float64 `json:"frequency_penalty,omitempty"`
+}
+
+// OllamaChatResponse is one line of an /api/chat NDJSON stream, or the whole
+// non-streaming response.
+type OllamaChatResponse struct {
+	// Model is the model name the client requested.
+	Model string `json:"model"`
+	// CreatedAt is the RFC 3339 time the line was produced.
+	CreatedAt string `json:"created_at"`
+	// Message holds the generated content of this line.
+	Message OllamaMessage `json:"message"`
+	// Done is true on the last line, which carries the statistics.
+	Done bool `json:"done"`
+	OllamaStats
+}
+
+// OllamaGenerateResponse is one line of an /api/generate NDJSON stream, or the
+// whole non-streaming response.
+type OllamaGenerateResponse struct {
+	// Model is the model name the client requested.
+	Model string `json:"model"`
+	// CreatedAt is the RFC 3339 time the line was produced.
+	CreatedAt string `json:"created_at"`
+	// Response holds the generated text of this line.
+	Response string `json:"response"`
+	// Thinking holds the generated reasoning of this line.
+	Thinking string `json:"thinking,omitempty"`
+	// Done is true on the last line, which carries the statistics.
+	Done bool `json:"done"`
+	OllamaStats
+}
+
+// OllamaStats holds the statistics of the last line of an Ollama response.
+// Durations are in nanoseconds.
+type OllamaStats struct {
+	// DoneReason is "stop" or "length".
+	DoneReason string `json:"done_reason,omitempty"`
+	// TotalDuration is the time spent on the whole request.
+	TotalDuration int64 `json:"total_duration,omitempty"`
+	// LoadDuration is the time spent loading the model; always 0 behind the proxy.
+	LoadDuration int64 `json:"load_duration,omitempty"`
+	// PromptEvalCount is the number of input tokens.
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	// PromptEvalDuration is the time until the first token.
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	// EvalCount is the number of generated tokens.
+	EvalCount int `json:"eval_count,omitempty"`
+	// EvalDuration is the time spent generating after the first token.
+	EvalDuration int64 `json:"eval_duration,omitempty"`
+}
+
+// OllamaErrorResponse is an Ollama error body, also sent as an NDJSON line
+// when a stream fails.
+type OllamaErrorResponse struct {
+	// Error is the error message.
+	Error string `json:"error"`
+}
+
+// OllamaTagsResponse is the /api/tags response.
+type OllamaTagsResponse struct {
+	// Models lists the available models.
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaModel describes one model of an /api/tags response.
+type OllamaModel struct {
+	// Name is the model name clients request.
+	Name string `json:"name"`
+	// Model repeats Name.
+	Model string `json:"model"`
+	// ModifiedAt is the RFC 3339 time the model was last changed.
+	ModifiedAt string `json:"modified_at"`
+	// Size is the model's size in bytes; 0 for remote models.
+	Size int64 `json:"size"`
+	// Digest identifies the model's contents.
+	Digest string `json:"digest"`
+	// Details describes the model.
+	Details OllamaModelDetails `json:"details"`
+}
+
+// OllamaModelDetails describes a model's format and family.
+type OllamaModelDetails struct {
+	ParentModel       string   `json:"parent_model"`
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// OllamaShowRequest is an /api/show request.
+type OllamaShowRequest struct {
+	// Model is the model to describe.
+	Model string `json:"model"`
+	// Name is the deprecated spelling of Model.
+	Name string `json:"name,omitempty"`
+}
+
+// OllamaShowResponse is the /api/show response.
+type OllamaShowResponse struct {
+	// Modelfile, Parameters and Template describe local models; empty behind the proxy.
+	Modelfile  string `json:"modelfile"`
+	Parameters string `json:"parameters"`
+	Template   string `json:"template"`
+	// Details describes the model.
+	Details OllamaModelDetails `json:"details"`
+	// ModelInfo holds model metadata keyed like "general.architecture".
+	ModelInfo map[string]interface{} `json:"model_info"`
+	// Capabilities lists what the model supports, e.g. "completion" and "tools".
+	Capabilities []string `json:"capabilities"`
+	// ModifiedAt is the RFC 3339 time the model was last changed.
+	ModifiedAt string `json:"modified_at"`
+}
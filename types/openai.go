@@ -229,6 +229,48 @@ type Choice struct {
 	FinishReason *string `json:"finish_reason,omitempty"`
 }
 
+// ChatCompletion represents a non-streaming response from the OpenAI Chat Completions API.
+// Returned by /v1/chat/completions when the request sets stream to false.
+type ChatCompletion struct {
+	// ID is a unique identifier for the completion.
+	ID string `json:"id"`
+	// Object identifies the object type.
+	// Always "chat.completion" for non-streaming responses.
+	Object string `json:"object"`
+	// Created is the Unix timestamp of completion creation.
+	Created int64 `json:"created"`
+	// Model is the model used for generation.
+	Model string `json:"model"`
+	// Choices is an array of completion choices.
+	Choices []CompletionChoice `json:"choices"`
+	// Usage contains token usage statistics.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// CompletionChoice represents a single complete choice in a ChatCompletion.
+type CompletionChoice struct {
+	// Index is the position of this choice in the choices array.
+	Index int `json:"index"`
+	// Message is the complete assistant message for this choice.
+	Message CompletionMessage `json:"message"`
+	// FinishReason indicates why generation stopped.
+	// Values: "stop", "length", "tool_calls", "content_filter".
+	FinishReason string `json:"finish_reason"`
+}
+
+// CompletionMessage is the assistant message inside a non-streaming choice.
+// Content is a pointer so that tool-call-only messages serialize it as null.
+type CompletionMessage struct {
+	// Role is always "assistant".
+	Role string `json:"role"`
+	// Content is the text content, or nil when the model produced none.
+	Content *string `json:"content"`
+	// ReasoningContent contains the model's reasoning process, if any.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// ToolCalls contains the complete tool calls made by the assistant.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
 // ReasoningDetail represents a reasoning segment in streaming responses.
 // Used by providers like MiniMax when reasoning_split is enabled.
 type ReasoningDetail struct {
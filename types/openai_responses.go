@@ -238,13 +238,38 @@ type OutputItem struct {
 	// Arguments are the function arguments for function_call type.
 	Arguments string `json:"arguments,omitempty"`
 	// Summary contains reasoning summary for reasoning type.
-	Summary string `json:"summary,omitempty"`
+	Summary SummaryText `json:"summary,omitempty"`
 	// Action for computer_use_call.
 	Action interface{} `json:"action,omitempty"`
 	// PendingSafetyChecks for computer_use_call.
 	PendingSafetyChecks []SafetyCheck `json:"pending_safety_checks,omitempty"`
 }
 
+// SummaryText is the reasoning summary of an output item.
+// The Responses API sends it as an array of summary_text parts; older payloads
+// and internal producers use a plain string. Both decode to the joined text.
+type SummaryText string
+
+// UnmarshalJSON accepts either a JSON string or an array of summary_text parts.
+func (s *SummaryText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*s = SummaryText(text)
+		return nil
+	}
+
+	var parts []SummaryTextItem
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	var joined string
+	for _, part := range parts {
+		joined += part.Text
+	}
+	*s = SummaryText(joined)
+	return nil
+}
+
 // SafetyCheck represents a safety check for computer use.
 type SafetyCheck struct {
 	// Code identifies the safety check.
@@ -4,6 +4,7 @@ package websearch
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"ai-proxy/types"
@@ -108,17 +109,26 @@ func InitDefaultService(cfg types.WebSearchConfig) *Service {
 	})
 }
 
-// DefaultService is the global web search service instance.
-var DefaultService *Service
+// defaultService is the global web search service instance.
+var defaultService atomic.Pointer[Service]
+
+// SetDefaultService installs the global web search service, at startup and
+// when a reloaded configuration changes it. Requests that already fetched
+// an adapter keep using the previous service.
+//
+// @param s - the service, nil to disable web search
+func SetDefaultService(s *Service) {
+	defaultService.Store(s)
+}
 
 // GetDefaultService returns the global web search service instance.
-// Returns nil if InitDefaultService hasn't been called or if web search is disabled.
+// Returns nil if SetDefaultService hasn't been called or if web search is disabled.
 func GetDefaultService() *Service {
-	return DefaultService
+	return defaultService.Load()
 }
 
 // GetDefaultAdapter returns an adapter for the default web search service.
 // Returns nil if web search is not enabled.
 func GetDefaultAdapter() *TransformerAdapter {
-	return NewTransformerAdapter(DefaultService)
+	return NewTransformerAdapter(GetDefaultService())
 }
@@ -0,0 +1,8 @@
+package websearch
+
+import "ai-proxy/metrics"
+
+// searchesExecuted counts searches sent to a backend, labeled by backend name
+// and result: "ok", "empty" or "error".
+var searchesExecuted = metrics.NewCounterVec("ai_proxy_web_searches_total",
+	"Web searches executed, by backend and result.", "backend", "result")
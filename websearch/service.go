@@ -142,6 +142,7 @@ func (s *Service) ExecuteSearch(ctx context.Context, toolUseID string, input *We
 
 	results, err := s.backend.Search(ctx, input.Query, opts)
 	if err != nil {
+		searchesExecuted.Inc(s.backend.Name(), "error")
 		logging.ErrorMsg("websearch: search failed for query=%s backend=%s: %v", input.Query, s.backend.Name(), err)
 		return &WebSearchToolResult{
 			ToolUseID: toolUseID,
@@ -154,6 +155,7 @@ func (s *Service) ExecuteSearch(ctx context.Context, toolUseID string, input *We
 	}
 
 	if len(results.Results) == 0 {
+		searchesExecuted.Inc(s.backend.Name(), "empty")
 		return &WebSearchToolResult{
 			ToolUseID: toolUseID,
 			Type:      "tool_result",
@@ -163,6 +165,7 @@ func (s *Service) ExecuteSearch(ctx context.Context, toolUseID string, input *We
 		}
 	}
 
+	searchesExecuted.Inc(s.backend.Name(), "ok")
 	text := s.formatResults(results)
 	return &WebSearchToolResult{
 		ToolUseID: toolUseID,